@@ -0,0 +1,163 @@
+package acl
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CacheTTL specifies how long the permissions of a user are cached.
+var CacheTTL = time.Minute
+
+type cacheEntry struct {
+	permissions []string
+	expires     time.Time
+}
+
+// generation counts the invalidations a load of the permissions of a user started from, those of every user
+// and those of the user.
+type generation struct {
+	all  uint64
+	user uint64
+}
+
+var cache = struct {
+	sync.Mutex
+	entries map[string]cacheEntry
+	// all and users count the invalidations so permissions loaded before one are not cached.
+	all       uint64
+	users     map[string]uint64
+	listeners []func(user string)
+}{entries: map[string]cacheEntry{}, users: map[string]uint64{}}
+
+// CachedPermissions returns the permissions granted to the user identified by its uuid,
+// loading them with UserPermissions at most once per CacheTTL.
+func CachedPermissions(user string) ([]string, error) {
+	var now = time.Now()
+	cache.Lock()
+	entry, ok := cache.entries[user]
+	var loadedAt = generation{all: cache.all, user: cache.users[user]}
+	cache.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.permissions, nil
+	}
+	list, err := UserPermissions(user)
+	if err != nil {
+		return nil, err
+	}
+	storePermissions(user, cacheEntry{permissions: list, expires: now.Add(CacheTTL)}, loadedAt)
+	return list, nil
+}
+
+// storePermissions caches the permissions of the user loaded at the given generation, unless they were
+// invalidated since.
+func storePermissions(user string, entry cacheEntry, loadedAt generation) {
+	cache.Lock()
+	if cache.all == loadedAt.all && cache.users[user] == loadedAt.user {
+		cache.entries[user] = entry
+	}
+	cache.Unlock()
+}
+
+// OnInvalidate registers a function called whenever cached permissions are invalidated.
+// The user is empty when the permissions of every user have been invalidated.
+func OnInvalidate(fn func(user string)) {
+	cache.Lock()
+	cache.listeners = append(cache.listeners, fn)
+	cache.Unlock()
+}
+
+// Invalidate drops the cached permissions of the user identified by its uuid.
+func Invalidate(user string) {
+	cache.Lock()
+	delete(cache.entries, user)
+	cache.users[user]++
+	var listeners = cache.listeners
+	cache.Unlock()
+	for _, fn := range listeners {
+		fn(user)
+	}
+}
+
+// InvalidateAll drops the cached permissions of every user.
+func InvalidateAll() {
+	cache.Lock()
+	cache.entries = map[string]cacheEntry{}
+	cache.users = map[string]uint64{}
+	cache.all++
+	var listeners = cache.listeners
+	cache.Unlock()
+	for _, fn := range listeners {
+		fn("")
+	}
+}
+
+// invalidateUser invalidates the given user, or every user if it is unknown as for bulk deletes.
+func invalidateUser(user string) {
+	if user == "" {
+		InvalidateAll()
+	} else {
+		Invalidate(user)
+	}
+}
+
+// AfterSave invalidates the permissions cache as the permissions of the role changed.
+func (r *RolePermission) AfterSave(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache as the permissions of the role changed.
+func (r *RolePermission) AfterDelete(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache as the role may have been granted to users.
+func (r *Role) AfterDelete(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterSave invalidates the permissions cache of the user.
+func (r *UserRole) AfterSave(tx *gorm.DB) error {
+	invalidateUser(r.User)
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache of the user.
+func (r *UserRole) AfterDelete(tx *gorm.DB) error {
+	invalidateUser(r.User)
+	return nil
+}
+
+// AfterSave invalidates the permissions cache as the roles of the group changed.
+func (r *GroupRole) AfterSave(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache as the roles of the group changed.
+func (r *GroupRole) AfterDelete(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache as the group may have had members.
+func (r *Group) AfterDelete(tx *gorm.DB) error {
+	InvalidateAll()
+	return nil
+}
+
+// AfterSave invalidates the permissions cache of the user.
+func (r *UserGroup) AfterSave(tx *gorm.DB) error {
+	invalidateUser(r.User)
+	return nil
+}
+
+// AfterDelete invalidates the permissions cache of the user.
+func (r *UserGroup) AfterDelete(tx *gorm.DB) error {
+	invalidateUser(r.User)
+	return nil
+}
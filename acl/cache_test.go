@@ -0,0 +1,43 @@
+package acl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStorePermissions(t *testing.T) {
+	defer InvalidateAll()
+	var entry = cacheEntry{permissions: []string{"ORDERS.VIEW"}, expires: time.Now().Add(time.Minute)}
+	var loadedAt = func(user string) generation {
+		cache.Lock()
+		defer cache.Unlock()
+		return generation{all: cache.all, user: cache.users[user]}
+	}
+	var cached = func(user string) bool {
+		cache.Lock()
+		defer cache.Unlock()
+		_, ok := cache.entries[user]
+		return ok
+	}
+
+	var at = loadedAt("alice")
+	Invalidate("alice")
+	storePermissions("alice", entry, at)
+	if cached("alice") {
+		t.Error("expected permissions loaded before an invalidation of the user not to be cached")
+	}
+
+	at = loadedAt("alice")
+	InvalidateAll()
+	storePermissions("alice", entry, at)
+	if cached("alice") {
+		t.Error("expected permissions loaded before an invalidation of every user not to be cached")
+	}
+
+	at = loadedAt("alice")
+	Invalidate("bob")
+	storePermissions("alice", entry, at)
+	if !cached("alice") {
+		t.Error("expected an invalidation of another user not to prevent caching")
+	}
+}
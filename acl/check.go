@@ -0,0 +1,38 @@
+package acl
+
+import (
+	"strings"
+)
+
+// SuperRole specifies the role which passes every permission check.
+var SuperRole = "ADMIN"
+
+// Wildcard is the permission key segment matching any key.
+const Wildcard = "*"
+
+// Match reports whether the permission (APP.KEY) is satisfied by one of the granted permissions.
+// A granted permission ending with `.*` matches every key below its prefix, e.g. ORDERS.* matches
+// ORDERS.VIEW and ORDERS.ITEMS.UPDATE, while `*` matches every permission.
+func Match(granted []string, permission string) bool {
+	permission = strings.ToUpper(permission)
+	for _, item := range granted {
+		item = strings.ToUpper(item)
+		if item == permission || item == Wildcard {
+			return true
+		}
+		if strings.HasSuffix(item, "."+Wildcard) && strings.HasPrefix(permission, strings.TrimSuffix(item, Wildcard)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports whether the user identified by its uuid has been granted the permission (APP.KEY),
+// taking wildcards and the SuperRole into account. Permissions of the user are cached, see CachedPermissions.
+func Check(user string, permission string) bool {
+	list, err := CachedPermissions(user)
+	if err != nil {
+		return false
+	}
+	return Match(list, permission)
+}
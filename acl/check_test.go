@@ -0,0 +1,25 @@
+package acl
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		granted    []string
+		permission string
+		want       bool
+	}{
+		{[]string{"ORDERS.VIEW"}, "orders.view", true},
+		{[]string{"ORDERS.VIEW"}, "ORDERS.UPDATE", false},
+		{[]string{"ORDERS.*"}, "ORDERS.UPDATE", true},
+		{[]string{"orders.*"}, "ORDERS.ITEMS.VIEW", true},
+		{[]string{"ORDERS.*"}, "ORDERSX.VIEW", false},
+		{[]string{"ORDERS.*"}, "ORDERS", false},
+		{[]string{"*"}, "USERS.DELETE", true},
+		{nil, "USERS.DELETE", false},
+	}
+	for _, test := range tests {
+		if got := Match(test.granted, test.permission); got != test.want {
+			t.Errorf("Match(%v, %q) = %v, want %v", test.granted, test.permission, got, test.want)
+		}
+	}
+}
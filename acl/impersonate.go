@@ -0,0 +1,20 @@
+package acl
+
+// Impersonate is the permission allowing a user to act as another user.
+const Impersonate = "ACL.IMPERSONATE"
+
+// Admin is the permission of the administrators: managing roles and groups, the acl tables, and the
+// operational endpoints. It is only held through the Wildcard of the SuperRole unless granted explicitly.
+const Admin = "ACL.ADMIN"
+
+func init() {
+	SetPermission(&App{
+		App:         "ACL",
+		Name:        "Access Control",
+		Description: "Access control management",
+		Permissions: []Permission{
+			{Key: "IMPERSONATE", Name: "Impersonate", Description: "Act as another user"},
+			{Key: "ADMIN", Name: "Administer", Description: "Manage the access control and the operations"},
+		},
+	})
+}
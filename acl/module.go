@@ -0,0 +1,28 @@
+package acl
+
+import (
+	"github.com/getevo/evo/v2/lib/db"
+)
+
+// Module is the application registering the acl models.
+// It is not named App as App represents the permission app model.
+type Module struct {
+}
+
+// Register registers the acl models so they are migrated.
+func (a Module) Register() error {
+	db.UseModel(App{}, Permission{}, Role{}, RolePermission{}, UserRole{}, Group{}, GroupRole{}, UserGroup{})
+	return nil
+}
+
+func (a Module) Router() error {
+	return nil
+}
+
+func (a Module) WhenReady() error {
+	return nil
+}
+
+func (a Module) Name() string {
+	return "acl"
+}
@@ -0,0 +1,31 @@
+package acl
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getevo/evo/v2"
+)
+
+// ErrorUnauthorized represents an error indicating that the request is not authenticated.
+var ErrorUnauthorized = errors.New("unauthorized")
+
+// ErrorPermissionDenied represents an error indicating that the user lacks the permission of the request.
+var ErrorPermissionDenied = errors.New("permission denied")
+
+// Deny answers the request with 401 when the user is anonymous, or 403 when the user lacks the permission
+// (APP.KEY), and reports whether it did. Handlers return nil once the request is denied:
+//
+//	if acl.Deny(request, acl.Admin) {
+//		return nil
+//	}
+func Deny(request *evo.Request, permission string) bool {
+	var user = request.User()
+	if user.Anonymous() {
+		return request.Error(ErrorUnauthorized, http.StatusUnauthorized)
+	}
+	if !user.HasPermission(permission) {
+		return request.Error(ErrorPermissionDenied, http.StatusForbidden)
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package acl
+
+import (
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"gorm.io/gorm/clause"
+)
+
+// Role represents a named set of permissions which can be granted to users and groups.
+type Role struct {
+	Role        string `gorm:"column:role;size:64;primaryKey" json:"role"`
+	Name        string `gorm:"column:name;size:64" json:"name"`
+	Description string `gorm:"column:description;size:255" json:"description"`
+}
+
+// TableName returns the name of the table for the Role struct.
+func (Role) TableName() string {
+	return "role"
+}
+
+// RolePermission assigns a permission to a role. Permission may be a wildcard such as ORDERS.*,
+// so it does not reference the permission table.
+type RolePermission struct {
+	Role       string `gorm:"column:role;size:64;primaryKey;fk:role" json:"role"`
+	Permission string `gorm:"column:permission;size:64;primaryKey" json:"permission"`
+}
+
+// TableName returns the name of the table for the RolePermission struct.
+func (RolePermission) TableName() string {
+	return "role_permission"
+}
+
+// UserRole grants a role to a user.
+type UserRole struct {
+	User string `gorm:"column:user;size:36;primaryKey;fk:users.uuid" json:"user"`
+	Role string `gorm:"column:role;size:64;primaryKey;fk:role" json:"role"`
+}
+
+// TableName returns the name of the table for the UserRole struct.
+func (UserRole) TableName() string {
+	return "user_role"
+}
+
+// Group represents a group of users sharing the same roles.
+type Group struct {
+	Group       string `gorm:"column:group;size:64;primaryKey" json:"group"`
+	Name        string `gorm:"column:name;size:64" json:"name"`
+	Description string `gorm:"column:description;size:255" json:"description"`
+}
+
+// TableName returns the name of the table for the Group struct.
+func (Group) TableName() string {
+	return "user_group"
+}
+
+// GroupRole grants a role to every member of a group.
+type GroupRole struct {
+	Group string `gorm:"column:group;size:64;primaryKey;fk:user_group" json:"group"`
+	Role  string `gorm:"column:role;size:64;primaryKey;fk:role" json:"role"`
+}
+
+// TableName returns the name of the table for the GroupRole struct.
+func (GroupRole) TableName() string {
+	return "group_role"
+}
+
+// UserGroup adds a user to a group.
+type UserGroup struct {
+	User  string `gorm:"column:user;size:36;primaryKey;fk:users.uuid" json:"user"`
+	Group string `gorm:"column:group;size:64;primaryKey;fk:user_group" json:"group"`
+}
+
+// TableName returns the name of the table for the UserGroup struct.
+func (UserGroup) TableName() string {
+	return "user_group_member"
+}
+
+// Grant grants the role to the user identified by its uuid.
+func Grant(user string, role string) error {
+	return db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&UserRole{User: user, Role: role}).Error
+}
+
+// Revoke revokes the role from the user identified by its uuid.
+func Revoke(user string, role string) error {
+	return db.Delete(&UserRole{User: user, Role: role}).Error
+}
+
+// GrantPermission assigns the permission (APP.KEY) to the role.
+func GrantPermission(role string, permission string) error {
+	return db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&RolePermission{Role: role, Permission: strings.ToUpper(permission)}).Error
+}
+
+// RevokePermission removes the permission (APP.KEY) from the role.
+func RevokePermission(role string, permission string) error {
+	return db.Delete(&RolePermission{Role: role, Permission: strings.ToUpper(permission)}).Error
+}
+
+// AddToGroup adds the user identified by its uuid to the group.
+func AddToGroup(user string, group string) error {
+	return db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&UserGroup{User: user, Group: group}).Error
+}
+
+// RemoveFromGroup removes the user identified by its uuid from the group.
+func RemoveFromGroup(user string, group string) error {
+	return db.Delete(&UserGroup{User: user, Group: group}).Error
+}
+
+// UserRoles returns the roles granted to the user directly or through its groups.
+func UserRoles(user string) ([]string, error) {
+	var roles []string
+	err := db.Raw("SELECT `role` FROM `user_role` WHERE `user` = ? UNION SELECT gr.`role` FROM `group_role` gr INNER JOIN `user_group_member` ug ON ug.`group` = gr.`group` WHERE ug.`user` = ?", user, user).Scan(&roles).Error
+	return roles, err
+}
+
+// UserPermissions returns the permissions (APP.KEY) granted to the user through its roles.
+// Users holding the SuperRole are granted the `*` wildcard.
+func UserPermissions(user string) ([]string, error) {
+	roles, err := UserRoles(user)
+	if err != nil || len(roles) == 0 {
+		return nil, err
+	}
+	var result []string
+	err = db.Model(&RolePermission{}).Distinct("permission").Where("`role` IN (?)", roles).Pluck("permission", &result).Error
+	for _, role := range roles {
+		if strings.EqualFold(role, SuperRole) {
+			result = append(result, Wildcard)
+			break
+		}
+	}
+	return result, err
+}
+
+// UserHasPermission reports whether the user has been granted the permission (APP.KEY) through its roles.
+// Unlike Check the permissions are always loaded from the database.
+func UserHasPermission(user string, permission string) bool {
+	list, err := UserPermissions(user)
+	if err != nil {
+		return false
+	}
+	return Match(list, permission)
+}
@@ -1,7 +1,16 @@
 package app
 
-import "github.com/getevo/evo/v2/lib/log"
+import (
+	"context"
+	"strings"
 
+	"github.com/getevo/evo/v2/lib/log"
+	"github.com/getevo/evo/v2/lib/settings"
+)
+
+// Application is a part of the program run by App: Register registers its models and hooks, Router its routes
+// and WhenReady starts its work once every application is registered. Applications with work to stop when the
+// program exits implement Stopper.
 type Application interface {
 	Register() error
 	Router() error
@@ -9,8 +18,20 @@ type Application interface {
 	Name() string
 }
 
+// Stopper is implemented by the applications stopping their work when the program exits, e.g. their workers and
+// streams: Shutdown returns when done or once ctx is done.
+type Stopper interface {
+	Shutdown(ctx context.Context) error
+}
+
 type App struct {
-	apps []Application
+	apps     []Application
+	modules  []Application
+	enabled  map[string]bool
+	disabled map[string]bool
+	failed   map[string]error
+	// setting returns the configuration values, nil when the applications are not configurable, e.g. in tests.
+	setting func(key string) string
 }
 
 func New() *App {
@@ -21,8 +42,81 @@ func (a *App) Register(applications ...Application) *App {
 	return a
 }
 
+// Enable runs the provided modules with the given names after the registered applications, `*` enables every module.
+// Modules are also enabled by the comma separated APP.MODULES setting.
+func (a *App) Enable(names ...string) *App {
+	if a.enabled == nil {
+		a.enabled = map[string]bool{}
+	}
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			a.enabled[name] = true
+		}
+	}
+	return a
+}
+
+// Failed returns the errors of the modules disabled because they failed to start.
+func (a *App) Failed() map[string]error {
+	return a.failed
+}
+
+// Run starts the registered applications, then the enabled modules. Plugins are loaded first from the
+// comma separated files and directories of the APP.PLUGINS setting.
+// A failing application stops the program, while a failing module, including a panic, is logged and
+// disabled so the rest of the program keeps running. Routes added by a module before failing stay registered.
+// Applications and modules switched off by configuration do not run, e.g. APPS.REST.ENABLED=false, and the
+// state of each is served by GET /admin/apps.
+// On SIGINT or SIGTERM, the program answers 503 to new requests, stops the applications, see Shutdown, and waits
+// for the requests in progress before exiting, within DrainTimeout.
 func (a *App) Run() *App {
+	a.loadPlugins()
+	a.Enable(strings.Split(settings.Get("APP.MODULES").String(), ",")...)
+	a.setting = storedSetting()
+	a.watch()
+	a.start()
+	a.router()
+	return a
+}
+
+// start runs the lifecycle of the registered applications and the enabled modules.
+func (a *App) start() {
+	a.disabled = map[string]bool{}
+	for _, app := range a.apps {
+		if a.switchedOff(app.Name()) {
+			a.disabled[app.Name()] = true
+			log.Warning("application disabled by configuration", "app", app.Name())
+		}
+	}
+	for _, module := range Modules() {
+		if !a.enabled[module.Name] && !a.enabled["*"] {
+			continue
+		}
+		if a.switchedOff(module.Name) {
+			a.disabled[module.Name] = true
+			continue
+		}
+		application, err := safeNew(module.New)
+		if err != nil {
+			a.fail(module.Name, "New", err)
+			continue
+		}
+		a.modules = append(a.modules, application)
+	}
+	var provided = map[string]bool{"*": true}
+	for _, module := range Modules() {
+		provided[module.Name] = true
+	}
+	for name := range a.enabled {
+		if !provided[name] {
+			log.Warning("module is enabled but not provided", "module", name)
+		}
+	}
+
 	for _, app := range a.apps {
+		if a.disabled[app.Name()] {
+			continue
+		}
 		if err := app.Register(); err != nil {
 			log.Fatalf("Can't start application Register() %s: %s", app.Name(), err)
 		}
@@ -30,11 +124,56 @@ func (a *App) Run() *App {
 			log.Fatalf("Can't start application Router() %s: %s", app.Name(), err)
 		}
 	}
+	var modules []Application
+	for _, module := range a.modules {
+		if err := safeCall(module.Register); err != nil {
+			a.fail(module.Name(), "Register", err)
+			continue
+		}
+		if err := safeCall(module.Router); err != nil {
+			a.fail(module.Name(), "Router", err)
+			continue
+		}
+		modules = append(modules, module)
+	}
+	a.modules = modules
+
 	for _, app := range a.apps {
+		if a.disabled[app.Name()] {
+			continue
+		}
 		if err := app.WhenReady(); err != nil {
 			log.Fatalf("Can't start application WhenReady() %s: %s", app.Name(), err)
 		}
 	}
+	for _, module := range a.modules {
+		if err := safeCall(module.WhenReady); err != nil {
+			a.fail(module.Name(), "WhenReady", err)
+		}
+	}
+}
 
-	return a
+func (a *App) loadPlugins() {
+	for _, path := range strings.Split(settings.Get("APP.PLUGINS").String(), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		var err error
+		if strings.HasSuffix(path, ".so") {
+			err = LoadPlugin(path)
+		} else {
+			err = LoadPlugins(path)
+		}
+		if err != nil {
+			log.Error("unable to load plugins", "path", path, "error", err.Error())
+		}
+	}
+}
+
+func (a *App) fail(name string, stage string, err error) {
+	if a.failed == nil {
+		a.failed = map[string]error{}
+	}
+	a.failed[name] = err
+	log.Error("module disabled", "module", name, "stage", stage, "error", err.Error())
 }
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testApp struct {
+	name     string
+	register error
+	panics   bool
+	ready    *bool
+	shutdown error
+	stopped  *[]string
+}
+
+func (t testApp) Register() error {
+	if t.panics {
+		panic("broken")
+	}
+	return t.register
+}
+func (t testApp) Router() error { return nil }
+func (t testApp) WhenReady() error {
+	*t.ready = true
+	return nil
+}
+func (t testApp) Shutdown(ctx context.Context) error {
+	*t.stopped = append(*t.stopped, t.name)
+	return t.shutdown
+}
+func (t testApp) Name() string { return t.name }
+
+// idleApp has no work to stop.
+type idleApp struct{}
+
+func (idleApp) Register() error  { return nil }
+func (idleApp) Router() error    { return nil }
+func (idleApp) WhenReady() error { return nil }
+func (idleApp) Name() string     { return "idle" }
+
+func TestRunModules(t *testing.T) {
+	var ready = map[string]*bool{"ok": new(bool), "failing": new(bool), "panicking": new(bool), "disabled": new(bool)}
+	var tests = []struct {
+		name    string
+		app     testApp
+		enabled bool
+		ready   bool
+		failed  bool
+	}{
+		{"ok", testApp{name: "ok"}, true, true, false},
+		{"failing", testApp{name: "failing", register: errors.New("no database")}, true, false, true},
+		{"panicking", testApp{name: "panicking", panics: true}, true, false, true},
+		{"disabled", testApp{name: "disabled"}, false, false, false},
+	}
+	var a = New()
+	for _, tt := range tests {
+		var app = tt.app
+		app.ready = ready[tt.name]
+		if err := Provide(tt.name, func() Application { return app }); err != nil {
+			t.Fatal(err)
+		}
+		if tt.enabled {
+			a.Enable(tt.name)
+		}
+	}
+	a.start()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if *ready[tt.name] != tt.ready {
+				t.Errorf("ready = %v, want %v", *ready[tt.name], tt.ready)
+			}
+			if _, failed := a.Failed()[tt.name]; failed != tt.failed {
+				t.Errorf("failed = %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestProvide(t *testing.T) {
+	var previous = modules
+	t.Cleanup(func() {
+		modules = previous
+	})
+	modules = map[string]*Module{}
+	if err := Provide("search", func() Application { return idleApp{} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Provide("search", func() Application { return testApp{name: "search"} }); err == nil {
+		t.Error("Provide() of a duplicate module = nil, want an error")
+	}
+	if _, ok := modules["search"].New().(idleApp); !ok {
+		t.Error("expected the first provided module to be kept")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var stopped []string
+	var ready = new(bool)
+	var app = func(name string, err error) testApp {
+		return testApp{name: name, ready: ready, shutdown: err, stopped: &stopped}
+	}
+	var a = New().Register(app("first", nil), idleApp{}, app("second", errors.New("busy")))
+	a.modules = []Application{app("module a", nil), idleApp{}, app("module b", nil)}
+
+	var err = a.Shutdown(context.Background())
+	var want = []string{"module b", "module a", "second", "first"}
+	if strings.Join(stopped, ",") != strings.Join(want, ",") {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	if err == nil || !strings.Contains(err.Error(), "second: busy") {
+		t.Errorf("Shutdown() = %v, want the error of second", err)
+	}
+}
+
+func TestWaitRequests(t *testing.T) {
+	if !waitRequests(context.Background()) {
+		t.Errorf("waitRequests() = false without requests in progress")
+	}
+	inflight.Add(1)
+	defer inflight.Add(-1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if waitRequests(ctx) {
+		t.Errorf("waitRequests() = true with a request in progress")
+	}
+}
+
+func TestSwitchedOff(t *testing.T) {
+	t.Setenv("APPS_FORM_BUILDER_ENABLED", "0")
+	var values = map[string]string{"APPS.REST.ENABLED": "false", "APPS.MAIL.ENABLED": "true", "APPS.AUTH.ENABLED": "maybe"}
+	var a = &App{setting: func(key string) string { return values[key] }}
+	var tests = []struct {
+		name string
+		want bool
+	}{
+		{"rest", true},
+		{"mail", false},
+		{"auth", false},
+		{"form-builder", true},
+		{"other", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.switchedOff(tt.name); got != tt.want {
+				t.Errorf("switchedOff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if (&App{}).switchedOff("rest") {
+		t.Errorf("switchedOff() = true without settings")
+	}
+}
+
+func TestDisabledApp(t *testing.T) {
+	var ready = map[string]*bool{"on": new(bool), "off": new(bool)}
+	var a = New().Register(testApp{name: "on", ready: ready["on"]}, testApp{name: "off", ready: ready["off"]})
+	a.setting = func(key string) string {
+		if key == "APPS.OFF.ENABLED" {
+			return "false"
+		}
+		return ""
+	}
+	a.start()
+	if !*ready["on"] || *ready["off"] {
+		t.Errorf("ready = on %v, off %v, want on only", *ready["on"], *ready["off"])
+	}
+	var statuses = map[string]string{}
+	for _, status := range a.Statuses() {
+		if !status.Module {
+			statuses[status.Name] = status.Status
+		}
+	}
+	if statuses["on"] != StatusRunning || statuses["off"] != StatusDisabled {
+		t.Errorf("Statuses() = %v, want on running and off disabled", statuses)
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Module is an optional application shipped in its own package, such as search, einvoice or media.
+// Modules are provided by the init function of their package, compiled in using a build tag:
+//
+//	//go:build einvoice
+//
+//	func init() {
+//		if err := app.Provide("einvoice", func() app.Application { return einvoice.App{} }); err != nil {
+//			log.Error(err)
+//		}
+//	}
+//
+// or loaded from a Go plugin, see LoadPlugin. Provided modules only run once enabled, see App.Enable.
+type Module struct {
+	Name string
+	New  func() Application
+	// Source is the plugin file the module was loaded from, empty for compiled in modules.
+	Source string
+}
+
+var modules = map[string]*Module{}
+var modulesMu sync.Mutex
+
+// loading is the plugin file being opened, recorded as the source of the modules it provides.
+var loading string
+
+// loadingErr is the first error of Provide while a plugin is being opened, returned by LoadPlugin.
+var loadingErr error
+
+// Provide adds a module to the registry. It returns an error and keeps the first module if a module with the
+// same name is already provided, the error of a plugin providing its modules in init is returned by LoadPlugin.
+func Provide(name string, fn func() Application) error {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if _, ok := modules[name]; ok {
+		var err = fmt.Errorf("module %s provided twice", name)
+		if loading != "" && loadingErr == nil {
+			loadingErr = err
+		}
+		return err
+	}
+	modules[name] = &Module{Name: name, New: fn, Source: loading}
+	return nil
+}
+
+// Modules returns the provided modules sorted by name.
+func Modules() []Module {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	var result []Module
+	for _, m := range modules {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// LoadPlugin opens a Go plugin built with `go build -buildmode=plugin`. The plugin provides its modules
+// by calling Provide in its init function, or exports a `Module` function returning the application:
+//
+//	func Module() app.Application { return media.App{} }
+//
+// The plugin must be built with the same Go version and dependencies as the program loading it.
+func LoadPlugin(path string) error {
+	modulesMu.Lock()
+	loading = path
+	loadingErr = nil
+	modulesMu.Unlock()
+	defer func() {
+		modulesMu.Lock()
+		loading = ""
+		modulesMu.Unlock()
+	}()
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	modulesMu.Lock()
+	err = loadingErr
+	modulesMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s", path, err)
+	}
+	symbol, err := p.Lookup("Module")
+	if err != nil {
+		// the plugin provides its modules in init
+		return nil
+	}
+	var fn func() Application
+	switch v := symbol.(type) {
+	case func() Application:
+		fn = v
+	case *func() Application:
+		fn = *v
+	default:
+		return fmt.Errorf("plugin %s: Module is %T, want func() app.Application", path, symbol)
+	}
+	var name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if application, err := safeNew(fn); err == nil {
+		name = application.Name()
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if _, ok := modules[name]; ok {
+		return fmt.Errorf("plugin %s: module %s provided twice", path, name)
+	}
+	modules[name] = &Module{Name: name, New: fn, Source: path}
+	return nil
+}
+
+// LoadPlugins opens every .so file of the directory. Plugins failing to load are skipped and
+// returned in the error, so a broken plugin does not prevent the others from loading.
+func LoadPlugins(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".so" {
+			continue
+		}
+		if err := LoadPlugin(filepath.Join(dir, entry.Name())); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to load plugins: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// safeNew calls the constructor of a module, recovering from panics.
+func safeNew(fn func() Application) (application Application, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	application = fn()
+	if application == nil {
+		return nil, fmt.Errorf("module constructor returned nil")
+	}
+	return application, nil
+}
+
+// safeCall runs a stage of a module, recovering from panics.
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/log"
+	"github.com/getevo/evo/v2/lib/settings"
+)
+
+// DrainTimeout bounds the graceful shutdown: the time given to the applications to stop and to the requests
+// in progress to complete. It is overridden by the APP.DRAIN_TIMEOUT setting, e.g. 1m.
+var DrainTimeout = 30 * time.Second
+
+var draining atomic.Bool
+var inflight atomic.Int64
+
+// Shutdown stops the enabled modules then the registered applications implementing Stopper, in the reverse
+// order of their start, sharing the deadline of ctx. Every application is stopped even when others fail; the
+// errors are joined.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	for i := len(a.modules) - 1; i >= 0; i-- {
+		var module = a.modules[i]
+		stopper, ok := module.(Stopper)
+		if !ok {
+			continue
+		}
+		if err := safeCall(func() error { return stopper.Shutdown(ctx) }); err != nil {
+			log.Error("unable to stop module", "module", module.Name(), "error", err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", module.Name(), err))
+		}
+	}
+	for i := len(a.apps) - 1; i >= 0; i-- {
+		var app = a.apps[i]
+		stopper, ok := app.(Stopper)
+		if !ok || a.disabled[app.Name()] {
+			continue
+		}
+		if err := safeCall(func() error { return stopper.Shutdown(ctx) }); err != nil {
+			log.Error("unable to stop application", "app", app.Name(), "error", err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", app.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// drainTimeout returns the DrainTimeout, or the APP.DRAIN_TIMEOUT setting when valid.
+func drainTimeout() time.Duration {
+	if d, err := time.ParseDuration(settings.Get("APP.DRAIN_TIMEOUT").String()); err == nil && d > 0 {
+		return d
+	}
+	return DrainTimeout
+}
+
+// track counts the requests in progress and answers 503 to the requests received while shutting down.
+func track(request *evo.Request) error {
+	if draining.Load() {
+		request.SetHeader("Connection", "close")
+		return request.SendStatus(http.StatusServiceUnavailable)
+	}
+	inflight.Add(1)
+	defer inflight.Add(-1)
+	return request.Next()
+}
+
+// handleSignals waits for SIGINT or SIGTERM, then rejects the new requests, waits for the requests in progress,
+// stops the applications and exits, within the drain timeout. The applications are stopped once the requests
+// are drained so the requests in progress can still use them.
+func (a *App) handleSignals() {
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	var sig = <-signals
+	log.Info("shutting down", "signal", sig.String())
+	draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout())
+	defer cancel()
+	var code = 0
+	if !waitRequests(ctx) {
+		log.Warning("drain timeout exceeded", "requests", inflight.Load())
+		code = 1
+	}
+	if err := a.Shutdown(ctx); err != nil {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// waitRequests waits for the requests in progress to complete, reporting false when ctx is done first.
+func waitRequests(ctx context.Context) bool {
+	var ticker = time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// watch installs the request tracking and the signal handling of the graceful shutdown.
+func (a *App) watch() {
+	if evo.GetFiber() != nil {
+		evo.Use("/", track)
+	}
+	go a.handleSignals()
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/settings"
+)
+
+// PREFIX specifies the prefix for the app routes in the admin panel.
+var PREFIX = "/admin"
+
+// Status of the applications.
+const (
+	StatusRunning  = "running"
+	StatusDisabled = "disabled"
+	StatusFailed   = "failed"
+)
+
+// Status is the state of a registered application or of a provided module.
+// - Module: the application is a module, see Provide.
+// - Source: the plugin file the module was loaded from.
+// - Error: the error which disabled the module.
+type Status struct {
+	Name   string `json:"name"`
+	Module bool   `json:"module"`
+	Status string `json:"status"`
+	Source string `json:"source,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+var envName = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// switchedOff reports whether the application is disabled by configuration: the APPS_<NAME>_ENABLED environment
+// variable, or else the APPS.<NAME>.ENABLED setting, read from the settings store or the evo settings, e.g.
+//
+//	APPS_REST_ENABLED=false
+//
+// Values not parsed as booleans leave the application enabled.
+func (a *App) switchedOff(name string) bool {
+	if a.setting == nil {
+		return false
+	}
+	var key = strings.ToUpper(name)
+	var value, ok = os.LookupEnv("APPS_" + envName.ReplaceAllString(key, "_") + "_ENABLED")
+	if !ok {
+		value = a.setting("APPS." + key + ".ENABLED")
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "false", "0", "no", "off":
+		return true
+	}
+	return false
+}
+
+// Statuses returns the state of the registered applications, then of the provided modules.
+func (a *App) Statuses() []Status {
+	var result []Status
+	for _, app := range a.apps {
+		var status = Status{Name: app.Name(), Status: StatusRunning}
+		if a.disabled[app.Name()] {
+			status.Status = StatusDisabled
+		}
+		result = append(result, status)
+	}
+	var running = map[string]bool{}
+	for _, module := range a.modules {
+		running[module.Name()] = true
+	}
+	for _, module := range Modules() {
+		var status = Status{Name: module.Name, Module: true, Status: StatusDisabled, Source: module.Source}
+		if err, ok := a.failed[module.Name]; ok {
+			status.Status, status.Error = StatusFailed, err.Error()
+		} else if running[module.Name] {
+			status.Status = StatusRunning
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// router sets up the app endpoint.
+// GET /apps returns the state of the applications and modules to the users holding acl.Admin.
+func (a *App) router() {
+	evo.Get(PREFIX+"/apps", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		return a.Statuses()
+	})
+}
+
+// storedSetting returns the value of the key from the settings store, whose stored values are loaded first so
+// they apply before the settings app is ready. Only the evo settings apply while the store is not available.
+func storedSetting() func(key string) string {
+	_ = safeCall(settings.Reload)
+	return func(key string) string {
+		return settings.Get("", key).String()
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/log"
+)
+
+// APIKeyHeader specifies the request header carrying API keys.
+var APIKeyHeader = "X-API-Key"
+
+// APIKeyPrefix is prepended to generated API keys to make them recognizable.
+const APIKeyPrefix = "tbk_"
+
+// APIKeyUUIDPrefix is prepended to the id of an API key to form the UUID of the requests it authenticates.
+const APIKeyUUIDPrefix = "apikey:"
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrAPIKeyExpired = errors.New("api key expired")
+)
+
+// APIKey represents a machine to machine credential. Only the sha256 of the secret is stored,
+// the Lookup column holds the first characters of the key to find the row.
+// Scopes is a space separated list of acl permissions granted to the key, wildcards allowed.
+type APIKey struct {
+	ID         uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name       string     `gorm:"column:name;size:64" json:"name"`
+	Lookup     string     `gorm:"column:lookup;size:16;index" json:"lookup"`
+	Hash       string     `gorm:"column:hash;size:64" json:"-"`
+	Scopes     string     `gorm:"column:scopes;size:1024" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName returns the name of the table for the APIKey struct.
+func (APIKey) TableName() string {
+	return "api_key"
+}
+
+// Permissions returns the scopes of the key.
+func (k APIKey) Permissions() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// lookupLength is the number of characters of the key, prefix included, stored in clear.
+const lookupLength = len(APIKeyPrefix) + 8
+
+// CreateAPIKey creates an API key holding the given scopes. A nil expiry creates a key which never expires.
+// The returned secret is not stored and can not be retrieved afterwards.
+func CreateAPIKey(name string, scopes []string, expires *time.Time) (string, *APIKey, error) {
+	var b = make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	var secret = APIKeyPrefix + hex.EncodeToString(b)
+	var key = APIKey{
+		Name:      name,
+		Lookup:    secret[:lookupLength],
+		Hash:      hashAPIKey(secret),
+		Scopes:    strings.ToUpper(strings.Join(scopes, " ")),
+		ExpiresAt: expires,
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return "", nil, err
+	}
+	return secret, &key, nil
+}
+
+// RevokeAPIKey deletes the API key with the given id.
+func RevokeAPIKey(id uint64) error {
+	return db.Delete(&APIKey{ID: id}).Error
+}
+
+// AuthenticateAPIKey returns the API key matching the secret and records its use.
+func AuthenticateAPIKey(secret string) (*APIKey, error) {
+	if len(secret) <= lookupLength || !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	var keys []APIKey
+	if err := db.Where("lookup = ?", secret[:lookupLength]).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	var hash = hashAPIKey(secret)
+	for idx := range keys {
+		var key = &keys[idx]
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		var now = time.Now()
+		if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+			return nil, ErrAPIKeyExpired
+		}
+		key.LastUsedAt = &now
+		go func(id uint64) {
+			if err := db.Model(&APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", now).Error; err != nil {
+				log.Error("unable to update api key usage", "id", id, "error", err)
+			}
+		}(key.ID)
+		return key, nil
+	}
+	return nil, ErrInvalidAPIKey
+}
+
+func hashAPIKey(secret string) string {
+	var sum = sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	var dbo = resttest.Setup(t, APIKey{})
+	secret, key, err := CreateAPIKey("billing", []string{"invoices.view", "reports.*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, APIKeyPrefix) || key.Hash == secret || strings.Contains(key.Hash, secret[lookupLength:]) {
+		t.Fatalf("expected a prefixed secret stored hashed, got %q hash %q", secret, key.Hash)
+	}
+	if got := key.Permissions(); !reflect.DeepEqual(got, []string{"INVOICES.VIEW", "REPORTS.*"}) {
+		t.Errorf("Permissions() = %v", got)
+	}
+
+	authenticated, err := AuthenticateAPIKey(secret)
+	if err != nil || authenticated.ID != key.ID {
+		t.Fatalf("AuthenticateAPIKey() = %+v, %v", authenticated, err)
+	}
+	var stored APIKey
+	for i := 0; i < 100 && stored.LastUsedAt == nil; i++ {
+		time.Sleep(5 * time.Millisecond)
+		dbo.Take(&stored, key.ID)
+	}
+	if stored.LastUsedAt == nil {
+		t.Error("expected the use of the key to be recorded")
+	}
+
+	var tests = []struct {
+		name   string
+		secret string
+	}{
+		{"empty", ""},
+		{"prefix only", APIKeyPrefix},
+		{"lookup only", secret[:lookupLength]},
+		{"wrong prefix", "xxx_" + secret[len(APIKeyPrefix):]},
+		{"tampered", secret[:len(secret)-1] + "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := AuthenticateAPIKey(tt.secret); err != ErrInvalidAPIKey {
+				t.Errorf("AuthenticateAPIKey() error = %v, want ErrInvalidAPIKey", err)
+			}
+		})
+	}
+
+	if err := RevokeAPIKey(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AuthenticateAPIKey(secret); err != ErrInvalidAPIKey {
+		t.Errorf("revoked key error = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestAuthenticateAPIKeyExpired(t *testing.T) {
+	resttest.Setup(t, APIKey{})
+	var expired = time.Now().Add(-time.Minute)
+	secret, _, err := CreateAPIKey("old", []string{"*"}, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AuthenticateAPIKey(secret); err != ErrAPIKeyExpired {
+		t.Errorf("AuthenticateAPIKey() error = %v, want ErrAPIKeyExpired", err)
+	}
+}
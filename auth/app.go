@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/log"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// Config holds the JWT validation settings.
+// - Issuer: expected iss claim, empty accepts any issuer.
+// - Audience: expected aud claim, empty accepts any audience.
+// - Secret: HMAC secret of HS256 tokens, empty disables HS256.
+// - PublicKeys: static RSA keys of RS256 tokens keyed by kid.
+// - JWKSURL: URL of a JSON Web Key Set providing RS256 keys.
+// - JWKSRefresh: interval between two JWKS downloads.
+// - Leeway: clock skew tolerated on exp and nbf.
+// - AllowUnknownUsers: accept valid tokens whose subject has no model.User row.
+var Config = struct {
+	Issuer            string
+	Audience          string
+	Secret            []byte
+	PublicKeys        map[string]*rsa.PublicKey
+	JWKSURL           string
+	JWKSRefresh       time.Duration
+	Leeway            time.Duration
+	AllowUnknownUsers bool
+}{
+	PublicKeys:  map[string]*rsa.PublicKey{},
+	JWKSRefresh: time.Hour,
+	Leeway:      30 * time.Second,
+}
+
+// PREFIX specifies the prefix for auth routes in the admin panel.
+var PREFIX = "/admin"
+
+// ErrorUnauthorized represents an error indicating that the request is not authenticated.
+var ErrorUnauthorized = errors.New("unauthorized")
+
+type App struct {
+}
+
+// Register makes evo authenticate requests using Bearer JWTs and API keys.
+// It must be registered before the rest app for the session endpoints to be exposed.
+func (a App) Register() error {
+	db.UseModel(APIKey{}, Session{})
+	evo.SetUserInterface(User{})
+	rest.Impersonator = Impersonate
+	return nil
+}
+
+// Router sets up the session and password endpoints.
+// POST /auth/login {"email", "password", "code", "device"} starts a session, code is the second factor if enabled.
+// POST /auth/password {"current_password", "new_password"} changes the password of the user.
+// POST /auth/password/reset-request {"email"} sends a reset token using ResetTokenSender.
+// POST /auth/password/reset {"token", "password"} sets the password using a reset token.
+// POST /auth/refresh {"refresh_token": "..."} rotates the refresh token and issues a new access token.
+// POST /auth/logout revokes the session of the access token.
+// POST /auth/logout-all revokes every session of the user.
+func (a App) Router() error {
+	var password = passwordController{}
+	evo.Post(PREFIX+"/auth/login", password.login)
+	evo.Post(PREFIX+"/auth/password", password.changePassword)
+	evo.Post(PREFIX+"/auth/password/reset-request", password.requestReset)
+	evo.Post(PREFIX+"/auth/password/reset", password.reset)
+	evo.Post(PREFIX+"/auth/refresh", func(request *evo.Request) interface{} {
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := request.BodyParser(&body); err != nil {
+			return err
+		}
+		session, refreshToken, accessToken, err := RefreshSession(body.RefreshToken)
+		if err != nil {
+			return err
+		}
+		return map[string]interface{}{"session": session, "refresh_token": refreshToken, "access_token": accessToken}
+	})
+	evo.Post(PREFIX+"/auth/logout", func(request *evo.Request) interface{} {
+		user, ok := request.User().(User)
+		if !ok || user.Anonymous() {
+			return ErrorUnauthorized
+		}
+		if sid := user.Claims.Get(SessionClaim).String(); sid != "" {
+			return RevokeSession(sid)
+		}
+		return nil
+	})
+	evo.Post(PREFIX+"/auth/logout-all", func(request *evo.Request) interface{} {
+		var uuid = accountUUID(request)
+		if uuid == "" {
+			return ErrorUnauthorized
+		}
+		return RevokeSessions(uuid)
+	})
+	return nil
+}
+
+// WhenReady loads the JWKS and keeps it refreshed.
+func (a App) WhenReady() error {
+	if Config.JWKSURL == "" {
+		return nil
+	}
+	if err := RefreshJWKS(); err != nil {
+		log.Error("unable to load jwks", "url", Config.JWKSURL, "error", err.Error())
+	}
+	go func() {
+		for range time.Tick(Config.JWKSRefresh) {
+			if err := RefreshJWKS(); err != nil {
+				log.Error("unable to refresh jwks", "url", Config.JWKSURL, "error", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (a App) Name() string {
+	return "auth"
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/log"
+	"github.com/iesitalia/toolbox/httpclient"
+)
+
+// jwk represents a single key of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var jwks = struct {
+	sync.RWMutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}{keys: map[string]*rsa.PublicKey{}}
+
+// publicKey returns the RSA key with the given id from the static keys or the JWKS.
+// An unknown id triggers a JWKS refresh, at most once per minute.
+func publicKey(kid string) *rsa.PublicKey {
+	if key, ok := Config.PublicKeys[kid]; ok {
+		return key
+	}
+	jwks.RLock()
+	key, ok := jwks.keys[kid]
+	var stale = time.Since(jwks.fetched) > time.Minute
+	jwks.RUnlock()
+	if ok {
+		return key
+	}
+	if Config.JWKSURL != "" && stale {
+		if err := RefreshJWKS(); err != nil {
+			log.Error("unable to refresh jwks", "url", Config.JWKSURL, "error", err.Error())
+			return nil
+		}
+		jwks.RLock()
+		key = jwks.keys[kid]
+		jwks.RUnlock()
+	}
+	return key
+}
+
+// RefreshJWKS downloads the key set from Config.JWKSURL and replaces the cached keys.
+func RefreshJWKS() error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := httpclient.Default.Get(context.Background(), Config.JWKSURL, &set); err != nil {
+		return err
+	}
+	var keys = map[string]*rsa.PublicKey{}
+	for _, item := range set.Keys {
+		if item.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(item.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(item.E)
+		if err != nil {
+			continue
+		}
+		keys[item.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	jwks.Lock()
+	jwks.keys = keys
+	jwks.fetched = time.Now()
+	jwks.Unlock()
+	return nil
+}
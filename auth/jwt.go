@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/generic"
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrUnknownKey       = errors.New("unknown token key")
+	ErrUnsupportedAlg   = errors.New("unsupported token algorithm")
+)
+
+// Claims represents the payload of a JWT.
+type Claims map[string]interface{}
+
+// Get returns the value of the given claim.
+func (c Claims) Get(key string) generic.Value {
+	return generic.Parse(c[key])
+}
+
+// Subject returns the sub claim.
+func (c Claims) Subject() string {
+	return c.Get("sub").String()
+}
+
+// Strings returns the given claim as a list of strings. A space separated string is split.
+func (c Claims) Strings(key string) []string {
+	switch v := c[key].(type) {
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, generic.Parse(item).String())
+		}
+		return result
+	case string:
+		return strings.Fields(v)
+	}
+	return nil
+}
+
+// header represents the header of a JWT.
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Sign returns a HS256 token carrying the claims, signed with Config.Secret.
+func Sign(claims Claims) (string, error) {
+	if len(Config.Secret) == 0 {
+		return "", ErrUnsupportedAlg
+	}
+	h, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	var signed = base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var mac = hmac.New(sha256.New, Config.Secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks the signature and the registered claims of the token and returns its claims.
+// Supported algorithms are HS256 using Config.Secret and RS256 using Config.PublicKeys or the JWKS keys.
+func Verify(token string) (Claims, error) {
+	var parts = strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var signed = []byte(parts[0] + "." + parts[1])
+
+	switch h.Alg {
+	case "HS256":
+		if len(Config.Secret) == 0 {
+			return nil, ErrUnsupportedAlg
+		}
+		var mac = hmac.New(sha256.New, Config.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, ErrInvalidSignature
+		}
+	case "RS256":
+		var key = publicKey(h.Kid)
+		if key == nil {
+			return nil, ErrUnknownKey
+		}
+		var digest = sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+
+	var claims = Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, validate(claims)
+}
+
+// validate checks the exp, nbf, iss and aud claims.
+func validate(claims Claims) error {
+	var now = time.Now().Unix()
+	if _, ok := claims["exp"]; ok && now > claims.Get("exp").Int64()+int64(Config.Leeway.Seconds()) {
+		return ErrTokenExpired
+	}
+	if _, ok := claims["nbf"]; ok && now+int64(Config.Leeway.Seconds()) < claims.Get("nbf").Int64() {
+		return ErrInvalidToken
+	}
+	if Config.Issuer != "" && claims.Get("iss").String() != Config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if Config.Audience != "" {
+		var found = false
+		for _, item := range claims.Strings("aud") {
+			if item == Config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrInvalidAudience
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, alg string, kid string, claims Claims, key interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	c, _ := json.Marshal(claims)
+	var signed = base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	var signature []byte
+	switch alg {
+	case "HS256":
+		var mac = hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var digest = sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Config.Secret = []byte("secret")
+	Config.Issuer = "toolbox"
+	Config.PublicKeys = map[string]*rsa.PublicKey{"k1": &private.PublicKey}
+
+	var valid = Claims{"sub": "u1", "iss": "toolbox", "exp": time.Now().Add(time.Hour).Unix()}
+	var expired = Claims{"sub": "u1", "iss": "toolbox", "exp": time.Now().Add(-time.Hour).Unix()}
+	var issuer = Claims{"sub": "u1", "iss": "other"}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"hs256", sign(t, "HS256", "", valid, []byte("secret")), nil},
+		{"rs256", sign(t, "RS256", "k1", valid, private), nil},
+		{"wrong secret", sign(t, "HS256", "", valid, []byte("wrong")), ErrInvalidSignature},
+		{"unknown kid", sign(t, "RS256", "k2", valid, private), ErrUnknownKey},
+		{"expired", sign(t, "HS256", "", expired, []byte("secret")), ErrTokenExpired},
+		{"issuer", sign(t, "HS256", "", issuer, []byte("secret")), ErrInvalidIssuer},
+		{"none", sign(t, "none", "", valid, nil), ErrUnsupportedAlg},
+		{"garbage", "a.b", ErrInvalidToken},
+	}
+	for _, test := range tests {
+		claims, err := Verify(test.token)
+		if err != test.err {
+			t.Errorf("%s: Verify() error = %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err == nil && claims.Subject() != "u1" {
+			t.Errorf("%s: Subject() = %s", test.name, claims.Subject())
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	Config.Secret = []byte("secret")
+	Config.Issuer = ""
+	token, err := Sign(Claims{"sub": "u1", "sid": "s1", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := Verify(token)
+	if err != nil || claims.Subject() != "u1" || claims.Get("sid").String() != "s1" {
+		t.Errorf("Verify(Sign()) = %v, %v", claims, err)
+	}
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/model"
+	"gorm.io/gorm"
+)
+
+// ResetTokenSender delivers password reset tokens, e.g. by email. Reset requests fail while it is nil.
+var ResetTokenSender func(user *model.User, token string) error
+
+// ErrResetUnavailable is returned by reset requests when no ResetTokenSender is configured.
+var ErrResetUnavailable = errors.New("password reset is not available")
+
+// SecondFactor verifies the one-time code of a user after the password has been checked, e.g. a TOTP code.
+// It must return nil for users without a second factor. Login only checks the password while it is nil.
+var SecondFactor func(user *model.User, code string) error
+
+// Login checks the email, password and second factor code and starts a session for the device.
+// Failed attempts, of the password or of the second factor, are counted and lock the account after
+// model.MaxFailedAttempts; the count is only reset once both factors passed. Unknown emails are answered
+// after checking the password against a decoy hash so they cannot be told apart by the response time.
+func Login(email, password, code, device, ip string) (session *Session, refreshToken string, accessToken string, err error) {
+	var user model.User
+	if db.Where("email = ?", strings.TrimSpace(email)).Take(&user).RowsAffected == 0 {
+		// check the password anyway so unknown emails take as long as wrong passwords
+		var credentials = model.Credentials{PasswordHash: decoyHash()}
+		_ = credentials.CheckPassword(password)
+		return nil, "", "", model.ErrInvalidCredentials
+	}
+	var checkErr = user.CheckPassword(password)
+	if checkErr == nil && SecondFactor != nil {
+		checkErr = SecondFactor(&user, code)
+	}
+	if checkErr != nil {
+		if checkErr != model.ErrAccountLocked {
+			if err := recordFailure(&user); err != nil {
+				return nil, "", "", err
+			}
+		}
+		return nil, "", "", checkErr
+	}
+	if err := saveCredentials(&user); err != nil {
+		return nil, "", "", err
+	}
+	return StartSession(user.UUID, device, ip)
+}
+
+// recordFailure counts a failed attempt in the database, locking the account after model.MaxFailedAttempts.
+// The counter is incremented by the query itself so concurrent attempts are all counted.
+func recordFailure(user *model.User) error {
+	var query = db.Model(&model.User{}).Where("uuid = ?", user.UUID)
+	if err := query.UpdateColumn("failed_attempts", gorm.Expr("failed_attempts + 1")).Error; err != nil {
+		return err
+	}
+	if model.MaxFailedAttempts <= 0 {
+		return nil
+	}
+	var until = time.Now().Add(model.LockoutDuration)
+	return db.Model(&model.User{}).Where("uuid = ? AND failed_attempts >= ?", user.UUID, model.MaxFailedAttempts).
+		UpdateColumns(map[string]interface{}{"failed_attempts": 0, "locked_until": until}).Error
+}
+
+var decoy struct {
+	once sync.Once
+	hash string
+}
+
+// decoyHash returns the hash of a random password, checked against the passwords given for unknown emails.
+func decoyHash() string {
+	decoy.once.Do(func() {
+		var b = make([]byte, 32)
+		_, _ = rand.Read(b)
+		var credentials model.Credentials
+		if err := credentials.SetPassword(hex.EncodeToString(b)); err == nil {
+			decoy.hash = credentials.PasswordHash
+		}
+	})
+	return decoy.hash
+}
+
+// ChangePassword sets the password of the user after checking the current one and revokes every session
+// of the user but the ones given in keep, e.g. the session of the request.
+func ChangePassword(uuid, current, password string, keep ...string) error {
+	var user model.User
+	if db.Where("uuid = ?", uuid).Take(&user).RowsAffected == 0 {
+		return model.ErrInvalidUser
+	}
+	if user.HasPassword() {
+		if err := user.CheckPassword(current); err != nil {
+			if err != model.ErrAccountLocked {
+				if err := recordFailure(&user); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+	}
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	if err := saveCredentials(&user); err != nil {
+		return err
+	}
+	return RevokeSessions(user.UUID, keep...)
+}
+
+// RequestPasswordReset generates a reset token for the user with the given email and sends it using ResetTokenSender.
+// Unknown emails are ignored so the endpoint does not reveal which accounts exist.
+func RequestPasswordReset(email string) error {
+	if ResetTokenSender == nil {
+		return ErrResetUnavailable
+	}
+	var user model.User
+	if db.Where("email = ?", strings.TrimSpace(email)).Take(&user).RowsAffected == 0 {
+		return nil
+	}
+	token, err := user.NewResetToken()
+	if err != nil {
+		return err
+	}
+	if err := saveCredentials(&user); err != nil {
+		return err
+	}
+	return ResetTokenSender(&user, user.UUID+"."+token)
+}
+
+// ResetPassword sets the password using a token sent by RequestPasswordReset and revokes every session of the user.
+func ResetPassword(token, password string) error {
+	uuid, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return model.ErrInvalidResetToken
+	}
+	var user model.User
+	if db.Where("uuid = ?", uuid).Take(&user).RowsAffected == 0 {
+		return model.ErrInvalidResetToken
+	}
+	if err := user.ResetPassword(secret, password); err != nil {
+		return err
+	}
+	if err := saveCredentials(&user); err != nil {
+		return err
+	}
+	return RevokeSessions(user.UUID)
+}
+
+func saveCredentials(user *model.User) error {
+	return db.Model(user).Select(model.CredentialColumns).Updates(user).Error
+}
+
+// passwordController holds the login and password endpoints.
+type passwordController struct{}
+
+func (passwordController) login(request *evo.Request) interface{} {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+		Device   string `json:"device"`
+	}
+	if err := request.BodyParser(&body); err != nil {
+		return err
+	}
+	if body.Device == "" {
+		body.Device = request.Header("User-Agent")
+	}
+	session, refreshToken, accessToken, err := Login(body.Email, body.Password, body.Code, body.Device, request.IP())
+	if err != nil {
+		logger.FromRequest(request).Warning("login failed", "email", body.Email, "error", err.Error())
+		return err
+	}
+	return map[string]interface{}{"session": session, "refresh_token": refreshToken, "access_token": accessToken}
+}
+
+func (passwordController) changePassword(request *evo.Request) interface{} {
+	if accountUUID(request) == "" {
+		return ErrorUnauthorized
+	}
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := request.BodyParser(&body); err != nil {
+		return err
+	}
+	var keep []string
+	if user, ok := request.User().(User); ok {
+		if sid := user.Claims.Get(SessionClaim).String(); sid != "" {
+			keep = append(keep, sid)
+		}
+	}
+	return ChangePassword(request.User().UUID(), body.CurrentPassword, body.NewPassword, keep...)
+}
+
+func (passwordController) requestReset(request *evo.Request) interface{} {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := request.BodyParser(&body); err != nil {
+		return err
+	}
+	return RequestPasswordReset(body.Email)
+}
+
+func (passwordController) reset(request *evo.Request) interface{} {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := request.BodyParser(&body); err != nil {
+		return err
+	}
+	return ResetPassword(body.Token, body.Password)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/model"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestRecordFailure(t *testing.T) {
+	var dbo = resttest.Setup(t, model.User{})
+	var user = model.User{UUID: "f0000000-0000-4000-8000-000000000001", Email: "lockout@example.com"}
+	if err := dbo.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < model.MaxFailedAttempts-1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := recordFailure(&user); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var stored model.User
+	dbo.Where("uuid = ?", user.UUID).Take(&stored)
+	if stored.FailedAttempts != model.MaxFailedAttempts-1 || stored.Locked() {
+		t.Fatalf("after %d failures: attempts = %d, locked = %v", model.MaxFailedAttempts-1, stored.FailedAttempts, stored.Locked())
+	}
+	if err := recordFailure(&user); err != nil {
+		t.Fatal(err)
+	}
+	stored = model.User{}
+	dbo.Where("uuid = ?", user.UUID).Take(&stored)
+	if stored.FailedAttempts != 0 || !stored.Locked() {
+		t.Fatalf("after %d failures: attempts = %d, locked = %v", model.MaxFailedAttempts, stored.FailedAttempts, stored.Locked())
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	resttest.Setup(t, model.User{})
+	if decoyHash() == "" {
+		t.Fatal("no decoy hash")
+	}
+	if _, _, _, err := Login("nobody@example.com", "whatever-password", "", "", ""); err != model.ErrInvalidCredentials {
+		t.Fatalf("Login = %v, want %v", err, model.ErrInvalidCredentials)
+	}
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	var dbo = resttest.Setup(t, model.User{}, Session{})
+	var user = model.User{UUID: "f0000000-0000-4000-8000-000000000002", Email: "change@example.com"}
+	if err := user.SetPassword("current-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbo.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	var expires = time.Now().Add(time.Hour)
+	for _, id := range []string{"current", "other"} {
+		if err := dbo.Create(&Session{ID: id, User: user.UUID, ExpiresAt: expires}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ChangePassword(user.UUID, "current-password", "the-new-password", "current"); err != nil {
+		t.Fatal(err)
+	}
+	for id, active := range map[string]bool{"current": true, "other": false} {
+		var session Session
+		dbo.Where("id = ?", id).Take(&session)
+		if session.Active() != active {
+			t.Errorf("session %s active = %v, want %v", id, session.Active(), active)
+		}
+	}
+}
+
+func TestRefreshSessionOnce(t *testing.T) {
+	resttest.Setup(t, model.User{}, Session{})
+	var secret = Config.Secret
+	Config.Secret = []byte("refresh-secret")
+	defer func() { Config.Secret = secret }()
+	_, token, _, err := StartSession("f0000000-0000-4000-8000-000000000003", "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var refreshed int
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := RefreshSession(token); err == nil {
+				mu.Lock()
+				refreshed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshed != 1 {
+		t.Fatalf("%d concurrent refreshes with the same token succeeded, want 1", refreshed)
+	}
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/google/uuid"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// SessionClaim is the token claim carrying the session id. Tokens carrying it are rejected once the session is revoked.
+const SessionClaim = "sid"
+
+// SessionTTL specifies how long a session can be refreshed after its creation.
+var SessionTTL = 30 * 24 * time.Hour
+
+// AccessTokenTTL specifies the lifetime of the access tokens issued for sessions.
+var AccessTokenTTL = 15 * time.Minute
+
+// SessionCacheTTL specifies how long the state of a session is cached by the JWT middleware.
+var SessionCacheTTL = 30 * time.Second
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionRevoked      = errors.New("session revoked")
+	ErrSessionExpired      = errors.New("session expired")
+)
+
+// Session represents the tokens issued to a user on a device.
+// The refresh token is only stored hashed, access tokens reference the session through the sid claim.
+// Users list and delete their own sessions through the rest endpoints.
+type Session struct {
+	ID          string     `gorm:"column:id;size:36;primaryKey" json:"id"`
+	User        string     `gorm:"column:user;size:36;index;fk:users.uuid" json:"user"`
+	Device      string     `gorm:"column:device;size:255" json:"device"`
+	IP          string     `gorm:"column:ip;size:64" json:"ip"`
+	RefreshHash string     `gorm:"column:refresh_hash;size:64" json:"-"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	LastSeenAt  time.Time  `gorm:"column:last_seen_at" json:"last_seen_at"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	RevokedAt   *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	rest.API
+	rest.DisableCreate
+	rest.DisableUpdate
+}
+
+// TableName returns the name of the table for the Session struct.
+func (Session) TableName() string {
+	return "user_session"
+}
+
+// Scope restricts the rest endpoints to the active sessions of the user of the request.
+func (Session) Scope(context *rest.Context, query *gorm.DB) *gorm.DB {
+	return query.Where("`user_session`.`user` = ? AND `user_session`.`revoked_at` IS NULL AND `user_session`.`expires_at` > ?", context.User().UUID(), time.Now())
+}
+
+// Active reports whether the session has neither been revoked nor expired.
+func (s Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+var sessionCache = struct {
+	sync.Mutex
+	entries map[string]time.Time
+}{entries: map[string]time.Time{}}
+
+// StartSession creates a session for the user and returns it with its refresh token and a first access token.
+func StartSession(user, device, ip string) (session *Session, refreshToken string, accessToken string, err error) {
+	var now = time.Now()
+	session = &Session{
+		ID:         uuid.NewString(),
+		User:       user,
+		Device:     device,
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(SessionTTL),
+	}
+	refreshToken, err = newRefreshToken(session)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err = db.Create(session).Error; err != nil {
+		return nil, "", "", err
+	}
+	accessToken, err = session.AccessToken()
+	return session, refreshToken, accessToken, err
+}
+
+// RefreshSession rotates the refresh token of the session it belongs to and issues a new access token.
+// Each refresh token can be used once, concurrent refreshes with the same token all fail but one.
+func RefreshSession(refreshToken string) (session *Session, rotated string, accessToken string, err error) {
+	id, _, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return nil, "", "", ErrInvalidRefreshToken
+	}
+	session = &Session{}
+	if db.Where("id = ?", id).Take(session).RowsAffected == 0 || session.RefreshHash != hashToken(refreshToken) {
+		return nil, "", "", ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil {
+		return nil, "", "", ErrSessionRevoked
+	}
+	if !session.Active() {
+		return nil, "", "", ErrSessionExpired
+	}
+	var previous = session.RefreshHash
+	if rotated, err = newRefreshToken(session); err != nil {
+		return nil, "", "", err
+	}
+	session.LastSeenAt = time.Now()
+	// the hash is swapped only if it is still the one of the given token so a token refreshes once
+	var result = db.Model(&Session{}).Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", session.ID, previous).
+		UpdateColumns(map[string]interface{}{"refresh_hash": session.RefreshHash, "last_seen_at": session.LastSeenAt})
+	if result.Error != nil {
+		return nil, "", "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", "", ErrInvalidRefreshToken
+	}
+	accessToken, err = session.AccessToken()
+	return session, rotated, accessToken, err
+}
+
+// AccessToken returns a HS256 access token for the session valid for AccessTokenTTL.
+func (s Session) AccessToken() (string, error) {
+	var now = time.Now()
+	var claims = Claims{"sub": s.User, SessionClaim: s.ID, "iat": now.Unix(), "exp": now.Add(AccessTokenTTL).Unix()}
+	if Config.Issuer != "" {
+		claims["iss"] = Config.Issuer
+	}
+	if Config.Audience != "" {
+		claims["aud"] = Config.Audience
+	}
+	return Sign(claims)
+}
+
+// RevokeSession revokes the session with the given id.
+func RevokeSession(id string) error {
+	forgetSession(id)
+	return db.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", id).UpdateColumn("revoked_at", time.Now()).Error
+}
+
+// RevokeSessions revokes every session of the user but the ones given in keep, logging it out everywhere else.
+func RevokeSessions(user string, keep ...string) error {
+	var query = func() *gorm.DB {
+		var query = db.Model(&Session{}).Where("`user` = ? AND revoked_at IS NULL", user)
+		if len(keep) > 0 {
+			query = query.Where("id NOT IN (?)", keep)
+		}
+		return query
+	}
+	var ids []string
+	if err := query().Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		forgetSession(id)
+	}
+	return query().UpdateColumn("revoked_at", time.Now()).Error
+}
+
+// SessionActive reports whether the session with the given id is active. The result is cached for SessionCacheTTL,
+// revocations of the current process take effect immediately.
+func SessionActive(id string) bool {
+	var now = time.Now()
+	sessionCache.Lock()
+	expires, ok := sessionCache.entries[id]
+	sessionCache.Unlock()
+	if ok && now.Before(expires) {
+		return true
+	}
+	var session Session
+	if db.Where("id = ?", id).Take(&session).RowsAffected == 0 || !session.Active() {
+		return false
+	}
+	sessionCache.Lock()
+	sessionCache.entries[id] = now.Add(SessionCacheTTL)
+	sessionCache.Unlock()
+	return true
+}
+
+// AfterDelete drops the deleted session from the cache.
+func (s *Session) AfterDelete(tx *gorm.DB) error {
+	forgetSession(s.ID)
+	return nil
+}
+
+func forgetSession(id string) {
+	sessionCache.Lock()
+	delete(sessionCache.entries, id)
+	sessionCache.Unlock()
+}
+
+// newRefreshToken generates a refresh token for the session and stores its hash on the session.
+func newRefreshToken(session *Session) (string, error) {
+	var b = make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var token = session.ID + "." + hex.EncodeToString(b)
+	session.RefreshHash = hashToken(token)
+	return token, nil
+}
+
+func hashToken(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
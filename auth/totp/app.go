@@ -0,0 +1,98 @@
+package totp
+
+import (
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/auth"
+	"github.com/iesitalia/toolbox/model"
+)
+
+// PREFIX specifies the prefix for TOTP routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the enrollment model and requires the TOTP code on login for enrolled users.
+func (a App) Register() error {
+	db.UseModel(Enrollment{})
+	auth.SecondFactor = func(user *model.User, code string) error {
+		return Check(user.UUID, code)
+	}
+	return nil
+}
+
+// Router sets up the TOTP endpoints of the current user.
+// GET /user/totp returns the enrollment state.
+// POST /user/totp/enroll returns a new secret and its provisioning URI.
+// POST /user/totp/confirm {"code"} enables two-factor authentication and returns the recovery codes.
+// POST /user/totp/disable {"code"} disables two-factor authentication.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/user/totp", func(request *evo.Request) interface{} {
+		var user, err = currentUser(request)
+		if err != nil {
+			return err
+		}
+		var enrollment = Get(user.UUID)
+		return map[string]interface{}{"enrolled": enrollment != nil, "confirmed": enrollment != nil && enrollment.Confirmed}
+	})
+	evo.Post(PREFIX+"/user/totp/enroll", func(request *evo.Request) interface{} {
+		var user, err = currentUser(request)
+		if err != nil {
+			return err
+		}
+		secret, uri, err := Enroll(user)
+		if err != nil {
+			return err
+		}
+		return map[string]string{"secret": secret, "uri": uri}
+	})
+	evo.Post(PREFIX+"/user/totp/confirm", func(request *evo.Request) interface{} {
+		var user, err = currentUser(request)
+		if err != nil {
+			return err
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := request.BodyParser(&body); err != nil {
+			return err
+		}
+		codes, err := Confirm(user.UUID, body.Code)
+		if err != nil {
+			return err
+		}
+		return map[string]interface{}{"recovery_codes": codes}
+	})
+	evo.Post(PREFIX+"/user/totp/disable", func(request *evo.Request) interface{} {
+		var user, err = currentUser(request)
+		if err != nil {
+			return err
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := request.BodyParser(&body); err != nil {
+			return err
+		}
+		return Disable(user.UUID, body.Code)
+	})
+	return nil
+}
+
+// currentUser returns the user authenticated by a token, API keys can not enroll.
+func currentUser(request *evo.Request) (*model.User, error) {
+	user, ok := request.User().(auth.User)
+	if !ok || user.User == nil {
+		return nil, auth.ErrorUnauthorized
+	}
+	return user.User, nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "totp"
+}
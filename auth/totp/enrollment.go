@@ -0,0 +1,199 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/encrypt"
+	"github.com/iesitalia/toolbox/model"
+	"gorm.io/gorm"
+)
+
+// Issuer is the issuer shown by authenticator apps.
+var Issuer = "Toolbox"
+
+// RecoveryCodes specifies the number of recovery codes generated on confirmation.
+var RecoveryCodes = 10
+
+var (
+	ErrNotEnrolled      = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyConfirmed = errors.New("two-factor authentication is already enabled")
+	ErrCodeRequired     = errors.New("two-factor code required")
+	ErrInvalidCode      = errors.New("invalid two-factor code")
+)
+
+// Enrollment holds the TOTP secret of a user. It is only enforced once confirmed with a valid code.
+// Recovery codes are stored hashed and can each be used once instead of a code.
+type Enrollment struct {
+	User          string     `gorm:"column:user;size:36;primaryKey;fk:users.uuid" json:"user"`
+	Secret        Secret     `gorm:"column:secret;size:255" json:"-"`
+	Confirmed     bool       `gorm:"column:confirmed" json:"confirmed"`
+	ConfirmedAt   *time.Time `gorm:"column:confirmed_at" json:"confirmed_at"`
+	RecoveryCodes string     `gorm:"column:recovery_codes;type:text" json:"-"`
+	LastStep      int64      `gorm:"column:last_step" json:"-"`
+}
+
+// TableName returns the name of the table for the Enrollment struct.
+func (Enrollment) TableName() string {
+	return "user_totp"
+}
+
+// Secret is the TOTP secret of an enrollment, stored encrypted with the current key of the encrypt package.
+// Secrets stored in clear by earlier versions are read as is, and encrypted when the enrollment is saved.
+type Secret string
+
+// Value encrypts the secret with the current key.
+func (s Secret) Value() (driver.Value, error) {
+	return encrypt.Encrypted(s).Value()
+}
+
+// Scan decrypts the secret loaded from the database.
+func (s *Secret) Scan(value interface{}) error {
+	if text, ok := value.(string); ok && !strings.Contains(text, ":") {
+		*s = Secret(text)
+		return nil
+	}
+	if b, ok := value.([]byte); ok && !strings.Contains(string(b), ":") {
+		*s = Secret(b)
+		return nil
+	}
+	var e encrypt.Encrypted
+	if err := e.Scan(value); err != nil {
+		return err
+	}
+	*s = Secret(e)
+	return nil
+}
+
+// Get returns the enrollment of the user, or nil if the user has not enrolled.
+func Get(user string) *Enrollment {
+	var enrollment Enrollment
+	if db.Where("`user` = ?", user).Take(&enrollment).RowsAffected == 0 {
+		return nil
+	}
+	return &enrollment
+}
+
+// Enroll generates a new secret for the user and returns it with its provisioning URI.
+// A confirmed enrollment must be disabled first.
+func Enroll(user *model.User) (secret string, uri string, err error) {
+	if existing := Get(user.UUID); existing != nil && existing.Confirmed {
+		return "", "", ErrAlreadyConfirmed
+	}
+	if secret, err = GenerateSecret(); err != nil {
+		return "", "", err
+	}
+	var enrollment = Enrollment{User: user.UUID, Secret: Secret(secret)}
+	if err = db.Save(&enrollment).Error; err != nil {
+		return "", "", err
+	}
+	var account = user.Email
+	if account == "" {
+		account = user.UUID
+	}
+	return secret, ProvisioningURI(Issuer, account, secret), nil
+}
+
+// Confirm enables two-factor authentication once the user proves the secret is set up, and returns the recovery codes.
+func Confirm(user string, code string) ([]string, error) {
+	var enrollment = Get(user)
+	if enrollment == nil {
+		return nil, ErrNotEnrolled
+	}
+	if enrollment.Confirmed {
+		return nil, ErrAlreadyConfirmed
+	}
+	step, ok := Validate(string(enrollment.Secret), code, time.Now(), enrollment.LastStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	var now = time.Now()
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = &now
+	enrollment.LastStep = step
+	enrollment.RecoveryCodes = hashes
+	return codes, db.Save(enrollment).Error
+}
+
+// Disable removes the enrollment of the user after checking a code or a recovery code.
+func Disable(user string, code string) error {
+	if err := Check(user, code); err != nil {
+		return err
+	}
+	return db.Where("`user` = ?", user).Delete(&Enrollment{}).Error
+}
+
+// Check verifies a code or a recovery code of the user. Users without a confirmed enrollment pass.
+// A recovery code is consumed when used. Codes and recovery codes are consumed by conditional updates, so
+// concurrent logins can not use the same code twice.
+func Check(user string, code string) error {
+	var enrollment = Get(user)
+	if enrollment == nil || !enrollment.Confirmed {
+		return nil
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrCodeRequired
+	}
+	if step, ok := Validate(string(enrollment.Secret), code, time.Now(), enrollment.LastStep); ok {
+		return consume(db.Model(&Enrollment{}).Where("`user` = ? AND last_step < ?", user, step).
+			UpdateColumn("last_step", step))
+	}
+	var hashes []string
+	_ = json.Unmarshal([]byte(enrollment.RecoveryCodes), &hashes)
+	var hash = hashCode(code)
+	for idx, item := range hashes {
+		if item == hash {
+			hashes = append(hashes[:idx], hashes[idx+1:]...)
+			b, _ := json.Marshal(hashes)
+			return consume(db.Model(&Enrollment{}).Where("`user` = ? AND recovery_codes = ?", user, enrollment.RecoveryCodes).
+				UpdateColumn("recovery_codes", string(b)))
+		}
+	}
+	return ErrInvalidCode
+}
+
+// consume returns the error of the update consuming a code, ErrInvalidCode when a concurrent check consumed it first.
+func consume(tx *gorm.DB) error {
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// generateRecoveryCodes returns the recovery codes and the JSON list of their hashes.
+func generateRecoveryCodes() ([]string, string, error) {
+	var codes []string
+	var hashes []string
+	for i := 0; i < RecoveryCodes; i++ {
+		var b = make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		var code = hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashCode(code))
+	}
+	b, _ := json.Marshal(hashes)
+	return codes, string(b), nil
+}
+
+func hashCode(code string) string {
+	var sum = sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/encrypt"
+)
+
+// secret is the RFC 6238 SHA1 test key "12345678901234567890".
+var secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		got, err := Code(secret, Step(time.Unix(test.unix, 0)))
+		if err != nil || got != test.want {
+			t.Errorf("Code at %d = %q, %v, want %q", test.unix, got, err, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var now = time.Unix(1234567890, 0)
+	code, _ := Code(secret, Step(now.Add(-Period)))
+	step, ok := Validate(secret, code, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("Validate within drift = %d, %v", step, ok)
+	}
+	if _, ok := Validate(secret, code, now, step); ok {
+		t.Error("Validate accepted a code twice")
+	}
+	if _, ok := Validate(secret, code, now.Add(5*Period), 0); ok {
+		t.Error("Validate accepted a code outside the drift window")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	var uri = ProvisioningURI("Toolbox", "user@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Toolbox:user@example.com?") || !strings.Contains(uri, "secret=ABC") {
+		t.Errorf("ProvisioningURI = %q", uri)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	if err := encrypt.AddKey("totp", []byte("0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if err := encrypt.SetCurrentKey("totp"); err != nil {
+		t.Fatal(err)
+	}
+	value, err := Secret(secret).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := value.(string); strings.Contains(stored, secret) || encrypt.KeyID(stored) != "totp" {
+		t.Errorf("secret stored as %q", stored)
+	}
+	var s Secret
+	if err := s.Scan(value); err != nil || string(s) != secret {
+		t.Errorf("Scan(encrypted) = %q, %v", s, err)
+	}
+	if err := s.Scan([]byte("LEGACYSECRET")); err != nil || s != "LEGACYSECRET" {
+		t.Errorf("Scan(clear) = %q, %v", s, err)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/log"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/model"
+	"github.com/iesitalia/toolbox/tenancy"
+)
+
+// PermissionResolver returns the permissions of an authenticated user.
+// By default permissions of the "permissions" claim of the token are merged with the
+// permissions granted to the user through its acl roles.
+var PermissionResolver = func(user *model.User, claims Claims) []string {
+	var permissions = claims.Strings("permissions")
+	if user != nil && user.UUID != "" {
+		granted, err := acl.CachedPermissions(user.UUID)
+		if err != nil {
+			log.Error("unable to load user permissions", "user", user.UUID, "error", err)
+		}
+		permissions = append(permissions, granted...)
+	}
+	return permissions
+}
+
+// User is the evo user interface of requests authenticated by a Bearer JWT or an API key.
+// Requests authenticated by an API key have a nil User and the APIKey set.
+type User struct {
+	User        *model.User
+	APIKey      *APIKey
+	Claims      Claims
+	permissions map[string]bool
+}
+
+// FromRequest authenticates the request using the API key of the APIKeyHeader header if present,
+// otherwise using the Bearer token of the Authorization header.
+// The token claims are stored in the tenancy.ClaimsLocal request local.
+// It returns an anonymous user if the credential is missing or invalid.
+func (u User) FromRequest(request *evo.Request) evo.UserInterface {
+	if secret := request.Header(APIKeyHeader); secret != "" {
+		key, err := AuthenticateAPIKey(secret)
+		if err != nil {
+			return User{}
+		}
+		var result = User{APIKey: key, permissions: map[string]bool{}}
+		for _, permission := range key.Permissions() {
+			result.permissions[strings.ToUpper(permission)] = true
+		}
+		return result
+	}
+
+	var authorization = request.Header("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+		return User{}
+	}
+	claims, err := Verify(strings.TrimSpace(authorization[7:]))
+	if err != nil {
+		return User{}
+	}
+	if sid := claims.Get(SessionClaim).String(); sid != "" && !SessionActive(sid) {
+		return User{}
+	}
+	request.Locals(tenancy.ClaimsLocal, map[string]interface{}(claims))
+
+	var user = model.User{}
+	if db.Where("uuid = ?", claims.Subject()).Take(&user).RowsAffected == 0 {
+		if !Config.AllowUnknownUsers {
+			return User{}
+		}
+		user = model.User{
+			UUID:      claims.Subject(),
+			FirstName: claims.Get("given_name").String(),
+			LastName:  claims.Get("family_name").String(),
+			Email:     claims.Get("email").String(),
+		}
+	}
+
+	var result = User{User: &user, Claims: claims, permissions: map[string]bool{}}
+	for _, permission := range PermissionResolver(&user, claims) {
+		result.permissions[strings.ToUpper(permission)] = true
+	}
+	return result
+}
+
+// Impersonate returns the user with the given uuid with the permissions granted by its acl roles.
+// It returns nil if the user does not exist.
+func Impersonate(request *evo.Request, uuid string) evo.UserInterface {
+	var user = model.User{}
+	if db.Where("uuid = ?", uuid).Take(&user).RowsAffected == 0 {
+		return nil
+	}
+	var result = User{User: &user, permissions: map[string]bool{}}
+	for _, permission := range PermissionResolver(&user, nil) {
+		result.permissions[strings.ToUpper(permission)] = true
+	}
+	return result
+}
+
+// HasPermission reports whether the user has been granted the given permission, directly or through a wildcard.
+func (u User) HasPermission(permission string) bool {
+	if u.permissions[strings.ToUpper(permission)] {
+		return true
+	}
+	return acl.Match(u.Permissions(), permission)
+}
+
+// Permissions returns the permissions of the user.
+func (u User) Permissions() []string {
+	var result []string
+	for permission := range u.permissions {
+		result = append(result, permission)
+	}
+	return result
+}
+
+func (u User) GetFirstName() string {
+	if u.APIKey != nil {
+		return u.APIKey.Name
+	}
+	if u.User == nil {
+		return ""
+	}
+	return u.User.FirstName
+}
+
+func (u User) GetLastName() string {
+	if u.User == nil {
+		return ""
+	}
+	return u.User.LastName
+}
+
+func (u User) GetFullName() string {
+	return strings.TrimSpace(u.GetFirstName() + " " + u.GetLastName())
+}
+
+func (u User) GetEmail() string {
+	if u.User == nil {
+		return ""
+	}
+	return u.User.Email
+}
+
+// UUID returns the uuid of the user, or "apikey:<id>" for API keys so every key has its own identity in
+// rate limits, ownership and preferences.
+func (u User) UUID() string {
+	if u.APIKey != nil {
+		return APIKeyUUIDPrefix + strconv.FormatUint(u.APIKey.ID, 10)
+	}
+	if u.User == nil {
+		return ""
+	}
+	return u.User.UUID
+}
+
+// accountUUID returns the uuid of the user account of the request, empty for anonymous requests and API keys.
+func accountUUID(request *evo.Request) string {
+	var user = request.User()
+	if user.Anonymous() || strings.HasPrefix(user.UUID(), APIKeyUUIDPrefix) {
+		return ""
+	}
+	return user.UUID()
+}
+
+// ID returns the id of the API key, or a stable non-zero id derived from the UUID of the user
+// since users are keyed by UUID. It returns 0 for anonymous users.
+func (u User) ID() uint64 {
+	if u.APIKey != nil {
+		return u.APIKey.ID
+	}
+	if u.User == nil || u.User.UUID == "" {
+		return 0
+	}
+	var h = fnv.New64a()
+	h.Write([]byte(u.User.UUID))
+	if id := h.Sum64(); id != 0 {
+		return id
+	}
+	return 1
+}
+
+func (u User) Anonymous() bool {
+	return u.User == nil && u.APIKey == nil
+}
+
+func (u User) Attributes() evo.Attributes {
+	return evo.Attributes(u.Claims)
+}
+
+func (u User) Interface() interface{} {
+	if u.APIKey != nil {
+		return u.APIKey
+	}
+	return u.User
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/iesitalia/toolbox/model"
+)
+
+func TestUserID(t *testing.T) {
+	if id := (User{}).ID(); id != 0 {
+		t.Fatalf("anonymous id = %d, want 0", id)
+	}
+	if id := (User{APIKey: &APIKey{ID: 7}}).ID(); id != 7 {
+		t.Fatalf("api key id = %d, want 7", id)
+	}
+	var a = User{User: &model.User{UUID: "3f0e1c2a-0000-4000-8000-000000000001"}}
+	var b = User{User: &model.User{UUID: "3f0e1c2a-0000-4000-8000-000000000002"}}
+	if a.ID() == 0 || a.ID() != a.ID() {
+		t.Fatalf("user id = %d, want a stable non-zero id", a.ID())
+	}
+	if a.ID() == b.ID() {
+		t.Fatalf("distinct users share the id %d", a.ID())
+	}
+}
+
+func TestUserUUID(t *testing.T) {
+	if uuid := (User{}).UUID(); uuid != "" {
+		t.Errorf("anonymous uuid = %q, want empty", uuid)
+	}
+	if uuid := (User{User: &model.User{UUID: "u1"}}).UUID(); uuid != "u1" {
+		t.Errorf("user uuid = %q, want u1", uuid)
+	}
+	var a, b = User{APIKey: &APIKey{ID: 7}}, User{APIKey: &APIKey{ID: 8}}
+	if a.UUID() != "apikey:7" || a.UUID() == b.UUID() {
+		t.Errorf("api key uuids = %q, %q, want distinct non-empty uuids", a.UUID(), b.UUID())
+	}
+}
+
+func TestUserPermissions(t *testing.T) {
+	var user = User{User: &model.User{}, permissions: map[string]bool{"ORDERS.VIEW": true, "REPORTS.*": true}}
+	for permission, want := range map[string]bool{"orders.view": true, "REPORTS.EXPORT": true, "ORDERS.EDIT": false} {
+		if got := user.HasPermission(permission); got != want {
+			t.Errorf("HasPermission(%q) = %v, want %v", permission, got, want)
+		}
+	}
+}
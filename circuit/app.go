@@ -0,0 +1,38 @@
+package circuit
+
+import (
+	"context"
+
+	"github.com/getevo/evo/v2"
+)
+
+type App struct {
+}
+
+// Register adds the database as a critical readiness check.
+func (a App) Register() error {
+	AddCheck("database", true, func(ctx context.Context) error {
+		sqlDB, err := evo.GetDBO().DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	return nil
+}
+
+// Router sets up the readiness endpoint.
+// GET /readyz reports the status of the service, with status 503 when a critical check fails, along with the
+// checks and breakers to the users holding acl.Admin.
+func (a App) Router() error {
+	evo.Get("/readyz", readyz)
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "circuit"
+}
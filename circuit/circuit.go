@@ -0,0 +1,241 @@
+// Package circuit lets the optional subsystems (cache, search, webhooks, rate providers) degrade gracefully.
+//
+// Calls to a dependency go through a named Breaker. After Threshold consecutive failures the breaker opens
+// and calls fail fast with ErrorOpen for Cooldown, then a single trial call decides whether it closes again.
+// Callers provide a fallback, such as querying SQL instead of the search index, queueing the work for later
+// or serving the last good value with Stale:
+//
+//	results, err := circuit.Call(circuit.Get("search"), func() ([]Hit, error) {
+//		return index.Search(q)
+//	}, func(err error) ([]Hit, error) {
+//		return searchSQL(q)
+//	})
+//
+// The state of the breakers is reported by the /readyz endpoint.
+package circuit
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iesitalia/toolbox/logger"
+)
+
+// ErrorOpen is returned by the calls rejected while a breaker is open.
+var ErrorOpen = errors.New("circuit open")
+
+// DefaultThreshold is the number of consecutive failures opening a breaker.
+var DefaultThreshold = 5
+
+// DefaultCooldown is how long a breaker stays open before a trial call.
+var DefaultCooldown = 30 * time.Second
+
+// State of a breaker.
+type State string
+
+const (
+	Closed   State = "closed"
+	Open     State = "open"
+	HalfOpen State = "half_open"
+)
+
+// Breaker tracks the failures of a dependency.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	lastError error
+	trial     bool
+	now       func() time.Time
+}
+
+// Status is the state of a breaker reported by the health endpoint.
+type Status struct {
+	Name      string     `json:"name"`
+	State     State      `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+var breakers = map[string]*Breaker{}
+var breakersMu sync.Mutex
+
+// Get returns the breaker with the given name, creating it with the default threshold and cooldown.
+func Get(name string) *Breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if b, ok := breakers[name]; ok {
+		return b
+	}
+	var b = &Breaker{Name: name, Threshold: DefaultThreshold, Cooldown: DefaultCooldown, state: Closed}
+	breakers[name] = b
+	return b
+}
+
+// Breakers returns the status of every breaker sorted by name.
+func Breakers() []Status {
+	breakersMu.Lock()
+	var list []*Breaker
+	for _, b := range breakers {
+		list = append(list, b)
+	}
+	breakersMu.Unlock()
+	var result []Status
+	for _, b := range list {
+		result = append(result, b.Status())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (b *Breaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// Allow reports whether a call may be attempted. While open it allows a single trial call once the cooldown is over.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.clock().Sub(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// Success records a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != Closed {
+		logger.Info("circuit closed", "circuit", b.Name)
+	}
+	b.state = Closed
+	b.failures = 0
+	b.trial = false
+	b.lastError = nil
+}
+
+// Failure records a failed call, opening the breaker after Threshold consecutive failures or a failed trial call.
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastError = err
+	b.trial = false
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.Threshold) {
+		if b.state == Closed {
+			logger.Warning("circuit open", "circuit", b.Name, "failures", b.failures, "error", errorString(err))
+		}
+		b.state = Open
+		b.openedAt = b.clock()
+	}
+}
+
+// Do runs fn unless the breaker is open and records its outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if !b.Allow() {
+		return ErrorOpen
+	}
+	if err := fn(); err != nil {
+		b.Failure(err)
+		return err
+	}
+	b.Success()
+	return nil
+}
+
+// Status returns the current state of the breaker.
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var status = Status{Name: b.Name, State: b.state, Failures: b.failures, LastError: errorString(b.lastError)}
+	if b.state != Closed {
+		var opened = b.openedAt
+		status.OpenedAt = &opened
+	}
+	return status
+}
+
+// Call runs fn through the breaker and calls fallback with the error if fn fails or the breaker is open.
+func Call[T any](b *Breaker, fn func() (T, error), fallback func(err error) (T, error)) (T, error) {
+	var result T
+	var err = b.Do(func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	if err != nil && fallback != nil {
+		return fallback(err)
+	}
+	return result, err
+}
+
+// Stale keeps the last good value of a call, served while the dependency is down:
+//
+//	var rates circuit.Stale[Rates]
+//	current, err := rates.Get(circuit.Get("rates"), fetchRates)
+type Stale[T any] struct {
+	mu      sync.RWMutex
+	value   T
+	ok      bool
+	updated time.Time
+}
+
+// Get runs fn through the breaker and stores its result. If fn fails or the breaker is open,
+// the last good value is returned without error, or the error if no value was ever fetched.
+func (s *Stale[T]) Get(b *Breaker, fn func() (T, error)) (T, error) {
+	return Call(b, func() (T, error) {
+		v, err := fn()
+		if err == nil {
+			s.mu.Lock()
+			s.value, s.ok, s.updated = v, true, time.Now()
+			s.mu.Unlock()
+		}
+		return v, err
+	}, func(err error) (T, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if s.ok {
+			return s.value, nil
+		}
+		return s.value, err
+	})
+}
+
+// Updated returns when the value was last fetched, zero if never.
+func (s *Stale[T]) Updated() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updated
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
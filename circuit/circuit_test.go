@@ -0,0 +1,109 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var now = time.Now()
+	var b = &Breaker{Name: "test", Threshold: 2, Cooldown: time.Minute, state: Closed, now: func() time.Time { return now }}
+	var fail = func() error { return errors.New("down") }
+	var ok = func() error { return nil }
+
+	var steps = []struct {
+		name    string
+		advance time.Duration
+		fn      func() error
+		err     error
+		state   State
+	}{
+		{"first failure", 0, fail, nil, Closed},
+		{"threshold", 0, fail, nil, Open},
+		{"rejected", 0, ok, ErrorOpen, Open},
+		{"failed trial", time.Minute, fail, nil, Open},
+		{"rejected after trial", time.Second, ok, ErrorOpen, Open},
+		{"successful trial", time.Minute, ok, nil, Closed},
+		{"closed", 0, ok, nil, Closed},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		var err = b.Do(step.fn)
+		if step.err != nil && !errors.Is(err, step.err) {
+			t.Errorf("%s: Do() = %v, want %v", step.name, err, step.err)
+		}
+		if state := b.Status().State; state != step.state {
+			t.Errorf("%s: state = %s, want %s", step.name, state, step.state)
+		}
+	}
+}
+
+func TestStale(t *testing.T) {
+	var b = &Breaker{Name: "rates", Threshold: 1, Cooldown: time.Minute, state: Closed}
+	var s Stale[int]
+	if _, err := s.Get(b, func() (int, error) { return 0, errors.New("down") }); err == nil {
+		t.Error("Get() without a value should fail")
+	}
+	b.Success()
+	if v, err := s.Get(b, func() (int, error) { return 42, nil }); err != nil || v != 42 {
+		t.Errorf("Get() = %d, %v, want 42", v, err)
+	}
+	if v, err := s.Get(b, func() (int, error) { return 0, errors.New("down") }); err != nil || v != 42 {
+		t.Errorf("stale Get() = %d, %v, want 42", v, err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var tests = []struct {
+		name     string
+		checks   []CheckResult
+		breakers []Status
+		want     string
+	}{
+		{"ready", []CheckResult{{Name: "database", Critical: true, OK: true}}, []Status{{Name: "search", State: Closed}}, Ready},
+		{"optional check", []CheckResult{{Name: "cache", OK: false}}, nil, Degraded},
+		{"open breaker", nil, []Status{{Name: "search", State: Open}}, Degraded},
+		{"critical check", []CheckResult{{Name: "cache", OK: false}, {Name: "database", Critical: true, OK: false}}, nil, NotReady},
+	}
+	for _, tt := range tests {
+		if got := report(tt.checks, tt.breakers).Status; got != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	defer func(timeout time.Duration) { CheckTimeout = timeout }(CheckTimeout)
+	CheckTimeout = 50 * time.Millisecond
+	var tests = []struct {
+		name  string
+		fn    func(ctx context.Context) error
+		error string
+	}{
+		{"pass", func(ctx context.Context) error { return nil }, ""},
+		{"fail", func(ctx context.Context) error { return errors.New("down") }, "down"},
+		{"panic", func(ctx context.Context) error { panic("boom") }, "panic: boom"},
+		{"timeout", func(ctx context.Context) error { time.Sleep(time.Second); return nil }, ErrorTimeout.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AddCheck(tt.name, false, tt.fn)
+			defer RemoveCheck(tt.name)
+			var report = Health(context.Background())
+			if len(report.Checks) != 1 {
+				t.Fatalf("Health() = %+v, want a single check", report)
+			}
+			if result := report.Checks[0]; result.Name != tt.name || result.OK != (tt.error == "") || result.Error != tt.error {
+				t.Errorf("Health() check = %+v, want error %q", result, tt.error)
+			}
+		})
+	}
+	AddCheck("cache", false, func(ctx context.Context) error { return nil })
+	AddCheck("cache", true, func(ctx context.Context) error { return nil })
+	defer RemoveCheck("cache")
+	if report := Health(context.Background()); len(report.Checks) != 1 || !report.Checks[0].Critical {
+		t.Errorf("expected AddCheck to replace the check with the same name, got %+v", report.Checks)
+	}
+}
@@ -0,0 +1,158 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/outcome"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// CheckTimeout bounds the duration of each readiness check.
+var CheckTimeout = 2 * time.Second
+
+// ErrorTimeout is reported for the checks which do not complete within CheckTimeout.
+var ErrorTimeout = errors.New("check timed out")
+
+// Health of the service reported by /readyz.
+const (
+	Ready    = "ready"
+	Degraded = "degraded"
+	NotReady = "not_ready"
+)
+
+type check struct {
+	name     string
+	critical bool
+	fn       func(ctx context.Context) error
+}
+
+var checks []check
+var checksMu sync.Mutex
+
+// AddCheck adds a readiness check, replacing the check with the same name. A failing critical check makes the
+// service not ready, other failing checks and open breakers only mark it as degraded.
+func AddCheck(name string, critical bool, fn func(ctx context.Context) error) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	for idx := range checks {
+		if checks[idx].name == name {
+			checks[idx] = check{name: name, critical: critical, fn: fn}
+			return
+		}
+	}
+	checks = append(checks, check{name: name, critical: critical, fn: fn})
+}
+
+// RemoveCheck removes a readiness check.
+func RemoveCheck(name string) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	for idx := range checks {
+		if checks[idx].name == name {
+			checks = append(checks[:idx], checks[idx+1:]...)
+			return
+		}
+	}
+}
+
+// CheckResult is the outcome of a readiness check.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the body of the /readyz endpoint. The checks and breakers are reported to the users holding
+// acl.Admin only.
+type Report struct {
+	Status   string        `json:"status"`
+	Checks   []CheckResult `json:"checks,omitempty"`
+	Breakers []Status      `json:"breakers,omitempty"`
+}
+
+// Health runs the readiness checks in parallel, each within CheckTimeout, and returns them with the state of
+// the breakers. Panics fail the check.
+func Health(ctx context.Context) Report {
+	checksMu.Lock()
+	var list = append([]check{}, checks...)
+	checksMu.Unlock()
+
+	var results = make([]CheckResult, len(list))
+	var wg sync.WaitGroup
+	for idx := range list {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			var c = list[idx]
+			var err = c.run(ctx)
+			results[idx] = CheckResult{Name: c.name, Critical: c.critical, OK: err == nil, Error: errorString(err)}
+		}(idx)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return report(results, Breakers())
+}
+
+// run runs the check within CheckTimeout, returning ErrorTimeout if it does not complete in time.
+func (c check) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	var done = make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrorTimeout
+	}
+}
+
+// report sums up the checks and breakers in the status of the service.
+func report(results []CheckResult, states []Status) Report {
+	var r = Report{Status: Ready, Checks: results, Breakers: states}
+	for _, result := range results {
+		if result.OK {
+			continue
+		}
+		if result.Critical {
+			r.Status = NotReady
+			return r
+		}
+		r.Status = Degraded
+	}
+	for _, state := range states {
+		if state.State != Closed {
+			r.Status = Degraded
+		}
+	}
+	return r
+}
+
+// readyz reports the health of the service, responding 503 when it is not ready.
+// The checks and breakers, whose errors may disclose the infrastructure, are left out for the other users.
+func readyz(request *evo.Request) interface{} {
+	var r = Health(context.Background())
+	if user := request.User(); user.Anonymous() || !user.HasPermission(acl.Admin) {
+		r = Report{Status: r.Status}
+	}
+	if r.Status == NotReady {
+		return outcome.Json(r).Status(503)
+	}
+	return outcome.Json(r)
+}
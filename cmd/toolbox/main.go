@@ -0,0 +1,39 @@
+// Command toolbox runs the code generators of the toolbox:
+//
+//	toolbox gen [-type Order,Customer] [dir]
+//
+// gen writes the accessors of the models of the package in dir, the current directory by default, see the gen
+// package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iesitalia/toolbox/gen"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "gen" {
+		fmt.Fprintln(os.Stderr, "usage: toolbox gen [-type Order,Customer] [dir]")
+		os.Exit(2)
+	}
+	var flags = flag.NewFlagSet("gen", flag.ExitOnError)
+	var types = flags.String("type", "", "comma separated models to generate, the structs embedding rest.API by default")
+	_ = flags.Parse(os.Args[2:])
+
+	var dir = "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	if err := gen.Write(dir, names); err != nil {
+		fmt.Fprintln(os.Stderr, "toolbox gen:", err)
+		os.Exit(1)
+	}
+}
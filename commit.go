@@ -0,0 +1,42 @@
+package toolbox
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// OnCommit registers fn to run once a statement creating, updating or deleting rows of the model succeeded
+// and its transaction was committed, unlike the AfterSave and AfterDelete hooks which run inside the
+// transaction, e.g. to reload a cache from the committed rows. The end of the transactions opened by the
+// caller is not observable, so fn runs when the statements inside them complete.
+// Registering a name again replaces its function.
+//
+//	toolbox.OnCommit(evo.GetDBO(), "settings:reload", Setting{}, func(db *gorm.DB) {
+//		Reload()
+//	})
+func OnCommit(dbo *gorm.DB, name string, model interface{}, fn func(db *gorm.DB)) error {
+	var typ = reflect.Indirect(reflect.ValueOf(model)).Type()
+	var callback = func(db *gorm.DB) {
+		if db.Error == nil && db.Statement.Schema != nil && db.Statement.Schema.ModelType == typ {
+			fn(db)
+		}
+	}
+	var create, update, delete = dbo.Callback().Create(), dbo.Callback().Update(), dbo.Callback().Delete()
+	if create.Get(name) != nil {
+		if err := create.Replace(name, callback); err != nil {
+			return err
+		}
+		if err := update.Replace(name, callback); err != nil {
+			return err
+		}
+		return delete.Replace(name, callback)
+	}
+	if err := create.After("gorm:commit_or_rollback_transaction").Register(name, callback); err != nil {
+		return err
+	}
+	if err := update.After("gorm:commit_or_rollback_transaction").Register(name, callback); err != nil {
+		return err
+	}
+	return delete.After("gorm:commit_or_rollback_transaction").Register(name, callback)
+}
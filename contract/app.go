@@ -0,0 +1,50 @@
+package contract
+
+import (
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// PREFIX specifies the prefix for contract routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the recording model.
+func (a App) Register() error {
+	db.UseModel(Recording{})
+	return nil
+}
+
+// Router sets up the report endpoint, restricted to the administrators.
+// GET /contract/report replays the recordings against the running build, through its own router.
+// Headers prefixed with "Replay-" are forwarded without the prefix, e.g. Replay-Authorization.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/contract/report", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		var headers = map[string]string{}
+		for key, value := range request.ReqHeaders() {
+			if len(key) > 7 && key[:7] == "Replay-" {
+				headers[key[7:]] = value
+			}
+		}
+		report, err := Replay(headers)
+		if err != nil {
+			return err
+		}
+		return report
+	})
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "contract"
+}
@@ -0,0 +1,172 @@
+package contract
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/log"
+)
+
+// SampleRate specifies the ratio (0..1) of requests recorded. Zero disables recording.
+var SampleRate = 0.0
+
+// MaxPerEndpoint specifies the maximum number of recordings kept per endpoint.
+var MaxPerEndpoint int64 = 50
+
+// RecordMethods specifies the HTTP methods recorded; only safe methods should be replayed.
+var RecordMethods = []string{"GET"}
+
+// ReplayTimeout limits the time a replayed request may take.
+var ReplayTimeout = 30 * time.Second
+
+// Recording represents a recorded request of an endpoint along with the shape of its response. The bodies
+// of the request and the response are not stored, as they may hold personal data.
+type Recording struct {
+	ID         uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Endpoint   string    `gorm:"column:endpoint;size:255;index:contract_endpoint" json:"endpoint"`
+	Method     string    `gorm:"column:method;size:8" json:"method"`
+	URL        string    `gorm:"column:url;size:2048" json:"url"`
+	Shape      string    `gorm:"column:shape;type:text" json:"shape"`
+	RecordedAt time.Time `gorm:"column:recorded_at" json:"recorded_at"`
+}
+
+// TableName returns the name of the table for the Recording struct.
+func (Recording) TableName() string {
+	return "contract_recording"
+}
+
+// counts caches the number of recordings per endpoint to avoid counting on every request.
+var counts = map[string]int64{}
+var mu sync.Mutex
+
+// Record stores the request and the shape of its response if the method is recorded and the request is sampled.
+func Record(endpoint string, method string, url string, response []byte) {
+	if SampleRate <= 0 || rand.Float64() >= SampleRate {
+		return
+	}
+	var recorded = false
+	for _, item := range RecordMethods {
+		if strings.EqualFold(item, method) {
+			recorded = true
+			break
+		}
+	}
+	if !recorded {
+		return
+	}
+	shape, err := ParseShape(response)
+	if err != nil {
+		return
+	}
+	encoded, err := json.Marshal(shape)
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	count, ok := counts[endpoint]
+	if !ok {
+		db.Model(&Recording{}).Where("endpoint = ?", endpoint).Count(&count)
+	}
+	if count >= MaxPerEndpoint {
+		counts[endpoint] = count
+		mu.Unlock()
+		return
+	}
+	counts[endpoint] = count + 1
+	mu.Unlock()
+
+	var item = Recording{
+		Endpoint:   endpoint,
+		Method:     strings.ToUpper(method),
+		URL:        url,
+		Shape:      string(encoded),
+		RecordedAt: time.Now(),
+	}
+	if err := db.Create(&item).Error; err != nil {
+		log.Error("unable to store contract recording", "endpoint", endpoint, "error", err.Error())
+	}
+}
+
+// Result represents the outcome of replaying a single recording.
+type Result struct {
+	Recording         uint              `json:"recording"`
+	Endpoint          string            `json:"endpoint"`
+	URL               string            `json:"url"`
+	Error             string            `json:"error,omitempty"`
+	Incompatibilities []Incompatibility `json:"incompatibilities,omitempty"`
+}
+
+// Report summarizes the replay of all recordings against the running build.
+type Report struct {
+	Replayed     int      `json:"replayed"`
+	Compatible   int      `json:"compatible"`
+	Incompatible int      `json:"incompatible"`
+	Results      []Result `json:"results"`
+}
+
+// Replay sends every recorded request to the router of the running build and compares the response shapes
+// with the recorded ones. Requests never leave the process, whatever their URL.
+// Only incompatible or failed replays are listed in the report results.
+func Replay(headers map[string]string) (Report, error) {
+	var report = Report{}
+	var recordings []Recording
+	if err := db.Order("endpoint ASC, id ASC").Find(&recordings).Error; err != nil {
+		return report, err
+	}
+	for _, item := range recordings {
+		report.Replayed++
+		var result = Result{Recording: item.ID, Endpoint: item.Endpoint, URL: item.URL}
+		var expected Shape
+		if err := json.Unmarshal([]byte(item.Shape), &expected); err != nil {
+			result.Error = "invalid recording: " + err.Error()
+		} else if body, err := replay(item, headers); err != nil {
+			result.Error = err.Error()
+		} else if actual, err := ParseShape(body); err != nil {
+			result.Error = "invalid response: " + err.Error()
+		} else {
+			result.Incompatibilities = Compare(expected, actual)
+		}
+		if result.Error == "" && len(result.Incompatibilities) == 0 {
+			report.Compatible++
+			continue
+		}
+		report.Incompatible++
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// replay sends the recorded request to the router of the running build and returns the body of the response.
+func replay(item Recording, headers map[string]string) ([]byte, error) {
+	// only the path and the query of the recorded URL are kept, so the request can not target another host
+	var path = item.URL
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j:]
+		} else {
+			path = "/"
+		}
+	}
+	req, err := http.NewRequest(item.Method, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := evo.GetFiber().Test(req, int(ReplayTimeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,101 @@
+package contract
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Shape represents the structure of a JSON document as a map of field paths to JSON types.
+// Array elements share the path of the array suffixed with "[]".
+type Shape map[string]string
+
+// ParseShape returns the shape of the given JSON document.
+func ParseShape(data []byte) (Shape, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var shape = Shape{}
+	shape.walk("$", v)
+	return shape, nil
+}
+
+func (s Shape) walk(path string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		s.set(path, "object")
+		for key, item := range value {
+			s.walk(path+"."+key, item)
+		}
+	case []interface{}:
+		s.set(path, "array")
+		for _, item := range value {
+			s.walk(path+"[]", item)
+		}
+	case string:
+		s.set(path, "string")
+	case float64:
+		s.set(path, "number")
+	case bool:
+		s.set(path, "bool")
+	case nil:
+		s.set(path, "null")
+	}
+}
+
+// set stores the type of the path, a null never replaces a known type.
+func (s Shape) set(path string, typ string) {
+	if current, ok := s[path]; ok && typ == "null" && current != "null" {
+		return
+	}
+	s[path] = typ
+}
+
+// Incompatibility represents a difference between a recorded and a replayed response breaking clients.
+type Incompatibility struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Compare returns the incompatibilities of actual against the expected shape.
+// Removed fields and changed types are incompatible, added fields and null values are not.
+// Fields below arrays are only compared when the array is not empty in both responses.
+func Compare(expected Shape, actual Shape) []Incompatibility {
+	var result []Incompatibility
+	for path, typ := range expected {
+		got, ok := actual[path]
+		if !ok {
+			if parentEmpty(path, actual) {
+				continue
+			}
+			result = append(result, Incompatibility{Path: path, Expected: typ, Actual: "missing"})
+			continue
+		}
+		if typ != got && typ != "null" && got != "null" {
+			result = append(result, Incompatibility{Path: path, Expected: typ, Actual: got})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// parentEmpty reports whether the path belongs to an array which is present but empty in the shape.
+func parentEmpty(path string, shape Shape) bool {
+	for i := len(path) - 2; i > 0; i-- {
+		if path[i:i+2] == "[]" {
+			var array = path[:i]
+			if shape[array] == "array" {
+				for p := range shape {
+					if len(p) > len(array)+1 && p[:len(array)+2] == array+"[]" {
+						return false
+					}
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package contract
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	var recorded = `{"success":true,"data":[{"id":1,"name":"a","tags":["x"]}],"error":""}`
+	tests := []struct {
+		response string
+		want     int
+	}{
+		{`{"success":true,"data":[{"id":2,"name":"b","tags":["y"]}],"error":""}`, 0},
+		{`{"success":true,"data":[{"id":2,"name":"b","tags":["y"],"extra":1}],"error":""}`, 0},
+		{`{"success":true,"data":[],"error":""}`, 0},
+		{`{"success":true,"data":[{"id":2,"name":null,"tags":[]}],"error":""}`, 0},
+		{`{"success":true,"data":[{"id":"2","name":"b","tags":["y"]}],"error":""}`, 1},
+		{`{"success":true,"data":[{"name":"b","tags":["y"]}]}`, 2},
+	}
+	expected, err := ParseShape([]byte(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		actual, err := ParseShape([]byte(test.response))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Compare(expected, actual); len(got) != test.want {
+			t.Errorf("Compare(%s) = %v, want %d incompatibilities", test.response, got, test.want)
+		}
+	}
+}
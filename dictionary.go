@@ -24,6 +24,17 @@ func (d *Dictionary[T]) Has(key string) bool {
 	return false
 }
 
+// Get returns the value of a key and whether the key exists.
+func (d *Dictionary[T]) Get(key string) (T, bool) {
+	for _, kv := range *d {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
 // ContainsValue checks if a value exists in the dictionary and returns the KeyValue if found.
 func (d *Dictionary[T]) ContainsValue(v T) (bool, KeyValue[T]) {
 	for _, kv := range *d {
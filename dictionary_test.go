@@ -39,6 +39,21 @@ func TestDictionary(t *testing.T) {
 		}
 	})
 
+	// Test Get method
+	t.Run("Get", func(t *testing.T) {
+		dict := Dictionary[string]{
+			{Key: "name", Value: "Alice"},
+		}
+
+		if v, ok := dict.Get("name"); !ok || v != "Alice" {
+			t.Errorf("Expected to get 'Alice', got %q", v)
+		}
+
+		if _, ok := dict.Get("age"); ok {
+			t.Error("Expected not to get key 'age'")
+		}
+	})
+
 	// Test Set method
 	t.Run("Set", func(t *testing.T) {
 		dict := Dictionary[string]{}
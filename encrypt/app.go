@@ -0,0 +1,52 @@
+package encrypt
+
+import (
+	"errors"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// PREFIX specifies the prefix for encryption routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+func (a App) Register() error {
+	return nil
+}
+
+// Router sets up the key rotation endpoints, restricted to the administrators.
+// GET /encrypt/rotation returns the status of the last rotation.
+// POST /encrypt/rotation?key=k2&batch=500 starts the rotation of every encrypted column to the key k2.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/encrypt/rotation", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		return Rotations()
+	})
+	evo.Post(PREFIX+"/encrypt/rotation", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		var key = request.Query("key").String()
+		if key == "" {
+			return errors.New("key is required")
+		}
+		if err := StartRotation(key, request.Query("batch").Int()); err != nil {
+			return err
+		}
+		return Rotations()
+	})
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "encrypt"
+}
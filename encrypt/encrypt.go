@@ -0,0 +1,164 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown encryption key")
+	ErrInvalidKey     = errors.New("encryption key must be 16, 24 or 32 bytes long")
+	ErrInvalidPayload = errors.New("invalid encrypted payload")
+	ErrNoCurrentKey   = errors.New("no current encryption key")
+)
+
+var keys = struct {
+	sync.RWMutex
+	keys    map[string][]byte
+	current string
+}{keys: map[string][]byte{}}
+
+// AddKey registers an AES key under the given id. Keys must stay registered as long as values encrypted with them exist.
+func AddKey(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid encryption key id %q", id)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return ErrInvalidKey
+	}
+	keys.Lock()
+	keys.keys[id] = key
+	keys.Unlock()
+	return nil
+}
+
+// SetCurrentKey sets the id of the key used to encrypt new values.
+func SetCurrentKey(id string) error {
+	keys.Lock()
+	defer keys.Unlock()
+	if _, ok := keys.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+	keys.current = id
+	return nil
+}
+
+// CurrentKey returns the id of the key used to encrypt new values.
+func CurrentKey() string {
+	keys.RLock()
+	defer keys.RUnlock()
+	return keys.current
+}
+
+func getKey(id string) (cipher.AEAD, error) {
+	keys.RLock()
+	key, ok := keys.keys[id]
+	keys.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptWith encrypts the plain text with the given key. The result has the form keyID:base64(nonce|ciphertext).
+func EncryptWith(id string, plain []byte) (string, error) {
+	aead, err := getKey(id)
+	if err != nil {
+		return "", err
+	}
+	var nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Encrypt encrypts the plain text with the current key.
+func Encrypt(plain []byte) (string, error) {
+	var id = CurrentKey()
+	if id == "" {
+		return "", ErrNoCurrentKey
+	}
+	return EncryptWith(id, plain)
+}
+
+// Decrypt decrypts a value produced by Encrypt and returns the plain text with the id of the key used.
+func Decrypt(value string) ([]byte, string, error) {
+	id, payload, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", ErrInvalidPayload
+	}
+	aead, err := getKey(id)
+	if err != nil {
+		return nil, id, err
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, id, ErrInvalidPayload
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, id, ErrInvalidPayload
+	}
+	return plain, id, nil
+}
+
+// KeyID returns the id of the key used to encrypt the value.
+func KeyID(value string) string {
+	id, _, _ := strings.Cut(value, ":")
+	return id
+}
+
+// Encrypted is a string field stored encrypted with the current key and decrypted when loaded.
+//
+// Example:
+//
+//	type Customer struct {
+//	    ID    uint64            `gorm:"column:id;primaryKey"`
+//	    TaxID encrypt.Encrypted `gorm:"column:tax_id;size:512" json:"tax_id"`
+//	}
+type Encrypted string
+
+// Value encrypts the field with the current key.
+func (e Encrypted) Value() (driver.Value, error) {
+	return Encrypt([]byte(e))
+}
+
+// Scan decrypts the value loaded from the database.
+func (e *Encrypted) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*e = ""
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return ErrInvalidPayload
+	}
+	if s == "" {
+		*e = ""
+		return nil
+	}
+	plain, _, err := Decrypt(s)
+	if err != nil {
+		return err
+	}
+	*e = Encrypted(plain)
+	return nil
+}
@@ -0,0 +1,67 @@
+package encrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	if err := AddKey("k1", []byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddKey("k2", []byte("fedcba9876543210")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrentKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	value, err := Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, "k1:") || KeyID(value) != "k1" {
+		t.Errorf("Encrypt() = %q, want k1 prefix", value)
+	}
+	plain, id, err := Decrypt(value)
+	if err != nil || string(plain) != "secret" || id != "k1" {
+		t.Errorf("Decrypt(%q) = %q, %q, %v", value, plain, id, err)
+	}
+
+	var e Encrypted
+	rotated, _ := EncryptWith("k2", []byte("other"))
+	if err := e.Scan([]byte(rotated)); err != nil || e != "other" {
+		t.Errorf("Scan(%q) = %q, %v", rotated, e, err)
+	}
+	if _, _, err := Decrypt("k1:" + strings.Repeat("A", 40)); err == nil {
+		t.Error("Decrypt of tampered payload succeeded")
+	}
+	if _, _, err := Decrypt("k3:abc"); err != ErrUnknownKey {
+		t.Errorf("Decrypt with unknown key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestStartRotationRunning(t *testing.T) {
+	if err := AddKey("r1", []byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddKey("r2", []byte("fedcba9876543210fedcba9876543210")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrentKey("r1"); err != nil {
+		t.Fatal(err)
+	}
+	rotations.Lock()
+	rotations.running = true
+	rotations.Unlock()
+	t.Cleanup(func() {
+		rotations.Lock()
+		rotations.running = false
+		rotations.Unlock()
+	})
+	if err := StartRotation("r2", 10); err != ErrRotationRunning {
+		t.Fatalf("StartRotation() error = %v, want ErrRotationRunning", err)
+	}
+	if key := CurrentKey(); key != "r1" {
+		t.Errorf("CurrentKey() = %q, want the key to stay r1 while a rotation is running", key)
+	}
+}
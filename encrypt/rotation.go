@@ -0,0 +1,228 @@
+package encrypt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/getevo/evo/v2/lib/log"
+)
+
+// Rotation states.
+const (
+	RotationPending = "pending"
+	RotationRunning = "running"
+	RotationDone    = "done"
+	RotationFailed  = "failed"
+)
+
+// ErrRotationRunning is returned when a rotation is started while another one is running.
+var ErrRotationRunning = errors.New("key rotation already running")
+
+// Field identifies an encrypted column of a model with a single primary key.
+type Field struct {
+	Table      string `json:"table"`
+	PrimaryKey string `json:"primary_key"`
+	Column     string `json:"column"`
+}
+
+// Rotation represents the progress of the re-encryption of a column to a new key.
+// Remaining is the number of non empty values not yet encrypted with the key, computed by the verification.
+type Rotation struct {
+	Field
+	Key        string     `json:"key"`
+	State      string     `json:"state"`
+	Total      int64      `json:"total"`
+	Processed  int64      `json:"processed"`
+	Failed     int64      `json:"failed"`
+	Remaining  int64      `json:"remaining"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      string     `json:"error"`
+}
+
+var rotations = struct {
+	sync.Mutex
+	running bool
+	list    []*Rotation
+}{}
+
+var encryptedType = reflect.TypeOf(Encrypted(""))
+
+// Fields returns the Encrypted columns of the registered models.
+// Models with a composite primary key are skipped as rows are processed by key.
+func Fields() []Field {
+	var result []Field
+	for _, model := range schema.Models {
+		if model.Schema == nil || len(model.Schema.PrimaryFields) != 1 {
+			continue
+		}
+		for _, field := range model.Schema.Fields {
+			if field.DBName != "" && field.FieldType == encryptedType {
+				result = append(result, Field{Table: model.Table, PrimaryKey: model.Schema.PrimaryFields[0].DBName, Column: field.DBName})
+			}
+		}
+	}
+	return result
+}
+
+// Rotations returns the status of the last rotation.
+func Rotations() []Rotation {
+	rotations.Lock()
+	defer rotations.Unlock()
+	var result = make([]Rotation, len(rotations.list))
+	for idx, item := range rotations.list {
+		result[idx] = *item
+	}
+	return result
+}
+
+// StartRotation re-encrypts every Encrypted column to the given key in the background, by batches of the given size.
+// The key becomes the current key before the rotation starts so new values are not encrypted with the old key.
+// The key is not switched when a rotation is already running.
+func StartRotation(key string, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	rotations.Lock()
+	if rotations.running {
+		rotations.Unlock()
+		return ErrRotationRunning
+	}
+	if err := SetCurrentKey(key); err != nil {
+		rotations.Unlock()
+		return err
+	}
+	rotations.running = true
+	rotations.list = nil
+	for _, field := range Fields() {
+		rotations.list = append(rotations.list, &Rotation{Field: field, Key: key, State: RotationPending})
+	}
+	var list = rotations.list
+	rotations.Unlock()
+
+	go func() {
+		for _, rotation := range list {
+			rotate(rotation, batchSize)
+		}
+		rotations.Lock()
+		rotations.running = false
+		rotations.Unlock()
+	}()
+	return nil
+}
+
+// update applies fn to the rotation while holding the rotations lock.
+func update(rotation *Rotation, fn func(r *Rotation)) {
+	rotations.Lock()
+	fn(rotation)
+	rotations.Unlock()
+}
+
+// rotate re-encrypts the column of the rotation then verifies no value is left on another key.
+func rotate(rotation *Rotation, batchSize int) {
+	var now = time.Now()
+	update(rotation, func(r *Rotation) {
+		r.State = RotationRunning
+		r.StartedAt = &now
+	})
+	var finish = func(err error) {
+		var now = time.Now()
+		update(rotation, func(r *Rotation) {
+			r.FinishedAt = &now
+			if err != nil {
+				r.State = RotationFailed
+				r.Error = err.Error()
+			} else {
+				r.State = RotationDone
+			}
+		})
+		if err != nil {
+			log.Error("key rotation failed", "table", rotation.Table, "column", rotation.Column, "error", err)
+		}
+	}
+
+	var table, pk, column = quote(rotation.Table), quote(rotation.PrimaryKey), quote(rotation.Column)
+	var pending = fmt.Sprintf("%s IS NOT NULL AND %s <> '' AND %s NOT LIKE ?", column, column, column)
+	var prefix = rotation.Key + ":%"
+	var total int64
+	if err := db.Table(rotation.Table).Where(pending, prefix).Count(&total).Error; err != nil {
+		finish(err)
+		return
+	}
+	update(rotation, func(r *Rotation) { r.Total = total })
+
+	var last interface{}
+	for {
+		var rows []map[string]interface{}
+		var query = db.Table(rotation.Table).Select(pk+" AS id, "+column+" AS value").Where(pending, prefix).Order(pk).Limit(batchSize)
+		if last != nil {
+			query = query.Where(pk+" > ?", last)
+		}
+		if err := query.Find(&rows).Error; err != nil {
+			finish(err)
+			return
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			last = row["id"]
+			var err = reencrypt(table, pk, column, row["id"], toString(row["value"]), rotation.Key)
+			update(rotation, func(r *Rotation) {
+				r.Processed++
+				if err != nil {
+					r.Failed++
+				}
+			})
+			if err != nil {
+				log.Error("unable to re-encrypt value", "table", rotation.Table, "column", rotation.Column, "id", row["id"], "error", err)
+			}
+		}
+	}
+
+	var remaining int64
+	if err := db.Table(rotation.Table).Where(pending, prefix).Count(&remaining).Error; err != nil {
+		finish(err)
+		return
+	}
+	update(rotation, func(r *Rotation) { r.Remaining = remaining })
+	if remaining > 0 {
+		finish(fmt.Errorf("%d values are not encrypted with key %s", remaining, rotation.Key))
+		return
+	}
+	finish(nil)
+}
+
+// reencrypt encrypts the value of a row with the given key and verifies the result decrypts to the same plain text
+// before storing it. The update only applies if the value has not changed meanwhile.
+func reencrypt(table, pk, column string, id interface{}, value string, key string) error {
+	plain, _, err := Decrypt(value)
+	if err != nil {
+		return err
+	}
+	encrypted, err := EncryptWith(key, plain)
+	if err != nil {
+		return err
+	}
+	check, _, err := Decrypt(encrypted)
+	if err != nil || string(check) != string(plain) {
+		return errors.New("verification of the re-encrypted value failed")
+	}
+	return db.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s = ?", table, column, pk, column), encrypted, id, value).Error
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func quote(name string) string {
+	return "`" + name + "`"
+}
@@ -0,0 +1,344 @@
+// Package factory builds rows of the models filled with realistic fake values, derived from their schema, for
+// the integration tests of the downstream projects:
+//
+//	var f = factory.New(evo.GetDBO()).Seed(1)
+//	order, err := f.Create(&Order{}, factory.Values{"status": "draft"})
+//
+// String values follow the name of their column (emails, phones, urls, names...) and respect the size of the
+// column, enum columns take one of their values and unique columns a distinct value. Create inserts the row
+// after creating the parents its required belongs-to relations refer to.
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoDatabase is returned by Create when the factory has no database.
+var ErrNoDatabase = errors.New("factory has no database")
+
+// MaxDepth bounds the chain of parents created for a row.
+var MaxDepth = 5
+
+// Values are values set on the built rows, keyed by column, JSON or field name.
+type Values map[string]interface{}
+
+// Factory builds and creates fake rows. It is safe for concurrent use.
+type Factory struct {
+	db     *gorm.DB
+	cache  *sync.Map
+	naming schema.Namer
+	mu     sync.Mutex
+	rand   *rand.Rand
+	seq    int64
+}
+
+// New returns a factory creating the rows in the database, which may be nil to build rows only.
+func New(db *gorm.DB) *Factory {
+	var f = &Factory{db: db, cache: &sync.Map{}, naming: schema.NamingStrategy{}, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	if db != nil && db.Config != nil && db.NamingStrategy != nil {
+		f.naming = db.NamingStrategy
+	}
+	return f
+}
+
+// Seed makes the values built by the factory reproducible.
+func (f *Factory) Seed(seed int64) *Factory {
+	f.mu.Lock()
+	f.rand = rand.New(rand.NewSource(seed))
+	f.seq = 0
+	f.mu.Unlock()
+	return f
+}
+
+// Build returns a pointer to a new row of the type of the model filled with fake values, then with the values
+// given. The primary keys generated by the database, the timestamps set by gorm, the columns with a default
+// value and the foreign keys of the relations are left unset.
+func (f *Factory) Build(model interface{}, values ...Values) (interface{}, error) {
+	var typ = reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	var ptr = reflect.New(typ)
+	s, err := schema.Parse(ptr.Interface(), f.cache, f.naming)
+	if err != nil {
+		return nil, err
+	}
+	var foreign = foreignKeys(s)
+	for _, field := range s.Fields {
+		if field.DBName == "" || foreign[field] || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 ||
+			field.HasDefaultValue || (field.PrimaryKey && field.AutoIncrement) {
+			continue
+		}
+		if value := f.value(field); value != nil {
+			if err := field.Set(context.Background(), ptr.Elem(), value); err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+	}
+	for _, items := range values {
+		for key, value := range items {
+			var field = lookup(s, key)
+			if field == nil {
+				return nil, fmt.Errorf("%s has no field %s", s.Name, key)
+			}
+			if err := field.Set(context.Background(), ptr.Elem(), value); err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+	}
+	return ptr.Interface(), nil
+}
+
+// Create builds a row like Build and inserts it, creating first a parent row for each belongs-to relation
+// whose foreign key is required and not set. It returns a pointer to the inserted row.
+func (f *Factory) Create(model interface{}, values ...Values) (interface{}, error) {
+	return f.create(model, 0, values...)
+}
+
+// CreateMany creates n rows like Create.
+func (f *Factory) CreateMany(model interface{}, n int, values ...Values) ([]interface{}, error) {
+	var rows = make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		row, err := f.Create(model, values...)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (f *Factory) create(model interface{}, depth int, values ...Values) (interface{}, error) {
+	if f.db == nil {
+		return nil, ErrNoDatabase
+	}
+	row, err := f.Build(model, values...)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.Parse(row, f.cache, f.naming)
+	if err != nil {
+		return nil, err
+	}
+	var rv = reflect.ValueOf(row).Elem()
+	for _, relation := range missingParents(s, rv) {
+		if depth >= MaxDepth {
+			return nil, fmt.Errorf("%s: too many parents to create", s.Name)
+		}
+		parent, err := f.create(reflect.New(relation.FieldSchema.ModelType).Interface(), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", relation.Name, err)
+		}
+		var prv = reflect.ValueOf(parent).Elem()
+		for _, ref := range relation.References {
+			value, _ := ref.PrimaryKey.ValueOf(context.Background(), prv)
+			if err := ref.ForeignKey.Set(context.Background(), rv, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", ref.ForeignKey.Name, err)
+			}
+		}
+	}
+	if err := f.db.Omit(clause.Associations).Create(row).Error; err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// foreignKeys returns the foreign key fields of the belongs-to relations of the schema.
+func foreignKeys(s *schema.Schema) map[*schema.Field]bool {
+	var result = map[*schema.Field]bool{}
+	for _, relation := range s.Relationships.BelongsTo {
+		for _, ref := range relation.References {
+			if ref.ForeignKey != nil {
+				result[ref.ForeignKey] = true
+			}
+		}
+	}
+	return result
+}
+
+// missingParents returns the belongs-to relations of the row whose foreign keys are required and not set.
+// Relations to the schema itself, such as the parent of a tree, are never required.
+func missingParents(s *schema.Schema, rv reflect.Value) []*schema.Relationship {
+	var result []*schema.Relationship
+	for _, relation := range s.Relationships.BelongsTo {
+		if relation.FieldSchema == s || relation.FieldSchema.ModelType == s.ModelType {
+			continue
+		}
+		var missing = false
+		for _, ref := range relation.References {
+			if ref.ForeignKey == nil || ref.ForeignKey.FieldType.Kind() == reflect.Ptr {
+				continue
+			}
+			if _, zero := ref.ForeignKey.ValueOf(context.Background(), rv); zero {
+				missing = true
+			}
+		}
+		if missing {
+			result = append(result, relation)
+		}
+	}
+	return result
+}
+
+// lookup returns the field of the schema by column, JSON or field name.
+func lookup(s *schema.Schema, key string) *schema.Field {
+	if field := s.LookUpField(key); field != nil {
+		return field
+	}
+	for _, field := range s.Fields {
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == key {
+			return field
+		}
+	}
+	return nil
+}
+
+// words holds the generators of the string columns by a word of their name.
+var words = []struct {
+	word  string
+	value func(f *Factory, seq int64) string
+}{
+	{"email", func(f *Factory, seq int64) string {
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(f.pick(firstNames)), strings.ToLower(f.pick(lastNames)), seq)
+	}},
+	{"phone", func(f *Factory, seq int64) string { return fmt.Sprintf("+39 06 %07d", f.intn(10000000)) }},
+	{"mobile", func(f *Factory, seq int64) string {
+		return fmt.Sprintf("+39 3%02d %07d", f.intn(100), f.intn(10000000))
+	}},
+	{"url", func(f *Factory, seq int64) string { return fmt.Sprintf("https://example.com/%d", seq) }},
+	{"website", func(f *Factory, seq int64) string { return fmt.Sprintf("https://www%d.example.com", seq) }},
+	{"uuid", func(f *Factory, seq int64) string { return f.uuid() }},
+	{"first_name", func(f *Factory, seq int64) string { return f.pick(firstNames) }},
+	{"last_name", func(f *Factory, seq int64) string { return f.pick(lastNames) }},
+	{"name", func(f *Factory, seq int64) string { return f.pick(firstNames) + " " + f.pick(lastNames) }},
+	{"city", func(f *Factory, seq int64) string { return f.pick(cities) }},
+	{"country", func(f *Factory, seq int64) string { return f.pick(countries) }},
+	{"address", func(f *Factory, seq int64) string { return fmt.Sprintf("Via %s %d", f.pick(lastNames), f.intn(200)+1) }},
+	{"zip", func(f *Factory, seq int64) string { return fmt.Sprintf("%05d", f.intn(100000)) }},
+	{"currency", func(f *Factory, seq int64) string { return f.pick([]string{"EUR", "USD", "GBP", "CHF"}) }},
+	{"lang", func(f *Factory, seq int64) string { return f.pick([]string{"en", "it", "fr", "de"}) }},
+	{"locale", func(f *Factory, seq int64) string { return f.pick([]string{"en", "it", "fr", "de"}) }},
+	{"code", func(f *Factory, seq int64) string { return fmt.Sprintf("%s%d", strings.ToUpper(f.letters(3)), seq) }},
+}
+
+var firstNames = []string{"Jane", "John", "Giulia", "Marco", "Sofia", "Luca", "Emma", "Paolo"}
+var lastNames = []string{"Doe", "Rossi", "Bianchi", "Smith", "Ferrari", "Romano", "Colombo", "Ricci"}
+var cities = []string{"Rome", "Milan", "Turin", "Naples", "Florence", "Bologna"}
+var countries = []string{"IT", "FR", "DE", "ES", "GB", "US"}
+
+var enumRegex = regexp.MustCompile(`(?i)^\s*enum\s*\((.*)\)\s*$`)
+
+// value returns a fake value of the field, nil for the types not generated.
+func (f *Factory) value(field *schema.Field) interface{} {
+	var typ = field.FieldType
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		// within the last year, on whole hours from today so the values of a seed are reproducible during the day
+		return time.Now().Truncate(24 * time.Hour).Add(-time.Duration(f.intn(365*24)) * time.Hour)
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return f.text(field)
+	case reflect.Bool:
+		return f.intn(2) == 1
+	case reflect.Int8, reflect.Uint8:
+		return f.intn(100) + 1
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if field.Unique || field.PrimaryKey {
+			return int(f.next())
+		}
+		return f.intn(1000) + 1
+	case reflect.Float32, reflect.Float64:
+		return float64(f.intn(100000)) / 100
+	}
+	return nil
+}
+
+// text returns a fake string of the field, at most as long as its column.
+func (f *Factory) text(field *schema.Field) string {
+	if match := enumRegex.FindStringSubmatch(field.TagSettings["TYPE"]); match != nil {
+		var options []string
+		for _, item := range strings.Split(match[1], ",") {
+			if item = strings.Trim(strings.TrimSpace(item), `'"`); item != "" {
+				options = append(options, item)
+			}
+		}
+		if len(options) > 0 {
+			return f.pick(options)
+		}
+	}
+	var seq = f.next()
+	var value string
+	var name = strings.ToLower(field.DBName)
+	for _, item := range words {
+		if name == item.word || strings.HasSuffix(name, "_"+item.word) || strings.HasPrefix(name, item.word+"_") {
+			value = item.value(f, seq)
+			break
+		}
+	}
+	if value == "" {
+		value = f.letters(8 + f.intn(8))
+		if field.Unique || field.PrimaryKey {
+			value = fmt.Sprintf("%s%d", value, seq)
+		}
+	}
+	if field.Size > 0 && len(value) > field.Size {
+		var suffix = fmt.Sprint(seq)
+		if (field.Unique || field.PrimaryKey) && len(suffix) < field.Size {
+			value = value[:field.Size-len(suffix)] + suffix
+		} else {
+			value = value[:field.Size]
+		}
+	}
+	return value
+}
+
+func (f *Factory) intn(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Intn(n)
+}
+
+func (f *Factory) next() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	return f.seq
+}
+
+func (f *Factory) pick(items []string) string {
+	return items[f.intn(len(items))]
+}
+
+func (f *Factory) letters(n int) string {
+	var b = make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + f.intn(26))
+	}
+	return string(b)
+}
+
+func (f *Factory) uuid() string {
+	var b = make([]byte, 16)
+	for i := range b {
+		b[i] = byte(f.intn(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,126 @@
+package factory
+
+import (
+	"net/mail"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+type customer struct {
+	ID    uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	Name  string `gorm:"column:name;size:255"`
+	Email string `gorm:"column:email;size:255;unique"`
+}
+
+type order struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+	Number     string    `gorm:"column:number;size:6;unique"`
+	Status     string    `gorm:"column:status;type:enum('draft','sent','paid')"`
+	Note       string    `gorm:"column:note;size:4"`
+	Total      float64   `gorm:"column:total"`
+	Paid       bool      `gorm:"column:paid;default:false"`
+	Date       time.Time `gorm:"column:date"`
+	CustomerID uint64    `gorm:"column:customer_id" json:"customer"`
+	Customer   *customer `gorm:"foreignKey:CustomerID"`
+	ParentID   *uint64   `gorm:"column:parent_id"`
+	Parent     *order    `gorm:"foreignKey:ParentID"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
+
+func TestBuild(t *testing.T) {
+	var f = New(nil).Seed(1)
+	row, err := f.Build(&customer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c = row.(*customer)
+	if _, err := mail.ParseAddress(c.Email); err != nil {
+		t.Errorf("Email = %s is not valid", c.Email)
+	}
+	if len(strings.Fields(c.Name)) != 2 {
+		t.Errorf("Name = %s, want a first and last name", c.Name)
+	}
+	if c.ID != 0 {
+		t.Errorf("ID = %d, want the key generated by the database", c.ID)
+	}
+
+	row, err = f.Build(order{}, Values{"customer": 7, "total": 12.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o = row.(*order)
+	if len(o.Number) > 6 || len(o.Note) > 4 || o.Number == "" {
+		t.Errorf("Number = %s, Note = %s, want at most 6 and 4 characters", o.Number, o.Note)
+	}
+	if o.Status != "draft" && o.Status != "sent" && o.Status != "paid" {
+		t.Errorf("Status = %s, want a value of the enum", o.Status)
+	}
+	if o.CustomerID != 7 || o.Total != 12.5 {
+		t.Errorf("CustomerID = %d, Total = %v, want the values given", o.CustomerID, o.Total)
+	}
+	if o.Date.IsZero() || !o.CreatedAt.IsZero() || o.Paid {
+		t.Errorf("Date = %v, CreatedAt = %v, Paid = %v, want a date only", o.Date, o.CreatedAt, o.Paid)
+	}
+
+	if _, err := f.Build(order{}, Values{"unknown": 1}); err == nil {
+		t.Errorf("Build() with an unknown field does not fail")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	a, _ := New(nil).Seed(42).Build(&customer{})
+	b, _ := New(nil).Seed(42).Build(&customer{})
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Build() = %+v and %+v with the same seed", a, b)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	var f = New(nil).Seed(1)
+	var seen = map[string]bool{}
+	for i := 0; i < 50; i++ {
+		row, err := f.Build(&order{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var number = row.(*order).Number
+		if seen[number] {
+			t.Fatalf("Number = %s built twice", number)
+		}
+		seen[number] = true
+	}
+}
+
+func TestMissingParents(t *testing.T) {
+	s, err := schema.Parse(&order{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names = func(o order) []string {
+		var result []string
+		for _, relation := range missingParents(s, reflect.ValueOf(&o).Elem()) {
+			result = append(result, relation.Name)
+		}
+		return result
+	}
+	if got := names(order{}); !reflect.DeepEqual(got, []string{"Customer"}) {
+		t.Errorf("missingParents() = %v, want the customer only", got)
+	}
+	if got := names(order{CustomerID: 3}); len(got) != 0 {
+		t.Errorf("missingParents() = %v, want none", got)
+	}
+	if !foreignKeys(s)[s.LookUpField("customer_id")] {
+		t.Errorf("foreignKeys() does not hold customer_id")
+	}
+}
+
+func TestCreateWithoutDatabase(t *testing.T) {
+	if _, err := New(nil).Create(&customer{}); err != ErrNoDatabase {
+		t.Errorf("Create() = %v, want ErrNoDatabase", err)
+	}
+}
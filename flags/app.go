@@ -0,0 +1,79 @@
+package flags
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// PREFIX specifies the prefix for flag routes.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers and protects the flag model and gates the rest resources behind their flags.
+// Flags are managed through the rest endpoints of the feature_flag resource, and reloaded once their
+// changes are committed so the change is applied and streamed immediately.
+func (a App) Register() error {
+	db.UseModel(Flag{})
+	rest.SetPermission(&rest.AppPermission{
+		App:         "FLAG",
+		Name:        "Feature flags",
+		Description: "feature flags targeting users and tenants",
+		Objects:     []interface{}{Flag{}},
+	})
+	rest.FlagResolver = restFlag
+	return toolbox.OnCommit(evo.GetDBO(), "flags:reload", Flag{}, func(tx *gorm.DB) {
+		if err := Reload(); err != nil {
+			logger.Error("unable to reload feature flags", "error", err.Error())
+		}
+	})
+}
+
+// Router sets up the evaluation endpoints, both require an authenticated user.
+// GET /flags returns the state of every flag for the user of the request.
+// GET /flags/stream streams the changes of the flags for the user of the request as server-sent events.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/flags", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			request.Error(rest.ErrorUnauthorized, http.StatusUnauthorized)
+			return nil
+		}
+		return Evaluate(RequestSubject(request))
+	})
+	evo.Get(PREFIX+"/flags/stream", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			request.Error(rest.ErrorUnauthorized, http.StatusUnauthorized)
+			return nil
+		}
+		Stream(request)
+		return nil
+	})
+	return nil
+}
+
+// WhenReady loads the flags and reloads them every RefreshInterval.
+func (a App) WhenReady() error {
+	if err := Reload(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(RefreshInterval) {
+			if err := Reload(); err != nil {
+				logger.Error("unable to reload feature flags", "error", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (a App) Name() string {
+	return "flags"
+}
@@ -0,0 +1,190 @@
+// Package flags evaluates feature flags stored in the feature_flag table.
+//
+// A flag is on for a subject when it is enabled and the subject is listed in its users or tenants,
+// or falls in its rollout percentage. Subjects are bucketed by hashing the flag key and the user,
+// so a user keeps the same result while the percentage does not change:
+//
+//	if flags.Enabled(flags.FromRequest(request), "new_filterview") {
+//		...
+//	}
+//
+// Rest resources implementing RESTFlag() string, and endpoints with a Flag, are served only to the subjects
+// their flag is on for; the others get a 404.
+package flags
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// RefreshInterval specifies how often the flags are reloaded to pick up changes made by other instances.
+var RefreshInterval = 30 * time.Second
+
+// Flag represents a feature flag.
+// - Enabled: turns the flag off for everyone when false.
+// - Percentage: share of the users, from 0 to 100, the flag is on for.
+// - Users, Tenants: space separated user uuids and tenants the flag is always on for.
+type Flag struct {
+	Key         string    `gorm:"column:key;size:64;primaryKey" json:"key"`
+	Description string    `gorm:"column:description;size:255" json:"description"`
+	Enabled     bool      `gorm:"column:enabled" json:"enabled"`
+	Percentage  int       `gorm:"column:percentage;default:100" json:"percentage"`
+	Users       string    `gorm:"column:users;type:text" json:"users"`
+	Tenants     string    `gorm:"column:tenants;type:text" json:"tenants"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	rest.API
+}
+
+// TableName returns the name of the table for the Flag struct.
+func (Flag) TableName() string {
+	return "feature_flag"
+}
+
+// Subject is the user and tenant a flag is evaluated for.
+type Subject struct {
+	User   string `json:"user"`
+	Tenant string `json:"tenant"`
+}
+
+// Evaluate reports whether the flag is on for the subject.
+func (f *Flag) Evaluate(s Subject) bool {
+	if !f.Enabled {
+		return false
+	}
+	if s.User != "" && contains(f.Users, s.User) {
+		return true
+	}
+	if s.Tenant != "" && contains(f.Tenants, s.Tenant) {
+		return true
+	}
+	if f.Percentage >= 100 {
+		return true
+	}
+	if f.Percentage <= 0 || s.User == "" {
+		return false
+	}
+	return bucket(f.Key, s.User) < f.Percentage
+}
+
+func contains(list string, item string) bool {
+	for _, v := range strings.Fields(list) {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the stable bucket, from 0 to 99, of the user for the flag.
+func bucket(key string, user string) int {
+	var sum = sha1.Sum([]byte(key + ":" + user))
+	return int(binary.BigEndian.Uint32(sum[:4]) % 100)
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx evaluating flags for the subject.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// SubjectFrom returns the subject of ctx.
+func SubjectFrom(ctx context.Context) Subject {
+	if s, ok := ctx.Value(subjectKey{}).(Subject); ok {
+		return s
+	}
+	return Subject{}
+}
+
+// FromRequest returns a context evaluating flags for the user and tenant of the request.
+func FromRequest(request *evo.Request) context.Context {
+	return WithSubject(context.Background(), RequestSubject(request))
+}
+
+// RequestSubject returns the user and tenant of the request.
+func RequestSubject(request *evo.Request) Subject {
+	var s = Subject{Tenant: rest.TenantResolver(request)}
+	if user := request.User(); user != nil && !user.Anonymous() {
+		s.User = user.UUID()
+	}
+	return s
+}
+
+// ContextSubject returns the user and tenant of a rest context.
+func ContextSubject(c *rest.Context) Subject {
+	var s = Subject{Tenant: c.Tenant()}
+	if user := c.User(); user != nil && !user.Anonymous() {
+		s.User = user.UUID()
+	}
+	return s
+}
+
+// restFlag reports whether the flag gating a rest resource or endpoint is on for the caller, see rest.FlagResolver.
+func restFlag(c *rest.Context, key string) bool {
+	return Enabled(WithSubject(context.Background(), ContextSubject(c)), key)
+}
+
+// Enabled reports whether the flag is on for the subject of ctx. Unknown flags are off.
+func Enabled(ctx context.Context, key string) bool {
+	mu.RLock()
+	f, ok := flags[key]
+	mu.RUnlock()
+	return ok && f.Evaluate(SubjectFrom(ctx))
+}
+
+// Evaluate returns the state of every flag for the subject.
+func Evaluate(s Subject) map[string]bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	var result = make(map[string]bool, len(flags))
+	for key, f := range flags {
+		result[key] = f.Evaluate(s)
+	}
+	return result
+}
+
+var flags = map[string]*Flag{}
+var mu sync.RWMutex
+var reloadMu sync.Mutex
+
+// Reload reads the flags from the database and notifies the streams of the changed flags.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	var items []Flag
+	if err := db.Find(&items).Error; err != nil {
+		return err
+	}
+	var loaded = make(map[string]*Flag, len(items))
+	for idx := range items {
+		loaded[items[idx].Key] = &items[idx]
+	}
+	mu.Lock()
+	var previous = flags
+	flags = loaded
+	mu.Unlock()
+
+	var changed []string
+	for key, f := range loaded {
+		if old, ok := previous[key]; !ok || !old.UpdatedAt.Equal(f.UpdatedAt) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := loaded[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	if len(changed) > 0 {
+		broadcast(changed)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package flags
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestEvaluate(t *testing.T) {
+	var tests = []struct {
+		name    string
+		flag    Flag
+		subject Subject
+		want    bool
+	}{
+		{"disabled", Flag{Key: "a", Percentage: 100, Users: "u1"}, Subject{User: "u1"}, false},
+		{"everyone", Flag{Key: "a", Enabled: true, Percentage: 100}, Subject{}, true},
+		{"nobody", Flag{Key: "a", Enabled: true}, Subject{User: "u1"}, false},
+		{"targeted user", Flag{Key: "a", Enabled: true, Users: "u1 u2"}, Subject{User: "u2"}, true},
+		{"other user", Flag{Key: "a", Enabled: true, Users: "u1 u2"}, Subject{User: "u3"}, false},
+		{"targeted tenant", Flag{Key: "a", Enabled: true, Tenants: "acme"}, Subject{User: "u3", Tenant: "acme"}, true},
+		{"anonymous rollout", Flag{Key: "a", Enabled: true, Percentage: 50}, Subject{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.Evaluate(tt.subject); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	var flag = Flag{Key: "new_filterview", Enabled: true, Percentage: 30}
+	var on = 0
+	for i := 0; i < 1000; i++ {
+		var subject = Subject{User: "user-" + string(rune('a'+i%26)) + string(rune('a'+i/26))}
+		var result = flag.Evaluate(subject)
+		if result != flag.Evaluate(subject) {
+			t.Fatalf("evaluation of %s is not stable", subject.User)
+		}
+		if result {
+			on++
+		}
+	}
+	if on < 220 || on > 380 {
+		t.Errorf("flag on for %d of 1000 users, want about 300", on)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	flags = map[string]*Flag{"beta": {Key: "beta", Enabled: true, Users: "u1"}}
+	var ctx = WithSubject(context.Background(), Subject{User: "u1"})
+	if !Enabled(ctx, "beta") {
+		t.Error("Enabled(beta) = false, want true")
+	}
+	if Enabled(context.Background(), "beta") {
+		t.Error("Enabled(beta) without subject = true, want false")
+	}
+	if Enabled(ctx, "missing") {
+		t.Error("Enabled(missing) = true, want false")
+	}
+}
+
+type user struct {
+	evo.DefaultUserInterface
+}
+
+func (user) Anonymous() bool {
+	return false
+}
+
+func (user) UUID() string {
+	return "u1"
+}
+
+func TestFlagsRoute(t *testing.T) {
+	resttest.Setup(t, Flag{})
+	if err := (App{}).Router(); err != nil {
+		t.Fatal(err)
+	}
+	flags = map[string]*Flag{"beta": {Key: "beta", Enabled: true, Users: "u1"}}
+
+	if status, _ := resttest.Request(t, http.MethodGet, "/admin/flags", nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /flags status = %d, want 401", status)
+	}
+	resttest.AsUser(t, user{})
+	status, body := resttest.Request(t, http.MethodGet, "/admin/flags", nil)
+	if status != http.StatusOK || !strings.Contains(string(body), `"beta":true`) {
+		t.Errorf("GET /flags = %d %s", status, body)
+	}
+}
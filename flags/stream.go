@@ -0,0 +1,103 @@
+package flags
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+)
+
+// HeartbeatInterval specifies how often a comment is written to idle streams to keep the connection open.
+var HeartbeatInterval = 15 * time.Second
+
+// Change is the event sent to the streams when a flag is added, changed or removed.
+type Change struct {
+	Key     string `json:"key"`
+	Enabled bool   `json:"enabled"`
+}
+
+var subscribers = map[chan []string]struct{}{}
+var subscribersMu sync.Mutex
+
+// subscribe returns a channel receiving the keys of the changed flags.
+func subscribe() chan []string {
+	var ch = make(chan []string, 16)
+	subscribersMu.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersMu.Unlock()
+	return ch
+}
+
+func unsubscribe(ch chan []string) {
+	subscribersMu.Lock()
+	delete(subscribers, ch)
+	subscribersMu.Unlock()
+}
+
+// broadcast sends the changed keys to every stream. Slow streams miss the change rather than blocking the reload.
+func broadcast(keys []string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- keys:
+		default:
+		}
+	}
+}
+
+// Stream writes the state of every flag for the subject of the request as server-sent events, followed by
+// a `flag` event carrying a Change each time a flag evaluated for the subject changes, until the client disconnects.
+func Stream(request *evo.Request) {
+	var subject = RequestSubject(request)
+	request.SetHeader("Content-Type", "text/event-stream")
+	request.SetHeader("Cache-Control", "no-cache")
+	request.SetHeader("X-Accel-Buffering", "no")
+	request.Context.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var ch = subscribe()
+		defer unsubscribe(ch)
+		var state = Evaluate(subject)
+		if writeEvent(w, "flags", state) != nil {
+			return
+		}
+		var heartbeat = time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case keys := <-ch:
+				var current = Evaluate(subject)
+				for _, key := range keys {
+					if current[key] == state[key] {
+						continue
+					}
+					if writeEvent(w, "flag", Change{Key: key, Enabled: current[key]}) != nil {
+						return
+					}
+				}
+				state = current
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if w.Flush() != nil {
+					// client went away
+					return
+				}
+			}
+		}
+	})
+}
+
+func writeEvent(w *bufio.Writer, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,397 @@
+// Package gen generates the accessors the rest handlers use instead of reflect for the models of a package, see
+// rest.Accessors for the paths using them; request bodies are still decoded by encoding/json. It backs the `toolbox gen` command, usually run by go:generate next to the models:
+//
+//	//go:generate go run github.com/iesitalia/toolbox/cmd/toolbox gen -type Order,Customer
+//
+// Without types, the structs embedding rest.API are generated. The generated code refers to the fields of the
+// models, so a field renamed or removed without generating again fails to compile, and the columns it expects
+// are checked against the schema when the resource is attached.
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// Output is the name of the file written in the package directory by Write.
+const Output = "rest_accessors_gen.go"
+
+// restPath is the import path of the rest package.
+const restPath = "github.com/iesitalia/toolbox/rest"
+
+// ErrNoModels is returned when the package has none of the requested models.
+var ErrNoModels = errors.New("no model to generate")
+
+// field is a field of a model the accessors read or write.
+// - column: the database column, empty for the fields which are not columns, e.g. relations.
+// - kind: the basic type parsed by the setter through strconv, empty for the types cast by generic.
+// - depth: the number of embedded structs the field is promoted through, 0 for the fields of the model.
+type field struct {
+	name   string
+	column string
+	json   string
+	kind   string
+	depth  int
+}
+
+// model is a struct of the package to generate accessors for.
+type model struct {
+	name   string
+	fields []field
+}
+
+// source is the parsed package.
+type source struct {
+	name    string
+	structs map[string]*ast.StructType
+	imports map[*ast.StructType]map[string]string
+	order   []string
+}
+
+// Write generates the accessors of the models of the package in dir and writes them to Output, see Generate.
+func Write(dir string, types []string) error {
+	code, err := Generate(dir, types)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, Output), code, 0644)
+}
+
+// Generate returns the source of the accessors of the given models of the package in dir, or of the structs
+// embedding rest.API when no type is given.
+func Generate(dir string, types []string) ([]byte, error) {
+	src, err := parse(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		types = src.apiModels()
+	}
+	var models []model
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		if _, ok := src.structs[name]; !ok {
+			return nil, fmt.Errorf("gen: type %s is not a struct of package %s", name, src.name)
+		}
+		models = append(models, model{name: name, fields: visible(src.fields(src.structs[name], map[string]bool{name: true}, 0))})
+	}
+	if len(models) == 0 {
+		return nil, ErrNoModels
+	}
+	return render(src.name, models)
+}
+
+// parse parses the non-test, non-generated files of the package in dir.
+func parse(dir string) (*source, error) {
+	var fset = token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != Output
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("gen: %d packages in %s, want 1", len(pkgs), dir)
+	}
+	var src = &source{structs: map[string]*ast.StructType{}, imports: map[*ast.StructType]map[string]string{}}
+	for name, pkg := range pkgs {
+		src.name = name
+		var files []string
+		for path := range pkg.Files {
+			files = append(files, path)
+		}
+		sort.Strings(files)
+		for _, path := range files {
+			var file = pkg.Files[path]
+			var imports = map[string]string{}
+			for _, spec := range file.Imports {
+				var importPath, _ = strconv.Unquote(spec.Path.Value)
+				var alias = importPath[strings.LastIndex(importPath, "/")+1:]
+				if spec.Name != nil {
+					alias = spec.Name.Name
+				}
+				imports[alias] = importPath
+			}
+			for _, decl := range file.Decls {
+				var gen, ok = decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					var typ = spec.(*ast.TypeSpec)
+					if st, ok := typ.Type.(*ast.StructType); ok && typ.TypeParams == nil {
+						src.structs[typ.Name.Name] = st
+						src.imports[st] = imports
+						src.order = append(src.order, typ.Name.Name)
+					}
+				}
+			}
+		}
+	}
+	return src, nil
+}
+
+// apiModels returns the structs embedding rest.API.
+func (src *source) apiModels() []string {
+	var names []string
+	for _, name := range src.order {
+		var st = src.structs[name]
+		for _, item := range st.Fields.List {
+			if sel, ok := item.Type.(*ast.SelectorExpr); ok && len(item.Names) == 0 && sel.Sel.Name == "API" {
+				if pkg, ok := sel.X.(*ast.Ident); ok && src.imports[st][pkg.Name] == restPath {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+	}
+	return names
+}
+
+// fields returns the fields of the struct at the depth, along with those promoted from the structs of the package
+// it embeds. The fields embedded from other packages are left to reflect.
+func (src *source) fields(st *ast.StructType, seen map[string]bool, depth int) []field {
+	var fields []field
+	for _, item := range st.Fields.List {
+		var tag = reflect.StructTag("")
+		if item.Tag != nil {
+			var value, _ = strconv.Unquote(item.Tag.Value)
+			tag = reflect.StructTag(value)
+		}
+		var gorm = gormSettings(tag.Get("gorm"))
+		if _, ok := gorm["-"]; ok {
+			continue
+		}
+		if len(item.Names) == 0 {
+			if ident, ok := item.Type.(*ast.Ident); ok && src.structs[ident.Name] != nil && !seen[ident.Name] {
+				seen[ident.Name] = true
+				fields = append(fields, src.fields(src.structs[ident.Name], seen, depth+1)...)
+			}
+			continue
+		}
+		if _, ok := gorm["EMBEDDED"]; ok {
+			continue
+		}
+		for _, ident := range item.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			var f = field{name: ident.Name, depth: depth}
+			if json := strings.Split(tag.Get("json"), ",")[0]; json != "-" {
+				f.json = json
+			}
+			if src.isColumn(item.Type, gorm) {
+				f.column = gorm["COLUMN"]
+				if f.column == "" {
+					f.column = strcase.ToSnake(ident.Name)
+				}
+				if basic, ok := item.Type.(*ast.Ident); ok && basicKinds[basic.Name] {
+					f.kind = basic.Name
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// visible returns the fields reachable by their name as Go resolves selectors: a field hides the fields of the
+// same name promoted through more embedded structs, and the fields of the same name at the same depth hide each
+// other.
+func visible(fields []field) []field {
+	var depths = map[string][]int{}
+	for _, f := range fields {
+		depths[f.name] = append(depths[f.name], f.depth)
+	}
+	var result []field
+	for _, f := range fields {
+		var shallower, same int
+		for _, depth := range depths[f.name] {
+			if depth < f.depth {
+				shallower++
+			} else if depth == f.depth {
+				same++
+			}
+		}
+		if shallower == 0 && same == 1 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// isColumn reports whether a field of the type is a column rather than a relation.
+func (src *source) isColumn(expr ast.Expr, gorm map[string]string) bool {
+	if _, ok := gorm["SERIALIZER"]; ok {
+		return true
+	}
+	if _, ok := gorm["TYPE"]; ok {
+		return true
+	}
+	switch typ := expr.(type) {
+	case *ast.Ident:
+		return src.structs[typ.Name] == nil
+	case *ast.StarExpr:
+		return src.isColumn(typ.X, gorm)
+	case *ast.ArrayType:
+		var elem, ok = typ.Elt.(*ast.Ident)
+		return ok && elem.Name == "byte"
+	case *ast.MapType, *ast.InterfaceType, *ast.FuncType, *ast.ChanType, *ast.StructType:
+		return false
+	}
+	return true
+}
+
+// gormSettings parses the gorm tag like schema.ParseTagSetting, keys upper-cased.
+func gormSettings(tag string) map[string]string {
+	var settings = map[string]string{}
+	for _, item := range strings.Split(tag, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		var key, value, _ = strings.Cut(item, ":")
+		if key == "-" {
+			settings["-"] = ""
+			continue
+		}
+		settings[strings.ToUpper(strings.TrimSpace(key))] = value
+	}
+	return settings
+}
+
+// basicKinds are the types the setters parse through strconv.
+var basicKinds = map[string]bool{
+	"string": true, "bool": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+}
+
+// render returns the formatted source of the accessors of the models.
+func render(pkg string, models []model) ([]byte, error) {
+	var body bytes.Buffer
+	var imports = map[string]bool{restPath: true}
+	for _, m := range models {
+		renderModel(&body, m, imports)
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by toolbox gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var std, paths []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			paths = append(paths, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(paths)
+	for _, path := range std {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	if len(std) > 0 {
+		out.WriteString("\n")
+	}
+	for _, path := range paths {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// renderModel writes the accessors of the model.
+func renderModel(w *bytes.Buffer, m model, imports map[string]bool) {
+	fmt.Fprintf(w, "\nvar _ rest.Accessors = (*%s)(nil)\n", m.name)
+	fmt.Fprintf(w, "\n// RESTNew returns a new %s.\nfunc (*%s) RESTNew() interface{} {\n\treturn &%s{}\n}\n", m.name, m.name, m.name)
+	fmt.Fprintf(w, "\n// RESTNewSlice returns a pointer to a new empty slice of %s.\nfunc (*%s) RESTNewSlice() interface{} {\n\treturn &[]%s{}\n}\n", m.name, m.name, m.name)
+
+	fmt.Fprintf(w, "\n// RESTGet returns the value of the field of the Go name.\nfunc (m *%s) RESTGet(field string) (interface{}, bool) {\n\tswitch field {\n", m.name)
+	for _, f := range m.fields {
+		fmt.Fprintf(w, "\tcase %q:\n\t\treturn m.%s, true\n", f.name, f.name)
+	}
+	w.WriteString("\t}\n\treturn nil, false\n}\n")
+
+	fmt.Fprintf(w, "\n// RESTSet parses the value into the field of the Go name.\nfunc (m *%s) RESTSet(field string, value string) (bool, error) {\n\tswitch field {\n", m.name)
+	for _, f := range m.fields {
+		if f.column == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\tcase %q:\n", f.name)
+		switch {
+		case f.kind == "string":
+			fmt.Fprintf(w, "\t\tm.%s = value\n\t\treturn true, nil\n", f.name)
+		case f.kind == "bool":
+			imports["strconv"] = true
+			fmt.Fprintf(w, "\t\tv, err := strconv.ParseBool(value)\n\t\tm.%s = v\n\t\treturn true, err\n", f.name)
+		case strings.HasPrefix(f.kind, "float"):
+			imports["strconv"] = true
+			fmt.Fprintf(w, "\t\tv, err := strconv.ParseFloat(value, %d)\n\t\tm.%s = %s\n\t\treturn true, err\n", bits(f.kind), f.name, convert(f.kind))
+		case strings.HasPrefix(f.kind, "int"):
+			imports["strconv"] = true
+			fmt.Fprintf(w, "\t\tv, err := strconv.ParseInt(value, 10, %d)\n\t\tm.%s = %s\n\t\treturn true, err\n", bits(f.kind), f.name, convert(f.kind))
+		case strings.HasPrefix(f.kind, "uint"):
+			imports["strconv"] = true
+			fmt.Fprintf(w, "\t\tv, err := strconv.ParseUint(value, 10, %d)\n\t\tm.%s = %s\n\t\treturn true, err\n", bits(f.kind), f.name, convert(f.kind))
+		default:
+			imports["github.com/getevo/evo/v2/lib/generic"] = true
+			fmt.Fprintf(w, "\t\treturn true, generic.Parse(value).Cast(&m.%s)\n", f.name)
+		}
+	}
+	w.WriteString("\t}\n\treturn false, nil\n}\n")
+
+	fmt.Fprintf(w, "\n// RESTFilterField returns the column and the Go name of the field named by column, JSON or Go name.\nfunc (*%s) RESTFilterField(name string) (string, string, bool) {\n\tswitch name {\n", m.name)
+	var used = map[string]bool{}
+	for _, f := range m.fields {
+		if f.column == "" {
+			continue
+		}
+		var names []string
+		for _, name := range []string{f.column, f.name, f.json, strings.ToLower(f.name)} {
+			if name != "" && !used[name] {
+				used[name] = true
+				names = append(names, strconv.Quote(name))
+			}
+		}
+		if len(names) > 0 {
+			fmt.Fprintf(w, "\tcase %s:\n\t\treturn %q, %q, true\n", strings.Join(names, ", "), f.column, f.name)
+		}
+	}
+	w.WriteString("\t}\n\treturn \"\", \"\", false\n}\n")
+
+	fmt.Fprintf(w, "\n// RESTFields returns the Go names of the columns.\nfunc (*%s) RESTFields() []string {\n\treturn []string{", m.name)
+	var columns []string
+	for _, f := range m.fields {
+		if f.column != "" {
+			columns = append(columns, strconv.Quote(f.name))
+		}
+	}
+	w.WriteString(strings.Join(columns, ", "))
+	w.WriteString("}\n}\n")
+}
+
+// convert returns the conversion of the value parsed by strconv to the basic type.
+func convert(kind string) string {
+	if kind == "float64" || kind == "int64" || kind == "uint64" {
+		return "v"
+	}
+	return kind + "(v)"
+}
+
+// bits returns the bit size of the basic type, 0 for int and uint.
+func bits(kind string) int {
+	var n, _ = strconv.Atoi(strings.TrimLeft(kind, "intufloa"))
+	return n
+}
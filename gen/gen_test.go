@@ -0,0 +1,84 @@
+package gen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	var dir = filepath.Join("testdata", "models")
+	code, err := Generate(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(code) != string(want) {
+		t.Errorf("Generate() does not match %s, run toolbox gen in %s:\n%s", Output, dir, code)
+	}
+}
+
+func TestGenerateTypes(t *testing.T) {
+	var dir = filepath.Join("testdata", "models")
+	if _, err := Generate(dir, []string{"Draft"}); err != nil {
+		t.Errorf("Generate(Draft) error = %v", err)
+	}
+	if _, err := Generate(dir, []string{"Status"}); err == nil {
+		t.Error("Generate(Status) accepted a type which is not a struct")
+	}
+	if _, err := Generate(t.TempDir(), nil); err == nil {
+		t.Error("Generate() accepted a directory without package")
+	}
+	var empty = t.TempDir()
+	if err := os.WriteFile(filepath.Join(empty, "doc.go"), []byte("package empty\n\ntype Row struct{ ID int }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(empty, nil); !errors.Is(err, ErrNoModels) {
+		t.Errorf("Generate() error = %v, want ErrNoModels", err)
+	}
+}
+
+func TestVisible(t *testing.T) {
+	var fields = []field{
+		{name: "ID", column: "id"},
+		{name: "CreatedBy", column: "created_by", depth: 1},
+		{name: "Number", column: "audit_number", depth: 1},
+		{name: "Source", column: "audit_source", depth: 1},
+		{name: "Source", column: "origin_source", depth: 1},
+		{name: "Number", column: "number"},
+		{name: "Note", column: "note", depth: 2},
+	}
+	var want = []field{
+		{name: "ID", column: "id"},
+		{name: "CreatedBy", column: "created_by", depth: 1},
+		{name: "Number", column: "number"},
+		{name: "Note", column: "note", depth: 2},
+	}
+	if got := visible(fields); !reflect.DeepEqual(got, want) {
+		t.Errorf("visible() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGormSettings(t *testing.T) {
+	var tests = []struct {
+		tag, key, value string
+		ok              bool
+	}{
+		{"column:line_count;not null", "COLUMN", "line_count", true},
+		{"foreignKey:CustomerID", "FOREIGNKEY", "CustomerID", true},
+		{"-", "-", "", true},
+		{"serializer:json", "SERIALIZER", "json", true},
+		{"primaryKey", "COLUMN", "", false},
+	}
+	for _, test := range tests {
+		value, ok := gormSettings(test.tag)[test.key]
+		if value != test.value || ok != test.ok {
+			t.Errorf("gormSettings(%q)[%s] = %q, %v, want %q, %v", test.tag, test.key, value, ok, test.value, test.ok)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+type Status string
+
+type Audit struct {
+	CreatedBy string `gorm:"column:created_by" json:"created_by"`
+	Note      string `gorm:"-" json:"note"`
+	Number    string `gorm:"column:audit_number" json:"audit_number"`
+	Source    string `gorm:"column:audit_source" json:"audit_source"`
+}
+
+type Origin struct {
+	Source string `gorm:"column:origin_source" json:"origin_source"`
+}
+
+type Customer struct {
+	ID     uint    `gorm:"column:id;primaryKey" json:"id"`
+	Email  string  `gorm:"column:email" json:"email"`
+	Orders []Order `gorm:"foreignKey:CustomerID" json:"orders"`
+	rest.API
+}
+
+type Order struct {
+	evo.Model
+	Audit
+	Origin
+	Number     string     `gorm:"column:number" json:"number"`
+	Total      float64    `json:"total"`
+	Paid       bool       `gorm:"column:paid" json:"-"`
+	Lines      int32      `gorm:"column:line_count" json:"lines"`
+	Status     Status     `gorm:"column:status" json:"status"`
+	ShippedAt  *time.Time `gorm:"column:shipped_at" json:"shipped_at"`
+	CustomerID uint       `gorm:"column:customer_id" json:"customer_id"`
+	Customer   *Customer  `gorm:"foreignKey:CustomerID" json:"customer"`
+	Tags       []string   `gorm:"serializer:json" json:"tags"`
+	secret     string
+	rest.API
+}
+
+type Draft struct {
+	Name string
+}
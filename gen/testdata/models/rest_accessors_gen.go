@@ -0,0 +1,169 @@
+// Code generated by toolbox gen. DO NOT EDIT.
+
+package models
+
+import (
+	"strconv"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+var _ rest.Accessors = (*Customer)(nil)
+
+// RESTNew returns a new Customer.
+func (*Customer) RESTNew() interface{} {
+	return &Customer{}
+}
+
+// RESTNewSlice returns a pointer to a new empty slice of Customer.
+func (*Customer) RESTNewSlice() interface{} {
+	return &[]Customer{}
+}
+
+// RESTGet returns the value of the field of the Go name.
+func (m *Customer) RESTGet(field string) (interface{}, bool) {
+	switch field {
+	case "ID":
+		return m.ID, true
+	case "Email":
+		return m.Email, true
+	case "Orders":
+		return m.Orders, true
+	}
+	return nil, false
+}
+
+// RESTSet parses the value into the field of the Go name.
+func (m *Customer) RESTSet(field string, value string) (bool, error) {
+	switch field {
+	case "ID":
+		v, err := strconv.ParseUint(value, 10, 0)
+		m.ID = uint(v)
+		return true, err
+	case "Email":
+		m.Email = value
+		return true, nil
+	}
+	return false, nil
+}
+
+// RESTFilterField returns the column and the Go name of the field named by column, JSON or Go name.
+func (*Customer) RESTFilterField(name string) (string, string, bool) {
+	switch name {
+	case "id", "ID":
+		return "id", "ID", true
+	case "email", "Email":
+		return "email", "Email", true
+	}
+	return "", "", false
+}
+
+// RESTFields returns the Go names of the columns.
+func (*Customer) RESTFields() []string {
+	return []string{"ID", "Email"}
+}
+
+var _ rest.Accessors = (*Order)(nil)
+
+// RESTNew returns a new Order.
+func (*Order) RESTNew() interface{} {
+	return &Order{}
+}
+
+// RESTNewSlice returns a pointer to a new empty slice of Order.
+func (*Order) RESTNewSlice() interface{} {
+	return &[]Order{}
+}
+
+// RESTGet returns the value of the field of the Go name.
+func (m *Order) RESTGet(field string) (interface{}, bool) {
+	switch field {
+	case "CreatedBy":
+		return m.CreatedBy, true
+	case "Number":
+		return m.Number, true
+	case "Total":
+		return m.Total, true
+	case "Paid":
+		return m.Paid, true
+	case "Lines":
+		return m.Lines, true
+	case "Status":
+		return m.Status, true
+	case "ShippedAt":
+		return m.ShippedAt, true
+	case "CustomerID":
+		return m.CustomerID, true
+	case "Customer":
+		return m.Customer, true
+	case "Tags":
+		return m.Tags, true
+	}
+	return nil, false
+}
+
+// RESTSet parses the value into the field of the Go name.
+func (m *Order) RESTSet(field string, value string) (bool, error) {
+	switch field {
+	case "CreatedBy":
+		m.CreatedBy = value
+		return true, nil
+	case "Number":
+		m.Number = value
+		return true, nil
+	case "Total":
+		v, err := strconv.ParseFloat(value, 64)
+		m.Total = v
+		return true, err
+	case "Paid":
+		v, err := strconv.ParseBool(value)
+		m.Paid = v
+		return true, err
+	case "Lines":
+		v, err := strconv.ParseInt(value, 10, 32)
+		m.Lines = int32(v)
+		return true, err
+	case "Status":
+		return true, generic.Parse(value).Cast(&m.Status)
+	case "ShippedAt":
+		return true, generic.Parse(value).Cast(&m.ShippedAt)
+	case "CustomerID":
+		v, err := strconv.ParseUint(value, 10, 0)
+		m.CustomerID = uint(v)
+		return true, err
+	case "Tags":
+		return true, generic.Parse(value).Cast(&m.Tags)
+	}
+	return false, nil
+}
+
+// RESTFilterField returns the column and the Go name of the field named by column, JSON or Go name.
+func (*Order) RESTFilterField(name string) (string, string, bool) {
+	switch name {
+	case "created_by", "CreatedBy", "createdby":
+		return "created_by", "CreatedBy", true
+	case "number", "Number":
+		return "number", "Number", true
+	case "total", "Total":
+		return "total", "Total", true
+	case "paid", "Paid":
+		return "paid", "Paid", true
+	case "line_count", "Lines", "lines":
+		return "line_count", "Lines", true
+	case "status", "Status":
+		return "status", "Status", true
+	case "shipped_at", "ShippedAt", "shippedat":
+		return "shipped_at", "ShippedAt", true
+	case "customer_id", "CustomerID", "customerid":
+		return "customer_id", "CustomerID", true
+	case "tags", "Tags":
+		return "tags", "Tags", true
+	}
+	return "", "", false
+}
+
+// RESTFields returns the Go names of the columns.
+func (*Order) RESTFields() []string {
+	return []string{"CreatedBy", "Number", "Total", "Paid", "Lines", "Status", "ShippedAt", "CustomerID", "Tags"}
+}
@@ -4,5 +4,44 @@ go 1.21
 
 require (
 	github.com/getevo/evo/v2 v2.0.0-20240519102330-23db9f6908fd
+	github.com/google/uuid v1.4.0
 	github.com/gosimple/unidecode v1.0.1
+	github.com/iancoleman/strcase v0.2.0
+	golang.org/x/crypto v0.16.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+	gorm.io/driver/mysql v1.4.7
+	gorm.io/gorm v1.24.6
+)
+
+require (
+	github.com/alecthomas/repr v0.2.0 // indirect
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de // indirect
+	github.com/awoodbeck/strftime v0.0.0-20180221155908-016cde65fcde // indirect
+	github.com/go-sql-driver/mysql v1.7.0 // indirect
+	github.com/gofiber/fiber/v2 v2.51.0 // indirect
+	github.com/gofiber/utils/v2 v2.0.0-beta.1 // indirect
+	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
+	github.com/golang-sql/sqlexp v0.1.0 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/kelindar/binary v1.0.17 // indirect
+	github.com/klauspost/compress v1.17.3 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/mattn/go-sqlite3 v1.14.15 // indirect
+	github.com/microsoft/go-mssqldb v0.19.0 // indirect
+	github.com/otiai10/copy v1.9.0 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+	github.com/tidwall/gjson v1.7.4 // indirect
+	github.com/tidwall/match v1.0.3 // indirect
+	github.com/tidwall/pretty v1.1.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasthttp v1.50.0 // indirect
+	github.com/valyala/tcplisten v1.0.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	gorm.io/driver/sqlite v1.4.4 // indirect
+	gorm.io/driver/sqlserver v1.4.2 // indirect
 )
@@ -0,0 +1,127 @@
+package hashid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// DefaultAlphabet is the alphabet used by encoders created without one.
+const DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// ErrInvalidHash represents an error indicating that the hash cannot be decoded.
+var ErrInvalidHash = errors.New("invalid hash")
+
+// CheckLength is the number of characters of the keyed check ending every hash.
+const CheckLength = 4
+
+// Encoder converts numeric identifiers to short strings and back.
+// Numbers are first scrambled by a keyed Feistel permutation so that consecutive ids do not
+// produce similar hashes, then written in base len(alphabet) using an alphabet shuffled by the salt,
+// followed by CheckLength characters of a keyed check so that hashes which were not encoded with the salt,
+// e.g. guessed to enumerate the rows, are rejected by Decode rather than decoded to another number.
+// Only the holders of the salt can encode or reverse hashes, so the salt must be kept secret and must not be
+// empty; the hashes themselves should not be considered confidential.
+type Encoder struct {
+	alphabet  []byte
+	key       []byte
+	minLength int
+}
+
+// New creates an encoder for the given salt. Hashes shorter than minLength are left padded.
+func New(salt string, minLength int) *Encoder {
+	var alphabet = []byte(DefaultAlphabet)
+	var key = sha256.Sum256([]byte(salt))
+	// consistent shuffle of the alphabet driven by the salt
+	for i := len(alphabet) - 1; i > 0; i-- {
+		var j = int(key[i%len(key)]+byte(i)) % (i + 1)
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+	return &Encoder{alphabet: alphabet, key: key[:], minLength: minLength}
+}
+
+// Encode returns the hash of the given number, its minimum length not counting the check.
+func (e *Encoder) Encode(n uint64) string {
+	var v = e.permute(n, false)
+	var check = e.check(v)
+	var base = uint64(len(e.alphabet))
+	var result []byte
+	for v > 0 {
+		result = append(result, e.alphabet[v%base])
+		v /= base
+	}
+	for len(result) < e.minLength || len(result) == 0 {
+		result = append(result, e.alphabet[0])
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result) + check
+}
+
+// Decode returns the number of the given hash.
+func (e *Encoder) Decode(hash string) (uint64, error) {
+	if len(hash) <= CheckLength {
+		return 0, ErrInvalidHash
+	}
+	var check = hash[len(hash)-CheckLength:]
+	hash = hash[:len(hash)-CheckLength]
+	var base = uint64(len(e.alphabet))
+	var v uint64
+	for i := 0; i < len(hash); i++ {
+		var idx = strings.IndexByte(string(e.alphabet), hash[i])
+		if idx < 0 {
+			return 0, ErrInvalidHash
+		}
+		var next = v*base + uint64(idx)
+		if v > 0 && next/base != v {
+			return 0, ErrInvalidHash
+		}
+		v = next
+	}
+	if !hmac.Equal([]byte(check), []byte(e.check(v))) {
+		return 0, ErrInvalidHash
+	}
+	return e.permute(v, true), nil
+}
+
+// check returns the keyed check of the permuted number.
+func (e *Encoder) check(v uint64) string {
+	var mac = hmac.New(sha256.New, e.key)
+	var buf [9]byte
+	buf[0] = 0xff
+	binary.BigEndian.PutUint64(buf[1:], v)
+	mac.Write(buf[:])
+	var sum = mac.Sum(nil)
+	var result = make([]byte, CheckLength)
+	for i := range result {
+		result[i] = e.alphabet[int(sum[i])%len(e.alphabet)]
+	}
+	return string(result)
+}
+
+// permute applies a 4 rounds Feistel network on the 64 bits of n, or its inverse.
+func (e *Encoder) permute(n uint64, inverse bool) uint64 {
+	var left, right = uint32(n >> 32), uint32(n)
+	for i := 0; i < 4; i++ {
+		var round = i
+		if inverse {
+			round = 3 - i
+			left, right = right^e.round(round, left), left
+		} else {
+			left, right = right, left^e.round(round, right)
+		}
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+func (e *Encoder) round(i int, v uint32) uint32 {
+	var mac = hmac.New(sha256.New, e.key)
+	var buf [5]byte
+	buf[0] = byte(i)
+	binary.BigEndian.PutUint32(buf[1:], v)
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package hashid
+
+import "testing"
+
+func TestEncodeDecode(t *testing.T) {
+	var encoder = New("salt", 8)
+	var seen = map[string]bool{}
+	for _, n := range []uint64{0, 1, 2, 3, 1000, 1 << 32, 1<<64 - 1} {
+		var hash = encoder.Encode(n)
+		if len(hash) < 8 {
+			t.Errorf("Encode(%d) = %q, shorter than min length", n, hash)
+		}
+		if seen[hash] {
+			t.Errorf("Encode(%d) = %q, duplicate hash", n, hash)
+		}
+		seen[hash] = true
+		got, err := encoder.Decode(hash)
+		if err != nil || got != n {
+			t.Errorf("Decode(%q) = %d, %v, want %d", hash, got, err, n)
+		}
+	}
+}
+
+func TestSalt(t *testing.T) {
+	if New("a", 0).Encode(1) == New("b", 0).Encode(1) {
+		t.Error("different salts produce the same hash")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var encoder = New("salt", 0)
+	var valid = encoder.Encode(42)
+	var tampered = []byte(valid)
+	tampered[0] = valid[1]
+	if tampered[0] == valid[0] {
+		tampered[0] = valid[2]
+	}
+	for _, hash := range []string{"", "ab-c", "abcd", "zzzzzzzzzzzzzzzzzzzzzzzzz", valid[:len(valid)-1] + "!", string(tampered), New("other", 0).Encode(42)} {
+		if _, err := encoder.Decode(hash); err == nil {
+			t.Errorf("Decode(%q) succeeded", hash)
+		}
+	}
+}
+
+func TestEnumeration(t *testing.T) {
+	var encoder = New("salt", 0)
+	var accepted int
+	for _, a := range DefaultAlphabet {
+		for _, b := range DefaultAlphabet {
+			if _, err := encoder.Decode("abcdefg" + string(a) + string(b) + "xy"); err == nil {
+				accepted++
+			}
+		}
+	}
+	if accepted > 1 {
+		t.Errorf("Decode() accepted %d guessed hashes", accepted)
+	}
+}
@@ -0,0 +1,36 @@
+package health
+
+import (
+	"github.com/getevo/evo/v2"
+)
+
+// LivenessPath specifies the route of the liveness probe.
+var LivenessPath = "/healthz"
+
+// App serves the liveness probe of the service, GET /healthz answering 200 while the process serves requests.
+// The readiness probe is served by the circuit app, see circuit.Health.
+type App struct {
+}
+
+// Register adds the data migrations and cache checkers. The database is checked by the circuit app.
+func (a App) Register() error {
+	AddChecker("migrations", Migrations())
+	AddChecker("cache", Cache())
+	return nil
+}
+
+// Router sets up the liveness probe route.
+func (a App) Router() error {
+	evo.Get(LivenessPath, func(request *evo.Request) interface{} {
+		return Report{Status: StatusOK}
+	})
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "health"
+}
@@ -0,0 +1,95 @@
+// Package health serves the liveness probe of the service and provides the readiness checkers, see App. The
+// checkers added by AddChecker are critical checks of the /readyz probe of the circuit app: the app adds the
+// data migrations and cache checkers, and other packages add their own, e.g. the backlog of their queues:
+//
+//	health.AddChecker("mail_queue", health.Backlog(mail.Backlog, 1000))
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/memo"
+	"github.com/iesitalia/toolbox/circuit"
+	"github.com/iesitalia/toolbox/migration"
+)
+
+// StatusOK is the status of the liveness probe.
+const StatusOK = "ok"
+
+// Checker checks a dependency of the service, returning an error when the service cannot serve requests.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is a function implementing Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls the function.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Report is the body of the liveness probe.
+type Report struct {
+	Status string `json:"status"`
+}
+
+// AddChecker adds the checker as a critical readiness check, replacing the check with the same name, see
+// circuit.AddCheck.
+func AddChecker(name string, checker Checker) {
+	circuit.AddCheck(name, true, checker.Check)
+}
+
+// RemoveChecker removes a checker from the readiness checks.
+func RemoveChecker(name string) {
+	circuit.RemoveCheck(name)
+}
+
+// Migrations returns the checker failing while registered data migrations are pending.
+func Migrations() Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		pending, err := migration.Pending()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d data migrations pending", len(pending))
+		}
+		return nil
+	})
+}
+
+// Cache returns the checker writing and reading back a key of the default cache driver.
+// It passes when no cache driver is set.
+func Cache() Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if memo.DefaultDriver() == nil {
+			return nil
+		}
+		var value = []byte(time.Now().String())
+		if err := memo.SetRaw("health:probe", value, 10*time.Second); err != nil {
+			return err
+		}
+		if got, ok := memo.GetRaw("health:probe"); !ok || string(got) != string(value) {
+			return errors.New("cache probe not found")
+		}
+		return nil
+	})
+}
+
+// Backlog returns the checker failing when the number of queued items returned by count exceeds max.
+func Backlog(count func(ctx context.Context) (int64, error), max int64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		n, err := count(ctx)
+		if err != nil {
+			return err
+		}
+		if n > max {
+			return fmt.Errorf("backlog of %d items exceeds %d", n, max)
+		}
+		return nil
+	})
+}
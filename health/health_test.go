@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iesitalia/toolbox/circuit"
+)
+
+func TestAddChecker(t *testing.T) {
+	AddChecker("queue", CheckerFunc(func(ctx context.Context) error { return errors.New("backlog") }))
+	defer RemoveChecker("queue")
+	var report = circuit.Health(context.Background())
+	if report.Status != circuit.NotReady {
+		t.Errorf("Health() = %+v, want a critical failing check", report)
+	}
+	for _, result := range report.Checks {
+		if result.Name == "queue" && (!result.Critical || result.Error != "backlog") {
+			t.Errorf("check = %+v, want critical with error backlog", result)
+		}
+	}
+}
+
+func TestBacklog(t *testing.T) {
+	var tests = []struct {
+		name  string
+		count int64
+		err   error
+		fail  bool
+	}{
+		{"below", 10, nil, false},
+		{"at max", 100, nil, false},
+		{"above", 101, nil, true},
+		{"error", 0, errors.New("no table"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checker = Backlog(func(ctx context.Context) (int64, error) { return tt.count, tt.err }, 100)
+			if err := checker.Check(context.Background()); (err != nil) != tt.fail {
+				t.Errorf("Check() = %v, want fail %v", err, tt.fail)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package httpclient provides a configured HTTP client with retries, trace propagation,
+// credentials injection and JSON decoding of responses.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iesitalia/toolbox/JSON"
+	"github.com/iesitalia/toolbox/retry"
+)
+
+// DefaultTimeout is the timeout of clients created without WithTimeout.
+var DefaultTimeout = 30 * time.Second
+
+// StatusError represents a response with a non 2xx status code.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, strings.TrimSpace(string(e.Body)))
+}
+
+// TokenSource returns the bearer token injected in requests.
+type TokenSource func(ctx context.Context) (string, error)
+
+// Client is an HTTP client built with New.
+type Client struct {
+	client  *http.Client
+	baseURL string
+	policy  retry.Policy
+	headers http.Header
+	token   TokenSource
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithTimeout sets the timeout of each attempt.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithBaseURL sets the URL relative paths are resolved against.
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(url, "/")
+	}
+}
+
+// WithRetry sets the retry policy, by default retry.DefaultPolicy.
+func WithRetry(policy retry.Policy) Option {
+	return func(c *Client) {
+		c.policy = policy
+	}
+}
+
+// WithHeader adds a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithAPIKey sends the API key in the X-API-Key header.
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithBearer sends the static token as a Bearer Authorization header.
+func WithBearer(token string) Option {
+	return WithTokenSource(func(ctx context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithTokenSource sends the token returned by source as a Bearer Authorization header, e.g. a refreshed JWT.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) {
+		c.token = source
+	}
+}
+
+// WithTransport sets the transport of the underlying http.Client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.client.Transport = transport
+	}
+}
+
+// New returns a client configured by the given options.
+func New(options ...Option) *Client {
+	var c = &Client{
+		client:  &http.Client{Timeout: DefaultTimeout},
+		policy:  retry.DefaultPolicy,
+		headers: http.Header{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Default is the client used by the package level helpers.
+var Default = New()
+
+// Get sends a GET request and decodes the JSON response into out, if not nil.
+func (c *Client) Get(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, url, nil, out)
+}
+
+// Post sends body encoded as JSON and decodes the JSON response into out, if not nil.
+func (c *Client) Post(ctx context.Context, url string, body interface{}, out interface{}) error {
+	return c.Do(ctx, http.MethodPost, url, body, out)
+}
+
+// Put sends body encoded as JSON and decodes the JSON response into out, if not nil.
+func (c *Client) Put(ctx context.Context, url string, body interface{}, out interface{}) error {
+	return c.Do(ctx, http.MethodPut, url, body, out)
+}
+
+// Delete sends a DELETE request and decodes the JSON response into out, if not nil.
+func (c *Client) Delete(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodDelete, url, nil, out)
+}
+
+// Do sends the request, retrying according to the client policy, and decodes the JSON response into out.
+// Body may be nil, a []byte, a string or a value encoded as JSON.
+// Network errors, 429 and 5xx responses are retried for idempotent methods; other methods are only
+// retried on 429 and 503 as the server did not process them.
+func (c *Client) Do(ctx context.Context, method, url string, body interface{}, out interface{}) error {
+	var payload []byte
+	switch v := body.(type) {
+	case nil:
+	case []byte:
+		payload = v
+	case string:
+		payload = []byte(v)
+	default:
+		payload = []byte(JSON.Stringify(v))
+	}
+	if c.baseURL != "" && !strings.Contains(url, "://") {
+		url = c.baseURL + "/" + strings.TrimLeft(url, "/")
+	}
+
+	var data []byte
+	err := retry.Do(ctx, c.policy, func(attempt int) error {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		if err := c.prepare(ctx, req, payload != nil); err != nil {
+			return retry.Permanent(err)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if !idempotent(method) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		var statusErr = StatusError{StatusCode: resp.StatusCode, Body: data}
+		if retryable(method, resp.StatusCode) {
+			return statusErr
+		}
+		return retry.Permanent(statusErr)
+	})
+	if err != nil {
+		return err
+	}
+	if out != nil && len(data) > 0 {
+		return JSON.Parse(string(data), out)
+	}
+	return nil
+}
+
+// prepare sets the default headers, credentials and propagated trace headers of the request.
+func (c *Client) prepare(ctx context.Context, req *http.Request, hasBody bool) error {
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if hasBody && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, propagator := range Propagators {
+		propagator(ctx, req)
+	}
+	return nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(method string, status int) bool {
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true
+	}
+	return status >= 500 && idempotent(method)
+}
@@ -0,0 +1,58 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/retry"
+)
+
+func TestClientRetryAndDecode(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("traceparent") != "00-abc-def-01" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"toolbox"}`))
+	}))
+	defer server.Close()
+
+	var client = New(WithBaseURL(server.URL), WithBearer("token"), WithRetry(retry.Policy{Attempts: 3, Delay: time.Millisecond}))
+	var out struct {
+		Name string `json:"name"`
+	}
+	var ctx = WithTraceContext(context.Background(), "00-abc-def-01", "")
+	if err := client.Get(ctx, "/item", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "toolbox" || calls != 2 {
+		t.Errorf("got %q after %d calls, want toolbox after 2", out.Name, calls)
+	}
+}
+
+func TestClientNoRetryOnPost(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var client = New(WithRetry(retry.Policy{Attempts: 3, Delay: time.Millisecond}))
+	err := client.Post(context.Background(), server.URL, map[string]int{"a": 1}, nil)
+	if status, ok := err.(StatusError); !ok || status.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Post() = %v, want StatusError 500", err)
+	}
+	if calls != 1 {
+		t.Errorf("POST sent %d times, want 1", calls)
+	}
+}
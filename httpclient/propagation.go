@@ -0,0 +1,35 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Propagator copies the tracing information carried by ctx into the outgoing request headers.
+type Propagator func(ctx context.Context, req *http.Request)
+
+// Propagators are applied to every request. By default the W3C trace context set by WithTraceContext is propagated.
+var Propagators = []Propagator{TraceContext}
+
+type traceKey struct{}
+
+type trace struct {
+	parent string
+	state  string
+}
+
+// WithTraceContext returns a copy of ctx carrying the W3C traceparent and tracestate values,
+// usually taken from the incoming request.
+func WithTraceContext(ctx context.Context, traceparent, tracestate string) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace{parent: traceparent, state: tracestate})
+}
+
+// TraceContext sets the traceparent and tracestate headers from the values carried by ctx.
+func TraceContext(ctx context.Context, req *http.Request) {
+	if t, ok := ctx.Value(traceKey{}).(trace); ok && t.parent != "" {
+		req.Header.Set("traceparent", t.parent)
+		if t.state != "" {
+			req.Header.Set("tracestate", t.state)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/settings"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/l10n"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+type App struct {
+}
+
+// Register registers the override model, and reloads the overrides once their changes are committed.
+// The default locale is read from the I18N.DEFAULT setting.
+func (a App) Register() error {
+	db.UseModel(Override{})
+	if locale := settings.Get("I18N.DEFAULT").String(); locale != "" {
+		Default = l10n.Normalize(locale)
+	}
+	return toolbox.OnCommit(evo.GetDBO(), "i18n:reload", Override{}, func(tx *gorm.DB) {
+		if err := Reload(); err != nil {
+			logger.Error("unable to reload messages", "error", err.Error())
+		}
+	})
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+// WhenReady loads the overrides and reloads them every RefreshInterval.
+func (a App) WhenReady() error {
+	if err := Reload(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(RefreshInterval) {
+			if err := Reload(); err != nil {
+				logger.Error("unable to reload messages", "error", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (a App) Name() string {
+	return "i18n"
+}
@@ -0,0 +1,305 @@
+// Package i18n renders the messages returned by the API in the language of the caller.
+//
+// Messages are registered in code by key and locale, and can be overridden in the i18n_message table
+// through its rest resource. Placeholders such as {column} are replaced by the parameters, and the
+// {count} parameter selects the plural form of the message:
+//
+//	i18n.Register("it", map[string]string{"permission denied": "permesso negato"})
+//	i18n.RegisterPlural("en", "validation.max_length", i18n.Message{
+//		One:   "{column} must be at most {count} character",
+//		Other: "{column} must be at most {count} characters",
+//	})
+//	return i18n.NewError("validation.max_length", "column", "code", "count", 3)
+//
+// Plain errors are translated using their text as key.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/l10n"
+	"gorm.io/gorm"
+)
+
+// Default is the locale of the messages when the caller language has no translation.
+var Default = "en"
+
+// RefreshInterval specifies how often the overrides are reloaded to pick up changes made by other instances.
+var RefreshInterval = time.Minute
+
+// Message holds the plural forms of a message. Other is used when the form selected by the count is empty
+// and for messages without count.
+type Message struct {
+	Zero  string `gorm:"column:zero;type:text" json:"zero,omitempty"`
+	One   string `gorm:"column:one;type:text" json:"one,omitempty"`
+	Two   string `gorm:"column:two;type:text" json:"two,omitempty"`
+	Few   string `gorm:"column:few;type:text" json:"few,omitempty"`
+	Many  string `gorm:"column:many;type:text" json:"many,omitempty"`
+	Other string `gorm:"column:other;type:text" json:"other"`
+}
+
+// Override is a message stored in the database, taking precedence over the one registered in code.
+type Override struct {
+	Key    string `gorm:"column:key;size:191;primaryKey" json:"key"`
+	Locale string `gorm:"column:locale;size:16;primaryKey" json:"locale"`
+	Message
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName returns the name of the table for the Override struct.
+func (Override) TableName() string {
+	return "i18n_message"
+}
+
+// BeforeSave normalizes the locale.
+func (o *Override) BeforeSave(tx *gorm.DB) error {
+	o.Locale = l10n.Normalize(o.Locale)
+	if o.Key == "" || o.Locale == "" {
+		return errors.New("key and locale are required")
+	}
+	return nil
+}
+
+// catalog holds the messages keyed by locale and key.
+type catalog map[string]map[string]Message
+
+var registered = catalog{}
+var overrides = catalog{}
+var mu sync.RWMutex
+
+func (c catalog) set(locale string, key string, m Message) {
+	if c[locale] == nil {
+		c[locale] = map[string]Message{}
+	}
+	c[locale][key] = m
+}
+
+// Register adds messages without plural forms for the locale.
+func Register(locale string, messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for key, text := range messages {
+		registered.set(l10n.Normalize(locale), key, Message{Other: text})
+	}
+}
+
+// RegisterPlural adds a message with plural forms for the locale.
+func RegisterPlural(locale string, key string, m Message) {
+	mu.Lock()
+	registered.set(l10n.Normalize(locale), key, m)
+	mu.Unlock()
+}
+
+// Reload reads the overrides from the database.
+func Reload() error {
+	var items []Override
+	if err := db.Find(&items).Error; err != nil {
+		return err
+	}
+	var loaded = catalog{}
+	for _, item := range items {
+		loaded.set(l10n.Normalize(item.Locale), item.Key, item.Message)
+	}
+	mu.Lock()
+	overrides = loaded
+	mu.Unlock()
+	return nil
+}
+
+// chain returns the locales tried for the locale: the locale, its language and the default.
+func chain(locale string) []string {
+	// the first locale of an Accept-Language like list is used
+	if idx := strings.IndexAny(locale, ",;"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	locale = l10n.Normalize(locale)
+	var result []string
+	for _, l := range []string{locale, language(locale), l10n.Normalize(Default)} {
+		if l == "" {
+			continue
+		}
+		var seen = false
+		for _, item := range result {
+			seen = seen || item == l
+		}
+		if !seen {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+func language(locale string) string {
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		return locale[:idx]
+	}
+	return locale
+}
+
+// lookup returns the message of the key in the first locale of the chain having it, overrides first.
+func lookup(locale string, key string) (Message, string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, l := range chain(locale) {
+		if m, ok := overrides[l][key]; ok {
+			return m, l, true
+		}
+		if m, ok := registered[l][key]; ok {
+			return m, l, true
+		}
+	}
+	return Message{}, "", false
+}
+
+// T returns the message of the key in the locale with the placeholders replaced by the parameters,
+// given as key value pairs. The key itself is rendered when no message is registered.
+func T(locale string, key string, params ...interface{}) string {
+	var values = map[string]interface{}{}
+	for i := 0; i+1 < len(params); i += 2 {
+		values[fmt.Sprint(params[i])] = params[i+1]
+	}
+	m, found, ok := lookup(locale, key)
+	var text = key
+	if ok {
+		text = m.Other
+		if count, ok := values["count"]; ok {
+			if form := m.form(plural(language(found), toInt(count))); form != "" {
+				text = form
+			}
+		}
+	}
+	for name, value := range values {
+		text = strings.ReplaceAll(text, "{"+name+"}", fmt.Sprint(value))
+	}
+	return text
+}
+
+func (m Message) form(category string) string {
+	switch category {
+	case "zero":
+		return m.Zero
+	case "one":
+		return m.One
+	case "two":
+		return m.Two
+	case "few":
+		return m.Few
+	case "many":
+		return m.Many
+	}
+	return m.Other
+}
+
+func toInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case uint:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	var n int64
+	fmt.Sscan(fmt.Sprint(v), &n)
+	return n
+}
+
+// plural returns the CLDR plural category of the integer n in the language.
+func plural(lang string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ja", "zh", "ko", "vi", "th", "id", "tr":
+		return "other"
+	case "fr", "pt":
+		if n <= 1 {
+			return "one"
+		}
+	case "ru", "uk", "sr", "hr", "bs":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n%100 >= 3 && n%100 <= 10:
+			return "few"
+		case n%100 >= 11:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// Error is an error rendered from a message of the catalog.
+type Error struct {
+	Key    string
+	Params []interface{}
+}
+
+// NewError returns an error rendering the message of the key with the parameters, given as key value pairs.
+func NewError(key string, params ...interface{}) *Error {
+	return &Error{Key: key, Params: params}
+}
+
+// Error returns the message in the default locale.
+func (e *Error) Error() string {
+	return T(Default, e.Key, e.Params...)
+}
+
+// Localize returns the message in the locale.
+func (e *Error) Localize(locale string) string {
+	return T(locale, e.Key, e.Params...)
+}
+
+// Translate returns the message of the error in the locale. Errors of the catalog are rendered with their
+// parameters, other errors are translated using their text as key.
+func Translate(locale string, err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Localize(locale)
+	}
+	return T(locale, err.Error())
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	Register("en", map[string]string{"permission denied": "permission denied"})
+	Register("it", map[string]string{"permission denied": "permesso negato"})
+	RegisterPlural("en", "rows", Message{One: "{count} row", Other: "{count} rows"})
+	RegisterPlural("it", "rows", Message{One: "{count} riga", Other: "{count} righe"})
+	RegisterPlural("pl", "rows", Message{One: "{count} wiersz", Few: "{count} wiersze", Many: "{count} wierszy"})
+	overrides = catalog{"it-CH": {"rows": {Other: "{count} righe (CH)"}}}
+	defer func() { overrides = catalog{} }()
+
+	var tests = []struct {
+		locale string
+		key    string
+		params []interface{}
+		want   string
+	}{
+		{"it", "permission denied", nil, "permesso negato"},
+		{"it-IT", "permission denied", nil, "permesso negato"},
+		{"de", "permission denied", nil, "permission denied"},
+		{"en", "rows", []interface{}{"count", 1}, "1 row"},
+		{"en", "rows", []interface{}{"count", 0}, "0 rows"},
+		{"it_IT", "rows", []interface{}{"count", 1}, "1 riga"},
+		{"it-CH", "rows", []interface{}{"count", 1}, "1 righe (CH)"},
+		{"pl", "rows", []interface{}{"count", 3}, "3 wiersze"},
+		{"pl", "rows", []interface{}{"count", 12}, "12 wierszy"},
+		{"pl", "rows", []interface{}{"count", 22}, "22 wiersze"},
+		{"fr", "missing {name}", []interface{}{"name", "key"}, "missing key"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.key, tt.params...); got != tt.want {
+			t.Errorf("T(%s, %s) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	Register("en", map[string]string{"validation.required": "{column} is required"})
+	Register("it", map[string]string{"validation.required": "{column} è obbligatorio"})
+	var err = fmt.Errorf("update: %w", NewError("validation.required", "column", "name"))
+	if got := Translate("it", err); got != "name è obbligatorio" {
+		t.Errorf("Translate(it) = %q", got)
+	}
+	if got := err.Error(); got != "update: name is required" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := Translate("it", errors.New("unknown error")); got != "unknown error" {
+		t.Errorf("Translate(plain) = %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package l10n
+
+import (
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/settings"
+	"github.com/iesitalia/toolbox"
+	"gorm.io/gorm"
+)
+
+type App struct {
+}
+
+// Register registers the translation model and the callbacks translating the query results.
+// The default locale is read from the L10N.DEFAULT setting and the fallbacks from L10N.FALLBACKS,
+// e.g. `de-CH:de-DE,it:en`.
+func (a App) Register() error {
+	db.UseModel(Translation{})
+	if Default == "" {
+		Default = Normalize(settings.Get("L10N.DEFAULT").String())
+	}
+	for _, item := range strings.Split(settings.Get("L10N.FALLBACKS").String(), ",") {
+		if locale, fallback, ok := strings.Cut(item, ":"); ok {
+			locale = Normalize(locale)
+			Fallbacks[locale] = append(Fallbacks[locale], Normalize(fallback))
+		}
+	}
+
+	return toolbox.Callbacks(evo.GetDBO(), func(dbo *gorm.DB) error {
+		if err := dbo.Callback().Query().After("*").Register("l10n:query", OnQuery); err != nil {
+			return err
+		}
+		return dbo.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("l10n:delete", OnDelete)
+	})
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "l10n"
+}
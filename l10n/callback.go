@@ -0,0 +1,140 @@
+package l10n
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// columns caches the translatable columns of each table.
+var columns sync.Map
+
+// Columns returns the fields of the schema marked with the l10n tag. Only string fields can be translated.
+func Columns(s *schema.Schema) []*schema.Field {
+	if cached, ok := columns.Load(s.Table); ok {
+		return cached.([]*schema.Field)
+	}
+	var result []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.StructField.Type.Kind() != reflect.String {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("l10n"); ok && tag != "-" && tag != "false" {
+			result = append(result, field)
+		}
+	}
+	columns.Store(s.Table, result)
+	return result
+}
+
+// translatable returns the translatable columns of the statement and the field of its single primary key.
+func translatable(db *gorm.DB) ([]*schema.Field, *schema.Field) {
+	if db.Error != nil || db.Statement.Schema == nil || len(db.Statement.Schema.PrimaryFields) != 1 {
+		return nil, nil
+	}
+	var fields = Columns(db.Statement.Schema)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, db.Statement.Schema.PrimaryFields[0]
+}
+
+// rows returns the structs written or read by the statement keyed by primary key.
+func rows(db *gorm.DB, pk *schema.Field) map[string][]reflect.Value {
+	var result = map[string][]reflect.Value{}
+	var collect = func(value reflect.Value) {
+		if v, zero := pk.ValueOf(context.Background(), value); !zero {
+			var id = fmt.Sprint(v)
+			result[id] = append(result[id], value)
+		}
+	}
+	var value = reflect.Indirect(db.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Struct:
+		collect(value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if item := reflect.Indirect(value.Index(i)); item.Kind() == reflect.Struct {
+				collect(item)
+			}
+		}
+	}
+	return result
+}
+
+// OnQuery replaces the translatable columns of the loaded rows with their translation in the locale of the statement.
+func OnQuery(db *gorm.DB) {
+	locale, ok := db.Get("lang")
+	if !ok || fmt.Sprint(locale) == "" {
+		return
+	}
+	fields, pk := translatable(db)
+	if fields == nil {
+		return
+	}
+	var loaded = rows(db, pk)
+	if len(loaded) == 0 {
+		return
+	}
+	var ids []string
+	for id := range loaded {
+		ids = append(ids, id)
+	}
+	var chain = Chain(fmt.Sprint(locale))
+	var items []Translation
+	var err = db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Where("`table` = ? AND row_id IN (?) AND locale IN (?)", db.Statement.Schema.Table, ids, chain).Find(&items).Error
+	if err != nil {
+		logger.Error("unable to load translations", "table", db.Statement.Schema.Table, "error", err.Error())
+		return
+	}
+	// index the translations by row, column and locale to pick the first locale of the chain
+	var translations = map[string]map[string]map[string]string{}
+	for _, item := range items {
+		if translations[item.RowID] == nil {
+			translations[item.RowID] = map[string]map[string]string{}
+		}
+		if translations[item.RowID][item.Column] == nil {
+			translations[item.RowID][item.Column] = map[string]string{}
+		}
+		translations[item.RowID][item.Column][item.Locale] = item.Value
+	}
+	for id, values := range loaded {
+		for _, field := range fields {
+			var byLocale = translations[id][field.DBName]
+			for _, l := range chain {
+				if value, ok := byLocale[l]; ok {
+					for _, row := range values {
+						_ = field.Set(context.Background(), row, value)
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// OnDelete removes the translations of the deleted rows.
+func OnDelete(db *gorm.DB) {
+	fields, pk := translatable(db)
+	if fields == nil {
+		return
+	}
+	var ids []string
+	for id := range rows(db, pk) {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var err = db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Where("`table` = ? AND row_id IN (?)", db.Statement.Schema.Table, ids).Delete(&Translation{}).Error
+	if err != nil {
+		logger.Error("unable to delete translations", "table", db.Statement.Schema.Table, "error", err.Error())
+	}
+}
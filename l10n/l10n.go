@@ -0,0 +1,129 @@
+// Package l10n translates the marked string columns of the models into the locale of the request.
+//
+// Columns are marked with the `l10n` tag and their translations stored in the translation table:
+//
+//	type Product struct {
+//		ID          uint64 `gorm:"primaryKey"`
+//		Name        string `l10n:"true"`
+//		Description string `l10n:"true"`
+//		model.Translatable
+//	}
+//
+// Queries run with a locale, set by the rest endpoints from the `language` header, replace the values of
+// the marked columns with their translation, looked up along the fallback chain of the locale. Columns
+// without a translation keep the value stored in the row.
+package l10n
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fallbacks lists the locales tried, in order, when a value is not translated in a locale,
+// e.g. {"de-CH": {"de-DE"}, "it": {"en"}}. The language of a regional locale is always tried.
+var Fallbacks = map[string][]string{}
+
+// Default is the locale tried last, set from the L10N.DEFAULT setting.
+var Default = ""
+
+// Translation is the value of a column of a row in a locale.
+type Translation struct {
+	Table  string `gorm:"column:table;size:64;primaryKey" json:"table"`
+	RowID  string `gorm:"column:row_id;size:64;primaryKey" json:"row_id"`
+	Column string `gorm:"column:column;size:64;primaryKey" json:"column"`
+	Locale string `gorm:"column:locale;size:16;primaryKey" json:"locale"`
+	Value  string `gorm:"column:value;type:text" json:"value"`
+}
+
+// TableName returns the name of the table for the Translation struct.
+func (Translation) TableName() string {
+	return "translation"
+}
+
+// WithLocale returns a session translating the results of its queries into the locale.
+func WithLocale(dbo *gorm.DB, locale string) *gorm.DB {
+	return dbo.Set("lang", locale)
+}
+
+// Normalize returns the locale using a dash and lowercase language, e.g. it_IT becomes it-IT.
+func Normalize(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		return strings.ToLower(locale[:idx]) + "-" + strings.ToUpper(locale[idx+1:])
+	}
+	return strings.ToLower(locale)
+}
+
+// Chain returns the locales tried for the given locale: the locale, its language, their fallbacks and the default.
+// The first locale of an Accept-Language like list, e.g. `it-IT,it;q=0.9`, is used.
+func Chain(locale string) []string {
+	if idx := strings.IndexAny(locale, ",;"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	var result []string
+	var seen = map[string]bool{}
+	var add func(locale string)
+	add = func(locale string) {
+		locale = Normalize(locale)
+		if locale == "" || seen[locale] {
+			return
+		}
+		seen[locale] = true
+		result = append(result, locale)
+		for _, fallback := range Fallbacks[locale] {
+			add(fallback)
+		}
+		if idx := strings.Index(locale, "-"); idx > 0 {
+			add(locale[:idx])
+		}
+	}
+	add(locale)
+	add(Default)
+	return result
+}
+
+// Get returns the translations of the row of the table keyed by locale and column.
+func Get(table string, rowID interface{}) (map[string]map[string]string, error) {
+	var items []Translation
+	if err := db.Where("`table` = ? AND row_id = ?", table, fmt.Sprint(rowID)).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	var result = map[string]map[string]string{}
+	for _, item := range items {
+		if result[item.Locale] == nil {
+			result[item.Locale] = map[string]string{}
+		}
+		result[item.Locale][item.Column] = item.Value
+	}
+	return result, nil
+}
+
+// Set stores the translations of the row of the table keyed by locale and column. Empty values remove the translation.
+func Set(table string, rowID interface{}, values map[string]map[string]string) error {
+	var id = fmt.Sprint(rowID)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for locale, columns := range values {
+			locale = Normalize(locale)
+			if locale == "" {
+				return fmt.Errorf("invalid locale")
+			}
+			for column, value := range columns {
+				var item = Translation{Table: table, RowID: id, Column: column, Locale: locale, Value: value}
+				var err error
+				if value == "" {
+					err = tx.Where("`table` = ? AND row_id = ? AND `column` = ? AND locale = ?", table, id, column, locale).Delete(&Translation{}).Error
+				} else {
+					err = tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"value"})}).Create(&item).Error
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
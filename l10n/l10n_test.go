@@ -0,0 +1,64 @@
+package l10n_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/iesitalia/toolbox/l10n"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestChain(t *testing.T) {
+	l10n.Fallbacks = map[string][]string{"de-CH": {"de-DE"}, "it": {"en"}}
+	l10n.Default = "en"
+	defer func() {
+		l10n.Fallbacks = map[string][]string{}
+		l10n.Default = ""
+	}()
+	var tests = []struct {
+		locale string
+		want   []string
+	}{
+		{"it_IT", []string{"it-IT", "it", "en"}},
+		{"de-ch", []string{"de-CH", "de-DE", "de", "en"}},
+		{"EN", []string{"en"}},
+		{"fr-FR,fr;q=0.9", []string{"fr-FR", "fr", "en"}},
+		{"", []string{"en"}},
+	}
+	for _, tt := range tests {
+		if got := l10n.Chain(tt.locale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Chain(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+type dish struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Name  string `gorm:"size:64" json:"name" l10n:"true"`
+	Price int    `json:"price"`
+}
+
+func TestUpdateKeepsBaseValues(t *testing.T) {
+	var dbo = resttest.Setup(t, l10n.Translation{}, dish{})
+	if err := dbo.Callback().Query().After("*").Register("l10n:query", l10n.OnQuery); err != nil {
+		t.Fatal(err)
+	}
+	var row = dish{Name: "Pasta with tomato", Price: 9}
+	dbo.Create(&row)
+	if err := l10n.Set("dishes", row.ID, map[string]map[string]string{"it": {"name": "Pasta al pomodoro"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resttest.WithHeader(t, "language", "it")
+	if page := resttest.Get[dish](t, fmt.Sprintf("/admin/rest/dishes/%d", row.ID)); page.Data.Name != "Pasta al pomodoro" {
+		t.Fatalf("expected the translated name, got %q", page.Data.Name)
+	}
+	resttest.Do[dish](t, http.MethodPost, fmt.Sprintf("/admin/rest/dishes/%d", row.ID), map[string]interface{}{"price": 11})
+	var stored dish
+	dbo.Take(&stored, row.ID)
+	if stored.Name != "Pasta with tomato" || stored.Price != 11 {
+		t.Errorf("expected the base name to be kept, got %+v", stored)
+	}
+}
@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/getevo/evo/v2"
+)
+
+// RequestIDHeader specifies the header carrying the request id, generated when missing.
+var RequestIDHeader = "X-Request-ID"
+
+// MaxRequestIDLength bounds the length of the request ids accepted from the RequestIDHeader header.
+var MaxRequestIDLength = 64
+
+// RequestIDLocal is the request local holding the request id.
+const RequestIDLocal = "request_id"
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying the logger.
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or a logger without fields.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return std
+}
+
+// RequestID returns the id of the request, taken from the RequestIDHeader header or generated.
+// Ids of the header longer than MaxRequestIDLength or holding characters other than letters, digits,
+// '-', '_', '.' and ':' are replaced by a generated one, so they can not forge log lines.
+// The id is echoed in the response header.
+func RequestID(request *evo.Request) string {
+	if id, ok := request.Locals(RequestIDLocal).(string); ok && id != "" {
+		return id
+	}
+	var id = request.Header(RequestIDHeader)
+	if !validRequestID(id) {
+		var b = make([]byte, 8)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	request.Locals(RequestIDLocal, id)
+	request.SetHeader(RequestIDHeader, id)
+	return id
+}
+
+// FromRequest returns a logger carrying the request id, method, path and user of the request.
+func FromRequest(request *evo.Request) *Logger {
+	var l = New("request_id", RequestID(request), "method", request.Method(), "path", request.Path())
+	if user := request.User(); user != nil && !user.Anonymous() {
+		l = l.With("user", user.UUID())
+	}
+	return l
+}
+
+// validRequestID reports whether the id given by a client can be used as request id.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		var c = id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/log"
+)
+
+// CaptureEvo routes the entries of evo's global logger to the sinks, so packages still using it
+// share the same output. The evo level filter still applies.
+func CaptureEvo() {
+	log.SetWriters(func(message string) {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(message), &data); err != nil {
+			write(Entry{Time: time.Now(), Level: InfoLevel, Message: message})
+			return
+		}
+		var entry = Entry{Time: time.Now(), Level: NoticeLevel, Fields: map[string]interface{}{}}
+		for key, value := range data {
+			switch key {
+			case "l":
+				entry.Level = ParseLevel(toString(value))
+			case "m":
+				entry.Message = toString(value)
+			case "d":
+			case "f":
+				entry.Fields["file"] = value
+			default:
+				entry.Fields[key] = value
+			}
+		}
+		write(entry)
+	})
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
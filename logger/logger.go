@@ -0,0 +1,218 @@
+// Package logger provides leveled structured logging with fields, context propagation and pluggable sinks.
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/log"
+)
+
+// Level is the severity of an entry. Levels are shared with the evo logger.
+type Level = log.Level
+
+const (
+	CriticalLevel = log.CriticalLevel
+	ErrorLevel    = log.ErrorLevel
+	WarningLevel  = log.WarningLevel
+	NoticeLevel   = log.NoticeLevel
+	InfoLevel     = log.InfoLevel
+	DebugLevel    = log.DebugLevel
+)
+
+var levelNames = map[Level]string{
+	CriticalLevel: "critical",
+	ErrorLevel:    "error",
+	WarningLevel:  "warning",
+	NoticeLevel:   "notice",
+	InfoLevel:     "info",
+	DebugLevel:    "debug",
+}
+
+// LevelName returns the lowercase name of the level.
+func LevelName(level Level) string {
+	return levelNames[level]
+}
+
+// ParseLevel parses a level name, unknown names resolve to NoticeLevel.
+func ParseLevel(name string) Level {
+	return log.ParseLevel(name)
+}
+
+// Entry represents a log entry passed to the sinks.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  map[string]interface{}
+}
+
+// MarshalJSON encodes the entry as a flat object where fields sit next to time, level and message.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	var data = make(map[string]interface{}, len(e.Fields)+3)
+	for key, value := range e.Fields {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+	data["time"] = e.Time.Format(time.RFC3339Nano)
+	data["level"] = LevelName(e.Level)
+	data["message"] = e.Message
+	return json.Marshal(data)
+}
+
+// Sink receives the entries of the loggers.
+type Sink interface {
+	Write(entry Entry) error
+}
+
+var output = struct {
+	sync.RWMutex
+	sinks []Sink
+	level Level
+}{sinks: []Sink{NewJSONSink(os.Stdout)}, level: InfoLevel}
+
+// SetLevel sets the most verbose level written to the sinks.
+func SetLevel(level Level) {
+	output.Lock()
+	output.level = level
+	output.Unlock()
+}
+
+// Enabled reports whether entries of the given level are written.
+func Enabled(level Level) bool {
+	output.RLock()
+	defer output.RUnlock()
+	return level <= output.level
+}
+
+// AddSink adds sinks receiving every entry.
+func AddSink(sinks ...Sink) {
+	output.Lock()
+	output.sinks = append(output.sinks, sinks...)
+	output.Unlock()
+}
+
+// SetSinks replaces the sinks, by default entries are written as JSON to stdout.
+func SetSinks(sinks ...Sink) {
+	output.Lock()
+	output.sinks = sinks
+	output.Unlock()
+}
+
+// write sends the entry to every sink. Sink failures are reported on stderr as they can not be logged.
+func write(entry Entry) {
+	output.RLock()
+	var sinks = output.sinks
+	output.RUnlock()
+	for _, sink := range sinks {
+		if err := sink.Write(entry); err != nil {
+			fmt.Fprintln(os.Stderr, "logger: unable to write entry:", err)
+		}
+	}
+}
+
+// Logger writes entries carrying a set of fields.
+type Logger struct {
+	fields map[string]interface{}
+}
+
+// New returns a logger carrying the given key/value pairs.
+func New(kv ...interface{}) *Logger {
+	return (&Logger{}).With(kv...)
+}
+
+// With returns a copy of the logger carrying the given key/value pairs in addition to its fields.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	var fields = make(map[string]interface{}, len(l.fields)+len(kv)/2)
+	for key, value := range l.fields {
+		fields[key] = value
+	}
+	addFields(fields, kv)
+	return &Logger{fields: fields}
+}
+
+// Fields returns the fields carried by the logger.
+func (l *Logger) Fields() map[string]interface{} {
+	return l.fields
+}
+
+// addFields adds key/value pairs to fields. A value without a key is stored under its position.
+func addFields(fields map[string]interface{}, kv []interface{}) {
+	for i := 0; i < len(kv); i++ {
+		key, ok := kv[i].(string)
+		if !ok || i+1 == len(kv) {
+			fields[fmt.Sprintf("$%d", i)] = kv[i]
+			continue
+		}
+		fields[key] = kv[i+1]
+		i++
+	}
+}
+
+// Log writes an entry of the given level with the fields of the logger and the given key/value pairs.
+func (l *Logger) Log(level Level, message string, kv ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	var fields = make(map[string]interface{}, len(l.fields)+len(kv)/2)
+	for key, value := range l.fields {
+		fields[key] = value
+	}
+	addFields(fields, kv)
+	write(Entry{Time: time.Now(), Level: level, Message: message, Fields: fields})
+}
+
+func (l *Logger) Debug(message string, kv ...interface{}) {
+	l.Log(DebugLevel, message, kv...)
+}
+
+func (l *Logger) Info(message string, kv ...interface{}) {
+	l.Log(InfoLevel, message, kv...)
+}
+
+func (l *Logger) Notice(message string, kv ...interface{}) {
+	l.Log(NoticeLevel, message, kv...)
+}
+
+func (l *Logger) Warning(message string, kv ...interface{}) {
+	l.Log(WarningLevel, message, kv...)
+}
+
+func (l *Logger) Error(message string, kv ...interface{}) {
+	l.Log(ErrorLevel, message, kv...)
+}
+
+func (l *Logger) Critical(message string, kv ...interface{}) {
+	l.Log(CriticalLevel, message, kv...)
+}
+
+var std = &Logger{}
+
+func Debug(message string, kv ...interface{}) {
+	std.Log(DebugLevel, message, kv...)
+}
+
+func Info(message string, kv ...interface{}) {
+	std.Log(InfoLevel, message, kv...)
+}
+
+func Notice(message string, kv ...interface{}) {
+	std.Log(NoticeLevel, message, kv...)
+}
+
+func Warning(message string, kv ...interface{}) {
+	std.Log(WarningLevel, message, kv...)
+}
+
+func Error(message string, kv ...interface{}) {
+	std.Log(ErrorLevel, message, kv...)
+}
+
+func Critical(message string, kv ...interface{}) {
+	std.Log(CriticalLevel, message, kv...)
+}
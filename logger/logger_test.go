@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	var sinks = output.sinks
+	SetSinks(NewJSONSink(&buf))
+	SetLevel(InfoLevel)
+	defer SetSinks(sinks...)
+
+	var l = New("request_id", "abc").With("tenant", "acme")
+	l.Info("hello", "count", 2)
+	l.Debug("hidden")
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatalf("invalid output %q: %v", buf.String(), err)
+	}
+	var want = map[string]interface{}{"request_id": "abc", "tenant": "acme", "count": float64(2), "level": "info", "message": "hello"}
+	for key, value := range want {
+		if data[key] != value {
+			t.Errorf("%s = %v, want %v", key, data[key], value)
+		}
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("debug entry written below level: %q", buf.String())
+	}
+	if len(New("a", "b").Fields()) != 1 || len(l.Fields()) != 2 {
+		t.Error("With modified the parent logger")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	var tests = map[string]bool{
+		"":                                      false,
+		"3f0e1c2a-0000-4000-8000-000000000001":  true,
+		"trace:abc.def_1":                       true,
+		"abc\n{\"level\":\"error\"}":            false,
+		"a b":                                   false,
+		strings.Repeat("a", MaxRequestIDLength): true,
+		strings.Repeat("a", MaxRequestIDLength+1): false,
+	}
+	for id, want := range tests {
+		if got := validRequestID(id); got != want {
+			t.Errorf("validRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONSink writes entries as one JSON object per line.
+type JSONSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewJSONSink returns a sink writing JSON lines to the writer.
+func NewJSONSink(writer io.Writer) *JSONSink {
+	return &JSONSink{writer: writer}
+}
+
+func (s *JSONSink) Write(entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(append(b, '\n'))
+	return err
+}
+
+// NewFileSink returns a sink appending JSON lines to the file at path, creating it if needed.
+func NewFileSink(path string) (*JSONSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONSink(file), nil
+}
+
+// FuncSink adapts a function to the Sink interface.
+type FuncSink func(entry Entry) error
+
+func (f FuncSink) Write(entry Entry) error {
+	return f(entry)
+}
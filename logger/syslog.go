@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// SyslogSink writes entries as JSON messages to syslog using the priority matching their level.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog daemon at raddr over network, or to the local daemon if both are empty.
+func NewSyslogSink(network, raddr, tag string) (*SyslogSink, error) {
+	writer, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+func (s *SyslogSink) Write(entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	var message = string(b)
+	switch entry.Level {
+	case CriticalLevel:
+		return s.writer.Crit(message)
+	case ErrorLevel:
+		return s.writer.Err(message)
+	case WarningLevel:
+		return s.writer.Warning(message)
+	case NoticeLevel:
+		return s.writer.Notice(message)
+	case DebugLevel:
+		return s.writer.Debug(message)
+	default:
+		return s.writer.Info(message)
+	}
+}
@@ -0,0 +1,173 @@
+package mail
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/settings"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/auth"
+	"github.com/iesitalia/toolbox/health"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/metering"
+	"github.com/iesitalia/toolbox/model"
+)
+
+// PREFIX specifies the prefix for mail routes in the admin panel.
+var PREFIX = "/admin"
+
+// Operators is the list of addresses notified of system failures, e.g. undelivered webhooks.
+var Operators []string
+
+// AlertInterval specifies the minimum time between two notifications of the failures of the same webhook.
+var AlertInterval = time.Hour
+
+// alerted holds the time the failures of each webhook were last notified.
+var alerted = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// throttled reports whether the failures of the webhook were notified less than AlertInterval ago, and
+// records the notification otherwise.
+func throttled(url string) bool {
+	alerted.Lock()
+	defer alerted.Unlock()
+	var now = time.Now()
+	if last, ok := alerted.at[url]; ok && now.Sub(last) < AlertInterval {
+		return true
+	}
+	for key, last := range alerted.at {
+		if now.Sub(last) >= AlertInterval {
+			delete(alerted.at, key)
+		}
+	}
+	alerted.at[url] = now
+	return false
+}
+
+// Templates used by the toolbox notifications. They can be overridden in code or in the mail_template table.
+const (
+	PasswordResetTemplate  = "password_reset"
+	WebhookFailureTemplate = "webhook_failure"
+)
+
+type App struct {
+}
+
+// Register registers the mail models and the default templates, configures the SMTP provider from the
+// MAIL.SMTP_HOST, MAIL.SMTP_PORT, MAIL.SMTP_USERNAME, MAIL.SMTP_PASSWORD and MAIL.FROM settings and
+// sends password reset tokens and webhook failures by email unless other handlers are already set.
+// The failures of a webhook are notified once every AlertInterval.
+// The backlog of the queue is checked by the readiness probe, see MaxBacklog.
+func (a App) Register() error {
+	db.UseModel(Template{}, Queued{})
+
+	RegisterTemplate(PasswordResetTemplate, "Reset your password",
+		"Hi $user.FirstName,\n\nuse the following token to reset your password: $token\n\nIf you did not request a password reset, ignore this email.", "")
+	RegisterTemplate(WebhookFailureTemplate, "Webhook delivery failed",
+		"The delivery to the webhook $url failed: $error", "")
+
+	if From == "" {
+		From = settings.Get("MAIL.FROM").String()
+	}
+	if host := settings.Get("MAIL.SMTP_HOST").String(); Default == nil && host != "" {
+		Default = SMTP{
+			Host:     host,
+			Port:     settings.Get("MAIL.SMTP_PORT").Int(),
+			Username: settings.Get("MAIL.SMTP_USERNAME").String(),
+			Password: settings.Get("MAIL.SMTP_PASSWORD").String(),
+		}
+	}
+
+	health.AddChecker("mail_queue", health.Backlog(Backlog, MaxBacklog))
+
+	if auth.ResetTokenSender == nil {
+		auth.ResetTokenSender = func(user *model.User, token string) error {
+			return Send(PasswordResetTemplate, user, map[string]interface{}{"token": token})
+		}
+	}
+	if metering.OnWebhookFailure == nil {
+		metering.OnWebhookFailure = func(url string, err error) {
+			if len(Operators) == 0 || throttled(url) {
+				return
+			}
+			if err := SendTo(WebhookFailureTemplate, Operators, nil, map[string]interface{}{"url": url, "error": err.Error()}); err != nil {
+				logger.Error("unable to queue webhook failure notification", "url", url, "error", err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Router sets up the queue endpoints, restricted to the users holding acl.Admin.
+// GET /mail/queue?status=failed lists the latest queued messages.
+// POST /mail/queue/:id/retry reschedules a failed message, or one left sending by a stopped instance.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/mail/queue", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		var items []Queued
+		var query = db.Order("id DESC").Limit(100)
+		if status := request.Query("status").String(); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if err := query.Find(&items).Error; err != nil {
+			return err
+		}
+		return items
+	})
+	evo.Post(PREFIX+"/mail/queue/:id/retry", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		return Retry(request.Param("id").Uint64())
+	})
+	return nil
+}
+
+// queueStop stops the delivery of the queued messages, closed once by stopQueue, and queueDone is closed once
+// stopped.
+var queueStop = make(chan struct{})
+var queueDone = make(chan struct{})
+var stopQueue sync.Once
+
+// WhenReady starts the delivery of the queued messages.
+func (a App) WhenReady() error {
+	go func() {
+		defer close(queueDone)
+		var ticker = time.NewTicker(QueueInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-queueStop:
+				return
+			case <-ticker.C:
+				if err := ProcessQueue(); err != nil {
+					logger.Error("unable to process mail queue", "error", err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the delivery of the queued messages, waiting for the run in progress. Messages not sent
+// stay queued for the next start.
+func (a App) Shutdown(ctx context.Context) error {
+	stopQueue.Do(func() { close(queueStop) })
+	select {
+	case <-queueDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a App) Name() string {
+	return "mail"
+}
@@ -0,0 +1,230 @@
+// Package mail sends transactional emails rendered from named templates.
+//
+// Messages are queued in the database and delivered by the mail app using the configured Provider,
+// failed deliveries are retried with exponential backoff:
+//
+//	mail.RegisterTemplate("welcome", "Welcome ${user.FirstName | title}", "Hi $user.FirstName, your account is ready.", "")
+//	err := mail.Send("welcome", user, map[string]interface{}{"plan": "pro"})
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoRecipient is returned when a message has no recipient.
+var ErrNoRecipient = errors.New("mail has no recipient")
+
+// ErrNoProvider is returned by deliveries while no Provider is configured.
+var ErrNoProvider = errors.New("mail provider is not configured")
+
+// Message represents an email. At least one of Text and HTML should be set,
+// both are sent as a multipart/alternative message. Attachments are sent along with them in a multipart/mixed message.
+type Message struct {
+	From        string            `json:"from"`
+	To          []string          `json:"to"`
+	Cc          []string          `json:"cc,omitempty"`
+	Bcc         []string          `json:"bcc,omitempty"`
+	ReplyTo     string            `json:"reply_to,omitempty"`
+	Subject     string            `json:"subject"`
+	Text        string            `json:"text,omitempty"`
+	HTML        string            `json:"html,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Template    string            `json:"template,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+}
+
+// Recipients returns the addresses of To, Cc and Bcc.
+func (m *Message) Recipients() []string {
+	var result []string
+	result = append(result, m.To...)
+	result = append(result, m.Cc...)
+	result = append(result, m.Bcc...)
+	return result
+}
+
+// Bytes returns the message in the RFC 5322 format. Bcc recipients are not written.
+func (m *Message) Bytes() []byte {
+	var buf bytes.Buffer
+	var header = func(key, value string) {
+		if value != "" {
+			buf.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Cc", strings.Join(m.Cc, ", "))
+	header("Reply-To", m.ReplyTo)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	for key, value := range m.Headers {
+		header(key, value)
+	}
+
+	if len(m.Attachments) == 0 {
+		m.writeContent(&buf)
+		return buf.Bytes()
+	}
+	var boundary = randomBoundary()
+	header("Content-Type", `multipart/mixed; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+	buf.WriteString("--" + boundary + "\r\n")
+	m.writeContent(&buf)
+	for _, attachment := range m.Attachments {
+		buf.WriteString("--" + boundary + "\r\n")
+		attachment.write(&buf)
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// writeContent writes the text and html parts of the message.
+func (m *Message) writeContent(buf *bytes.Buffer) {
+	switch {
+	case m.Text != "" && m.HTML != "":
+		var boundary = randomBoundary()
+		buf.WriteString(`Content-Type: multipart/alternative; boundary="` + boundary + "\"\r\n")
+		buf.WriteString("\r\n")
+		buf.WriteString("--" + boundary + "\r\n")
+		writePart(buf, "text/plain", m.Text)
+		buf.WriteString("--" + boundary + "\r\n")
+		writePart(buf, "text/html", m.HTML)
+		buf.WriteString("--" + boundary + "--\r\n")
+	case m.HTML != "":
+		writePart(buf, "text/html", m.HTML)
+	default:
+		writePart(buf, "text/plain", m.Text)
+	}
+}
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// write writes the attachment as a base64 encoded part.
+func (a Attachment) write(buf *bytes.Buffer) {
+	var contentType = a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var filename = mime.QEncoding.Encode("utf-8", a.Filename)
+	buf.WriteString("Content-Type: " + contentType + "; name=\"" + filename + "\"\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"" + filename + "\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	var encoded = base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func writePart(buf *bytes.Buffer, contentType string, body string) {
+	buf.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	var w = quotedprintable.NewWriter(buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+	buf.WriteString("\r\n")
+}
+
+func randomBoundary() string {
+	var b = make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Provider delivers messages, e.g. through SMTP or the API of an email service.
+type Provider interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, message *Message) error
+
+// Send calls f(ctx, message).
+func (f ProviderFunc) Send(ctx context.Context, message *Message) error {
+	return f(ctx, message)
+}
+
+// SMTP delivers messages through an SMTP server. STARTTLS is used when the server supports it.
+// Auth is skipped when Username is empty.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Send delivers the message to the SMTP server.
+func (s SMTP) Send(ctx context.Context, message *Message) error {
+	var recipients = message.Recipients()
+	if len(recipients) == 0 {
+		return ErrNoRecipient
+	}
+	var port = s.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	var done = make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(s.Host, strconv.Itoa(port)), auth, address(message.From), recipients, message.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// address returns the email of an address written as "Name <email>".
+func address(s string) string {
+	if i := strings.LastIndex(s, "<"); i >= 0 {
+		return strings.TrimSuffix(strings.TrimSpace(s[i+1:]), ">")
+	}
+	return strings.TrimSpace(s)
+}
+
+// Default is the provider used to deliver queued messages.
+var Default Provider
+
+// From is the sender of messages which do not set one.
+var From string
+
+// Deliver sends the message immediately using the Default provider, without queueing.
+func Deliver(ctx context.Context, message *Message) error {
+	if Default == nil {
+		return ErrNoProvider
+	}
+	if len(message.Recipients()) == 0 {
+		return ErrNoRecipient
+	}
+	if message.From == "" {
+		message.From = From
+	}
+	if err := Default.Send(ctx, message); err != nil {
+		return fmt.Errorf("unable to send mail to %s: %w", strings.Join(message.To, ", "), err)
+	}
+	return nil
+}
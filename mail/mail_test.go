@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  Message
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "text",
+			message:  Message{From: "a@example.com", To: []string{"b@example.com"}, Subject: "Hello", Text: "body"},
+			contains: []string{"From: a@example.com\r\n", "To: b@example.com\r\n", "Subject: Hello\r\n", "Content-Type: text/plain; charset=utf-8", "body"},
+			excludes: []string{"multipart"},
+		},
+		{
+			name:     "alternative",
+			message:  Message{To: []string{"b@example.com"}, Text: "plain", HTML: "<b>rich</b>"},
+			contains: []string{"multipart/alternative; boundary=", "text/plain", "text/html", "<b>rich</b>"},
+		},
+		{
+			name:     "bcc hidden and encoded subject",
+			message:  Message{To: []string{"b@example.com"}, Bcc: []string{"c@example.com"}, Subject: "Caffè", Text: "x"},
+			contains: []string{"Subject: =?utf-8?q?Caff=C3=A8?="},
+			excludes: []string{"c@example.com"},
+		},
+		{
+			name: "attachment",
+			message: Message{To: []string{"b@example.com"}, Text: "see attached", Attachments: []Attachment{
+				{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")},
+			}},
+			contains: []string{"multipart/mixed; boundary=", "Content-Type: text/plain", "see attached",
+				`Content-Disposition: attachment; filename="report.csv"`, "Content-Transfer-Encoding: base64", "YSxiCjEsMgo="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = string(tt.message.Bytes())
+			for _, c := range tt.contains {
+				if !strings.Contains(s, c) {
+					t.Errorf("message does not contain %q:\n%s", c, s)
+				}
+			}
+			for _, c := range tt.excludes {
+				if strings.Contains(s, c) {
+					t.Errorf("message contains %q:\n%s", c, s)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	var tpl = Template{Name: "welcome", Subject: "Welcome ${name | title}", Text: "Plan: $plan$missing"}
+	message, err := tpl.Render(map[string]interface{}{"name": "mario rossi", "plan": "pro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Subject != "Welcome Mario Rossi" {
+		t.Errorf("subject = %q", message.Subject)
+	}
+	if message.Text != "Plan: pro" {
+		t.Errorf("text = %q", message.Text)
+	}
+	if message.Template != "welcome" {
+		t.Errorf("template = %q", message.Template)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := map[string]string{
+		"a@example.com":               "a@example.com",
+		"Mario Rossi <a@example.com>": "a@example.com",
+		" <a@example.com> ":           "a@example.com",
+	}
+	for in, want := range tests {
+		if got := address(in); got != want {
+			t.Errorf("address(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	if throttled("https://a.example.com/hook") {
+		t.Fatalf("expected the first failure to be notified")
+	}
+	if !throttled("https://a.example.com/hook") {
+		t.Errorf("expected the second failure within AlertInterval to be throttled")
+	}
+	if throttled("https://b.example.com/hook") {
+		t.Errorf("expected the failures of another webhook to be notified")
+	}
+	alerted.at["https://a.example.com/hook"] = time.Now().Add(-AlertInterval)
+	if throttled("https://a.example.com/hook") {
+		t.Errorf("expected a failure after AlertInterval to be notified")
+	}
+}
+
+func TestShutdownTwice(t *testing.T) {
+	if err := (App{}).WhenReady(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := (App{}).Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown() #%d = %v", i+1, err)
+		}
+		cancel()
+	}
+}
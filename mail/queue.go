@@ -0,0 +1,186 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/model"
+	"github.com/iesitalia/toolbox/retry"
+)
+
+// Status of a queued message. Messages are sending while they are delivered.
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// RetryPolicy specifies the attempts of a queued message and the delay between them.
+var RetryPolicy = retry.Policy{
+	Attempts:   5,
+	Delay:      time.Minute,
+	MaxDelay:   time.Hour,
+	Multiplier: 4,
+}
+
+// QueueInterval specifies how often the queue is processed.
+var QueueInterval = 10 * time.Second
+
+// QueueBatchSize specifies the maximum number of messages sent on each run of the queue.
+var QueueBatchSize = 50
+
+// SendTimeout limits the time of a single delivery.
+var SendTimeout = 30 * time.Second
+
+// MaxBacklog specifies the number of due messages above which the service is reported not ready, see health.
+// It is read when the app is registered.
+var MaxBacklog int64 = 1000
+
+// Queued represents a message waiting to be delivered, or the outcome of its delivery.
+type Queued struct {
+	ID          uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Template    string     `gorm:"column:template;size:64" json:"template"`
+	Recipient   string     `gorm:"column:recipient;size:255;index" json:"recipient"`
+	Subject     string     `gorm:"column:subject;size:255" json:"subject"`
+	Message     string     `gorm:"column:message;type:mediumtext" json:"-"`
+	Status      string     `gorm:"column:status;size:16;index:mail_queue_due" json:"status"`
+	Attempts    int        `gorm:"column:attempts" json:"attempts"`
+	Error       string     `gorm:"column:error;size:1024" json:"error"`
+	NextAttempt time.Time  `gorm:"column:next_attempt;index:mail_queue_due" json:"next_attempt"`
+	SentAt      *time.Time `gorm:"column:sent_at" json:"sent_at"`
+	model.CreatedAt
+}
+
+// TableName returns the name of the table for the Queued struct.
+func (Queued) TableName() string {
+	return "mail_queue"
+}
+
+// Enqueue stores the message in the queue, it is delivered by the next run of the queue.
+func Enqueue(message *Message) error {
+	if len(message.Recipients()) == 0 {
+		return ErrNoRecipient
+	}
+	if message.From == "" {
+		message.From = From
+	}
+	b, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	var item = Queued{
+		Template:    message.Template,
+		Recipient:   strings.Join(message.To, ", "),
+		Subject:     message.Subject,
+		Message:     string(b),
+		Status:      StatusPending,
+		NextAttempt: time.Now(),
+	}
+	return db.Create(&item).Error
+}
+
+// Send renders the named template and queues it for the user.
+// The template receives the user as `user` and the entries of data, e.g. $user.FirstName and $plan.
+func Send(name string, user *model.User, data map[string]interface{}) error {
+	if user == nil || user.Email == "" {
+		return ErrNoRecipient
+	}
+	return SendTo(name, []string{user.Email}, user, data)
+}
+
+// SendTo renders the named template and queues it for the given addresses.
+func SendTo(name string, to []string, user *model.User, data map[string]interface{}) error {
+	t, err := GetTemplate(name)
+	if err != nil {
+		return err
+	}
+	var params = map[string]interface{}{}
+	for k, v := range data {
+		params[k] = v
+	}
+	if user != nil {
+		params["user"] = user
+	}
+	message, err := t.Render(params)
+	if err != nil {
+		return err
+	}
+	message.To = to
+	return Enqueue(message)
+}
+
+// ProcessQueue delivers the pending messages which are due.
+// Failed deliveries are rescheduled according to RetryPolicy, then marked as failed.
+func ProcessQueue() error {
+	var items []Queued
+	err := db.Where("status = ? AND next_attempt <= ?", StatusPending, time.Now()).
+		Order("next_attempt ASC").Limit(QueueBatchSize).Find(&items).Error
+	if err != nil {
+		return err
+	}
+	for idx := range items {
+		deliver(&items[idx])
+	}
+	return nil
+}
+
+// deliver sends the queued message. The message is claimed first by marking it as sending, so instances
+// processing the same queue do not send it twice. A delivery timing out may have reached the server, the
+// message is marked as failed rather than retried. Messages left sending by a stopped instance are not retried
+// either, see Retry.
+func deliver(item *Queued) {
+	var claim = db.Model(&Queued{}).Where("id = ? AND status = ?", item.ID, StatusPending).
+		UpdateColumns(map[string]interface{}{"status": StatusSending, "next_attempt": time.Now().Add(SendTimeout)})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+	var message Message
+	var err = json.Unmarshal([]byte(item.Message), &message)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+		err = Deliver(ctx, &message)
+		cancel()
+	}
+	item.Attempts++
+	var now = time.Now()
+	if err == nil {
+		item.Status = StatusSent
+		item.SentAt = &now
+		item.Error = ""
+	} else {
+		item.Error = err.Error()
+		if len(item.Error) > 1024 {
+			item.Error = item.Error[:1024]
+		}
+		if item.Attempts >= RetryPolicy.Attempts || errors.Is(err, context.DeadlineExceeded) {
+			item.Status = StatusFailed
+			logger.Error("unable to send mail", "id", item.ID, "template", item.Template, "recipient", item.Recipient, "error", err.Error())
+		} else {
+			item.Status = StatusPending
+			item.NextAttempt = now.Add(RetryPolicy.Backoff(item.Attempts))
+		}
+	}
+	if err := db.Model(item).Select("status", "attempts", "error", "next_attempt", "sent_at").Updates(item).Error; err != nil {
+		logger.Error("unable to update mail queue", "id", item.ID, "error", err.Error())
+	}
+}
+
+// Backlog returns the number of pending messages which are due.
+func Backlog(ctx context.Context) (int64, error) {
+	var count int64
+	err := db.WithContext(ctx).Model(&Queued{}).Where("status = ? AND next_attempt <= ?", StatusPending, time.Now()).Count(&count).Error
+	return count, err
+}
+
+// Retry reschedules a failed message for an immediate delivery. Messages sending for longer than SendTimeout,
+// the next attempt of a message being sent, were left by a stopped instance and can be retried too.
+func Retry(id uint64) error {
+	return db.Model(&Queued{}).Where("id = ? AND (status = ? OR (status = ? AND next_attempt < ?))", id, StatusFailed, StatusSending, time.Now()).
+		Updates(map[string]interface{}{"status": StatusPending, "attempts": 0, "next_attempt": time.Now()}).Error
+}
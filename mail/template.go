@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/templates"
+)
+
+// Template represents a named email. Subject, Text and HTML are rendered by the templates package.
+// Templates stored in the database override the templates registered in code with the same name,
+// so the content can be edited without redeploying.
+type Template struct {
+	Name    string `gorm:"column:name;size:64;primaryKey" json:"name"`
+	Subject string `gorm:"column:subject;size:255" json:"subject"`
+	Text    string `gorm:"column:text;type:text" json:"text"`
+	HTML    string `gorm:"column:html;type:text" json:"html"`
+}
+
+// TableName returns the name of the table for the Template struct.
+func (Template) TableName() string {
+	return "mail_template"
+}
+
+// Engine renders the templates. Missing keys are rendered empty so a message never leaks a raw variable.
+var Engine = templates.New(templates.MissingEmpty)
+
+var registry = map[string]Template{}
+var mu sync.RWMutex
+
+// RegisterTemplate registers the default content of a named template.
+func RegisterTemplate(name, subject, text, html string) {
+	mu.Lock()
+	registry[name] = Template{Name: name, Subject: subject, Text: text, HTML: html}
+	mu.Unlock()
+}
+
+// GetTemplate returns the template with the given name, looking up the database first.
+func GetTemplate(name string) (Template, error) {
+	var t Template
+	if db.Where("name = ?", name).Take(&t).RowsAffected > 0 {
+		return t, nil
+	}
+	mu.RLock()
+	t, ok := registry[name]
+	mu.RUnlock()
+	if !ok {
+		return t, fmt.Errorf("unknown mail template %q", name)
+	}
+	return t, nil
+}
+
+// Render renders the subject and the bodies of the template into a message.
+func (t Template) Render(params ...interface{}) (*Message, error) {
+	var message = Message{Template: t.Name}
+	var err error
+	if message.Subject, err = Engine.Execute(t.Subject, params...); err != nil {
+		return nil, err
+	}
+	if message.Text, err = Engine.Execute(t.Text, params...); err != nil {
+		return nil, err
+	}
+	if message.HTML, err = Engine.Execute(t.HTML, params...); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
@@ -0,0 +1,92 @@
+package metering
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/logger"
+)
+
+// PREFIX specifies the prefix for metering routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the usage model.
+func (a App) Register() error {
+	db.UseModel(Usage{})
+	return nil
+}
+
+// Router sets up the usage endpoint.
+// GET /metering/usage?from=2024-01-01&to=2024-01-31 returns the usage of the tenant of the request. The users
+// holding acl.Admin choose the tenant with ?tenant=, and get the usage of every tenant without it.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/metering/usage", func(request *evo.Request) interface{} {
+		var user = request.User()
+		if user.Anonymous() {
+			request.Error(ErrorUnauthorized, http.StatusUnauthorized)
+			return nil
+		}
+		var tenant = request.Query("tenant").String()
+		if !user.HasPermission(acl.Admin) {
+			if tenant = TenantResolver(request); tenant == "" {
+				request.Error(acl.ErrorPermissionDenied, http.StatusForbidden)
+				return nil
+			}
+		}
+		usage, err := GetUsage(tenant, request.Query("from").String(), request.Query("to").String())
+		if err != nil {
+			return err
+		}
+		return usage
+	})
+	return nil
+}
+
+// flushStop stops the periodic aggregation, closed once by stopFlush, and flushDone is closed once stopped.
+var flushStop = make(chan struct{})
+var flushDone = make(chan struct{})
+var stopFlush sync.Once
+
+// WhenReady starts the periodic aggregation of the recorded counters.
+func (a App) WhenReady() error {
+	go func() {
+		defer close(flushDone)
+		var ticker = time.NewTicker(FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-flushStop:
+				return
+			case <-ticker.C:
+				if err := Flush(); err != nil {
+					logger.Error("unable to flush usage", "error", err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the periodic aggregation and flushes the counters recorded since the last run, so the
+// usage and its webhooks are not lost.
+func (a App) Shutdown(ctx context.Context) error {
+	stopFlush.Do(func() { close(flushStop) })
+	select {
+	case <-flushDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return Flush()
+}
+
+func (a App) Name() string {
+	return "metering"
+}
@@ -0,0 +1,168 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/circuit"
+	"github.com/iesitalia/toolbox/httpclient"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metric names recorded by the toolbox packages.
+const (
+	APICalls     = "api_calls"
+	RowsExported = "rows_exported"
+	StorageBytes = "storage_bytes"
+)
+
+// ErrorUnauthorized represents an error indicating that the request is not authenticated.
+var ErrorUnauthorized = acl.ErrorUnauthorized
+
+// TenantResolver returns the tenant of a request, set by the rest package to rest.TenantResolver.
+var TenantResolver = func(request *evo.Request) string {
+	return ""
+}
+
+// FlushInterval specifies how often the in-memory counters are aggregated into the database.
+var FlushInterval = time.Minute
+
+// Webhooks is the list of URLs receiving usage events after each flush.
+var Webhooks []string
+
+// WebhookClient is the client sending usage events to the webhooks.
+var WebhookClient = httpclient.New(httpclient.WithTimeout(10 * time.Second))
+
+// OnWebhookFailure is called when usage events can not be delivered to a webhook, e.g. to notify the operators.
+var OnWebhookFailure func(url string, err error)
+
+// MaxUndeliveredEvents bounds the events kept for a webhook while it is unreachable, the oldest are dropped first.
+var MaxUndeliveredEvents = 10000
+
+// undelivered holds the events of each webhook to send again on the next flush.
+var undelivered = map[string][]Event{}
+var flushMu sync.Mutex
+
+// Usage represents the aggregated value of a metric for a tenant in a single day.
+type Usage struct {
+	Tenant    string    `gorm:"column:tenant;size:64;primaryKey" json:"tenant"`
+	Metric    string    `gorm:"column:metric;size:64;primaryKey" json:"metric"`
+	Period    string    `gorm:"column:period;size:10;primaryKey" json:"period"`
+	Value     int64     `gorm:"column:value" json:"value"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName returns the name of the table for the Usage struct.
+func (Usage) TableName() string {
+	return "usage_meter"
+}
+
+// Event represents a usage delta sent to the billing webhooks.
+type Event struct {
+	Tenant string    `json:"tenant"`
+	Metric string    `json:"metric"`
+	Period string    `json:"period"`
+	Delta  int64     `json:"delta"`
+	Time   time.Time `json:"time"`
+}
+
+type counter struct {
+	tenant string
+	metric string
+}
+
+var counters = map[counter]int64{}
+var mu sync.Mutex
+
+// Record adds n to the given metric of the tenant.
+// Values are kept in memory and written to the database on the next flush.
+func Record(tenant string, metric string, n int64) {
+	if n == 0 {
+		return
+	}
+	mu.Lock()
+	counters[counter{tenant: tenant, metric: metric}] += n
+	mu.Unlock()
+}
+
+// Flush aggregates the recorded counters into the database and sends the deltas to the webhooks.
+// Webhooks are called through a circuit breaker named webhook:<url>; events not delivered are
+// sent again on the next flush.
+func Flush() error {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+	mu.Lock()
+	var pending = counters
+	counters = map[counter]int64{}
+	mu.Unlock()
+
+	var now = time.Now()
+	var period = now.Format("2006-01-02")
+	var events []Event
+	for key, delta := range pending {
+		var usage = Usage{Tenant: key.tenant, Metric: key.metric, Period: period, Value: delta, UpdatedAt: now}
+		err := db.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"value":      gorm.Expr("value + ?", delta),
+				"updated_at": now,
+			}),
+		}).Create(&usage).Error
+		if err != nil {
+			// keep the delta for the next flush
+			Record(key.tenant, key.metric, delta)
+			logger.Error("unable to store usage", "tenant", key.tenant, "metric", key.metric, "error", err.Error())
+			continue
+		}
+		events = append(events, Event{Tenant: key.tenant, Metric: key.metric, Period: period, Delta: delta, Time: now})
+	}
+
+	for _, url := range Webhooks {
+		var batch = append(undelivered[url], events...)
+		if len(batch) == 0 {
+			continue
+		}
+		var err = circuit.Get("webhook:" + url).Do(func() error {
+			return WebhookClient.Post(context.Background(), url, batch, nil)
+		})
+		if err == nil {
+			delete(undelivered, url)
+			continue
+		}
+		if len(batch) > MaxUndeliveredEvents {
+			batch = batch[len(batch)-MaxUndeliveredEvents:]
+		}
+		undelivered[url] = batch
+		if errors.Is(err, circuit.ErrorOpen) {
+			continue
+		}
+		logger.Error("unable to send usage webhook", "url", url, "events", len(batch), "error", err.Error())
+		if OnWebhookFailure != nil {
+			OnWebhookFailure(url, err)
+		}
+	}
+	return nil
+}
+
+// GetUsage returns the aggregated usage of the tenant between the given periods (inclusive, YYYY-MM-DD).
+// Empty tenant returns the usage of all tenants, empty from/to leave the range open.
+func GetUsage(tenant string, from string, to string) ([]Usage, error) {
+	var result []Usage
+	var query = db.Order("period ASC")
+	if tenant != "" {
+		query = query.Where("tenant = ?", tenant)
+	}
+	if from != "" {
+		query = query.Where("period >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("period <= ?", to)
+	}
+	return result, query.Find(&result).Error
+}
@@ -0,0 +1,102 @@
+package metering_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/metering"
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+type user struct {
+	evo.DefaultUserInterface
+	admin bool
+}
+
+func (u user) Anonymous() bool {
+	return false
+}
+
+func (u user) HasPermission(permission string) bool {
+	return u.admin
+}
+
+func TestFlush(t *testing.T) {
+	var db = resttest.Setup(t, metering.Usage{})
+	metering.Record("acme", metering.APICalls, 2)
+	metering.Record("acme", metering.APICalls, 3)
+	if err := metering.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	metering.Record("acme", metering.APICalls, 1)
+	if err := metering.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var usage metering.Usage
+	db.Where("tenant = ? AND metric = ?", "acme", metering.APICalls).Take(&usage)
+	if usage.Value != 6 {
+		t.Errorf("usage = %d, want 6", usage.Value)
+	}
+}
+
+func TestUsageScope(t *testing.T) {
+	var db = resttest.Setup(t, metering.Usage{})
+	if err := (metering.App{}).Router(); err != nil {
+		t.Fatal(err)
+	}
+	var member = rest.TenantMember
+	rest.TenantMember = func(request *evo.Request, tenant string) bool {
+		return tenant == "acme"
+	}
+	t.Cleanup(func() {
+		rest.TenantMember = member
+	})
+	db.Create(&[]metering.Usage{
+		{Tenant: "acme", Metric: metering.APICalls, Period: "2024-01-01", Value: 1},
+		{Tenant: "globex", Metric: metering.APICalls, Period: "2024-01-01", Value: 2},
+	})
+
+	var tenants = func(t *testing.T, url string) (int, []string) {
+		t.Helper()
+		status, body := resttest.Request(t, http.MethodGet, url, nil)
+		var response struct {
+			Data []metering.Usage `json:"data"`
+		}
+		_ = json.Unmarshal(body, &response)
+		var result []string
+		for _, item := range response.Data {
+			result = append(result, item.Tenant)
+		}
+		return status, result
+	}
+	t.Run("anonymous", func(t *testing.T) {
+		if status, _ := tenants(t, "/admin/metering/usage"); status != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", status)
+		}
+	})
+	t.Run("no tenant", func(t *testing.T) {
+		resttest.AsUser(t, user{})
+		if status, _ := tenants(t, "/admin/metering/usage?tenant=globex"); status != http.StatusForbidden {
+			t.Errorf("expected 403, got %d", status)
+		}
+	})
+	t.Run("member", func(t *testing.T) {
+		resttest.AsUser(t, user{})
+		resttest.WithHeader(t, rest.TenantHeader, "acme")
+		if status, got := tenants(t, "/admin/metering/usage?tenant=globex"); status != http.StatusOK || len(got) != 1 || got[0] != "acme" {
+			t.Errorf("member of acme got %d %v", status, got)
+		}
+	})
+	t.Run("admin", func(t *testing.T) {
+		resttest.AsUser(t, user{admin: true})
+		if _, got := tenants(t, "/admin/metering/usage?tenant=globex"); len(got) != 1 || got[0] != "globex" {
+			t.Errorf("admin got %v", got)
+		}
+		if _, got := tenants(t, "/admin/metering/usage"); len(got) != 2 {
+			t.Errorf("admin got %v for every tenant", got)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package migration
+
+import (
+	"github.com/getevo/evo/v2/lib/args"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/log"
+	"os"
+)
+
+// RunAtStartup specifies whether pending data migrations are executed when the application is ready.
+// Regardless of this value, they can be executed by starting the application with the --migrate-data switch,
+// in which case the process exits once they are done.
+var RunAtStartup = true
+
+type App struct {
+}
+
+// Register registers the executed data migrations and lock models.
+func (a App) Register() error {
+	db.UseModel(Executed{}, Lock{})
+	return nil
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+// WhenReady executes the pending data migrations.
+func (a App) WhenReady() error {
+	if args.Exists("--migrate-data") {
+		if err := Run(); err != nil {
+			log.Fatalf("data migration failed: %s", err)
+		}
+		os.Exit(0)
+	}
+	if RunAtStartup {
+		return Run()
+	}
+	return nil
+}
+
+func (a App) Name() string {
+	return "migration"
+}
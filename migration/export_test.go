@@ -0,0 +1,17 @@
+package migration
+
+import "testing"
+
+// TryLock exposes tryLock to the tests of the external package.
+var TryLock = tryLock
+
+// Reset replaces the registered migrations for the duration of the test.
+func Reset(t *testing.T, items ...DataMigration) []DataMigration {
+	var previous = migrations
+	t.Cleanup(func() {
+		migrations = previous
+	})
+	migrations = nil
+	Register(items...)
+	return migrations
+}
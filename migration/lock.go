@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// LockTimeout is the lease of the lock taken by Run, it is renewed while the migrations are running so the lock
+// of a crashed instance expires after this time.
+var LockTimeout = time.Minute
+
+// LockRetryInterval specifies how often an instance waiting for the lock tries to take it.
+var LockRetryInterval = time.Second
+
+// Lock represents the lock preventing several instances from running the data migrations at the same time.
+type Lock struct {
+	ID          int        `gorm:"column:id;primaryKey;autoIncrement:false" json:"id"`
+	Owner       string     `gorm:"column:owner;size:64" json:"owner"`
+	LockedUntil *time.Time `gorm:"column:locked_until" json:"locked_until"`
+}
+
+// TableName returns the name of the table for the Lock struct.
+func (Lock) TableName() string {
+	return "data_migration_lock"
+}
+
+// lock is the lock held by an instance.
+type lock struct {
+	owner string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// tryLock takes the lock if it is free or expired and reports whether it succeeded.
+func tryLock(owner string) (bool, error) {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Lock{ID: 1}).Error; err != nil {
+		return false, err
+	}
+	var now = time.Now()
+	var result = db.Model(&Lock{}).Where("id = 1 AND (locked_until IS NULL OR locked_until < ?)", now).
+		Updates(map[string]interface{}{"owner": owner, "locked_until": now.Add(LockTimeout)})
+	return result.RowsAffected == 1, result.Error
+}
+
+// acquire waits for the lock and renews its lease until it is released.
+func acquire() (*lock, error) {
+	var l = &lock{owner: uuid.NewString(), stop: make(chan struct{}), done: make(chan struct{})}
+	for {
+		ok, err := tryLock(l.owner)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		time.Sleep(LockRetryInterval)
+	}
+	go l.renew()
+	return l, nil
+}
+
+// renew extends the lease of the lock until it is released.
+func (l *lock) renew() {
+	defer close(l.done)
+	var ticker = time.NewTicker(LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			db.Model(&Lock{}).Where("id = 1 AND owner = ?", l.owner).Update("locked_until", time.Now().Add(LockTimeout))
+		}
+	}
+}
+
+// release stops renewing the lease and frees the lock.
+func (l *lock) release() error {
+	close(l.stop)
+	<-l.done
+	return db.Model(&Lock{}).Where("id = 1 AND owner = ?", l.owner).Update("locked_until", nil).Error
+}
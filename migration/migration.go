@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/version"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+// DataMigration represents a Go function migrating existing data.
+// - Version: application version the migration belongs to, migrations run ordered by version.
+// - Key: idempotency key, a migration with an already executed key is never run again.
+// - Description: a brief description of the migration.
+// - Run: the function performing the migration.
+type DataMigration struct {
+	Version     string
+	Key         string
+	Description string
+	Run         func(m *Migrator) error
+}
+
+// Executed represents a data migration which has been executed successfully.
+type Executed struct {
+	Key         string    `gorm:"column:key;size:128;primaryKey" json:"key"`
+	Version     string    `gorm:"column:version;size:32" json:"version"`
+	Description string    `gorm:"column:description;size:255" json:"description"`
+	Rows        int64     `gorm:"column:rows" json:"rows"`
+	Duration    string    `gorm:"column:duration;size:32" json:"duration"`
+	ExecutedAt  time.Time `gorm:"column:executed_at" json:"executed_at"`
+}
+
+// TableName returns the name of the table for the Executed struct.
+func (Executed) TableName() string {
+	return "data_migration"
+}
+
+// migrations holds the registered data migrations.
+var migrations []DataMigration
+var mu sync.Mutex
+
+// Register adds data migrations to be executed by Run.
+func Register(items ...DataMigration) {
+	mu.Lock()
+	migrations = append(migrations, items...)
+	mu.Unlock()
+}
+
+// Pending returns the registered migrations which have not been executed yet, ordered by version.
+func Pending() ([]DataMigration, error) {
+	var executed []Executed
+	if err := db.Find(&executed).Error; err != nil {
+		return nil, err
+	}
+	var done = map[string]bool{}
+	for _, item := range executed {
+		done[item.Key] = true
+	}
+
+	mu.Lock()
+	var pending []DataMigration
+	for _, item := range migrations {
+		if !done[item.Key] {
+			pending = append(pending, item)
+		}
+	}
+	mu.Unlock()
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return version.CompareSimple(pending[i].Version, pending[j].Version) < 0
+	})
+	return pending, nil
+}
+
+// Run executes the pending data migrations in order.
+// It stops at the first failing migration, the following ones will be tried again on the next run.
+// Instances running it at the same time wait for each other through Lock, so every migration runs once.
+func Run() (err error) {
+	l, err := acquire()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := l.release(); err == nil {
+			err = e
+		}
+	}()
+	pending, err := Pending()
+	if err != nil {
+		return err
+	}
+	for _, item := range pending {
+		if item.Key == "" || item.Run == nil {
+			return fmt.Errorf("invalid data migration %s", item.Description)
+		}
+		var m = &Migrator{Migration: item, DB: db.Session(&gorm.Session{})}
+		logger.Info("running data migration", "key", item.Key, "version", item.Version)
+		var start = time.Now()
+		if err := item.Run(m); err != nil {
+			return fmt.Errorf("data migration %s failed: %s", item.Key, err)
+		}
+		var record = Executed{
+			Key:         item.Key,
+			Version:     item.Version,
+			Description: item.Description,
+			Rows:        m.processed,
+			Duration:    time.Since(start).String(),
+			ExecutedAt:  time.Now(),
+		}
+		if err := db.Create(&record).Error; err != nil {
+			return err
+		}
+		logger.Info("data migration done", "key", item.Key, "rows", m.processed, "duration", record.Duration)
+	}
+	return nil
+}
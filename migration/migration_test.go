@@ -0,0 +1,103 @@
+package migration_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/migration"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestRun(t *testing.T) {
+	var db = resttest.Setup(t, migration.Executed{}, migration.Lock{})
+	var order []string
+	var step = func(key string, err error) func(m *migration.Migrator) error {
+		return func(m *migration.Migrator) error {
+			order = append(order, key)
+			m.Progress(1)
+			return err
+		}
+	}
+	var registered = migration.Reset(t,
+		migration.DataMigration{Version: "1.10.0", Key: "third", Run: step("third", nil)},
+		migration.DataMigration{Version: "1.2.0", Key: "second", Run: step("second", errors.New("boom"))},
+		migration.DataMigration{Version: "1.1.0", Key: "first", Run: step("first", nil)},
+	)
+
+	if err := migration.Run(); err == nil {
+		t.Fatal("Run() error = nil, want the error of the failing migration")
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("order = %v, want [first second]", order)
+	}
+
+	registered[1].Run = step("second", nil)
+	order = nil
+	if err := migration.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "second" || order[1] != "third" {
+		t.Errorf("order of the second run = %v, want [second third]", order)
+	}
+	var executed []migration.Executed
+	db.Order("version").Find(&executed)
+	if len(executed) != 3 || executed[0].Rows != 1 {
+		t.Errorf("executed = %+v", executed)
+	}
+
+	var lock migration.Lock
+	db.First(&lock, 1)
+	if lock.LockedUntil != nil {
+		t.Errorf("lock not released, locked until %v", lock.LockedUntil)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	resttest.Setup(t, migration.Executed{}, migration.Lock{})
+	var interval = migration.LockRetryInterval
+	migration.LockRetryInterval = 10 * time.Millisecond
+	t.Cleanup(func() { migration.LockRetryInterval = interval })
+	var runs atomic.Int32
+	migration.Reset(t, migration.DataMigration{Version: "1.0.0", Key: "once", Run: func(m *migration.Migrator) error {
+		runs.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}})
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- migration.Run()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("migration ran %d times, want once", n)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	var db = resttest.Setup(t, migration.Lock{})
+	if ok, err := migration.TryLock("a"); !ok || err != nil {
+		t.Fatalf("tryLock(a) = %v, %v", ok, err)
+	}
+	if ok, _ := migration.TryLock("b"); ok {
+		t.Error("tryLock(b) took a held lock")
+	}
+	db.Model(&migration.Lock{}).Where("id = 1").Update("locked_until", time.Now().Add(-time.Second))
+	if ok, err := migration.TryLock("b"); !ok || err != nil {
+		t.Errorf("tryLock(b) of an expired lock = %v, %v", ok, err)
+	}
+}
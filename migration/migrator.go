@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+// Migrator is passed to a running data migration.
+// It gives access to the database and provides batching and progress reporting helpers.
+type Migrator struct {
+	Migration DataMigration
+	DB        *gorm.DB
+	processed int64
+	total     int64
+}
+
+// SetTotal sets the expected number of rows used in progress reports.
+func (m *Migrator) SetTotal(total int64) {
+	m.total = total
+}
+
+// Progress adds n to the number of processed rows and logs the progress of the migration.
+func (m *Migrator) Progress(n int64) {
+	m.processed += n
+	if m.total > 0 {
+		logger.Info("data migration progress", "key", m.Migration.Key, "processed", m.processed, "total", m.total, "percent", m.processed*100/m.total)
+	} else {
+		logger.Info("data migration progress", "key", m.Migration.Key, "processed", m.processed)
+	}
+}
+
+// Processed returns the number of rows processed so far.
+func (m *Migrator) Processed() int64 {
+	return m.processed
+}
+
+// Batch loads the rows of query into dest by batches of the given size and calls fn for each batch.
+// The total is counted before starting and progress is reported after every batch.
+//
+// Example:
+//
+//	var users []User
+//	err := m.Batch(m.DB.Model(&User{}).Where("slug = ''"), &users, 500, func(tx *gorm.DB, batch int) error {
+//	    for i := range users {
+//	        users[i].Slug = toolbox.Slugify(users[i].Name)
+//	    }
+//	    return tx.Save(&users).Error
+//	})
+func (m *Migrator) Batch(query *gorm.DB, dest interface{}, size int, fn func(tx *gorm.DB, batch int) error) error {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err == nil {
+		m.SetTotal(total)
+	}
+	return query.FindInBatches(dest, size, func(tx *gorm.DB, batch int) error {
+		if err := fn(tx, batch); err != nil {
+			return err
+		}
+		m.Progress(tx.RowsAffected)
+		return nil
+	}).Error
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// GeocodeTimeout specifies how long the geocoder may take to locate an address.
+var GeocodeTimeout = 5 * time.Second
+
+// EarthRadius is the mean radius of the earth in kilometers used by the near filter.
+const EarthRadius = 6371.0
+
+// Geocoder returns the coordinates of an address.
+type Geocoder interface {
+	Geocode(ctx context.Context, address Address) (lat float64, lng float64, err error)
+}
+
+// GeocoderFunc is an adapter allowing the use of ordinary functions as geocoders.
+type GeocoderFunc func(ctx context.Context, address Address) (float64, float64, error)
+
+// Geocode calls f(ctx, address).
+func (f GeocoderFunc) Geocode(ctx context.Context, address Address) (float64, float64, error) {
+	return f(ctx, address)
+}
+
+// DefaultGeocoder locates the addresses when they are created or changed, addresses are not geocoded when nil.
+var DefaultGeocoder Geocoder
+
+// Address is a postal address with its coordinates, embedded in models directly or with a prefix:
+//
+//	type Store struct {
+//		ID uint64 `gorm:"primaryKey"`
+//		model.Address
+//		Billing model.Address `gorm:"embedded;embeddedPrefix:billing_" json:"billing"`
+//		rest.API
+//	}
+//
+// The coordinates are set by the DefaultGeocoder when the address changes, and list endpoints filter the rows
+// within a radius in kilometers with lat[near]=<lat>,<lng>,<km>, e.g. billing_lat[near]=45.46,9.19,10.
+type Address struct {
+	Street     string  `gorm:"column:street;size:255" json:"street"`
+	City       string  `gorm:"column:city;size:128" json:"city"`
+	PostalCode string  `gorm:"column:postal_code;size:16" json:"postal_code"`
+	Province   string  `gorm:"column:province;size:64" json:"province"`
+	Country    string  `gorm:"column:country;size:2" json:"country"`
+	Lat        float64 `gorm:"column:lat;index" json:"lat"`
+	Lng        float64 `gorm:"column:lng" json:"lng"`
+	Geocoded   string  `gorm:"column:geocoded;size:40" json:"-"`
+}
+
+func init() {
+	rest.RegisterOperator("near", nearFilter)
+}
+
+// Line returns the address on a single line, e.g. "Via Roma 1, 00184 Roma RM, IT".
+func (a Address) Line() string {
+	var parts []string
+	var locality = strings.TrimSpace(strings.Join([]string{a.PostalCode, a.City, a.Province}, " "))
+	for _, part := range []string{a.Street, strings.Join(strings.Fields(locality), " "), a.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsEmpty reports whether the address has no street, city or postal code.
+func (a Address) IsEmpty() bool {
+	return strings.TrimSpace(a.Street+a.City+a.PostalCode) == ""
+}
+
+// hash identifies the address the coordinates were found for.
+func (a Address) hash() string {
+	var sum = sha1.Sum([]byte(strings.ToLower(a.Line())))
+	return hex.EncodeToString(sum[:])
+}
+
+// OnCreate geocodes the address of the created row.
+func (a *Address) OnCreate(db *gorm.DB, object reflect.Value) {
+	a.geocode(db, object)
+}
+
+// OnUpdate geocodes the address of the updated row if it has changed.
+func (a *Address) OnUpdate(db *gorm.DB, object reflect.Value) {
+	a.geocode(db, object)
+}
+
+// geocode sets the coordinates of the address using the DefaultGeocoder and stores them in the row.
+// Failures are logged and leave the coordinates unchanged.
+func (a *Address) geocode(db *gorm.DB, object reflect.Value) {
+	if DefaultGeocoder == nil || a.IsEmpty() || a.Geocoded == a.hash() || !object.CanAddr() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(db.Statement.Context, GeocodeTimeout)
+	defer cancel()
+	lat, lng, err := DefaultGeocoder.Geocode(ctx, *a)
+	if err != nil {
+		logger.Error("unable to geocode address", "table", db.Statement.Table, "address", a.Line(), "error", err.Error())
+		return
+	}
+	a.Lat, a.Lng, a.Geocoded = lat, lng, a.hash()
+	var columns = addressColumns(db, object, a)
+	if columns == nil {
+		return
+	}
+	err = db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Model(object.Addr().Interface()).UpdateColumns(map[string]interface{}{
+		columns["Lat"]:      a.Lat,
+		columns["Lng"]:      a.Lng,
+		columns["Geocoded"]: a.Geocoded,
+	}).Error
+	if err != nil {
+		logger.Error("unable to store address coordinates", "table", db.Statement.Table, "error", err.Error())
+	}
+}
+
+// addressColumns returns the columns of the fields of the address embedded in the object, keyed by field name.
+func addressColumns(db *gorm.DB, object reflect.Value, a *Address) map[string]string {
+	var name string
+	for i := 0; i < object.NumField(); i++ {
+		if f := object.Field(i); f.CanAddr() && f.Addr().Interface() == a {
+			name = object.Type().Field(i).Name
+			break
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	var columns = map[string]string{}
+	for _, field := range db.Statement.Schema.Fields {
+		if len(field.BindNames) == 2 && field.BindNames[0] == name {
+			columns[field.Name] = field.DBName
+		}
+	}
+	return columns
+}
+
+// nearCondition returns the condition selecting the rows within the radius of the point given as <lat>,<lng>,<km>.
+// The column is the latitude of the address, the longitude is the column with the same prefix.
+func nearCondition(column string, value string) (string, []interface{}, error) {
+	var parts = strings.Split(value, ",")
+	if len(parts) != 3 || !strings.HasSuffix(column, "lat") {
+		return "", nil, fmt.Errorf("invalid near filter %s=%s", column, value)
+	}
+	var point [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid near filter %s=%s", column, value)
+		}
+		point[i] = v
+	}
+	var lat, lng, radius = point[0], point[1], point[2]
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 || radius <= 0 {
+		return "", nil, fmt.Errorf("invalid near filter %s=%s", column, value)
+	}
+	var latColumn = "`" + column + "`"
+	var lngColumn = "`" + strings.TrimSuffix(column, "lat") + "lng`"
+	// the bounding box on the latitude lets the database use the index before computing the distance
+	var delta = radius / EarthRadius * 180 / math.Pi
+	var condition = fmt.Sprintf("%s BETWEEN ? AND ? AND %.1f * ACOS(LEAST(1, COS(RADIANS(?)) * COS(RADIANS(%s)) * COS(RADIANS(%s) - RADIANS(?)) + SIN(RADIANS(?)) * SIN(RADIANS(%s)))) <= ?",
+		latColumn, EarthRadius, latColumn, lngColumn, latColumn)
+	return condition, []interface{}{lat - delta, lat + delta, lat, lng, lat, radius}, nil
+}
+
+// nearFilter is the near filter operator of the list endpoints.
+func nearFilter(context *rest.Context, query *gorm.DB, column string, value string) (*gorm.DB, error) {
+	condition, args, err := nearCondition(column, value)
+	if err != nil {
+		return query, err
+	}
+	return query.Where(condition, args...), nil
+}
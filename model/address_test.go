@@ -0,0 +1,60 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressLine(t *testing.T) {
+	var tests = []struct {
+		address Address
+		want    string
+	}{
+		{Address{Street: "Via Roma 1", City: "Roma", PostalCode: "00184", Province: "RM", Country: "IT"}, "Via Roma 1, 00184 Roma RM, IT"},
+		{Address{City: "Milano", Country: "IT"}, "Milano, IT"},
+		{Address{}, ""},
+	}
+	for _, test := range tests {
+		if got := test.address.Line(); got != test.want {
+			t.Errorf("Line() = %q, want %q", got, test.want)
+		}
+	}
+	var a = Address{Street: "Via Roma 1", City: "Roma"}
+	var b = Address{Street: "VIA ROMA 1", City: "roma", Lat: 41.9}
+	if a.hash() != b.hash() {
+		t.Error("hash should ignore case and coordinates")
+	}
+	if b.City = "Milano"; a.hash() == b.hash() {
+		t.Error("hash should change with the address")
+	}
+}
+
+func TestNearCondition(t *testing.T) {
+	var tests = []struct {
+		column string
+		value  string
+		lng    string
+		ok     bool
+	}{
+		{"lat", "45.46,9.19,10", "`lng`", true},
+		{"billing_lat", "-33.86, 151.2, 0.5", "`billing_lng`", true},
+		{"lat", "45.46,9.19", "", false},
+		{"lat", "95,9.19,10", "", false},
+		{"lat", "45.46,9.19,0", "", false},
+		{"city", "45.46,9.19,10", "", false},
+	}
+	for _, test := range tests {
+		condition, args, err := nearCondition(test.column, test.value)
+		if (err == nil) != test.ok {
+			t.Errorf("nearCondition(%q, %q) error = %v, want ok %v", test.column, test.value, err, test.ok)
+			continue
+		}
+		if err == nil && (!strings.Contains(condition, test.lng) || len(args) != strings.Count(condition, "?")) {
+			t.Errorf("nearCondition(%q, %q) = %q, %v", test.column, test.value, condition, args)
+		}
+	}
+	_, args, _ := nearCondition("lat", "45,9,111.19")
+	if lower := args[0].(float64); lower < 43.99 || lower > 44.01 {
+		t.Errorf("bounding box of 111.19 km = %v, want about one degree", args[:2])
+	}
+}
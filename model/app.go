@@ -1,8 +1,13 @@
 package model
 
 import (
+	"time"
+
 	"github.com/getevo/evo/v2"
 	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/migration"
+	"github.com/iesitalia/toolbox/rest"
 )
 
 type App struct {
@@ -11,30 +16,78 @@ type App struct {
 // Register registers all the resources and sets up the router for the application.
 // For each model in `schema.Models`, it attaches a resource using the `AttachResource` method.
 func (a App) Register() error {
-	db.UseModel(TagEntity{}, TagList{})
+	db.UseModel(TagEntity{}, TagList{}, TagAudit{}, Comment{})
+	migration.Register(tagEntityMigration)
+	rest.AddReadHook(publishedRead)
+	rest.AddCreateHook(publishedCreate)
+	rest.AddUpdateHook(rest.UpdateHook{Before: treeBeforeUpdate})
 
 	var callback Callback
 	var dbo = evo.GetDBO()
-	err := dbo.Callback().Create().After("*").Register("tag:create", callback.OnModify)
+	// callbacks run after the commit are registered after gorm:commit_or_rollback_transaction rather than "*",
+	// gorm sorts "*" callbacks with an unstable sort reordering its own ones once a processor holds more than 12
+	err := dbo.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("tag:create", callback.OnModify)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("tag:update", callback.OnModify)
 	if err != nil {
 		panic(err)
 	}
-	err = dbo.Callback().Update().After("*").Register("tag:update", callback.OnModify)
+	err = dbo.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("tag:delete", callback.OnModify)
 	if err != nil {
 		panic(err)
 	}
-	err = dbo.Callback().Delete().After("*").Register("tag:delete", callback.OnModify)
+	err = registerTreeCallbacks(dbo)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("rollup:create", callback.OnRollup)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Update().Before("gorm:update").Register("rollup:update:parents", callback.OnRollupParents)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("rollup:update", callback.OnRollup)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Delete().Before("gorm:delete").Register("rollup:delete:parents", callback.OnRollupParents)
+	if err != nil {
+		panic(err)
+	}
+	err = dbo.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("rollup:delete", callback.OnRollup)
 	if err != nil {
 		panic(err)
 	}
 	return nil
 }
 
+// Router registers the tag endpoints spanning every resource of the models embedding Tag.
 func (a App) Router() error {
+	evo.Get(rest.PREFIX+"/tags", Tags)
+	evo.Get(rest.PREFIX+"/tags/autocomplete", TagAutocomplete)
+	evo.Post(rest.PREFIX+"/tags/rename", RenameTag)
+	evo.Get(rest.PREFIX+"/tags/:key/entities", TaggedEntities)
 	return nil
 }
 
+// WhenReady resolves the rollups declared by the registered models and starts their periodic reconciliation.
 func (a App) WhenReady() error {
+	if err := LoadRollups(); err != nil {
+		return err
+	}
+	if len(GetRollups()) > 0 {
+		go func() {
+			for range time.Tick(RollupReconcileInterval) {
+				if err := ReconcileRollups(); err != nil {
+					logger.Error("unable to reconcile rollups", "error", err.Error())
+				}
+			}
+		}()
+	}
 	return nil
 }
 
@@ -1,8 +1,14 @@
 package model
 
 import (
-	"gorm.io/gorm"
+	"context"
 	"reflect"
+
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/observability"
+	"github.com/iesitalia/toolbox/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 // Callback represents a callback function that can be registered to be executed
@@ -10,38 +16,126 @@ import (
 type Callback struct {
 }
 
+// ModelEventMaxRows bounds the number of rows loaded to dispatch the events of the updates by condition.
+var ModelEventMaxRows = 1000
+
+// Operations of the model events.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// modelEvents counts the model events dispatched by OnModify.
+var modelEvents = observability.NewCounter("model_events_total",
+	"Model events dispatched to the models.", "table", "operation")
+
+// ModelEvent is a write of a row dispatched to the OnModelEvent(event *ModelEvent) methods of the types
+// embedded in the model.
+// - Operation: create, update or delete.
+// - Tx: the statement of the write, running in its transaction if any.
+// - Object: the addressable written row.
+// - RowsAffected: the number of rows written by the statement.
+type ModelEvent struct {
+	Operation    string
+	Tx           *gorm.DB
+	Object       reflect.Value
+	RowsAffected int64
+}
+
+// ModelEventHandler is implemented by the types embedded in models observing the writes of their rows.
+type ModelEventHandler interface {
+	OnModelEvent(event *ModelEvent)
+}
+
+// operations maps the first clause of the statements to the operation and the legacy method of the events.
+var operations = map[string][2]string{
+	"INSERT": {OperationCreate, "OnCreate"},
+	"UPDATE": {OperationUpdate, "OnUpdate"},
+	"DELETE": {OperationDelete, "OnDelete"},
+}
+
 // OnModify is a callback method that is triggered after a modify operation (insert, update, delete) on the database.
 // It checks if the database operation was successful and if the schema is not nil.
-// If the schema represents a struct, it determines the action based on the build clauses.
-// It then calls the corresponding "OnCreate", "OnUpdate", or "OnDelete" method on each field of the struct that has the action as a method.
-// The method is called with two parameters: the db object and the reflect value of the schema.
+// It determines the action based on the build clauses and collects the written rows: the struct of the statement,
+// the elements of a slice for batch operations, or, for updates by condition such as Updates with a map on an
+// empty model, the rows matching the condition loaded after the update, up to ModelEventMaxRows.
+// It then calls the corresponding "OnCreate", "OnUpdate", or "OnDelete" method on each field of each row that has
+// the action as a method, with the db object and the reflect value of the row, and the OnModelEvent method of the
+// fields implementing ModelEventHandler.
+// Deletes by condition are not dispatched as their rows are gone. The events are counted by the model_events_total
+// metric and traced as a child span of the request writing them, see observability.
 func (c Callback) OnModify(db *gorm.DB) {
-	if db.Error == nil && db.Statement.Schema != nil {
-		if db.Statement.ReflectValue.Kind() == reflect.Struct {
-			var action = ""
-			switch db.Statement.BuildClauses[0] {
-			case "INSERT":
-				action = "OnCreate"
-			case "UPDATE":
-				action = "OnUpdate"
-			case "DELETE":
-				action = "OnDelete"
+	if db.Error != nil || db.Statement.Schema == nil || len(db.Statement.BuildClauses) == 0 {
+		return
+	}
+	operation, ok := operations[db.Statement.BuildClauses[0]]
+	if !ok {
+		return
+	}
+	var objects = modifiedObjects(db.Statement.ReflectValue)
+	if operation[0] == OperationUpdate && len(objects) == 1 && !primaryKeySet(db.Statement.Schema, objects[0]) {
+		objects = affectedRows(db)
+	}
+	var span = tracing.FromContext(db.Statement.Context).Child("model events")
+	defer span.Finish(nil, "table", db.Statement.Table, "operation", operation[0], "rows", len(objects))
+	for _, object := range objects {
+		modelEvents.Inc(db.Statement.Table, operation[0])
+		var event = ModelEvent{Operation: operation[0], Tx: db, Object: object, RowsAffected: db.Statement.RowsAffected}
+		for i := 0; i < object.NumField(); i++ {
+			var ref = object.Field(i)
+			if !ref.CanAddr() {
+				continue
+			}
+			if m := ref.Addr().MethodByName(operation[1]); m.IsValid() {
+				m.Call([]reflect.Value{reflect.ValueOf(db), reflect.ValueOf(object)})
 			}
-			if action == "" {
-				return
+			if handler, ok := ref.Addr().Interface().(ModelEventHandler); ok {
+				handler.OnModelEvent(&event)
 			}
-			for i := 0; i < db.Statement.ReflectValue.NumField(); i++ {
-				var ref = db.Statement.ReflectValue.Field(i)
-				if ref.CanAddr() {
-					var m = ref.Addr().MethodByName(action)
-					if m.IsValid() {
-						m.Call([]reflect.Value{reflect.ValueOf(db), reflect.ValueOf(db.Statement.ReflectValue)})
-					}
-				}
+		}
+	}
+}
 
+// modifiedObjects returns the structs written by a statement: the struct itself or the elements of a slice.
+func modifiedObjects(value reflect.Value) []reflect.Value {
+	var result []reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		result = append(result, value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if item := reflect.Indirect(value.Index(i)); item.Kind() == reflect.Struct {
+				result = append(result, item)
 			}
+		}
+	}
+	return result
+}
 
+// primaryKeySet reports whether a primary key of the row is set.
+func primaryKeySet(s *schema.Schema, object reflect.Value) bool {
+	for _, field := range s.PrimaryFields {
+		if _, zero := field.ValueOf(context.Background(), object); !zero {
+			return true
 		}
+	}
+	return false
+}
 
+// affectedRows loads the rows matching the condition of an update. Rows whose update changed the columns of
+// the condition are not found.
+func affectedRows(db *gorm.DB) []reflect.Value {
+	where, ok := db.Statement.Clauses["WHERE"]
+	if !ok || db.Statement.RowsAffected == 0 {
+		return nil
+	}
+	var slice = reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	var tx = db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Table).
+		Clauses(where.Expression).Limit(ModelEventMaxRows)
+	if err := tx.Find(slice.Interface()).Error; err != nil {
+		logger.Error("unable to load updated rows", "table", db.Statement.Table, "error", err.Error())
+		return nil
 	}
+	return modifiedObjects(slice.Elem())
 }
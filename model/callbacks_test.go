@@ -0,0 +1,51 @@
+package model
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestModifiedObjects(t *testing.T) {
+	var row = tagIntRow{ID: 1}
+	var rows = []tagIntRow{{ID: 1}, {ID: 2}}
+	var pointers = []*tagIntRow{{ID: 1}, nil, {ID: 3}}
+	var tests = []struct {
+		value reflect.Value
+		want  int
+	}{
+		{reflect.ValueOf(&row).Elem(), 1},
+		{reflect.ValueOf(rows), 2},
+		{reflect.ValueOf(pointers), 2},
+		{reflect.ValueOf(map[string]interface{}{"id": 1}), 0},
+	}
+	for _, test := range tests {
+		if got := modifiedObjects(test.value); len(got) != test.want {
+			t.Errorf("modifiedObjects(%v) = %d objects, want %d", test.value.Type(), len(got), test.want)
+		}
+	}
+}
+
+func TestPrimaryKeySet(t *testing.T) {
+	var tests = []struct {
+		object interface{}
+		want   bool
+	}{
+		{&tagIntRow{ID: 3}, true},
+		{&tagIntRow{}, false},
+		{&tagUUIDRow{UUID: "a"}, true},
+		{&tagCompositeRow{Tenant: "acme"}, true},
+		{&tagCompositeRow{}, false},
+	}
+	for _, test := range tests {
+		s, err := schema.Parse(test.object, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := primaryKeySet(s, reflect.ValueOf(test.object).Elem()); got != test.want {
+			t.Errorf("primaryKeySet(%+v) = %v, want %v", test.object, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// MaxCommentLength bounds the length of the body of a comment.
+var MaxCommentLength = 10000
+
+// ErrorEmptyComment is returned when a comment has no body.
+var ErrorEmptyComment = errors.New("comment is empty")
+
+// ErrorCommentTooLong is returned when the body of a comment is longer than MaxCommentLength.
+var ErrorCommentTooLong = errors.New("comment is too long")
+
+// ErrorInvalidParent is returned when a reply refers to a comment of another row.
+var ErrorInvalidParent = errors.New("parent comment not found")
+
+// CommentPermission allows to comment the rows of a resource and to edit or delete the own comments.
+var CommentPermission = acl.Permission{
+	Key:         "COMMENT",
+	Name:        "Comment",
+	Description: "Comment items",
+}
+
+// Comment is a comment on a row of a rest resource, identified by its table and primary key.
+// Replies refer to the comment they answer with ParentID. Deleted comments are kept, without body,
+// so the replies stay in their thread.
+type Comment struct {
+	ID         uint64         `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OwnerTable string         `gorm:"column:owner_table;size:64;index:comment_owner_idx" json:"owner_table"`
+	OwnerID    string         `gorm:"column:owner_id;size:64;index:comment_owner_idx" json:"owner_id"`
+	ParentID   *uint64        `gorm:"column:parent_id" json:"parent_id"`
+	Author     string         `gorm:"column:author;size:36" json:"author"`
+	Body       string         `gorm:"column:body;type:text" json:"body"`
+	CreatedAt  time.Time      `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`
+}
+
+// TableName returns the name of the table of the Comment struct.
+func (Comment) TableName() string {
+	return "comment"
+}
+
+// CommentRequest is the body of the endpoints adding and editing a comment.
+type CommentRequest struct {
+	Body     string  `json:"body"`
+	ParentID *uint64 `json:"parent_id"`
+}
+
+// Commentable adds discussion endpoints to the resources of the models embedding it. Permissions are those
+// of the acl App of the resource: VIEW to read the comments, COMMENT to add them and to edit or delete the own
+// ones, UPDATE to delete the comments of the other users.
+// GET /rest/:table/comments/:pk lists the comments of the row in thread order.
+// POST /rest/:table/comments/:pk {"body": "...", "parent_id": 1} adds a comment, a reply when parent_id is set.
+// PUT /rest/:table/comments/:pk/:comment {"body": "..."} edits a comment of the user.
+// DELETE /rest/:table/comments/:pk/:comment deletes a comment.
+type Commentable struct{}
+
+// RESTActions registers the comment endpoints on the resources of models embedding Commentable.
+func (Commentable) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "COMMENTS",
+			Method:      rest.GET,
+			URL:         "/comments",
+			PKUrl:       true,
+			Handler:     ListComments,
+			Description: "list the comments of the row",
+			Permissions: []acl.Permission{rest.ListPermission},
+		},
+		{
+			Name:        "ADD_COMMENT",
+			Method:      rest.POST,
+			URL:         "/comments",
+			PKUrl:       true,
+			Handler:     AddComment,
+			Description: "comment the row or reply to a comment",
+			Permissions: []acl.Permission{CommentPermission},
+		},
+		{
+			Name:        "EDIT_COMMENT",
+			Method:      rest.PUT,
+			URL:         "/comments",
+			PKUrl:       true,
+			URLParams:   []rest.Filter{{Name: "comment"}},
+			Handler:     EditComment,
+			Description: "edit a comment of the user",
+			Permissions: []acl.Permission{CommentPermission},
+		},
+		{
+			Name:        "DELETE_COMMENT",
+			Method:      rest.DELETE,
+			URL:         "/comments",
+			PKUrl:       true,
+			URLParams:   []rest.Filter{{Name: "comment"}},
+			Handler:     DeleteComment,
+			Description: "delete a comment",
+			Permissions: []acl.Permission{CommentPermission},
+		},
+	}
+}
+
+// commentOwner returns the primary key of the row given by the request, checking it exists and is visible to the user.
+func commentOwner(context *rest.Context) (string, error) {
+	var object = context.GetObject()
+	found, err := context.FindByPrimaryKey(object.Addr().Interface())
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", rest.ErrorObjectNotExist
+	}
+	var keys []string
+	for _, field := range context.Schema.PrimaryFields {
+		v, _ := field.ValueOf(context.Request.Context.Context(), reflect.Indirect(object))
+		keys = append(keys, fmt.Sprint(v))
+	}
+	return strings.Join(keys, ","), nil
+}
+
+// commentOf returns the comment given by the request among the comments of the row.
+func commentOf(context *rest.Context, owner string) (*Comment, error) {
+	var comment Comment
+	if db.Where("id = ? AND owner_table = ? AND owner_id = ?", context.Request.Param("comment").Uint64(), context.Schema.Table, owner).
+		Take(&comment).RowsAffected == 0 {
+		return nil, rest.ErrorObjectNotExist
+	}
+	return &comment, nil
+}
+
+// validateComment trims the body and checks its length.
+func validateComment(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrorEmptyComment
+	}
+	if len([]rune(body)) > MaxCommentLength {
+		return "", ErrorCommentTooLong
+	}
+	return body, nil
+}
+
+// threadOrder sorts the comments so that each reply follows its parent, the threads and the replies of a
+// comment by creation. Replies to missing comments are kept at the end.
+func threadOrder(comments []Comment) []Comment {
+	var children = map[uint64][]Comment{}
+	var ids = map[uint64]bool{}
+	for _, comment := range comments {
+		ids[comment.ID] = true
+	}
+	var roots, orphans []Comment
+	for _, comment := range comments {
+		switch {
+		case comment.ParentID == nil:
+			roots = append(roots, comment)
+		case ids[*comment.ParentID]:
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+		default:
+			orphans = append(orphans, comment)
+		}
+	}
+	var result = make([]Comment, 0, len(comments))
+	var walk func(list []Comment)
+	walk = func(list []Comment) {
+		for _, comment := range list {
+			result = append(result, comment)
+			walk(children[comment.ID])
+		}
+	}
+	walk(roots)
+	return append(result, orphans...)
+}
+
+// ListComments lists the comments of the row given by the primary key of the request, in thread order.
+// Deleted comments are returned without body.
+func ListComments(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	owner, err := commentOwner(context)
+	if err != nil {
+		return err
+	}
+	var comments []Comment
+	if err := db.Unscoped().Where("owner_table = ? AND owner_id = ?", context.Schema.Table, owner).Order("id").Find(&comments).Error; err != nil {
+		return err
+	}
+	for i := range comments {
+		if comments[i].DeletedAt.Valid {
+			comments[i].Body = ""
+		}
+	}
+	context.Response.Data = threadOrder(comments)
+	return nil
+}
+
+// AddComment adds a comment of the user to the row given by the primary key of the request.
+func AddComment(context *rest.Context) error {
+	if context.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	if err := context.HasPerm("COMMENT"); err != nil {
+		return err
+	}
+	owner, err := commentOwner(context)
+	if err != nil {
+		return err
+	}
+	var body CommentRequest
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	var comment = Comment{
+		OwnerTable: context.Schema.Table,
+		OwnerID:    owner,
+		ParentID:   body.ParentID,
+		Author:     context.User().UUID(),
+	}
+	if comment.Body, err = validateComment(body.Body); err != nil {
+		return err
+	}
+	if body.ParentID != nil {
+		var count int64
+		db.Unscoped().Model(&Comment{}).Where("id = ? AND owner_table = ? AND owner_id = ?", *body.ParentID, comment.OwnerTable, owner).Count(&count)
+		if count == 0 {
+			return ErrorInvalidParent
+		}
+	}
+	if err := db.Create(&comment).Error; err != nil {
+		return err
+	}
+	context.Response.Data = &comment
+	return nil
+}
+
+// EditComment changes the body of a comment of the user.
+func EditComment(context *rest.Context) error {
+	if context.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	if err := context.HasPerm("COMMENT"); err != nil {
+		return err
+	}
+	owner, err := commentOwner(context)
+	if err != nil {
+		return err
+	}
+	comment, err := commentOf(context, owner)
+	if err != nil {
+		return err
+	}
+	if comment.Author != context.User().UUID() {
+		return rest.ErrorPermissionDenied
+	}
+	var body CommentRequest
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	if comment.Body, err = validateComment(body.Body); err != nil {
+		return err
+	}
+	if err := db.Model(comment).Update("body", comment.Body).Error; err != nil {
+		return err
+	}
+	context.Response.Data = comment
+	return nil
+}
+
+// DeleteComment deletes a comment of the user, or of any user with the UPDATE permission of the resource.
+func DeleteComment(context *rest.Context) error {
+	if context.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	if err := context.HasPerm("COMMENT"); err != nil {
+		return err
+	}
+	owner, err := commentOwner(context)
+	if err != nil {
+		return err
+	}
+	comment, err := commentOf(context, owner)
+	if err != nil {
+		return err
+	}
+	if comment.Author != context.User().UUID() {
+		if err := context.HasPerm("UPDATE"); err != nil {
+			return err
+		}
+	}
+	return db.Delete(comment).Error
+}
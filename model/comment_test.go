@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestThreadOrder(t *testing.T) {
+	var parent = func(id uint64) *uint64 { return &id }
+	var comments = []Comment{
+		{ID: 1},
+		{ID: 2},
+		{ID: 3, ParentID: parent(1)},
+		{ID: 4, ParentID: parent(3)},
+		{ID: 5, ParentID: parent(1)},
+		{ID: 6, ParentID: parent(99)},
+		{ID: 7, ParentID: parent(2)},
+	}
+	var ids []uint64
+	for _, comment := range threadOrder(comments) {
+		ids = append(ids, comment.ID)
+	}
+	if got := fmt.Sprint(ids); got != "[1 3 4 5 2 7 6]" {
+		t.Errorf("threadOrder() = %s", got)
+	}
+}
+
+func TestValidateComment(t *testing.T) {
+	var tests = []struct {
+		body string
+		want string
+		err  error
+	}{
+		{"  looks good \n", "looks good", nil},
+		{" \n\t", "", ErrorEmptyComment},
+		{strings.Repeat("è", MaxCommentLength), strings.Repeat("è", MaxCommentLength), nil},
+		{strings.Repeat("a", MaxCommentLength+1), "", ErrorCommentTooLong},
+	}
+	for _, tt := range tests {
+		got, err := validateComment(tt.body)
+		if got != tt.want || err != tt.err {
+			t.Errorf("validateComment(%.20q) = %.20q, %v, want %.20q, %v", tt.body, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+type commentPost struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Title string `gorm:"size:64" json:"title"`
+	Commentable
+}
+
+func (commentPost) TableName() string {
+	return "comment_post"
+}
+
+func TestCommentEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, commentPost{}, Comment{})
+	resttest.AsUser(t, tagAdmin{})
+	db.Create(&commentPost{ID: 1, Title: "launch"})
+
+	var created = resttest.Post[Comment](t, "/admin/rest/comment_post/comments/1", CommentRequest{Body: "first"})
+	if created.Data.ID == 0 || created.Data.Body != "first" {
+		t.Fatalf("expected the created comment, got %+v", created.Data)
+	}
+	var url = fmt.Sprintf("/admin/rest/comment_post/comments/1/%d", created.Data.ID)
+	if edited := resttest.Put[Comment](t, url, CommentRequest{Body: "edited"}); edited.Data.Body != "edited" {
+		t.Errorf("expected the edited comment, got %+v", edited.Data)
+	}
+	if list := resttest.Get[[]Comment](t, "/admin/rest/comment_post/comments/1"); len(list.Data) != 1 || list.Data[0].Body != "edited" {
+		t.Errorf("expected the comments of the row, got %+v", list.Data)
+	}
+}
@@ -0,0 +1,212 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordAlgorithm specifies the algorithm used to hash new passwords: bcrypt or argon2id.
+// Existing hashes are verified whatever their algorithm.
+var PasswordAlgorithm = "bcrypt"
+
+// MinPasswordLength specifies the minimum length of passwords.
+var MinPasswordLength = 8
+
+// PasswordHistorySize specifies how many previous passwords can not be reused.
+var PasswordHistorySize = 5
+
+// MaxFailedAttempts specifies the number of consecutive failed logins locking the account, zero disables lockout.
+var MaxFailedAttempts = 5
+
+// LockoutDuration specifies how long an account stays locked.
+var LockoutDuration = 15 * time.Minute
+
+// ResetTokenTTL specifies the validity of password reset tokens.
+var ResetTokenTTL = time.Hour
+
+var (
+	ErrPasswordTooShort   = errors.New("password too short")
+	ErrPasswordReused     = errors.New("password recently used")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrInvalidResetToken  = errors.New("invalid or expired reset token")
+)
+
+// Credentials holds the password of a user with its history, lockout state and reset token.
+// Secrets are only stored hashed and never serialized.
+type Credentials struct {
+	PasswordHash      string     `gorm:"column:password_hash;size:255" json:"-"`
+	PasswordHistory   string     `gorm:"column:password_history;type:text" json:"-"`
+	PasswordChangedAt *time.Time `gorm:"column:password_changed_at" json:"password_changed_at,omitempty"`
+	FailedAttempts    int        `gorm:"column:failed_attempts;default:0" json:"-"`
+	LockedUntil       *time.Time `gorm:"column:locked_until" json:"locked_until,omitempty"`
+	ResetTokenHash    string     `gorm:"column:reset_token_hash;size:64" json:"-"`
+	ResetTokenExpires *time.Time `gorm:"column:reset_token_expires" json:"-"`
+}
+
+// CredentialColumns lists the columns to save after a Credentials method changed them.
+var CredentialColumns = []string{"password_hash", "password_history", "password_changed_at", "failed_attempts", "locked_until", "reset_token_hash", "reset_token_expires"}
+
+// HasPassword reports whether a password has been set.
+func (c *Credentials) HasPassword() bool {
+	return c.PasswordHash != ""
+}
+
+// Locked reports whether the account is locked after too many failed attempts.
+func (c *Credentials) Locked() bool {
+	return c.LockedUntil != nil && time.Now().Before(*c.LockedUntil)
+}
+
+// SetPassword hashes and sets the password, rejecting passwords too short or found in the history.
+func (c *Credentials) SetPassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	var history = c.history()
+	for _, hash := range append([]string{c.PasswordHash}, history...) {
+		if hash != "" && verifyPassword(hash, password) {
+			return ErrPasswordReused
+		}
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if c.PasswordHash != "" && PasswordHistorySize > 0 {
+		history = append([]string{c.PasswordHash}, history...)
+		if len(history) > PasswordHistorySize {
+			history = history[:PasswordHistorySize]
+		}
+	}
+	b, _ := json.Marshal(history)
+	var now = time.Now()
+	c.PasswordHash = hash
+	c.PasswordHistory = string(b)
+	c.PasswordChangedAt = &now
+	c.FailedAttempts = 0
+	c.LockedUntil = nil
+	c.ResetTokenHash = ""
+	c.ResetTokenExpires = nil
+	return nil
+}
+
+// CheckPassword verifies the password, counting failures and locking the account after MaxFailedAttempts.
+// The credentials must be saved afterwards as the lockout state changes.
+func (c *Credentials) CheckPassword(password string) error {
+	if c.Locked() {
+		return ErrAccountLocked
+	}
+	if c.PasswordHash == "" || !verifyPassword(c.PasswordHash, password) {
+		c.Fail()
+		return ErrInvalidCredentials
+	}
+	c.FailedAttempts = 0
+	c.LockedUntil = nil
+	return nil
+}
+
+// Fail counts a failed authentication attempt, e.g. a wrong second factor code, locking the account after
+// MaxFailedAttempts. The credentials must be saved afterwards.
+func (c *Credentials) Fail() {
+	c.FailedAttempts++
+	if MaxFailedAttempts > 0 && c.FailedAttempts >= MaxFailedAttempts {
+		var until = time.Now().Add(LockoutDuration)
+		c.LockedUntil = &until
+		c.FailedAttempts = 0
+	}
+}
+
+// NewResetToken generates a password reset token valid for ResetTokenTTL and returns it.
+// Only its hash is kept, the token must be delivered to the user.
+func (c *Credentials) NewResetToken() (string, error) {
+	var b = make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var token = hex.EncodeToString(b)
+	var expires = time.Now().Add(ResetTokenTTL)
+	c.ResetTokenHash = hashToken(token)
+	c.ResetTokenExpires = &expires
+	return token, nil
+}
+
+// ResetPassword sets the password if the reset token is valid. The token can only be used once.
+func (c *Credentials) ResetPassword(token, password string) error {
+	if c.ResetTokenHash == "" || c.ResetTokenExpires == nil || time.Now().After(*c.ResetTokenExpires) ||
+		subtle.ConstantTimeCompare([]byte(c.ResetTokenHash), []byte(hashToken(token))) != 1 {
+		return ErrInvalidResetToken
+	}
+	return c.SetPassword(password)
+}
+
+func (c *Credentials) history() []string {
+	var history []string
+	if c.PasswordHistory != "" {
+		_ = json.Unmarshal([]byte(c.PasswordHistory), &history)
+	}
+	return history
+}
+
+func hashToken(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// argon2id parameters of new hashes.
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+)
+
+func hashPassword(password string) (string, error) {
+	if PasswordAlgorithm == "argon2id" {
+		var salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		var key = argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(b), err
+}
+
+func verifyPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		var parts = strings.Split(hash, "$")
+		if len(parts) != 6 {
+			return false
+		}
+		var memory uint32
+		var iterations uint32
+		var threads uint8
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+			return false
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false
+		}
+		key, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil {
+			return false
+		}
+		var other = argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
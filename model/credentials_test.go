@@ -0,0 +1,73 @@
+package model
+
+import "testing"
+
+func TestCredentialsPassword(t *testing.T) {
+	for _, algorithm := range []string{"bcrypt", "argon2id"} {
+		PasswordAlgorithm = algorithm
+		var c Credentials
+		if err := c.SetPassword("short"); err != ErrPasswordTooShort {
+			t.Errorf("%s: SetPassword(short) = %v, want ErrPasswordTooShort", algorithm, err)
+		}
+		if err := c.SetPassword("first-password"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.CheckPassword("first-password"); err != nil {
+			t.Errorf("%s: CheckPassword = %v", algorithm, err)
+		}
+		if err := c.CheckPassword("wrong-password"); err != ErrInvalidCredentials {
+			t.Errorf("%s: CheckPassword(wrong) = %v, want ErrInvalidCredentials", algorithm, err)
+		}
+		if err := c.SetPassword("second-password"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SetPassword("first-password"); err != ErrPasswordReused {
+			t.Errorf("%s: SetPassword(reused) = %v, want ErrPasswordReused", algorithm, err)
+		}
+	}
+	PasswordAlgorithm = "bcrypt"
+}
+
+func TestCredentialsLockout(t *testing.T) {
+	MaxFailedAttempts = 3
+	var c Credentials
+	_ = c.SetPassword("valid-password")
+	for i := 0; i < 3; i++ {
+		_ = c.CheckPassword("wrong-password")
+	}
+	if err := c.CheckPassword("valid-password"); err != ErrAccountLocked {
+		t.Errorf("CheckPassword after lockout = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestCredentialsFail(t *testing.T) {
+	MaxFailedAttempts = 2
+	var c Credentials
+	_ = c.SetPassword("valid-password")
+	c.Fail()
+	if c.Locked() || c.FailedAttempts != 1 {
+		t.Errorf("after one failure: locked %v, attempts %d", c.Locked(), c.FailedAttempts)
+	}
+	c.Fail()
+	if !c.Locked() {
+		t.Error("account not locked after MaxFailedAttempts failures")
+	}
+}
+
+func TestCredentialsReset(t *testing.T) {
+	var c Credentials
+	_ = c.SetPassword("valid-password")
+	token, err := c.NewResetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ResetPassword("other", "new-password"); err != ErrInvalidResetToken {
+		t.Errorf("ResetPassword(invalid) = %v, want ErrInvalidResetToken", err)
+	}
+	if err := c.ResetPassword(token, "new-password"); err != nil {
+		t.Errorf("ResetPassword = %v", err)
+	}
+	if err := c.ResetPassword(token, "another-password"); err != ErrInvalidResetToken {
+		t.Errorf("ResetPassword(reused token) = %v, want ErrInvalidResetToken", err)
+	}
+}
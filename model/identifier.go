@@ -0,0 +1,110 @@
+package model
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IDKind is the kind of the primary keys generated by Identifier.
+type IDKind int
+
+const (
+	// UUIDv4 keys are random UUIDs.
+	UUIDv4 IDKind = iota
+	// UUIDv7 keys are UUIDs starting with their creation time in milliseconds, so rows are inserted in index order.
+	UUIDv7
+	// ULID keys are 26 characters long Crockford base32 strings sorted by creation time.
+	ULID
+)
+
+// IdentifierKind is the kind of the keys generated for the models embedding Identifier, UUIDv4 by default.
+var IdentifierKind = UUIDv4
+
+// crockford is the alphabet of the ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Identifier gives the models embedding it a string primary key in the uuid column, generated on create when
+// not set, following IdentifierKind. Keys given by the caller are kept.
+//
+// The key is generated by the gorm BeforeCreate hook, which a BeforeCreate(tx *gorm.DB) method of the model
+// hides, and by the rest Create, Clone and Set endpoints, which return the URL of the created object in the
+// Location header, see rest.IDGenerator.
+//
+//	type Invoice struct {
+//	    model.Identifier
+//	    Number string `gorm:"column:number;size:32" json:"number"`
+//	    rest.API
+//	}
+type Identifier struct {
+	UUID string `gorm:"column:uuid;primaryKey;size:36" json:"uuid"`
+}
+
+// GenerateID sets a new key, of the IdentifierKind, unless the key is already set.
+func (o *Identifier) GenerateID() {
+	if o.UUID == "" {
+		o.UUID = NewID(IdentifierKind)
+	}
+}
+
+// BeforeCreate generates the key of the row before it is inserted.
+func (o *Identifier) BeforeCreate(tx *gorm.DB) error {
+	o.GenerateID()
+	return nil
+}
+
+// NewID returns a new key of the kind.
+func NewID(kind IDKind) string {
+	switch kind {
+	case UUIDv7:
+		return newUUIDv7(time.Now())
+	case ULID:
+		return newULID(time.Now())
+	}
+	return uuid.NewString()
+}
+
+// newUUIDv7 returns a version 7 UUID of the time, as described by RFC 9562.
+func newUUIDv7(now time.Time) string {
+	var id uuid.UUID
+	putMillis(id[:6], now)
+	_, _ = rand.Read(id[6:])
+	id[6] = 0x70 | id[6]&0x0f
+	id[8] = 0x80 | id[8]&0x3f
+	return id.String()
+}
+
+// newULID returns a ULID of the time: 48 bits of milliseconds followed by 80 random bits.
+func newULID(now time.Time) string {
+	var id [16]byte
+	putMillis(id[:6], now)
+	_, _ = rand.Read(id[6:])
+	return encodeULID(id)
+}
+
+// putMillis writes the Unix time of now in milliseconds to the 6 bytes of b, big endian.
+func putMillis(b []byte, now time.Time) {
+	var ms = uint64(now.UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+}
+
+// encodeULID encodes the 128 bits of the ULID in 26 Crockford base32 characters, the first one holding the
+// 3 most significant bits.
+func encodeULID(id [16]byte) string {
+	var out [26]byte
+	for i := range out {
+		var value byte
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			value <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>(bit%8)) != 0 {
+				value |= 1
+			}
+		}
+		out[i] = crockford[value]
+	}
+	return string(out[:])
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iesitalia/toolbox/resttest"
+	"gorm.io/gorm"
+)
+
+func TestEncodeULID(t *testing.T) {
+	var tests = []struct {
+		name string
+		id   [16]byte
+		want string
+	}{
+		{"zero", [16]byte{}, "00000000000000000000000000"},
+		{"max", [16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		{"last bit", [16]byte{15: 1}, "00000000000000000000000001"},
+		{"first bit", [16]byte{0: 0x80}, "40000000000000000000000000"},
+	}
+	for _, test := range tests {
+		if got := encodeULID(test.id); got != test.want {
+			t.Errorf("%s: encodeULID = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewID(t *testing.T) {
+	var now = time.UnixMilli(1700000000000)
+
+	v7, err := uuid.Parse(newUUIDv7(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v7.Version() != 7 || v7.Variant() != uuid.RFC4122 {
+		t.Errorf("UUIDv7: version %d, variant %s", v7.Version(), v7.Variant())
+	}
+	if !strings.HasPrefix(v7.String(), "018bcfe5-6800-7") {
+		t.Errorf("UUIDv7 %s does not start with the time", v7)
+	}
+	if newUUIDv7(now.Add(time.Millisecond)) <= newUUIDv7(now) {
+		t.Error("UUIDv7 keys not sorted by time")
+	}
+
+	var ulid = newULID(now)
+	if len(ulid) != 26 || strings.Trim(ulid, crockford) != "" || !strings.HasPrefix(ulid, "01HF7YAT00") {
+		t.Errorf("invalid ULID %s", ulid)
+	}
+	if newULID(now.Add(time.Millisecond)) <= ulid {
+		t.Error("ULID keys not sorted by time")
+	}
+
+	v4, err := uuid.Parse(NewID(UUIDv4))
+	if err != nil || v4.Version() != 4 {
+		t.Errorf("invalid UUIDv4 %s", v4)
+	}
+}
+
+func TestGenerateID(t *testing.T) {
+	var o = Identifier{UUID: "given"}
+	o.GenerateID()
+	if o.UUID != "given" {
+		t.Errorf("GenerateID replaced the key with %s", o.UUID)
+	}
+	o.UUID = ""
+	o.GenerateID()
+	if _, err := uuid.Parse(o.UUID); err != nil {
+		t.Errorf("GenerateID set %q: %s", o.UUID, err)
+	}
+}
+
+type idOrder struct {
+	Identifier
+	Number string   `gorm:"column:number;size:32" json:"number"`
+	Lines  []idLine `gorm:"foreignKey:OrderUUID" json:"lines"`
+}
+
+func (idOrder) TableName() string {
+	return "id_order"
+}
+
+type idLine struct {
+	Identifier
+	OrderUUID string `gorm:"column:order_uuid;size:36" json:"order_uuid"`
+	Name      string `gorm:"column:name;size:32" json:"name"`
+}
+
+func (idLine) TableName() string {
+	return "id_line"
+}
+
+// BeforeCreate hides the hook of Identifier.
+func (l *idLine) BeforeCreate(tx *gorm.DB) error {
+	return nil
+}
+
+func TestCloneGeneratesChildIDs(t *testing.T) {
+	var db = resttest.Setup(t, idOrder{}, idLine{})
+	db.Create(&idOrder{Identifier: Identifier{UUID: "o1"}, Number: "A1"})
+	db.Create(&idLine{Identifier: Identifier{UUID: "l1"}, OrderUUID: "o1", Name: "first"})
+	resttest.AsUser(t, tagAdmin{})
+
+	resttest.Post[map[string]interface{}](t, "/admin/rest/id_order/o1/clone?children=lines", nil)
+	var lines []idLine
+	db.Find(&lines)
+	if len(lines) != 2 {
+		t.Fatalf("expected the line to be copied, got %d lines", len(lines))
+	}
+	for _, line := range lines {
+		if line.UUID == "" || line.OrderUUID == "" {
+			t.Errorf("expected the copied line to get its own key, got %+v", line)
+		}
+	}
+}
+
+func TestUserGenerateID(t *testing.T) {
+	var user = User{UUID: "kept"}
+	user.GenerateID()
+	if user.UUID != "kept" {
+		t.Errorf("GenerateID() replaced the key of the user: %q", user.UUID)
+	}
+	user = User{}
+	if err := user.BeforeCreate(nil); err != nil || user.UUID == "" {
+		t.Errorf("BeforeCreate() = %v, key %q, want a generated key", err, user.UUID)
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"github.com/getevo/evo/v2/lib/db"
+	"gorm.io/gorm"
 	"time"
 )
 
@@ -213,9 +214,23 @@ type User struct {
 	FirstName string `gorm:"column:first_name;size:255" validation:"alpha,required" json:"first_name"`
 	LastName  string `gorm:"column:last_name;size:255" validation:"alpha,required" json:"last_name"`
 	Email     string `gorm:"column:email;size:255;unique" validation:"email" json:"email"`
+	Credentials
 }
 
 // TableName returns the name of the database table associated with the User struct.
 func (User) TableName() string {
 	return "users"
 }
+
+// GenerateID sets a new key, of the IdentifierKind, unless the key is already set, like Identifier does.
+func (u *User) GenerateID() {
+	if u.UUID == "" {
+		u.UUID = NewID(IdentifierKind)
+	}
+}
+
+// BeforeCreate generates the key of the user before it is inserted.
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	u.GenerateID()
+	return nil
+}
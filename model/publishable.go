@@ -0,0 +1,190 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// PublishPermission allows to publish and unpublish the rows of a resource.
+var PublishPermission = acl.Permission{
+	Key:         "PUBLISH",
+	Name:        "Publish",
+	Description: "Publish or unpublish items",
+}
+
+// Publishable gives the rows of the models embedding it a draft and a published revision. The row is the
+// draft, edited through the usual endpoints; publishing copies it to the published revision served to the
+// anonymous users, who do not see the rows never published or unpublished.
+// POST /rest/:table/publish/:pk publishes the current draft of the row.
+// POST /rest/:table/unpublish/:pk withdraws the row from the anonymous users.
+//
+// Anonymous users filter and sort the rows on the primary key and the publication columns only, as the other
+// columns hold the draft. Created and cloned rows start unpublished.
+//
+// Publishable implements rest.Policy, models with their own policy must exclude the unpublished rows themselves.
+type Publishable struct {
+	PublishedAt   *time.Time `gorm:"column:published_at;index" json:"published_at"`
+	PublishedBy   string     `gorm:"column:published_by;size:36" json:"published_by"`
+	PublishedData string     `gorm:"column:published_data;type:text" json:"-"`
+}
+
+// publishable returns the Publishable embedded in a model.
+func (p *Publishable) publishable() *Publishable {
+	return p
+}
+
+// anonymous reports whether the caller of the context is not logged in.
+func anonymous(context *rest.Context) bool {
+	var user = context.User()
+	return user == nil || user.Anonymous()
+}
+
+// Scope excludes the unpublished rows from the queries of the anonymous users.
+func (Publishable) Scope(context *rest.Context, query *gorm.DB) *gorm.DB {
+	if anonymous(context) {
+		return query.Where(context.Schema.Table + ".published_at IS NOT NULL")
+	}
+	return query
+}
+
+// RESTQueryable restricts the filters and the order of the anonymous users to the columns of the published
+// revision which are not part of the draft.
+func (Publishable) RESTQueryable(context *rest.Context, column string) bool {
+	if !anonymous(context) {
+		return true
+	}
+	switch column {
+	case "published_at", "published_by":
+		return true
+	}
+	for _, field := range context.Schema.PrimaryFields {
+		if field.DBName == column {
+			return true
+		}
+	}
+	return false
+}
+
+// RESTActions registers the publish and unpublish endpoints on the resources of models embedding Publishable.
+func (Publishable) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "PUBLISH",
+			Method:      rest.POST,
+			URL:         "/publish",
+			PKUrl:       true,
+			Handler:     Publish,
+			Description: "publish the current draft of the row",
+			Permissions: []acl.Permission{PublishPermission},
+		},
+		{
+			Name:        "UNPUBLISH",
+			Method:      rest.POST,
+			URL:         "/unpublish",
+			PKUrl:       true,
+			Handler:     Unpublish,
+			Description: "withdraw the row from the anonymous users",
+			Permissions: []acl.Permission{PublishPermission},
+		},
+	}
+}
+
+// publishedRead replaces the rows read by anonymous users with their published revision.
+func publishedRead(context *rest.Context, object reflect.Value) {
+	if !anonymous(context) || !object.CanAddr() {
+		return
+	}
+	obj, ok := object.Addr().Interface().(interface{ publishable() *Publishable })
+	if !ok {
+		return
+	}
+	var p = obj.publishable()
+	if p.PublishedData == "" {
+		return
+	}
+	var published = *p
+	if err := json.Unmarshal([]byte(p.PublishedData), object.Addr().Interface()); err != nil {
+		context.Logger().Error("unable to read published revision", "error", err.Error())
+	}
+	*p = published
+}
+
+// publishedCreate resets the publication of the rows created or cloned through the endpoints, so the copy of a
+// published row is a draft.
+func publishedCreate(context *rest.Context, current interface{}) error {
+	if context.Action.Name == "SET" {
+		return nil
+	}
+	if obj, ok := current.(interface{ publishable() *Publishable }); ok {
+		*obj.publishable() = Publishable{}
+	}
+	return nil
+}
+
+// publication returns the row given by the request along with its Publishable.
+func publication(context *rest.Context) (interface{}, *Publishable, error) {
+	var ptr = context.GetObject().Addr().Interface()
+	obj, ok := ptr.(interface{ publishable() *Publishable })
+	if !ok {
+		return nil, nil, rest.ErrorObjectNotExist
+	}
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, rest.ErrorObjectNotExist
+	}
+	return ptr, obj.publishable(), nil
+}
+
+// Publish copies the current draft of the row given by the primary key to its published revision.
+func Publish(context *rest.Context) error {
+	if context.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	if err := context.HasPerm("PUBLISH"); err != nil {
+		return err
+	}
+	ptr, p, err := publication(context)
+	if err != nil {
+		return err
+	}
+	var now = time.Now()
+	p.PublishedAt, p.PublishedBy = &now, context.User().UUID()
+	data, err := json.Marshal(ptr)
+	if err != nil {
+		return err
+	}
+	p.PublishedData = string(data)
+	if err := context.GetDBO().Model(ptr).Select("published_at", "published_by", "published_data").Updates(ptr).Error; err != nil {
+		return err
+	}
+	context.Response.Data = ptr
+	return nil
+}
+
+// Unpublish withdraws the row given by the primary key from the anonymous users. The draft is kept.
+func Unpublish(context *rest.Context) error {
+	if context.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	if err := context.HasPerm("PUBLISH"); err != nil {
+		return err
+	}
+	ptr, p, err := publication(context)
+	if err != nil {
+		return err
+	}
+	p.PublishedAt, p.PublishedBy, p.PublishedData = nil, "", ""
+	if err := context.GetDBO().Model(ptr).Select("published_at", "published_by", "published_data").Updates(ptr).Error; err != nil {
+		return err
+	}
+	context.Response.Data = ptr
+	return nil
+}
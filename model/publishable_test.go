@@ -0,0 +1,96 @@
+package model
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+	"gorm.io/gorm/schema"
+)
+
+type publishablePage struct {
+	ID    uint64 `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Publishable
+}
+
+func TestPublishedRead(t *testing.T) {
+	var at = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		name string
+		row  publishablePage
+		want publishablePage
+	}{
+		{
+			"draft only",
+			publishablePage{ID: 1, Title: "Draft"},
+			publishablePage{ID: 1, Title: "Draft"},
+		},
+		{
+			"published revision",
+			publishablePage{ID: 2, Title: "Edited", Body: "new", Publishable: Publishable{PublishedAt: &at, PublishedData: `{"id":2,"title":"Live","body":"old"}`}},
+			publishablePage{ID: 2, Title: "Live", Body: "old", Publishable: Publishable{PublishedAt: &at, PublishedData: `{"id":2,"title":"Live","body":"old"}`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var row = tt.row
+			publishedRead(&rest.Context{}, reflect.ValueOf(&row).Elem())
+			if !reflect.DeepEqual(row, tt.want) {
+				t.Errorf("publishedRead() = %+v, want %+v", row, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishedQueryable(t *testing.T) {
+	s, err := schema.Parse(&publishablePage{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var context = &rest.Context{Schema: s}
+	var tests = map[string]bool{
+		"id":           true,
+		"published_at": true,
+		"title":        false,
+		"body":         false,
+	}
+	for column, want := range tests {
+		if got := (Publishable{}).RESTQueryable(context, column); got != want {
+			t.Errorf("RESTQueryable(%q) = %v, want %v", column, got, want)
+		}
+	}
+}
+
+func TestPublishedCreate(t *testing.T) {
+	var at = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	var live = Publishable{PublishedAt: &at, PublishedBy: "editor", PublishedData: `{"id":2,"title":"Live"}`}
+	for _, action := range []string{"CREATE", "CLONE", "SET"} {
+		var row = publishablePage{ID: 2, Title: "Edited", Publishable: live}
+		if err := publishedCreate(&rest.Context{Action: &rest.Endpoint{Name: action}}, &row); err != nil {
+			t.Fatal(err)
+		}
+		if reset := row.PublishedAt == nil && row.PublishedData == ""; reset == (action == "SET") {
+			t.Errorf("%s: unexpected publication %+v", action, row.Publishable)
+		}
+	}
+}
+
+func TestPublishEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, publishablePage{})
+	resttest.AsUser(t, tagAdmin{})
+	db.Create(&publishablePage{ID: 1, Title: "Draft"})
+
+	var published = resttest.Post[publishablePage](t, "/admin/rest/publishable_pages/publish/1", nil)
+	if published.Data.ID != 1 || published.Data.PublishedAt == nil {
+		t.Errorf("expected the published row, got %+v", published.Data)
+	}
+	var unpublished = resttest.Post[publishablePage](t, "/admin/rest/publishable_pages/unpublish/1", nil)
+	if unpublished.Data.ID != 1 || unpublished.Data.PublishedAt != nil {
+		t.Errorf("expected the unpublished row, got %+v", unpublished.Data)
+	}
+}
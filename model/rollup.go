@@ -0,0 +1,295 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	scm "github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// RollupReconcileInterval specifies how often every rollup column is recalculated from scratch,
+// fixing the values the callbacks can not maintain such as raw queries.
+var RollupReconcileInterval = time.Hour
+
+// Rollups is implemented by models keeping aggregates of a has-many relation in their own columns, so list endpoints
+// can sort and filter on them without joins. Keys are columns of the model and values aggregate expressions:
+//
+//	func (Customer) Rollups() map[string]string {
+//		return map[string]string{"orders_total": "sum(orders.amount)", "orders_count": "count(orders)"}
+//	}
+//
+// Supported functions are sum, count, min, max and avg. The relation is the has-many field or its table.
+type Rollups interface {
+	Rollups() map[string]string
+}
+
+// Rollup is an aggregate of a child table stored in a column of the parent table.
+type Rollup struct {
+	Table       string
+	PrimaryKey  string
+	Column      string
+	Function    string
+	ChildTable  string
+	ChildColumn string
+	ForeignKey  string
+	// SoftDelete excludes the children flagged by the deleted column of DeletedAt.
+	SoftDelete bool
+}
+
+var rollupRegex = regexp.MustCompile(`(?i)^\s*(sum|count|min|max|avg)\s*\(\s*([a-zA-Z0-9_]+)(?:\.([a-zA-Z0-9_]+))?\s*\)\s*$`)
+
+// parseRollup returns the function, relation and field of an aggregate expression. The field is empty for count(relation).
+func parseRollup(expr string) (function string, relation string, field string, err error) {
+	var match = rollupRegex.FindStringSubmatch(expr)
+	if match == nil {
+		return "", "", "", fmt.Errorf("invalid rollup expression %q", expr)
+	}
+	function = strings.ToUpper(match[1])
+	if match[3] == "" && function != "COUNT" {
+		return "", "", "", fmt.Errorf("rollup expression %q requires a field", expr)
+	}
+	return function, match[2], match[3], nil
+}
+
+// newRollup resolves the expression of a column against the has-many relations of the schema.
+func newRollup(s *schema.Schema, column string, expr string) (*Rollup, error) {
+	function, relation, field, err := parseRollup(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.PrimaryFields) != 1 {
+		return nil, fmt.Errorf("rollup %s.%s requires a single primary key", s.Table, column)
+	}
+	if s.LookUpField(column) == nil {
+		return nil, fmt.Errorf("rollup column %s.%s does not exist", s.Table, column)
+	}
+	for _, rel := range s.Relationships.HasMany {
+		if !strings.EqualFold(rel.Name, relation) && rel.FieldSchema.Table != relation {
+			continue
+		}
+		if len(rel.References) != 1 {
+			return nil, fmt.Errorf("rollup %s.%s requires a single foreign key", s.Table, column)
+		}
+		var rollup = Rollup{
+			Table:      s.Table,
+			PrimaryKey: s.PrimaryFields[0].DBName,
+			Column:     column,
+			Function:   function,
+			ChildTable: rel.FieldSchema.Table,
+			ForeignKey: rel.References[0].ForeignKey.DBName,
+			SoftDelete: rel.FieldSchema.LookUpField("deleted") != nil,
+		}
+		if field != "" {
+			var f = rel.FieldSchema.LookUpField(field)
+			if f == nil {
+				return nil, fmt.Errorf("rollup field %s.%s does not exist", rollup.ChildTable, field)
+			}
+			rollup.ChildColumn = f.DBName
+		}
+		return &rollup, nil
+	}
+	return nil, fmt.Errorf("rollup %s.%s: has-many relation %s not found", s.Table, column, relation)
+}
+
+// SQL returns the statement recalculating the rollup of the given parents, or of every row if none is given.
+func (r *Rollup) SQL(parents int) string {
+	var aggregate = "COUNT(*)"
+	if r.ChildColumn != "" {
+		aggregate = fmt.Sprintf("%s(c.`%s`)", r.Function, r.ChildColumn)
+	}
+	var condition = fmt.Sprintf("c.`%s` = p.`%s`", r.ForeignKey, r.PrimaryKey)
+	if r.SoftDelete {
+		condition += " AND c.`deleted` = 0"
+	}
+	var query = fmt.Sprintf("UPDATE `%s` p SET p.`%s` = (SELECT COALESCE(%s, 0) FROM `%s` c WHERE %s)",
+		r.Table, r.Column, aggregate, r.ChildTable, condition)
+	if parents > 0 {
+		query += fmt.Sprintf(" WHERE p.`%s` IN (?)", r.PrimaryKey)
+	}
+	return query
+}
+
+// Refresh recalculates the rollup of the given parents, or of every row if none is given.
+func (r *Rollup) Refresh(db *gorm.DB, parents ...interface{}) error {
+	if len(parents) > 0 {
+		return db.Exec(r.SQL(len(parents)), parents).Error
+	}
+	return db.Exec(r.SQL(0)).Error
+}
+
+// rollups holds the rollups keyed by child table.
+var rollups = map[string][]*Rollup{}
+var rollupsMu sync.RWMutex
+
+// LoadRollups resolves the rollups declared by the registered models.
+func LoadRollups() error {
+	var result = map[string][]*Rollup{}
+	for _, model := range scm.Models {
+		obj, ok := model.Sample.(Rollups)
+		if !ok {
+			continue
+		}
+		for column, expr := range obj.Rollups() {
+			rollup, err := newRollup(model.Schema, column, expr)
+			if err != nil {
+				return err
+			}
+			result[rollup.ChildTable] = append(result[rollup.ChildTable], rollup)
+		}
+	}
+	rollupsMu.Lock()
+	rollups = result
+	rollupsMu.Unlock()
+	return nil
+}
+
+// GetRollups returns every loaded rollup.
+func GetRollups() []*Rollup {
+	rollupsMu.RLock()
+	defer rollupsMu.RUnlock()
+	var result []*Rollup
+	for _, list := range rollups {
+		result = append(result, list...)
+	}
+	return result
+}
+
+// ReconcileRollups recalculates every rollup column.
+func ReconcileRollups() error {
+	var dbo = evo.GetDBO()
+	for _, rollup := range GetRollups() {
+		if err := rollup.Refresh(dbo); err != nil {
+			return fmt.Errorf("rollup %s.%s: %w", rollup.Table, rollup.Column, err)
+		}
+	}
+	return nil
+}
+
+// rollupParentsKey is the statement setting holding the parents recorded by OnRollupParents.
+const rollupParentsKey = "rollup:parents"
+
+// OnRollupParents is the callback recording the parents of the children about to be updated or deleted,
+// before the write moves them to another parent or removes them, so OnRollup refreshes these parents too.
+// Children are found by the conditions of the statement and the primary keys of the written objects.
+func (c Callback) OnRollupParents(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	rollupsMu.RLock()
+	var list = rollups[db.Statement.Schema.Table]
+	rollupsMu.RUnlock()
+	if len(list) == 0 {
+		return
+	}
+	var query = db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Schema.Table)
+	var conditioned = db.Statement.AllowGlobalUpdate
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		query = query.Clauses(where.Expression)
+		conditioned = true
+	}
+	if len(db.Statement.Schema.PrimaryFields) == 1 {
+		var primaryKey = db.Statement.Schema.PrimaryFields[0].DBName
+		if keys := foreignKeys(db, primaryKey); len(keys) > 0 {
+			query = query.Where(db.Statement.Quote(primaryKey)+" IN (?)", keys)
+			conditioned = true
+		}
+	}
+	if !conditioned {
+		return
+	}
+	query = query.Session(&gorm.Session{})
+	var parents = map[string][]interface{}{}
+	for _, rollup := range list {
+		if _, ok := parents[rollup.ForeignKey]; ok {
+			continue
+		}
+		var keys []interface{}
+		if err := query.Distinct(rollup.ForeignKey).Pluck(rollup.ForeignKey, &keys).Error; err != nil {
+			logger.Error("unable to find the parents of rollup", "table", rollup.Table, "column", rollup.Column, "error", err.Error())
+			continue
+		}
+		parents[rollup.ForeignKey] = keys
+	}
+	db.Set(rollupParentsKey, parents)
+}
+
+// OnRollup is the callback refreshing the rollups of the parents of the created, updated or deleted children.
+// Parents are found by the foreign key of the written objects together with the parents recorded by
+// OnRollupParents, so a child moved to another parent updates both parents.
+func (c Callback) OnRollup(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	rollupsMu.RLock()
+	var list = rollups[db.Statement.Schema.Table]
+	rollupsMu.RUnlock()
+	if len(list) == 0 {
+		return
+	}
+	var dbo = db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+	for _, rollup := range list {
+		var parents = rollupParents(db, rollup)
+		if len(parents) == 0 {
+			continue
+		}
+		if err := rollup.Refresh(dbo, parents...); err != nil {
+			logger.Error("unable to refresh rollup", "table", rollup.Table, "column", rollup.Column, "error", err.Error())
+		}
+	}
+}
+
+// rollupParents returns the distinct parents of the rollup written by the statement, before and after the write.
+func rollupParents(db *gorm.DB, rollup *Rollup) []interface{} {
+	var result = foreignKeys(db, rollup.ForeignKey)
+	var seen = map[string]bool{}
+	for _, key := range result {
+		seen[fmt.Sprint(key)] = true
+	}
+	if v, ok := db.Get(rollupParentsKey); ok {
+		for _, key := range v.(map[string][]interface{})[rollup.ForeignKey] {
+			if key == nil || seen[fmt.Sprint(key)] {
+				continue
+			}
+			seen[fmt.Sprint(key)] = true
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// foreignKeys returns the distinct non-zero values of the column in the objects written by the statement.
+func foreignKeys(db *gorm.DB, column string) []interface{} {
+	var field = db.Statement.Schema.LookUpField(column)
+	if field == nil {
+		return nil
+	}
+	var seen = map[interface{}]bool{}
+	var result []interface{}
+	var collect = func(value reflect.Value) {
+		v, zero := field.ValueOf(context.Background(), value)
+		if zero || seen[v] {
+			return
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	var value = reflect.Indirect(db.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Struct:
+		collect(value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			collect(reflect.Indirect(value.Index(i)))
+		}
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/iesitalia/toolbox/resttest"
+	"gorm.io/gorm"
+)
+
+func TestParseRollup(t *testing.T) {
+	tests := []struct {
+		expr     string
+		function string
+		relation string
+		field    string
+		err      bool
+	}{
+		{expr: "sum(orders.amount)", function: "SUM", relation: "orders", field: "amount"},
+		{expr: " AVG( Orders.amount ) ", function: "AVG", relation: "Orders", field: "amount"},
+		{expr: "count(orders)", function: "COUNT", relation: "orders"},
+		{expr: "count(orders.id)", function: "COUNT", relation: "orders", field: "id"},
+		{expr: "sum(orders)", err: true},
+		{expr: "median(orders.amount)", err: true},
+		{expr: "sum(orders.amount); DROP TABLE users", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			function, relation, field, err := parseRollup(tt.expr)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if function != tt.function || relation != tt.relation || field != tt.field {
+				t.Errorf("got %s %s %s, want %s %s %s", function, relation, field, tt.function, tt.relation, tt.field)
+			}
+		})
+	}
+}
+
+func TestRollupSQL(t *testing.T) {
+	var r = Rollup{Table: "customer", PrimaryKey: "id", Column: "orders_total", Function: "SUM", ChildTable: "orders", ChildColumn: "amount", ForeignKey: "customer_id"}
+	var want = "UPDATE `customer` p SET p.`orders_total` = (SELECT COALESCE(SUM(c.`amount`), 0) FROM `orders` c WHERE c.`customer_id` = p.`id`)"
+	if got := r.SQL(0); got != want {
+		t.Errorf("SQL(0) = %s", got)
+	}
+	r.ChildColumn = ""
+	r.SoftDelete = true
+	want = "UPDATE `customer` p SET p.`orders_total` = (SELECT COALESCE(COUNT(*), 0) FROM `orders` c WHERE c.`customer_id` = p.`id` AND c.`deleted` = 0) WHERE p.`id` IN (?)"
+	if got := r.SQL(2); got != want {
+		t.Errorf("SQL(2) = %s", got)
+	}
+}
+
+type rollupParent struct {
+	ID       uint `gorm:"primaryKey"`
+	Children int
+}
+
+func (rollupParent) TableName() string {
+	return "rollup_parent"
+}
+
+type rollupChild struct {
+	ID       uint `gorm:"primaryKey"`
+	ParentID uint
+}
+
+func (rollupChild) TableName() string {
+	return "rollup_child"
+}
+
+var rollupCallbacks sync.Once
+
+// rollupWritten holds the parents of the rollup_child rollup of the last statement.
+var rollupWritten []string
+
+func TestRollupParents(t *testing.T) {
+	var db = resttest.Setup(t, rollupParent{}, rollupChild{})
+	var rollup = &Rollup{Table: "rollup_parent", PrimaryKey: "id", Column: "children", Function: "COUNT", ChildTable: "rollup_child", ForeignKey: "parent_id"}
+	rollupsMu.Lock()
+	var loaded = rollups
+	rollups = map[string][]*Rollup{"rollup_child": {rollup}}
+	rollupsMu.Unlock()
+	t.Cleanup(func() {
+		rollupsMu.Lock()
+		rollups = loaded
+		rollupsMu.Unlock()
+	})
+	rollupCallbacks.Do(func() {
+		var callback Callback
+		var record = func(db *gorm.DB) {
+			if db.Statement.Schema == nil || db.Statement.Schema.Table != "rollup_child" {
+				return
+			}
+			rollupWritten = nil
+			for _, key := range rollupParents(db, rollup) {
+				rollupWritten = append(rollupWritten, fmt.Sprint(key))
+			}
+			sort.Strings(rollupWritten)
+		}
+		_ = db.Callback().Update().Before("gorm:update").Register("test:rollup:update:parents", callback.OnRollupParents)
+		_ = db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("test:rollup:update", record)
+		_ = db.Callback().Delete().Before("gorm:delete").Register("test:rollup:delete:parents", callback.OnRollupParents)
+		_ = db.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("test:rollup:delete", record)
+	})
+	db.Create(&[]rollupChild{{ID: 1, ParentID: 1}, {ID: 2, ParentID: 2}, {ID: 3, ParentID: 3}, {ID: 4, ParentID: 2}})
+
+	var tests = []struct {
+		name  string
+		write func() error
+		want  string
+	}{
+		{name: "moved by update", want: "[1 2]", write: func() error {
+			return db.Model(&rollupChild{ID: 1, ParentID: 1}).Update("parent_id", 2).Error
+		}},
+		{name: "moved by save", want: "[1 3]", write: func() error {
+			return db.Save(&rollupChild{ID: 3, ParentID: 1}).Error
+		}},
+		{name: "moved by condition", want: "[1 4]", write: func() error {
+			return db.Model(&rollupChild{}).Where("id = ?", 3).Update("parent_id", 4).Error
+		}},
+		{name: "deleted by condition", want: "[2]", write: func() error {
+			return db.Where("parent_id = ?", 2).Delete(&rollupChild{}).Error
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(rollupWritten); got != tt.want {
+				t.Errorf("refreshed parents = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// ErrNothingToReorder is returned by the reorder endpoint when no primary key is given.
+var ErrNothingToReorder = errors.New("no rows to reorder")
+
+// ErrMixedSortScope is returned by the reorder endpoint when the rows belong to different parents.
+var ErrMixedSortScope = errors.New("rows to reorder must belong to the same parent")
+
+// ErrorReorderKey is returned by the reorder endpoint for models without a single primary key.
+var ErrorReorderKey = errors.New("reordering requires a single primary key")
+
+// Sortable gives the rows of the models embedding it a manual order stored in the position column, for
+// drag-and-drop admin lists. Lists are sorted by it with ?order=position.
+// POST /rest/:table/reorder {"ids": [3, 1, 2]} orders the given rows among the slots of the list they occupy,
+// e.g. the rows of a page after a drag-and-drop, the other rows keep their place. Positions start from 1.
+//
+// Models ordered within a parent implement SortScope, returning the foreign key column of the parent:
+//
+//	func (Item) SortScope() string { return "category_id" }
+type Sortable struct {
+	Position int `gorm:"column:position;index" json:"position"`
+}
+
+// SortScope is implemented by the sortable models ordered within a parent.
+type SortScope interface {
+	SortScope() string
+}
+
+// ReorderRequest is the body of the reorder endpoint.
+type ReorderRequest struct {
+	IDs []interface{} `json:"ids"`
+}
+
+// RESTActions registers the reorder endpoint on the resources of models embedding Sortable.
+func (Sortable) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "REORDER",
+			Method:      rest.POST,
+			URL:         "/reorder",
+			Handler:     Reorder,
+			Description: "set the order of the rows given by primary key",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+	}
+}
+
+// keyString returns the string form of a primary key read from the database or decoded from JSON.
+func keyString(v interface{}) string {
+	switch value := v.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []byte:
+		return string(value)
+	}
+	return fmt.Sprint(v)
+}
+
+// reorder returns the new positions of the rows, keyed by primary key: the given rows take the slots they occupy
+// in the current order, in the given order, and the other rows keep their slot. Only the rows whose position
+// changes are returned.
+func reorder(current []string, positions map[string]int, ids []string) map[string]int {
+	var given = map[string]bool{}
+	var unique []string
+	for _, id := range ids {
+		if !given[id] {
+			given[id] = true
+			unique = append(unique, id)
+		}
+	}
+	var result = map[string]int{}
+	var next = 0
+	for i, id := range current {
+		if given[id] && next < len(unique) {
+			id = unique[next]
+			next++
+		}
+		if positions[id] != i+1 {
+			result[id] = i + 1
+		}
+	}
+	return result
+}
+
+// Reorder sets the positions of the rows given by primary key, within their parent for the models implementing
+// SortScope, recomputing the positions of the other rows of the list in one transaction. On resources requiring
+// approval the change of each row moved is recorded as pending instead, see rest.RequireApproval.
+func Reorder(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	var body ReorderRequest
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	if err := context.CheckLength(len(body.IDs)); err != nil {
+		return err
+	}
+	var ids []string
+	var unique []interface{}
+	var seen = map[string]bool{}
+	for _, id := range body.IDs {
+		id, err := context.DecodeID(id)
+		if err != nil {
+			return err
+		}
+		var key = keyString(id)
+		if !seen[key] {
+			seen[key] = true
+			ids = append(ids, key)
+			unique = append(unique, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ErrNothingToReorder
+	}
+	if len(context.Schema.PrimaryFields) != 1 {
+		return ErrorReorderKey
+	}
+	var pk = context.Schema.PrimaryFields[0].DBName
+	var table = context.Schema.Table
+	var columns = []string{"`" + table + "`.`" + pk + "` AS id", "`" + table + "`.`position` AS position"}
+	var scope string
+	if obj, ok := context.GetObject().Addr().Interface().(SortScope); ok {
+		scope = obj.SortScope()
+		columns = append(columns, "`"+table+"`.`"+scope+"` AS scope")
+	}
+
+	var given []map[string]interface{}
+	if err := context.ApplyPolicies(context.GetDBO().Table(table)).Select(columns).
+		Where("`"+table+"`.`"+pk+"` IN (?)", unique).Find(&given).Error; err != nil {
+		return err
+	}
+	if len(given) != len(unique) {
+		return rest.ErrorObjectNotExist
+	}
+	var query = context.ApplyPolicies(context.GetDBO().Table(table)).Select(columns)
+	if scope != "" {
+		var parent = given[0]["scope"]
+		for _, row := range given {
+			if keyString(row["scope"]) != keyString(parent) {
+				return ErrMixedSortScope
+			}
+		}
+		if parent == nil {
+			query = query.Where("`" + table + "`.`" + scope + "` IS NULL")
+		} else {
+			query = query.Where("`"+table+"`.`"+scope+"` = ?", parent)
+		}
+	}
+	var rows []map[string]interface{}
+	if err := query.Order("`" + table + "`.`position`, `" + table + "`.`" + pk + "`").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var keys = map[string]interface{}{}
+	var current []string
+	var positions = map[string]int{}
+	for _, row := range rows {
+		var id = keyString(row["id"])
+		keys[id] = row["id"]
+		current = append(current, id)
+		positions[id] = generic.Parse(row["position"]).Int()
+	}
+	var changes = reorder(current, positions, ids)
+	if context.RequiresApproval() {
+		for id, position := range changes {
+			var object = context.GetObject()
+			if err := context.Schema.PrimaryFields[0].Set(context.Request.Context.Context(), object, keys[id]); err != nil {
+				return err
+			}
+			if err := context.RequestApproval(object, map[string]interface{}{"position": position}); err != nil {
+				return err
+			}
+		}
+		context.Response.Data = map[string]interface{}{"rows": len(rows), "pending": len(changes)}
+		return nil
+	}
+	err := context.GetDBO().Transaction(func(tx *gorm.DB) error {
+		for id, position := range changes {
+			if err := tx.Table(table).Where("`"+pk+"` = ?", keys[id]).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	context.Response.Data = map[string]interface{}{"rows": len(rows), "moved": len(changes)}
+	return nil
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestKeyString(t *testing.T) {
+	var tests = []struct {
+		value interface{}
+		want  string
+	}{
+		{float64(3), "3"},
+		{float64(1000000), "1000000"},
+		{int64(42), "42"},
+		{uint64(7), "7"},
+		{[]byte("a1"), "a1"},
+		{"b2", "b2"},
+	}
+	for _, test := range tests {
+		if got := keyString(test.value); got != test.want {
+			t.Errorf("keyString(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestReorder(t *testing.T) {
+	var current = []string{"1", "2", "3", "4"}
+	var positions = map[string]int{"1": 1, "2": 2, "3": 3, "4": 4}
+	var tests = []struct {
+		name      string
+		positions map[string]int
+		ids       []string
+		want      map[string]int
+	}{
+		{"unchanged", positions, []string{"1", "2", "3", "4"}, map[string]int{}},
+		{"full list", positions, []string{"4", "3", "2", "1"}, map[string]int{"4": 1, "3": 2, "2": 3, "1": 4}},
+		{"single row", positions, []string{"3"}, map[string]int{}},
+		{"page", positions, []string{"4", "3"}, map[string]int{"4": 3, "3": 4}},
+		{"scattered", positions, []string{"4", "2"}, map[string]int{"4": 2, "2": 4}},
+		{"duplicates", positions, []string{"2", "2", "1"}, map[string]int{"2": 1, "1": 2}},
+		{"unset positions", map[string]int{}, []string{"2"}, map[string]int{"1": 1, "2": 2, "3": 3, "4": 4}},
+	}
+	for _, test := range tests {
+		if got := reorder(current, test.positions, test.ids); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: reorder() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+type sortTask struct {
+	rest.RequireApproval
+	ID uint `gorm:"primaryKey" json:"id"`
+	Sortable
+}
+
+func (sortTask) TableName() string {
+	return "sort_task"
+}
+
+func TestReorderApproval(t *testing.T) {
+	var db = resttest.Setup(t, sortTask{}, rest.PendingChange{})
+	db.Create(&[]sortTask{{ID: 1, Sortable: Sortable{Position: 1}}, {ID: 2, Sortable: Sortable{Position: 2}}})
+	resttest.AsUser(t, tagAdmin{})
+
+	var page = resttest.Do[map[string]interface{}](t, http.MethodPost, "/admin/rest/sort_task/reorder", ReorderRequest{IDs: []interface{}{2, 1}})
+	if page.Status != http.StatusAccepted || page.Data["pending"] != float64(2) {
+		t.Fatalf("expected the moves to wait for approval, got %+v", page)
+	}
+	var tasks []sortTask
+	if db.Order("id").Find(&tasks); tasks[0].Position != 1 || tasks[1].Position != 2 {
+		t.Errorf("expected the positions to be unchanged, got %+v", tasks)
+	}
+	var pending int64
+	if db.Model(&rest.PendingChange{}).Where("`table` = ?", "sort_task").Count(&pending); pending != 2 {
+		t.Errorf("expected 2 pending changes, got %d", pending)
+	}
+}
+
+type sortItem struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	Sortable
+}
+
+func (sortItem) TableName() string {
+	return "sort_item"
+}
+
+func TestReorderPage(t *testing.T) {
+	var db = resttest.Setup(t, sortItem{})
+	for i := 1; i <= 4; i++ {
+		db.Create(&sortItem{ID: uint(i), Sortable: Sortable{Position: i}})
+	}
+	resttest.AsUser(t, tagAdmin{})
+
+	var page = resttest.Post[map[string]interface{}](t, "/admin/rest/sort_item/reorder", ReorderRequest{IDs: []interface{}{4, 3}})
+	if page.Data["rows"] != float64(4) || page.Data["moved"] != float64(2) {
+		t.Errorf("expected the result in the data of the response, got %v", page.Data)
+	}
+	var items []sortItem
+	db.Order("position").Find(&items)
+	var order []uint
+	for _, item := range items {
+		order = append(order, item.ID)
+	}
+	if !reflect.DeepEqual(order, []uint{1, 2, 4, 3}) {
+		t.Errorf("expected the rows to keep their slots, got %v", order)
+	}
+}
+
+type sortHidden struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	Sortable
+	rest.ObfuscateID
+}
+
+func (sortHidden) TableName() string {
+	return "sort_hidden"
+}
+
+func TestReorderObfuscated(t *testing.T) {
+	var db = resttest.Setup(t, sortHidden{})
+	for i := 1; i <= 3; i++ {
+		db.Create(&sortHidden{ID: uint(i), Sortable: Sortable{Position: i}})
+	}
+	resttest.AsUser(t, tagAdmin{})
+	res, err := rest.GetResource(sortHidden{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page := resttest.Do[map[string]interface{}](t, http.MethodPost, "/admin/rest/sort_hidden/reorder", ReorderRequest{IDs: []interface{}{3, 2}}); page.Success {
+		t.Error("expected the plain ids of an obfuscated resource to be rejected")
+	}
+	resttest.Post[map[string]interface{}](t, "/admin/rest/sort_hidden/reorder", ReorderRequest{IDs: []interface{}{res.IDEncoder().Encode(3), res.IDEncoder().Encode(2)}})
+	var items []sortHidden
+	db.Order("position").Find(&items)
+	var order []uint
+	for _, item := range items {
+		order = append(order, item.ID)
+	}
+	if !reflect.DeepEqual(order, []uint{1, 3, 2}) {
+		t.Errorf("expected the hashed ids to be decoded, got %v", order)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/iesitalia/toolbox/rest"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 	"reflect"
 	"strings"
 )
@@ -51,19 +52,50 @@ func (v *Tag) OnUpdate(db *gorm.DB, object reflect.Value) {
 //
 // This will add a filter to the query such that the column value is checked against the IDs 1, 2, and 3.
 func (v Tag) RestFilter(context *rest.Context, query *gorm.DB, filter map[string]string) {
+	var key = entityKey(query, context.Schema.Table, context.Schema)
 	if filter["condition"] == "contains" {
-		query = query.Where(context.Schema.PrimaryFields[0].DBName+" IN (SELECT `id` FROM tag_entity WHERE `table` = ? AND tag_key IN (?))", context.Schema.Table, strings.Split(filter["value"], ","))
+		query = query.Where(key+" IN (SELECT `id` FROM tag_entity WHERE `table` = ? AND tag_key IN (?))", context.Schema.Table, strings.Split(filter["value"], ","))
 	} else {
-		query = query.Where(context.Schema.PrimaryFields[0].DBName+" IN (SELECT `id` FROM tag_entity WHERE `table` = ? AND tag_key = ?)", context.Schema.Table, strings.Split(filter["value"], ","))
+		query = query.Where(key+" IN (SELECT `id` FROM tag_entity WHERE `table` = ? AND tag_key = ?)", context.Schema.Table, strings.Split(filter["value"], ","))
 	}
 }
 
+// entityID returns the key of the row in tag_entity: its primary key, comma separated for composite keys.
+// It is empty when a primary key is not set.
+func entityID(s *schema.Schema, object reflect.Value) string {
+	var keys []string
+	for _, field := range s.PrimaryFields {
+		v, zero := field.ValueOf(context.Background(), object)
+		if zero {
+			return ""
+		}
+		keys = append(keys, generic.Parse(v).String())
+	}
+	return strings.Join(keys, ",")
+}
+
+// entityKey returns the SQL expression of the key of the rows of the table in tag_entity, see entityID, in the
+// dialect of the database: the columns of composite keys are joined by CONCAT_WS on MySQL, by || otherwise.
+func entityKey(dbo *gorm.DB, table string, s *schema.Schema) string {
+	var columns []string
+	for _, field := range s.PrimaryFields {
+		columns = append(columns, dbo.Statement.Quote(table+"."+field.DBName))
+	}
+	if len(columns) == 1 {
+		return columns[0]
+	}
+	if dbo.Dialector.Name() == "mysql" {
+		return "CONCAT_WS(',', " + strings.Join(columns, ", ") + ")"
+	}
+	return strings.Join(columns, " || ',' || ")
+}
+
 // OnCreateOrUpdate updates or creates tags and tag entities associated with the Tag object in the database.
 // If the Tag object is nil, it sets the tag field to an empty JSON string.
 // Otherwise, it unmarshals the JSON string from the tag field into a dictionary. If unmarshaling fails, it sets the tag field to an empty JSON string.
 // It then iterates through each item in the dictionary and creates TagList and TagEntity objects based on that item. It also keeps track of the tag keys in a separate list.
 // After creating all the necessary objects, it performs the following operations using the DBO:
-// - If there are tags to create, it inserts the tags and tag entities into the database using the "IGNORE" modifier to handle duplicate entries. It also deletes any tag entities that
+// - If there are tags to create, it inserts the tags and tag entities into the database, skipping the existing ones with an ON CONFLICT DO NOTHING clause. It also deletes any tag entities that
 func (v *Tag) OnCreateOrUpdate(db *gorm.DB, object reflect.Value) {
 	if v == nil {
 		err := v.Tag.Scan("{}")
@@ -84,11 +116,9 @@ func (v *Tag) OnCreateOrUpdate(db *gorm.DB, object reflect.Value) {
 		var tags []TagList
 		var tagEntity []TagEntity
 		var tagList []string
-		var id int64
-
-		for _, field := range db.Statement.Schema.PrimaryFields {
-			v, _ := field.ValueOf(context.Background(), object)
-			id = generic.Parse(v).Int64()
+		var id = entityID(db.Statement.Schema, object)
+		if id == "" {
+			return
 		}
 		for _, item := range dict {
 			tags = append(tags, TagList{Key: item.Key, Value: item.Value})
@@ -97,8 +127,8 @@ func (v *Tag) OnCreateOrUpdate(db *gorm.DB, object reflect.Value) {
 		}
 		dbo := evo.GetDBO()
 		if len(tags) > 0 {
-			dbo.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&tags)
-			dbo.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&tagEntity)
+			dbo.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags)
+			dbo.Clauses(clause.OnConflict{DoNothing: true}).Create(&tagEntity)
 			dbo.Where("`table` = ? AND `id` = ? AND `tag_key` NOT IN(?)", db.Statement.Table, id, tagList).Delete(&TagEntity{})
 		} else {
 			dbo.Where("`table` = ? AND `id` = ?", db.Statement.Table, id).Delete(&TagEntity{})
@@ -109,10 +139,9 @@ func (v *Tag) OnCreateOrUpdate(db *gorm.DB, object reflect.Value) {
 
 // OnDelete deletes the TagEntity associated with the Tag object from the database.
 func (v *Tag) OnDelete(db *gorm.DB, object reflect.Value) {
-	var id int64
-	for _, field := range db.Statement.Schema.PrimaryFields {
-		v, _ := field.ValueOf(context.Background(), object)
-		id = generic.Parse(v).Int64()
+	var id = entityID(db.Statement.Schema, object)
+	if id == "" {
+		return
 	}
 	dbo := evo.GetDBO()
 	dbo.Where("`table` = ? AND `id` = ? ", db.Statement.Table, id).Delete(&TagEntity{})
@@ -140,10 +169,12 @@ func (Tag) TableName() string {
 // TagKey is the key of the tag.
 // It is stored in the column "tag_key" and is used as an index in the "tag_entity" table.
 // It is JSON encoded and is available in the JSON field "json:"tag_key,omitempty"".
+// ID is the primary key of the row, comma separated for composite keys, so integer, string and UUID keys
+// are all supported. An entity is tagged once with each key.
 type TagEntity struct {
-	TagKey string `gorm:"column:tag_key;index:tag_entity_idx;fk:tag_list" json:"tag_key,omitempty"`
-	Table  string `gorm:"column:table;index:tag_entity_idx;index:entity_idx" json:"table"`
-	ID     int64  `gorm:"column:id;index:tag_entity_idx;index:entity_idx" json:"id"`
+	TagKey string `gorm:"column:tag_key;uniqueIndex:tag_entity_idx;fk:tag_list" json:"tag_key,omitempty"`
+	Table  string `gorm:"column:table;uniqueIndex:tag_entity_idx;index:entity_idx" json:"table"`
+	ID     string `gorm:"column:id;size:255;uniqueIndex:tag_entity_idx;index:entity_idx" json:"id"`
 }
 
 // TableName returns the name of the table in the database where the TagEntity objects are stored.
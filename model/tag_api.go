@@ -0,0 +1,266 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// TagAutocompleteSize and TagAutocompleteMaxSize bound the number of tags suggested by the autocomplete endpoint.
+var TagAutocompleteSize = 10
+var TagAutocompleteMaxSize = 50
+
+// TagEntitiesSize and TagEntitiesMaxSize bound the number of rows per resource listed for a tag.
+var TagEntitiesSize = 100
+var TagEntitiesMaxSize = 1000
+
+// ErrInvalidTagRename is returned when a rename does not give two different tags.
+var ErrInvalidTagRename = errors.New("rename requires two different tags")
+
+// TagUsage is a tag along with the number of rows using it, listed by the tags endpoint.
+type TagUsage struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// TaggedItem is a row using a tag, listed by the tag entities endpoint.
+type TaggedItem struct {
+	Resource string      `json:"resource"`
+	Data     interface{} `json:"data"`
+}
+
+// RenameTagRequest is the body of the rename endpoint. Renaming to an existing tag merges the tags.
+type RenameTagRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+var tagLikeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// taggedResources returns the resources of the models embedding Tag the user of the request has the
+// permission for, keyed by table.
+func taggedResources(request *evo.Request, permission string) map[string]*rest.Context {
+	var result = map[string]*rest.Context{}
+	for _, resource := range rest.Resources() {
+		var context = resource.NewContext(request, "TAGS")
+		if context.Schema.LookUpField("tag") == nil {
+			continue
+		}
+		if _, ok := context.GetObject().Addr().Interface().(interface{ OnCreateOrUpdate(*gorm.DB, reflect.Value) }); !ok {
+			continue
+		}
+		if permission == "VIEW" && !context.Readable() {
+			continue
+		}
+		if permission != "VIEW" && context.HasPerm(permission) != nil {
+			continue
+		}
+		result[resource.Table] = context
+	}
+	return result
+}
+
+// taggedRows returns the subquery of the keys of the rows of the resource visible to the user, see entityKey.
+func taggedRows(context *rest.Context) *gorm.DB {
+	return context.ApplyPolicies(context.GetDBO().Model(context.GetObject().Addr().Interface())).
+		Select(entityKey(context.GetDBO(), context.Schema.Table, context.Schema))
+}
+
+// tagUsage returns the tags of the list with their counts, most used first.
+func tagUsage(list []TagList, counts map[string]int64) []TagUsage {
+	var result = []TagUsage{}
+	var seen = map[string]bool{}
+	for _, item := range list {
+		if seen[item.Key] {
+			continue
+		}
+		seen[item.Key] = true
+		result = append(result, TagUsage{Key: item.Key, Value: item.Value, Count: counts[item.Key]})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// Tags lists the tags of the rows the user can view with the number of rows using them, most used first,
+// for tag clouds. ?resource= counts the rows of a resource only.
+func Tags(request *evo.Request) interface{} {
+	if request.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	var only = request.Query("resource").String()
+	var counts = map[string]int64{}
+	for table, context := range taggedResources(request, "VIEW") {
+		if only != "" && table != only {
+			continue
+		}
+		var rows []struct {
+			TagKey string
+			Count  int64
+		}
+		if err := context.GetDBO().Model(&TagEntity{}).Select("`tag_key`, COUNT(*) AS count").
+			Where("`table` = ? AND `id` IN (?)", table, taggedRows(context)).Group("tag_key").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			counts[row.TagKey] += row.Count
+		}
+	}
+	var keys = make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	var list []TagList
+	if len(keys) > 0 {
+		if err := evo.GetDBO().Where("`key` IN (?)", keys).Order("`key`").Find(&list).Error; err != nil {
+			return err
+		}
+	}
+	return tagUsage(list, counts)
+}
+
+// TagAutocomplete suggests the tags starting with ?q= among the tags of the rows the user can view, up to
+// ?size= tags.
+func TagAutocomplete(request *evo.Request) interface{} {
+	if request.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	var size = rest.RowLimit(request.Query("size").Int(), TagAutocompleteMaxSize)
+	if request.Query("size").Int() <= 0 {
+		size = TagAutocompleteSize
+	}
+	var q = request.Query("q").String()
+	var seen = map[string]bool{}
+	var keys []string
+	for table, context := range taggedResources(request, "VIEW") {
+		var query = context.GetDBO().Model(&TagEntity{}).Distinct().
+			Where("`table` = ? AND `id` IN (?)", table, taggedRows(context))
+		if q != "" {
+			query = query.Where("`tag_key` LIKE ?", tagLikeEscaper.Replace(q)+"%")
+		}
+		var found []string
+		if err := query.Pluck("tag_key", &found).Error; err != nil {
+			return err
+		}
+		for _, key := range found {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	var list = []TagList{}
+	if len(keys) > 0 {
+		if err := evo.GetDBO().Where("`key` IN (?)", keys).Order("`key`").Limit(size).Find(&list).Error; err != nil {
+			return err
+		}
+	}
+	return list
+}
+
+// TaggedEntities lists the rows using the tag given by the URL, across the resources the user can view,
+// up to ?size= rows per resource.
+func TaggedEntities(request *evo.Request) interface{} {
+	if request.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	var key = request.Param("key").String()
+	var size = rest.RowLimit(request.Query("size").Int(), TagEntitiesMaxSize)
+	if request.Query("size").Int() <= 0 {
+		size = TagEntitiesSize
+	}
+	var contexts = taggedResources(request, "VIEW")
+	var tables = make([]string, 0, len(contexts))
+	for table := range contexts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	var items = []TaggedItem{}
+	for _, table := range tables {
+		var context = contexts[table]
+		var slice = context.GetObjectSlice()
+		var column = entityKey(context.GetDBO(), table, context.Schema)
+		var entities = evo.GetDBO().Model(&TagEntity{}).Select("`id`").Where("`table` = ? AND `tag_key` = ?", table, key)
+		if err := context.ApplyPolicies(context.GetDBO().Model(context.GetObject().Addr().Interface())).
+			Where(column+" IN (?)", entities).Order(column).Limit(size).Find(slice.Addr().Interface()).Error; err != nil {
+			return err
+		}
+		for i := 0; i < slice.Len(); i++ {
+			var row = slice.Index(i)
+			context.ReadRow(row)
+			items = append(items, TaggedItem{Resource: table, Data: context.Action.Resource.Output(row.Addr().Interface())})
+		}
+	}
+	return items
+}
+
+// RenameTag renames a tag, merging it into the target tag when it exists, on the rows using it the user can
+// see in one transaction. The tag columns of the rows are kept in sync and every change is recorded in
+// tag_audit. The user requires the UPDATE permission on every resource using the tag, and the tag is removed
+// from the tag list once no row uses it anymore.
+func RenameTag(request *evo.Request) interface{} {
+	if request.User().Anonymous() {
+		return rest.ErrorUnauthorized
+	}
+	var body RenameTagRequest
+	if err := request.BodyParser(&body); err != nil {
+		return err
+	}
+	body.From, body.To = strings.TrimSpace(body.From), strings.TrimSpace(body.To)
+	if body.From == "" || body.To == "" || body.From == body.To {
+		return ErrInvalidTagRename
+	}
+	var contexts = taggedResources(request, "UPDATE")
+	var user = request.User().UUID()
+	var tagged int
+	err := evo.GetDBO().Transaction(func(tx *gorm.DB) error {
+		var tables []string
+		if err := tx.Model(&TagEntity{}).Distinct().Where("`tag_key` = ?", body.From).Pluck("table", &tables).Error; err != nil {
+			return err
+		}
+		for _, table := range tables {
+			var context, ok = contexts[table]
+			if !ok {
+				return rest.ErrorPermissionDenied
+			}
+			var ids []string
+			if err := tx.Model(&TagEntity{}).Where("`table` = ? AND `tag_key` = ? AND `id` IN (?)", table, body.From, taggedRows(context)).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			var key = entityKey(tx, table, context.Schema)
+			for start := 0; start < len(ids); start += TagBatchSize {
+				var end = start + TagBatchSize
+				if end > len(ids) {
+					end = len(ids)
+				}
+				if err := tagBatch(tx, table, key, ids[start:end], []string{body.To}, []string{body.From}, user); err != nil {
+					return err
+				}
+				tagged += end - start
+			}
+		}
+		var used int64
+		if err := tx.Model(&TagEntity{}).Where("`tag_key` = ?", body.From).Count(&used).Error; err != nil {
+			return err
+		}
+		if used > 0 {
+			return nil
+		}
+		return tx.Where("`key` = ?", body.From).Delete(&TagList{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return map[string]interface{}{"rows": tagged, "from": body.From, "to": body.To}
+}
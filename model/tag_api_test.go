@@ -0,0 +1,110 @@
+package model
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestTagUsage(t *testing.T) {
+	var list = []TagList{
+		{Key: "lead", Value: "lead"},
+		{Key: "vip", Value: "vip"},
+		{Key: "vip", Value: "VIP"},
+		{Key: "archived", Value: "archived"},
+		{Key: "new", Value: "new"},
+	}
+	var counts = map[string]int64{"vip": 5, "new": 2, "lead": 2, "gone": 9}
+	var want = []TagUsage{
+		{Key: "vip", Value: "vip", Count: 5},
+		{Key: "lead", Value: "lead", Count: 2},
+		{Key: "new", Value: "new", Count: 2},
+		{Key: "archived", Value: "archived", Count: 0},
+	}
+	if got := tagUsage(list, counts); !reflect.DeepEqual(got, want) {
+		t.Errorf("tagUsage() = %v, want %v", got, want)
+	}
+	if got := tagUsage(nil, counts); len(got) != 0 {
+		t.Errorf("tagUsage(nil) = %v, want empty", got)
+	}
+}
+
+func TestTagLikeEscaper(t *testing.T) {
+	var tests = map[string]string{
+		"vip":    "vip",
+		"50%":    `50\%`,
+		"a_b":    `a\_b`,
+		`back\s`: `back\\s`,
+	}
+	for input, want := range tests {
+		if got := tagLikeEscaper.Replace(input); got != want {
+			t.Errorf("tagLikeEscaper(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+type tagNote struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	Tag
+	TenantID
+}
+
+func (tagNote) TableName() string {
+	return "tag_note"
+}
+
+type tagAdmin struct {
+	evo.DefaultUserInterface
+}
+
+func (tagAdmin) Anonymous() bool {
+	return false
+}
+
+func (tagAdmin) HasPermission(permission string) bool {
+	return true
+}
+
+var tagRoutes sync.Once
+
+func TestTagsTenant(t *testing.T) {
+	var db = resttest.Setup(t, tagNote{}, TagList{}, TagEntity{})
+	tagRoutes.Do(func() {
+		if err := (App{}).Router(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	db.Create(&[]tagNote{{ID: 1, TenantID: TenantID{TenantID: "north"}}, {ID: 2, TenantID: TenantID{TenantID: "south"}}})
+	db.Create(&[]TagList{{Key: "vip", Value: "vip"}, {Key: "secret", Value: "secret"}})
+	db.Create(&[]TagEntity{{TagKey: "vip", Table: "tag_note", ID: "1"}, {TagKey: "secret", Table: "tag_note", ID: "2"}})
+	resttest.AsUser(t, tagAdmin{})
+	resttest.WithHeader(t, rest.TenantHeader, "north")
+
+	var keys = func(url string) []string {
+		t.Helper()
+		var result []string
+		for _, item := range resttest.Get[[]TagUsage](t, url).Data {
+			result = append(result, item.Key)
+		}
+		return result
+	}
+	if got := keys("/admin/tags"); !reflect.DeepEqual(got, []string{"vip"}) {
+		t.Errorf("expected the tags of the tenant, got %v", got)
+	}
+	if got := keys("/admin/tags/autocomplete?q=s"); len(got) != 0 {
+		t.Errorf("expected the tags of other tenants not to be suggested, got %v", got)
+	}
+
+	resttest.Post[map[string]interface{}](t, "/admin/tags/rename", RenameTagRequest{From: "secret", To: "public"})
+	var entity TagEntity
+	if db.Where("`id` = ?", "2").Take(&entity); entity.TagKey != "secret" {
+		t.Errorf("expected the rows of other tenants to keep their tags, got %+v", entity)
+	}
+	if db.Where("`key` = ?", "secret").Take(&TagList{}).RowsAffected == 0 {
+		t.Errorf("expected the tag used by other tenants to stay listed")
+	}
+}
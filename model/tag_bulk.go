@@ -0,0 +1,200 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagBatchSize specifies the number of rows tagged per SQL batch by the bulk tag endpoint.
+var TagBatchSize = 1000
+
+// ErrNothingToTag is returned by the bulk tag endpoint when no tag is added or removed.
+var ErrNothingToTag = errors.New("no tags to add or remove")
+
+// ErrorTagScope is returned by the bulk tag endpoint when neither ids nor filters select the rows to tag.
+var ErrorTagScope = errors.New("ids or filters are required to select the rows to tag")
+
+// TagAudit records a tag added to or removed from an entity by the bulk tag endpoint.
+type TagAudit struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Table     string    `gorm:"column:table;size:64;index:tag_audit_entity_idx" json:"table"`
+	EntityID  string    `gorm:"column:entity_id;size:255;index:tag_audit_entity_idx" json:"entity_id"`
+	TagKey    string    `gorm:"column:tag_key;size:255" json:"tag_key"`
+	Action    string    `gorm:"column:action;size:8" json:"action"`
+	User      string    `gorm:"column:user;size:36" json:"user"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName returns the name of the table of the TagAudit struct.
+func (TagAudit) TableName() string {
+	return "tag_audit"
+}
+
+// BulkTagRequest is the body of the bulk tag endpoint. IDs are the primary keys of the rows, comma separated
+// for composite keys. When IDs is empty the tags apply to every row matching the filters of the query string.
+type BulkTagRequest struct {
+	IDs    []interface{} `json:"ids"`
+	Add    []string      `json:"add"`
+	Remove []string      `json:"remove"`
+}
+
+// RESTActions registers the bulk tag endpoint on the resources of models embedding Tag.
+// POST /rest/:table/tags {"ids": [1, 2], "add": ["vip"], "remove": ["lead"]}
+func (Tag) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "TAGS",
+			Method:      rest.POST,
+			URL:         "/tags",
+			Handler:     BulkTag,
+			Description: "add and remove tags on the rows given by id or matching the filters",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+	}
+}
+
+// BulkTag adds and removes tags on many rows at once using batched SQL on tag_entity.
+// The tag column of the rows is kept in sync and every change is recorded in tag_audit.
+func BulkTag(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	var body BulkTagRequest
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	if err := context.CheckLength(len(body.IDs)); err != nil {
+		return err
+	}
+	if len(body.Add) == 0 && len(body.Remove) == 0 {
+		return ErrNothingToTag
+	}
+	var table = context.Schema.Table
+	var key = entityKey(context.GetDBO(), table, context.Schema)
+
+	var query = context.ApplyPolicies(context.GetDBO().Table(table))
+	if len(body.IDs) > 0 {
+		var keys []string
+		for _, id := range body.IDs {
+			keys = append(keys, keyString(id))
+		}
+		query = query.Where(key+" IN (?)", keys)
+	} else {
+		var conditions = whereLength(query)
+		var err error
+		if query, err = context.ApplyFilters(query); err != nil {
+			return err
+		}
+		// without filters every row of the table would be tagged
+		if whereLength(query) == conditions {
+			return ErrorTagScope
+		}
+	}
+	var ids []string
+	if err := query.Pluck(key, &ids).Error; err != nil {
+		return err
+	}
+
+	var user = context.User().UUID()
+	var tagged int
+	for start := 0; start < len(ids); start += TagBatchSize {
+		var end = start + TagBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := tagBatch(evo.GetDBO(), table, key, ids[start:end], body.Add, body.Remove, user); err != nil {
+			return err
+		}
+		tagged += end - start
+	}
+	context.Response.Data = map[string]interface{}{"rows": tagged, "add": body.Add, "remove": body.Remove}
+	return nil
+}
+
+// tagBatch applies the tag changes to a batch of rows in a transaction. KeyColumn is the SQL expression of
+// the keys of the rows in tag_entity, see entityKey.
+func tagBatch(db *gorm.DB, table, keyColumn string, ids []string, add, remove []string, user string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var entities []TagEntity
+		var audits []TagAudit
+		for _, id := range ids {
+			for _, key := range add {
+				entities = append(entities, TagEntity{TagKey: key, Table: table, ID: id})
+				audits = append(audits, TagAudit{Table: table, EntityID: id, TagKey: key, Action: "add", User: user})
+			}
+			for _, key := range remove {
+				audits = append(audits, TagAudit{Table: table, EntityID: id, TagKey: key, Action: "remove", User: user})
+			}
+		}
+		if len(add) > 0 {
+			var list []TagList
+			for _, key := range add {
+				list = append(list, TagList{Key: key, Value: key})
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&list).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entities).Error; err != nil {
+				return err
+			}
+		}
+		if len(remove) > 0 {
+			if err := tx.Where("`table` = ? AND `id` IN (?) AND `tag_key` IN (?)", table, ids, remove).Delete(&TagEntity{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var rows []map[string]interface{}
+		if err := tx.Table(table).Select(keyColumn+" AS id, `tag`").Where(keyColumn+" IN (?)", ids).Find(&rows).Error; err != nil {
+			return err
+		}
+		// rows ending with the same tags are updated together, one UPDATE per distinct tag column
+		var updates = map[string][]interface{}{}
+		var order []string
+		for _, row := range rows {
+			var dict = toolbox.Dictionary[string]{}
+			var raw = generic.Parse(row["tag"]).String()
+			if raw != "" {
+				_ = json.Unmarshal([]byte(raw), &dict)
+			}
+			for _, key := range add {
+				if !dict.Has(key) {
+					dict.Set(key, key)
+				}
+			}
+			for _, key := range remove {
+				_ = dict.Delete(key)
+			}
+			b, _ := json.Marshal(dict)
+			if _, ok := updates[string(b)]; !ok {
+				order = append(order, string(b))
+			}
+			updates[string(b)] = append(updates[string(b)], row["id"])
+		}
+		for _, tags := range order {
+			if err := tx.Table(table).Where(keyColumn+" IN (?)", updates[tags]).Update("tag", tags).Error; err != nil {
+				return err
+			}
+		}
+		return tx.CreateInBatches(&audits, TagBatchSize).Error
+	})
+}
+
+// whereLength returns the number of WHERE conditions of the query.
+func whereLength(query *gorm.DB) int {
+	if c, ok := query.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			return len(where.Exprs)
+		}
+	}
+	return 0
+}
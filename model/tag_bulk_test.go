@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+type tagBulkRow struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Status string `gorm:"column:status;size:16" json:"status"`
+	Tag
+}
+
+func (tagBulkRow) TableName() string {
+	return "tag_bulk_row"
+}
+
+func TestBulkTag(t *testing.T) {
+	var db = resttest.Setup(t, tagBulkRow{}, TagList{}, TagEntity{}, TagAudit{})
+	var rows = []tagBulkRow{{ID: 1, Status: "open"}, {ID: 2, Status: "open"}, {ID: 3, Status: "closed"}}
+	for i := range rows {
+		_ = rows[i].Tag.Tag.Scan("{}")
+	}
+	db.Create(&rows)
+	resttest.AsUser(t, tagAdmin{})
+
+	var tags = func() map[uint]map[string]string {
+		t.Helper()
+		var result = map[uint]map[string]string{}
+		var stored []tagBulkRow
+		db.Order("id").Find(&stored)
+		for _, row := range stored {
+			var dict = map[string]string{}
+			var raw []struct{ Key, Value string }
+			_ = json.Unmarshal([]byte(row.Tag.Tag.String()), &raw)
+			for _, item := range raw {
+				dict[item.Key] = item.Value
+			}
+			result[row.ID] = dict
+		}
+		return result
+	}
+
+	if page := resttest.Do[map[string]interface{}](t, "POST", "/admin/rest/tag_bulk_row/tags", BulkTagRequest{Add: []string{"vip"}}); page.Success || page.Error != ErrorTagScope.Error() {
+		t.Fatalf("tagging without ids nor filters answered success %v and error %q, want %q", page.Success, page.Error, ErrorTagScope)
+	}
+	for id, dict := range tags() {
+		if len(dict) != 0 {
+			t.Fatalf("row %d tagged without ids nor filters: %v", id, dict)
+		}
+	}
+
+	if page := resttest.Post[map[string]interface{}](t, "/admin/rest/tag_bulk_row/tags?status[eq]=open", BulkTagRequest{Add: []string{"vip", "lead"}}); page.Data["rows"] != float64(2) {
+		t.Errorf("expected the number of tagged rows in the data of the response, got %v", page.Data)
+	}
+	resttest.Post[map[string]interface{}](t, "/admin/rest/tag_bulk_row/tags", BulkTagRequest{IDs: []interface{}{2, 3}, Remove: []string{"lead"}, Add: []string{"new"}})
+	var got = tags()
+	var want = map[uint][]string{1: {"vip", "lead"}, 2: {"vip", "new"}, 3: {"new"}}
+	for id, keys := range want {
+		if len(got[id]) != len(keys) {
+			t.Errorf("row %d tags = %v, want %v", id, got[id], keys)
+			continue
+		}
+		for _, key := range keys {
+			if _, ok := got[id][key]; !ok {
+				t.Errorf("row %d tags = %v, want %v", id, got[id], keys)
+			}
+		}
+	}
+	var audits int64
+	db.Model(&TagAudit{}).Where("`table` = ?", "tag_bulk_row").Count(&audits)
+	if audits != 8 {
+		t.Errorf("tag audits = %d, want 8", audits)
+	}
+}
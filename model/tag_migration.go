@@ -0,0 +1,79 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/iesitalia/toolbox/migration"
+	"gorm.io/gorm"
+)
+
+// tagEntityMigration converts the tag tables of the versions keying the tagged rows by an integer: the entity ids
+// become strings, the duplicate tag_entity rows are removed and tag_entity_idx is recreated unique, so the tags
+// can be inserted skipping the existing rows.
+var tagEntityMigration = migration.DataMigration{
+	Version:     "0",
+	Key:         "model:tag_entity:unique",
+	Description: "convert tag entity ids to strings and deduplicate tag_entity",
+	Run:         migrateTagEntity,
+}
+
+// migrateTagEntity runs tagEntityMigration using the schema migrator of the database, so it is dialect neutral.
+func migrateTagEntity(m *migration.Migrator) error {
+	var migrator = m.DB.Migrator()
+	for _, item := range []struct {
+		model  interface{}
+		column string
+		field  string
+	}{
+		{&TagEntity{}, "id", "ID"},
+		{&TagAudit{}, "entity_id", "EntityID"},
+	} {
+		if !migrator.HasTable(item.model) {
+			continue
+		}
+		columns, err := migrator.ColumnTypes(item.model)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			var typ = strings.ToLower(column.DatabaseTypeName())
+			if column.Name() == item.column && !strings.Contains(typ, "char") && !strings.Contains(typ, "text") {
+				if err := migrator.AlterColumn(item.model, item.field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if !migrator.HasTable(&TagEntity{}) {
+		return nil
+	}
+
+	var duplicates []TagEntity
+	if err := m.DB.Model(&TagEntity{}).Select("tag_key", "table", "id").
+		Group("tag_key").Group("table").Group("id").Having("COUNT(*) > 1").Find(&duplicates).Error; err != nil {
+		return err
+	}
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range duplicates {
+			var where = map[string]interface{}{"tag_key": item.TagKey, "table": item.Table, "id": item.ID}
+			if err := tx.Where(where).Delete(&TagEntity{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.Progress(int64(len(duplicates)))
+
+	if migrator.HasIndex(&TagEntity{}, "tag_entity_idx") {
+		if err := migrator.DropIndex(&TagEntity{}, "tag_entity_idx"); err != nil {
+			return err
+		}
+	}
+	return migrator.CreateIndex(&TagEntity{}, "tag_entity_idx")
+}
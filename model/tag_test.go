@@ -0,0 +1,110 @@
+package model
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/iesitalia/toolbox/migration"
+	"github.com/iesitalia/toolbox/resttest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type tagIntRow struct {
+	ID uint64 `gorm:"column:id;primaryKey"`
+	Tag
+}
+
+type tagUUIDRow struct {
+	UUID string `gorm:"column:uuid;primaryKey"`
+	Tag
+}
+
+type tagCompositeRow struct {
+	Tenant string `gorm:"column:tenant;primaryKey"`
+	Code   int    `gorm:"column:code;primaryKey"`
+	Tag
+}
+
+// mysqlDialector names the dialect of the test database mysql.
+type mysqlDialector struct {
+	gorm.Dialector
+}
+
+func (mysqlDialector) Name() string {
+	return "mysql"
+}
+
+func TestEntityID(t *testing.T) {
+	var dbo = resttest.Setup(t)
+	var mysql = &gorm.DB{Config: &gorm.Config{Dialector: mysqlDialector{dbo.Dialector}}}
+	mysql.Statement = &gorm.Statement{DB: mysql}
+	var tests = []struct {
+		object interface{}
+		id     string
+		key    string
+		mysql  string
+	}{
+		{&tagIntRow{ID: 42}, "42", "`t`.`id`", "`t`.`id`"},
+		{&tagIntRow{}, "", "`t`.`id`", "`t`.`id`"},
+		{&tagUUIDRow{UUID: "2f1b6c1e-8d7a-4c3e-9b1a-5e6f7a8b9c0d"}, "2f1b6c1e-8d7a-4c3e-9b1a-5e6f7a8b9c0d", "`t`.`uuid`", "`t`.`uuid`"},
+		{&tagCompositeRow{Tenant: "acme", Code: 7}, "acme,7", "`t`.`tenant` || ',' || `t`.`code`", "CONCAT_WS(',', `t`.`tenant`, `t`.`code`)"},
+	}
+	for _, test := range tests {
+		s, err := schema.Parse(test.object, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := entityID(s, reflect.ValueOf(test.object).Elem()); got != test.id {
+			t.Errorf("entityID(%+v) = %q, want %q", test.object, got, test.id)
+		}
+		if got := entityKey(dbo, "t", s); got != test.key {
+			t.Errorf("entityKey(%T) = %q, want %q", test.object, got, test.key)
+		}
+		if got := entityKey(mysql, "t", s); got != test.mysql {
+			t.Errorf("entityKey(%T) on mysql = %q, want %q", test.object, got, test.mysql)
+		}
+	}
+}
+
+func TestTagEntityMigration(t *testing.T) {
+	var dbo = resttest.Setup(t)
+	var migrator = dbo.Migrator()
+	if err := migrator.DropTable(&TagEntity{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = migrator.DropTable(&TagEntity{})
+		_ = dbo.AutoMigrate(&TagEntity{})
+	})
+	// the table of the versions keying the tagged rows by an integer
+	for _, query := range []string{
+		"CREATE TABLE `tag_entity` (`tag_key` text, `table` text, `id` integer)",
+		"CREATE INDEX `tag_entity_idx` ON `tag_entity` (`tag_key`, `table`, `id`)",
+		"INSERT INTO `tag_entity` VALUES ('vip', 'note', 1), ('vip', 'note', 1), ('vip', 'note', 2), ('lead', 'note', 1)",
+	} {
+		if err := dbo.Exec(query).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateTagEntity(&migration.Migrator{Migration: tagEntityMigration, DB: dbo}); err != nil {
+		t.Fatal(err)
+	}
+	var entities []TagEntity
+	dbo.Order("tag_key, id").Find(&entities)
+	var want = []TagEntity{{"lead", "note", "1"}, {"vip", "note", "1"}, {"vip", "note", "2"}}
+	if !reflect.DeepEqual(entities, want) {
+		t.Errorf("entities = %v, want %v", entities, want)
+	}
+	columns, _ := migrator.ColumnTypes(&TagEntity{})
+	for _, column := range columns {
+		if column.Name() == "id" && column.DatabaseTypeName() == "integer" {
+			t.Errorf("expected the id column to be converted to a string")
+		}
+	}
+	if err := dbo.Create(&TagEntity{TagKey: "vip", Table: "note", ID: "2"}).Error; err == nil {
+		t.Errorf("expected tag_entity_idx to be unique")
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// TenantID binds an entity to a tenant.
+// When embedded in a model, rest queries are scoped to the tenant of the request
+// and the tenant is stamped on created and updated objects.
+type TenantID struct {
+	TenantID string `gorm:"column:tenant_id;size:64;index:tenant_id" json:"tenant_id"`
+}
+
+// SetTenant sets the tenant of the entity.
+func (o *TenantID) SetTenant(tenant string) {
+	o.TenantID = tenant
+}
+
+// Scope restricts the query to the rows of the request tenant.
+func (o *TenantID) Scope(context *rest.Context, query *gorm.DB) *gorm.DB {
+	return query.Where("`"+context.Schema.Table+"`.`tenant_id` = ?", context.Tenant())
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/l10n"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// Translatable adds endpoints managing the translations of the columns marked with the `l10n` tag
+// to the resources of the models embedding it, see the l10n package.
+// GET /rest/:table/translations/:pk returns the translations of the row keyed by locale and column.
+// PUT /rest/:table/translations/:pk {"de": {"name": "Stuhl"}} sets them, empty values remove a translation.
+type Translatable struct{}
+
+// RESTActions registers the translation endpoints on the resources of models embedding Translatable.
+func (Translatable) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "TRANSLATIONS",
+			Method:      rest.GET,
+			URL:         "/translations",
+			PKUrl:       true,
+			Handler:     GetTranslations,
+			Description: "get the translations of the row",
+		},
+		{
+			Name:        "SET_TRANSLATIONS",
+			Method:      rest.PUT,
+			URL:         "/translations",
+			PKUrl:       true,
+			Handler:     SetTranslations,
+			Description: "set the translations of the row by locale and column",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+	}
+}
+
+// translationRow returns the primary key of the row given by the request, checking it exists and is visible to the user.
+func translationRow(context *rest.Context) (interface{}, error) {
+	if len(context.Schema.PrimaryFields) != 1 {
+		return nil, fmt.Errorf("translations require a single primary key")
+	}
+	var object = context.GetObject()
+	found, err := context.FindByPrimaryKey(object.Addr().Interface())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, rest.ErrorObjectNotExist
+	}
+	var id, _ = context.Schema.PrimaryFields[0].ValueOf(context.Request.Context.Context(), object)
+	return id, nil
+}
+
+// GetTranslations returns the translations of the row given by the primary key of the request.
+func GetTranslations(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	id, err := translationRow(context)
+	if err != nil {
+		return err
+	}
+	translations, err := l10n.Get(context.Schema.Table, id)
+	if err != nil {
+		return err
+	}
+	context.Response.Data = translations
+	return nil
+}
+
+// SetTranslations stores the translations of the row given by the primary key of the request.
+// Only the columns marked with the `l10n` tag are accepted.
+func SetTranslations(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	id, err := translationRow(context)
+	if err != nil {
+		return err
+	}
+	var body map[string]map[string]string
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	var allowed = map[string]bool{}
+	for _, field := range l10n.Columns(context.Schema) {
+		allowed[field.DBName] = true
+	}
+	for _, columns := range body {
+		for column := range columns {
+			if !allowed[column] {
+				return fmt.Errorf("column %s is not translatable", column)
+			}
+		}
+	}
+	if err := l10n.Set(context.Schema.Table, id, body); err != nil {
+		return err
+	}
+	translations, err := l10n.Get(context.Schema.Table, id)
+	if err != nil {
+		return err
+	}
+	context.Response.Data = translations
+	return nil
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/iesitalia/toolbox/l10n"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+type translatableChair struct {
+	ID   uint64 `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:64" json:"name" l10n:"true"`
+	Translatable
+}
+
+func (translatableChair) TableName() string {
+	return "translatable_chair"
+}
+
+func TestTranslationEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, l10n.Translation{}, translatableChair{})
+	db.Create(&translatableChair{ID: 1, Name: "Chair"})
+	resttest.AsUser(t, tagAdmin{})
+
+	var set = resttest.Put[map[string]map[string]string](t, "/admin/rest/translatable_chair/translations/1", map[string]map[string]string{"de": {"name": "Stuhl"}})
+	if set.Data["de"]["name"] != "Stuhl" {
+		t.Errorf("expected the stored translations in the data of the response, got %v", set.Data)
+	}
+	var got = resttest.Get[map[string]map[string]string](t, "/admin/rest/translatable_chair/translations/1")
+	if len(got.Data) != 1 || got.Data["de"]["name"] != "Stuhl" {
+		t.Errorf("expected the translations in the data of the response, got %v", got.Data)
+	}
+}
@@ -0,0 +1,510 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTreeCycle is returned when a row is moved under itself or one of its descendants.
+var ErrTreeCycle = errors.New("a row can not be moved under itself or its descendants")
+
+// ErrTreeParent is returned when the parent of a row does not exist.
+var ErrTreeParent = errors.New("parent does not exist")
+
+// Tree arranges the rows of the models embedding it in a hierarchy, for category-like resources with a single
+// integer primary key. Path is the materialized path of the row, e.g. /1/4/9/, and Depth its level from 0;
+// both are maintained by the callbacks of the App on create and update, descendants included, in the transaction
+// of the write: the rows and their parents are locked, and a write moving a row under itself or one of its
+// descendants or under a missing parent is rolled back with ErrTreeCycle or ErrTreeParent.
+// GET /rest/:table/tree returns the rows nested under their parents, ?root= restricts it to a subtree.
+// GET /rest/:table/children/:pk lists the direct children of the row.
+// GET /rest/:table/ancestors/:pk lists the ancestors of the row, from the root.
+// POST /rest/:table/move/:pk {"parent_id": 4} moves the row under another parent, null for the root.
+// Updates of parent_id through the update endpoints are checked against cycles the same way.
+type Tree struct {
+	ParentID *uint64 `gorm:"column:parent_id;index" json:"parent_id"`
+	Path     string  `gorm:"column:path;size:767;index" json:"path"`
+	Depth    int     `gorm:"column:depth" json:"depth"`
+	// stored is the path of the row in the database before the update being written.
+	stored string
+}
+
+// tree returns the Tree embedded in a model.
+func (t *Tree) tree() *Tree {
+	return t
+}
+
+// MoveRequest is the body of the move endpoint.
+type MoveRequest struct {
+	ParentID *uint64 `json:"parent_id"`
+}
+
+// RESTActions registers the tree endpoints on the resources of models embedding Tree.
+func (Tree) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "TREE",
+			Method:      rest.GET,
+			URL:         "/tree",
+			Handler:     NestedTree,
+			Description: "return the rows nested under their parents",
+			Permissions: []acl.Permission{rest.ListPermission},
+		},
+		{
+			Name:        "CHILDREN",
+			Method:      rest.GET,
+			URL:         "/children",
+			PKUrl:       true,
+			Handler:     Children,
+			Description: "list the direct children of the row",
+			Permissions: []acl.Permission{rest.ListPermission},
+		},
+		{
+			Name:        "ANCESTORS",
+			Method:      rest.GET,
+			URL:         "/ancestors",
+			PKUrl:       true,
+			Handler:     Ancestors,
+			Description: "list the ancestors of the row from the root",
+			Permissions: []acl.Permission{rest.ListPermission},
+		},
+		{
+			Name:        "MOVE",
+			Method:      rest.POST,
+			URL:         "/move",
+			PKUrl:       true,
+			Handler:     Move,
+			Description: "move the row under another parent",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+	}
+}
+
+// treePath returns the materialized path of a row from the path of its parent.
+func treePath(parent string, id string) string {
+	if parent == "" {
+		parent = "/"
+	}
+	return parent + id + "/"
+}
+
+// treeDepth returns the depth of a materialized path, 0 for the roots.
+func treeDepth(path string) int {
+	return strings.Count(strings.Trim(path, "/"), "/")
+}
+
+// treeAncestors returns the primary keys of the ancestors of the row of a materialized path, from the root.
+func treeAncestors(path string) []string {
+	var ids = strings.Split(strings.Trim(path, "/"), "/")
+	if len(ids) <= 1 {
+		return nil
+	}
+	return ids[:len(ids)-1]
+}
+
+// checkMove rejects moving the row of the path under the parent of the given path.
+func checkMove(path string, parent string) error {
+	if path != "" && strings.HasPrefix(parent, path) {
+		return ErrTreeCycle
+	}
+	return nil
+}
+
+// treeID returns the primary key of the object, empty when the model has not a single primary key or it is not set.
+func treeID(db *gorm.DB, object reflect.Value) string {
+	if len(db.Statement.Schema.PrimaryFields) != 1 {
+		return ""
+	}
+	v, zero := db.Statement.Schema.PrimaryFields[0].ValueOf(context.Background(), object)
+	if zero {
+		return ""
+	}
+	return generic.Parse(v).String()
+}
+
+// parentPath returns the materialized path of the parent, empty for the roots. The parent row is locked until the
+// end of the transaction of the db, if any.
+func parentPath(db *gorm.DB, table, pk string, parent *uint64) (string, error) {
+	if parent == nil {
+		return "", nil
+	}
+	var path []string
+	if err := db.Table(table).Clauses(clause.Locking{Strength: "UPDATE"}).Where("`"+pk+"` = ?", *parent).Limit(1).Pluck("path", &path).Error; err != nil {
+		return "", err
+	}
+	if len(path) == 0 {
+		return "", ErrTreeParent
+	}
+	return path[0], nil
+}
+
+// registerTreeCallbacks registers the callbacks maintaining the trees within the transactions of the writes.
+func registerTreeCallbacks(dbo *gorm.DB) error {
+	var callback Callback
+	if err := dbo.Callback().Update().Before("gorm:update").Register("tree:lock", callback.OnTreeLock); err != nil {
+		return err
+	}
+	if err := dbo.Callback().Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register("tree:create", callback.OnTree); err != nil {
+		return err
+	}
+	return dbo.Callback().Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register("tree:update", callback.OnTree)
+}
+
+// treeOf returns the Tree embedded in a written row.
+func treeOf(object reflect.Value) (*Tree, bool) {
+	if !object.CanAddr() {
+		return nil, false
+	}
+	obj, ok := object.Addr().Interface().(interface{ tree() *Tree })
+	if !ok {
+		return nil, false
+	}
+	return obj.tree(), true
+}
+
+// OnTreeLock reads, before an update, the stored path of the updated rows embedding Tree and locks them until the
+// end of the transaction, so that concurrent moves of a branch are checked one after the other.
+func (c Callback) OnTreeLock(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || len(db.Statement.Schema.PrimaryFields) != 1 {
+		return
+	}
+	var pk = db.Statement.Schema.PrimaryFields[0].DBName
+	var dbo = db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+	for _, object := range modifiedObjects(db.Statement.ReflectValue) {
+		t, ok := treeOf(object)
+		if !ok {
+			continue
+		}
+		var id = treeID(db, object)
+		if id == "" {
+			continue
+		}
+		var path []string
+		if err := dbo.Table(db.Statement.Table).Clauses(clause.Locking{Strength: "UPDATE"}).Where("`"+pk+"` = ?", id).Limit(1).Pluck("path", &path).Error; err != nil {
+			db.AddError(err)
+			return
+		}
+		if len(path) > 0 {
+			t.stored = path[0]
+		}
+	}
+}
+
+// OnTree sets the path and the depth of the created or updated rows embedding Tree and of their descendants.
+// The errors are added to the statement, rolling back its transaction.
+func (c Callback) OnTree(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	for _, object := range modifiedObjects(db.Statement.ReflectValue) {
+		t, ok := treeOf(object)
+		if !ok {
+			continue
+		}
+		if err := t.maintain(db, object); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// maintain keeps the materialized path of the row and its descendants in line with its parent.
+func (t *Tree) maintain(db *gorm.DB, object reflect.Value) error {
+	var id = treeID(db, object)
+	if id == "" {
+		return nil
+	}
+	var table = db.Statement.Table
+	var pk = db.Statement.Schema.PrimaryFields[0].DBName
+	var dbo = db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+	parent, err := parentPath(dbo, table, pk, t.ParentID)
+	if err != nil {
+		return err
+	}
+	var previous = t.stored
+	t.stored = ""
+	var path = treePath(parent, id)
+	if path == previous && path == t.Path {
+		return nil
+	}
+	if err := checkMove(previous, parent); err != nil {
+		return err
+	}
+	t.Path, t.Depth = path, treeDepth(path)
+	if err := dbo.Table(table).Where("`"+pk+"` = ?", id).Updates(map[string]interface{}{"path": t.Path, "depth": t.Depth}).Error; err != nil {
+		return err
+	}
+	if previous == "" || previous == path {
+		return nil
+	}
+	var moved = "? || SUBSTRING(`path`, ?)"
+	if dbo.Dialector.Name() == "mysql" {
+		moved = "CONCAT(?, SUBSTRING(`path`, ?))"
+	}
+	return dbo.Exec("UPDATE `"+table+"` SET `path` = "+moved+", `depth` = `depth` + ? WHERE `path` LIKE ? AND `"+pk+"` <> ?",
+		t.Path, len(previous)+1, t.Depth-treeDepth(previous), previous+"%", id).Error
+}
+
+// treeBeforeUpdate rejects the updates of parent_id moving a row under itself or its descendants.
+func treeBeforeUpdate(context *rest.Context, previous, current interface{}) error {
+	before, ok := previous.(interface{ tree() *Tree })
+	if !ok {
+		return nil
+	}
+	var from, to = before.tree(), current.(interface{ tree() *Tree }).tree()
+	if from.Path != "" && pathParent(from.Path) == parentKey(to.ParentID) {
+		return nil
+	}
+	return treeMovable(context, current, from, to.ParentID)
+}
+
+// pathParent returns the primary key of the parent of the row of a materialized path, empty for the roots.
+// The path is compared rather than parent_id as the previous object of the update hooks shares its pointers.
+func pathParent(path string) string {
+	var ids = treeAncestors(path)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[len(ids)-1]
+}
+
+// parentKey returns the string form of a parent, empty for the roots.
+func parentKey(parent *uint64) string {
+	if parent == nil {
+		return ""
+	}
+	return strconv.FormatUint(*parent, 10)
+}
+
+// treeMovable checks that the row of the tree can be moved under the parent.
+func treeMovable(context *rest.Context, object interface{}, t *Tree, parent *uint64) error {
+	if len(context.Schema.PrimaryFields) != 1 {
+		return errors.New("trees require a single primary key")
+	}
+	if parent != nil && parentKey(parent) == rowKey(context, reflect.Indirect(reflect.ValueOf(object))) {
+		return ErrTreeCycle
+	}
+	path, err := parentPath(context.GetDBO(), context.Schema.Table, context.Schema.PrimaryFields[0].DBName, parent)
+	if err != nil {
+		return err
+	}
+	return checkMove(t.Path, path)
+}
+
+// rowKey returns the primary key of a row of the resource.
+func rowKey(context *rest.Context, row reflect.Value) string {
+	v, _ := context.Schema.PrimaryFields[0].ValueOf(context.Request.Context.Context(), row)
+	return generic.Parse(v).String()
+}
+
+// findTreeRow reads the row given by the primary key of the request along with its Tree.
+func findTreeRow(context *rest.Context) (interface{}, *Tree, error) {
+	var ptr = context.GetObject().Addr().Interface()
+	obj, ok := ptr.(interface{ tree() *Tree })
+	if !ok || len(context.Schema.PrimaryFields) != 1 {
+		return nil, nil, rest.ErrorObjectNotExist
+	}
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, rest.ErrorObjectNotExist
+	}
+	return ptr, obj.tree(), nil
+}
+
+// treeRows reads the rows of the resource visible to the user matching the query.
+func treeRows(context *rest.Context, where func(query *gorm.DB) *gorm.DB) (reflect.Value, error) {
+	var slice = context.GetObjectSlice()
+	var query = context.ApplyPolicies(context.GetDBO().Model(context.GetObject().Addr().Interface()))
+	query = where(query).Limit(rest.RowLimit(0, context.Limits().AllEndpointCap))
+	if err := query.Find(slice.Addr().Interface()).Error; err != nil {
+		return slice, err
+	}
+	for i := 0; i < slice.Len(); i++ {
+		context.ReadRow(slice.Index(i))
+	}
+	return slice, nil
+}
+
+// treeNode is a row of the nested tree.
+type treeNode struct {
+	id     string
+	parent string
+	data   map[string]interface{}
+}
+
+// nestTree nests the nodes under their parents, in the order of the nodes. Nodes whose parent is not
+// listed are roots.
+func nestTree(nodes []treeNode) []map[string]interface{} {
+	var byID = map[string]map[string]interface{}{}
+	for _, node := range nodes {
+		node.data["children"] = []map[string]interface{}{}
+		byID[node.id] = node.data
+	}
+	var roots = []map[string]interface{}{}
+	for _, node := range nodes {
+		if parent, ok := byID[node.parent]; ok && node.parent != node.id {
+			parent["children"] = append(parent["children"].([]map[string]interface{}), node.data)
+		} else {
+			roots = append(roots, node.data)
+		}
+	}
+	return roots
+}
+
+// NestedTree returns the rows nested under their parents, or the subtree of the row given by ?root=.
+// Rows embedding Sortable are ordered by position within their parent.
+func NestedTree(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var table = context.Schema.Table
+	var order = "`" + table + "`.`depth`"
+	if context.Schema.LookUpField("position") != nil {
+		order += ", `" + table + "`.`position`"
+	}
+	var prefix string
+	if root := context.Request.Query("root").String(); root != "" {
+		if len(context.Schema.PrimaryFields) != 1 {
+			return rest.ErrorObjectNotExist
+		}
+		var path []string
+		if err := context.ApplyPolicies(context.GetDBO().Model(context.GetObject().Addr().Interface())).
+			Where("`"+table+"`.`"+context.Schema.PrimaryFields[0].DBName+"` = ?", root).Limit(1).Pluck("`"+table+"`.`path`", &path).Error; err != nil {
+			return err
+		}
+		if len(path) == 0 {
+			return rest.ErrorObjectNotExist
+		}
+		prefix = path[0]
+	}
+	slice, err := treeRows(context, func(query *gorm.DB) *gorm.DB {
+		if prefix != "" {
+			query = query.Where("`"+table+"`.`path` LIKE ?", prefix+"%")
+		}
+		return query.Order(order)
+	})
+	if err != nil {
+		return err
+	}
+	var nodes []treeNode
+	for i := 0; i < slice.Len(); i++ {
+		var row = slice.Index(i)
+		var t = row.Addr().Interface().(interface{ tree() *Tree }).tree()
+		var data map[string]interface{}
+		b, err := json.Marshal(row.Addr().Interface())
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return err
+		}
+		var node = treeNode{id: rowKey(context, row), parent: parentKey(t.ParentID), data: data}
+		nodes = append(nodes, node)
+	}
+	context.Response.Data = nestTree(nodes)
+	return nil
+}
+
+// Children lists the direct children of the row given by the primary key.
+func Children(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	ptr, _, err := findTreeRow(context)
+	if err != nil {
+		return err
+	}
+	var table = context.Schema.Table
+	var id = rowKey(context, reflect.ValueOf(ptr).Elem())
+	var order = "`" + table + "`.`" + context.Schema.PrimaryFields[0].DBName + "`"
+	if context.Schema.LookUpField("position") != nil {
+		order = "`" + table + "`.`position`, " + order
+	}
+	slice, err := treeRows(context, func(query *gorm.DB) *gorm.DB {
+		return query.Where("`"+table+"`.`parent_id` = ?", id).Order(order)
+	})
+	if err != nil {
+		return err
+	}
+	context.Response.Data = slice.Interface()
+	return nil
+}
+
+// Ancestors lists the ancestors of the row given by the primary key, from the root.
+func Ancestors(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	_, t, err := findTreeRow(context)
+	if err != nil {
+		return err
+	}
+	var ids = treeAncestors(t.Path)
+	if len(ids) == 0 {
+		context.Response.Data = reflect.MakeSlice(context.GetObjectSlice().Type(), 0, 0).Interface()
+		return nil
+	}
+	var table = context.Schema.Table
+	slice, err := treeRows(context, func(query *gorm.DB) *gorm.DB {
+		return query.Where("`"+table+"`.`"+context.Schema.PrimaryFields[0].DBName+"` IN (?)", ids)
+	})
+	if err != nil {
+		return err
+	}
+	var rows = make([]reflect.Value, slice.Len())
+	for i := range rows {
+		rows[i] = slice.Index(i)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Addr().Interface().(interface{ tree() *Tree }).tree().Depth < rows[j].Addr().Interface().(interface{ tree() *Tree }).tree().Depth
+	})
+	var result = context.GetObjectSlice()
+	for _, row := range rows {
+		result = reflect.Append(result, row)
+	}
+	context.Response.Data = result.Interface()
+	return nil
+}
+
+// Move moves the row given by the primary key under the parent given in the body, null for the root.
+// The path and the depth of the row and its descendants are updated by the callbacks of the App. On resources
+// requiring approval the move is recorded as a pending change.
+func Move(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	var body MoveRequest
+	if err := context.ParseBody(&body); err != nil {
+		return err
+	}
+	ptr, t, err := findTreeRow(context)
+	if err != nil {
+		return err
+	}
+	if err := treeMovable(context, ptr, t, body.ParentID); err != nil {
+		return err
+	}
+	if context.RequiresApproval() {
+		return context.RequestApproval(reflect.ValueOf(ptr).Elem(), map[string]interface{}{"parent_id": body.ParentID})
+	}
+	t.ParentID = body.ParentID
+	if err := context.GetDBO().Model(ptr).Select("parent_id").Updates(ptr).Error; err != nil {
+		return err
+	}
+	context.Response.Data = ptr
+	return nil
+}
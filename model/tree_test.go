@@ -0,0 +1,192 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+func TestTreePath(t *testing.T) {
+	var tests = []struct {
+		parent    string
+		id        string
+		path      string
+		depth     int
+		ancestors []string
+	}{
+		{"", "1", "/1/", 0, nil},
+		{"/1/", "4", "/1/4/", 1, []string{"1"}},
+		{"/1/4/", "9", "/1/4/9/", 2, []string{"1", "4"}},
+	}
+	for _, test := range tests {
+		var path = treePath(test.parent, test.id)
+		if path != test.path {
+			t.Errorf("treePath(%q, %q) = %q, want %q", test.parent, test.id, path, test.path)
+		}
+		if got := treeDepth(path); got != test.depth {
+			t.Errorf("treeDepth(%q) = %d, want %d", path, got, test.depth)
+		}
+		if got := treeAncestors(path); !reflect.DeepEqual(got, test.ancestors) {
+			t.Errorf("treeAncestors(%q) = %v, want %v", path, got, test.ancestors)
+		}
+	}
+}
+
+func TestCheckMove(t *testing.T) {
+	var tests = []struct {
+		path   string
+		parent string
+		err    error
+	}{
+		{"/1/4/", "/2/", nil},
+		{"/1/4/", "", nil},
+		{"/1/4/", "/1/", nil},
+		{"/1/4/", "/1/4/", ErrTreeCycle},
+		{"/1/4/", "/1/4/9/", ErrTreeCycle},
+		{"/1/4/", "/1/44/", nil},
+		{"", "/1/", nil},
+	}
+	for _, test := range tests {
+		if err := checkMove(test.path, test.parent); !errors.Is(err, test.err) {
+			t.Errorf("checkMove(%q, %q) = %v, want %v", test.path, test.parent, err, test.err)
+		}
+	}
+}
+
+func TestPathParent(t *testing.T) {
+	var four = uint64(4)
+	if got := pathParent("/1/4/9/"); got != parentKey(&four) {
+		t.Errorf("pathParent() = %q, want %q", got, "4")
+	}
+	if got := pathParent("/1/"); got != parentKey(nil) {
+		t.Errorf("pathParent() = %q, want root", got)
+	}
+}
+
+func TestNestTree(t *testing.T) {
+	var nodes = []treeNode{
+		{id: "1", data: map[string]interface{}{"name": "a"}},
+		{id: "2", parent: "1", data: map[string]interface{}{"name": "b"}},
+		{id: "3", parent: "2", data: map[string]interface{}{"name": "c"}},
+		{id: "4", parent: "1", data: map[string]interface{}{"name": "d"}},
+		{id: "5", parent: "99", data: map[string]interface{}{"name": "e"}},
+	}
+	b, err := json.Marshal(nestTree(nodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `[{"children":[{"children":[{"children":[],"name":"c"}],"name":"b"},{"children":[],"name":"d"}],"name":"a"},{"children":[],"name":"e"}]`
+	if string(b) != want {
+		t.Errorf("nestTree() = %s, want %s", b, want)
+	}
+}
+
+type category struct {
+	ID uint64 `gorm:"primaryKey" json:"id"`
+	Tree
+}
+
+func (category) TableName() string {
+	return "tree_category"
+}
+
+var treeCallbacks sync.Once
+
+func TestTreeCallbacks(t *testing.T) {
+	var db = resttest.Setup(t, category{})
+	treeCallbacks.Do(func() {
+		if err := registerTreeCallbacks(db); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var one, two, four = uint64(1), uint64(2), uint64(4)
+	for _, row := range []category{{ID: 1}, {ID: 2, Tree: Tree{ParentID: &one}}, {ID: 3, Tree: Tree{ParentID: &two}}, {ID: 4}} {
+		if err := db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var paths = func() map[uint64]string {
+		var rows []category
+		db.Order("id").Find(&rows)
+		var result = map[uint64]string{}
+		for _, row := range rows {
+			result[row.ID] = row.Path
+		}
+		return result
+	}
+	if got := paths(); !reflect.DeepEqual(got, map[uint64]string{1: "/1/", 2: "/1/2/", 3: "/1/2/3/", 4: "/4/"}) {
+		t.Fatalf("unexpected paths after create: %v", got)
+	}
+
+	var root category
+	db.First(&root, 1)
+	if err := db.Model(&root).Update("parent_id", 3).Error; !errors.Is(err, ErrTreeCycle) {
+		t.Errorf("expected moving a row under its descendant to fail with ErrTreeCycle, got %v", err)
+	}
+	var roots int64
+	db.Model(&category{}).Where("id = 1 AND parent_id IS NULL").Count(&roots)
+	if roots != 1 {
+		t.Errorf("expected the cyclic update to be rolled back")
+	}
+
+	var branch category
+	db.First(&branch, 2)
+	if err := db.Model(&branch).Update("parent_id", 4).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(); !reflect.DeepEqual(got, map[uint64]string{1: "/1/", 2: "/4/2/", 3: "/4/2/3/", 4: "/4/"}) {
+		t.Errorf("expected the branch to move with its descendants, got %v", got)
+	}
+
+	if err := db.Save(&category{ID: 2, Tree: Tree{ParentID: &four}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(); got[2] != "/4/2/" {
+		t.Errorf("expected a save without the path to keep it, got %q", got[2])
+	}
+}
+
+func TestTreeEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, category{})
+	treeCallbacks.Do(func() {
+		if err := registerTreeCallbacks(db); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var one, two = uint64(1), uint64(2)
+	for _, row := range []category{{ID: 1}, {ID: 2, Tree: Tree{ParentID: &one}}, {ID: 3, Tree: Tree{ParentID: &two}}} {
+		if err := db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	resttest.AsUser(t, tagAdmin{})
+
+	var tree = resttest.Get[[]map[string]interface{}](t, "/admin/rest/tree_category/tree")
+	if len(tree.Data) != 1 || tree.Data[0]["id"] != float64(1) || len(tree.Data[0]["children"].([]interface{})) != 1 {
+		t.Errorf("expected the nested rows in the data of the response, got %+v", tree.Data)
+	}
+	var ids = func(rows []category) []uint64 {
+		var result = []uint64{}
+		for _, row := range rows {
+			result = append(result, row.ID)
+		}
+		return result
+	}
+	if children := resttest.Get[[]category](t, "/admin/rest/tree_category/children/1"); !reflect.DeepEqual(ids(children.Data), []uint64{2}) {
+		t.Errorf("expected the children in the data of the response, got %+v", children.Data)
+	}
+	if ancestors := resttest.Get[[]category](t, "/admin/rest/tree_category/ancestors/3"); !reflect.DeepEqual(ids(ancestors.Data), []uint64{1, 2}) {
+		t.Errorf("expected the ancestors in the data of the response, got %+v", ancestors.Data)
+	}
+	if ancestors := resttest.Get[[]category](t, "/admin/rest/tree_category/ancestors/1"); ancestors.Data == nil || len(ancestors.Data) != 0 {
+		t.Errorf("expected no ancestors of a root in the data of the response, got %+v", ancestors.Data)
+	}
+	var moved = resttest.Post[category](t, "/admin/rest/tree_category/move/3", map[string]interface{}{"parent_id": nil})
+	if moved.Data.ID != 3 || moved.Data.ParentID != nil {
+		t.Errorf("expected the moved row in the data of the response, got %+v", moved.Data)
+	}
+}
@@ -0,0 +1,58 @@
+package money
+
+import (
+	"strings"
+	"sync"
+)
+
+// Currency describes an ISO 4217 currency.
+// - Digits: the number of decimal digits of the minor unit, e.g. 2 for EUR and 0 for JPY.
+// - Symbol: the symbol used when formatting, the code is used when empty.
+type Currency struct {
+	Code   string
+	Digits int
+	Symbol string
+}
+
+var currencies = map[string]Currency{}
+var currenciesMu sync.RWMutex
+
+func init() {
+	for _, c := range []Currency{
+		{"EUR", 2, "€"}, {"USD", 2, "$"}, {"GBP", 2, "£"}, {"CHF", 2, "CHF"}, {"JPY", 0, "¥"},
+		{"CNY", 2, "¥"}, {"CAD", 2, "CA$"}, {"AUD", 2, "A$"}, {"NZD", 2, "NZ$"}, {"SEK", 2, "kr"},
+		{"NOK", 2, "kr"}, {"DKK", 2, "kr"}, {"PLN", 2, "zł"}, {"CZK", 2, "Kč"}, {"HUF", 2, "Ft"},
+		{"RON", 2, "lei"}, {"BGN", 2, "лв"}, {"RSD", 2, "RSD"}, {"TRY", 2, "₺"}, {"RUB", 2, "₽"},
+		{"UAH", 2, "₴"}, {"ILS", 2, "₪"}, {"AED", 2, "AED"}, {"SAR", 2, "SAR"}, {"INR", 2, "₹"},
+		{"KRW", 0, "₩"}, {"HKD", 2, "HK$"}, {"SGD", 2, "S$"}, {"BRL", 2, "R$"}, {"MXN", 2, "MX$"},
+		{"ARS", 2, "ARS"}, {"CLP", 0, "CLP"}, {"ZAR", 2, "R"}, {"EGP", 2, "E£"}, {"ISK", 0, "kr"},
+		{"VND", 0, "₫"}, {"BHD", 3, "BHD"}, {"KWD", 3, "KWD"}, {"OMR", 3, "OMR"}, {"JOD", 3, "JOD"},
+		{"TND", 3, "TND"}, {"IQD", 3, "IQD"}, {"LYD", 3, "LYD"},
+	} {
+		currencies[c.Code] = c
+	}
+}
+
+// Register adds or replaces a currency.
+func Register(c Currency) {
+	c.Code = strings.ToUpper(c.Code)
+	currenciesMu.Lock()
+	currencies[c.Code] = c
+	currenciesMu.Unlock()
+}
+
+// Lookup returns the currency of the code.
+func Lookup(code string) (Currency, bool) {
+	currenciesMu.RLock()
+	defer currenciesMu.RUnlock()
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// symbol returns the symbol of the currency, or its code.
+func (c Currency) symbol(code string) string {
+	if c.Symbol != "" {
+		return c.Symbol
+	}
+	return code
+}
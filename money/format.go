@@ -0,0 +1,83 @@
+package money
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Style describes how the amounts are written in a locale.
+// - Decimal, Group: the decimal and thousands separators.
+// - Suffix: the symbol follows the amount, separated by a space.
+type Style struct {
+	Decimal string
+	Group   string
+	Suffix  bool
+}
+
+// Styles maps locales and languages to their style, locales take precedence over their language.
+var Styles = map[string]Style{
+	"en":    {Decimal: ".", Group: ","},
+	"it":    {Decimal: ",", Group: ".", Suffix: true},
+	"de":    {Decimal: ",", Group: ".", Suffix: true},
+	"de-CH": {Decimal: ".", Group: "’"},
+	"it-CH": {Decimal: ".", Group: "’"},
+	"es":    {Decimal: ",", Group: ".", Suffix: true},
+	"pt":    {Decimal: ",", Group: ".", Suffix: true},
+	"fr":    {Decimal: ",", Group: " ", Suffix: true},
+	"nl":    {Decimal: ",", Group: "."},
+}
+
+// DefaultStyle is used for the locales without a style.
+var DefaultStyle = Styles["en"]
+
+// StyleOf returns the style of the locale, e.g. it-IT, it_IT or it.
+// The first locale of an Accept-Language like list is used.
+func StyleOf(locale string) Style {
+	if idx := strings.IndexAny(locale, ",;"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	var language, region, _ = strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	language = strings.ToLower(language)
+	if style, ok := Styles[language+"-"+strings.ToUpper(region)]; ok && region != "" {
+		return style
+	}
+	if style, ok := Styles[language]; ok {
+		return style
+	}
+	return DefaultStyle
+}
+
+// Format returns the amount written for the locale with the symbol of its currency,
+// e.g. "€1,234.50" in en and "1.234,50 €" in it.
+func (m Money) Format(locale string) string {
+	var style = StyleOf(locale)
+	var c, _ = Lookup(m.Currency)
+	var decimal = strings.TrimPrefix(m.Decimal(), "-")
+	var integer, fraction, _ = strings.Cut(decimal, ".")
+
+	var b strings.Builder
+	if m.Amount < 0 {
+		b.WriteString("-")
+	}
+	if !style.Suffix {
+		var symbol = []rune(c.symbol(m.Currency))
+		b.WriteString(string(symbol))
+		// alphabetic symbols such as CHF are separated from the amount
+		if len(symbol) > 0 && unicode.IsLetter(symbol[len(symbol)-1]) {
+			b.WriteString(" ")
+		}
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(style.Group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(style.Decimal + fraction)
+	}
+	if style.Suffix {
+		b.WriteString(" " + c.symbol(m.Currency))
+	}
+	return b.String()
+}
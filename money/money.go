@@ -0,0 +1,291 @@
+// Package money represents amounts of money as an integer number of minor units of an ISO 4217 currency,
+// so sums and splits never lose cents to floating point rounding.
+//
+// Models store a Money in two columns by embedding it with a prefix:
+//
+//	type Order struct {
+//		ID    uint64      `gorm:"primaryKey"`
+//		Total money.Money `gorm:"embedded;embeddedPrefix:total_" json:"total"`
+//	}
+//
+// which maps to the total_amount and total_currency columns, and is marshaled as
+// {"amount":"12.50","currency":"EUR"}.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrorCurrencyMismatch is returned when an operation is applied to amounts of different currencies.
+var ErrorCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrorUnknownCurrency is returned when the currency is not a known ISO 4217 code.
+var ErrorUnknownCurrency = errors.New("unknown currency")
+
+// ErrorOverflow is returned when the result of an operation does not fit in the minor units.
+var ErrorOverflow = errors.New("amount overflow")
+
+// ErrorInvalidAmount is returned when a decimal amount can not be parsed.
+var ErrorInvalidAmount = errors.New("invalid amount")
+
+// ErrorPrecision is returned when a decimal amount has more digits than the minor units of its currency.
+var ErrorPrecision = errors.New("amount exceeds the precision of the currency")
+
+// Money is an amount in the minor units of a currency, e.g. 1250 EUR is 12.50 €.
+type Money struct {
+	Amount   int64  `gorm:"column:amount" json:"-"`
+	Currency string `gorm:"column:currency;size:3" json:"-"`
+}
+
+// New returns the amount of minor units of the currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Parse returns the decimal amount of the currency, e.g. Parse("12.5", "EUR") is 1250 EUR.
+func Parse(amount string, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	var c, ok = Lookup(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s", ErrorUnknownCurrency, currency)
+	}
+	var s = strings.TrimSpace(amount)
+	var negative = strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	var integer, fraction, _ = strings.Cut(s, ".")
+	if integer == "" && fraction == "" || strings.ContainsAny(integer+fraction, "+-") {
+		return Money{}, fmt.Errorf("%w: %q", ErrorInvalidAmount, amount)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > c.Digits {
+		return Money{}, fmt.Errorf("%w: %q", ErrorPrecision, amount)
+	}
+	var digits = integer + fraction + strings.Repeat("0", c.Digits-len(fraction))
+	if negative {
+		digits = "-" + digits
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrorOverflow
+		}
+		return Money{}, fmt.Errorf("%w: %q", ErrorInvalidAmount, amount)
+	}
+	return Money{Amount: v, Currency: currency}, nil
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Validate returns an error if the currency is unknown.
+func (m Money) Validate() error {
+	if _, ok := Lookup(m.Currency); !ok {
+		return fmt.Errorf("%w: %s", ErrorUnknownCurrency, m.Currency)
+	}
+	return nil
+}
+
+// same returns an error if the amounts have different currencies. A zero amount without currency matches any.
+func (m Money) same(other Money) (string, error) {
+	switch {
+	case m.Currency == other.Currency:
+		return m.Currency, nil
+	case m.Currency == "" && m.Amount == 0:
+		return other.Currency, nil
+	case other.Currency == "" && other.Amount == 0:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrorCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// Add returns the sum of the amounts.
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.same(other)
+	if err != nil {
+		return Money{}, err
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) || (other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrorOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, nil
+}
+
+// Sub returns the difference of the amounts.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrorOverflow
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Neg returns the opposite amount.
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Cmp compares the amounts, returning -1, 0 or +1.
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.same(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Mul returns the amount multiplied by n.
+func (m Money) Mul(n int64) (Money, error) {
+	var v = new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !v.IsInt64() {
+		return Money{}, ErrorOverflow
+	}
+	return Money{Amount: v.Int64(), Currency: m.Currency}, nil
+}
+
+// MulRate returns the amount multiplied by numerator/denominator, rounded half to even to the minor unit,
+// e.g. MulRate(22, 100) for a 22% tax.
+func (m Money) MulRate(numerator, denominator int64) (Money, error) {
+	if denominator == 0 {
+		return Money{}, ErrorInvalidAmount
+	}
+	var r = new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator)), big.NewInt(denominator))
+	var v = roundHalfEven(r)
+	if !v.IsInt64() {
+		return Money{}, ErrorOverflow
+	}
+	return Money{Amount: v.Int64(), Currency: m.Currency}, nil
+}
+
+func roundHalfEven(r *big.Rat) *big.Int {
+	var q, rem = new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	// compare twice the remainder with the denominator to find the nearest integer
+	var twice = new(big.Int).Abs(new(big.Int).Mul(rem, big.NewInt(2)))
+	var cmp = twice.Cmp(r.Denom())
+	if cmp > 0 || (cmp == 0 && q.Bit(0) == 1) {
+		if rem.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Allocate splits the amount in parts proportional to the ratios without losing minor units:
+// the remainder is given one unit at a time to the first parts, so Allocate(1, 1, 1) of 100 is 34, 33, 33.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("%w: negative ratio", ErrorInvalidAmount)
+		}
+		total += int64(ratio)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: ratios sum to zero", ErrorInvalidAmount)
+	}
+	var result = make([]Money, len(ratios))
+	var remainder = m.Amount
+	for i, ratio := range ratios {
+		// big.Int.Quo truncates towards zero, so the remainder has the sign of the amount
+		var share = new(big.Int).Quo(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(int64(ratio))), big.NewInt(total))
+		result[i] = Money{Amount: share.Int64(), Currency: m.Currency}
+		remainder -= share.Int64()
+	}
+	var unit int64 = 1
+	if remainder < 0 {
+		unit = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(result) {
+		if ratios[i] == 0 {
+			continue
+		}
+		result[i].Amount += unit
+		remainder -= unit
+	}
+	return result, nil
+}
+
+// Split divides the amount in n parts differing by at most one minor unit.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: invalid number of parts %d", ErrorInvalidAmount, n)
+	}
+	var ratios = make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal returns the amount in major units, e.g. "12.50" for 1250 EUR.
+func (m Money) Decimal() string {
+	var c, _ = Lookup(m.Currency)
+	var digits = strconv.FormatUint(abs(m.Amount), 10)
+	var sign = ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if c.Digits == 0 {
+		return sign + digits
+	}
+	if len(digits) <= c.Digits {
+		digits = strings.Repeat("0", c.Digits-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-c.Digits] + "." + digits[len(digits)-c.Digits:]
+}
+
+func abs(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// String returns the decimal amount followed by the currency, e.g. "12.50 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type jsonMoney struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string along with the currency.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON decodes the amount given as a decimal string or number and validates the currency.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*m = Money{}
+		return nil
+	}
+	var v jsonMoney
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount.String(), v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,161 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		amount   string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"12.5", "eur", 1250, nil},
+		{"-0.01", "EUR", -1, nil},
+		{".5", "USD", 50, nil},
+		{"1000", "JPY", 1000, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"1.230", "EUR", 123, nil},
+		{"1.234", "EUR", 0, ErrorPrecision},
+		{"1.5", "JPY", 0, ErrorPrecision},
+		{"abc", "EUR", 0, ErrorInvalidAmount},
+		{"", "EUR", 0, ErrorInvalidAmount},
+		{"1", "XXX", 0, ErrorUnknownCurrency},
+		{"99999999999999999999", "EUR", 0, ErrorOverflow},
+	}
+	for _, test := range tests {
+		m, err := Parse(test.amount, test.currency)
+		if !errors.Is(err, test.err) || (err == nil && m.Amount != test.want) {
+			t.Errorf("Parse(%q, %q) = %v, %v, want %d, %v", test.amount, test.currency, m, err, test.want, test.err)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	var a, b = New(1050, "EUR"), New(-50, "EUR")
+	if sum, err := a.Add(b); err != nil || sum != New(1000, "EUR") {
+		t.Errorf("Add = %v, %v", sum, err)
+	}
+	if diff, err := a.Sub(b); err != nil || diff.Amount != 1100 {
+		t.Errorf("Sub = %v, %v", diff, err)
+	}
+	if _, err := a.Add(New(1, "USD")); !errors.Is(err, ErrorCurrencyMismatch) {
+		t.Errorf("Add of different currencies = %v", err)
+	}
+	if sum, err := (Money{}).Add(a); err != nil || sum != a {
+		t.Errorf("Add to zero = %v, %v", sum, err)
+	}
+	if _, err := New(math.MaxInt64, "EUR").Add(New(1, "EUR")); !errors.Is(err, ErrorOverflow) {
+		t.Errorf("Add overflow = %v", err)
+	}
+	if _, err := New(math.MaxInt64/2+1, "EUR").Mul(2); !errors.Is(err, ErrorOverflow) {
+		t.Errorf("Mul overflow = %v", err)
+	}
+	if cmp, err := a.Cmp(b); err != nil || cmp != 1 {
+		t.Errorf("Cmp = %d, %v", cmp, err)
+	}
+}
+
+func TestMulRate(t *testing.T) {
+	var tests = []struct {
+		amount      int64
+		numerator   int64
+		denominator int64
+		want        int64
+	}{
+		{1000, 22, 100, 220},
+		{25, 1, 10, 2},
+		{35, 1, 10, 4},
+		{-25, 1, 10, -2},
+		{-35, 1, 10, -4},
+		{1, 2, 3, 1},
+	}
+	for _, test := range tests {
+		m, err := New(test.amount, "EUR").MulRate(test.numerator, test.denominator)
+		if err != nil || m.Amount != test.want {
+			t.Errorf("MulRate(%d, %d/%d) = %v, %v, want %d", test.amount, test.numerator, test.denominator, m, err, test.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	var tests = []struct {
+		amount int64
+		ratios []int
+		want   []int64
+	}{
+		{100, []int{1, 1, 1}, []int64{34, 33, 33}},
+		{-100, []int{1, 1, 1}, []int64{-34, -33, -33}},
+		{5, []int{3, 7}, []int64{2, 3}},
+		{10, []int{0, 1, 1}, []int64{0, 5, 5}},
+		{1, []int{0, 1, 1}, []int64{0, 1, 0}},
+	}
+	for _, test := range tests {
+		parts, err := New(test.amount, "EUR").Allocate(test.ratios...)
+		if err != nil || len(parts) != len(test.want) {
+			t.Fatalf("Allocate(%d, %v) = %v, %v", test.amount, test.ratios, parts, err)
+		}
+		for i := range parts {
+			if parts[i].Amount != test.want[i] {
+				t.Errorf("Allocate(%d, %v) = %v, want %v", test.amount, test.ratios, parts, test.want)
+				break
+			}
+		}
+	}
+	if _, err := New(1, "EUR").Allocate(0, 0); err == nil {
+		t.Error("Allocate with zero ratios should fail")
+	}
+	if parts, err := New(10, "EUR").Split(4); err != nil || parts[0].Amount != 3 || parts[3].Amount != 2 {
+		t.Errorf("Split = %v, %v", parts, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		money  Money
+		locale string
+		want   string
+	}{
+		{New(123450, "EUR"), "en", "€1,234.50"},
+		{New(123450, "EUR"), "it-IT", "1.234,50 €"},
+		{New(-5, "EUR"), "it", "-0,05 €"},
+		{New(123456789, "CHF"), "de-CH", "CHF 1’234’567.89"},
+		{New(1000000, "JPY"), "en-US", "¥1,000,000"},
+		{New(100, "USD"), "xx", "$1.00"},
+		{New(1234, "KWD"), "en", "KWD 1.234"},
+	}
+	for _, test := range tests {
+		if got := test.money.Format(test.locale); got != test.want {
+			t.Errorf("Format(%v, %q) = %q, want %q", test.money, test.locale, got, test.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	b, err := json.Marshal(New(-1205, "EUR"))
+	if err != nil || string(b) != `{"amount":"-12.05","currency":"EUR"}` {
+		t.Errorf("Marshal = %s, %v", b, err)
+	}
+	var tests = []struct {
+		json string
+		want Money
+		err  bool
+	}{
+		{`{"amount":"12.05","currency":"eur"}`, New(1205, "EUR"), false},
+		{`{"amount":12.5,"currency":"USD"}`, New(1250, "USD"), false},
+		{`null`, Money{}, false},
+		{`{"amount":"1.001","currency":"EUR"}`, Money{}, true},
+		{`{"amount":"1","currency":"XYZ"}`, Money{}, true},
+	}
+	for _, test := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(test.json), &m)
+		if (err != nil) != test.err || (err == nil && m != test.want) {
+			t.Errorf("Unmarshal(%s) = %v, %v", test.json, m, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/mail"
+)
+
+// PREFIX specifies the prefix for notification routes in the admin panel.
+var PREFIX = "/admin"
+
+// ErrorUnauthorized represents an error indicating that the request is not authenticated.
+var ErrorUnauthorized = errors.New("unauthorized")
+
+// MaxListSize bounds the number of notifications returned by a single list call.
+var MaxListSize = 100
+
+type App struct {
+}
+
+// Register registers the notification model and the default template of the email channel.
+func (a App) Register() error {
+	db.UseModel(Notification{})
+	mail.RegisterTemplate(NotificationTemplate, "$title", "Hi $user.FirstName,\n\n$title", "")
+	return nil
+}
+
+// Router sets up the endpoints of the notifications of the current user.
+// GET /notifications?unread=1&before=<id>&limit=20 lists the latest notifications.
+// GET /notifications/count returns the number of unread notifications.
+// GET /notifications/stream streams the new notifications as server-sent events.
+// POST /notifications/:id/read marks a notification as read.
+// POST /notifications/read marks every notification as read.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/notifications", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			return ErrorUnauthorized
+		}
+		var limit = request.Query("limit").Int()
+		if limit <= 0 || limit > MaxListSize {
+			limit = MaxListSize
+		}
+		items, err := List(request.User().UUID(), request.Query("unread").Bool(), request.Query("before").Uint64(), limit)
+		if err != nil {
+			return err
+		}
+		return items
+	})
+	evo.Get(PREFIX+"/notifications/count", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			return ErrorUnauthorized
+		}
+		count, err := Unread(request.User().UUID())
+		if err != nil {
+			return err
+		}
+		return Count{Unread: count}
+	})
+	evo.Get(PREFIX+"/notifications/stream", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			return ErrorUnauthorized
+		}
+		Stream(request)
+		return nil
+	})
+	evo.Post(PREFIX+"/notifications/read", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			return ErrorUnauthorized
+		}
+		return MarkRead(request.User().UUID())
+	})
+	evo.Post(PREFIX+"/notifications/:id/read", func(request *evo.Request) interface{} {
+		if request.User().Anonymous() {
+			return ErrorUnauthorized
+		}
+		return MarkRead(request.User().UUID(), request.Param("id").Uint64())
+	})
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+// Shutdown ends the open notification streams.
+func (a App) Shutdown(ctx context.Context) error {
+	return CloseStreams(ctx)
+}
+
+func (a App) Name() string {
+	return "notifications"
+}
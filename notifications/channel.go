@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/circuit"
+	"github.com/iesitalia/toolbox/httpclient"
+	"github.com/iesitalia/toolbox/mail"
+	"github.com/iesitalia/toolbox/model"
+)
+
+// ErrorUnknownChannel is returned when a route names a channel that is not registered.
+var ErrorUnknownChannel = errors.New("unknown notification channel")
+
+// Built-in channels.
+// - in_app: wakes up the streams of the user, the notification is already stored for the feed.
+// - email: sends the NotificationTemplate to the email address of the user.
+// - webhook: posts the notification to the Webhooks.
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// NotificationTemplate is the mail template of the email channel. The title, the type and the payload
+// of the notification are available to the template as $title, $type and $payload.
+const NotificationTemplate = "notification"
+
+// DefaultChannels are the channels of the types without route.
+var DefaultChannels = []string{ChannelInApp}
+
+// Routes maps notification types to the channels they are delivered to.
+var Routes = map[string][]string{}
+
+// Webhooks is the list of URLs receiving the notifications routed to the webhook channel.
+var Webhooks []string
+
+// WebhookClient is the client posting the notifications to the webhooks.
+var WebhookClient = httpclient.New(httpclient.WithTimeout(10 * time.Second))
+
+// Channel delivers a notification.
+type Channel interface {
+	Deliver(ctx context.Context, notification *Notification) error
+}
+
+// ChannelFunc adapts a function to the Channel interface.
+type ChannelFunc func(ctx context.Context, notification *Notification) error
+
+// Deliver calls f(ctx, notification).
+func (f ChannelFunc) Deliver(ctx context.Context, notification *Notification) error {
+	return f(ctx, notification)
+}
+
+var channels = map[string]Channel{
+	ChannelInApp:   ChannelFunc(deliverInApp),
+	ChannelEmail:   ChannelFunc(deliverEmail),
+	ChannelWebhook: ChannelFunc(deliverWebhook),
+}
+var channelsMu sync.RWMutex
+
+// RegisterChannel adds a channel, or replaces the channel with the same name.
+func RegisterChannel(name string, channel Channel) {
+	channelsMu.Lock()
+	channels[name] = channel
+	channelsMu.Unlock()
+}
+
+func getChannel(name string) (Channel, bool) {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	channel, ok := channels[name]
+	return channel, ok
+}
+
+// channelsOf returns the channels of a notification type.
+func channelsOf(typ string) []string {
+	if route, ok := Routes[typ]; ok {
+		return route
+	}
+	return DefaultChannels
+}
+
+func deliverInApp(ctx context.Context, notification *Notification) error {
+	broadcast(notification.User)
+	return nil
+}
+
+func deliverEmail(ctx context.Context, notification *Notification) error {
+	var user model.User
+	if err := db.WithContext(ctx).Where("uuid = ?", notification.User).Take(&user).Error; err != nil {
+		return err
+	}
+	return mail.Send(NotificationTemplate, &user, map[string]interface{}{
+		"title":   notification.Title,
+		"type":    notification.Type,
+		"payload": notification.Payload,
+	})
+}
+
+// deliverWebhook posts the notification to every webhook through a circuit breaker named webhook:<url>.
+func deliverWebhook(ctx context.Context, notification *Notification) error {
+	var errs []error
+	for _, url := range Webhooks {
+		var url = url
+		errs = append(errs, circuit.Get("webhook:"+url).Do(func() error {
+			return WebhookClient.Post(ctx, url, notification, nil)
+		}))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+// Package notifications stores the notifications of the users and fans them out to delivery channels.
+//
+//	err := notifications.Notify(ctx, &notifications.Notification{
+//		User:    user.UUID,
+//		Type:    "order.shipped",
+//		Title:   "Your order has shipped",
+//		Payload: map[string]interface{}{"order": order.ID},
+//	})
+//
+// Every notification is stored and shown in the in-app feed, the Routes of its type select the other
+// channels it is delivered to, e.g. Routes["order.shipped"] = []string{notifications.ChannelInApp, notifications.ChannelEmail}.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+)
+
+// ErrorNoUser is returned when a notification has no recipient user.
+var ErrorNoUser = errors.New("notification has no user")
+
+// DeliveryTimeout limits the time of the delivery of a notification to each channel.
+var DeliveryTimeout = 30 * time.Second
+
+// Notification is a message to a user, read when ReadAt is set.
+type Notification struct {
+	ID        uint64                 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	User      string                 `gorm:"column:user;size:36;index:notification_user" json:"user"`
+	Type      string                 `gorm:"column:type;size:64" json:"type"`
+	Title     string                 `gorm:"column:title;size:255" json:"title"`
+	Payload   map[string]interface{} `gorm:"column:payload;type:text;serializer:json" json:"payload"`
+	ReadAt    *time.Time             `gorm:"column:read_at;index:notification_user" json:"read_at"`
+	CreatedAt time.Time              `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName returns the name of the table for the Notification struct.
+func (Notification) TableName() string {
+	return "notification"
+}
+
+// Notify stores the notification and delivers it in background to the channels of its type.
+func Notify(ctx context.Context, notification *Notification) error {
+	if notification.User == "" {
+		return ErrorNoUser
+	}
+	if err := db.WithContext(ctx).Create(notification).Error; err != nil {
+		return err
+	}
+	var n = *notification
+	go deliver(context.Background(), &n)
+	return nil
+}
+
+// deliver sends the notification to each channel of its type, logging the failed deliveries.
+func deliver(ctx context.Context, notification *Notification) map[string]error {
+	var errs = map[string]error{}
+	for _, name := range channelsOf(notification.Type) {
+		channel, ok := getChannel(name)
+		if !ok {
+			errs[name] = ErrorUnknownChannel
+		} else {
+			var ctx, cancel = context.WithTimeout(ctx, DeliveryTimeout)
+			if err := channel.Deliver(ctx, notification); err != nil {
+				errs[name] = err
+			}
+			cancel()
+		}
+		if err, ok := errs[name]; ok {
+			logger.Error("unable to deliver notification", "id", notification.ID, "type", notification.Type, "channel", name, "error", err.Error())
+		}
+	}
+	return errs
+}
+
+// List returns the latest notifications of the user with an id lower than before when it is not zero,
+// only the unread ones when unread is set.
+func List(user string, unread bool, before uint64, limit int) ([]Notification, error) {
+	var result []Notification
+	var query = db.Where("`user` = ?", user).Order("id DESC").Limit(limit)
+	if unread {
+		query = query.Where("read_at IS NULL")
+	}
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+	return result, query.Find(&result).Error
+}
+
+// Unread returns the number of unread notifications of the user.
+func Unread(user string) (int64, error) {
+	var count int64
+	return count, db.Model(&Notification{}).Where("`user` = ? AND read_at IS NULL", user).Count(&count).Error
+}
+
+// MarkRead marks the notifications of the user with the given ids as read, or all of them when no id is given.
+func MarkRead(user string, ids ...uint64) error {
+	var query = db.Model(&Notification{}).Where("`user` = ? AND read_at IS NULL", user)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if err := query.UpdateColumn("read_at", time.Now()).Error; err != nil {
+		return err
+	}
+	broadcast(user)
+	return nil
+}
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestDeliver(t *testing.T) {
+	var delivered []string
+	RegisterChannel("test", ChannelFunc(func(ctx context.Context, notification *Notification) error {
+		delivered = append(delivered, "test:"+notification.Title)
+		return nil
+	}))
+	RegisterChannel("failing", ChannelFunc(func(ctx context.Context, notification *Notification) error {
+		return errors.New("down")
+	}))
+	Routes = map[string][]string{
+		"order.shipped": {"test", "failing"},
+		"order.lost":    {"missing", "test"},
+		"silent":        {},
+	}
+	defer func() { Routes = map[string][]string{} }()
+
+	var tests = []struct {
+		typ       string
+		delivered string
+		errors    string
+	}{
+		{"order.shipped", "[test:title]", "[failing]"},
+		{"order.lost", "[test:title]", "[missing]"},
+		{"silent", "[]", "[]"},
+		{"other", "[]", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			delivered = []string{}
+			var errs = deliver(context.Background(), &Notification{User: "u1", Type: tt.typ, Title: "title"})
+			var names = []string{}
+			for name := range errs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if got := fmt.Sprint(delivered); got != tt.delivered {
+				t.Errorf("delivered = %s, want %s", got, tt.delivered)
+			}
+			if got := fmt.Sprint(names); got != tt.errors {
+				t.Errorf("errors = %s, want %s", got, tt.errors)
+			}
+		})
+	}
+	if !errors.Is(deliver(context.Background(), &Notification{Type: "order.lost"})["missing"], ErrorUnknownChannel) {
+		t.Errorf("expected ErrorUnknownChannel")
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	var a, b = subscribe("u1"), subscribe("u2")
+	defer unsubscribe("u2", b)
+	broadcast("u1")
+	broadcast("u1")
+	if len(a) != 1 || len(b) != 0 {
+		t.Errorf("signals = %d, %d, want 1, 0", len(a), len(b))
+	}
+	unsubscribe("u1", a)
+	if _, ok := subscribers["u1"]; ok {
+		t.Errorf("subscribers of u1 not removed")
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	var w = bufio.NewWriter(&buf)
+	if err := writeEvent(w, "count", Count{Unread: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "event: count\ndata: {\"unread\":3}\n\n"; got != want {
+		t.Errorf("writeEvent() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+)
+
+// HeartbeatInterval specifies how often a comment is written to idle streams to keep the connection open.
+var HeartbeatInterval = 15 * time.Second
+
+// PollInterval specifies how often the streams check the database for the notifications created or read
+// on other instances. Changes made on the same instance are streamed immediately.
+var PollInterval = 5 * time.Second
+
+// Count is the event sent to the streams when the number of unread notifications changes.
+type Count struct {
+	Unread int64 `json:"unread"`
+}
+
+var subscribers = map[string]map[chan struct{}]struct{}{}
+var subscribersMu sync.Mutex
+
+// closing is closed by CloseStreams to end the open streams, tracked by streams.
+var closing = make(chan struct{})
+var closeOnce sync.Once
+var streams sync.WaitGroup
+
+// subscribe returns a channel receiving a signal each time the notifications of the user change.
+func subscribe(user string) chan struct{} {
+	var ch = make(chan struct{}, 1)
+	subscribersMu.Lock()
+	if subscribers[user] == nil {
+		subscribers[user] = map[chan struct{}]struct{}{}
+	}
+	subscribers[user][ch] = struct{}{}
+	subscribersMu.Unlock()
+	return ch
+}
+
+func unsubscribe(user string, ch chan struct{}) {
+	subscribersMu.Lock()
+	delete(subscribers[user], ch)
+	if len(subscribers[user]) == 0 {
+		delete(subscribers, user)
+	}
+	subscribersMu.Unlock()
+}
+
+// broadcast wakes up the streams of the user. A stream already woken up does not need another signal.
+func broadcast(user string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers[user] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Stream writes the number of unread notifications of the user of the request as a `count` server-sent event,
+// followed by a `notification` event for each new notification and a `count` event each time the number
+// changes, until the client disconnects.
+func Stream(request *evo.Request) {
+	var user = request.User().UUID()
+	request.SetHeader("Content-Type", "text/event-stream")
+	request.SetHeader("Cache-Control", "no-cache")
+	request.SetHeader("X-Accel-Buffering", "no")
+	request.Context.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		streams.Add(1)
+		defer streams.Done()
+		var ch = subscribe(user)
+		defer unsubscribe(user, ch)
+		var last uint64
+		var latest Notification
+		if db.Where("`user` = ?", user).Order("id DESC").Limit(1).Find(&latest).Error == nil {
+			last = latest.ID
+		}
+		var unread int64 = -1
+		var update = func() error {
+			var created []Notification
+			if err := db.Where("`user` = ? AND id > ?", user, last).Order("id").Limit(100).Find(&created).Error; err != nil {
+				return nil
+			}
+			for _, notification := range created {
+				if err := writeEvent(w, "notification", notification); err != nil {
+					return err
+				}
+				last = notification.ID
+			}
+			count, err := Unread(user)
+			if err != nil || count == unread {
+				return nil
+			}
+			unread = count
+			return writeEvent(w, "count", Count{Unread: count})
+		}
+		if update() != nil {
+			return
+		}
+		var heartbeat = time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		var poll = time.NewTicker(PollInterval)
+		defer poll.Stop()
+		for {
+			select {
+			case <-closing:
+				return
+			case <-ch:
+				if update() != nil {
+					return
+				}
+			case <-poll.C:
+				if update() != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if w.Flush() != nil {
+					// client went away
+					return
+				}
+			}
+		}
+	})
+}
+
+// CloseStreams ends the open streams, and the streams opened afterwards once they have sent the initial events,
+// waiting for them to complete or for ctx to be done. Clients reconnect to another instance.
+func CloseStreams(ctx context.Context) error {
+	closeOnce.Do(func() { close(closing) })
+	var done = make(chan struct{})
+	go func() {
+		streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeEvent(w *bufio.Writer, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,142 @@
+package observability
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/settings"
+	"github.com/iesitalia/toolbox/tracing"
+	"gorm.io/gorm"
+)
+
+// MetricsPath specifies the route serving the metrics.
+var MetricsPath = "/metrics"
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// OpenMetricsContentType is the media type of the OpenMetrics text format, served to the scrapers accepting it.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+var queryDuration = NewHistogram("db_query_duration_seconds",
+	"Duration of the SQL statements.", DefaultBuckets, "table", "operation")
+
+var queryErrors = NewCounter("db_query_errors_total",
+	"SQL statements which failed.", "table", "operation")
+
+const startKey = "observability:start"
+const spanKey = "observability:span"
+
+// App serves the metrics and instruments the SQL statements of the default database: each statement is timed
+// and, when it runs with the context of a traced rest request, finished as a child span of the request.
+// The route requires the bearer token of the OBSERVABILITY.METRICS_TOKEN setting and answers 401 while it is not
+// set, unless OBSERVABILITY.METRICS_PUBLIC is true.
+type App struct {
+}
+
+// Register installs the callbacks timing the SQL statements of the default database and of its read replicas.
+func (a App) Register() error {
+	return toolbox.Callbacks(evo.GetDBO(), instrument)
+}
+
+// instrument registers the callbacks timing the SQL statements of the database.
+func instrument(dbo *gorm.DB) error {
+	var callbacks = dbo.Callback()
+	// the callbacks are registered before the first and after the last callback of each processor of gorm
+	var processors = []struct {
+		operation     string
+		before, after func(name string, fn func(*gorm.DB)) error
+	}{
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:after_query").Register},
+		{"create", callbacks.Create().Before("gorm:begin_transaction").Register, callbacks.Create().After("gorm:commit_or_rollback_transaction").Register},
+		{"update", callbacks.Update().Before("gorm:begin_transaction").Register, callbacks.Update().After("gorm:commit_or_rollback_transaction").Register},
+		{"delete", callbacks.Delete().Before("gorm:begin_transaction").Register, callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+	for _, p := range processors {
+		if err := p.before("observability:before_"+p.operation, start(p.operation)); err != nil {
+			return err
+		}
+		if err := p.after("observability:after_"+p.operation, finish(p.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Router sets up the metrics route.
+func (a App) Router() error {
+	if settings.Get("", "OBSERVABILITY.METRICS_TOKEN").String() == "" && !settings.Get("", "OBSERVABILITY.METRICS_PUBLIC").Bool() {
+		logger.Warning("metrics are not served as OBSERVABILITY.METRICS_TOKEN is not set", "path", MetricsPath)
+	}
+	evo.Get(MetricsPath, func(request *evo.Request) interface{} {
+		if !authorized(request.Header("Authorization"), settings.Get("", "OBSERVABILITY.METRICS_TOKEN").String(),
+			settings.Get("", "OBSERVABILITY.METRICS_PUBLIC").Bool()) {
+			request.Status(401)
+			return nil
+		}
+		var b bytes.Buffer
+		var write, contentType = Write, ContentType
+		if strings.Contains(request.Header("Accept"), "application/openmetrics-text") {
+			write, contentType = WriteOpenMetrics, OpenMetricsContentType
+		}
+		if err := write(&b); err != nil {
+			return err
+		}
+		request.SetHeader("Content-Type", contentType)
+		request.Context.Context().SetBody(b.Bytes())
+		return nil
+	})
+	return nil
+}
+
+// authorized reports whether the Authorization header carries the bearer token of the metrics. Without a token,
+// the metrics are only served when they are public.
+func authorized(header, token string, public bool) bool {
+	if token == "" {
+		return public
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) == 1
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "observability"
+}
+
+// start returns the callback recording the start of the statement, and its span.
+func start(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+		if span := tracing.FromContext(db.Statement.Context).Child("sql " + operation); span != nil {
+			db.InstanceSet(spanKey, span)
+		}
+	}
+}
+
+// finish returns the callback observing the duration of the statement and finishing its span.
+func finish(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		var table = db.Statement.Table
+		var span *tracing.Span
+		if v, ok := db.InstanceGet(spanKey); ok {
+			span = v.(*tracing.Span)
+		}
+		if v, ok := db.InstanceGet(startKey); ok {
+			queryDuration.ObserveSpan(time.Since(v.(time.Time)).Seconds(), span, table, operation)
+		}
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			queryErrors.Inc(table, operation)
+		}
+		span.Finish(nil, "table", table, "operation", operation, "rows", db.Statement.RowsAffected)
+	}
+}
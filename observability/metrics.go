@@ -0,0 +1,270 @@
+// Package observability keeps the metrics of the service in memory and serves them in the Prometheus text
+// exposition format on the /metrics route, or in the OpenMetrics format, carrying the exemplars linking the
+// histogram buckets to sampled traces, to the scrapers accepting it, see App. It records the latency, outcome and rows of the rest
+// endpoints, the duration of the SQL queries and the model events; packages add their own metrics with
+// NewCounter and NewHistogram.
+package observability
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iesitalia/toolbox/tracing"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of latency histograms, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a counter or histogram written by Write, or by WriteOpenMetrics when openMetrics is true.
+type metric interface {
+	write(w *bufio.Writer, openMetrics bool)
+}
+
+var registry = struct {
+	sync.RWMutex
+	metrics map[string]metric
+}{metrics: map[string]metric{}}
+
+// register adds the metric to the registry, panicking on duplicate names as Prometheus would reject them.
+func register(name string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.metrics[name]; ok {
+		panic("observability: duplicate metric " + name)
+	}
+	registry.metrics[name] = m
+}
+
+// Counter is a monotonic counter partitioned by labels.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter registers a counter with the given label names.
+func NewCounter(name string, help string, labels ...string) *Counter {
+	var c = &Counter{name: name, help: help, labels: labels, values: map[string]float64{}}
+	register(name, c)
+	return c
+}
+
+// Add adds the value to the counter of the label values, given in the order of the label names.
+func (c *Counter) Add(value float64, labels ...string) {
+	var key = labelSet(c.labels, labels)
+	c.mu.Lock()
+	c.values[key] += value
+	c.mu.Unlock()
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Value returns the counter of the label values.
+func (c *Counter) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelSet(c.labels, labels)]
+}
+
+func (c *Counter) write(w *bufio.Writer, openMetrics bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var family, name = c.name, c.name
+	if openMetrics {
+		// OpenMetrics names the counter family without the _total suffix of its samples
+		family = strings.TrimSuffix(c.name, "_total")
+		name = family + "_total"
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", family, escapeHelp(c.help), family)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", name, braces(key), formatFloat(c.values[key]))
+	}
+}
+
+// Histogram counts observations in buckets partitioned by labels.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts    []uint64
+	count     uint64
+	sum       float64
+	exemplars []*exemplar
+}
+
+// exemplar links the last observation of a bucket to the trace it was made in.
+type exemplar struct {
+	traceID string
+	value   float64
+	time    time.Time
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in increasing order, and label names.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	var h = &Histogram{name: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogramValue{}}
+	register(name, h)
+	return h
+}
+
+// Observe records the value in the histogram of the label values, given in the order of the label names.
+func (h *Histogram) Observe(value float64, labels ...string) {
+	h.ObserveSpan(value, nil, labels...)
+}
+
+// ObserveSpan records the value like Observe and, when the span is sampled, keeps its trace id as the exemplar
+// of the bucket of the value.
+func (h *Histogram) ObserveSpan(value float64, span *tracing.Span, labels ...string) {
+	var key = labelSet(h.labels, labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var v, ok = h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets)), exemplars: make([]*exemplar, len(h.buckets)+1)}
+		h.values[key] = v
+	}
+	var bucket = len(h.buckets)
+	for i := len(h.buckets) - 1; i >= 0 && value <= h.buckets[i]; i-- {
+		v.counts[i]++
+		bucket = i
+	}
+	v.count++
+	v.sum += value
+	if span.IsSampled() {
+		v.exemplars[bucket] = &exemplar{traceID: span.TraceID, value: value, time: time.Now()}
+	}
+}
+
+func (h *Histogram) write(w *bufio.Writer, openMetrics bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name)
+	var keys = make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var v = h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d%s\n", h.name, braces(join(key, `le="`+formatFloat(bound)+`"`)), v.counts[i], v.exemplar(i, openMetrics))
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d%s\n", h.name, braces(join(key, `le="+Inf"`)), v.count, v.exemplar(len(h.buckets), openMetrics))
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, braces(key), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, braces(key), v.count)
+	}
+}
+
+// exemplar returns the exemplar of the bucket as written after its sample, empty without exemplar or outside
+// of the OpenMetrics format.
+func (v *histogramValue) exemplar(bucket int, openMetrics bool) string {
+	if !openMetrics || v.exemplars[bucket] == nil {
+		return ""
+	}
+	var e = v.exemplars[bucket]
+	return fmt.Sprintf(` # {trace_id="%s"} %s %s`, e.traceID, formatFloat(e.value),
+		strconv.FormatFloat(float64(e.time.UnixMilli())/1000, 'f', 3, 64))
+}
+
+// Write writes every registered metric in the Prometheus text exposition format, sorted by name.
+func Write(w io.Writer) error {
+	return write(w, false)
+}
+
+// WriteOpenMetrics writes every registered metric in the OpenMetrics text format, sorted by name, along with
+// the exemplars of the histograms.
+func WriteOpenMetrics(w io.Writer) error {
+	return write(w, true)
+}
+
+func write(w io.Writer, openMetrics bool) error {
+	registry.RLock()
+	var names = make([]string, 0, len(registry.metrics))
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var metrics = make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = registry.metrics[name]
+	}
+	registry.RUnlock()
+	var b = bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(b, openMetrics)
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	return b.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// labelSet returns the labels formatted as in the exposition format, without braces. Missing values are empty.
+func labelSet(names []string, values []string) string {
+	var items = make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		items[i] = name + `="` + labelEscaper.Replace(value) + `"`
+	}
+	return strings.Join(items, ",")
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func join(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func sortedKeys(values map[string]float64) []string {
+	var keys = make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,114 @@
+package observability
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/iesitalia/toolbox/tracing"
+)
+
+func TestWrite(t *testing.T) {
+	var counter = NewCounter("test_requests_total", "Requests.\nBy outcome.", "endpoint", "outcome")
+	counter.Inc("GET", "success")
+	counter.Add(2, "GET", "success")
+	counter.Inc("ALL", `say "hi"`)
+	var histogram = NewHistogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	histogram.Observe(0.05, "GET")
+	histogram.Observe(0.5, "GET")
+	histogram.Observe(2, "GET")
+
+	if v := counter.Value("GET", "success"); v != 3 {
+		t.Errorf("Value() = %v, want 3", v)
+	}
+	var b bytes.Buffer
+	if err := Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []string{
+		"# HELP test_requests_total Requests.\\nBy outcome.\n# TYPE test_requests_total counter\n",
+		`test_requests_total{endpoint="ALL",outcome="say \"hi\""} 1` + "\n",
+		`test_requests_total{endpoint="GET",outcome="success"} 3` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{endpoint="GET",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{endpoint="GET",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{endpoint="GET",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{endpoint="GET"} 2.55` + "\n",
+		`test_duration_seconds_count{endpoint="GET"} 3` + "\n",
+	}
+	for _, want := range tests {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Write() = %s, want %q", b.String(), want)
+		}
+	}
+	if strings.Index(b.String(), "test_duration_seconds") > strings.Index(b.String(), "test_requests_total") {
+		t.Errorf("Write() does not sort the metrics by name")
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	NewCounter("test_duplicate_total", "Duplicate.")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewCounter() with a duplicate name does not panic")
+		}
+	}()
+	NewCounter("test_duplicate_total", "Duplicate.")
+}
+
+func TestAuthorized(t *testing.T) {
+	var tests = []struct {
+		header string
+		token  string
+		public bool
+		want   bool
+	}{
+		{"", "", false, false},
+		{"Bearer secret", "", false, false},
+		{"", "", true, true},
+		{"Bearer secret", "secret", false, true},
+		{"Bearer secret", "secret", true, true},
+		{"Bearer other", "secret", true, false},
+		{"", "secret", true, false},
+	}
+	for _, test := range tests {
+		if got := authorized(test.header, test.token, test.public); got != test.want {
+			t.Errorf("authorized(%q, %q, %v) = %v, want %v", test.header, test.token, test.public, got, test.want)
+		}
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var counter = NewCounter("test_open_total", "Open.", "endpoint")
+	counter.Inc("GET")
+	var histogram = NewHistogram("test_open_seconds", "Open latency.", []float64{0.1, 1}, "endpoint")
+	histogram.ObserveSpan(0.5, &tracing.Span{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", Sampled: true}, "GET")
+	histogram.ObserveSpan(0.05, &tracing.Span{TraceID: "00f067aa0ba902b700f067aa0ba902b7"}, "GET")
+
+	var b bytes.Buffer
+	if err := WriteOpenMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []string{
+		"# TYPE test_open counter\n",
+		`test_open_total{endpoint="GET"} 1` + "\n",
+		`test_open_seconds_bucket{endpoint="GET",le="0.1"} 1` + "\n",
+		`test_open_seconds_bucket{endpoint="GET",le="1"} 2 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.5 `,
+	}
+	for _, want := range tests {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteOpenMetrics() = %s, want %q", b.String(), want)
+		}
+	}
+	if !strings.HasSuffix(b.String(), "# EOF\n") {
+		t.Errorf("WriteOpenMetrics() does not end with # EOF")
+	}
+
+	b.Reset()
+	if err := Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "trace_id") || strings.Contains(b.String(), "# EOF") {
+		t.Errorf("Write() = %s, want no exemplars", b.String())
+	}
+}
@@ -0,0 +1,168 @@
+package toolbox
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/getevo/evo/v2/lib/validation"
+)
+
+// ErrorInvalidPhone is returned when a phone number can not be normalized.
+var ErrorInvalidPhone = errors.New("invalid phone number")
+
+// ErrorUnknownRegion is returned when a national phone number is given for a region without numbering plan.
+var ErrorUnknownRegion = errors.New("unknown phone region")
+
+// DefaultPhoneRegion is the region of the national numbers stored in PhoneNumber fields and checked by the phone validation.
+var DefaultPhoneRegion = "IT"
+
+// PhoneRegion describes the numbering plan of a region.
+// - Code: the country calling code.
+// - Trunk: the prefix dialed before national numbers, removed in the international format.
+// - KeepTrunk: the trunk prefix is part of the number, as for the landlines of Italy.
+// - Min, Max: the length range of the national significant number.
+type PhoneRegion struct {
+	Code      string
+	Trunk     string
+	KeepTrunk bool
+	Min       int
+	Max       int
+}
+
+// PhoneRegions maps the ISO 3166 region codes to their numbering plan.
+var PhoneRegions = map[string]PhoneRegion{
+	"IT": {Code: "39", Trunk: "0", KeepTrunk: true, Min: 6, Max: 11},
+	"SM": {Code: "378", Min: 6, Max: 10},
+	"VA": {Code: "39", Trunk: "0", KeepTrunk: true, Min: 6, Max: 11},
+	"CH": {Code: "41", Trunk: "0", Min: 9, Max: 9},
+	"FR": {Code: "33", Trunk: "0", Min: 9, Max: 9},
+	"DE": {Code: "49", Trunk: "0", Min: 6, Max: 13},
+	"AT": {Code: "43", Trunk: "0", Min: 4, Max: 13},
+	"ES": {Code: "34", Min: 9, Max: 9},
+	"PT": {Code: "351", Min: 9, Max: 9},
+	"GB": {Code: "44", Trunk: "0", Min: 9, Max: 10},
+	"IE": {Code: "353", Trunk: "0", Min: 7, Max: 9},
+	"NL": {Code: "31", Trunk: "0", Min: 9, Max: 9},
+	"BE": {Code: "32", Trunk: "0", Min: 8, Max: 9},
+	"LU": {Code: "352", Min: 4, Max: 11},
+	"PL": {Code: "48", Min: 9, Max: 9},
+	"SE": {Code: "46", Trunk: "0", Min: 7, Max: 10},
+	"DK": {Code: "45", Min: 8, Max: 8},
+	"NO": {Code: "47", Min: 8, Max: 8},
+	"GR": {Code: "30", Min: 10, Max: 10},
+	"US": {Code: "1", Trunk: "1", Min: 10, Max: 10},
+	"CA": {Code: "1", Trunk: "1", Min: 10, Max: 10},
+}
+
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "/", "", "(", "", ")", "", "\u00a0", "")
+
+var phoneDigitsRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// NormalizePhone returns the phone number in the E.164 format, e.g. +390612345678.
+// Numbers starting with + or 00 are international, the others are national numbers of the default region.
+func NormalizePhone(raw string, defaultRegion string) (string, error) {
+	var number = phoneSeparators.Replace(strings.TrimSpace(raw))
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		if !phoneDigitsRegex.MatchString(number) {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidPhone, raw)
+		}
+		region, ok := PhoneRegions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrorUnknownRegion, defaultRegion)
+		}
+		if region.Trunk != "" && !region.KeepTrunk {
+			number = strings.TrimPrefix(number, region.Trunk)
+		}
+		if len(number) < region.Min || len(number) > region.Max {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidPhone, raw)
+		}
+		number = region.Code + number
+	}
+	// E.164 numbers have at most 15 digits and never start with 0
+	if !phoneDigitsRegex.MatchString(number) || number[0] == '0' || len(number) < 8 || len(number) > 15 {
+		return "", fmt.Errorf("%w: %s", ErrorInvalidPhone, raw)
+	}
+	return "+" + number, nil
+}
+
+// PhoneNumber is a phone number stored in the E.164 format. National numbers are read in the DefaultPhoneRegion.
+type PhoneNumber string
+
+// Normalize returns the number in the E.164 format.
+func (p PhoneNumber) Normalize() (PhoneNumber, error) {
+	if p == "" {
+		return "", nil
+	}
+	number, err := NormalizePhone(string(p), DefaultPhoneRegion)
+	return PhoneNumber(number), err
+}
+
+// Value normalizes the number before it is stored, failing if it is not valid.
+func (p PhoneNumber) Value() (driver.Value, error) {
+	number, err := p.Normalize()
+	return string(number), err
+}
+
+// Scan reads the number from the database.
+func (p *PhoneNumber) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = ""
+	case []byte:
+		*p = PhoneNumber(v)
+	case string:
+		*p = PhoneNumber(v)
+	default:
+		return fmt.Errorf("unsupported phone number type %T", value)
+	}
+	return nil
+}
+
+// GormDataType returns the gorm data type of the number.
+func (PhoneNumber) GormDataType() string {
+	return "string"
+}
+
+// UnmarshalJSON reads and normalizes the number.
+func (p *PhoneNumber) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	number, err := PhoneNumber(s).Normalize()
+	if err != nil {
+		return err
+	}
+	*p = number
+	return nil
+}
+
+func init() {
+	// phone, or phone(CH) to read national numbers in another region
+	validation.Validators[regexp.MustCompile(`^phone(?:\(([a-zA-Z]{2})\))?$`)] = phoneValidator
+}
+
+func phoneValidator(match []string, value *generic.Value) error {
+	var v = value.String()
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	var region = DefaultPhoneRegion
+	if len(match) > 1 && match[1] != "" {
+		region = match[1]
+	}
+	if _, err := NormalizePhone(v, region); err != nil {
+		return fmt.Errorf("invalid phone number %s", v)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package toolbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/getevo/evo/v2/lib/validation"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	var tests = []struct {
+		raw    string
+		region string
+		want   string
+		err    error
+	}{
+		{"06 1234 5678", "IT", "+390612345678", nil},
+		{"333-123.4567", "it", "+393331234567", nil},
+		{"+39 06 12345678", "US", "+390612345678", nil},
+		{"0041 44 668 18 00", "IT", "+41446681800", nil},
+		{"044 668 18 00", "CH", "+41446681800", nil},
+		{"(415) 555-2671", "US", "+14155552671", nil},
+		{"1 415 555 2671", "US", "+14155552671", nil},
+		{"020 7946 0958", "GB", "+442079460958", nil},
+		{"12345", "IT", "", ErrorInvalidPhone},
+		{"06 CALL ME", "IT", "", ErrorInvalidPhone},
+		{"+0612345678", "IT", "", ErrorInvalidPhone},
+		{"+1234567890123456", "IT", "", ErrorInvalidPhone},
+		{"0612345678", "XX", "", ErrorUnknownRegion},
+	}
+	for _, test := range tests {
+		got, err := NormalizePhone(test.raw, test.region)
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Errorf("NormalizePhone(%q, %q) = %q, %v, want %q, %v", test.raw, test.region, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestPhoneNumber(t *testing.T) {
+	var p PhoneNumber
+	if err := p.UnmarshalJSON([]byte(`"06 1234 5678"`)); err != nil || p != "+390612345678" {
+		t.Errorf("UnmarshalJSON = %q, %v", p, err)
+	}
+	if err := p.UnmarshalJSON([]byte(`"abc"`)); err == nil {
+		t.Error("UnmarshalJSON of an invalid number should fail")
+	}
+	if v, err := PhoneNumber("333 1234567").Value(); err != nil || v != "+393331234567" {
+		t.Errorf("Value = %v, %v", v, err)
+	}
+	if v, err := PhoneNumber("").Value(); err != nil || v != "" {
+		t.Errorf("Value of empty number = %v, %v", v, err)
+	}
+	if err := p.Scan([]byte("+41446681800")); err != nil || p != "+41446681800" {
+		t.Errorf("Scan = %q, %v", p, err)
+	}
+}
+
+func TestPhoneValidation(t *testing.T) {
+	var tests = []struct {
+		value string
+		rule  string
+		ok    bool
+	}{
+		{"06 1234 5678", "phone", true},
+		{"", "phone", true},
+		{"12", "phone", false},
+		{"044 668 18 00", "phone(CH)", true},
+		{"044 668 18 00 99", "phone(CH)", false},
+	}
+	for _, test := range tests {
+		if err := validation.Value(test.value, test.rule); (err == nil) != test.ok {
+			t.Errorf("validation.Value(%q, %q) = %v, want ok %v", test.value, test.rule, err, test.ok)
+		}
+	}
+}
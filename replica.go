@@ -0,0 +1,54 @@
+package toolbox
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+var replicas struct {
+	sync.Mutex
+	dbs       []*gorm.DB
+	callbacks []func(dbo *gorm.DB) error
+}
+
+// Callbacks installs the gorm callbacks registered by fn on the database and on its read replicas, added
+// before or after with AddReplica, so the rows read from a replica are processed like the rows read from the
+// database, e.g. translated, and its statements are instrumented alike.
+//
+//	toolbox.Callbacks(evo.GetDBO(), func(dbo *gorm.DB) error {
+//		return dbo.Callback().Query().After("*").Register("l10n:query", OnQuery)
+//	})
+func Callbacks(dbo *gorm.DB, fn func(dbo *gorm.DB) error) error {
+	replicas.Lock()
+	defer replicas.Unlock()
+	replicas.callbacks = append(replicas.callbacks, fn)
+	if err := fn(dbo); err != nil {
+		return err
+	}
+	for _, replica := range replicas.dbs {
+		if err := fn(replica); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddReplica installs the callbacks registered with Callbacks on a read replica of the database. Adding a
+// replica again does nothing.
+func AddReplica(dbo *gorm.DB) error {
+	replicas.Lock()
+	defer replicas.Unlock()
+	for _, replica := range replicas.dbs {
+		if replica == dbo {
+			return nil
+		}
+	}
+	replicas.dbs = append(replicas.dbs, dbo)
+	for _, fn := range replicas.callbacks {
+		if err := fn(dbo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
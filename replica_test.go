@@ -0,0 +1,32 @@
+package toolbox
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestCallbacks(t *testing.T) {
+	var primary, early, late = &gorm.DB{}, &gorm.DB{}, &gorm.DB{}
+	var installed = map[*gorm.DB]int{}
+	if err := AddReplica(early); err != nil {
+		t.Fatal(err)
+	}
+	if err := Callbacks(primary, func(dbo *gorm.DB) error {
+		installed[dbo]++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddReplica(late); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddReplica(late); err != nil {
+		t.Fatal(err)
+	}
+	for name, dbo := range map[string]*gorm.DB{"primary": primary, "early": early, "late": late} {
+		if installed[dbo] != 1 {
+			t.Errorf("%s: callbacks installed %d times, want once", name, installed[dbo])
+		}
+	}
+}
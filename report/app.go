@@ -0,0 +1,130 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/scheduler"
+	"gorm.io/gorm"
+)
+
+// PREFIX specifies the prefix for report routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the report and delivery models, and schedules the reports once their changes are committed.
+func (a App) Register() error {
+	db.UseModel(Report{}, Delivery{})
+	return toolbox.OnCommit(evo.GetDBO(), "report:schedule", Report{}, func(tx *gorm.DB) {
+		if err := Schedule(); err != nil {
+			logger.Error("unable to schedule reports", "error", err.Error())
+		}
+	})
+}
+
+// Router sets up the report endpoints.
+// POST /report/:id/run delivers the report immediately and returns the delivery, requiring REPORT.UPDATE.
+// GET /report/:id/download returns the current content of the report, requiring REPORT.VIEW.
+// Both are limited to the reports the policies of the report resource grant to the request and to the users
+// allowed to read the resource of the report.
+func (a App) Router() error {
+	evo.Post(PREFIX+"/report/:id/run", func(request *evo.Request) interface{} {
+		report, err := find(request, "UPDATE")
+		if err != nil {
+			return fail(request, err)
+		}
+		delivery, err := report.Deliver(request.Context.Context())
+		if err != nil {
+			return err
+		}
+		return delivery
+	})
+	evo.Get(PREFIX+"/report/:id/download", func(request *evo.Request) interface{} {
+		report, err := find(request, "VIEW")
+		if err != nil {
+			return fail(request, err)
+		}
+		file, _, _, err := report.Render()
+		if err != nil {
+			return err
+		}
+		request.SetHeader("Content-Type", file.ContentType)
+		request.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+		request.Context.Context().SetBody(file.Data)
+		return nil
+	})
+	return nil
+}
+
+// find returns the report of the request when the user has the permission on the report resource and can
+// read the resource the report shows. The report is looked up within the policies of the report resource,
+// e.g. the tenant of the request.
+func find(request *evo.Request, permission string) (*Report, error) {
+	resource, err := rest.GetResource(Report{})
+	if err != nil {
+		return nil, err
+	}
+	var context = resource.NewContext(request, "REPORT")
+	if err := context.HasPerm(permission); err != nil {
+		return nil, err
+	}
+	var report Report
+	if context.ApplyPolicies(db.Where("id = ?", request.Param("id").Uint64())).Take(&report).RowsAffected == 0 {
+		return nil, rest.ErrorObjectNotExist
+	}
+	for _, item := range rest.Resources() {
+		if item.Table == report.Resource {
+			if !item.NewContext(request, "REPORT").Readable() {
+				return nil, rest.ErrorPermissionDenied
+			}
+			return &report, nil
+		}
+	}
+	return nil, rest.ErrorObjectNotExist
+}
+
+// fail answers the request with the error and its status.
+func fail(request *evo.Request, err error) interface{} {
+	var status = http.StatusBadRequest
+	switch {
+	case errors.Is(err, rest.ErrorUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, rest.ErrorPermissionDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, rest.ErrorObjectNotExist):
+		status = http.StatusNotFound
+	}
+	request.Error(err, status)
+	return nil
+}
+
+// WhenReady protects the report resources and schedules the enabled reports. The schedules are reloaded
+// every minute on each instance to pick up the reports changed on the others.
+func (a App) WhenReady() error {
+	rest.SetPermission(&rest.AppPermission{
+		App:         "REPORT",
+		Name:        "Reports",
+		Description: "scheduled delivery of the resource views by email",
+		Objects:     []interface{}{Report{}, Delivery{}},
+	})
+	if err := Schedule(); err != nil {
+		logger.Error("unable to schedule reports", "error", err.Error())
+	}
+	scheduler.Every("1m", func(ctx context.Context) error {
+		return Schedule()
+	}).Named("report.schedule").RunLocal()
+	return nil
+}
+
+func (a App) Name() string {
+	return "report"
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Layout of the PDF reports: A4 landscape pages in points, with the text in Helvetica.
+const (
+	pdfWidth      = 842.0
+	pdfHeight     = 595.0
+	pdfMargin     = 36.0
+	pdfFontSize   = 8.0
+	pdfTitleSize  = 12.0
+	pdfRowHeight  = 12.0
+	pdfCharWidth  = 0.5 * pdfFontSize
+	pdfCellMargin = 4.0
+)
+
+// renderPDF writes the table as a PDF document, repeating the header row on every page.
+// Cells are truncated to the width of their column, which is proportional to the length of its values.
+func renderPDF(table Table) ([]byte, error) {
+	var widths = pdfColumnWidths(table)
+	var pages []string
+	var page strings.Builder
+	var y float64
+	var newPage = func() {
+		if page.Len() > 0 {
+			pages = append(pages, page.String())
+			page.Reset()
+		}
+		y = pdfHeight - pdfMargin
+		if len(pages) == 0 && table.Title != "" {
+			y -= pdfTitleSize
+			pdfText(&page, "F2", pdfTitleSize, pdfMargin, y, table.Title)
+			y -= pdfRowHeight
+		}
+		y -= pdfRowHeight
+		pdfRow(&page, "F2", widths, y, table.Columns)
+		fmt.Fprintf(&page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, y-3, pdfWidth-pdfMargin, y-3)
+		y -= 4
+	}
+	newPage()
+	for _, row := range table.Rows {
+		if y-pdfRowHeight < pdfMargin {
+			newPage()
+		}
+		y -= pdfRowHeight
+		pdfRow(&page, "F1", widths, y, row)
+	}
+	pages = append(pages, page.String())
+	return pdfDocument(pages), nil
+}
+
+// pdfColumnWidths divides the width of the page among the columns by the length of their longest value, up to 40 characters.
+func pdfColumnWidths(table Table) []float64 {
+	var weights = make([]float64, len(table.Columns))
+	var total float64
+	for i, column := range table.Columns {
+		var longest = utf8.RuneCountInString(column)
+		for _, row := range table.Rows {
+			if i < len(row) && utf8.RuneCountInString(row[i]) > longest {
+				longest = utf8.RuneCountInString(row[i])
+			}
+		}
+		if longest > 40 {
+			longest = 40
+		}
+		weights[i] = float64(longest + 2)
+		total += weights[i]
+	}
+	for i := range weights {
+		weights[i] = (pdfWidth - 2*pdfMargin) * weights[i] / total
+	}
+	return weights
+}
+
+func pdfRow(page *strings.Builder, font string, widths []float64, y float64, cells []string) {
+	var x = pdfMargin
+	for i, width := range widths {
+		if i < len(cells) {
+			pdfText(page, font, pdfFontSize, x, y, pdfTruncate(cells[i], int((width-pdfCellMargin)/pdfCharWidth)))
+		}
+		x += width
+	}
+}
+
+func pdfText(page *strings.Builder, font string, size float64, x, y float64, text string) {
+	fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(text))
+}
+
+// pdfTruncate shortens the text to the given number of characters, ending it with an ellipsis.
+func pdfTruncate(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if max < 1 {
+		return ""
+	}
+	if r := []rune(text); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return text
+}
+
+// pdfString encodes the text in WinAnsi and escapes it for a PDF string literal.
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		c, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 32 {
+				c = ' '
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pdfDocument writes the pages with the catalog, the fonts and the cross-reference table.
+func pdfDocument(pages []string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	var object = func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	// objects 1 to 4 are the catalog, the page tree and the fonts, followed by each page and its content
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+	var xref = buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// Formats of the rendered reports.
+const (
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+	FormatCSV  = "csv"
+)
+
+// Table is the content of a report: a title, the column headers and the rows of text.
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+}
+
+// File is a rendered report.
+type File struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// NewTable returns the table of the rows returned by a FilterView. Hidden and action columns are left out,
+// and the html of the linked values is converted to text.
+func NewTable(title string, view *rest.FilterView, rows [][]interface{}) Table {
+	var table = Table{Title: title}
+	var columns []int
+	for i, column := range view.Columns {
+		if column.Hidden || len(column.Actions) > 0 {
+			continue
+		}
+		columns = append(columns, i)
+		table.Columns = append(table.Columns, column.Title)
+	}
+	for _, row := range rows {
+		var cells = make([]string, 0, len(columns))
+		for _, i := range columns {
+			var value = ""
+			if i < len(row) && row[i] != nil {
+				value = strings.TrimSpace(html.UnescapeString(toolbox.StripHTMLTags(fmt.Sprint(row[i]))))
+			}
+			cells = append(cells, value)
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+	return table
+}
+
+// Render returns the table rendered in the given format, named after the filename without extension.
+func Render(format string, filename string, table Table) (*File, error) {
+	var file = File{Filename: filename + "." + strings.ToLower(format)}
+	var err error
+	switch strings.ToLower(format) {
+	case FormatXLSX:
+		file.ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		file.Data, err = renderXLSX(table)
+	case FormatPDF:
+		file.ContentType = "application/pdf"
+		file.Data, err = renderPDF(table)
+	case FormatCSV:
+		file.ContentType = "text/csv"
+		file.Data, err = renderCSV(table)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func renderCSV(table Table) ([]byte, error) {
+	var buf bytes.Buffer
+	var w = csv.NewWriter(&buf)
+	if err := w.Write(table.Columns); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(table.Rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+// Package report delivers the FilterView of a rest resource by email on a schedule.
+//
+// Reports are managed through the rest endpoints of the report resource:
+//
+//	{"name": "Open orders", "resource": "order", "filters": "status=open&sort=created_at.desc",
+//	 "format": "xlsx", "recipients": "sales@example.com, ops@example.com", "cron": "0 7 * * 1-5", "enabled": true}
+//
+// Filters are the query parameters of the view endpoint, including its url parameters and the language the
+// values are formatted for. Each delivery is recorded in the report_delivery table.
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/scheduler"
+	"gorm.io/gorm"
+
+	mailer "github.com/iesitalia/toolbox/mail"
+)
+
+// ErrorInvalidFormat is returned when a report has a format other than xlsx, pdf and csv.
+var ErrorInvalidFormat = errors.New("invalid report format")
+
+// ErrorNoRecipient is returned when a report has no valid recipient.
+var ErrorNoRecipient = errors.New("report has no recipient")
+
+// MaxRows specifies the maximum number of rows of a delivered report.
+var MaxRows = 10000
+
+// Status of a delivery.
+const (
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
+
+// Report is the scheduled delivery of the FilterView of a resource.
+type Report struct {
+	ID         uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name       string     `gorm:"column:name;size:255" json:"name"`
+	Resource   string     `gorm:"column:resource;size:64" json:"resource"`
+	Filters    string     `gorm:"column:filters;size:2048" json:"filters"`
+	Format     string     `gorm:"column:format;size:8" json:"format"`
+	Recipients string     `gorm:"column:recipients;size:1024" json:"recipients"`
+	Cron       string     `gorm:"column:cron;size:64" json:"cron"`
+	Enabled    bool       `gorm:"column:enabled" json:"enabled"`
+	LastRunAt  *time.Time `gorm:"column:last_run_at" json:"last_run_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	rest.API
+}
+
+// TableName returns the name of the table for the Report struct.
+func (Report) TableName() string {
+	return "report"
+}
+
+// Delivery records a run of a report.
+type Delivery struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ReportID   uint64    `gorm:"column:report_id;index" json:"report_id"`
+	Status     string    `gorm:"column:status;size:16" json:"status"`
+	Recipients string    `gorm:"column:recipients;size:1024" json:"recipients"`
+	Rows       int       `gorm:"column:rows" json:"rows"`
+	Total      int64     `gorm:"column:total" json:"total"`
+	Size       int       `gorm:"column:size" json:"size"`
+	Error      string    `gorm:"column:error;size:1024" json:"error"`
+	StartedAt  time.Time `gorm:"column:started_at" json:"started_at"`
+	FinishedAt time.Time `gorm:"column:finished_at" json:"finished_at"`
+	rest.API
+	rest.DisableCreate
+	rest.DisableUpdate
+}
+
+// TableName returns the name of the table for the Delivery struct.
+func (Delivery) TableName() string {
+	return "report_delivery"
+}
+
+// BeforeSave validates the format, the recipients, the filters and the cron expression of the report.
+func (r *Report) BeforeSave(tx *gorm.DB) error {
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format != FormatXLSX && r.Format != FormatPDF && r.Format != FormatCSV {
+		return fmt.Errorf("%w: %s", ErrorInvalidFormat, r.Format)
+	}
+	if _, err := r.recipients(); err != nil {
+		return err
+	}
+	if _, err := url.ParseQuery(r.Filters); err != nil {
+		return fmt.Errorf("invalid report filters: %w", err)
+	}
+	if _, err := scheduler.ParseCron(r.Cron); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recipients returns the addresses of the comma separated recipients.
+func (r *Report) recipients() ([]string, error) {
+	var result []string
+	for _, item := range strings.Split(r.Recipients, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(item); err != nil {
+			return nil, fmt.Errorf("invalid report recipient %s", item)
+		}
+		result = append(result, item)
+	}
+	if len(result) == 0 {
+		return nil, ErrorNoRecipient
+	}
+	return result, nil
+}
+
+// Render returns the current content of the report rendered in its format, along with the total number of rows.
+func (r *Report) Render() (*File, int, int64, error) {
+	view, err := rest.FindFilterView(r.Resource)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	values, err := url.ParseQuery(r.Filters)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	err, total, rows := view.Data(0, MaxRows, rest.ViewValues(values))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var title = r.Name
+	if title == "" {
+		title = view.Title
+	}
+	var filename = strings.TrimSuffix(strings.TrimSpace(title), ".") + " " + time.Now().Format("2006-01-02")
+	file, err := Render(r.Format, filename, NewTable(title, view, rows))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return file, len(rows), total, nil
+}
+
+// Deliver renders the report and queues it to its recipients, recording the delivery.
+func (r *Report) Deliver(ctx context.Context) (*Delivery, error) {
+	var delivery = Delivery{ReportID: r.ID, Recipients: r.Recipients, StartedAt: time.Now()}
+	var err = r.deliver(&delivery)
+	delivery.FinishedAt = time.Now()
+	delivery.Status = StatusSent
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+		if len(delivery.Error) > 1024 {
+			delivery.Error = delivery.Error[:1024]
+		}
+	}
+	if dbErr := db.WithContext(ctx).Create(&delivery).Error; dbErr != nil && err == nil {
+		err = dbErr
+	}
+	db.Model(&Report{}).Where("id = ?", r.ID).UpdateColumn("last_run_at", delivery.StartedAt)
+	return &delivery, err
+}
+
+func (r *Report) deliver(delivery *Delivery) error {
+	recipients, err := r.recipients()
+	if err != nil {
+		return err
+	}
+	file, rows, total, err := r.Render()
+	if err != nil {
+		return err
+	}
+	delivery.Rows, delivery.Total, delivery.Size = rows, total, len(file.Data)
+	var text = fmt.Sprintf("%s: %d rows attached.", r.Name, rows)
+	if total > int64(rows) {
+		text = fmt.Sprintf("%s: the first %d of %d rows attached.", r.Name, rows, total)
+	}
+	return mailer.Enqueue(&mailer.Message{
+		To:      recipients,
+		Subject: r.Name,
+		Text:    text,
+		Attachments: []mailer.Attachment{
+			{Filename: file.Filename, ContentType: file.ContentType, Data: file.Data},
+		},
+	})
+}
+
+var scheduled = map[uint64]string{}
+var scheduleMu sync.Mutex
+
+// Schedule adds a scheduler task for each enabled report and removes the tasks of the disabled and deleted ones.
+// Tasks are named report:<id>, so each activation is delivered by a single instance. The task of a report
+// whose schedule changed is replaced.
+func Schedule() error {
+	var reports []Report
+	if err := db.Where("enabled = ?", true).Find(&reports).Error; err != nil {
+		return err
+	}
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+	var enabled = map[uint64]bool{}
+	for i := range reports {
+		var report = reports[i]
+		enabled[report.ID] = true
+		cron, ok := scheduled[report.ID]
+		if ok && cron == report.Cron {
+			continue
+		}
+		if ok {
+			scheduler.Remove(taskName(report.ID))
+			delete(scheduled, report.ID)
+		}
+		schedule, err := scheduler.ParseCron(report.Cron)
+		if err != nil {
+			logger.Error("invalid report schedule", "report", report.ID, "cron", report.Cron, "error", err.Error())
+			continue
+		}
+		var id = report.ID
+		scheduler.Add(schedule, func(ctx context.Context) error {
+			var report Report
+			if err := db.WithContext(ctx).Where("id = ? AND enabled = ?", id, true).Take(&report).Error; err != nil {
+				return err
+			}
+			_, err := report.Deliver(ctx)
+			return err
+		}).Named(taskName(id))
+		scheduled[report.ID] = report.Cron
+	}
+	for id := range scheduled {
+		if !enabled[id] {
+			scheduler.Remove(taskName(id))
+			delete(scheduled, id)
+		}
+	}
+	return nil
+}
+
+func taskName(id uint64) string {
+	return "report:" + strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,201 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/iesitalia/toolbox/rest"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	var tests = []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := xlsxColumn(tt.index); got != tt.want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	var view = &rest.FilterView{Columns: []rest.FilterViewColumn{
+		{Title: "ID", Hidden: true},
+		{Title: "Name"},
+		{Title: "Amount"},
+		{Title: "", Actions: []rest.Action{{}}},
+	}}
+	var table = NewTable("Orders", view, [][]interface{}{
+		{1, `<a href="/order/1">Rossi &amp; figli</a>`, "12.50", "x"},
+		{2, nil, 3},
+	})
+	if got := strings.Join(table.Columns, ","); got != "Name,Amount" {
+		t.Errorf("columns = %q", got)
+	}
+	if got := fmt.Sprint(table.Rows); got != "[[Rossi & figli 12.50] [ 3]]" {
+		t.Errorf("rows = %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var table = Table{
+		Title:   "Orders: open",
+		Columns: []string{"Name", "Zip", "Amount"},
+		Rows:    [][]string{{"Rossi (S.p.A.) & figli", "00184", "12.5"}, {"Bianchi, Luca", "20121", "-3"}},
+	}
+	var tests = []struct {
+		format      string
+		contentType string
+		check       func(data []byte) error
+	}{
+		{FormatCSV, "text/csv", func(data []byte) error {
+			var want = "Name,Zip,Amount\nRossi (S.p.A.) & figli,00184,12.5\n\"Bianchi, Luca\",20121,-3\n"
+			if string(data) != want {
+				return fmt.Errorf("got %q", data)
+			}
+			return nil
+		}},
+		{FormatXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", func(data []byte) error {
+			sheet, err := unzip(data, "xl/worksheets/sheet1.xml")
+			if err != nil {
+				return err
+			}
+			for _, want := range []string{
+				`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">Name</t></is></c>`,
+				`<t xml:space="preserve">Rossi (S.p.A.) &amp; figli</t>`,
+				`<c r="B2" t="inlineStr"><is><t xml:space="preserve">00184</t></is></c>`,
+				`<c r="C2"><v>12.5</v></c>`,
+				`<c r="C3"><v>-3</v></c>`,
+			} {
+				if !strings.Contains(sheet, want) {
+					return fmt.Errorf("sheet has no %s", want)
+				}
+			}
+			workbook, err := unzip(data, "xl/workbook.xml")
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(workbook, `name="Orders open"`) {
+				return fmt.Errorf("invalid sheet name in %s", workbook)
+			}
+			return nil
+		}},
+		{FormatPDF, "application/pdf", func(data []byte) error {
+			if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+				return fmt.Errorf("invalid document")
+			}
+			if !bytes.Contains(data, []byte(`(Rossi \(S.p.A.\) & figli)`)) {
+				return fmt.Errorf("unescaped text")
+			}
+			if !bytes.Contains(data, []byte("/Count 1")) {
+				return fmt.Errorf("expected a single page")
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			file, err := Render(strings.ToUpper(tt.format), "orders", table)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Filename != "orders."+tt.format || file.ContentType != tt.contentType {
+				t.Errorf("file = %s %s", file.Filename, file.ContentType)
+			}
+			if err := tt.check(file.Data); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	if _, err := Render("docx", "orders", table); !errors.Is(err, ErrorInvalidFormat) {
+		t.Errorf("Render(docx) error = %v", err)
+	}
+}
+
+func TestRenderPDFPages(t *testing.T) {
+	var table = Table{Columns: []string{"N"}}
+	for i := 0; i < 100; i++ {
+		table.Rows = append(table.Rows, []string{fmt.Sprint(i)})
+	}
+	data, _ := renderPDF(table)
+	if !bytes.Contains(data, []byte("/Count 3")) {
+		t.Errorf("expected 3 pages")
+	}
+	if got := bytes.Count(data, []byte("(N) Tj")); got != 3 {
+		t.Errorf("header repeated %d times, want 3", got)
+	}
+}
+
+func TestPDFTruncate(t *testing.T) {
+	var tests = []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"a  long\ntext", 20, "a long text"},
+		{"truncated text", 6, "trunc…"},
+		{"àèìòù", 3, "àè…"},
+		{"text", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := pdfTruncate(tt.text, tt.max); got != tt.want {
+			t.Errorf("pdfTruncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	var tests = []struct {
+		recipients string
+		want       string
+		err        bool
+	}{
+		{"a@example.com", "[a@example.com]", false},
+		{" a@example.com, ,b@example.com ", "[a@example.com b@example.com]", false},
+		{"", "", true},
+		{"a@example.com, invalid", "", true},
+	}
+	for _, tt := range tests {
+		var report = Report{Recipients: tt.recipients}
+		got, err := report.recipients()
+		if (err != nil) != tt.err {
+			t.Errorf("recipients(%q) error = %v", tt.recipients, err)
+			continue
+		}
+		if !tt.err && fmt.Sprint(got) != tt.want {
+			t.Errorf("recipients(%q) = %v, want %s", tt.recipients, got, tt.want)
+		}
+	}
+}
+
+func unzip(data []byte, name string) (string, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	for _, file := range r.File {
+		if file.Name == name {
+			f, err := file.Open()
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			return string(b), err
+		}
+	}
+	return "", fmt.Errorf("%s not found", name)
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"reflect"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/logger"
+)
+
+// Accessors is implemented on the model pointer by the code `toolbox gen` generates, see the gen package. The
+// handlers use it instead of reflect when the model implements it to allocate the rows and slices of the
+// requests, to set the key fields given in the url, to read the fields of partial updates and filters and to
+// resolve the columns of the filters. Request bodies are still decoded by encoding/json, so Create and Update
+// only save the allocation of their rows, see BenchmarkAccessors.
+//   - RESTNew returns a new zero model, RESTNewSlice a pointer to a new empty slice of the model.
+//   - RESTGet returns the value of the field of the Go name, RESTSet parses the value into it. Both report false
+//     for the fields the generator does not know, e.g. those of the types embedded from other packages.
+//   - RESTFilterField returns the column and the Go name of the field a filter names by column, JSON or Go
+//     name, false for the names which are not columns.
+//   - RESTFields returns the Go names of the columns known to the generator.
+//
+// The accessors are checked against the schema of the model when the resource is attached and ignored, with an
+// error logged, when the model changed since they were generated.
+type Accessors interface {
+	RESTNew() interface{}
+	RESTNewSlice() interface{}
+	RESTGet(field string) (interface{}, bool)
+	RESTSet(field string, value string) (bool, error)
+	RESTFilterField(name string) (string, string, bool)
+	RESTFields() []string
+}
+
+// bindAccessors sets the accessors of the resource when the model implements Accessors and they match its schema.
+func (res *Resource) bindAccessors() {
+	accessors, ok := reflect.New(res.Object.Type()).Interface().(Accessors)
+	if !ok || res.Schema == nil {
+		return
+	}
+	for _, name := range accessors.RESTFields() {
+		var field = res.Schema.LookUpField(name)
+		column, goName, ok := accessors.RESTFilterField(name)
+		if field == nil || !ok || field.DBName != column || field.Name != goName {
+			logger.Error("generated accessors do not match the model, run toolbox gen again", "resource", res.Table, "field", name)
+			return
+		}
+	}
+	res.accessors = accessors
+}
+
+// accessors returns the generated accessors of the model of the request, nil when it has none.
+func (context *Context) accessors() Accessors {
+	if context.Action == nil || context.Action.Resource == nil || context.Action.Resource.Object.Type() != context.Object.Type() {
+		return nil
+	}
+	return context.Action.Resource.accessors
+}
+
+// fieldValue returns the value of the field of the Go name of the object, through its accessors when it has some.
+func fieldValue(object interface{}, field string) interface{} {
+	if accessors, ok := object.(Accessors); ok {
+		if value, ok := accessors.RESTGet(field); ok {
+			return value
+		}
+	}
+	return getValueByFieldName(object, field)
+}
+
+// setField parses the value into the field of the Go name of the object, through its accessors when it has some.
+func setField(object reflect.Value, field string, value string) error {
+	if accessors, ok := object.Addr().Interface().(Accessors); ok {
+		if handled, err := accessors.RESTSet(field, value); handled {
+			return err
+		}
+	}
+	return generic.Parse(value).Cast(object.FieldByName(field).Addr().Interface())
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type accessedModel struct {
+	ID    int    `gorm:"column:id;primaryKey" json:"id"`
+	Title string `gorm:"column:heading" json:"title"`
+	Extra string `gorm:"column:extra" json:"extra"`
+}
+
+var accessedNew int
+
+func (*accessedModel) RESTNew() interface{} {
+	accessedNew++
+	return &accessedModel{}
+}
+
+func (*accessedModel) RESTNewSlice() interface{} { return &[]accessedModel{} }
+
+func (m *accessedModel) RESTGet(field string) (interface{}, bool) {
+	switch field {
+	case "ID":
+		return m.ID, true
+	case "Title":
+		return m.Title, true
+	}
+	return nil, false
+}
+
+func (m *accessedModel) RESTSet(field string, value string) (bool, error) {
+	if field == "Title" {
+		m.Title = "set:" + value
+		return true, nil
+	}
+	return false, nil
+}
+
+func (*accessedModel) RESTFilterField(name string) (string, string, bool) {
+	switch name {
+	case "id", "ID":
+		return "id", "ID", true
+	case "heading", "Title", "title":
+		return "heading", "Title", true
+	}
+	return "", "", false
+}
+
+func (*accessedModel) RESTFields() []string { return []string{"ID", "Title"} }
+
+// staleModel renamed Title to Heading since its accessors were written.
+type staleModel struct {
+	ID      int    `gorm:"column:id;primaryKey" json:"id"`
+	Heading string `gorm:"column:heading" json:"heading"`
+}
+
+func (*staleModel) RESTNew() interface{}                             { return &staleModel{} }
+func (*staleModel) RESTNewSlice() interface{}                        { return &[]staleModel{} }
+func (*staleModel) RESTGet(field string) (interface{}, bool)         { return nil, false }
+func (*staleModel) RESTSet(field string, value string) (bool, error) { return false, nil }
+func (*staleModel) RESTFields() []string                             { return []string{"ID", "Title"} }
+
+func (*staleModel) RESTFilterField(name string) (string, string, bool) {
+	return (*accessedModel)(nil).RESTFilterField(name)
+}
+
+func TestBindAccessors(t *testing.T) {
+	var tests = []struct {
+		model interface{}
+		bound bool
+	}{
+		{&accessedModel{}, true},
+		{&staleModel{}, false},
+		{&indexedModel{}, false},
+	}
+	for _, test := range tests {
+		s, err := schema.Parse(test.model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res = &Resource{Object: reflect.ValueOf(test.model).Elem(), Schema: s, Table: s.Table}
+		res.bindAccessors()
+		if got := res.accessors != nil; got != test.bound {
+			t.Errorf("%T: accessors bound = %v, want %v", test.model, got, test.bound)
+		}
+	}
+}
+
+func TestAccessorsUse(t *testing.T) {
+	var object = reflect.ValueOf(accessedModel{})
+	var res = &Resource{Object: object, accessors: (*accessedModel)(nil)}
+	var context = &Context{Object: object, Action: &Endpoint{Resource: res}}
+
+	var created = accessedNew
+	if row := context.GetObject(); row.Type() != object.Type() || accessedNew != created+1 {
+		t.Error("GetObject() did not allocate through RESTNew")
+	}
+	if slice := context.GetObjectSlice(); slice.Type() != reflect.TypeOf([]accessedModel{}) {
+		t.Errorf("GetObjectSlice() = %s", slice.Type())
+	}
+
+	var row = reflect.ValueOf(&accessedModel{}).Elem()
+	if err := setField(row, "Title", "x"); err != nil || row.Interface().(accessedModel).Title != "set:x" {
+		t.Errorf("setField(Title) = %v, %+v, want the value set by RESTSet", err, row.Interface())
+	}
+	if err := setField(row, "Extra", "y"); err != nil || row.Interface().(accessedModel).Extra != "y" {
+		t.Errorf("setField(Extra) = %v, %+v, want the value cast by reflect", err, row.Interface())
+	}
+	var input = &accessedModel{ID: 7, Extra: "z"}
+	if got := fieldValue(input, "ID"); got != 7 {
+		t.Errorf("fieldValue(ID) = %v, want 7", got)
+	}
+	if got := fieldValue(input, "Extra"); got != "z" {
+		t.Errorf("fieldValue(Extra) = %v, want z", got)
+	}
+}
+
+func BenchmarkAccessors(b *testing.B) {
+	s, err := schema.Parse(&accessedModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var object = reflect.ValueOf(accessedModel{})
+	var row = &accessedModel{ID: 7, Title: "x"}
+	var paths = []struct {
+		name    string
+		context *Context
+	}{
+		{"accessors", &Context{Object: object, Schema: s, Action: &Endpoint{Resource: &Resource{Object: object, accessors: (*accessedModel)(nil)}}}},
+		{"reflect", &Context{Object: object, Schema: s, Action: &Endpoint{Resource: &Resource{Object: object}}}},
+	}
+	for _, path := range paths {
+		var context = path.context
+		b.Run("GetObject/"+path.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				context.GetObject()
+			}
+		})
+		b.Run("FilterField/"+path.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if accessors := context.accessors(); accessors != nil {
+					accessors.RESTFilterField("title")
+				} else {
+					findField(context.Schema, "title")
+				}
+			}
+		})
+	}
+	b.Run("FieldValue/accessors", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fieldValue(row, "Title")
+		}
+	})
+	b.Run("FieldValue/reflect", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			getValueByFieldName(row, "Title")
+		}
+	})
+}
@@ -1,25 +1,122 @@
 package rest
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
 	"github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/i18n"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/metering"
+	"github.com/iesitalia/toolbox/scheduler"
+	"github.com/iesitalia/toolbox/settings"
 )
 
 // PREFIX specifies the prefix for API routes in the admin panel.
 var PREFIX = "/admin"
 
+// TenantHeader specifies the request header used to identify the tenant of a request.
+var TenantHeader = "X-Tenant"
+
+// TenantMember reports whether the user of the request belongs to the tenant asked for by the request, e.g.
+// in the TenantHeader header. By default only the users holding acl.Admin belong to the tenants, the tenancy
+// app checks its membership table.
+var TenantMember = func(request *evo.Request, tenant string) bool {
+	var user = request.User()
+	return !user.Anonymous() && user.HasPermission(acl.Admin)
+}
+
+// TenantResolver extracts the tenant of a request, by default from the TenantHeader header. Requests asking
+// for a tenant the user is not a member of, see TenantMember, have no tenant.
+var TenantResolver = func(request *evo.Request) string {
+	var tenant = request.Header(TenantHeader)
+	if tenant != "" && !TenantMember(request, tenant) {
+		return ""
+	}
+	return tenant
+}
+
+func init() {
+	// metering can not import rest, its usage endpoint resolves the tenants through TenantResolver
+	metering.TenantResolver = func(request *evo.Request) string {
+		return TenantResolver(request)
+	}
+}
+
 type App struct {
 }
 
 // Register registers all the resources and sets up the router for the application.
+// Resources declared in the files of the comma separated REST.RESOURCES setting are loaded first, along with the
+// models of the projections, see Project.
 // For each model in `schema.Models`, it attaches a resource using the `AttachResource` method.
+// The writes of the models are published to the change streams, see StreamChanges.
+// The reads of the list and get endpoints are routed to the MySQL read replica of the REST.REPLICA_DSN setting,
+// when set, see SetReplica.
 func (a App) Register() error {
-	resources = map[string]*Resource{}
+	resources.reset()
+	db.UseModel(Segment{}, ViewPreference{}, FieldAudit{}, PendingChange{})
+	if err := useProjections(); err != nil {
+		return err
+	}
+
+	for _, path := range strings.Split(settings.Get("", "REST.RESOURCES").String(), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			if err := LoadDeclarations(path); err != nil {
+				return err
+			}
+		}
+	}
+	if err := useDeclarations(); err != nil {
+		return err
+	}
+	if dsn := settings.Get("", "REST.REPLICA_DSN").String(); dsn != "" {
+		if err := OpenReplica(dsn); err != nil {
+			return fmt.Errorf("invalid REST.REPLICA_DSN: %w", err)
+		}
+	}
 
 	for idx := range schema.Models {
 		var model = schema.Models[idx]
-		AttachResource(&model)
+		applyDeclaration(AttachResource(&model))
+	}
+
+	var dbo = evo.GetDBO()
+	detectEngine(dbo)
+	if err := dbo.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("rest:change:create", changeCallback(ChangeCreate)); err != nil {
+		return err
+	}
+	if err := dbo.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("rest:change:update", changeCallback(ChangeUpdate)); err != nil {
+		return err
+	}
+	if err := dbo.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("rest:change:delete", changeCallback(ChangeDelete)); err != nil {
+		return err
 	}
+	if err := dbo.Callback().Raw().After("gorm:raw").Register("rest:count:exec", execCallback); err != nil {
+		return err
+	}
+	SetPermission(&AppPermission{
+		App:         "SETTING",
+		Name:        "Settings",
+		Description: "stored configuration values",
+		Objects:     []interface{}{settings.Setting{}},
+	})
+	SetPermission(&AppPermission{
+		App:         "I18N",
+		Name:        "Messages",
+		Description: "translations of the api messages",
+		Objects:     []interface{}{i18n.Override{}},
+	})
+	SetPermission(&AppPermission{
+		App:         "SEGMENT",
+		Name:        "Segments",
+		Description: "saved filters of the resources",
+		Objects:     []interface{}{Segment{}},
+	})
 	return nil
 }
 
@@ -27,10 +124,33 @@ func (a App) Router() error {
 	var controller = Controller{}
 	evo.Get(PREFIX+"/rest/orm", controller.ORM)
 	evo.Get(PREFIX+"/rest/models", controller.Models)
+	evo.Get(PREFIX+"/rest/logging", controller.GetLogging)
+	evo.Post(PREFIX+"/rest/logging", controller.SetLogging)
+	evo.Get(PREFIX+"/rest/sync", Sync)
+	evo.Get(PREFIX+"/rest/trash", Trash)
+	evo.Get(PREFIX+"/rest/schema/diff", SchemaDiffs)
 	return nil
 }
 
+// WhenReady starts the periodic refresh of the segment member counts and the purge of the soft deleted
+// rows older than the REST.TRASH_RETENTION setting.
 func (a App) WhenReady() error {
+	if retention := settings.Get("", "REST.TRASH_RETENTION").String(); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			return fmt.Errorf("invalid REST.TRASH_RETENTION: %w", err)
+		}
+		TrashRetention = d
+	}
+	subscribeCounts()
+	scheduler.Every("1h", PurgeTrash).Named("rest.trash.purge")
+	go func() {
+		for range time.Tick(SegmentRefreshInterval) {
+			if err := RefreshSegments(); err != nil {
+				logger.Error("unable to refresh segments", "error", err.Error())
+			}
+		}
+	}()
 	return nil
 }
 
@@ -0,0 +1,339 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// Actions of the pending changes.
+const (
+	ApprovalUpdate = "update"
+	ApprovalDelete = "delete"
+)
+
+// Status of the pending changes. Approved changes are applying while they are applied.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApplying = "applying"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// ErrorSelfApproval is returned when a user reviews a change they requested.
+var ErrorSelfApproval = errors.New("changes can not be reviewed by the user requesting them")
+
+// ErrorInvalidChange is returned when the body of an update requiring approval is not a JSON object.
+var ErrorInvalidChange = errors.New("changes requiring approval must be a json object")
+
+// ApprovePermission allows to approve and reject the pending changes of a resource.
+var ApprovePermission = acl.Permission{
+	Key:         "APPROVE",
+	Name:        "Approve",
+	Description: "Approve or reject pending changes",
+}
+
+// RequireApproval is a marker type turning the updates and deletions of a resource into pending changes,
+// applied once a user with the APPROVE permission other than the requester approves them.
+// GET /rest/:table/pending lists the changes, filtered by ?status=, pending by default. Users without the
+// APPROVE permission see their own changes only.
+// POST /rest/:table/pending/approve/:change applies a pending change.
+// POST /rest/:table/pending/reject/:change {"reason": "..."} rejects a pending change.
+type RequireApproval struct{}
+
+// PendingChange is an update or a deletion of a row waiting for approval. Data holds the body of the update.
+// Changes are reviewed within the tenant of the request which made them.
+type PendingChange struct {
+	ID          uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Tenant      string     `gorm:"column:tenant;size:64;index" json:"tenant"`
+	Table       string     `gorm:"column:table;size:64;index:pending_change_row_idx" json:"table"`
+	RowID       string     `gorm:"column:row_id;size:64;index:pending_change_row_idx" json:"row_id"`
+	Action      string     `gorm:"column:action;size:16" json:"action"`
+	Data        string     `gorm:"column:data;type:text" json:"data"`
+	Status      string     `gorm:"column:status;size:16;index" json:"status"`
+	RequestedBy string     `gorm:"column:requested_by;size:36" json:"requested_by"`
+	ReviewedBy  string     `gorm:"column:reviewed_by;size:36" json:"reviewed_by"`
+	ReviewedAt  *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	Reason      string     `gorm:"column:reason;size:1024" json:"reason"`
+	Error       string     `gorm:"column:error;size:1024" json:"error"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName returns the name of the table for the PendingChange struct.
+func (PendingChange) TableName() string {
+	return "pending_change"
+}
+
+// outputChange returns the change as served to the clients. The change keeps its own id, the key of its row is
+// obfuscated when the resource obfuscates its ids.
+func (context *Context) outputChange(change PendingChange) PendingChange {
+	if !context.Action.Resource.Feature.ObfuscateID {
+		return change
+	}
+	var keys = strings.Split(change.RowID, ",")
+	for i, key := range keys {
+		if n, err := strconv.ParseUint(key, 10, 64); err == nil {
+			keys[i] = context.Action.Resource.IDEncoder().Encode(n)
+		}
+	}
+	change.RowID = strings.Join(keys, ",")
+	return change
+}
+
+// approvalEndpoints returns the endpoints reviewing the pending changes of a resource.
+func approvalEndpoints() []*Endpoint {
+	return []*Endpoint{
+		{
+			Name:        "PENDING CHANGES",
+			Method:      GET,
+			URL:         "/pending",
+			Handler:     PendingChanges,
+			Description: "list the changes waiting for approval",
+			Permissions: []acl.Permission{ListPermission},
+		},
+		{
+			Name:        "APPROVE CHANGE",
+			Method:      POST,
+			URL:         "/pending/approve",
+			URLParams:   []Filter{{Name: "change"}},
+			Handler:     ApproveChange,
+			Description: "apply a change waiting for approval",
+			Permissions: []acl.Permission{ApprovePermission},
+		},
+		{
+			Name:        "REJECT CHANGE",
+			Method:      POST,
+			URL:         "/pending/reject",
+			URLParams:   []Filter{{Name: "change"}},
+			Handler:     RejectChange,
+			Description: "reject a change waiting for approval",
+			Permissions: []acl.Permission{ApprovePermission},
+		},
+	}
+}
+
+// RequiresApproval reports whether the changes of the rows of the resource wait for approval, see
+// RequireApproval.
+func (context *Context) RequiresApproval() bool {
+	return context.Action.Resource.Feature.RequireApproval
+}
+
+// RequestApproval records the update of the object setting the values, keyed by json name, as a pending change
+// and answers 202 with it. Endpoints changing the rows of the resources requiring approval outside of the update
+// endpoints call it instead of writing the change, see RequiresApproval.
+func (context *Context) RequestApproval(object reflect.Value, values map[string]interface{}) error {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return context.requestApproval(ApprovalUpdate, object, b)
+}
+
+// requestApproval records the change of the object as pending and answers 202 with the pending change.
+func (context *Context) requestApproval(action string, object reflect.Value, data []byte) error {
+	if action == ApprovalUpdate {
+		var fields map[string]interface{}
+		if json.Unmarshal(data, &fields) != nil {
+			return ErrorInvalidChange
+		}
+	}
+	var change = PendingChange{
+		Tenant:      context.Tenant(),
+		Table:       context.Schema.Table,
+		RowID:       context.rowID(object),
+		Action:      action,
+		Data:        string(data),
+		Status:      ApprovalPending,
+		RequestedBy: context.User().UUID(),
+	}
+	if err := db.Create(&change).Error; err != nil {
+		return err
+	}
+	context.SetStatus(http.StatusAccepted)
+	var output = context.outputChange(change)
+	context.Response.Data = &output
+	return nil
+}
+
+// pendingChange returns the pending change given by the request, provided it belongs to the tenant of the
+// request and the user can view its row.
+func (context *Context) pendingChange() (*PendingChange, error) {
+	var change PendingChange
+	if db.Where("id = ? AND tenant = ? AND `table` = ? AND status = ?", context.Request.Param("change").Uint64(), context.Tenant(),
+		context.Schema.Table, ApprovalPending).Take(&change).RowsAffected == 0 {
+		return nil, ErrorObjectNotExist
+	}
+	if _, err := context.changeRow(&change); err != nil {
+		return nil, err
+	}
+	if change.RequestedBy == context.User().UUID() {
+		return nil, ErrorSelfApproval
+	}
+	return &change, nil
+}
+
+// changeRow returns the row of the change, ErrorObjectNotExist when it does not exist or the user can not
+// view it.
+func (context *Context) changeRow(change *PendingChange) (reflect.Value, error) {
+	object := context.GetObject()
+	var keys = strings.Split(change.RowID, ",")
+	if len(keys) != len(context.Schema.PrimaryFields) {
+		return object, ErrorObjectNotExist
+	}
+	for i, field := range context.Schema.PrimaryFields {
+		value, err := parseAuditValue(field, keys[i])
+		if err != nil {
+			return object, err
+		}
+		if err := field.Set(context.ctx(), object, value); err != nil {
+			return object, err
+		}
+	}
+	found, err := context.FindByPrimaryKey(object.Addr().Interface())
+	if err != nil {
+		return object, err
+	}
+	if !found {
+		return object, ErrorObjectNotExist
+	}
+	return object, nil
+}
+
+// review stores the outcome of the review of a change, provided it still has the given status, so a change is
+// reviewed once when reviewers act concurrently.
+func (context *Context) review(change *PendingChange, from string, status string, reason string) error {
+	var now = time.Now()
+	var reviewed = *change
+	reviewed.Status, reviewed.ReviewedBy, reviewed.ReviewedAt, reviewed.Reason = status, context.User().UUID(), &now, reason
+	var result = db.Model(&reviewed).Where("status = ?", from).
+		Select("status", "reviewed_by", "reviewed_at", "reason", "error").Updates(&reviewed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorObjectNotExist
+	}
+	*change = reviewed
+	return nil
+}
+
+// PendingChanges lists the changes of the resource with the status given by the query, pending by default.
+// Changes of rows the user can not view are left out, except the approved deletions whose rows are gone.
+func PendingChanges(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var status = context.Request.Query("status").String()
+	if status == "" {
+		status = ApprovalPending
+	}
+	var query = db.Where("tenant = ? AND `table` = ? AND status = ?", context.Tenant(), context.Schema.Table, status)
+	if context.HasPerm(ApprovePermission.Key) != nil {
+		query = query.Where("requested_by = ?", context.User().UUID())
+	}
+	var changes []PendingChange
+	if err := query.Order("id").Limit(RowLimit(0, context.Limits().AllEndpointCap)).Find(&changes).Error; err != nil {
+		return err
+	}
+	var visible = make([]PendingChange, 0, len(changes))
+	for i := range changes {
+		if changes[i].Action != ApprovalDelete || changes[i].Status != ApprovalApproved {
+			if _, err := context.changeRow(&changes[i]); err != nil {
+				continue
+			}
+		}
+		visible = append(visible, context.outputChange(changes[i]))
+	}
+	context.Response.Data = visible
+	return nil
+}
+
+// ApproveChange applies a pending change as the approving user, running the hooks of the update and delete
+// endpoints. The change is claimed first by marking it as applying, so it is applied once. Changes failing to
+// apply stay pending with the error recorded.
+func ApproveChange(context *Context) error {
+	if context.User().Anonymous() {
+		return ErrorUnauthorized
+	}
+	if err := context.HasPerm(ApprovePermission.Key); err != nil {
+		return err
+	}
+	change, err := context.pendingChange()
+	if err != nil {
+		return err
+	}
+	var claim = db.Model(change).Where("status = ?", ApprovalPending).Update("status", ApprovalApplying)
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return ErrorObjectNotExist
+	}
+	if err := context.applyChange(change); err != nil {
+		change.Error = err.Error()
+		if len(change.Error) > 1024 {
+			change.Error = change.Error[:1024]
+		}
+		db.Model(change).Updates(map[string]interface{}{"status": ApprovalPending, "error": change.Error})
+		return err
+	}
+	change.Error = ""
+	if err := context.review(change, ApprovalApplying, ApprovalApproved, ""); err != nil {
+		return err
+	}
+	var output = context.outputChange(*change)
+	context.Response.Data = &output
+	return nil
+}
+
+// applyChange applies the update or the deletion of the pending change to its row.
+func (context *Context) applyChange(change *PendingChange) error {
+	object, err := context.changeRow(change)
+	if err != nil {
+		return err
+	}
+	if change.Action == ApprovalDelete {
+		return context.delete(object)
+	}
+	return context.update(object, func(out interface{}) error {
+		return json.Unmarshal([]byte(change.Data), out)
+	})
+}
+
+// RejectChange rejects a pending change with the reason given in the body.
+func RejectChange(context *Context) error {
+	if context.User().Anonymous() {
+		return ErrorUnauthorized
+	}
+	if err := context.HasPerm(ApprovePermission.Key); err != nil {
+		return err
+	}
+	change, err := context.pendingChange()
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if len(context.Request.Body()) > 0 {
+		if err := context.ParseBody(&body); err != nil {
+			return err
+		}
+	}
+	if len(body.Reason) > 1024 {
+		body.Reason = body.Reason[:1024]
+	}
+	if err := context.review(change, ApprovalPending, ApprovalRejected, body.Reason); err != nil {
+		return err
+	}
+	var output = context.outputChange(*change)
+	context.Response.Data = &output
+	return nil
+}
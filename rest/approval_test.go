@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"testing"
+)
+
+type approvalModel struct {
+	ID   int    `gorm:"column:id;primaryKey"`
+	Name string `gorm:"column:name"`
+	API
+	RequireApproval
+}
+
+func TestRequireApprovalFeature(t *testing.T) {
+	if !GetFeatures(approvalModel{}).RequireApproval {
+		t.Error("RequireApproval is not detected")
+	}
+	if GetFeatures(orderUser{}).RequireApproval {
+		t.Error("RequireApproval is detected on a model without the marker")
+	}
+}
+
+func TestApprovalEndpoints(t *testing.T) {
+	var tests = map[string]struct {
+		method Method
+		url    string
+		key    string
+	}{
+		"PENDING CHANGES": {GET, "/pending", "VIEW"},
+		"APPROVE CHANGE":  {POST, "/pending/approve", "APPROVE"},
+		"REJECT CHANGE":   {POST, "/pending/reject", "APPROVE"},
+	}
+	var endpoints = approvalEndpoints()
+	if len(endpoints) != len(tests) {
+		t.Fatalf("approvalEndpoints() returned %d endpoints, want %d", len(endpoints), len(tests))
+	}
+	for _, endpoint := range endpoints {
+		var want, ok = tests[endpoint.Name]
+		if !ok {
+			t.Errorf("unexpected endpoint %s", endpoint.Name)
+			continue
+		}
+		if endpoint.Method != want.method || endpoint.URL != want.url || endpoint.Permissions[0].Key != want.key {
+			t.Errorf("%s = %s %s %s, want %s %s %s", endpoint.Name, endpoint.Method, endpoint.URL, endpoint.Permissions[0].Key,
+				want.method, want.url, want.key)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/iesitalia/toolbox/i18n"
+)
+
+// ErrorBodyTooLarge is returned, with the 413 status, for request bodies above the MaxBodySize of the resource.
+var ErrorBodyTooLarge = errors.New("request body too large")
+
+// ErrorArrayTooLong is returned, with the 413 status, for arrays of rows above the MaxArrayLength of the resource.
+var ErrorArrayTooLong = errors.New("request body array too long")
+
+// ErrorJSONTooDeep is returned, with the 400 status, for JSON bodies nested deeper than the MaxJSONDepth of the
+// resource.
+var ErrorJSONTooDeep = errors.New("request body nested too deep")
+
+// BodyLimitError is the error of a request body exceeding a limit of the resource. It matches its kind,
+// ErrorBodyTooLarge, ErrorArrayTooLong or ErrorJSONTooDeep, through errors.Is, and its message gives the limit
+// in the language of the caller.
+type BodyLimitError struct {
+	Kind  error
+	Limit int64
+}
+
+// Error returns the message of the error in the default locale.
+func (e *BodyLimitError) Error() string {
+	return e.message().Error()
+}
+
+// Unwrap returns the kind of the error and its message.
+func (e *BodyLimitError) Unwrap() []error {
+	return []error{e.Kind, e.message()}
+}
+
+// message returns the message of the error in the catalog.
+func (e *BodyLimitError) message() *i18n.Error {
+	switch e.Kind {
+	case ErrorArrayTooLong:
+		return i18n.NewError("body.array_too_long", "count", e.Limit)
+	case ErrorJSONTooDeep:
+		return i18n.NewError("body.too_deep", "count", e.Limit)
+	}
+	return i18n.NewError("body.too_large", "count", e.Limit)
+}
+
+func init() {
+	i18n.RegisterPlural("en", "body.too_large", i18n.Message{
+		One:   "the request body must be at most {count} byte",
+		Other: "the request body must be at most {count} bytes",
+	})
+	i18n.RegisterPlural("en", "body.array_too_long", i18n.Message{
+		One:   "the request body must have at most {count} item",
+		Other: "the request body must have at most {count} items",
+	})
+	i18n.RegisterPlural("en", "body.too_deep", i18n.Message{
+		One:   "the request body must be nested at most {count} level",
+		Other: "the request body must be nested at most {count} levels",
+	})
+}
+
+// ParseBody parses the request body into out once checked against the MaxBodySize and MaxJSONDepth limits of
+// the resource, and the MaxArrayLength limit when out is a slice. See checkBody. Endpoints added by the host
+// app or other packages parse their bodies with it so the limits apply to them as well.
+func (context *Context) ParseBody(out interface{}) error {
+	if err := context.checkBody(isSlice(out)); err != nil {
+		return err
+	}
+	return context.Request.BodyParser(out)
+}
+
+// CheckLength checks the number of items of an array given in the request body, e.g. the ids of a batch
+// endpoint, against the MaxArrayLength limit of the resource, setting the 413 status on failure.
+func (context *Context) CheckLength(n int) error {
+	var limits = context.Limits()
+	if limits.MaxArrayLength > 0 && n > limits.MaxArrayLength {
+		context.SetStatus(http.StatusRequestEntityTooLarge)
+		return &BodyLimitError{Kind: ErrorArrayTooLong, Limit: int64(limits.MaxArrayLength)}
+	}
+	return nil
+}
+
+// parseRows parses the rows of the model in the request body into out like ParseBody. The keys named by the
+// naming strategy of the resource are mapped back to the json tags of the model first, see rowBody.
+func (context *Context) parseRows(out interface{}) error {
+	if err := context.checkBody(isSlice(out)); err != nil {
+		return err
+	}
+	if body, ok := context.rowBody(); ok {
+		return json.Unmarshal(body, out)
+	}
+	return context.Request.BodyParser(out)
+}
+
+// rowBody returns the JSON body of the request with the keys named by the naming strategy of the resource
+// mapped back to the json tags of the model. It returns false for resources serving the keys of the json tags
+// and for bodies which are not JSON, which are decoded as is.
+func (context *Context) rowBody() ([]byte, bool) {
+	var naming = context.naming()
+	if naming == NamingTags {
+		return nil, false
+	}
+	if contentType := context.Request.Header("Content-Type"); contentType != "" && !strings.Contains(contentType, "json") {
+		return nil, false
+	}
+	var decoder = json.NewDecoder(strings.NewReader(context.Request.Body()))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	b, err := json.Marshal(naming.restore(context.Schema, value))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// checkBody checks the request body against the limits of the resource, setting the status of the response on
+// failure. The JSON depth, and the array length when array is set, are only checked for JSON bodies.
+func (context *Context) checkBody(array bool) error {
+	var limits = context.Limits()
+	var body = context.Request.Body()
+	if limits.MaxBodySize > 0 && int64(len(body)) > limits.MaxBodySize {
+		context.SetStatus(http.StatusRequestEntityTooLarge)
+		return &BodyLimitError{Kind: ErrorBodyTooLarge, Limit: limits.MaxBodySize}
+	}
+	if contentType := context.Request.Header("Content-Type"); contentType != "" && !strings.Contains(contentType, "json") {
+		return nil
+	}
+	var maxArray = 0
+	if array {
+		maxArray = limits.MaxArrayLength
+	}
+	if err := scanJSON([]byte(body), limits.MaxJSONDepth, maxArray); err != nil {
+		if errors.Is(err, ErrorArrayTooLong) {
+			context.SetStatus(http.StatusRequestEntityTooLarge)
+		} else {
+			context.SetStatus(http.StatusBadRequest)
+		}
+		return err
+	}
+	return nil
+}
+
+// scanJSON checks the nesting of the JSON document against maxDepth and the length of its top-level array
+// against maxArray, zero for no limit, without decoding it. Malformed documents are left to the decoder.
+func scanJSON(body []byte, maxDepth int, maxArray int) error {
+	var depth, items = 0, 0
+	var array, expect, inString, escaped bool
+	for _, c := range body {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ',':
+			expect = depth == 1 && array
+			continue
+		case '}', ']':
+			depth--
+			continue
+		}
+		if expect {
+			expect = false
+			if items++; maxArray > 0 && items > maxArray {
+				return &BodyLimitError{Kind: ErrorArrayTooLong, Limit: int64(maxArray)}
+			}
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			if depth == 0 && c == '[' {
+				array, expect = true, true
+			}
+			if depth++; maxDepth > 0 && depth > maxDepth {
+				return &BodyLimitError{Kind: ErrorJSONTooDeep, Limit: int64(maxDepth)}
+			}
+		}
+	}
+	return nil
+}
+
+// isSlice reports whether out points to a slice.
+func isSlice(out interface{}) bool {
+	var value = reflect.ValueOf(out)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Slice
+}
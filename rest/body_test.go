@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iesitalia/toolbox/i18n"
+)
+
+func TestScanJSON(t *testing.T) {
+	var tests = []struct {
+		body     string
+		depth    int
+		array    int
+		want     error
+		describe string
+	}{
+		{`{"a": 1}`, 1, 0, nil, "flat object"},
+		{`{"a": {"b": 1}}`, 1, 0, ErrorJSONTooDeep, "nested object"},
+		{`{"a": "{[{[{["}`, 1, 0, nil, "brackets in string"},
+		{`{"a": "\"{"}`, 1, 0, nil, "escaped quote"},
+		{`[[[[1]]]]`, 3, 0, ErrorJSONTooDeep, "nested arrays"},
+		{`[1, 2, 3]`, 0, 3, nil, "array at limit"},
+		{`[1, 2, 3, 4]`, 0, 3, ErrorArrayTooLong, "array above limit"},
+		{`[{"a": [1, 2, 3, 4]}, {"b": ","}]`, 0, 2, nil, "nested commas"},
+		{`[]`, 0, 1, nil, "empty array"},
+		{` [ ] `, 0, 1, nil, "empty array with spaces"},
+		{`{"a": [1, 2, 3]}`, 0, 1, nil, "array in object"},
+		{`not json`, 1, 1, nil, "malformed"},
+	}
+	for _, test := range tests {
+		if err := scanJSON([]byte(test.body), test.depth, test.array); !errors.Is(err, test.want) || (err == nil) != (test.want == nil) {
+			t.Errorf("%s: scanJSON(%s) = %v, want %v", test.describe, test.body, err, test.want)
+		}
+	}
+}
+
+func TestBodyLimitError(t *testing.T) {
+	var err error = &BodyLimitError{Kind: ErrorArrayTooLong, Limit: 1000}
+	if !errors.Is(err, ErrorArrayTooLong) || errors.Is(err, ErrorBodyTooLarge) {
+		t.Error("BodyLimitError does not match its kind only")
+	}
+	if got := i18n.Translate("en", err); got != "the request body must have at most 1000 items" {
+		t.Errorf("Translate() = %q", got)
+	}
+	if got := (&BodyLimitError{Kind: ErrorBodyTooLarge, Limit: 1}).Error(); !strings.Contains(got, "1 byte") {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestIsSlice(t *testing.T) {
+	var rows []orderUser
+	var row orderUser
+	if !isSlice(&rows) || isSlice(&row) {
+		t.Error("isSlice() does not tell slices from rows")
+	}
+}
+
+func TestCheckLength(t *testing.T) {
+	var context = &Context{quota: &Quota{Limits: Limits{MaxArrayLength: 2}}}
+	if err := context.CheckLength(2); err != nil {
+		t.Errorf("CheckLength(2) = %v", err)
+	}
+	if err := context.CheckLength(3); !errors.Is(err, ErrorArrayTooLong) || context.status != http.StatusRequestEntityTooLarge {
+		t.Errorf("CheckLength(3) = %v with status %d", err, context.status)
+	}
+	var unlimited = &Context{quota: &Quota{}}
+	if err := unlimited.CheckLength(1000); err != nil {
+		t.Errorf("CheckLength() without limit = %v", err)
+	}
+}
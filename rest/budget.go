@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// withBudget returns the query bound to a deadline of the given budget, a function reporting
+// whether the deadline has been exceeded and the function releasing the deadline.
+// A non-positive budget leaves the query unbounded.
+func withBudget(query *gorm.DB, budget time.Duration) (*gorm.DB, func() bool, context.CancelFunc) {
+	if budget <= 0 {
+		return query, func() bool { return false }, func() {}
+	}
+	ctx, cancel := context.WithTimeout(query.Statement.Context, budget)
+	return query.WithContext(ctx), func() bool {
+		return errors.Is(ctx.Err(), context.DeadlineExceeded)
+	}, cancel
+}
+
+// withoutPreloads returns a copy of the query which does not load associations.
+func withoutPreloads(query *gorm.DB) *gorm.DB {
+	var tx = query.Session(&gorm.Session{Context: query.Statement.Context})
+	tx.Statement.Preloads = map[string][]interface{}{}
+	return tx
+}
@@ -0,0 +1,384 @@
+package rest
+
+import (
+	"bufio"
+	stdcontext "context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actions of the changes streamed by StreamChanges.
+// - create, update: the row was created or changed and matches the filters of the stream, Data holds the row.
+// - remove: the row was changed and no longer matches the filters of the stream.
+// - delete: the row was deleted.
+// - reset: changes were missed or made without primary key, e.g. batch updates, the client should reload.
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeRemove = "remove"
+	ChangeDelete = "delete"
+	ChangeReset  = "reset"
+)
+
+// ChangeHeartbeatInterval specifies how often a comment is written to idle change streams to keep the connection open.
+var ChangeHeartbeatInterval = 15 * time.Second
+
+// ChangeBufferSize specifies the number of changes buffered for a stream, the stream is reset when it falls behind.
+var ChangeBufferSize = 64
+
+// ChangeLogSize specifies the minimum number of changes of a table kept to resume the sync subscriptions, see Sync.
+var ChangeLogSize = 1000
+
+// Change is the event sent to the change streams of a table.
+// Cursor is the position of the change among the changes of every table.
+type Change struct {
+	Action string                 `json:"action"`
+	Table  string                 `json:"table"`
+	Cursor uint64                 `json:"cursor,omitempty"`
+	Keys   map[string]interface{} `json:"keys,omitempty"`
+	Data   interface{}            `json:"data,omitempty"`
+	// tenant is the tenant_id of the changed row, when known
+	tenant string
+}
+
+// pendingChanges holds the changes of the statements of a transaction until it is committed, see transaction.
+type pendingChanges struct {
+	changes []Change
+}
+
+type pendingChangesKey struct{}
+
+type changeSubscription struct {
+	changes chan Change
+	lost    atomic.Bool
+}
+
+// changeLog holds the latest changes of a table. Floor is the cursor before the oldest change kept.
+type changeLog struct {
+	changes []Change
+	floor   uint64
+}
+
+var changeCursor uint64
+var changeSubscribers = map[string]map[*changeSubscription]struct{}{}
+var changeLogs = map[string]*changeLog{}
+var changeSubscribersMu sync.RWMutex
+
+func newChangeSubscription() *changeSubscription {
+	return &changeSubscription{changes: make(chan Change, ChangeBufferSize)}
+}
+
+// subscribeChanges returns a subscription to the changes of the table.
+func subscribeChanges(table string) *changeSubscription {
+	var s = newChangeSubscription()
+	s.watch(table)
+	return s
+}
+
+func unsubscribeChanges(table string, s *changeSubscription) {
+	s.unwatch(table)
+}
+
+// watch adds the changes of the table to the subscription.
+func (s *changeSubscription) watch(table string) {
+	changeSubscribersMu.Lock()
+	if changeSubscribers[table] == nil {
+		changeSubscribers[table] = map[*changeSubscription]struct{}{}
+	}
+	changeSubscribers[table][s] = struct{}{}
+	changeSubscribersMu.Unlock()
+}
+
+// unwatch removes the changes of the table from the subscription.
+func (s *changeSubscription) unwatch(table string) {
+	changeSubscribersMu.Lock()
+	delete(changeSubscribers[table], s)
+	if len(changeSubscribers[table]) == 0 {
+		delete(changeSubscribers, table)
+	}
+	changeSubscribersMu.Unlock()
+}
+
+// hasChangeSubscribers reports whether the changes of the table are streamed or logged.
+func hasChangeSubscribers(table string) bool {
+	changeSubscribersMu.RLock()
+	defer changeSubscribersMu.RUnlock()
+	return len(changeSubscribers[table]) > 0 || changeLogs[table] != nil
+}
+
+// currentCursor returns the cursor of the latest change.
+func currentCursor() uint64 {
+	changeSubscribersMu.RLock()
+	defer changeSubscribersMu.RUnlock()
+	return changeCursor
+}
+
+// logChanges starts keeping the latest changes of the table.
+func logChanges(table string) {
+	changeSubscribersMu.Lock()
+	if changeLogs[table] == nil {
+		changeLogs[table] = &changeLog{floor: changeCursor}
+	}
+	changeSubscribersMu.Unlock()
+}
+
+// changesSince returns the logged changes of the table after the cursor. It returns false if changes after
+// the cursor are no longer, or were never, logged.
+func changesSince(table string, cursor uint64) ([]Change, bool) {
+	changeSubscribersMu.RLock()
+	defer changeSubscribersMu.RUnlock()
+	var log = changeLogs[table]
+	if log == nil || cursor < log.floor || cursor > changeCursor {
+		return nil, false
+	}
+	var result []Change
+	for _, change := range log.changes {
+		if change.Cursor > cursor {
+			result = append(result, change)
+		}
+	}
+	return result, true
+}
+
+// publishChange assigns the next cursor to the change, logs it and sends it to the streams of its table.
+// Streams falling behind miss the change rather than blocking the write, and are reset.
+func publishChange(change Change) {
+	changeSubscribersMu.Lock()
+	defer changeSubscribersMu.Unlock()
+	changeCursor++
+	change.Cursor = changeCursor
+	if log := changeLogs[change.Table]; log != nil {
+		log.changes = append(log.changes, change)
+		// trimmed in batches to avoid copying the log on every change
+		if n := len(log.changes) - ChangeLogSize; n >= ChangeLogSize {
+			log.floor = log.changes[n-1].Cursor
+			log.changes = append([]Change(nil), log.changes[n:]...)
+		}
+	}
+	for s := range changeSubscribers[change.Table] {
+		select {
+		case s.changes <- change:
+		default:
+			s.lost.Store(true)
+		}
+	}
+}
+
+// changeCallback returns the gorm callback publishing the rows written by a statement with the given action,
+// once its transaction is committed. The statements of the transactions opened by the endpoints publish
+// their changes when the transaction is committed, see transaction; the end of the other transactions opened
+// by the caller is not observable, their statements publish when they complete.
+// Changes are published only within the instance.
+func changeCallback(action string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil || db.RowsAffected == 0 {
+			return
+		}
+		invalidateCounts(db.Statement.Schema.Table)
+		if !hasChangeSubscribers(db.Statement.Schema.Table) {
+			return
+		}
+		var changes = statementChanges(action, db.Statement)
+		if pending, ok := db.Statement.Context.Value(pendingChangesKey{}).(*pendingChanges); ok {
+			pending.changes = append(pending.changes, changes...)
+			return
+		}
+		for _, change := range changes {
+			publishChange(change)
+		}
+	}
+}
+
+// transaction runs fn in a transaction of the database of the context, publishing the changes of its
+// statements to the change streams once it is committed.
+func (context *Context) transaction(fn func(tx *gorm.DB) error) error {
+	var pending = &pendingChanges{}
+	var dbo = context.GetDBO()
+	if err := dbo.WithContext(stdcontext.WithValue(dbo.Statement.Context, pendingChangesKey{}, pending)).Transaction(fn); err != nil {
+		return err
+	}
+	for _, change := range pending.changes {
+		publishChange(change)
+	}
+	return nil
+}
+
+// statementChanges returns a change for each row of the statement, or a reset when the rows are not identified
+// by their primary key.
+func statementChanges(action string, statement *gorm.Statement) []Change {
+	var table = statement.Schema.Table
+	var tenant = statement.Schema.LookUpField("tenant_id")
+	var value = reflect.Indirect(statement.ReflectValue)
+	var rows []reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		rows = append(rows, value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, reflect.Indirect(value.Index(i)))
+		}
+	}
+	var changes []Change
+	for _, row := range rows {
+		var keys = map[string]interface{}{}
+		for _, field := range statement.Schema.PrimaryFields {
+			v, zero := field.ValueOf(statement.Context, row)
+			if zero {
+				return []Change{{Action: ChangeReset, Table: table}}
+			}
+			keys[jsonName(field.Tag.Get("json"), field.Name)] = v
+		}
+		if len(keys) == 0 {
+			return []Change{{Action: ChangeReset, Table: table}}
+		}
+		var change = Change{Action: action, Table: table, Keys: keys}
+		if tenant != nil {
+			v, _ := tenant.ValueOf(statement.Context, row)
+			change.tenant, _ = v.(string)
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return []Change{{Action: ChangeReset, Table: table}}
+	}
+	return changes
+}
+
+// jsonName returns the name of a field in the json tag, or the name of the field if the tag has none.
+func jsonName(tag string, name string) string {
+	if tag = strings.Split(tag, ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return name
+}
+
+// StreamChanges writes the changes of the rows of the resource as `change` server-sent events until the client
+// disconnects. Query parameters filter the rows like on the paginate endpoint, and the policies of the resource
+// apply: created and updated rows are sent only if the user can see them with the filters of the stream,
+// deletions carry the primary key only. Streams receive the changes made on the same instance.
+func StreamChanges(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var sample = context.GetObject().Addr().Interface()
+	base, err := filterMapper(context.Request.QueryString(), context, context.GetDBO().Model(sample))
+	if err != nil {
+		return err
+	}
+	base, err = context.applySegment(base)
+	if err != nil {
+		return err
+	}
+	base = context.ApplyPolicies(base).Session(&gorm.Session{})
+
+	var table = context.Action.Resource.Table
+	context.streamed = true
+	context.Request.SetHeader("Content-Type", "text/event-stream")
+	context.Request.SetHeader("Cache-Control", "no-cache")
+	context.Request.SetHeader("X-Accel-Buffering", "no")
+	context.Request.Context.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var subscription = subscribeChanges(table)
+		defer unsubscribeChanges(table, subscription)
+		var heartbeat = time.NewTicker(ChangeHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case change := <-subscription.changes:
+				if subscription.lost.Swap(false) {
+					change = Change{Action: ChangeReset, Table: table}
+				}
+				if !context.visibleChange(&change) {
+					continue
+				}
+				if change.Action != ChangeReset && change.Action != ChangeDelete {
+					context.loadChange(base, &change)
+				}
+				if context.Action.Resource.Feature.ObfuscateID && change.Keys != nil {
+					change.Keys, _ = context.Action.Resource.obfuscateIDs(change.Keys).(map[string]interface{})
+					if change.Data != nil {
+						change.Data = context.Action.Resource.obfuscateIDs(change.Data)
+					}
+				}
+				if writeChange(w, change) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if subscription.lost.Swap(false) {
+					if writeChange(w, Change{Action: ChangeReset, Table: table}) != nil {
+						return
+					}
+					continue
+				}
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if w.Flush() != nil {
+					// client went away
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// visibleChange reports whether the change of a row of a resource bound to tenants, see TenantSetter, belongs
+// to the tenant of the context, so the keys of the rows of the other tenants are not sent. The changes of the
+// rows of unknown tenant are turned into a reset.
+func (context *Context) visibleChange(change *Change) bool {
+	if change.Action == ChangeReset {
+		return true
+	}
+	if _, ok := context.GetObject().Addr().Interface().(TenantSetter); !ok {
+		return true
+	}
+	if change.tenant == "" {
+		*change = Change{Action: ChangeReset, Table: change.Table, Cursor: change.Cursor}
+		return true
+	}
+	return change.tenant == context.Tenant()
+}
+
+// loadChange reads the changed row through the filters of the stream, turning the change into a remove
+// when the row does not match them.
+func (context *Context) loadChange(base *gorm.DB, change *Change) {
+	var ptr = context.GetObject().Addr().Interface()
+	var query = base
+	for _, field := range context.Schema.PrimaryFields {
+		query = query.Where(field.DBName+" = ?", change.Keys[jsonName(field.Tag.Get("json"), field.Name)])
+	}
+	if query.Take(ptr).RowsAffected == 0 {
+		change.Action = ChangeRemove
+		return
+	}
+	context.afterRead(ptr)
+	change.Data = ptr
+}
+
+// afterRead prepares a row read from the database to be sent to the client, like the rows of Paginate.
+func (context *Context) afterRead(ptr interface{}) {
+	context.ReadRow(reflect.ValueOf(ptr))
+	if obj, ok := ptr.(interface{ AfterGet(context *Context) error }); ok {
+		if err := obj.AfterGet(context); err != nil {
+			context.Logger().Error("unable to read changed row", "error", err.Error())
+		}
+	}
+}
+
+func writeChange(w *bufio.Writer, change Change) error {
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: change\ndata: %s\n\n", b); err != nil {
+		return err
+	}
+	return w.Flush()
+}
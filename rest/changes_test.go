@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestStatementChanges(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"row", &orderUser{ID: 7}, "[{update user map[id:7]}]"},
+		{"rows", &[]orderUser{{ID: 1}, {ID: 2}}, "[{update user map[id:1]} {update user map[id:2]}]"},
+		{"pointers", []*orderUser{{ID: 3}}, "[{update user map[id:3]}]"},
+		{"no key", &orderUser{FirstName: "Mario"}, "[{reset user map[]}]"},
+		{"empty", &[]orderUser{}, "[{reset user map[]}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var statement = &gorm.Statement{Schema: s, Context: context.Background(), ReflectValue: reflect.ValueOf(tt.value)}
+			var got []string
+			for _, change := range statementChanges(ChangeUpdate, statement) {
+				got = append(got, fmt.Sprintf("{%s %s %v}", change.Action, change.Table, change.Keys))
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("statementChanges() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishChange(t *testing.T) {
+	var saved = ChangeBufferSize
+	ChangeBufferSize = 1
+	defer func() { ChangeBufferSize = saved }()
+	var a, b = subscribeChanges("order"), subscribeChanges("user")
+	defer unsubscribeChanges("user", b)
+	publishChange(Change{Action: ChangeCreate, Table: "order"})
+	if len(a.changes) != 1 || len(b.changes) != 0 || a.lost.Load() {
+		t.Fatalf("changes = %d, %d", len(a.changes), len(b.changes))
+	}
+	publishChange(Change{Action: ChangeUpdate, Table: "order"})
+	if !a.lost.Load() {
+		t.Errorf("expected the stream falling behind to be marked lost")
+	}
+	unsubscribeChanges("order", a)
+	if hasChangeSubscribers("order") {
+		t.Errorf("subscribers of order not removed")
+	}
+}
+
+func TestVisibleChange(t *testing.T) {
+	var north = "north"
+	var scoped = &Context{Object: reflect.ValueOf(Segment{}), tenant: &north}
+	var keys = map[string]interface{}{"id": 1}
+	var tests = []struct {
+		name    string
+		change  Change
+		visible bool
+		action  string
+	}{
+		{"same tenant", Change{Action: ChangeDelete, Keys: keys, tenant: "north"}, true, ChangeDelete},
+		{"other tenant", Change{Action: ChangeDelete, Keys: keys, tenant: "south"}, false, ChangeDelete},
+		{"unknown tenant", Change{Action: ChangeUpdate, Keys: keys}, true, ChangeReset},
+		{"reset", Change{Action: ChangeReset}, true, ChangeReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var change = tt.change
+			if got := scoped.visibleChange(&change); got != tt.visible || change.Action != tt.action {
+				t.Errorf("visibleChange() = %v %s, want %v %s", got, change.Action, tt.visible, tt.action)
+			}
+			if change.Action == ChangeReset && change.Keys != nil {
+				t.Errorf("expected the reset to carry no keys, got %v", change.Keys)
+			}
+		})
+	}
+	s, err := schema.Parse(&Segment{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement = &gorm.Statement{Schema: s, Context: context.Background(), ReflectValue: reflect.ValueOf(&Segment{ID: 1, TenantID: "south"})}
+	if changes := statementChanges(ChangeDelete, statement); changes[0].tenant != "south" {
+		t.Errorf("expected the tenant of the row, got %q", changes[0].tenant)
+	}
+	var untenanted = &Context{Object: reflect.ValueOf(orderUser{}), tenant: &north}
+	if change := (Change{Action: ChangeDelete, Keys: keys}); !untenanted.visibleChange(&change) || change.Action != ChangeDelete {
+		t.Errorf("expected the changes of resources without tenant to be sent, got %+v", change)
+	}
+}
@@ -0,0 +1,228 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrorUnknownRelation is returned when a clone request names a relation which is not a has-many relation of the resource.
+var ErrorUnknownRelation = errors.New("unknown has-many relation")
+
+// CloneStrategy returns the value of a unique field of a copy from its value in the original row.
+type CloneStrategy func(field *schema.Field, value interface{}) interface{}
+
+// CloneSuffix is appended by CopySuffix to the unique string fields of the copies.
+var CloneSuffix = "-copy"
+
+// CopySuffix is the default CloneStrategy: it appends CloneSuffix to the strings, trimmed to the size of the
+// column, and resets the values of the other types.
+func CopySuffix(field *schema.Field, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return copySuffix(field, v)
+	case *string:
+		if v != nil {
+			var s = copySuffix(field, *v)
+			return &s
+		}
+	}
+	return reflect.Zero(field.FieldType).Interface()
+}
+
+// copySuffix appends CloneSuffix to the string, trimming it to fit the size of the column.
+func copySuffix(field *schema.Field, s string) string {
+	if field.Size > len(CloneSuffix) && len(s)+len(CloneSuffix) > field.Size {
+		s = s[:field.Size-len(CloneSuffix)]
+	}
+	return s + CloneSuffix
+}
+
+// cloneStrategy returns the strategy of the model, given by its RESTCloneStrategy() CloneStrategy method, CopySuffix by default.
+func cloneStrategy(object interface{}) CloneStrategy {
+	if obj, ok := object.(interface{ RESTCloneStrategy() CloneStrategy }); ok {
+		if strategy := obj.RESTCloneStrategy(); strategy != nil {
+			return strategy
+		}
+	}
+	return CopySuffix
+}
+
+// uniqueFields returns the fields of the schema which can not be copied as they are: the unique columns,
+// the columns of single column unique indexes and the slug columns. Primary keys are reset separately.
+func uniqueFields(s *schema.Schema) []*schema.Field {
+	s.ParseIndexes()
+	var fields []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey {
+			continue
+		}
+		if field.Unique || field.DBName == "slug" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// resetCopy prepares the row of the schema to be inserted as a copy: the primary key, unless it is part of a
+// composite key without auto-increment, and the timestamps set by gorm are reset and the unique fields are
+// changed by the strategy. Models with keys generated by the application implement IDGenerator.
+func resetCopy(context *Context, s *schema.Schema, object reflect.Value, strategy CloneStrategy) error {
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if (field.PrimaryKey && (field.AutoIncrement || len(s.PrimaryFields) == 1)) || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+			if err := field.Set(context.ctx(), object, reflect.Zero(field.FieldType).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, field := range uniqueFields(s) {
+		value, _ := field.ValueOf(context.ctx(), object)
+		if err := field.Set(context.ctx(), object, strategy(field, value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneRelations returns the has-many relations of the schema listed by name or JSON name in the ?children= query.
+func cloneRelations(s *schema.Schema, names string) ([]*schema.Relationship, error) {
+	var relations []*schema.Relationship
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found *schema.Relationship
+		for _, relation := range s.Relationships.HasMany {
+			var tag = strings.Split(relation.Field.Tag.Get("json"), ",")[0]
+			if strings.EqualFold(relation.Name, name) || tag == name {
+				found = relation
+				break
+			}
+		}
+		if found == nil {
+			return nil, ErrorUnknownRelation
+		}
+		relations = append(relations, found)
+	}
+	return relations, nil
+}
+
+// cloneChildren copies the rows of a has-many relation of the original row to the copy.
+func (context *Context) cloneChildren(tx *gorm.DB, relation *schema.Relationship, original, clone reflect.Value) error {
+	var query = tx
+	for _, ref := range relation.References {
+		if ref.PrimaryKey == nil {
+			query = query.Where(clause.Eq{Column: clause.Column{Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+			continue
+		}
+		value, _ := ref.PrimaryKey.ValueOf(context.ctx(), original)
+		query = query.Where(clause.Eq{Column: clause.Column{Name: ref.ForeignKey.DBName}, Value: value})
+	}
+	var slice = reflect.New(relation.Field.IndirectFieldType)
+	if err := query.Find(slice.Interface()).Error; err != nil {
+		return err
+	}
+	var children = slice.Elem()
+	var strategy = cloneStrategy(reflect.New(relation.FieldSchema.ModelType).Interface())
+	for i := 0; i < children.Len(); i++ {
+		var child = reflect.Indirect(children.Index(i))
+		if err := resetCopy(context, relation.FieldSchema, child, strategy); err != nil {
+			return err
+		}
+		context.generateID(child.Addr().Interface())
+		for _, ref := range relation.References {
+			if ref.PrimaryKey == nil {
+				continue
+			}
+			value, _ := ref.PrimaryKey.ValueOf(context.ctx(), clone)
+			if err := ref.ForeignKey.Set(context.ctx(), child, value); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit(clause.Associations).Create(child.Addr().Interface()).Error; err != nil {
+			return err
+		}
+	}
+	return relation.Field.Set(context.ctx(), clone, children.Interface())
+}
+
+// Clone copies the row given by the primary key, along with the rows of the has-many relations listed by
+// the ?children= query, e.g. ?children=lines,notes. The primary keys and the timestamps of the copies are
+// reset and their unique fields are changed by the clone strategy of the model, see CloneStrategy.
+// The create hooks of the model run for the copy of the row, not for the copies of the children, whose keys are
+// generated when they implement IDGenerator.
+func Clone(context *Context) error {
+	if err := context.HasPerm("CREATE"); err != nil {
+		return err
+	}
+	relations, err := cloneRelations(context.Schema, context.Request.Query("children").String())
+	if err != nil {
+		return err
+	}
+	var original = context.GetObject()
+	var ptr = original.Addr().Interface()
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorObjectNotExist
+	}
+
+	var object = context.GetObject()
+	object.Set(original)
+	ptr = object.Addr().Interface()
+	for _, relation := range context.Schema.Relationships.Relations {
+		if relation.Type == schema.HasMany || relation.Type == schema.HasOne {
+			_ = relation.Field.Set(context.ctx(), object, reflect.Zero(relation.Field.FieldType).Interface())
+		}
+	}
+	if err := resetCopy(context, context.Schema, object, cloneStrategy(ptr)); err != nil {
+		return err
+	}
+	context.stampTenant(ptr)
+	context.generateID(ptr)
+
+	if obj, ok := ptr.(interface{ BeforeCreate(context *Context) error }); ok {
+		if err := obj.BeforeCreate(context); err != nil {
+			return err
+		}
+	}
+	if obj, ok := ptr.(interface{ ValidateCreate(context *Context) error }); ok {
+		if err := obj.ValidateCreate(context); err != nil {
+			return err
+		}
+	}
+	if err := context.beforeCreateHooks(ptr); err != nil {
+		return err
+	}
+	err = context.transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(ptr).Error; err != nil {
+			return err
+		}
+		for _, relation := range relations {
+			if err := context.cloneChildren(tx, relation, original, object); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if obj, ok := ptr.(interface{ AfterCreate(context *Context) error }); ok {
+		if err := obj.AfterCreate(context); err != nil {
+			return err
+		}
+	}
+	context.Response.Data = ptr
+	return nil
+}
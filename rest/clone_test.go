@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type cloneLine struct {
+	ID      int    `gorm:"column:id;primaryKey"`
+	OrderID int    `gorm:"column:order_id"`
+	SKU     string `gorm:"column:sku;size:12;uniqueIndex"`
+}
+
+type cloneOrder struct {
+	ID    int         `gorm:"column:id;primaryKey"`
+	Slug  string      `gorm:"column:slug"`
+	Code  string      `gorm:"column:code;unique"`
+	Note  *string     `gorm:"column:note;unique"`
+	Name  string      `gorm:"column:name"`
+	Lines []cloneLine `gorm:"foreignKey:OrderID" json:"lines"`
+}
+
+func TestCopySuffix(t *testing.T) {
+	s, err := schema.Parse(&cloneLine{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sku = s.LookUpField("sku")
+	var tests = []struct {
+		value string
+		want  string
+	}{
+		{"", "-copy"},
+		{"ABC", "ABC-copy"},
+		{"ABCDEFG", "ABCDEFG-copy"},
+		{"ABCDEFGHIJ", "ABCDEFG-copy"},
+	}
+	for _, test := range tests {
+		if got := CopySuffix(sku, test.value); got != test.want {
+			t.Errorf("CopySuffix(%q) = %v, want %q", test.value, got, test.want)
+		}
+	}
+	var id = s.LookUpField("id")
+	if got := CopySuffix(id, 5); got != 0 {
+		t.Errorf("CopySuffix(5) = %v, want 0", got)
+	}
+}
+
+func TestUniqueFields(t *testing.T) {
+	var tests = []struct {
+		object interface{}
+		want   []string
+	}{
+		{&cloneOrder{}, []string{"slug", "code", "note"}},
+		{&cloneLine{}, []string{"sku"}},
+	}
+	for _, test := range tests {
+		s, err := schema.Parse(test.object, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, field := range uniqueFields(s) {
+			got = append(got, field.DBName)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("uniqueFields(%T) = %v, want %v", test.object, got, test.want)
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("uniqueFields(%T) = %v, want %v", test.object, got, test.want)
+			}
+		}
+	}
+}
+
+func TestCloneRelations(t *testing.T) {
+	s, err := schema.Parse(&cloneOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		names string
+		want  int
+		err   error
+	}{
+		{"", 0, nil},
+		{"lines", 1, nil},
+		{"Lines", 1, nil},
+		{" lines ,", 1, nil},
+		{"notes", 0, ErrorUnknownRelation},
+	}
+	for _, test := range tests {
+		relations, err := cloneRelations(s, test.names)
+		if !errors.Is(err, test.err) || len(relations) != test.want {
+			t.Errorf("cloneRelations(%q) = %d, %v, want %d, %v", test.names, len(relations), err, test.want, test.err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/db"
+	scm "github.com/getevo/evo/v2/lib/db/schema"
+)
+
+// Computed is implemented by models exposing derived fields which are not stored, e.g. full names, totals or URLs.
+// The fields are appended to every row of the model returned by the endpoints of the resource, and the columns of
+// the filter views reference them by FilterViewColumn.Computed. Computed fields can not be sorted or filtered on.
+//
+//	func (u *User) Computed(context *rest.Context) map[string]interface{} {
+//		return map[string]interface{}{"full_name": u.FirstName + " " + u.LastName}
+//	}
+type Computed interface {
+	Computed(context *Context) map[string]interface{}
+}
+
+// computedRow returns the JSON representation of the row with its computed fields appended. Numbers are kept
+// as json.Number so 64-bit keys do not lose precision.
+func (context *Context) computedRow(row reflect.Value) interface{} {
+	obj, ok := row.Addr().Interface().(Computed)
+	if !ok {
+		return row.Addr().Interface()
+	}
+	b, err := json.Marshal(row.Addr().Interface())
+	if err != nil {
+		return row.Addr().Interface()
+	}
+	var decoder = json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var result map[string]interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return row.Addr().Interface()
+	}
+	for key, value := range obj.Computed(context) {
+		result[key] = value
+	}
+	return result
+}
+
+// withComputed appends the computed fields to the rows of the model in the response data: a row, a pointer to
+// a row, or a slice of rows or pointers. Other data is returned as is.
+func (context *Context) withComputed(data interface{}) interface{} {
+	var typ = context.Object.Type()
+	if !reflect.PtrTo(typ).Implements(reflect.TypeOf((*Computed)(nil)).Elem()) {
+		return data
+	}
+	var v = reflect.ValueOf(data)
+	switch {
+	case v.Kind() == reflect.Ptr && v.Type().Elem() == typ && !v.IsNil():
+		return context.computedRow(v.Elem())
+	case v.Kind() == reflect.Struct && v.Type() == typ:
+		var row = reflect.New(typ).Elem()
+		row.Set(v)
+		return context.computedRow(row)
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Slice && !v.IsNil():
+		return context.withComputed(v.Elem().Interface())
+	case v.Kind() == reflect.Slice && (v.Type().Elem() == typ || v.Type().Elem() == reflect.PtrTo(typ)):
+		var result = make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			var row = reflect.Indirect(v.Index(i))
+			if !row.IsValid() {
+				result = append(result, nil)
+				continue
+			}
+			result = append(result, context.computedRow(row))
+		}
+		return result
+	}
+	return data
+}
+
+// key returns the key of the value of the column in the rows of the view.
+func (c *FilterViewColumn) key() string {
+	if c.Computed != "" {
+		return c.Computed
+	}
+	return c.DBField
+}
+
+// computeColumns adds the computed fields referenced by the columns of the view to the rows, joined by the
+// primary key columns selected as keyAliases. The rows of the model are loaded in one query. Models without a
+// primary key have no computed columns.
+func (v *FilterView) computeColumns(m *scm.Model, rows []map[string]interface{}, params ViewParams) error {
+	var computed bool
+	for _, column := range v.Columns {
+		computed = computed || column.Computed != ""
+	}
+	if !computed || len(rows) == 0 || len(m.PrimaryKey) == 0 {
+		return nil
+	}
+	if _, ok := reflect.New(m.Value.Type()).Interface().(Computed); !ok {
+		return nil
+	}
+	var aliases = keyAliases(m)
+	var where = make([]string, len(rows))
+	var args []interface{}
+	for i, row := range rows {
+		var conditions = make([]string, len(aliases))
+		for j, alias := range aliases {
+			conditions[j] = "`" + m.Table + "`.`" + m.PrimaryKey[j] + "` = ?"
+			args = append(args, row[alias])
+		}
+		where[i] = "(" + strings.Join(conditions, " AND ") + ")"
+	}
+	var slice = reflect.New(reflect.SliceOf(m.Value.Type()))
+	if err := db.Where(strings.Join(where, " OR "), args...).Find(slice.Interface()).Error; err != nil {
+		return err
+	}
+	var context = &Context{Object: m.Value, Schema: m.Schema, Response: &Pagination{}}
+	if p, ok := params.(requestParams); ok {
+		context.Request = p.request
+	}
+	if resource, err := GetResource(m.Sample); err == nil {
+		context.Action = &Endpoint{Name: "FILTER VIEW", Resource: resource, Object: resource.Object}
+	}
+	var values = map[string]map[string]interface{}{}
+	for i := 0; i < slice.Elem().Len(); i++ {
+		var row = slice.Elem().Index(i)
+		var key = map[string]interface{}{}
+		for j, column := range m.PrimaryKey {
+			key[aliases[j]], _ = m.Schema.LookUpField(column).ValueOf(context.ctx(), row)
+		}
+		values[rowKey(key, aliases)] = row.Addr().Interface().(Computed).Computed(context)
+	}
+	for _, row := range rows {
+		for key, value := range values[rowKey(row, aliases)] {
+			row[key] = value
+		}
+	}
+	return nil
+}
+
+// keyString returns the string form of a primary key read by the ORM or scanned into a map.
+func keyString(v interface{}) string {
+	switch value := v.(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	scm "github.com/getevo/evo/v2/lib/db/schema"
+)
+
+type computedPerson struct {
+	ID    int64  `json:"id"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+func (p *computedPerson) Computed(context *Context) map[string]interface{} {
+	return map[string]interface{}{"full_name": p.First + " " + p.Last}
+}
+
+type computedPlain struct {
+	ID int `json:"id"`
+}
+
+func TestWithComputed(t *testing.T) {
+	var context = &Context{Object: reflect.ValueOf(computedPerson{})}
+	var row = map[string]interface{}{"id": json.Number("1"), "first": "Jane", "last": "Doe", "full_name": "Jane Doe"}
+	var tests = []struct {
+		name string
+		data interface{}
+		want interface{}
+	}{
+		{"pointer", &computedPerson{ID: 1, First: "Jane", Last: "Doe"}, row},
+		{"struct", computedPerson{ID: 1, First: "Jane", Last: "Doe"}, row},
+		{"slice", []computedPerson{{ID: 1, First: "Jane", Last: "Doe"}}, []interface{}{row}},
+		{"pointer to slice", &[]computedPerson{{ID: 1, First: "Jane", Last: "Doe"}}, []interface{}{row}},
+		{"slice of pointers", []*computedPerson{{ID: 1, First: "Jane", Last: "Doe"}, nil}, []interface{}{row, nil}},
+		{"other data", map[string]int{"rows": 2}, map[string]int{"rows": 2}},
+	}
+	for _, test := range tests {
+		if got := context.withComputed(test.data); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: withComputed() = %#v, want %#v", test.name, got, test.want)
+		}
+	}
+
+	var large = context.withComputed(&computedPerson{ID: 1<<53 + 1}).(map[string]interface{})
+	if large["id"] != json.Number("9007199254740993") {
+		t.Errorf("withComputed() id = %v, want 9007199254740993", large["id"])
+	}
+
+	var plain = &Context{Object: reflect.ValueOf(computedPlain{})}
+	var data = []computedPlain{{ID: 1}}
+	if got := plain.withComputed(data); !reflect.DeepEqual(got, data) {
+		t.Errorf("withComputed() of a model without computed fields = %#v, want %#v", got, data)
+	}
+}
+
+func TestKeyString(t *testing.T) {
+	var tests = []struct {
+		value interface{}
+		want  string
+	}{
+		{int64(5), "5"},
+		{uint64(5), "5"},
+		{[]byte("abc"), "abc"},
+		{"abc", "abc"},
+	}
+	for _, test := range tests {
+		if got := keyString(test.value); got != test.want {
+			t.Errorf("keyString(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestComputeColumnsWithoutKey(t *testing.T) {
+	var view = &FilterView{Columns: []FilterViewColumn{{Computed: "full_name"}}}
+	var model = &scm.Model{Value: reflect.ValueOf(computedPerson{})}
+	var rows = []map[string]interface{}{{"first": "Jane"}}
+	if err := view.computeColumns(model, rows, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rows[0]["full_name"]; ok {
+		t.Errorf("expected no computed fields without a primary key, got %v", rows[0])
+	}
+}
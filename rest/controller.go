@@ -2,6 +2,8 @@ package rest
 
 import (
 	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/acl"
 )
 
 // Controller represents a controller type.
@@ -9,13 +11,32 @@ type Controller struct{}
 
 // Field represents a field in a data structure.
 // It contains metadata about the field, such as its name, database name, type, default value, and whether it is a primary key.
+// The other properties describe the input of the field in generic CRUD forms, see formField:
+// - Key: the key of the field in the request and response bodies.
+// - Widget: the input rendering the field, e.g. text, textarea, number, checkbox, select, date or reference.
+// - Options: the values accepted by select inputs, keyed by value.
+// - Validation, Rules: the constraints of the value, and the rules of the validation tag of the field.
+// - Reference: the resource the values of association pickers are taken from.
+// - Group: the group of fields the field is rendered in.
 type Field struct {
-	Name      string `json:"label"`
-	FieldName string `json:"-"`
-	DBName    string `json:"name,omitempty"`
-	Type      string `json:"type,omitempty"`
-	Default   string `json:"default,omitempty"`
-	PK        bool   `json:"pk,omitempty"`
+	Name        string                     `json:"label"`
+	FieldName   string                     `json:"-"`
+	DBName      string                     `json:"name,omitempty"`
+	Type        string                     `json:"type,omitempty"`
+	Default     string                     `json:"default,omitempty"`
+	PK          bool                       `json:"pk,omitempty"`
+	Key         string                     `json:"key,omitempty"`
+	Widget      string                     `json:"widget,omitempty"`
+	Required    bool                       `json:"required,omitempty"`
+	ReadOnly    bool                       `json:"readonly,omitempty"`
+	Hidden      bool                       `json:"hidden,omitempty"`
+	Options     toolbox.Dictionary[string] `json:"options,omitempty"`
+	Validation  *ColumnValidation          `json:"validation,omitempty"`
+	Rules       []string                   `json:"rules,omitempty"`
+	Reference   *FieldReference            `json:"reference,omitempty"`
+	Group       string                     `json:"group,omitempty"`
+	Placeholder string                     `json:"placeholder,omitempty"`
+	Help        string                     `json:"help,omitempty"`
 }
 
 // Param represents a parameter used in the Resource struct.
@@ -27,7 +48,7 @@ type Param struct {
 
 // Models returns a collection of resources.
 func (c Controller) Models(request *evo.Request) interface{} {
-	return resources
+	return resources.all()
 }
 
 // ORM is a method in the Controller struct that handles an ORM request.
@@ -36,3 +57,27 @@ func (c Controller) Models(request *evo.Request) interface{} {
 func (c Controller) ORM(request *evo.Request) interface{} {
 	return nil
 }
+
+// GetLogging returns the current SQL logging configuration, to the users holding acl.Admin.
+func (c Controller) GetLogging(request *evo.Request) interface{} {
+	if acl.Deny(request, acl.Admin) {
+		return nil
+	}
+	return GetSQLLogConfig()
+}
+
+// SetLogging replaces the SQL logging configuration with the one given in the request body, for the users
+// holding acl.Admin.
+func (c Controller) SetLogging(request *evo.Request) interface{} {
+	if acl.Deny(request, acl.Admin) {
+		return nil
+	}
+	var config SQLLogConfig
+	if err := request.BodyParser(&config); err != nil {
+		return err
+	}
+	if err := SetSQLLogConfig(config); err != nil {
+		return err
+	}
+	return GetSQLLogConfig()
+}
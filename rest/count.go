@@ -0,0 +1,231 @@
+package rest
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/pubsub"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+// CountStrategy is how Paginate counts the rows matching a request.
+type CountStrategy string
+
+const (
+	// CountExact counts the rows by a COUNT(*) per request.
+	CountExact CountStrategy = "exact"
+	// CountCached counts the rows by a COUNT(*) reused for the TTL of the count by the requests with the same
+	// conditions, or until a row of the table is written through gorm, Exec included. The writes of the other
+	// instances are only seen when an evo pubsub driver is configured, see CountsTopic; otherwise their counts
+	// stay stale until the TTL.
+	CountCached CountStrategy = "cached"
+	// CountEstimated reads the number of rows of the table estimated by information_schema for the requests
+	// without conditions, joins or scopes, e.g. those of the policies, flagging the response as estimated, and
+	// counts the rows exactly otherwise.
+	CountEstimated CountStrategy = "estimated"
+	// CountNone skips the count: the response reports whether a next page exists instead, see Pagination.HasNext.
+	CountNone CountStrategy = "none"
+)
+
+// Count is the counting of the rows of a resource by Paginate. Resources choose it by implementing
+// RESTCount() Count on the model, DefaultCount otherwise, and requests with ?total=none skip the count:
+//
+//	func (Event) RESTCount() rest.Count {
+//		return rest.Count{Strategy: rest.CountCached, TTL: 5 * time.Minute}
+//	}
+type Count struct {
+	Strategy CountStrategy `json:"strategy"`
+	TTL      time.Duration `json:"ttl"`
+}
+
+// DefaultCount is the counting of the resources which do not choose one. TTL is the time counts are cached for
+// by the resources choosing CountCached without a TTL.
+var DefaultCount = Count{Strategy: CountExact, TTL: time.Minute}
+
+// CountsTopic is the topic of the evo pubsub driver, when one is configured, the instances publish the tables
+// written on and forget the cached counts of, so a write on one instance invalidates the counts of all.
+var CountsTopic = "rest:counts"
+
+// MaxCachedCounts bounds the number of counts cached, the cache is emptied when it is reached.
+var MaxCachedCounts = 10000
+
+type countEntry struct {
+	total   int64
+	expires time.Time
+}
+
+// counts caches the counts by table, then by count query.
+var counts = struct {
+	sync.Mutex
+	size    int
+	entries map[string]map[string]countEntry
+}{entries: map[string]map[string]countEntry{}}
+
+// countStrategy returns the counting of the request.
+func (context *Context) countStrategy() Count {
+	var strategy = DefaultCount
+	if context.Action != nil && context.Action.Resource != nil && context.Action.Resource.Feature != nil &&
+		context.Action.Resource.Feature.Count.Strategy != "" {
+		strategy = context.Action.Resource.Feature.Count
+	}
+	if CountStrategy(context.Request.Query("total").String()) == CountNone {
+		strategy.Strategy = CountNone
+	}
+	if strategy.TTL <= 0 {
+		strategy.TTL = DefaultCount.TTL
+	}
+	return strategy
+}
+
+// count returns the number of rows matching the query according to the strategy, and whether it is estimated.
+func count(query *gorm.DB, model interface{}, strategy Count) (int64, bool, error) {
+	var total int64
+	switch strategy.Strategy {
+	case CountEstimated:
+		if unconditioned(query, model) {
+			if total, ok := estimatedCount(query, model); ok {
+				return total, true, nil
+			}
+		}
+	case CountCached:
+		var table, key = countKey(query, model)
+		if total, ok := cachedCount(table, key); ok {
+			return total, false, nil
+		}
+		if err := query.Model(model).Count(&total).Error; err != nil {
+			return 0, false, err
+		}
+		cacheCount(table, key, total, strategy.TTL)
+		return total, false, nil
+	}
+	err := query.Model(model).Count(&total).Error
+	return total, false, err
+}
+
+// unconditioned reports whether the count of the query is the count of the whole table of the model: the query
+// has no conditions, joins or scopes, such as those the policies add.
+func unconditioned(query *gorm.DB, model interface{}) bool {
+	var countSQL = func(tx *gorm.DB) string {
+		return tx.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			var total int64
+			return tx.Model(model).Count(&total)
+		})
+	}
+	// Model materializes the new statement, ToSQL would otherwise copy the statement of the query
+	return countSQL(query.Session(&gorm.Session{})) == countSQL(query.Session(&gorm.Session{NewDB: true}).Model(model))
+}
+
+// estimatedCount returns the number of rows of the table of the model estimated by information_schema.
+func estimatedCount(query *gorm.DB, model interface{}) (int64, bool) {
+	var stmt = query.Session(&gorm.Session{NewDB: true}).Model(model).Statement
+	if err := stmt.Parse(model); err != nil {
+		return 0, false
+	}
+	var rows *int64
+	err := query.Session(&gorm.Session{NewDB: true}).
+		Raw("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", stmt.Schema.Table).
+		Scan(&rows).Error
+	if err != nil || rows == nil {
+		return 0, false
+	}
+	return *rows, true
+}
+
+// countKey returns the table of the query and the SQL of its conditions identifying its count.
+func countKey(query *gorm.DB, model interface{}) (string, string) {
+	var table string
+	var sql = query.Session(&gorm.Session{}).ToSQL(func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Model(model).Find(model)
+		if tx.Statement.Schema != nil {
+			table = tx.Statement.Schema.Table
+		}
+		return tx
+	})
+	return table, sql
+}
+
+// cachedCount returns the count of the query cached for the table, if not expired.
+func cachedCount(table string, key string) (int64, bool) {
+	counts.Lock()
+	defer counts.Unlock()
+	entry, ok := counts.entries[table][key]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, false
+	}
+	return entry.total, true
+}
+
+// cacheCount caches the count of the query for the TTL.
+func cacheCount(table string, key string, total int64, ttl time.Duration) {
+	counts.Lock()
+	defer counts.Unlock()
+	if counts.size >= MaxCachedCounts {
+		counts.entries, counts.size = map[string]map[string]countEntry{}, 0
+	}
+	if counts.entries[table] == nil {
+		counts.entries[table] = map[string]countEntry{}
+	}
+	if _, ok := counts.entries[table][key]; !ok {
+		counts.size++
+	}
+	counts.entries[table][key] = countEntry{total: total, expires: time.Now().Add(ttl)}
+}
+
+// forgetCounts removes the cached counts of the table, of every table when it is empty.
+func forgetCounts(table string) {
+	counts.Lock()
+	if table == "" {
+		counts.entries, counts.size = map[string]map[string]countEntry{}, 0
+	} else {
+		counts.size -= len(counts.entries[table])
+		delete(counts.entries, table)
+	}
+	counts.Unlock()
+}
+
+// invalidateCounts forgets the cached counts of the table once a row is written, and publishes the table on
+// CountsTopic for the other instances to forget them as well.
+func invalidateCounts(table string) {
+	forgetCounts(table)
+	if len(pubsub.Drivers()) == 0 {
+		return
+	}
+	if err := pubsub.PublishBytes(CountsTopic, []byte(table)); err != nil {
+		logger.Error("unable to publish count invalidation", "table", table, "error", err.Error())
+	}
+}
+
+// subscribeCounts forgets the cached counts of the tables published on CountsTopic by the other instances, when
+// an evo pubsub driver is configured.
+func subscribeCounts() {
+	if len(pubsub.Drivers()) == 0 {
+		return
+	}
+	pubsub.Subscribe(CountsTopic, func(topic string, message []byte, driver pubsub.Interface) {
+		forgetCounts(string(message))
+	})
+}
+
+// execTable matches the table written by a raw statement.
+var execTable = regexp.MustCompile("(?i)^\\s*(?:UPDATE(?:\\s+IGNORE)?|INSERT(?:\\s+IGNORE)?\\s+INTO|REPLACE\\s+INTO|DELETE\\s+FROM)\\s+[`\"]?([\\w.]+?)[`\"]?(?:\\s|\\(|$)")
+
+// execCallback forgets the cached counts of the table written by a statement run with Exec, of every table when
+// the statement does not name it plainly, e.g. a multi-table update.
+func execCallback(db *gorm.DB) {
+	if db.Error != nil || db.Statement.RowsAffected == 0 {
+		return
+	}
+	var sql = db.Statement.SQL.String()
+	if m := execTable.FindStringSubmatch(sql); m != nil {
+		var table = m[1]
+		if i := strings.LastIndex(table, "."); i >= 0 {
+			table = table[i+1:]
+		}
+		invalidateCounts(table)
+		return
+	}
+	invalidateCounts("")
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+type countedModel struct {
+	ID int `gorm:"column:id;primaryKey"`
+}
+
+func (countedModel) RESTCount() Count {
+	return Count{Strategy: CountCached, TTL: time.Hour}
+}
+
+func TestCountFeature(t *testing.T) {
+	if got := GetFeatures(countedModel{}).Count; got.Strategy != CountCached || got.TTL != time.Hour {
+		t.Errorf("Count = %+v, want the count of RESTCount()", got)
+	}
+	if got := GetFeatures(orderUser{}).Count; got.Strategy != "" {
+		t.Errorf("Count = %+v on a model without RESTCount()", got)
+	}
+}
+
+func TestCountCache(t *testing.T) {
+	defer forgetCounts("events")
+	defer forgetCounts("orders")
+	cacheCount("events", "SELECT * FROM events", 42, time.Hour)
+	cacheCount("events", "SELECT * FROM events WHERE id > 1", 41, -time.Second)
+	cacheCount("orders", "SELECT * FROM orders", 7, time.Hour)
+
+	var tests = []struct {
+		table, key string
+		total      int64
+		ok         bool
+	}{
+		{"events", "SELECT * FROM events", 42, true},
+		{"events", "SELECT * FROM events WHERE id > 1", 0, false},
+		{"events", "SELECT * FROM events WHERE id > 2", 0, false},
+		{"orders", "SELECT * FROM orders", 7, true},
+	}
+	for _, test := range tests {
+		if total, ok := cachedCount(test.table, test.key); total != test.total || ok != test.ok {
+			t.Errorf("cachedCount(%s, %q) = %d, %v, want %d, %v", test.table, test.key, total, ok, test.total, test.ok)
+		}
+	}
+
+	forgetCounts("events")
+	if _, ok := cachedCount("events", "SELECT * FROM events"); ok {
+		t.Error("the counts of the table are cached after forgetCounts")
+	}
+	if _, ok := cachedCount("orders", "SELECT * FROM orders"); !ok {
+		t.Error("forgetCounts removed the counts of another table")
+	}
+}
+
+func TestCountCacheBound(t *testing.T) {
+	var max = MaxCachedCounts
+	MaxCachedCounts = 2
+	defer func() {
+		MaxCachedCounts = max
+		forgetCounts("events")
+	}()
+	cacheCount("events", "a", 1, time.Hour)
+	cacheCount("events", "b", 2, time.Hour)
+	cacheCount("events", "c", 3, time.Hour)
+	if _, ok := cachedCount("events", "a"); ok {
+		t.Error("the cache is not emptied once MaxCachedCounts is reached")
+	}
+	if total, ok := cachedCount("events", "c"); !ok || total != 3 {
+		t.Errorf("cachedCount(c) = %d, %v, want 3, true", total, ok)
+	}
+}
+
+func TestUnconditioned(t *testing.T) {
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name  string
+		query *gorm.DB
+		want  bool
+	}{
+		{"plain", db, true},
+		{"where", db.Where("id > ?", 1), false},
+		{"join", db.Joins("JOIN members ON members.id = counted_models.id"), false},
+		{"scope", db.Scopes(func(tx *gorm.DB) *gorm.DB { return tx.Where("id > ?", 1) }), false},
+	}
+	for _, test := range tests {
+		if got := unconditioned(test.query, &[]countedModel{}); got != test.want {
+			t.Errorf("%s: unconditioned() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExecTable(t *testing.T) {
+	var tests = []struct {
+		sql, table string
+	}{
+		{"UPDATE `events` SET `position` = 1 WHERE id = 2", "events"},
+		{"update events set x = 1", "events"},
+		{"INSERT INTO \"events\" (id) VALUES (1)", "events"},
+		{"INSERT IGNORE INTO events(id) VALUES (1)", "events"},
+		{"DELETE FROM app.events WHERE id = 1", "events"},
+		{"DELETE FROM `app`.`events` WHERE id = 1", ""},
+		{"UPDATE events e JOIN orders o ON o.id = e.order_id SET e.x = 1", "events"},
+		{"CREATE TABLE events (id int)", ""},
+	}
+	for _, test := range tests {
+		var table string
+		if m := execTable.FindStringSubmatch(test.sql); m != nil {
+			table = m[1]
+		}
+		if test.table != "" && table != test.table && table != "app."+test.table {
+			t.Errorf("execTable(%q) = %q, want %q", test.sql, table, test.table)
+		}
+		if test.table == "" && table != "" {
+			t.Errorf("execTable(%q) = %q, want no table", test.sql, table)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// PKParam is the URL segment standing for the primary keys of the resource in the URLs of custom endpoints.
+const PKParam = ":pk"
+
+// Custom registers an endpoint of the resource for the host app, e.g.
+//
+//	resource, _ := rest.GetResource(&Order{})
+//	resource.Custom(rest.POST, "/:pk/approve", ApproveOrder, rest.UpdatePermission)
+//
+// The endpoint is named after the last static segment of its URL, Approve here, and listed by ModelInfo along
+// with the built-in endpoints; the returned endpoint may be given a description. The user must be authenticated
+// and have every permission given, which are added to the permissions of the resource. When the URL holds
+// PKParam, it is replaced by the primary keys of the resource and the row is loaded before the handler runs,
+// answering ErrorObjectNotExist when it is missing or out of the policies of the user; the handler gets it by
+// LoadRow.
+func (res *Resource) Custom(method Method, url string, handler func(context *Context) error, permissions ...acl.Permission) *Endpoint {
+	var segments []string
+	var name string
+	var pk bool
+	for _, segment := range strings.Split(strings.Trim(url, "/"), "/") {
+		switch {
+		case segment == PKParam:
+			pk = true
+			for _, field := range res.Schema.PrimaryFields {
+				segments = append(segments, ":"+field.DBName)
+			}
+			continue
+		case segment != "" && !strings.HasPrefix(segment, ":"):
+			name = segment
+		}
+		segments = append(segments, segment)
+	}
+	if name == "" {
+		name = string(method)
+	}
+	var action = &Endpoint{
+		Name:        strcase.ToCamel(name),
+		Method:      method,
+		URL:         strings.Join(segments, "/"),
+		Permissions: permissions,
+		Handler: func(context *Context) error {
+			if context.User().Anonymous() {
+				return ErrorUnauthorized
+			}
+			for _, permission := range permissions {
+				if err := context.HasPerm(permission.Key); err != nil {
+					return err
+				}
+			}
+			if pk {
+				if _, err := context.LoadRow(); err != nil {
+					return err
+				}
+			}
+			return handler(context)
+		},
+	}
+	res.Action(action)
+	return action
+}
+
+// LoadRow returns a pointer to the row given by the primary keys in the URL, with the policies of the resource
+// applied, or ErrorObjectNotExist. The row is loaded once per request.
+func (context *Context) LoadRow() (interface{}, error) {
+	if context.row != nil {
+		return context.row, nil
+	}
+	var object = context.GetObject()
+	var ptr = object.Addr().Interface()
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrorObjectNotExist
+	}
+	context.ReadRow(object)
+	context.row = ptr
+	return ptr, nil
+}
+
+// BindObject parses the request body into a new row of the model, prepared like the create endpoint does:
+// normalized, see NormalizeInput, and stamped with the tenant of the request.
+func (context *Context) BindObject() (interface{}, error) {
+	var object = context.GetObject()
+	var ptr = object.Addr().Interface()
+	if err := context.parseRows(ptr); err != nil {
+		return nil, err
+	}
+	NormalizeInput(ptr)
+	context.shadowWrite(object, nil)
+	context.stampTenant(ptr)
+	return ptr, nil
+}
+
+// Bind parses the request into the struct ptr points to: the body, when there is one, then the fields tagged
+// `param:"name"` from the URL parameters and the fields tagged `query:"name"` from the query string, converted to
+// the type of the field. Fields of types implementing encoding.TextUnmarshaler are supported.
+//
+//	type ApproveRequest struct {
+//		ID     uint64 `param:"id"`
+//		Notify bool   `query:"notify"`
+//		Note   string `json:"note"`
+//	}
+func (context *Context) Bind(ptr interface{}) error {
+	if len(context.Request.Body()) > 0 {
+		if err := context.ParseBody(ptr); err != nil {
+			return err
+		}
+	}
+	return bindParams(ptr, func(name string) string {
+		return context.Request.Param(name).String()
+	}, func(name string) string {
+		return context.Request.Query(name).String()
+	})
+}
+
+// bindParams sets the fields of the struct tagged param and query to the values returned by param and query.
+// Empty values leave the fields as they are.
+func bindParams(ptr interface{}, param func(string) string, query func(string) string) error {
+	var v = reflect.Indirect(reflect.ValueOf(ptr))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("bind requires a pointer to a struct")
+	}
+	for i := 0; i < v.NumField(); i++ {
+		var field = v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		var value string
+		if name := field.Tag.Get("param"); name != "" {
+			value = param(name)
+		} else if name := field.Tag.Get("query"); name != "" {
+			value = query(name)
+		}
+		if value == "" {
+			continue
+		}
+		if err := setParam(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// setParam sets the value to the text, converted to the type of the value.
+func setParam(value reflect.Value, text string) error {
+	if u, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+	if value.Kind() == reflect.Ptr {
+		var elem = reflect.New(value.Type().Elem())
+		if err := setParam(elem.Elem(), text); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+type customParams struct {
+	ID      uint64     `param:"id"`
+	Notify  bool       `query:"notify"`
+	Ratio   float64    `query:"ratio"`
+	Limit   *int       `query:"limit"`
+	Since   time.Time  `query:"since"`
+	Note    string     `json:"note"`
+	Skipped string     `query:"skipped"`
+	Level   int8       `query:"level"`
+	hidden  string     `query:"hidden"`
+	Until   *time.Time `query:"until"`
+}
+
+func TestBindParams(t *testing.T) {
+	var values = map[string]string{
+		"id":     "42",
+		"notify": "true",
+		"ratio":  "0.5",
+		"limit":  "10",
+		"since":  "2024-05-01T10:00:00Z",
+		"hidden": "x",
+	}
+	var lookup = func(name string) string { return values[name] }
+	var p = customParams{Note: "kept", Skipped: "default"}
+	if err := bindParams(&p, lookup, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 42 || !p.Notify || p.Ratio != 0.5 || p.Limit == nil || *p.Limit != 10 || p.Note != "kept" ||
+		p.Skipped != "default" || p.hidden != "" || p.Until != nil || !p.Since.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("bindParams = %+v", p)
+	}
+
+	var tests = []map[string]string{
+		{"id": "-1"},
+		{"notify": "maybe"},
+		{"level": "300"},
+		{"since": "yesterday"},
+	}
+	for _, test := range tests {
+		var lookup = func(name string) string { return test[name] }
+		if err := bindParams(&customParams{}, lookup, lookup); err == nil {
+			t.Errorf("bindParams(%v) succeeded", test)
+		}
+	}
+	if err := bindParams(&values, lookup, lookup); err == nil {
+		t.Errorf("bindParams of a map succeeded")
+	}
+}
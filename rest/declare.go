@@ -0,0 +1,316 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/getevo/evo/v2/lib/db/types"
+	"github.com/iancoleman/strcase"
+	"gopkg.in/yaml.v3"
+)
+
+// Declaration describes a resource declared in a YAML or JSON file instead of a Go struct.
+// Declared resources get the same endpoints as struct based models:
+//
+//	resources:
+//	  - table: country
+//	    permission: COUNTRY
+//	    features: [disable_delete]
+//	    limits: {max_page_size: 500}
+//	    quotas:
+//	      - {requests: 60}
+//	      - {permission: EXPORT, limits: {max_export_rows: 5000000}}
+//	    fields:
+//	      - {name: code, type: string, size: 2, primary: true}
+//	      - {name: name, type: string, size: 128, index: true}
+//	      - {name: population, type: int, nullable: true}
+//
+// A uint `id` auto increment primary key is added when no field is primary.
+// Permission is the acl app key of the resource, the upper cased table when empty.
+// Flag is the key of the feature flag the resource is served behind, see FlagResolver.
+// Naming is the naming of the keys of the rows, snake or camel, see NamingStrategy.
+type Declaration struct {
+	Table       string             `json:"table"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Permission  string             `json:"permission"`
+	Features    []string           `json:"features"`
+	Limits      Limits             `json:"limits"`
+	Quotas      []Quota            `json:"quotas"`
+	Flag        string             `json:"flag"`
+	Naming      NamingStrategy     `json:"naming"`
+	Fields      []DeclarationField `json:"fields"`
+}
+
+// DeclarationField describes a column of a declared resource.
+// Type is one of string, text, int, uint, float, bool, time, date and json.
+// UI holds the settings of the form input of the field, as in the ui tag of struct fields, see uiTag.
+type DeclarationField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	Primary  bool   `json:"primary"`
+	Nullable bool   `json:"nullable"`
+	Index    bool   `json:"index"`
+	Unique   bool   `json:"unique"`
+	Default  string `json:"default"`
+	UI       string `json:"ui"`
+}
+
+var declarations []Declaration
+
+// declaredTables maps the tables of the declared resources to their declaration.
+var declaredTables = map[string]*Declaration{}
+
+var identifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var declarationTypes = map[string]reflect.Type{
+	"string": reflect.TypeOf(""),
+	"text":   reflect.TypeOf(""),
+	"int":    reflect.TypeOf(int64(0)),
+	"uint":   reflect.TypeOf(uint64(0)),
+	"float":  reflect.TypeOf(float64(0)),
+	"bool":   reflect.TypeOf(false),
+	"time":   reflect.TypeOf(time.Time{}),
+	"date":   reflect.TypeOf(time.Time{}),
+	"json":   reflect.TypeOf(types.JSON{}),
+}
+
+var declarationFeatures = map[string]reflect.Type{
+	"disable_create":   reflect.TypeOf(DisableCreate{}),
+	"disable_update":   reflect.TypeOf(DisableUpdate{}),
+	"disable_delete":   reflect.TypeOf(DisableDelete{}),
+	"disable_view":     reflect.TypeOf(DisableView{}),
+	"enable_set_api":   reflect.TypeOf(EnableSetAPI{}),
+	"obfuscate_id":     reflect.TypeOf(ObfuscateID{}),
+	"jsonapi":          reflect.TypeOf(JSONAPI{}),
+	"require_approval": reflect.TypeOf(RequireApproval{}),
+	"primary_reads":    reflect.TypeOf(PrimaryReads{}),
+}
+
+// Declare adds resources to be registered by the rest app. It must be called before the rest app is registered.
+func Declare(items ...Declaration) error {
+	for _, item := range items {
+		if _, err := item.Type(); err != nil {
+			return err
+		}
+	}
+	declarations = append(declarations, items...)
+	return nil
+}
+
+// LoadDeclarations reads the resources of a YAML (.yml, .yaml) or JSON file and declares them.
+// The file holds a `resources` list of declarations.
+func LoadDeclarations(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	items, err := ParseDeclarations(b, filepath.Ext(path) != ".json")
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return Declare(items...)
+}
+
+// ParseDeclarations decodes the resources of a YAML or JSON document.
+// YAML is converted to JSON first, so both formats use the json keys of Declaration.
+func ParseDeclarations(b []byte, isYAML bool) ([]Declaration, error) {
+	if isYAML {
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		var err error
+		if b, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	var file struct {
+		Resources []Declaration `json:"resources"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	return file.Resources, nil
+}
+
+// Type returns the struct type of the declared resource.
+// The type carries the table in the tag of a blank Declaration field: reflect.StructOf returns the same type for
+// the same fields, so declarations sharing their fields would share their gorm schema and resource otherwise.
+func (d *Declaration) Type() (reflect.Type, error) {
+	if !identifierRegex.MatchString(d.Table) {
+		return nil, fmt.Errorf("invalid resource table %q", d.Table)
+	}
+	if len(d.Fields) == 0 {
+		return nil, fmt.Errorf("resource %s has no fields", d.Table)
+	}
+	var fields = []reflect.StructField{{
+		Name: "Declaration",
+		Type: reflect.TypeOf(struct{}{}),
+		Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"-" json:"-" table:"%s"`, d.Table)),
+	}}
+	var primary = false
+	var seen = map[string]bool{"declaration": true}
+	for _, field := range d.Fields {
+		primary = primary || field.Primary
+	}
+	if !primary {
+		fields = append(fields, reflect.StructField{
+			Name: "ID",
+			Type: reflect.TypeOf(uint64(0)),
+			Tag:  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`,
+		})
+		seen["id"] = true
+	}
+	for _, field := range d.Fields {
+		f, err := field.structField(d.Table)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(field.Name)] {
+			return nil, fmt.Errorf("resource %s: duplicate field %s", d.Table, field.Name)
+		}
+		seen[strings.ToLower(field.Name)] = true
+		fields = append(fields, f)
+	}
+	for _, feature := range d.Features {
+		t, ok := declarationFeatures[strings.ToLower(feature)]
+		if !ok {
+			return nil, fmt.Errorf("resource %s: unknown feature %s", d.Table, feature)
+		}
+		fields = append(fields, reflect.StructField{Name: t.Name(), Type: t, Anonymous: true, Tag: `json:"-"`})
+	}
+	return reflect.StructOf(fields), nil
+}
+
+func (f DeclarationField) structField(table string) (reflect.StructField, error) {
+	if !identifierRegex.MatchString(f.Name) {
+		return reflect.StructField{}, fmt.Errorf("resource %s: invalid field name %q", table, f.Name)
+	}
+	var kind = strings.ToLower(f.Type)
+	if kind == "" {
+		kind = "string"
+	}
+	t, ok := declarationTypes[kind]
+	if !ok {
+		return reflect.StructField{}, fmt.Errorf("resource %s: field %s has unknown type %s", table, f.Name, f.Type)
+	}
+	var tag = []string{"column:" + f.Name}
+	switch kind {
+	case "string":
+		var size = f.Size
+		if size == 0 {
+			size = 255
+		}
+		tag = append(tag, "size:"+strconv.Itoa(size))
+	case "text", "date", "json":
+		tag = append(tag, "type:"+kind)
+	}
+	if f.Primary {
+		tag = append(tag, "primaryKey")
+		if kind == "int" || kind == "uint" {
+			tag = append(tag, "autoIncrement")
+		}
+	}
+	if f.Index {
+		tag = append(tag, "index")
+	}
+	if f.Unique {
+		tag = append(tag, "unique")
+	}
+	if strings.ContainsAny(f.Default, ";\"`") {
+		return reflect.StructField{}, fmt.Errorf("resource %s: field %s has an invalid default", table, f.Name)
+	}
+	if f.Default != "" {
+		tag = append(tag, "default:"+f.Default)
+	}
+	if strings.ContainsAny(f.UI, "\"`") {
+		return reflect.StructField{}, fmt.Errorf("resource %s: field %s has invalid ui settings", table, f.Name)
+	}
+	if f.Nullable && !f.Primary {
+		t = reflect.PointerTo(t)
+	}
+	return reflect.StructField{
+		Name: strcase.ToCamel(f.Name),
+		Type: t,
+		Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"%s" json:"%s" ui:"%s"`, strings.Join(tag, ";"), f.Name, f.UI)),
+	}, nil
+}
+
+// useDeclarations registers the models of the declared resources.
+// Generated types have no name, so the table name is set on their cached gorm schema and the
+// evo model is named after the table.
+func useDeclarations() error {
+	for idx := range declarations {
+		var d = &declarations[idx]
+		t, err := d.Type()
+		if err != nil {
+			return err
+		}
+		var sample = reflect.New(t).Elem().Interface()
+		var stmt = db.Model(sample).Statement
+		if err := stmt.Parse(sample); err != nil {
+			return fmt.Errorf("resource %s: %w", d.Table, err)
+		}
+		stmt.Schema.Table = d.Table
+		stmt.Schema.Name = strcase.ToCamel(d.Table)
+		EnableAPI(sample)
+		db.UseModel(sample)
+		for i := range schema.Models {
+			if schema.Models[i].Type == t {
+				schema.Models[i].Name = "declared." + stmt.Schema.Name
+				schema.Models[i].Package = "declared"
+			}
+		}
+		declaredTables[d.Table] = d
+	}
+	return nil
+}
+
+// declaredTable returns the table of a type generated by Declaration.Type, empty for the other types.
+func declaredTable(t reflect.Type) string {
+	if t.Kind() != reflect.Struct || t.NumField() == 0 || t.Field(0).Name != "Declaration" {
+		return ""
+	}
+	return t.Field(0).Tag.Get("table")
+}
+
+// applyDeclaration sets the limits and permissions of a declared resource.
+func applyDeclaration(resource *Resource) {
+	var d, ok = declaredTables[declaredTable(resource.Object.Type())]
+	if !ok {
+		return
+	}
+	resource.Feature.Limits = resource.Feature.Limits.Override(d.Limits)
+	resource.Feature.Quotas = d.Quotas
+	resource.Feature.Flag = d.Flag
+	if d.Naming != NamingTags {
+		resource.Feature.Naming = d.Naming
+	}
+	var permission = d.Permission
+	if permission == "" {
+		permission = d.Table
+	}
+	var name = d.Name
+	if name == "" {
+		name = d.Table
+	}
+	// SetPermission looks resources up by type name
+	resources.set(resource.Object.Type().String(), resource)
+	SetPermission(&AppPermission{
+		App:         strings.ToUpper(permission),
+		Name:        name,
+		Description: d.Description,
+		Objects:     []interface{}{resource.Object.Interface()},
+	})
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestParseDeclarations(t *testing.T) {
+	var src = `
+resources:
+  - table: country
+    permission: COUNTRY
+    features: [disable_delete]
+    limits: {max_page_size: 500}
+    fields:
+      - {name: code, type: string, size: 2, primary: true}
+      - {name: name, size: 128, index: true}
+      - {name: population, type: int, nullable: true}
+`
+	items, err := ParseDeclarations([]byte(src), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Table != "country" || items[0].Limits.MaxPageSize != 500 || len(items[0].Fields) != 3 {
+		t.Fatalf("unexpected declarations %+v", items)
+	}
+
+	typ, err := items[0].Type()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := schema.Parse(reflect.New(typ).Interface(), &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.PrimaryFields) != 1 || s.PrimaryFields[0].DBName != "code" {
+		t.Errorf("primary fields = %v", s.PrimaryFieldDBNames)
+	}
+	if f := s.LookUpField("population"); f == nil || f.FieldType.Kind() != reflect.Ptr {
+		t.Errorf("population should be a nullable field")
+	}
+	if f := s.LookUpField("name"); f == nil || f.Size != 128 {
+		t.Errorf("name should have size 128")
+	}
+	if !GetFeatures(reflect.New(typ).Elem().Interface()).DisableDelete {
+		t.Errorf("delete should be disabled")
+	}
+}
+
+func TestDeclarationType(t *testing.T) {
+	tests := []struct {
+		name        string
+		declaration Declaration
+		err         bool
+		primary     string
+	}{
+		{name: "implicit id", declaration: Declaration{Table: "note", Fields: []DeclarationField{{Name: "body", Type: "text"}}}, primary: "id"},
+		{name: "invalid table", declaration: Declaration{Table: "no-te", Fields: []DeclarationField{{Name: "body"}}}, err: true},
+		{name: "no fields", declaration: Declaration{Table: "note"}, err: true},
+		{name: "unknown type", declaration: Declaration{Table: "note", Fields: []DeclarationField{{Name: "body", Type: "blob"}}}, err: true},
+		{name: "duplicate id", declaration: Declaration{Table: "note", Fields: []DeclarationField{{Name: "id", Type: "int"}}}, err: true},
+		{name: "unknown feature", declaration: Declaration{Table: "note", Features: []string{"fly"}, Fields: []DeclarationField{{Name: "body"}}}, err: true},
+		{name: "invalid default", declaration: Declaration{Table: "note", Fields: []DeclarationField{{Name: "body", Default: `x;primaryKey`}}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := tt.declaration.Type()
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			s, err := schema.Parse(reflect.New(typ).Interface(), &sync.Map{}, schema.NamingStrategy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.PrioritizedPrimaryField == nil || s.PrioritizedPrimaryField.DBName != tt.primary {
+				t.Errorf("primary = %v, want %s", s.PrimaryFieldDBNames, tt.primary)
+			}
+		})
+	}
+}
+
+func TestDeclarationTypeDistinct(t *testing.T) {
+	var fields = []DeclarationField{{Name: "name"}}
+	country, err := (&Declaration{Table: "country", Fields: fields}).Type()
+	if err != nil {
+		t.Fatal(err)
+	}
+	region, err := (&Declaration{Table: "region", Fields: fields}).Type()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if country == region {
+		t.Fatalf("expected declarations of distinct tables to have distinct types")
+	}
+	if table := declaredTable(region); table != "region" {
+		t.Errorf("declared table = %q, want region", table)
+	}
+	if table := declaredTable(reflect.TypeOf(Segment{})); table != "" {
+		t.Errorf("declared table of a struct model = %q, want none", table)
+	}
+}
+
+func TestApplyDeclarationPermission(t *testing.T) {
+	var d = Declaration{Table: "planet", Fields: []DeclarationField{{Name: "name"}}}
+	typ, err := d.Type()
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaredTables[d.Table] = &d
+	var resource = &Resource{Object: reflect.New(typ).Elem(), Table: d.Table, Feature: &Feature{}}
+	t.Cleanup(func() {
+		delete(declaredTables, d.Table)
+		resources.remove(resource)
+	})
+	applyDeclaration(resource)
+	if !resource.Feature.CheckPermission || resource.Permissions.App != "PLANET" {
+		t.Errorf("expected the permission of the table to be required, got %+v", resource.Permissions)
+	}
+}
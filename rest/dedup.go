@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/generic"
+)
+
+// DuplicateError represents an error indicating that an identical object has just been created by the same user.
+type DuplicateError struct {
+	Location string
+}
+
+func (e DuplicateError) Error() string {
+	if e.Location == "" {
+		return "duplicate request, object being created"
+	}
+	return "duplicate request, object already created at " + e.Location
+}
+
+type dedupEntry struct {
+	location string
+	expires  time.Time
+}
+
+var dedup = struct {
+	sync.Mutex
+	entries map[string]dedupEntry
+}{entries: map[string]dedupEntry{}}
+
+// contentHash returns the hash of the normalized object together with the user and tenant of the request.
+func (context *Context) contentHash(ptr interface{}) string {
+	b, _ := json.Marshal(ptr)
+	var h = sha256.New()
+	h.Write([]byte(context.Action.Resource.Table + "\x00" + context.User().UUID() + "\x00" + context.Tenant() + "\x00"))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkDuplicate returns a DuplicateError and sets the 409 status if the same content was created, or is being
+// created, within the dedup window. Otherwise it reserves the hash, so concurrent duplicates are rejected, until
+// rememberCreate records the created object or forgetCreate releases it.
+func (context *Context) checkDuplicate(hash string) error {
+	var now = time.Now()
+	dedup.Lock()
+	entry, ok := dedup.entries[hash]
+	if !ok || now.After(entry.expires) {
+		for key, entry := range dedup.entries {
+			if now.After(entry.expires) {
+				delete(dedup.entries, key)
+			}
+		}
+		dedup.entries[hash] = dedupEntry{expires: now.Add(context.Action.Resource.Feature.DedupWindow)}
+		dedup.Unlock()
+		return nil
+	}
+	dedup.Unlock()
+	context.SetStatus(http.StatusConflict)
+	if entry.location != "" {
+		context.Request.SetHeader("Location", entry.location)
+	}
+	return DuplicateError{Location: entry.location}
+}
+
+// rememberCreate records the location of the object created for the hash reserved by checkDuplicate.
+func (context *Context) rememberCreate(hash string, ptr interface{}) {
+	var location = context.ObjectURL(ptr)
+	dedup.Lock()
+	dedup.entries[hash] = dedupEntry{
+		location: location,
+		expires:  time.Now().Add(context.Action.Resource.Feature.DedupWindow),
+	}
+	dedup.Unlock()
+}
+
+// forgetCreate releases the hash reserved by checkDuplicate when the object could not be created.
+func forgetCreate(hash string) {
+	dedup.Lock()
+	if entry, ok := dedup.entries[hash]; ok && entry.location == "" {
+		delete(dedup.entries, hash)
+	}
+	dedup.Unlock()
+}
+
+// ObjectURL returns the URL of the GET endpoint of the given object.
+func (context *Context) ObjectURL(ptr interface{}) string {
+	var res = context.Action.Resource
+	var url = "/" + strings.Trim(PREFIX+"/rest/"+res.Path, "/")
+	for _, field := range res.Schema.PrimaryFields {
+		var v = getValueByFieldName(ptr, field.Name)
+		if res.Feature.ObfuscateID {
+			url += "/" + res.IDEncoder().Encode(generic.Parse(v).Uint64())
+		} else {
+			url += "/" + fmt.Sprint(v)
+		}
+	}
+	return url
+}
@@ -0,0 +1,47 @@
+package rest
+
+import (
+	stdcontext "context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DisconnectPollInterval is the interval the connection of a request is checked at while its queries run, so
+// the queries of the clients which went away are canceled.
+var DisconnectPollInterval = 100 * time.Millisecond
+
+// withDisconnect returns the query bound to a context canceled when the client of the request disconnects or
+// the server shuts down, and the function releasing the context once the queries are done.
+func (context *Context) withDisconnect(query *gorm.DB) (*gorm.DB, stdcontext.CancelFunc) {
+	ctx, cancel := stdcontext.WithCancel(query.Statement.Context)
+	if context.Request == nil || context.Request.Context == nil {
+		return query.WithContext(ctx), cancel
+	}
+	var request = context.Request.Context.Context()
+	var conn = request.Conn()
+	var log = context.Logger()
+	go func() {
+		var ticker = time.NewTicker(DisconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-request.Done():
+				cancel()
+				return
+			case <-ticker.C:
+				gone, ok := clientGone(conn)
+				if gone {
+					log.Info("client disconnected, canceling the queries of the request")
+					cancel()
+				}
+				if gone || !ok {
+					return
+				}
+			}
+		}
+	}()
+	return query.WithContext(ctx), cancel
+}
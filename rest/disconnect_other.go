@@ -0,0 +1,10 @@
+//go:build !unix
+
+package rest
+
+import "net"
+
+// clientGone reports that the connection can not be checked on this platform.
+func clientGone(conn net.Conn) (gone bool, ok bool) {
+	return false, false
+}
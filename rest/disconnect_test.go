@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientGone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("unable to listen:", err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if gone, ok := clientGone(server); !ok {
+		t.Skip("connections can not be checked on this platform")
+	} else if gone {
+		t.Fatal("clientGone() = true for an open connection")
+	}
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if gone, _ := clientGone(server); gone {
+		t.Fatal("clientGone() = true for a connection with pending data")
+	}
+	var buf = make([]byte, 16)
+	if n, _ := server.Read(buf); n != 16 {
+		t.Fatalf("clientGone() consumed the pending data, read %d bytes", n)
+	}
+	client.Close()
+	time.Sleep(10 * time.Millisecond)
+	if gone, _ := clientGone(server); !gone {
+		t.Fatal("clientGone() = false for a closed connection")
+	}
+	if gone, ok := clientGone(&net.TCPConn{}); gone || ok {
+		t.Errorf("clientGone() = %v, %v for a connection without descriptor", gone, ok)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package rest
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// clientGone reports whether the client closed the connection, peeking it without consuming the pipelined
+// requests, and whether the connection can be checked at all.
+func clientGone(conn net.Conn) (gone bool, ok bool) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+	var buf = make([]byte, 1)
+	var n int
+	var peekErr error
+	err = raw.Read(func(fd uintptr) bool {
+		n, _, peekErr = syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		// done whatever the outcome, the poller must not wait for the connection to be readable
+		return true
+	})
+	switch {
+	case err != nil:
+		return true, true
+	case errors.Is(peekErr, syscall.EAGAIN), errors.Is(peekErr, syscall.EWOULDBLOCK), errors.Is(peekErr, syscall.EINTR):
+		return false, true
+	case peekErr != nil:
+		return true, true
+	}
+	return n == 0, true
+}
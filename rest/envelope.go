@@ -0,0 +1,60 @@
+package rest
+
+import "strconv"
+
+// Envelope wraps the responses of the endpoints of a resource. Resources choose it by implementing
+// RESTEnvelope() Envelope on the model, DefaultEnvelope otherwise, and requests with ?envelope=false get
+// RawEnvelope. Envelopes may set the status and the headers of the response through the context.
+type Envelope interface {
+	Wrap(context *Context) interface{}
+}
+
+// EnvelopeFunc adapts a function to the Envelope interface.
+type EnvelopeFunc func(context *Context) interface{}
+
+// Wrap returns f(context).
+func (f EnvelopeFunc) Wrap(context *Context) interface{} {
+	return f(context)
+}
+
+// PaginationEnvelope serves the data along with the pagination and the outcome of the request, see Pagination.
+type PaginationEnvelope struct{}
+
+// Wrap returns the Pagination of the context.
+func (PaginationEnvelope) Wrap(context *Context) interface{} {
+	return context.GetResponse()
+}
+
+// RawEnvelope serves the data alone, e.g. the array of rows of the list endpoints. The total number of rows
+// and of pages are sent in the X-Total-Count and X-Total-Pages headers, or whether a next page exists in the
+// X-Has-Next header when the rows are not counted, and errors as {"error": message} with an error status.
+type RawEnvelope struct{}
+
+// Wrap returns the data of the context, or its error.
+func (RawEnvelope) Wrap(context *Context) interface{} {
+	if !context.Response.Success {
+		context.SetStatus(context.errorStatus())
+		return map[string]string{"error": context.Response.Error}
+	}
+	if context.Response.HasNext != nil {
+		context.Request.SetHeader("X-Has-Next", strconv.FormatBool(*context.Response.HasNext))
+		return context.Response.Data
+	}
+	context.Request.SetHeader("X-Total-Count", strconv.FormatInt(context.Response.Total, 10))
+	context.Request.SetHeader("X-Total-Pages", strconv.Itoa(context.Response.TotalPages))
+	return context.Response.Data
+}
+
+// DefaultEnvelope is the envelope of the resources which do not choose one.
+var DefaultEnvelope Envelope = PaginationEnvelope{}
+
+// envelope returns the envelope of the response of the request.
+func (context *Context) envelope() Envelope {
+	if q := context.Request.Query("envelope").String(); q != "" && !context.Request.Query("envelope").Bool() {
+		return RawEnvelope{}
+	}
+	if context.Action.Resource != nil && context.Action.Resource.Feature.Envelope != nil {
+		return context.Action.Resource.Feature.Envelope
+	}
+	return DefaultEnvelope
+}
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	var tests = []struct {
+		err    error
+		status int
+		want   int
+	}{
+		{ErrorObjectNotExist, 0, http.StatusNotFound},
+		{ErrorNotFound, 0, http.StatusNotFound},
+		{ErrorUnauthorized, 0, http.StatusUnauthorized},
+		{ErrorPermissionDenied, 0, http.StatusForbidden},
+		{errors.New("invalid"), 0, http.StatusBadRequest},
+		{ErrorRateLimited, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		{ErrorObjectNotExist, http.StatusAccepted, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		var context = &Context{err: test.err, status: test.status}
+		if got := context.errorStatus(); got != test.want {
+			t.Errorf("errorStatus(%v, %d) = %d, want %d", test.err, test.status, got, test.want)
+		}
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	var tests = []struct {
+		name     string
+		envelope Envelope
+		response Pagination
+		want     string
+	}{
+		{"pagination", PaginationEnvelope{}, Pagination{Success: true, Data: []int{1, 2}, Total: 2, TotalPages: 1, Page: 1, Size: 10},
+			`{"total":2,"offset":0,"total_pages":1,"current_page":1,"size":10,"data":[1,2],"success":true,"error":"","type":"","filter_view":null,"partial":false}`},
+		{"pagination error", PaginationEnvelope{}, Pagination{Success: false, Data: []int{1}, Total: 1, Error: "failed"},
+			`{"total":0,"offset":0,"total_pages":0,"current_page":0,"size":0,"data":0,"success":false,"error":"failed","type":"","filter_view":null,"partial":false}`},
+		{"func", EnvelopeFunc(func(context *Context) interface{} {
+			return map[string]interface{}{"items": context.Response.Data}
+		}), Pagination{Success: true, Data: []int{1, 2}}, `{"items":[1,2]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response = tt.response
+			b, err := json.Marshal(tt.envelope.Wrap(&Context{Response: &response}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("Wrap() encoded %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
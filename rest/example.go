@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/schema"
+)
+
+// Example is a sample request body and response data of an endpoint, returned by ModelInfo.
+type Example struct {
+	Request  interface{} `json:"request,omitempty"`
+	Response interface{} `json:"response,omitempty"`
+}
+
+// exampleStrings holds sample values of string columns by a word of their name.
+var exampleStrings = []struct {
+	word  string
+	value string
+}{
+	{"email", "jane.doe@example.com"},
+	{"phone", "+39 06 1234567"},
+	{"mobile", "+39 333 1234567"},
+	{"url", "https://example.com"},
+	{"website", "https://example.com"},
+	{"uuid", "2f1b6c1e-8d7a-4c3e-9b1a-5e6f7a8b9c0d"},
+	{"first_name", "Jane"},
+	{"last_name", "Doe"},
+	{"name", "Jane Doe"},
+	{"title", "Quarterly report"},
+	{"description", "A short description"},
+	{"city", "Rome"},
+	{"country", "IT"},
+	{"address", "Via del Corso 1"},
+	{"zip", "00186"},
+	{"currency", "EUR"},
+	{"lang", "en"},
+	{"locale", "en"},
+	{"status", "active"},
+	{"code", "ABC123"},
+}
+
+// exampleValue returns a sample value of the field.
+func exampleValue(field *schema.Field) interface{} {
+	var typ = field.FieldType
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "2024-01-15T09:30:00Z"
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		var name = strings.ToLower(field.DBName)
+		for _, item := range exampleStrings {
+			if name == item.word || strings.HasSuffix(name, "_"+item.word) || strings.HasPrefix(name, item.word+"_") {
+				return item.value
+			}
+		}
+		return "text"
+	case reflect.Bool:
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 1
+	case reflect.Float32, reflect.Float64:
+		return 9.99
+	case reflect.Slice, reflect.Array:
+		return []interface{}{}
+	}
+	return nil
+}
+
+// exampleObject returns a sample object of the schema keyed by the json names of its columns.
+// The primary key and the timestamps set by gorm are left out of the input samples.
+func exampleObject(s *schema.Schema, input bool) map[string]interface{} {
+	var object = map[string]interface{}{}
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		if input && (field.PrimaryKey || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0) {
+			continue
+		}
+		object[jsonName(field.Tag.Get("json"), field.Name)] = exampleValue(field)
+	}
+	return object
+}
+
+// exampleKey returns the key of an endpoint name, matching the names of the endpoints however they are spelled.
+func exampleKey(name string) string {
+	return strings.ToUpper(strcase.ToCamel(name))
+}
+
+// defaultExamples returns the examples generated from the schema for the standard endpoints.
+func defaultExamples(s *schema.Schema) map[string]Example {
+	var input, output = exampleObject(s, true), exampleObject(s, false)
+	var partial = map[string]interface{}{}
+	// the first string column of the schema, for the example to be the same on every call
+	for _, field := range s.Fields {
+		var key = jsonName(field.Tag.Get("json"), field.Name)
+		if _, ok := input[key].(string); ok && field.DBName != "" {
+			partial[key] = input[key]
+			break
+		}
+	}
+	return map[string]Example{
+		"CREATE":         {Request: input, Response: output},
+		"UPDATE":         {Request: input, Response: output},
+		"GET":            {Response: output},
+		"ALL":            {Response: []interface{}{output}},
+		"PAGINATE":       {Response: []interface{}{output}},
+		"QUERYBYEXAMPLE": {Request: partial, Response: []interface{}{output}},
+	}
+}
+
+// examples returns the examples of the endpoints of the resource: those of the RESTExamples() map[string]Example
+// method of the model, keyed by endpoint name, or else generated from the schema.
+func (context *Context) examples() map[string]Example {
+	var result = map[string]Example{}
+	var generated = defaultExamples(context.Schema)
+	var declared = map[string]Example{}
+	if obj, ok := context.Object.Interface().(interface{ RESTExamples() map[string]Example }); ok {
+		for name, example := range obj.RESTExamples() {
+			declared[exampleKey(name)] = example
+		}
+	}
+	for _, action := range context.Action.Resource.Actions {
+		var key = exampleKey(action.Name)
+		if example, ok := declared[key]; ok {
+			result[action.Name] = example
+		} else if example, ok := generated[key]; ok {
+			result[action.Name] = example
+		}
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+type exampleContact struct {
+	ID        uint64    `gorm:"column:id;primaryKey" json:"id"`
+	FirstName string    `gorm:"column:first_name" json:"first_name"`
+	Email     string    `gorm:"column:email" json:"email"`
+	Score     float64   `gorm:"column:score" json:"score"`
+	Active    bool      `gorm:"column:active" json:"active"`
+	Secret    string    `gorm:"column:secret" json:"-"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func TestExampleObject(t *testing.T) {
+	s, err := schema.Parse(&exampleContact{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name  string
+		input bool
+		want  map[string]interface{}
+	}{
+		{"input", true, map[string]interface{}{
+			"first_name": "Jane", "email": "jane.doe@example.com", "score": 9.99, "active": true,
+		}},
+		{"output", false, map[string]interface{}{
+			"id": 1, "first_name": "Jane", "email": "jane.doe@example.com", "score": 9.99, "active": true,
+			"created_at": "2024-01-15T09:30:00Z",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exampleObject(s, tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exampleObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExampleKey(t *testing.T) {
+	for _, name := range []string{"QUERY BY EXAMPLE", "query-by-example", "QueryByExample"} {
+		if got := exampleKey(name); got != "QUERYBYEXAMPLE" {
+			t.Errorf("exampleKey(%q) = %q", name, got)
+		}
+	}
+}
+
+func TestDefaultExamples(t *testing.T) {
+	s, err := schema.Parse(&exampleContact{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if got := defaultExamples(s)["QUERYBYEXAMPLE"].Request; !reflect.DeepEqual(got, map[string]interface{}{"first_name": "Jane"}) {
+			t.Fatalf("query by example request = %v, want the first string column", got)
+		}
+	}
+}
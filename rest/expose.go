@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"sync"
+
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/i18n"
+	"github.com/iesitalia/toolbox/settings"
+)
+
+var apiModels sync.Map
+
+// EnableAPI enables the rest endpoints of models which cannot embed the API marker,
+// such as models of packages imported by rest. It must be called before the rest app is registered.
+func EnableAPI(objects ...interface{}) {
+	for _, object := range objects {
+		apiModels.LoadOrStore(getObject(object).Type().String(), "")
+	}
+}
+
+// EnableAdminAPI enables the rest endpoints of the models like EnableAPI, restricted to the users holding
+// acl.Admin whatever the permissions of the resources, see Feature.Permission.
+func EnableAdminAPI(objects ...interface{}) {
+	for _, object := range objects {
+		apiModels.Store(getObject(object).Type().String(), acl.Admin)
+	}
+}
+
+func init() {
+	// the acl tables grant the permissions, so only administrators may change or even read them
+	EnableAdminAPI(acl.Role{}, acl.RolePermission{}, acl.UserRole{}, acl.Group{}, acl.GroupRole{}, acl.UserGroup{})
+	EnableAPI(settings.Setting{}, i18n.Override{})
+}
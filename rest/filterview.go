@@ -5,12 +5,18 @@ import (
 	"github.com/getevo/evo/v2"
 	"github.com/getevo/evo/v2/lib/db"
 	scm "github.com/getevo/evo/v2/lib/db/schema"
-	"github.com/getevo/evo/v2/lib/tpl"
+	"github.com/getevo/evo/v2/lib/generic"
 	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/money"
 	"github.com/iesitalia/toolbox/query"
+	"github.com/iesitalia/toolbox/templates"
+	"github.com/iesitalia/toolbox/tracing"
 	"gorm.io/gorm/schema"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Join represents a join operation in a database query.
@@ -37,26 +43,46 @@ type FilterView struct {
 	URLParams   []Filter           `json:"url_params"`
 	Filters     []Filter           `json:"filters,omitempty" json:"filters,omitempty"`
 	Columns     []FilterViewColumn `json:"columns,omitempty" json:"columns,omitempty"`
+	Density     string             `json:"density,omitempty"`
+	GroupRowsBy *RowGroup          `json:"group_rows_by,omitempty"`
+	RowStyles   []RowStyle         `json:"-"`
+	Rows        []RowMeta          `json:"rows,omitempty"`
 }
 
 // FilterViewColumn represents a column in a filter view. It has properties such as title, href, type, processor, sort, options, dbField, and actions.
 // - Title: The title of the column.
-// - Href: The href of the column.
+// - Href: The href of the column, rendered with the values of the row. The primary key of the row is pk, the
+// values of a composite key separated by a slash as in the rest routes.
 // - Type: The type of the column.
 // - Processor: A function that processes the data of the column.
 // - Sort: A flag indicating whether the column can be sorted.
-// - Options: The list of options for the column.
+// - Options: The list of options for the column. Values are returned as their label, the list maps them back to the
+// stored values used for sorting and filtering.
 // - DBField: The database field of the column.
 // - Actions: The list of actions for the column.
+// - Key: The key of the column in the user preferences, see ColumnKey.
+// - Width, Hidden: The layout of the column set from the user preferences.
+// - Editable, Validation: The column can be updated inline using the field update endpoint, see UpdateField.
+// - Computed: The key of a field computed by the model, see Computed, read in place of DBField. Computed columns
+// can not be sorted.
+// - Currency: The currency of a column of type money. When empty the currency is read from the column next to
+// DBField named with the currency suffix, e.g. total_currency for total_amount, as stored by money.Money.
 type FilterViewColumn struct {
-	Title     string                                   `json:"title,omitempty"`
-	Href      string                                   `json:"href,omitempty"`
-	Type      string                                   `json:"type"`
-	Processor func(data map[string]interface{}) string `json:"-"`
-	Sort      bool                                     `json:"sort"`
-	Options   toolbox.Dictionary[string]               `json:"list,omitempty"`
-	DBField   string                                   `json:"-"`
-	Actions   []Action                                 `json:"-"`
+	Title      string                                   `json:"title,omitempty"`
+	Href       string                                   `json:"href,omitempty"`
+	Type       string                                   `json:"type"`
+	Processor  func(data map[string]interface{}) string `json:"-"`
+	Sort       bool                                     `json:"sort"`
+	Options    toolbox.Dictionary[string]               `json:"list,omitempty"`
+	DBField    string                                   `json:"-"`
+	Actions    []Action                                 `json:"-"`
+	Key        string                                   `json:"key"`
+	Width      int                                      `json:"width,omitempty"`
+	Hidden     bool                                     `json:"hidden,omitempty"`
+	Editable   bool                                     `json:"editable,omitempty"`
+	Validation *ColumnValidation                        `json:"validation,omitempty"`
+	Currency   string                                   `json:"currency,omitempty"`
+	Computed   string                                   `json:"-"`
 }
 
 // Filter represents a filter for data retrieval.
@@ -64,7 +90,8 @@ type FilterViewColumn struct {
 //
 // - Title: the title of the filter.
 // - Type: the type of the filter.
-// - Options: dictionary of options for the filter.
+// - Options: dictionary of options for the filter. Labels given as value, or as items of a comma separated list,
+// are replaced by their key. Filters without options use the options of the column with the same name.
 // - Name: the name of the filter.
 // - Filter: the filter condition to be applied.
 type Filter struct {
@@ -84,17 +111,68 @@ type Action struct {
 	Icon    string `json:"icon,omitempty"`
 }
 
+// ViewParams are the parameters of a FilterView query: the query parameters filtering and sorting the rows,
+// the url parameters of the view and the locale the values are formatted for.
+type ViewParams interface {
+	Query(name string) string
+	Param(name string) string
+	Locale() string
+}
+
+// requestParams reads the parameters of a FilterView query from the request.
+type requestParams struct {
+	request *evo.Request
+}
+
+func (p requestParams) Query(name string) string { return p.request.Query(name).String() }
+func (p requestParams) Param(name string) string { return p.request.Param(name).String() }
+func (p requestParams) Locale() string           { return requestLocale(p.request) }
+
+// ViewValues are the parameters of a FilterView query given outside of a request, e.g. by scheduled reports.
+// Url parameters are read from the same values, and the locale from the language value.
+type ViewValues url.Values
+
+// Query returns the value of the query parameter.
+func (v ViewValues) Query(name string) string { return url.Values(v).Get(name) }
+
+// Param returns the value of the url parameter.
+func (v ViewValues) Param(name string) string { return url.Values(v).Get(name) }
+
+// Locale returns the language value.
+func (v ViewValues) Locale() string { return url.Values(v).Get("language") }
+
 // GetData retrieves data from the FilterView based on the given offset, size, and request parameters. It returns an error if the model or the queries are invalid, the total number of
 func (v *FilterView) GetData(offset int, size int, request *evo.Request) (error, int64, [][]interface{}) {
+	return v.Data(offset, size, requestParams{request})
+}
+
+// Data retrieves the rows of the FilterView like GetData, reading the parameters from params.
+func (v *FilterView) Data(offset int, size int, params ViewParams) (error, int64, [][]interface{}) {
+	var start = time.Now()
+	var span *tracing.Span
+	if p, ok := params.(requestParams); ok && p.request != nil {
+		span = tracing.FromRequest(p.request).Child("filterview")
+	}
+	defer func() {
+		var table string
+		if v.Model != nil {
+			table = v.Model.TableName()
+		}
+		filterViewDuration.ObserveSpan(time.Since(start).Seconds(), span, table)
+		span.Finish(nil, "table", table)
+	}()
 	var query = query.Query{}
 	for _, item := range v.Columns {
 		if item.DBField == "-" || item.DBField == "" {
 			continue
 		}
 		query.Select(item.DBField)
+		if item.Type == "money" && item.Currency == "" {
+			query.Select(currencyField(item.DBField))
+		}
 	}
 
-	var order = request.Query("sort").String()
+	var order = params.Query("sort")
 	if order != "" {
 		valid := true
 		for _, item := range strings.Split(order, ",") {
@@ -108,14 +186,14 @@ func (v *FilterView) GetData(offset int, size int, request *evo.Request) (error,
 		}
 	}
 
-	if v.Model == nil {
-		return fmt.Errorf("invalid model %s", reflect.TypeOf(v.Model).Name()), 0, nil
+	m, err := v.model()
+	if err != nil {
+		return err, 0, nil
 	}
-	m := scm.Find(v.Model.TableName())
-	if m == nil {
-		return fmt.Errorf("invalid model %s", reflect.TypeOf(v.Model).Name()), 0, nil
+	var keys = keyAliases(m)
+	for i, alias := range keys {
+		query.Select(m.Table+"."+m.PrimaryKey[i], alias)
 	}
-	query.Select(m.Table+"."+m.PrimaryKey[0], "pk")
 	for _, item := range v.Select {
 		if item.As != "" {
 			query.Select(item.Select, item.As)
@@ -123,34 +201,40 @@ func (v *FilterView) GetData(offset int, size int, request *evo.Request) (error,
 			query.Select(item.Select)
 		}
 	}
-
-	query.From(v.Model.TableName())
-	for _, item := range v.Join {
-		query.From(item.Table)
-		if item.Condition != "" {
-			query.Where(item.Condition)
-		}
-	}
-
-	for _, item := range v.URLParams {
-		query.Where(strings.Replace(item.Filter, "*", request.Param(item.Name).String(), -1))
+	v.selectRowStyles(&query)
+	if err := v.applyConditions(&query, params); err != nil {
+		return err, 0, nil
 	}
 
 	query.Limit(fmt.Sprint(size))
 	query.Offset(fmt.Sprint(offset))
-	for _, item := range v.Filters {
-		if request.Query(item.Name).String() != "" {
-			query.Where(strings.Replace(item.Filter, "*", request.Query(item.Name).String(), -1))
-		}
-	}
 
 	var total int64
-	db.Raw(query.GetCountQuery()).Scan(&total)
-
 	var data []map[string]interface{}
-	db.Debug().Raw(query.GetQuery()).Scan(&data)
+	var dbo, reads = v.readDB(m.Name)
+	dbo.Raw(query.GetCountQuery()).Scan(&total)
+	dbo.Raw(query.GetQuery()).Scan(&data)
+	if reads != nil && reads.failed.Load() {
+		replicaFallbacks.Inc(m.Table)
+		total, data = 0, nil
+		db.Raw(query.GetCountQuery()).Scan(&total)
+		db.Raw(query.GetQuery()).Scan(&data)
+	}
+	if len(keys) > 1 {
+		for _, row := range data {
+			row["pk"] = rowKey(row, keys)
+		}
+	}
+	if err := v.computeColumns(m, data, params); err != nil {
+		return err, 0, nil
+	}
 
 	var result = make([][]interface{}, len(data))
+	var locale = params.Locale()
+	v.Rows = nil
+	if len(v.RowStyles) > 0 {
+		v.Rows = make([]RowMeta, len(data))
+	}
 
 	for j, row := range data {
 		var item = make([]interface{}, len(v.Columns))
@@ -160,8 +244,8 @@ func (v *FilterView) GetData(offset int, size int, request *evo.Request) (error,
 				for _, action := range column.Actions {
 					buttons = append(buttons, Action{
 						Type:    action.Type,
-						Href:    tpl.Render(action.Href, row),
-						OnClick: tpl.Render(action.OnClick, row),
+						Href:    templates.Render(action.Href, row),
+						OnClick: templates.Render(action.OnClick, row),
 						Text:    action.Text,
 						Icon:    action.Icon,
 					})
@@ -169,23 +253,177 @@ func (v *FilterView) GetData(offset int, size int, request *evo.Request) (error,
 				item[i] = buttons
 				continue
 			}
-			if column.Processor == nil {
-				item[i] = fmt.Sprint(row[column.DBField])
+			if column.Processor == nil && column.Type == "money" {
+				item[i] = column.formatMoney(row, locale)
+			} else if column.Processor == nil && len(column.Options) > 0 {
+				item[i] = column.label(row[column.key()])
+			} else if column.Processor == nil {
+				item[i] = fmt.Sprint(row[column.key()])
 
 			} else {
 				item[i] = column.Processor(row)
 			}
 			if column.Href != "" {
-				item[i] = "<a href=\"" + tpl.Render(column.Href, row) + "\">" + fmt.Sprint(item[i]) + "</a>"
+				item[i] = "<a href=\"" + templates.Render(column.Href, row) + "\">" + fmt.Sprint(item[i]) + "</a>"
 			}
 
 		}
 		result[j] = item
+		if v.Rows != nil {
+			v.Rows[j] = v.rowMeta(row)
+		}
 	}
 
 	return nil, total, result
 }
 
+// keyAliases returns the names the primary key columns are selected as in the rows of the view: pk for a single
+// key, pk_ followed by the column for each column of a composite key.
+func keyAliases(m *scm.Model) []string {
+	if len(m.PrimaryKey) == 1 {
+		return []string{"pk"}
+	}
+	var aliases = make([]string, len(m.PrimaryKey))
+	for i, column := range m.PrimaryKey {
+		aliases[i] = "pk_" + column
+	}
+	return aliases
+}
+
+// rowKey returns the primary key of the row in the form used by the rest routes, the values of the key columns
+// separated by a slash.
+func rowKey(row map[string]interface{}, aliases []string) string {
+	var values = make([]string, len(aliases))
+	for i, alias := range aliases {
+		values[i] = keyString(row[alias])
+	}
+	return strings.Join(values, "/")
+}
+
+// FindFilterView returns the filter view of the resource with the given table.
+func FindFilterView(table string) (*FilterView, error) {
+	for _, resource := range resources.all() {
+		if resource.Table != table {
+			continue
+		}
+		if obj, ok := resource.Object.Interface().(interface{ FilterView() FilterView }); ok {
+			var fv = obj.FilterView()
+			return &fv, nil
+		}
+		return nil, fmt.Errorf("resource %s has no filter view", table)
+	}
+	return nil, fmt.Errorf("resource %s: %w", table, ErrorObjectNotExist)
+}
+
+// model returns the schema of the model of the view.
+func (v *FilterView) model() (*scm.Model, error) {
+	if v.Model == nil {
+		return nil, fmt.Errorf("invalid model %s", reflect.TypeOf(v.Model).Name())
+	}
+	m := scm.Find(v.Model.TableName())
+	if m == nil {
+		return nil, fmt.Errorf("invalid model %s", reflect.TypeOf(v.Model).Name())
+	}
+	return m, nil
+}
+
+// applyConditions adds the tables, joins, url params and filters of the view to the query.
+// Requests expanding a group of GroupRowsBy are limited to the rows of the group.
+func (v *FilterView) applyConditions(query *query.Query, params ViewParams) error {
+	query.From(v.Model.TableName())
+	for _, item := range v.Join {
+		query.From(item.Table)
+		if item.Condition != "" {
+			query.Where(item.Condition)
+		}
+	}
+
+	for _, item := range v.URLParams {
+		query.Where(strings.Replace(item.Filter, "*", params.Param(item.Name), -1))
+	}
+
+	for _, item := range v.Filters {
+		if value := params.Query(item.Name); value != "" {
+			query.Where(strings.Replace(item.Filter, "*", v.optionKeys(item, value), -1))
+		}
+	}
+	if v.GroupRowsBy != nil && isGroupRequest(params) {
+		condition, err := v.GroupRowsBy.condition(params.Query("group"), generic.Parse(params.Query("group_null")).Bool())
+		if err != nil {
+			return err
+		}
+		query.Where(condition)
+	}
+	return nil
+}
+
+// label returns the label of the stored value, or the value if it is not an option.
+func (c *FilterViewColumn) label(value interface{}) string {
+	var key = fmt.Sprint(value)
+	if b, ok := value.([]byte); ok {
+		key = string(b)
+	}
+	if label, ok := c.Options.Get(key); ok {
+		return label
+	}
+	return key
+}
+
+// optionKeys replaces the labels in the value of the filter by their key.
+func (v *FilterView) optionKeys(filter Filter, value string) string {
+	var options = filter.Options
+	if len(options) == 0 {
+		for _, column := range v.Columns {
+			if column.DBField == filter.Name {
+				options = column.Options
+				break
+			}
+		}
+	}
+	if len(options) == 0 {
+		return value
+	}
+	var items = strings.Split(value, ",")
+	for i, item := range items {
+		if options.Has(item) {
+			continue
+		}
+		for _, option := range options {
+			if strings.EqualFold(option.Value, strings.TrimSpace(item)) {
+				items[i] = option.Key
+				break
+			}
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// currencyField returns the currency column stored next to the amount column of a money.Money.
+func currencyField(field string) string {
+	if strings.HasSuffix(field, "amount") {
+		return strings.TrimSuffix(field, "amount") + "currency"
+	}
+	return field + "_currency"
+}
+
+// formatMoney returns the amount of the row written for the locale, or the raw value if it is not a valid amount.
+func (c *FilterViewColumn) formatMoney(row map[string]interface{}, locale string) string {
+	var value = fmt.Sprint(row[c.key()])
+	var amount, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
+	}
+	var currency = c.Currency
+	if currency == "" {
+		currency = fmt.Sprint(row[currencyField(c.DBField)])
+	}
+	var m = money.New(amount, currency)
+	if m.Validate() != nil {
+		return value
+	}
+	return m.Format(locale)
+}
+
 // SetSelect adds the given Select to the FilterView's Select field
 func (v *FilterView) SetSelect(s Select) {
 	var skip = false
@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+
+	scm "github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/iesitalia/toolbox"
+)
+
+func TestFilterViewOptions(t *testing.T) {
+	var status = toolbox.Dictionary[string]{{Key: "1", Value: "Open"}, {Key: "2", Value: "Closed"}}
+	var view = FilterView{Columns: []FilterViewColumn{{DBField: "status", Options: status}}}
+
+	var labels = []struct {
+		value interface{}
+		want  string
+	}{
+		{int64(1), "Open"},
+		{[]byte("2"), "Closed"},
+		{"3", "3"},
+	}
+	for _, tt := range labels {
+		if got := view.Columns[0].label(tt.value); got != tt.want {
+			t.Errorf("label(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	var filters = []struct {
+		name   string
+		filter Filter
+		value  string
+		want   string
+	}{
+		{"key", Filter{Name: "status"}, "1", "1"},
+		{"label of column", Filter{Name: "status"}, "closed", "2"},
+		{"in list", Filter{Name: "status"}, "Open,2, closed", "1,2,2"},
+		{"unknown label", Filter{Name: "status"}, "Pending", "Pending"},
+		{"filter options", Filter{Name: "state", Options: toolbox.Dictionary[string]{{Key: "a", Value: "Active"}}}, "active", "a"},
+		{"no options", Filter{Name: "name"}, "Open", "Open"},
+	}
+	for _, tt := range filters {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := view.optionKeys(tt.filter, tt.value); got != tt.want {
+				t.Errorf("optionKeys(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyAliases(t *testing.T) {
+	var single = keyAliases(&scm.Model{PrimaryKey: []string{"id"}})
+	if !reflect.DeepEqual(single, []string{"pk"}) {
+		t.Errorf("keyAliases() of a single key = %v", single)
+	}
+	var composite = keyAliases(&scm.Model{PrimaryKey: []string{"order_id", "line"}})
+	if !reflect.DeepEqual(composite, []string{"pk_order_id", "pk_line"}) {
+		t.Errorf("keyAliases() of a composite key = %v", composite)
+	}
+	var row = map[string]interface{}{"pk_order_id": int64(7), "pk_line": []byte("2")}
+	if key := rowKey(row, composite); key != "7/2" {
+		t.Errorf("rowKey() = %q, want 7/2", key)
+	}
+}
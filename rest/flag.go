@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorNotFound is returned by the resources and endpoints gated by a feature flag which is off for the caller.
+var ErrorNotFound = errors.New("not found")
+
+// FlagResolver reports whether the feature flag is on for the user and tenant of the context.
+// It is set by the flags App; resources and endpoints gated by a flag are not served while it is nil.
+var FlagResolver func(context *Context, key string) bool
+
+// flagged reports whether the endpoint of the context is served to the caller. Resources are gated by
+// implementing RESTFlag() string on the model, single endpoints by setting the Flag of the Endpoint.
+func (context *Context) flagged() bool {
+	for _, key := range []string{context.Action.Resource.Feature.Flag, context.Action.Flag} {
+		if key != "" && (FlagResolver == nil || !FlagResolver(context, key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFlag answers 404 to the callers the flag of the endpoint is off for.
+func (context *Context) checkFlag() error {
+	if !context.flagged() {
+		context.SetStatus(http.StatusNotFound)
+		return ErrorNotFound
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package rest
+
+import "testing"
+
+func TestFlagged(t *testing.T) {
+	defer func(resolver func(context *Context, key string) bool) { FlagResolver = resolver }(FlagResolver)
+	var on = func(context *Context, key string) bool { return key == "beta" }
+	var tests = []struct {
+		name     string
+		resolver func(context *Context, key string) bool
+		resource string
+		endpoint string
+		want     bool
+	}{
+		{"not gated", nil, "", "", true},
+		{"no resolver", nil, "beta", "", false},
+		{"resource on", on, "beta", "", true},
+		{"resource off", on, "alpha", "", false},
+		{"endpoint on", on, "", "beta", true},
+		{"endpoint off", on, "beta", "alpha", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FlagResolver = tt.resolver
+			var context = &Context{Action: &Endpoint{Flag: tt.endpoint, Resource: &Resource{Feature: &Feature{Flag: tt.resource}}}}
+			if got := context.flagged(); got != tt.want {
+				t.Errorf("flagged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
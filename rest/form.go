@@ -0,0 +1,226 @@
+package rest
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iesitalia/toolbox"
+	"gorm.io/gorm/schema"
+)
+
+// LabelColumns are the columns displayed by association pickers for the rows of the referenced resource, the
+// first one the resource has, unless the relation field sets it by its ui tag, e.g. `ui:"label_field:email"`.
+var LabelColumns = []string{"name", "title", "label", "code", "email"}
+
+// FieldReference is the resource the values of an association picker are taken from.
+// - Resource, URL: the table of the referenced resource and the base URL of its endpoints.
+// - Key: the column of the referenced resource stored by the field.
+// - Label: the column of the referenced resource displayed by the picker.
+// - Many: the field holds a list of rows.
+type FieldReference struct {
+	Resource string `json:"resource"`
+	URL      string `json:"url"`
+	Key      string `json:"key"`
+	Label    string `json:"label,omitempty"`
+	Many     bool   `json:"many,omitempty"`
+}
+
+var enumRegex = regexp.MustCompile(`(?i)^\s*enum\s*\((.*)\)\s*$`)
+
+// uiTag returns the settings of the ui tag of the field, in the format of the gorm tag:
+//
+//	Status string `gorm:"column:status;size:16" ui:"widget:radio;group:Workflow;options:draft=Draft,live=Published"`
+//
+// Settings are label, widget, group, placeholder, help, options, min, max, pattern, label_field, hidden and readonly.
+func uiTag(field *schema.Field) map[string]string {
+	return schema.ParseTagSetting(field.Tag.Get("ui"), ";")
+}
+
+// parseOptions returns the options given as a comma separated list of values, or of value=label pairs.
+func parseOptions(list string) toolbox.Dictionary[string] {
+	var options toolbox.Dictionary[string]
+	for _, item := range strings.Split(list, ",") {
+		var key, label, found = strings.Cut(strings.TrimSpace(item), "=")
+		if key == "" {
+			continue
+		}
+		if !found {
+			label = key
+		}
+		options = append(options, toolbox.KeyValue[string]{Key: key, Value: label})
+	}
+	return options
+}
+
+// enumOptions returns the values of an enum column type, e.g. enum('draft','live').
+func enumOptions(typ string) toolbox.Dictionary[string] {
+	var match = enumRegex.FindStringSubmatch(typ)
+	if match == nil {
+		return nil
+	}
+	var options toolbox.Dictionary[string]
+	for _, item := range strings.Split(match[1], ",") {
+		var value = strings.Trim(strings.TrimSpace(item), `'"`)
+		if value != "" {
+			options = append(options, toolbox.KeyValue[string]{Key: value, Value: value})
+		}
+	}
+	return options
+}
+
+// fieldWidget returns the input of a column derived from its type.
+func fieldWidget(field *schema.Field) string {
+	var typ = strings.ToLower(field.TagSettings["TYPE"])
+	switch {
+	case typ == "date":
+		return "date"
+	case strings.Contains(typ, "text"):
+		return "textarea"
+	case strings.Contains(typ, "json") || field.DataType == "json":
+		return "json"
+	}
+	switch field.DataType {
+	case schema.Bool:
+		return "checkbox"
+	case schema.Int, schema.Uint, schema.Float:
+		return "number"
+	case schema.Time:
+		return "datetime"
+	case schema.Bytes:
+		return "file"
+	case schema.String:
+		if field.Size > 1024 {
+			return "textarea"
+		}
+	}
+	return "text"
+}
+
+// labelColumn returns the column of the schema displayed by association pickers.
+func labelColumn(s *schema.Schema) string {
+	for _, name := range LabelColumns {
+		if field := s.LookUpField(name); field != nil && field.DBName != "" {
+			return field.DBName
+		}
+	}
+	if len(s.PrimaryFields) > 0 {
+		return s.PrimaryFields[0].DBName
+	}
+	return ""
+}
+
+// reference returns the picker of the rows of the relation.
+func reference(relation *schema.Relationship) *FieldReference {
+	var target = relation.FieldSchema
+	var ref = &FieldReference{
+		Resource: target.Table,
+		URL:      "/" + strings.Trim(PREFIX+"/rest/"+target.Table, "/"),
+		Label:    uiTag(relation.Field)["LABEL_FIELD"],
+		Many:     relation.Type == schema.HasMany || relation.Type == schema.Many2Many,
+	}
+	if ref.Label == "" {
+		ref.Label = labelColumn(target)
+	}
+	for _, r := range relation.References {
+		if r.PrimaryKey != nil && relation.Type == schema.BelongsTo {
+			ref.Key = r.PrimaryKey.DBName
+		}
+	}
+	if ref.Key == "" && len(target.PrimaryFields) > 0 {
+		ref.Key = target.PrimaryFields[0].DBName
+	}
+	return ref
+}
+
+// formField returns the form metadata of a field of the schema. The widget is derived from the type of the
+// column unless the ui tag sets it, fields with options render as select and the foreign keys of belongs-to
+// relations as reference pickers. Relation fields render as reference pickers for many-to-many relations,
+// as lists for has-many relations, and are hidden otherwise since their foreign key is edited instead.
+func formField(s *schema.Schema, item *schema.Field) Field {
+	var ui = uiTag(item)
+	var field = Field{
+		Name:        item.Name,
+		FieldName:   item.Name,
+		DBName:      item.DBName,
+		Type:        item.FieldType.Name(),
+		Default:     item.DefaultValue,
+		PK:          item.PrimaryKey,
+		Key:         jsonName(item.Tag.Get("json"), item.Name),
+		Group:       ui["GROUP"],
+		Placeholder: ui["PLACEHOLDER"],
+		Help:        ui["HELP"],
+		Hidden:      ui["HIDDEN"] != "",
+		ReadOnly:    ui["READONLY"] != "" || (item.PrimaryKey && item.AutoIncrement) || item.AutoCreateTime > 0 || item.AutoUpdateTime > 0,
+	}
+	if label := ui["LABEL"]; label != "" {
+		field.Name = label
+	}
+	if item.DBName == "" {
+		if relation, ok := s.Relationships.Relations[item.Name]; ok {
+			switch relation.Type {
+			case schema.Many2Many:
+				field.Widget, field.Reference = "reference", reference(relation)
+			case schema.HasMany:
+				field.Widget, field.Reference = "list", reference(relation)
+			default:
+				field.Hidden = true
+			}
+		}
+		if ui["WIDGET"] != "" {
+			field.Widget = ui["WIDGET"]
+		}
+		return field
+	}
+
+	for _, rule := range strings.Split(item.Tag.Get("validation"), ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			field.Rules = append(field.Rules, rule)
+			field.Required = field.Required || rule == "required"
+		}
+	}
+	if item.NotNull && !item.HasDefaultValue && !item.PrimaryKey && item.DataType != schema.Bool {
+		field.Required = true
+	}
+
+	field.Widget = fieldWidget(item)
+	if options := ui["OPTIONS"]; options != "" {
+		field.Options = parseOptions(options)
+	} else {
+		field.Options = enumOptions(item.TagSettings["TYPE"])
+	}
+	if len(field.Options) > 0 {
+		field.Widget = "select"
+	}
+	for _, relation := range s.Relationships.Relations {
+		if relation.Type != schema.BelongsTo {
+			continue
+		}
+		for _, r := range relation.References {
+			if r.ForeignKey == item {
+				field.Widget, field.Reference = "reference", reference(relation)
+			}
+		}
+	}
+	if field.PK && item.AutoIncrement {
+		field.Widget = "hidden"
+	}
+	if ui["WIDGET"] != "" {
+		field.Widget = ui["WIDGET"]
+	}
+
+	var validation = ColumnValidation{Required: field.Required, Pattern: ui["PATTERN"]}
+	if item.DataType == schema.String {
+		validation.MaxLength = item.Size
+	}
+	if v, err := strconv.ParseFloat(ui["MIN"], 64); err == nil {
+		validation.Min = &v
+	}
+	if v, err := strconv.ParseFloat(ui["MAX"], 64); err == nil {
+		validation.Max = &v
+	}
+	if validation != (ColumnValidation{}) {
+		field.Validation = &validation
+	}
+	return field
+}
@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox"
+	"gorm.io/gorm/schema"
+)
+
+type formCategory struct {
+	ID    uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Title string `gorm:"column:title;size:64"`
+}
+
+type formLabel struct {
+	ID   uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Code string `gorm:"column:code;size:8"`
+}
+
+type formPost struct {
+	ID         uint          `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Title      string        `gorm:"column:title;size:128;not null" validation:"required" ui:"group:Content;placeholder:Title" json:"title"`
+	Body       string        `gorm:"column:body;type:text" ui:"group:Content" json:"body"`
+	Status     string        `gorm:"column:status;type:enum('draft','live')" json:"status"`
+	Kind       string        `gorm:"column:kind;size:16" ui:"options:a=Article,news;widget:radio" json:"kind"`
+	Rating     int           `gorm:"column:rating" ui:"min:1;max:5" json:"rating"`
+	Public     bool          `gorm:"column:public;not null" json:"public"`
+	PublishAt  time.Time     `gorm:"column:publish_at" json:"publish_at"`
+	CategoryID uint          `gorm:"column:category_id" json:"category_id"`
+	Category   *formCategory `json:"category"`
+	Labels     []formLabel   `gorm:"many2many:form_post_labels" json:"labels"`
+	CreatedAt  time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func TestFormField(t *testing.T) {
+	s, err := schema.Parse(&formPost{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields = map[string]Field{}
+	for _, item := range s.Fields {
+		fields[item.Name] = formField(s, item)
+	}
+	var five, one = 5.0, 1.0
+	var tests = []struct {
+		name  string
+		check func(f Field) bool
+	}{
+		{"ID", func(f Field) bool { return f.Widget == "hidden" && f.ReadOnly && f.PK }},
+		{"Title", func(f Field) bool {
+			return f.Widget == "text" && f.Required && f.Group == "Content" && f.Placeholder == "Title" &&
+				reflect.DeepEqual(f.Rules, []string{"required"}) && f.Validation.MaxLength == 128 && f.Key == "title"
+		}},
+		{"Body", func(f Field) bool { return f.Widget == "textarea" && !f.Required && f.Validation == nil }},
+		{"Status", func(f Field) bool {
+			return f.Widget == "select" && reflect.DeepEqual(f.Options, toolbox.Dictionary[string]{{Key: "draft", Value: "draft"}, {Key: "live", Value: "live"}})
+		}},
+		{"Kind", func(f Field) bool {
+			return f.Widget == "radio" && reflect.DeepEqual(f.Options, toolbox.Dictionary[string]{{Key: "a", Value: "Article"}, {Key: "news", Value: "news"}})
+		}},
+		{"Rating", func(f Field) bool {
+			return f.Widget == "number" && *f.Validation.Min == one && *f.Validation.Max == five
+		}},
+		{"Public", func(f Field) bool { return f.Widget == "checkbox" && !f.Required }},
+		{"PublishAt", func(f Field) bool { return f.Widget == "datetime" }},
+		{"CategoryID", func(f Field) bool {
+			return f.Widget == "reference" && reflect.DeepEqual(f.Reference, &FieldReference{Resource: "form_categories", URL: "/admin/rest/form_categories", Key: "id", Label: "title"})
+		}},
+		{"Category", func(f Field) bool { return f.Hidden }},
+		{"Labels", func(f Field) bool {
+			return f.Widget == "reference" && reflect.DeepEqual(f.Reference, &FieldReference{Resource: "form_labels", URL: "/admin/rest/form_labels", Key: "id", Label: "code", Many: true})
+		}},
+		{"CreatedAt", func(f Field) bool { return f.ReadOnly && f.Widget == "datetime" }},
+	}
+	for _, test := range tests {
+		if f, ok := fields[test.name]; !ok || !test.check(f) {
+			t.Errorf("formField(%s) = %+v, reference %+v", test.name, f, f.Reference)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	var tests = []struct {
+		list string
+		want toolbox.Dictionary[string]
+	}{
+		{"", nil},
+		{"a,b", toolbox.Dictionary[string]{{Key: "a", Value: "a"}, {Key: "b", Value: "b"}}},
+		{" s=Small , m=Medium,", toolbox.Dictionary[string]{{Key: "s", Value: "Small"}, {Key: "m", Value: "Medium"}}},
+	}
+	for _, test := range tests {
+		if got := parseOptions(test.list); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseOptions(%q) = %v, want %v", test.list, got, test.want)
+		}
+	}
+}
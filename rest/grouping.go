@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/query"
+)
+
+// RowGroup groups the rows of a FilterView by a field, e.g. the orders by customer:
+//
+//	GroupRowsBy: &rest.RowGroup{
+//		Field: "order.customer_id",
+//		Label: "customer.name",
+//		Aggregates: []rest.Aggregate{{Name: "total", Function: "sum", Field: "order.total_amount"}},
+//	}
+//
+// The view endpoint then returns a page of group header rows. The rows of a group are returned by a follow-up
+// call to the same endpoint with ?group=<key>, or ?group_null=1 for the rows without key.
+// - Field: the column the rows are grouped by.
+// - Label: the column shown as title of the group, the key is used when empty.
+// - Aggregates: the values calculated over the rows of each group.
+type RowGroup struct {
+	Field      string      `json:"field"`
+	Title      string      `json:"title,omitempty"`
+	Label      string      `json:"-"`
+	Aggregates []Aggregate `json:"aggregates,omitempty"`
+}
+
+// Aggregate is a value calculated over the rows of a group using one of sum, count, min, max and avg.
+type Aggregate struct {
+	Name     string `json:"name"`
+	Title    string `json:"title,omitempty"`
+	Function string `json:"function"`
+	Field    string `json:"-"`
+}
+
+// GroupRow is the header row of a group.
+// - Null: the rows of the group have no key.
+// - Count: the number of rows of the group.
+type GroupRow struct {
+	Key        interface{}            `json:"key"`
+	Null       bool                   `json:"null,omitempty"`
+	Label      string                 `json:"label"`
+	Count      int64                  `json:"count"`
+	Aggregates map[string]interface{} `json:"aggregates,omitempty"`
+}
+
+var groupFieldRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+(\.[a-zA-Z0-9_]+)?$`)
+
+var aggregateFunctions = map[string]bool{"SUM": true, "COUNT": true, "MIN": true, "MAX": true, "AVG": true}
+
+// quoteField returns the quoted column, or an error if it is not a column or table.column.
+func quoteField(field string) (string, error) {
+	if !groupFieldRegex.MatchString(field) {
+		return "", fmt.Errorf("invalid group field %q", field)
+	}
+	return "`" + strings.Replace(field, ".", "`.`", 1) + "`", nil
+}
+
+// sqlString returns the value quoted as a string literal.
+func sqlString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(value) + "'"
+}
+
+// condition returns the condition selecting the rows of the group with the given key.
+func (g *RowGroup) condition(key string, null bool) (string, error) {
+	field, err := quoteField(g.Field)
+	if err != nil {
+		return "", err
+	}
+	if null {
+		return field + " IS NULL", nil
+	}
+	return field + " = " + sqlString(key), nil
+}
+
+// expression returns the aggregate expression, e.g. SUM(`order`.`total`).
+func (a Aggregate) expression() (string, error) {
+	var function = strings.ToUpper(a.Function)
+	if !aggregateFunctions[function] {
+		return "", fmt.Errorf("invalid aggregate function %q", a.Function)
+	}
+	if !identifierRegex.MatchString(a.Name) {
+		return "", fmt.Errorf("invalid aggregate name %q", a.Name)
+	}
+	if a.Field == "" && function == "COUNT" {
+		return "COUNT(*)", nil
+	}
+	field, err := quoteField(a.Field)
+	if err != nil {
+		return "", err
+	}
+	return function + "(" + field + ")", nil
+}
+
+// isGroupRequest reports whether the request expands a group.
+func isGroupRequest(params ViewParams) bool {
+	return params.Query("group") != "" || generic.Parse(params.Query("group_null")).Bool()
+}
+
+// GetGroups returns a page of the group header rows of a view with GroupRowsBy, ordered by key,
+// along with the total number of groups.
+func (v *FilterView) GetGroups(offset int, size int, request *evo.Request) (error, int64, []GroupRow) {
+	if v.GroupRowsBy == nil {
+		return fmt.Errorf("filter view has no row grouping"), 0, nil
+	}
+	if _, err := v.model(); err != nil {
+		return err, 0, nil
+	}
+	var group = v.GroupRowsBy
+	field, err := quoteField(group.Field)
+	if err != nil {
+		return err, 0, nil
+	}
+	var q = query.Query{}
+	// parenthesized expressions are selected as is
+	q.Select("("+field+")", "group_key")
+	q.Select("(COUNT(*))", "group_count")
+	if group.Label != "" {
+		label, err := quoteField(group.Label)
+		if err != nil {
+			return err, 0, nil
+		}
+		q.Select("(MAX("+label+"))", "group_label")
+	}
+	for _, aggregate := range group.Aggregates {
+		expression, err := aggregate.expression()
+		if err != nil {
+			return err, 0, nil
+		}
+		q.Select("("+expression+")", "aggregate_"+aggregate.Name)
+	}
+	if err := v.applyConditions(&q, requestParams{request}); err != nil {
+		return err, 0, nil
+	}
+	q.GroupBy(field)
+	q.Order(group.Field + ".asc")
+
+	var total int64
+	db.Raw("SELECT COUNT(*) FROM (" + q.GetCountQuery() + ") AS `groups`").Scan(&total)
+
+	q.Limit(fmt.Sprint(size))
+	q.Offset(fmt.Sprint(offset))
+	var data []map[string]interface{}
+	if err := db.Raw(q.GetQuery()).Scan(&data).Error; err != nil {
+		return err, 0, nil
+	}
+	var result = make([]GroupRow, len(data))
+	for i, row := range data {
+		result[i] = group.row(row)
+	}
+	return nil, total, result
+}
+
+// row returns the header row of a group read from the database.
+func (g *RowGroup) row(data map[string]interface{}) GroupRow {
+	var row = GroupRow{
+		Key:   data["group_key"],
+		Null:  data["group_key"] == nil,
+		Count: toInt64(data["group_count"]),
+	}
+	if label, ok := data["group_label"]; ok && label != nil {
+		row.Label = toString(label)
+	} else if !row.Null {
+		row.Label = toString(row.Key)
+	}
+	if b, ok := row.Key.([]byte); ok {
+		row.Key = string(b)
+	}
+	if len(g.Aggregates) > 0 {
+		row.Aggregates = map[string]interface{}{}
+		for _, aggregate := range g.Aggregates {
+			var value = data["aggregate_"+aggregate.Name]
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			row.Aggregates[aggregate.Name] = value
+		}
+	}
+	return row
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func toInt64(v interface{}) int64 {
+	var i int64
+	fmt.Sscan(toString(v), &i)
+	return i
+}
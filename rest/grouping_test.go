@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"testing"
+)
+
+func TestRowGroupCondition(t *testing.T) {
+	var tests = []struct {
+		name  string
+		field string
+		key   string
+		null  bool
+		want  string
+		ok    bool
+	}{
+		{"column", "customer_id", "12", false, "`customer_id` = '12'", true},
+		{"table column", "order.customer_id", "12", false, "`order`.`customer_id` = '12'", true},
+		{"null", "order.customer_id", "", true, "`order`.`customer_id` IS NULL", true},
+		{"quoted key", "name", `o'brien\`, false, "`name` = 'o''brien\\\\'", true},
+		{"invalid field", "name; DROP TABLE x", "1", false, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var group = RowGroup{Field: tt.field}
+			got, err := group.condition(tt.key, tt.null)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("condition() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateExpression(t *testing.T) {
+	var tests = []struct {
+		aggregate Aggregate
+		want      string
+		ok        bool
+	}{
+		{Aggregate{Name: "total", Function: "sum", Field: "order.total_amount"}, "SUM(`order`.`total_amount`)", true},
+		{Aggregate{Name: "orders", Function: "count"}, "COUNT(*)", true},
+		{Aggregate{Name: "last", Function: "MAX", Field: "created_at"}, "MAX(`created_at`)", true},
+		{Aggregate{Name: "total", Function: "sum"}, "", false},
+		{Aggregate{Name: "x", Function: "group_concat", Field: "name"}, "", false},
+		{Aggregate{Name: "bad name", Function: "sum", Field: "amount"}, "", false},
+	}
+	for _, tt := range tests {
+		got, err := tt.aggregate.expression()
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("expression(%+v) = %q, %v, want %q", tt.aggregate, got, err, tt.want)
+		}
+	}
+}
+
+func TestRowGroupRow(t *testing.T) {
+	var group = RowGroup{Field: "customer_id", Label: "customer.name", Aggregates: []Aggregate{{Name: "total", Function: "sum", Field: "amount"}}}
+	var row = group.row(map[string]interface{}{
+		"group_key":       int64(7),
+		"group_count":     int64(3),
+		"group_label":     []byte("ACME"),
+		"aggregate_total": []byte("150.00"),
+	})
+	if row.Key != int64(7) || row.Null || row.Label != "ACME" || row.Count != 3 || row.Aggregates["total"] != "150.00" {
+		t.Errorf("row() = %+v", row)
+	}
+	row = (&RowGroup{Field: "customer_id"}).row(map[string]interface{}{"group_key": nil, "group_count": "2"})
+	if !row.Null || row.Label != "" || row.Count != 2 {
+		t.Errorf("row() of null key = %+v", row)
+	}
+}
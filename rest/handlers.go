@@ -1,12 +1,18 @@
 package rest
 
 import (
+	stdcontext "context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/getevo/evo/v2/lib/db"
-	"github.com/getevo/evo/v2/lib/generic"
 	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/metering"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // ErrorObjectNotExist represents an error indicating that the object does not exist.
@@ -19,42 +25,61 @@ var ErrorPermissionDenied = errors.New("permission denied")
 
 var ErrorUnauthorized = errors.New("unauthorized")
 
+// setKeys returns the fields of the schema tagged SET_KEY, which select the rows replaced by the SET endpoint.
+func setKeys(s *schema.Schema) []*schema.Field {
+	var keys []*schema.Field
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings["SET_KEY"]; ok && field.DBName != "" {
+			keys = append(keys, field)
+		}
+	}
+	return keys
+}
+
+// Set replaces the rows matching the SET_KEY fields given in the URL by the rows of the body, setting their
+// SET_KEY fields to the values of the URL. Models may tag several fields SET_KEY to replace the rows of a
+// composite key. The rows are checked by the create hooks before any row is replaced, see AddCreateHook.
 func Set(context *Context) error {
 	if err := context.HasPerm("UPDATE"); err != nil {
 		return err
 	}
-	//var dbo = context.GetDBO()
 	object := context.GetObject()
 
-	var key = ""
-	var fieldName = ""
-	for _, field := range context.Schema.Fields {
-		if _, ok := field.TagSettings["SET_KEY"]; ok {
-			key = field.DBName
-			fieldName = field.Name
-			break
-		}
+	var keys = setKeys(context.Schema)
+	if len(keys) == 0 {
+		return ErrorColumnNotExist
 	}
 
 	array := context.GetObjectSlice()
 	ptr := array.Addr()
-	err := context.Request.BodyParser(ptr.Interface())
-	if err != nil {
-		return err
-	}
-	var pk = context.Request.Param(key)
-	err = db.Debug().Where("`"+key+"` = ?", pk).Delete(object.Addr().Interface()).Error
+	err := context.parseRows(ptr.Interface())
 	if err != nil {
 		return err
 	}
 	for i := 0; i < array.Len(); i++ {
-		err := generic.Parse(pk).Cast(ptr.Elem().Index(i).FieldByName(fieldName).Addr().Interface())
-		if err != nil {
+		for _, field := range keys {
+			if err := setField(ptr.Elem().Index(i), field.Name, context.Request.Param(field.DBName).String()); err != nil {
+				return err
+			}
+		}
+		NormalizeInput(ptr.Elem().Index(i).Addr().Interface())
+		context.shadowWrite(ptr.Elem().Index(i), nil)
+		context.stampTenant(ptr.Elem().Index(i).Addr().Interface())
+		context.generateID(ptr.Elem().Index(i).Addr().Interface())
+		if err := context.beforeCreateHooks(ptr.Elem().Index(i).Addr().Interface()); err != nil {
 			return err
 		}
-
 	}
-	err = db.Create(ptr.Interface()).Error
+	var dbo = context.GetDBO()
+	var query = context.ApplyPolicies(dbo)
+	for _, field := range keys {
+		query = query.Where("`"+field.DBName+"` = ?", context.Request.Param(field.DBName).String())
+	}
+	err = query.Delete(object.Addr().Interface()).Error
+	if err != nil {
+		return err
+	}
+	err = dbo.Create(ptr.Interface()).Error
 	if err != nil {
 		return err
 	}
@@ -66,9 +91,12 @@ func Set(context *Context) error {
 // Create takes a Context as input and creates a new object.
 // It uses the context's Request and DBO to perform the creation.
 // The object to be created is retrieved from the context's Object field.
-// The object is parsed from the request's body using the BodyParser method.
+// The object is parsed from the request's body within the body limits of the resource, see Limits.
+// Objects implementing IDGenerator get their primary key generated, and the URL of the created object is returned
+// in the Location header.
 // The object can optionally implement the BeforeCreate method, which is called before the creation.
 // The object can optionally implement the ValidateCreate method, which is called to validate the object before creation.
+// The create hooks run next, see AddCreateHook.
 // The object is then created in the database using the DBO's Create method.
 // If the object implements the AfterCreate method, it is called after the creation.
 // The created object is set as the data in the context's Response field.
@@ -80,10 +108,28 @@ func Create(context *Context) error {
 	var dbo = context.GetDBO()
 	object := context.GetObject()
 	ptr := object.Addr().Interface()
-	err := context.Request.BodyParser(ptr)
+	err := context.parseRows(ptr)
 	if err != nil {
 		return err
 	}
+	NormalizeInput(ptr)
+	context.shadowWrite(object, nil)
+	context.stampTenant(ptr)
+
+	var hash string
+	var created bool
+	if context.Action.Resource.Feature.DedupWindow > 0 {
+		hash = context.contentHash(ptr)
+		if err := context.checkDuplicate(hash); err != nil {
+			return err
+		}
+		defer func() {
+			if !created {
+				forgetCreate(hash)
+			}
+		}()
+	}
+	var generated = context.generateID(ptr)
 
 	if obj, ok := ptr.(interface{ BeforeCreate(context *Context) error }); ok {
 		err := obj.BeforeCreate(context)
@@ -97,15 +143,25 @@ func Create(context *Context) error {
 			return err
 		}
 	}
+	if err := context.beforeCreateHooks(ptr); err != nil {
+		return err
+	}
 	if err := dbo.Create(ptr).Error; err != nil {
 		return err
 	}
+	created = true
+	if hash != "" {
+		context.rememberCreate(hash, ptr)
+	}
 
 	if obj, ok := ptr.(interface{ AfterCreate(context *Context) error }); ok {
 		if err := obj.AfterCreate(context); err != nil {
 			return err
 		}
 	}
+	if generated {
+		context.Request.SetHeader("Location", context.ObjectURL(ptr))
+	}
 	context.Response.Data = ptr
 	return nil
 }
@@ -115,13 +171,13 @@ func Create(context *Context) error {
 // parses the request body to update the object, and executes the updates
 // on the database. It also calls the BeforeUpdate and ValidateUpdate methods
 // if they are implemented by the object to perform any necessary operations
-// before and after the update. Finally, it sets the updated object as the response
-// data in the context.
+// before and after the update, along with the update hooks, see AddUpdateHook.
+// Finally, it sets the updated object as the response data in the context.
+// Updates of resources requiring approval are stored as pending changes instead, see RequireApproval.
 func Update(context *Context) error {
 	if err := context.HasPerm("UPDATE"); err != nil {
 		return err
 	}
-	var dbo = context.GetDBO()
 	object := context.GetObject()
 	ptr := object.Addr().Interface()
 	key, err := context.FindByPrimaryKey(ptr)
@@ -132,10 +188,31 @@ func Update(context *Context) error {
 	if !key {
 		return ErrorObjectNotExist
 	}
-	err = context.Request.BodyParser(ptr)
-	if err != nil {
+	if context.Action.Resource.Feature.RequireApproval {
+		if err := context.checkBody(false); err != nil {
+			return err
+		}
+		var body = []byte(context.Request.Body())
+		if b, ok := context.rowBody(); ok {
+			body = b
+		}
+		return context.requestApproval(ApprovalUpdate, object, body)
+	}
+	return context.update(object, context.parseRows)
+}
+
+// update applies the values decoded by parse to the object read from the database.
+func (context *Context) update(object reflect.Value, parse func(out interface{}) error) error {
+	var dbo = context.GetDBO()
+	ptr := object.Addr().Interface()
+	var snapshot = context.auditSnapshot(object)
+	var prev = previous(object)
+	if err := parse(ptr); err != nil {
 		return err
 	}
+	NormalizeInput(ptr)
+	context.shadowWrite(object, prev)
+	context.stampTenant(ptr)
 	if obj, ok := ptr.(interface{ BeforeUpdate(context *Context) error }); ok {
 		if err := obj.BeforeUpdate(context); err != nil {
 			return err
@@ -147,16 +224,27 @@ func Update(context *Context) error {
 			return err
 		}
 	}
+	if err := context.beforeUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
 	//evo.Dump(ptr)
-	if err := dbo.Debug().Omit(clause.Associations).Save(ptr).Error; err != nil {
+	if err := dbo.Omit(clause.Associations).Save(ptr).Error; err != nil {
 		return err
 	}
+	if audits := context.auditChanges(snapshot, object); len(audits) > 0 {
+		if err := db.Create(&audits).Error; err != nil {
+			context.Logger().Error("unable to record field audit", "error", err.Error())
+		}
+	}
 
 	if obj, ok := ptr.(interface{ AfterUpdate(context *Context) error }); ok {
 		if err := obj.AfterUpdate(context); err != nil {
 			return err
 		}
 	}
+	if err := context.afterUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
 	context.Response.Data = ptr
 
 	return nil
@@ -165,11 +253,11 @@ func Update(context *Context) error {
 // Delete deletes an object from the database.
 // It takes a Context pointer as a parameter.
 // It returns an error if an error occurs during the deletion process.
+// Deletions of resources requiring approval are stored as pending changes instead, see RequireApproval.
 func Delete(context *Context) error {
 	if err := context.HasPerm("DELETE"); err != nil {
 		return err
 	}
-	var dbo = context.GetDBO()
 	object := context.GetObject()
 	ptr := object.Addr().Interface()
 	key, err := context.FindByPrimaryKey(ptr)
@@ -179,6 +267,16 @@ func Delete(context *Context) error {
 	if !key {
 		return ErrorObjectNotExist
 	}
+	if context.Action.Resource.Feature.RequireApproval {
+		return context.requestApproval(ApprovalDelete, object, nil)
+	}
+	return context.delete(object)
+}
+
+// delete deletes the object read from the database, softly when the model supports it.
+func (context *Context) delete(object reflect.Value) error {
+	var dbo = context.GetDBO()
+	ptr := object.Addr().Interface()
 	if obj, ok := ptr.(interface{ BeforeDelete(context *Context) error }); ok {
 		if err := obj.BeforeDelete(context); err != nil {
 			return err
@@ -229,6 +327,7 @@ func Get(context *Context) error {
 	if !key {
 		return ErrorObjectNotExist
 	}
+	context.ReadRow(object)
 
 	if obj, ok := ptr.(interface{ AfterGet(context *Context) error }); ok {
 		if err := obj.AfterGet(context); err != nil {
@@ -243,13 +342,14 @@ func Get(context *Context) error {
 // All queries the database and retrieves all objects based on the given context.
 // It applies filters, handles before and after events, and sets the response.
 // It returns an error if any occurred during the process.
+// With ?stream=true the objects are written as newline-delimited JSON instead, see StreamNDJSON.
 func All(context *Context) error {
 	if err := context.HasPerm("VIEW"); err != nil {
 		return err
 	}
 	var dbo = context.GetDBO()
 
-	var slice = context.GetObjectSlice()
+	var slice = context.pooledSlice()
 	ptr := slice.Addr().Interface()
 	if obj, ok := context.GetObject().Addr().Interface().(interface{ BeforeGet(context *Context) error }); ok {
 		if err := obj.BeforeGet(context); err != nil {
@@ -262,12 +362,22 @@ func All(context *Context) error {
 	if err != nil {
 		return err
 	}
+	if context.Request.Query("stream").Bool() {
+		return context.StreamNDJSON(dbo)
+	}
 	if err := dbo.Find(ptr).Error; err != nil {
 		return err
 	}
+	if context.preloads.truncated(slice) {
+		context.Response.Partial = true
+	}
 	context.Response.Total = int64(slice.Len())
 	context.Response.Size = slice.Len()
+	metering.Record(context.Tenant(), metering.RowsExported, int64(slice.Len()))
 
+	for i := 0; i < slice.Len(); i++ {
+		context.ReadRow(slice.Index(i))
+	}
 	if _, ok := context.GetObject().Addr().Interface().(interface{ AfterGet(context *Context) error }); ok {
 		for i := 0; i < slice.Len(); i++ {
 			if obj, ok := slice.Index(i).Addr().Interface().(interface{ AfterGet(context *Context) error }); ok {
@@ -285,11 +395,73 @@ func All(context *Context) error {
 
 // Paginate applies pagination to a database query based on the context provided.
 // It modifies the context's response object with the paginated data.
+// The page size of requests preloading many or deep associations is reduced according to Limits.PreloadCost.
 func Paginate(context *Context) error {
+	return paginate(context, nil)
+}
+
+// QueryByExample paginates the rows matching every non-zero field of the partial object in the body,
+// e.g. {"status": "open", "customer_id": 7}, along with the filters of the query string.
+// With ?prefix=1 string fields match the rows starting with the given value.
+// Zero values such as false and 0 can not be matched by example, use the filters instead.
+func QueryByExample(context *Context) error {
+	var ptr = context.GetObject().Addr().Interface()
+	if err := context.parseRows(ptr); err != nil {
+		return err
+	}
+	where, args, err := exampleConditions(context.ctx(), context.Schema, reflect.ValueOf(ptr), context.Request.Query("prefix").Bool(), context.queryable)
+	if err != nil {
+		return err
+	}
+	return paginate(context, func(query *gorm.DB) *gorm.DB {
+		for i := range where {
+			query = query.Where(where[i], args[i])
+		}
+		return query
+	})
+}
+
+// exampleConditions returns a condition for each non-zero column of the object, matching string prefixes
+// when prefix is set. Columns which are not queryable are rejected with ErrorPermissionDenied.
+func exampleConditions(ctx stdcontext.Context, s *schema.Schema, value reflect.Value, prefix bool, queryable func(column string) bool) ([]string, []interface{}, error) {
+	var where []string
+	var args []interface{}
+	value = reflect.Indirect(value)
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		v, zero := field.ValueOf(ctx, value)
+		if zero {
+			continue
+		}
+		if !queryable(field.DBName) {
+			return nil, nil, ErrorPermissionDenied
+		}
+		var column = s.Table + "." + field.DBName
+		if str, ok := v.(string); ok && prefix {
+			where = append(where, column+` LIKE ?`)
+			args = append(args, likeEscaper.Replace(str)+"%")
+			continue
+		}
+		where = append(where, column+" = ?")
+		args = append(args, v)
+	}
+	return where, args, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ParallelCount runs the count of Paginate on its own connection while the page is read, instead of before it.
+var ParallelCount = true
+
+// paginate paginates the rows of the resource, restricted by where when it is not nil.
+// The queries are canceled when the client disconnects.
+func paginate(context *Context, where func(query *gorm.DB) *gorm.DB) error {
 	if err := context.HasPerm("VIEW"); err != nil {
 		return err
 	}
-	var slice = context.GetObjectSlice()
+	var slice = context.pooledSlice()
 
 	if obj, ok := context.GetObject().Addr().Interface().(interface{ BeforeGet(context *Context) error }); ok {
 		if err := obj.BeforeGet(context); err != nil {
@@ -299,25 +471,102 @@ func Paginate(context *Context) error {
 
 	ptr := slice.Addr().Interface()
 	var p toolbox.Pagination
-	p.SetLimit(context.Request.Query("size").Int())
+	var size = context.Request.Query("size").Int()
+	if size == 0 {
+		size = context.Setting("REST.PAGE_SIZE").Int()
+	}
+	p.Limit = context.Limits().PageSize(size)
+	var association, join = context.Request.Query("associations").String(), context.Request.Query("join").String()
+	if view, err := context.resolveView(); err == nil && view != nil {
+		association, join = strings.Join(view.Associations, ","), ""
+	}
+	var cost = preloadCost(association, join, context.Schema, context.Limits().PreloadDepth)
+	if limited, ok := context.Limits().PreloadPageSize(p.Limit, cost); ok {
+		p.Limit = limited
+		context.Response.PreloadLimit = limited
+	}
 	p.SetCurrentPage(context.Request.Query("page").Int())
 	context.Response.Size = p.Limit
 	context.Response.Offset = p.GetOffset()
 	context.Response.Page = p.CurrentPage
 
-	var query = db.Model(ptr)
+	var query = context.GetDBO().Model(ptr)
 	var err error
 	query, err = context.ApplyFilters(query)
 	if err != nil {
 		return err
 	}
-	query.Model(ptr).Count(&context.Response.Total)
-	p.Records = int(context.Response.Total)
-	p.SetPages()
-	context.Response.TotalPages = p.Pages
-	if err := query.Limit(p.Limit).Offset(p.GetOffset()).Find(ptr).Error; err != nil {
+	if where != nil {
+		query = where(query)
+	}
+	var budget = context.Limits().TimeBudget
+	var strategy = context.countStrategy()
+	var release stdcontext.CancelFunc
+	query, release = context.withDisconnect(query)
+	defer release()
+
+	// the count runs on its own session along with the page
+	var counted = make(chan func(), 1)
+	var limit = p.Limit
+	if strategy.Strategy == CountNone {
+		// one more row tells whether a next page exists
+		limit++
+		counted <- func() {}
+	} else {
+		var counter, exceeded, cancel = withBudget(query.Session(&gorm.Session{}), budget)
+		var run = func() {
+			defer cancel()
+			var total, estimated, _ = count(counter, context.GetObjectSlice().Addr().Interface(), strategy)
+			var partial = exceeded()
+			counted <- func() {
+				if partial {
+					context.Response.Partial = true
+					context.Response.Total = 0
+					return
+				}
+				context.Response.Total, context.Response.Estimated = total, estimated
+				p.Records = int(total)
+				p.SetPages()
+				context.Response.TotalPages = p.Pages
+				// SetPages counts one page when there are no rows
+				if total == 0 {
+					context.Response.TotalPages = 0
+				}
+			}
+		}
+		if ParallelCount {
+			go run()
+		} else {
+			run()
+		}
+	}
+
+	query = query.Limit(limit).Offset(p.GetOffset())
+	var find, exceeded, cancel = withBudget(query.Session(&gorm.Session{}), budget)
+	err = find.Find(ptr).Error
+	cancel()
+	if err != nil && exceeded() && len(query.Statement.Preloads) > 0 {
+		context.Response.Partial = true
+		err = withoutPreloads(query).Find(ptr).Error
+	}
+	(<-counted)()
+	if err != nil {
 		return err
 	}
+	if context.preloads.truncated(slice) {
+		context.Response.Partial = true
+	}
+	if strategy.Strategy == CountNone {
+		var next = slice.Len() > p.Limit
+		if next {
+			slice.SetLen(p.Limit)
+		}
+		context.Response.Total, context.Response.TotalPages = 0, 0
+		context.Response.HasNext = &next
+	}
+	for i := 0; i < slice.Len(); i++ {
+		context.ReadRow(slice.Index(i))
+	}
 	if _, ok := context.GetObject().Addr().Interface().(interface{ AfterGet(context *Context) error }); ok {
 		for i := 0; i < slice.Len(); i++ {
 			if obj, ok := slice.Index(i).Addr().Interface().(interface{ AfterGet(context *Context) error }); ok {
@@ -333,16 +582,27 @@ func Paginate(context *Context) error {
 }
 
 // FilterViewHandler filters the view based on the request parameters and updates the context.Response accordingly.
+// The saved preference of the user sets the column layout and the page size when the request has no size.
+// Views grouping their rows return the group header rows, see RowGroup.
 func FilterViewHandler(context *Context) error {
 	if err := context.HasPerm("VIEW"); err != nil {
 		return err
 	}
 	if obj, ok := context.Object.Interface().(interface{ FilterView() FilterView }); ok {
 		var fv = obj.FilterView()
+		var preference *ViewPreference
+		if user := context.User(); !user.Anonymous() {
+			preference = GetViewPreference(user.UUID(), context.Action.Resource.Table)
+		}
+		fv.ApplyPreference(preference)
+		fv.describeEditable(context.Schema)
 
 		context.Response.Offset = context.Request.Query("offset").Int()
 		context.Response.Page = context.Request.Query("page").Int()
 		context.Response.Size = context.Request.Query("size").Int()
+		if context.Response.Size == 0 && preference != nil {
+			context.Response.Size = preference.PageSize
+		}
 
 		if context.Response.Offset == 0 && context.Response.Page > 0 {
 			context.Response.Page = context.Response.Page - 1
@@ -358,7 +618,16 @@ func FilterViewHandler(context *Context) error {
 		if context.Response.Size == 0 {
 			context.Response.Size = 25
 		}
-		err, total, data := fv.GetData(context.Response.Offset, context.Response.Size, context.Request)
+		var total int64
+		var data interface{}
+		var err error
+		context.Response.Type = "filterview"
+		if fv.GroupRowsBy != nil && !isGroupRequest(requestParams{context.Request}) {
+			err, total, data = fv.GetGroups(context.Response.Offset, context.Response.Size, context.Request)
+			context.Response.Type = "filterview_groups"
+		} else {
+			err, total, data = fv.GetData(context.Response.Offset, context.Response.Size, context.Request)
+		}
 		if err != nil {
 			return err
 		}
@@ -369,7 +638,6 @@ func FilterViewHandler(context *Context) error {
 		}
 		context.Response.Data = data
 		context.Response.FilterView = &fv
-		context.Response.Type = "filterview"
 		return nil
 	}
 	return fmt.Errorf("invalid filterview handler")
@@ -382,14 +650,20 @@ func FilterViewHandler(context *Context) error {
 // - ID: The ID of the object
 // - Fields: An array of Field objects that represent the fields of the object
 // - Endpoints: An array of Endpoint objects that represent the endpoints associated with the object.
+// - Groups: The groups of the fields, in order of first appearance.
 type Info struct {
-	Name      string      `json:"name,omitempty"`
-	ID        string      `json:"id,omitempty"`
-	Fields    []Field     `json:"fields,omitempty"`
-	Endpoints []*Endpoint `json:"endpoints,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	ID        string             `json:"id,omitempty"`
+	Fields    []Field            `json:"fields,omitempty"`
+	Groups    []string           `json:"groups,omitempty"`
+	Endpoints []*Endpoint        `json:"endpoints,omitempty"`
+	Examples  map[string]Example `json:"examples,omitempty"`
 }
 
 // ModelInfo retrieves information about a model and populates the response data with the info.
+// Examples holds sample bodies of the endpoints, see Example.
+// Fields carry the metadata needed to render CRUD forms, see formField. Models give the options of
+// their fields by a RESTOptions() map[string]toolbox.Dictionary[string] method, keyed by column.
 func ModelInfo(context *Context) error {
 	if err := context.HasPerm("VIEW"); err != nil {
 		return err
@@ -399,16 +673,30 @@ func ModelInfo(context *Context) error {
 		ID:   context.Schema.Table,
 	}
 
+	var options = map[string]toolbox.Dictionary[string]{}
+	if obj, ok := context.GetObject().Addr().Interface().(interface {
+		RESTOptions() map[string]toolbox.Dictionary[string]
+	}); ok {
+		options = obj.RESTOptions()
+	}
+	var groups = map[string]bool{}
 	for _, item := range context.Schema.Fields {
-		info.Fields = append(info.Fields, Field{
-			Name:    item.Name,
-			DBName:  item.DBName,
-			Type:    item.FieldType.Name(),
-			Default: item.DefaultValue,
-			PK:      item.PrimaryKey,
-		})
-	}
-	info.Endpoints = resources[context.Action.Resource.Name].Actions
+		var field = formField(context.Schema, item)
+		field.Key = context.naming().Key(item)
+		if list, ok := options[item.DBName]; ok && item.DBName != "" {
+			field.Options = list
+			if field.Widget == "text" {
+				field.Widget = "select"
+			}
+		}
+		if field.Group != "" && !groups[field.Group] {
+			groups[field.Group] = true
+			info.Groups = append(info.Groups, field.Group)
+		}
+		info.Fields = append(info.Fields, field)
+	}
+	info.Endpoints = context.Action.Resource.Actions
+	info.Examples = context.examples()
 	context.Response.Data = info
 	return nil
 }
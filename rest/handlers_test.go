@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestExampleConditions(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queryable = func(column string) bool {
+		return column != "company_id"
+	}
+	var tests = []struct {
+		name   string
+		value  orderUser
+		prefix bool
+		where  []string
+		args   []interface{}
+		denied bool
+	}{
+		{"empty", orderUser{}, false, nil, nil, false},
+		{"equal", orderUser{FirstName: "Ann", ID: 3}, false,
+			[]string{"user.id = ?", "user.first_name = ?"}, []interface{}{3, "Ann"}, false},
+		{"not queryable", orderUser{FirstName: "Ann", CompanyID: 3}, false, nil, nil, true},
+		{"prefix", orderUser{FirstName: "An"}, true, []string{"user.first_name LIKE ?"}, []interface{}{"An%"}, false},
+		{"escaped prefix", orderUser{FirstName: `50%_a\b`}, true, []string{"user.first_name LIKE ?"}, []interface{}{`50\%\_a\\b%`}, false},
+		{"primary key", orderUser{ID: 7}, true, []string{"user.id = ?"}, []interface{}{7}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := exampleConditions(context.Background(), s, reflect.ValueOf(&tt.value), tt.prefix, queryable)
+			if (err == ErrorPermissionDenied) != tt.denied {
+				t.Fatalf("error = %v, want denied %v", err, tt.denied)
+			}
+			if !reflect.DeepEqual(where, tt.where) || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("got %v %v, want %v %v", where, args, tt.where, tt.args)
+			}
+		})
+	}
+}
+
+type compositeLine struct {
+	OrderID int    `gorm:"column:order_id;primaryKey;SET_KEY"`
+	Line    int    `gorm:"column:line;primaryKey"`
+	Shop    string `gorm:"column:shop;SET_KEY"`
+	Name    string `gorm:"column:name"`
+}
+
+func TestCompositeKeys(t *testing.T) {
+	s, err := schema.Parse(&compositeLine{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := primaryKeyURL(s); url != "/:order_id/:line" {
+		t.Errorf("primaryKeyURL = %q", url)
+	}
+	var keys []string
+	for _, field := range setKeys(s) {
+		keys = append(keys, field.DBName)
+	}
+	if !reflect.DeepEqual(keys, []string{"order_id", "shop"}) {
+		t.Errorf("setKeys = %v", keys)
+	}
+}
@@ -0,0 +1,245 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/encrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// MaxHistorySize limits the number of changes returned by the history endpoint.
+var MaxHistorySize = 1000
+
+// rowID returns the value of the primary key of the object as stored in field_audit, comma separated for
+// composite keys.
+func (context *Context) rowID(object reflect.Value) string {
+	var ids []string
+	for _, pk := range context.Schema.PrimaryFields {
+		v, _ := pk.ValueOf(context.ctx(), object)
+		ids = append(ids, fmt.Sprint(v))
+	}
+	return strings.Join(ids, ",")
+}
+
+// encryptedType is the type of the columns stored encrypted, see encrypt.Encrypted.
+var encryptedType = reflect.TypeOf(encrypt.Encrypted(""))
+
+// auditedFields returns the columns of the schema recorded in field_audit, leaving out the primary key, which
+// the obfuscated ids are derived from, the timestamps set by gorm, and the secrets: the columns hidden from
+// JSON and the encrypted ones, whose values would be stored in clear.
+func auditedFields(s *schema.Schema) []*schema.Field {
+	var fields []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+			continue
+		}
+		if field.Tag.Get("json") == "-" || field.FieldType == encryptedType || field.IndirectFieldType == encryptedType {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// auditSnapshot returns the audit values of the columns of the object.
+func (context *Context) auditSnapshot(object reflect.Value) map[string]string {
+	var snapshot = map[string]string{}
+	for _, field := range auditedFields(context.Schema) {
+		v, _ := field.ValueOf(context.ctx(), object)
+		snapshot[field.DBName] = auditValue(v)
+	}
+	return snapshot
+}
+
+// auditChanges returns a FieldAudit for each column of the object changed since the snapshot.
+func (context *Context) auditChanges(snapshot map[string]string, object reflect.Value) []FieldAudit {
+	var audits []FieldAudit
+	var row = context.rowID(object)
+	for _, field := range auditedFields(context.Schema) {
+		v, _ := field.ValueOf(context.ctx(), object)
+		var value = auditValue(v)
+		if old, ok := snapshot[field.DBName]; !ok || old == value {
+			continue
+		}
+		audits = append(audits, FieldAudit{
+			Table:          context.Schema.Table,
+			RowID:          row,
+			Column:         field.DBName,
+			OldValue:       snapshot[field.DBName],
+			NewValue:       value,
+			User:           context.User().UUID(),
+			ImpersonatedBy: context.impersonator(),
+		})
+	}
+	return audits
+}
+
+// parseAuditValue converts a value recorded by auditValue back to the type of the field.
+func parseAuditValue(field *schema.Field, text string) (interface{}, error) {
+	var typ = field.FieldType
+	if typ.Kind() == reflect.String {
+		return reflect.ValueOf(text).Convert(typ).Interface(), nil
+	}
+	if typ.Kind() == reflect.Ptr && (text == "" || text == "null") {
+		return reflect.Zero(typ).Interface(), nil
+	}
+	var base = typ
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	var value = reflect.New(base)
+	switch {
+	case base == reflect.TypeOf(time.Time{}):
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", field.DBName, err)
+		}
+		value.Elem().Set(reflect.ValueOf(t))
+	default:
+		if err := json.Unmarshal([]byte(text), value.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", field.DBName, err)
+		}
+	}
+	if typ.Kind() == reflect.Ptr {
+		return value.Interface(), nil
+	}
+	return value.Elem().Interface(), nil
+}
+
+// revertValues returns the value each column had before the given changes, ordered by id.
+func revertValues(audits []FieldAudit) map[string]string {
+	var values = map[string]string{}
+	for _, audit := range audits {
+		if _, ok := values[audit.Column]; !ok {
+			values[audit.Column] = audit.OldValue
+		}
+	}
+	return values
+}
+
+// History returns the changes recorded in field_audit for the object given by the primary key, oldest first.
+func History(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	object := context.GetObject()
+	found, err := context.FindByPrimaryKey(object.Addr().Interface())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorObjectNotExist
+	}
+	var audits []FieldAudit
+	if err := db.Where("`table` = ? AND row_id = ?", context.Schema.Table, context.rowID(object)).
+		Order("id").Limit(MaxHistorySize).Find(&audits).Error; err != nil {
+		return err
+	}
+	context.Response.Data = audits
+	return nil
+}
+
+// RevertTo restores the object given by the primary key to its state right after the change :audit of its
+// history, undoing the later changes. The update runs the BeforeUpdate, ValidateUpdate and AfterUpdate hooks
+// of the model and is recorded in field_audit along with the update in a single transaction. On resources
+// requiring approval the restored values are recorded as a pending change instead.
+func RevertTo(context *Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	object := context.GetObject()
+	ptr := object.Addr().Interface()
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorObjectNotExist
+	}
+	var row = context.rowID(object)
+	var target FieldAudit
+	if db.Where("id = ? AND `table` = ? AND row_id = ?", context.Request.Param("audit").Uint64(), context.Schema.Table, row).
+		Take(&target).RowsAffected == 0 {
+		return ErrorObjectNotExist
+	}
+	var audits []FieldAudit
+	if err := db.Where("`table` = ? AND row_id = ? AND id > ?", context.Schema.Table, row, target.ID).
+		Order("id").Find(&audits).Error; err != nil {
+		return err
+	}
+
+	var snapshot = context.auditSnapshot(object)
+	var prev = previous(object)
+	var columns []string
+	var values = map[string]interface{}{}
+	for column, text := range revertValues(audits) {
+		var field = context.Schema.LookUpField(column)
+		if field == nil || field.PrimaryKey {
+			continue
+		}
+		value, err := parseAuditValue(field, text)
+		if err != nil {
+			return err
+		}
+		if err := field.Set(context.ctx(), object, value); err != nil {
+			return err
+		}
+		columns = append(columns, field.DBName)
+		values[jsonName(field.Tag.Get("json"), field.Name)], _ = field.ValueOf(context.ctx(), object)
+	}
+	if len(columns) == 0 {
+		context.Response.Data = ptr
+		return nil
+	}
+	if context.RequiresApproval() {
+		return context.RequestApproval(object, values)
+	}
+	context.stampTenant(ptr)
+	if obj, ok := ptr.(interface{ BeforeUpdate(context *Context) error }); ok {
+		if err := obj.BeforeUpdate(context); err != nil {
+			return err
+		}
+	}
+	if obj, ok := ptr.(interface{ ValidateUpdate(context *Context) error }); ok {
+		if err := obj.ValidateUpdate(context); err != nil {
+			return err
+		}
+	}
+	if err := context.beforeUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
+	for _, f := range context.Schema.Fields {
+		if f.AutoUpdateTime > 0 {
+			columns = append(columns, f.DBName)
+		}
+	}
+	err = context.transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(ptr).Omit(clause.Associations).Select(columns).Updates(ptr).Error; err != nil {
+			return err
+		}
+		if changes := context.auditChanges(snapshot, object); len(changes) > 0 {
+			return tx.Create(&changes).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if obj, ok := ptr.(interface{ AfterUpdate(context *Context) error }); ok {
+		if err := obj.AfterUpdate(context); err != nil {
+			return err
+		}
+	}
+	if err := context.afterUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
+	context.Response.Data = ptr
+	return nil
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iesitalia/toolbox/encrypt"
+	"gorm.io/gorm/schema"
+)
+
+type historyRow struct {
+	ID        int               `gorm:"column:id;primaryKey"`
+	Name      string            `gorm:"column:name"`
+	Qty       int               `gorm:"column:qty"`
+	Price     float64           `gorm:"column:price"`
+	Active    bool              `gorm:"column:active"`
+	Note      *string           `gorm:"column:note"`
+	DueAt     *time.Time        `gorm:"column:due_at"`
+	UpdatedAt time.Time         `gorm:"column:updated_at"`
+	Password  string            `gorm:"column:password" json:"-"`
+	TaxID     encrypt.Encrypted `gorm:"column:tax_id"`
+}
+
+func TestParseAuditValue(t *testing.T) {
+	s, err := schema.Parse(&historyRow{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var note = "hello"
+	var due = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		column string
+		value  interface{}
+	}{
+		{"name", "Ann"},
+		{"name", ""},
+		{"qty", 42},
+		{"price", 9.5},
+		{"active", true},
+		{"note", &note},
+		{"note", (*string)(nil)},
+		{"due_at", &due},
+		{"due_at", (*time.Time)(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			got, err := parseAuditValue(s.LookUpField(tt.column), auditValue(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("parseAuditValue(%q) = %#v, want %#v", auditValue(tt.value), got, tt.value)
+			}
+		})
+	}
+	if _, err := parseAuditValue(s.LookUpField("qty"), "ten"); err == nil {
+		t.Error("expected an error for an invalid number")
+	}
+}
+
+func TestAuditedFields(t *testing.T) {
+	s, err := schema.Parse(&historyRow{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, field := range auditedFields(s) {
+		got = append(got, field.DBName)
+	}
+	var want = []string{"name", "qty", "price", "active", "note", "due_at"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("auditedFields() = %v, want %v", got, want)
+	}
+}
+
+func TestRevertValues(t *testing.T) {
+	var got = revertValues([]FieldAudit{
+		{ID: 5, Column: "name", OldValue: "b", NewValue: "c"},
+		{ID: 6, Column: "qty", OldValue: "1", NewValue: "2"},
+		{ID: 7, Column: "name", OldValue: "c", NewValue: "d"},
+	})
+	var want = map[string]string{"name": "b", "qty": "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("revertValues() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// UpdateHook observes the changes made by the update endpoints. Before runs ahead of the write and rejects it
+// by returning an error, After runs once the change is saved. Previous is a shallow copy of the object before
+// the change, current the object being written, both pointers to the model.
+type UpdateHook struct {
+	Before func(context *Context, previous, current interface{}) error
+	After  func(context *Context, previous, current interface{}) error
+}
+
+var updateHooks []UpdateHook
+var updateHooksMu sync.RWMutex
+
+// AddUpdateHook adds a hook to the update, field update and revert endpoints of every resource.
+func AddUpdateHook(hook UpdateHook) {
+	updateHooksMu.Lock()
+	updateHooks = append(updateHooks, hook)
+	updateHooksMu.Unlock()
+}
+
+// previous returns a shallow copy of the object for the update hooks.
+func previous(object reflect.Value) interface{} {
+	var v = reflect.New(object.Type())
+	v.Elem().Set(object)
+	return v.Interface()
+}
+
+// beforeUpdateHooks runs the Before function of the update hooks.
+func (context *Context) beforeUpdateHooks(previous, current interface{}) error {
+	updateHooksMu.RLock()
+	defer updateHooksMu.RUnlock()
+	for _, hook := range updateHooks {
+		if hook.Before != nil {
+			if err := hook.Before(context, previous, current); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// afterUpdateHooks runs the After function of the update hooks.
+func (context *Context) afterUpdateHooks(previous, current interface{}) error {
+	updateHooksMu.RLock()
+	defer updateHooksMu.RUnlock()
+	for _, hook := range updateHooks {
+		if hook.After != nil {
+			if err := hook.After(context, previous, current); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateHook checks the objects written by the create, set and clone endpoints before they are saved, rejecting
+// the write by returning an error. Current is a pointer to the model.
+type CreateHook func(context *Context, current interface{}) error
+
+var createHooks []CreateHook
+var createHooksMu sync.RWMutex
+
+// AddCreateHook adds a hook to the create, set and clone endpoints of every resource.
+func AddCreateHook(hook CreateHook) {
+	createHooksMu.Lock()
+	createHooks = append(createHooks, hook)
+	createHooksMu.Unlock()
+}
+
+// beforeCreateHooks runs the create hooks, stopping at the first error.
+func (context *Context) beforeCreateHooks(current interface{}) error {
+	createHooksMu.RLock()
+	defer createHooksMu.RUnlock()
+	for _, hook := range createHooks {
+		if err := hook(context, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadHook is called on every row read by the get, list, stream and sync endpoints, before the AfterGet
+// method of the model. Object is the addressable row.
+type ReadHook func(context *Context, object reflect.Value)
+
+var readHooks []ReadHook
+var readHooksMu sync.RWMutex
+
+// AddReadHook adds a hook to the rows read by the endpoints of every resource.
+func AddReadHook(hook ReadHook) {
+	readHooksMu.Lock()
+	readHooks = append(readHooks, hook)
+	readHooksMu.Unlock()
+}
+
+// ReadRow prepares a row read from the database: backfills the renamed columns and runs the read hooks.
+// Endpoints returning rows of the resource read outside of the standard handlers call it on every row.
+func (context *Context) ReadRow(value reflect.Value) {
+	context.shadowRead(value)
+	readHooksMu.RLock()
+	defer readHooksMu.RUnlock()
+	var object = reflect.Indirect(value)
+	for _, hook := range readHooks {
+		hook(context, object)
+	}
+}
+
+var lifecycleHooks struct {
+	sync.RWMutex
+	request  []func(context *Context) error
+	response []func(context *Context)
+	error    []func(context *Context, err error)
+}
+
+// OnRequest adds a hook called on every request to the endpoints of every resource, before the feature flag,
+// rate limit and permission checks. Returning an error rejects the request with it, skipping the handler.
+func OnRequest(hook func(context *Context) error) {
+	lifecycleHooks.Lock()
+	lifecycleHooks.request = append(lifecycleHooks.request, hook)
+	lifecycleHooks.Unlock()
+}
+
+// OnResponse adds a hook called on every request to the endpoints of every resource once it is handled,
+// before the response is serialized, successful or not. Streamed responses are already sent.
+// The rows of the listing endpoints are cleared and pooled for the next requests once the response is encoded,
+// see MaxPooledRows: hooks keeping context.Response.Data after they return, e.g. to send it asynchronously,
+// copy it or encode it first.
+func OnResponse(hook func(context *Context)) {
+	lifecycleHooks.Lock()
+	lifecycleHooks.response = append(lifecycleHooks.response, hook)
+	lifecycleHooks.Unlock()
+}
+
+// OnError adds a hook called on every request to the endpoints of every resource failing, with its error,
+// before the OnResponse hooks.
+func OnError(hook func(context *Context, err error)) {
+	lifecycleHooks.Lock()
+	lifecycleHooks.error = append(lifecycleHooks.error, hook)
+	lifecycleHooks.Unlock()
+}
+
+// onRequest runs the OnRequest hooks, stopping at the first error.
+func (context *Context) onRequest() error {
+	lifecycleHooks.RLock()
+	defer lifecycleHooks.RUnlock()
+	for _, hook := range lifecycleHooks.request {
+		if err := hook(context); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onResponse runs the OnError hooks when the request failed, then the OnResponse hooks.
+func (context *Context) onResponse() {
+	lifecycleHooks.RLock()
+	defer lifecycleHooks.RUnlock()
+	if !context.Response.Success {
+		var err = context.err
+		if err == nil {
+			err = errors.New(context.Response.Error)
+		}
+		for _, hook := range lifecycleHooks.error {
+			hook(context, err)
+		}
+	}
+	for _, hook := range lifecycleHooks.response {
+		hook(context)
+	}
+}
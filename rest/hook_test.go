@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	lifecycleHooks.Lock()
+	var request, response, failure = lifecycleHooks.request, lifecycleHooks.response, lifecycleHooks.error
+	lifecycleHooks.request, lifecycleHooks.response, lifecycleHooks.error = nil, nil, nil
+	lifecycleHooks.Unlock()
+	defer func() {
+		lifecycleHooks.Lock()
+		lifecycleHooks.request, lifecycleHooks.response, lifecycleHooks.error = request, response, failure
+		lifecycleHooks.Unlock()
+	}()
+
+	var calls []string
+	var rejected = errors.New("rejected")
+	OnRequest(func(context *Context) error {
+		calls = append(calls, "request")
+		return nil
+	})
+	OnRequest(func(context *Context) error {
+		return rejected
+	})
+	OnRequest(func(context *Context) error {
+		calls = append(calls, "unreached")
+		return nil
+	})
+	OnError(func(context *Context, err error) {
+		calls = append(calls, "error "+err.Error())
+	})
+	OnResponse(func(context *Context) {
+		calls = append(calls, "response")
+	})
+
+	var context = &Context{Response: &Pagination{Success: true}}
+	if err := context.onRequest(); err != rejected {
+		t.Errorf("onRequest = %v, want %v", err, rejected)
+	}
+	context.onResponse()
+	context.Response.Success = false
+	context.err = rejected
+	context.onResponse()
+	context.err = nil
+	context.Response.Error = "failed"
+	context.onResponse()
+	var want = []string{"request", "response", "error rejected", "response", "error failed", "response"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/outcome"
+)
+
+// IdempotencyHeader is the request header carrying the idempotency key of a mutation, see Idempotency.
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotencyTTL is the time the responses are replayed for by the Idempotency middlewares without TTL.
+var IdempotencyTTL = 24 * time.Hour
+
+// MaxIdempotencyKeys bounds the number of responses stored, the oldest are dropped once it is reached.
+var MaxIdempotencyKeys = 10000
+
+// ErrorIdempotencyInProgress is returned, with the 409 status, while the first request of an idempotency key runs.
+var ErrorIdempotencyInProgress = errors.New("a request with the same idempotency key is in progress")
+
+// ErrorIdempotencyMismatch is returned, with the 422 status, when an idempotency key is reused for another request.
+var ErrorIdempotencyMismatch = errors.New("idempotency key already used for another request")
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *outcome.Response
+	expires     time.Time
+}
+
+// idempotency stores the responses by user, resource and key. Entries without response are in progress.
+var idempotency = struct {
+	sync.Mutex
+	entries map[string]*idempotencyEntry
+}{entries: map[string]*idempotencyEntry{}}
+
+// idempotentRequest is the idempotency key of the request, stored along with its response once it succeeds.
+type idempotentRequest struct {
+	key     string
+	entry   *idempotencyEntry
+	replay  *outcome.Response
+	settled bool
+}
+
+// Idempotency returns a middleware honoring the Idempotency-Key header of the PUT and POST requests: the first
+// successful response of a key is stored for the TTL, IdempotencyTTL at the time of the call when zero, and
+// replayed to the retries of the same user with the Idempotent-Replayed header, without running the handler
+// again. A retry while the first request runs fails with 409, and a key reused for another method, URL or body
+// fails with 422. Failed requests are not stored, so they can be retried with the same key.
+//
+// The Create and Set endpoints use it. Responses are stored in memory, so retries must reach the same instance.
+func Idempotency(ttl time.Duration) Middleware {
+	if ttl <= 0 {
+		ttl = IdempotencyTTL
+	}
+	return func(context *Context, next func() error) error {
+		var key = context.Request.Header(IdempotencyHeader)
+		var method = context.Request.Method()
+		if key == "" || (method != string(PUT) && method != string(POST)) {
+			return next()
+		}
+		key = context.Action.Resource.Table + "\x00" + context.User().UUID() + "\x00" + context.Tenant() + "\x00" + key
+		var fingerprint = requestFingerprint(method, context.Request.OriginalURL(), context.Request.Body())
+		var now = time.Now()
+
+		idempotency.Lock()
+		entry, ok := idempotency.entries[key]
+		if ok && now.After(entry.expires) {
+			ok = false
+		}
+		var stored *outcome.Response
+		if ok {
+			stored = entry.response
+		} else {
+			entry = &idempotencyEntry{fingerprint: fingerprint, expires: now.Add(ttl)}
+			storeIdempotency(key, entry, now)
+		}
+		idempotency.Unlock()
+
+		switch {
+		case ok && entry.fingerprint != fingerprint:
+			context.SetStatus(http.StatusUnprocessableEntity)
+			return ErrorIdempotencyMismatch
+		case ok && stored == nil:
+			context.SetStatus(http.StatusConflict)
+			return ErrorIdempotencyInProgress
+		case ok:
+			context.idempotent = &idempotentRequest{key: key, replay: stored, settled: true}
+			return nil
+		}
+		context.idempotent = &idempotentRequest{key: key, entry: entry}
+		var err = next()
+		if err != nil {
+			context.settleIdempotency(nil)
+		}
+		return err
+	}
+}
+
+// storeIdempotency stores the entry, dropping the expired entries and then the oldest once MaxIdempotencyKeys is
+// reached. It must be called with the lock held.
+func storeIdempotency(key string, entry *idempotencyEntry, now time.Time) {
+	if len(idempotency.entries) >= MaxIdempotencyKeys {
+		var oldest string
+		for k, e := range idempotency.entries {
+			if now.After(e.expires) {
+				delete(idempotency.entries, k)
+			} else if oldest == "" || e.expires.Before(idempotency.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(idempotency.entries) >= MaxIdempotencyKeys && oldest != "" {
+			delete(idempotency.entries, oldest)
+		}
+	}
+	idempotency.entries[key] = entry
+}
+
+// requestFingerprint returns the hash identifying the request an idempotency key was used for.
+func requestFingerprint(method string, url string, body string) string {
+	var h = sha256.New()
+	h.Write([]byte(method + "\x00" + url + "\x00"))
+	h.Write([]byte(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayed returns the stored response the request replays, nil when it does not replay one.
+func (context *Context) replayed() *outcome.Response {
+	if context.idempotent == nil || context.idempotent.replay == nil {
+		return nil
+	}
+	var response = *context.idempotent.replay
+	response.Headers = map[string]string{}
+	for k, v := range context.idempotent.replay.Headers {
+		response.Headers[k] = v
+	}
+	return response.Header("Idempotent-Replayed", "true")
+}
+
+// settleIdempotency stores the response of the first request of an idempotency key, or releases the key when the
+// request failed so it can be retried.
+func (context *Context) settleIdempotency(response *outcome.Response) {
+	if context.idempotent == nil || context.idempotent.settled {
+		return
+	}
+	context.idempotent.settled = true
+	idempotency.Lock()
+	defer idempotency.Unlock()
+	if idempotency.entries[context.idempotent.key] != context.idempotent.entry {
+		return
+	}
+	if response == nil || !context.Response.Success {
+		delete(idempotency.entries, context.idempotent.key)
+		return
+	}
+	context.idempotent.entry.response = response
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/outcome"
+)
+
+func resetIdempotency() {
+	idempotency.Lock()
+	idempotency.entries = map[string]*idempotencyEntry{}
+	idempotency.Unlock()
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	var base = requestFingerprint("PUT", "/admin/rest/orders", `{"a":1}`)
+	if base != requestFingerprint("PUT", "/admin/rest/orders", `{"a":1}`) {
+		t.Error("the fingerprint of a request is not stable")
+	}
+	for _, other := range []string{
+		requestFingerprint("POST", "/admin/rest/orders", `{"a":1}`),
+		requestFingerprint("PUT", "/admin/rest/items", `{"a":1}`),
+		requestFingerprint("PUT", "/admin/rest/orders", `{"a":2}`),
+	} {
+		if other == base {
+			t.Error("different requests share the fingerprint")
+		}
+	}
+}
+
+func TestStoreIdempotencyBound(t *testing.T) {
+	var max = MaxIdempotencyKeys
+	MaxIdempotencyKeys = 2
+	defer func() {
+		MaxIdempotencyKeys = max
+		resetIdempotency()
+	}()
+	resetIdempotency()
+	var now = time.Now()
+	idempotency.Lock()
+	storeIdempotency("old", &idempotencyEntry{expires: now.Add(time.Minute)}, now)
+	storeIdempotency("new", &idempotencyEntry{expires: now.Add(time.Hour)}, now)
+	storeIdempotency("last", &idempotencyEntry{expires: now.Add(time.Hour)}, now)
+	_, old := idempotency.entries["old"]
+	var size = len(idempotency.entries)
+	idempotency.Unlock()
+	if old || size != 2 {
+		t.Errorf("entries = %d, old kept = %v, want the oldest entry dropped", size, old)
+	}
+}
+
+func TestSettleIdempotency(t *testing.T) {
+	defer resetIdempotency()
+	var settle = func(key string, success bool, response *outcome.Response) *idempotencyEntry {
+		var entry = &idempotencyEntry{expires: time.Now().Add(time.Hour)}
+		idempotency.Lock()
+		idempotency.entries[key] = entry
+		idempotency.Unlock()
+		var context = &Context{Response: newResponse(), idempotent: &idempotentRequest{key: key, entry: entry}}
+		context.Response.Success = success
+		context.settleIdempotency(response)
+		context.settleIdempotency(nil)
+		idempotency.Lock()
+		defer idempotency.Unlock()
+		return idempotency.entries[key]
+	}
+	var response = outcome.Json(map[string]string{"id": "1"}).Header("Location", "/orders/1")
+	if entry := settle("ok", true, response); entry == nil || entry.response != response {
+		t.Error("the response of a successful request is not stored")
+	}
+	if entry := settle("failed", false, response); entry != nil {
+		t.Error("the key of a failed request is not released")
+	}
+	if entry := settle("aborted", true, nil); entry != nil {
+		t.Error("the key of a request without response is not released")
+	}
+
+	var context = &Context{idempotent: &idempotentRequest{replay: response, settled: true}}
+	var replay = context.replayed()
+	if replay.Headers["Idempotent-Replayed"] != "true" || replay.Headers["Location"] != "/orders/1" {
+		t.Errorf("replayed headers = %v", replay.Headers)
+	}
+	if _, ok := response.Headers["Idempotent-Replayed"]; ok {
+		t.Error("replaying changed the stored response")
+	}
+}
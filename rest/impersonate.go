@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// ImpersonateHeader specifies the request header carrying the uuid of the impersonated user.
+var ImpersonateHeader = "X-Impersonate-User"
+
+// ImpersonatedByHeader specifies the response header set to the uuid of the real user of impersonated requests.
+var ImpersonatedByHeader = "X-Impersonated-By"
+
+// Impersonator loads the user with the given uuid. It returns nil if the user does not exist.
+// Impersonation is disabled while it is nil.
+var Impersonator func(request *evo.Request, uuid string) evo.UserInterface
+
+// ErrorImpersonationUnavailable represents an error indicating that the impersonated user can not be loaded.
+var ErrorImpersonationUnavailable = errors.New("impersonated user not found")
+
+// ErrorImpersonationDenied represents an error indicating that the impersonated user holds permissions the
+// real user lacks.
+var ErrorImpersonationDenied = errors.New("impersonated user has permissions the user lacks")
+
+// User returns the user the request acts as, which is the impersonated user of impersonated requests.
+func (context *Context) User() evo.UserInterface {
+	if context.impersonated != nil {
+		return context.impersonated
+	}
+	if context.Request == nil {
+		return context.user
+	}
+	return context.Request.User()
+}
+
+// ImpersonatedBy returns the real user of an impersonated request, or nil if the request is not impersonated.
+func (context *Context) ImpersonatedBy() evo.UserInterface {
+	if context.impersonated == nil {
+		return nil
+	}
+	if context.Request == nil {
+		return context.user
+	}
+	return context.Request.User()
+}
+
+// impersonator returns the uuid of the real user of an impersonated request, empty otherwise.
+func (context *Context) impersonator() string {
+	if user := context.ImpersonatedBy(); user != nil {
+		return user.UUID()
+	}
+	return ""
+}
+
+// impersonate switches the user of the request to the one of the ImpersonateHeader header.
+// The real user must hold the acl.Impersonate permission and every permission of the impersonated user, so
+// impersonation can not escalate privileges. Impersonated requests are logged and every entry of their logger
+// is flagged with the real user.
+func (context *Context) impersonate() error {
+	var uuid = context.Request.Header(ImpersonateHeader)
+	if uuid == "" {
+		return nil
+	}
+	var user = context.Request.User()
+	if user.Anonymous() {
+		context.SetStatus(http.StatusUnauthorized)
+		return ErrorUnauthorized
+	}
+	if !user.HasPermission(acl.Impersonate) {
+		context.SetStatus(http.StatusForbidden)
+		return ErrorPermissionDenied
+	}
+	if Impersonator == nil {
+		return ErrorImpersonationUnavailable
+	}
+	var impersonated = Impersonator(context.Request, uuid)
+	if impersonated == nil || impersonated.Anonymous() {
+		return ErrorImpersonationUnavailable
+	}
+	permissions, err := permissionsOf(impersonated)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !user.HasPermission(permission) {
+			context.SetStatus(http.StatusForbidden)
+			return ErrorImpersonationDenied
+		}
+	}
+	context.impersonated = impersonated
+	context.Request.SetHeader(ImpersonatedByHeader, user.UUID())
+	context.logger = nil
+	context.Logger().Info("impersonated request", "action", context.Action.Name)
+	return nil
+}
+
+// permissionsOf returns the permissions granted to the user, read from the user when it lists them, e.g.
+// auth.User, from its acl roles otherwise.
+func permissionsOf(user evo.UserInterface) ([]string, error) {
+	if u, ok := user.(interface{ Permissions() []string }); ok {
+		return u.Permissions(), nil
+	}
+	return acl.CachedPermissions(user.UUID())
+}
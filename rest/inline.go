@@ -0,0 +1,284 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/i18n"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrorColumnNotEditable is returned when a field update targets a column not marked as editable in the filter view.
+var ErrorColumnNotEditable = errors.New("column is not editable")
+
+// ColumnValidation describes the values accepted by an editable column. It is returned with the filter view
+// so grids can validate the input before sending it, and checked again by the field update endpoint.
+// - MaxLength: maximum number of characters of text values, defaults to the size of the column.
+// - Min, Max: bounds of numeric values.
+// - Pattern: regular expression text values must match.
+type ColumnValidation struct {
+	Required  bool     `json:"required,omitempty"`
+	MaxLength int      `json:"max_length,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// FieldAudit records a value changed by the update and field update endpoints, see History.
+type FieldAudit struct {
+	ID       uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Table    string `gorm:"column:table;size:64;index:field_audit_row_idx" json:"table"`
+	RowID    string `gorm:"column:row_id;size:64;index:field_audit_row_idx" json:"row_id"`
+	Column   string `gorm:"column:column;size:64" json:"column"`
+	OldValue string `gorm:"column:old_value;type:text" json:"old_value"`
+	NewValue string `gorm:"column:new_value;type:text" json:"new_value"`
+	User     string `gorm:"column:user;size:36" json:"user"`
+	// ImpersonatedBy is the real user of the impersonated requests, User being the impersonated one.
+	ImpersonatedBy string    `gorm:"column:impersonated_by;size:36" json:"impersonated_by,omitempty"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName returns the name of the table for the FieldAudit struct.
+func (FieldAudit) TableName() string {
+	return "field_audit"
+}
+
+func init() {
+	i18n.Register("en", map[string]string{
+		"validation.required": "{column} is required",
+		"validation.number":   "{column} must be a number",
+		"validation.min":      "{column} must be at least {min}",
+		"validation.max":      "{column} must be at most {max}",
+		"validation.pattern":  "{column} has an invalid format",
+		"validation.options":  "{column} must be one of the options",
+	})
+	i18n.RegisterPlural("en", "validation.max_length", i18n.Message{
+		One:   "{column} must be at most {count} character",
+		Other: "{column} must be at most {count} characters",
+	})
+}
+
+// editableColumn returns the editable column of the filter view for the database column.
+func editableColumn(v FilterView, column string) (*FilterViewColumn, bool) {
+	for idx := range v.Columns {
+		if v.Columns[idx].Editable && v.Columns[idx].ColumnKey() == column {
+			return &v.Columns[idx], true
+		}
+	}
+	return nil, false
+}
+
+// Validate checks the value of a column against the validation rules. Options of the column restrict the accepted values.
+func (c *FilterViewColumn) Validate(value interface{}) error {
+	var rules = ColumnValidation{}
+	if c.Validation != nil {
+		rules = *c.Validation
+	}
+	var text string
+	if value != nil {
+		text = fmt.Sprint(value)
+	}
+	if text == "" {
+		if rules.Required {
+			return i18n.NewError("validation.required", "column", c.ColumnKey())
+		}
+		return nil
+	}
+	if rules.MaxLength > 0 && len([]rune(text)) > rules.MaxLength {
+		return i18n.NewError("validation.max_length", "column", c.ColumnKey(), "count", rules.MaxLength)
+	}
+	if rules.Min != nil || rules.Max != nil {
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return i18n.NewError("validation.number", "column", c.ColumnKey())
+		}
+		if rules.Min != nil && n < *rules.Min {
+			return i18n.NewError("validation.min", "column", c.ColumnKey(), "min", *rules.Min)
+		}
+		if rules.Max != nil && n > *rules.Max {
+			return i18n.NewError("validation.max", "column", c.ColumnKey(), "max", *rules.Max)
+		}
+	}
+	if rules.Pattern != "" {
+		re, err := regexp.Compile(rules.Pattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(text) {
+			return i18n.NewError("validation.pattern", "column", c.ColumnKey())
+		}
+	}
+	if len(c.Options) > 0 {
+		for _, option := range c.Options {
+			if option.Key == text {
+				return nil
+			}
+		}
+		return i18n.NewError("validation.options", "column", c.ColumnKey())
+	}
+	return nil
+}
+
+// describeEditable fills the type and the default max length of the editable columns from the schema.
+func (v *FilterView) describeEditable(s *schema.Schema) {
+	for idx := range v.Columns {
+		var column = &v.Columns[idx]
+		if !column.Editable {
+			continue
+		}
+		var field = s.LookUpField(column.ColumnKey())
+		if field == nil {
+			continue
+		}
+		if column.Type == "" {
+			column.Type = string(field.DataType)
+		}
+		if field.DataType == schema.String && field.Size > 0 {
+			if column.Validation == nil {
+				column.Validation = &ColumnValidation{}
+			}
+			if column.Validation.MaxLength == 0 {
+				column.Validation.MaxLength = field.Size
+			}
+		}
+	}
+}
+
+// UpdateField sets a single column of the object given by the primary key from the body {"value": ...}.
+// Only the columns marked as editable in the filter view of the model can be updated. The update runs the
+// BeforeUpdate, ValidateUpdate and AfterUpdate hooks of the model and records the change in field_audit.
+func UpdateField(context *Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	obj, ok := context.Object.Interface().(interface{ FilterView() FilterView })
+	if !ok {
+		return ErrorColumnNotEditable
+	}
+	var name = context.Request.Param("column").String()
+	column, ok := editableColumn(obj.FilterView(), name)
+	if !ok {
+		return ErrorColumnNotEditable
+	}
+	var field = context.Schema.LookUpField(name)
+	if field == nil || field.PrimaryKey {
+		return ErrorColumnNotEditable
+	}
+	var body struct {
+		Value interface{} `json:"value"`
+	}
+	if err := context.checkBody(false); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(context.Request.Body()), &body); err != nil {
+		return err
+	}
+	if err := column.Validate(body.Value); err != nil {
+		return err
+	}
+
+	object := context.GetObject()
+	ptr := object.Addr().Interface()
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorObjectNotExist
+	}
+	oldValue, _ := field.ValueOf(context.Request.Context.Context(), object)
+	var prev = previous(object)
+
+	// decode the value through the json name of the field to convert it to the field type
+	var key = strings.Split(field.Tag.Get("json"), ",")[0]
+	if key == "" || key == "-" {
+		key = field.Name
+	}
+	b, err := json.Marshal(map[string]interface{}{key: body.Value})
+	if err != nil {
+		return err
+	}
+	if context.Action.Resource.Feature.RequireApproval {
+		return context.requestApproval(ApprovalUpdate, object, b)
+	}
+	if err := json.Unmarshal(b, ptr); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	NormalizeInput(ptr)
+	context.shadowWrite(object, prev)
+	context.stampTenant(ptr)
+	if obj, ok := ptr.(interface{ BeforeUpdate(context *Context) error }); ok {
+		if err := obj.BeforeUpdate(context); err != nil {
+			return err
+		}
+	}
+	if obj, ok := ptr.(interface{ ValidateUpdate(context *Context) error }); ok {
+		if err := obj.ValidateUpdate(context); err != nil {
+			return err
+		}
+	}
+	if err := context.beforeUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
+	var columns = []string{field.DBName}
+	for _, f := range context.Schema.Fields {
+		if f.AutoUpdateTime > 0 {
+			columns = append(columns, f.DBName)
+		}
+	}
+	columns = context.shadowColumns(columns)
+	if err := context.GetDBO().Model(ptr).Omit(clause.Associations).Select(columns).Updates(ptr).Error; err != nil {
+		return err
+	}
+	if obj, ok := ptr.(interface{ AfterUpdate(context *Context) error }); ok {
+		if err := obj.AfterUpdate(context); err != nil {
+			return err
+		}
+	}
+	if err := context.afterUpdateHooks(prev, ptr); err != nil {
+		return err
+	}
+
+	newValue, _ := field.ValueOf(context.Request.Context.Context(), object)
+	var audit = FieldAudit{
+		Table:          context.Schema.Table,
+		RowID:          context.rowID(object),
+		Column:         field.DBName,
+		OldValue:       auditValue(oldValue),
+		NewValue:       auditValue(newValue),
+		User:           context.User().UUID(),
+		ImpersonatedBy: context.impersonator(),
+	}
+	if err := db.Create(&audit).Error; err != nil {
+		context.Logger().Error("unable to record field audit", "column", field.DBName, "error", err.Error())
+	}
+	context.Response.Data = ptr
+	return nil
+}
+
+func auditValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
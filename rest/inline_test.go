@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/iesitalia/toolbox"
+)
+
+func TestColumnValidate(t *testing.T) {
+	var min, max = 1.0, 10.0
+	var tests = []struct {
+		name   string
+		column FilterViewColumn
+		value  interface{}
+		ok     bool
+	}{
+		{"no rules", FilterViewColumn{DBField: "note"}, "anything", true},
+		{"required empty", FilterViewColumn{DBField: "name", Validation: &ColumnValidation{Required: true}}, "", false},
+		{"optional empty", FilterViewColumn{DBField: "name", Validation: &ColumnValidation{MaxLength: 3}}, nil, true},
+		{"too long", FilterViewColumn{DBField: "code", Validation: &ColumnValidation{MaxLength: 3}}, "abcd", false},
+		{"multibyte length", FilterViewColumn{DBField: "code", Validation: &ColumnValidation{MaxLength: 3}}, "àèì", true},
+		{"in range", FilterViewColumn{DBField: "qty", Validation: &ColumnValidation{Min: &min, Max: &max}}, 5.0, true},
+		{"below min", FilterViewColumn{DBField: "qty", Validation: &ColumnValidation{Min: &min}}, 0, false},
+		{"not a number", FilterViewColumn{DBField: "qty", Validation: &ColumnValidation{Max: &max}}, "ten", false},
+		{"pattern", FilterViewColumn{DBField: "zip", Validation: &ColumnValidation{Pattern: `^\d{5}$`}}, "00184", true},
+		{"pattern mismatch", FilterViewColumn{DBField: "zip", Validation: &ColumnValidation{Pattern: `^\d{5}$`}}, "184", false},
+		{"option", FilterViewColumn{DBField: "status", Options: toolbox.Dictionary[string]{{Key: "open", Value: "Open"}}}, "open", true},
+		{"unknown option", FilterViewColumn{DBField: "status", Options: toolbox.Dictionary[string]{{Key: "open", Value: "Open"}}}, "closed", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.column.Validate(tt.value); (err == nil) != tt.ok {
+				t.Errorf("Validate(%v) = %v, want ok %v", tt.value, err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/getevo/evo/v2/lib/outcome"
+	"gorm.io/gorm/schema"
+)
+
+// JSONAPIContentType is the media type of the JSON:API documents. Requests accepting it, or any request to a
+// resource embedding JSONAPI, are answered with JSON:API documents instead of the default envelope.
+const JSONAPIContentType = "application/vnd.api+json"
+
+// JSONAPI is a marker type which, embedded in a model, makes the rest endpoints of its resource answer with
+// JSON:API documents whatever the Accept header of the requests.
+type JSONAPI struct{}
+
+// JSONAPIDocument is the top level of a JSON:API response, see https://jsonapi.org/format/.
+type JSONAPIDocument struct {
+	Data     interface{}            `json:"data,omitempty"`
+	Included []JSONAPIResource      `json:"included,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Links    map[string]string      `json:"links,omitempty"`
+	Errors   []JSONAPIError         `json:"errors,omitempty"`
+}
+
+// JSONAPIResource is a row of a resource in a JSON:API document.
+type JSONAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]interface{}         `json:"attributes"`
+	Relationships map[string]JSONAPIRelationship `json:"relationships,omitempty"`
+}
+
+// JSONAPIIdentifier identifies a related row in the relationships of a JSON:API resource.
+type JSONAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// JSONAPIRelationship is a relation of a JSON:API resource: an identifier, a list of identifiers or null.
+type JSONAPIRelationship struct {
+	Data interface{} `json:"data"`
+}
+
+// JSONAPIError is an error of a JSON:API document.
+type JSONAPIError struct {
+	Status string `json:"status"`
+	Title  string `json:"title"`
+}
+
+// jsonAPI returns whether the response of the request is a JSON:API document.
+func (context *Context) jsonAPI() bool {
+	if context.Action.Resource != nil && context.Action.Resource.Feature.JSONAPI {
+		return true
+	}
+	return strings.Contains(context.Request.Header("Accept"), JSONAPIContentType)
+}
+
+// isRows returns whether the data is a row of the model, a pointer to a row, or a slice of rows or pointers.
+func (context *Context) isRows(data interface{}) bool {
+	var typ = reflect.TypeOf(data)
+	if typ == nil {
+		return false
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	return typ == context.Object.Type()
+}
+
+// errorStatus returns the HTTP status of an error of the request.
+func (context *Context) errorStatus() int {
+	switch {
+	case context.status >= 400:
+		return context.status
+	case errors.Is(context.err, ErrorObjectNotExist), errors.Is(context.err, ErrorNotFound):
+		return http.StatusNotFound
+	case errors.Is(context.err, ErrorUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(context.err, ErrorPermissionDenied):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// jsonAPIResponse returns the response of the request as a JSON:API document. The data of the response, as
+// served by the default envelope, becomes the primary data when rows is set, and the meta otherwise.
+func (context *Context) jsonAPIResponse(rows bool) *outcome.Response {
+	var document JSONAPIDocument
+	var status = context.status
+	if !context.Response.Success {
+		status = context.errorStatus()
+		document.Errors = []JSONAPIError{{Status: strconv.Itoa(status), Title: context.Response.Error}}
+	} else if rows {
+		var included = &jsonAPIIncluded{seen: map[JSONAPIIdentifier]bool{}}
+		document.Data = jsonAPIData(context.Schema, context.naming(), genericJSON(context.Response.Data), included)
+		document.Included = included.resources
+		if _, ok := document.Data.([]JSONAPIResource); ok {
+			document.Meta = map[string]interface{}{
+				"total":        context.Response.Total,
+				"total_pages":  context.Response.TotalPages,
+				"current_page": context.Response.Page,
+				"size":         context.Response.Size,
+			}
+			if context.Response.Partial {
+				document.Meta["partial"] = true
+			}
+			if context.Response.Estimated {
+				document.Meta["estimated"] = true
+			}
+			if context.Response.HasNext != nil {
+				document.Meta["has_next"] = *context.Response.HasNext
+			}
+		}
+		if context.Action.Name == "PAGINATE" {
+			document.Links = pageLinks(context.Request.OriginalURL(), context.Response.Page, context.Response.TotalPages)
+		}
+	} else {
+		document.Meta = map[string]interface{}{"data": context.Response.Data}
+	}
+	var response = outcome.Json(document)
+	response.ContentType = JSONAPIContentType
+	if status > 0 {
+		response.Status(status)
+	}
+	return response
+}
+
+// genericJSON returns the JSON representation of the data as maps and slices, keeping the numbers as they are.
+func genericJSON(data interface{}) interface{} {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var decoder = json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// jsonAPIIncluded collects the related rows of a document, once each.
+type jsonAPIIncluded struct {
+	seen      map[JSONAPIIdentifier]bool
+	resources []JSONAPIResource
+}
+
+// jsonAPIData returns the resources of the generic JSON rows of the schema: a resource or a list of resources.
+func jsonAPIData(s *schema.Schema, naming NamingStrategy, data interface{}, included *jsonAPIIncluded) interface{} {
+	if list, ok := data.([]interface{}); ok {
+		var result = make([]JSONAPIResource, 0, len(list))
+		for _, item := range list {
+			if row, ok := item.(map[string]interface{}); ok {
+				result = append(result, jsonAPIRow(s, naming, row, included))
+			}
+		}
+		return result
+	}
+	if row, ok := data.(map[string]interface{}); ok {
+		return jsonAPIRow(s, naming, row, included)
+	}
+	return nil
+}
+
+// jsonAPIID returns the id of a row: its primary key, or its primary keys joined by commas.
+func jsonAPIID(s *schema.Schema, naming NamingStrategy, row map[string]interface{}) string {
+	var keys []string
+	for _, field := range s.PrimaryFields {
+		keys = append(keys, fmt.Sprint(row[naming.Key(field)]))
+	}
+	return strings.Join(keys, ",")
+}
+
+// jsonAPIRow returns the resource of a generic JSON row of the schema. The loaded relations become
+// relationships and their rows are added to the included rows.
+func jsonAPIRow(s *schema.Schema, naming NamingStrategy, row map[string]interface{}, included *jsonAPIIncluded) JSONAPIResource {
+	var resource = JSONAPIResource{Type: s.Table, ID: jsonAPIID(s, naming, row), Attributes: map[string]interface{}{}}
+	var skip = map[string]bool{}
+	for _, field := range s.PrimaryFields {
+		skip[naming.Key(field)] = true
+	}
+	for _, relation := range s.Relationships.Relations {
+		var key = naming.Key(relation.Field)
+		var value, ok = row[key]
+		if !ok {
+			continue
+		}
+		skip[key] = true
+		if value == nil {
+			continue
+		}
+		if resource.Relationships == nil {
+			resource.Relationships = map[string]JSONAPIRelationship{}
+		}
+		resource.Relationships[key] = JSONAPIRelationship{Data: included.add(relation.FieldSchema, naming, value)}
+	}
+	for key, value := range row {
+		if !skip[key] {
+			resource.Attributes[key] = value
+		}
+	}
+	return resource
+}
+
+// add adds the related rows to the included rows, returning their identifiers.
+func (included *jsonAPIIncluded) add(s *schema.Schema, naming NamingStrategy, value interface{}) interface{} {
+	if list, ok := value.([]interface{}); ok {
+		var identifiers = make([]JSONAPIIdentifier, 0, len(list))
+		for _, item := range list {
+			if row, ok := item.(map[string]interface{}); ok {
+				identifiers = append(identifiers, included.addRow(s, naming, row))
+			}
+		}
+		return identifiers
+	}
+	if row, ok := value.(map[string]interface{}); ok {
+		return included.addRow(s, naming, row)
+	}
+	return nil
+}
+
+// addRow adds a related row to the included rows, returning its identifier.
+func (included *jsonAPIIncluded) addRow(s *schema.Schema, naming NamingStrategy, row map[string]interface{}) JSONAPIIdentifier {
+	var resource = jsonAPIRow(s, naming, row, included)
+	var identifier = JSONAPIIdentifier{Type: resource.Type, ID: resource.ID}
+	if !included.seen[identifier] {
+		included.seen[identifier] = true
+		included.resources = append(included.resources, resource)
+	}
+	return identifier
+}
+
+// pageLinks returns the pagination links of the page of the request URL.
+func pageLinks(raw string, page int, pages int) map[string]string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	var link = func(page int) string {
+		var query = u.Query()
+		query.Set("page", strconv.Itoa(page))
+		var l = *u
+		l.RawQuery = query.Encode()
+		return l.String()
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pages < 1 {
+		pages = 1
+	}
+	var links = map[string]string{
+		"self":  link(page),
+		"first": link(1),
+		"last":  link(pages),
+	}
+	if page > 1 {
+		links["prev"] = link(page - 1)
+	}
+	if page < pages {
+		links["next"] = link(page + 1)
+	}
+	return links
+}
@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type jsonAPIAuthor struct {
+	ID   int    `gorm:"column:id;primaryKey" json:"id"`
+	Name string `gorm:"column:name" json:"name"`
+}
+
+type jsonAPIComment struct {
+	ID       int            `gorm:"column:id;primaryKey" json:"id"`
+	PostID   int            `gorm:"column:post_id" json:"post_id"`
+	Body     string         `gorm:"column:body" json:"body"`
+	AuthorID int            `gorm:"column:author_id" json:"author_id"`
+	Author   *jsonAPIAuthor `json:"author"`
+}
+
+type jsonAPIPost struct {
+	ID       int              `gorm:"column:id;primaryKey" json:"id"`
+	Title    string           `gorm:"column:title" json:"title"`
+	AuthorID int              `gorm:"column:author_id" json:"author_id"`
+	Author   *jsonAPIAuthor   `json:"author"`
+	Comments []jsonAPIComment `gorm:"foreignKey:PostID" json:"comments"`
+}
+
+func TestJSONAPIData(t *testing.T) {
+	s, err := schema.Parse(&jsonAPIPost{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var author = &jsonAPIAuthor{ID: 7, Name: "Ada"}
+	var posts = []jsonAPIPost{
+		{ID: 1, Title: "First", AuthorID: 7, Author: author, Comments: []jsonAPIComment{{ID: 10, PostID: 1, Body: "Nice", AuthorID: 7, Author: author}}},
+		{ID: 2, Title: "Second", AuthorID: 7},
+	}
+	var included = &jsonAPIIncluded{seen: map[JSONAPIIdentifier]bool{}}
+	b, err := json.Marshal(JSONAPIDocument{Data: jsonAPIData(s, NamingTags, genericJSON(posts), included), Included: included.resources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `{"data":[` +
+		`{"type":"json_api_posts","id":"1","attributes":{"author_id":7,"title":"First"},"relationships":{"author":{"data":{"type":"json_api_authors","id":"7"}},"comments":{"data":[{"type":"json_api_comments","id":"10"}]}}},` +
+		`{"type":"json_api_posts","id":"2","attributes":{"author_id":7,"title":"Second"}}],` +
+		`"included":[` +
+		`{"type":"json_api_authors","id":"7","attributes":{"name":"Ada"}},` +
+		`{"type":"json_api_comments","id":"10","attributes":{"author_id":7,"body":"Nice","post_id":1},"relationships":{"author":{"data":{"type":"json_api_authors","id":"7"}}}}]}`
+	if string(b) != want {
+		t.Errorf("document = %s\nwant %s", b, want)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	var tests = []struct {
+		page  int
+		pages int
+		want  map[string]string
+	}{
+		{1, 1, map[string]string{"self": "/rest/posts/paginate?page=1&size=10", "first": "/rest/posts/paginate?page=1&size=10", "last": "/rest/posts/paginate?page=1&size=10"}},
+		{2, 3, map[string]string{
+			"self":  "/rest/posts/paginate?page=2&size=10",
+			"first": "/rest/posts/paginate?page=1&size=10",
+			"last":  "/rest/posts/paginate?page=3&size=10",
+			"prev":  "/rest/posts/paginate?page=1&size=10",
+			"next":  "/rest/posts/paginate?page=3&size=10",
+		}},
+	}
+	for _, test := range tests {
+		if got := pageLinks("/rest/posts/paginate?size=10&page=9", test.page, test.pages); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("pageLinks(%d, %d) = %v, want %v", test.page, test.pages, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package rest
+
+import "time"
+
+// Limits represents the hard limits and defaults applied on list endpoints.
+// - MaxPageSize: maximum page size accepted by Paginate.
+// - DefaultPageSize: page size used by Paginate when none is requested.
+// - MaxExportRows: maximum number of rows returned by a streamed export.
+// - AllEndpointCap: maximum number of rows returned by All.
+// - TimeBudget: time allowed to the count and preload phases of Paginate before partial results are returned.
+// - PreloadCost: maximum cost of the preloads of a page, the rows of the page times the preloaded relations
+// weighted by their depth. Paginate reduces the page size of the requests preloading deep or many associations
+// to stay within the cost and reports the reduced size as preload_limit in the response.
+// - PreloadDepth: maximum depth of the relations preloaded by the associations, join and fields queries, e.g. 2
+// for Customer.Address. associations=deep stops at this depth, deeper paths are rejected.
+// - PreloadRows: maximum number of rows loaded by the query of each preloaded relation, overridden by relation
+// path by the RESTPreloadLimits() map[string]int method of the model. The limit bounds the rows loaded for the
+// whole page rather than for each row, the responses whose preloads reach it are marked partial.
+// - MaxBodySize: maximum size in bytes of the request bodies.
+// - MaxArrayLength: maximum number of rows in the request bodies of the endpoints taking an array, e.g. Set.
+// - MaxJSONDepth: maximum nesting of the JSON request bodies.
+//
+// Zero means no limit, except for DefaultPageSize.
+type Limits struct {
+	MaxPageSize     int           `json:"max_page_size"`
+	DefaultPageSize int           `json:"default_page_size"`
+	MaxExportRows   int           `json:"max_export_rows"`
+	AllEndpointCap  int           `json:"all_endpoint_cap"`
+	TimeBudget      time.Duration `json:"time_budget"`
+	PreloadCost     int           `json:"preload_cost"`
+	PreloadDepth    int           `json:"preload_depth"`
+	PreloadRows     int           `json:"preload_rows"`
+	MaxBodySize     int64         `json:"max_body_size"`
+	MaxArrayLength  int           `json:"max_array_length"`
+	MaxJSONDepth    int           `json:"max_json_depth"`
+}
+
+// DefaultLimits holds the global limits applied to resources not overriding them.
+var DefaultLimits = Limits{
+	MaxPageSize:     100,
+	DefaultPageSize: 10,
+	MaxExportRows:   1000000,
+	AllEndpointCap:  10000,
+	PreloadCost:     1000,
+	PreloadDepth:    3,
+	MaxBodySize:     4 << 20,
+	MaxArrayLength:  1000,
+	MaxJSONDepth:    32,
+}
+
+// Override returns a copy of the limits where every non-zero field of o replaces the current value.
+func (l Limits) Override(o Limits) Limits {
+	if o.MaxPageSize != 0 {
+		l.MaxPageSize = o.MaxPageSize
+	}
+	if o.DefaultPageSize != 0 {
+		l.DefaultPageSize = o.DefaultPageSize
+	}
+	if o.MaxExportRows != 0 {
+		l.MaxExportRows = o.MaxExportRows
+	}
+	if o.AllEndpointCap != 0 {
+		l.AllEndpointCap = o.AllEndpointCap
+	}
+	if o.TimeBudget != 0 {
+		l.TimeBudget = o.TimeBudget
+	}
+	if o.PreloadCost != 0 {
+		l.PreloadCost = o.PreloadCost
+	}
+	if o.PreloadDepth != 0 {
+		l.PreloadDepth = o.PreloadDepth
+	}
+	if o.PreloadRows != 0 {
+		l.PreloadRows = o.PreloadRows
+	}
+	if o.MaxBodySize != 0 {
+		l.MaxBodySize = o.MaxBodySize
+	}
+	if o.MaxArrayLength != 0 {
+		l.MaxArrayLength = o.MaxArrayLength
+	}
+	if o.MaxJSONDepth != 0 {
+		l.MaxJSONDepth = o.MaxJSONDepth
+	}
+	return l
+}
+
+// PageSize returns the page size to use for the requested size.
+// Non-positive sizes fall back to DefaultPageSize and sizes above MaxPageSize are capped.
+func (l Limits) PageSize(size int) int {
+	if size <= 0 {
+		size = l.DefaultPageSize
+	}
+	if l.MaxPageSize > 0 && size > l.MaxPageSize {
+		size = l.MaxPageSize
+	}
+	return size
+}
+
+// PreloadPageSize returns the page size reduced so the page times the cost of its preloads stays within
+// PreloadCost, and whether it was reduced. Pages keep at least one row.
+func (l Limits) PreloadPageSize(size int, cost int) (int, bool) {
+	if l.PreloadCost <= 0 || cost <= 0 || size*cost <= l.PreloadCost {
+		return size, false
+	}
+	var limited = l.PreloadCost / cost
+	if limited < 1 {
+		limited = 1
+	}
+	return limited, true
+}
+
+// RowLimit returns the number of rows to fetch for the requested limit given the cap.
+// Non-positive limits fall back to the cap, a zero cap leaves the limit untouched.
+func RowLimit(limit int, cap int) int {
+	if cap > 0 && (limit <= 0 || limit > cap) {
+		return cap
+	}
+	return limit
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestLimitsPageSize(t *testing.T) {
+	var limits = Limits{MaxPageSize: 50, DefaultPageSize: 20}
+	tests := []struct {
+		size int
+		want int
+	}{
+		{0, 20},
+		{-1, 20},
+		{30, 30},
+		{50, 50},
+		{500, 50},
+	}
+	for _, test := range tests {
+		if got := limits.PageSize(test.size); got != test.want {
+			t.Errorf("PageSize(%d) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
+
+func TestLimitsOverride(t *testing.T) {
+	var limits = DefaultLimits.Override(Limits{MaxPageSize: 500, TimeBudget: time.Second})
+	if limits.MaxPageSize != 500 {
+		t.Errorf("MaxPageSize = %d, want 500", limits.MaxPageSize)
+	}
+	if limits.TimeBudget != time.Second {
+		t.Errorf("TimeBudget = %s, want 1s", limits.TimeBudget)
+	}
+	if limits.AllEndpointCap != DefaultLimits.AllEndpointCap {
+		t.Errorf("AllEndpointCap = %d, want %d", limits.AllEndpointCap, DefaultLimits.AllEndpointCap)
+	}
+}
+
+func TestRowLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		cap   int
+		want  int
+	}{
+		{0, 100, 100},
+		{50, 100, 50},
+		{500, 100, 100},
+		{0, 0, 0},
+		{500, 0, 500},
+	}
+	for _, test := range tests {
+		if got := RowLimit(test.limit, test.cap); got != test.want {
+			t.Errorf("RowLimit(%d, %d) = %d, want %d", test.limit, test.cap, got, test.want)
+		}
+	}
+}
+
+func TestLimitsPreloadPageSize(t *testing.T) {
+	var limits = Limits{PreloadCost: 100}
+	tests := []struct {
+		size    int
+		cost    int
+		want    int
+		limited bool
+	}{
+		{50, 0, 50, false},
+		{50, 2, 50, false},
+		{50, 3, 33, true},
+		{10, 1000, 1, true},
+		{10, 10, 10, false},
+	}
+	for _, test := range tests {
+		if got, limited := limits.PreloadPageSize(test.size, test.cost); got != test.want || limited != test.limited {
+			t.Errorf("PreloadPageSize(%d, %d) = %d, %v, want %d, %v", test.size, test.cost, got, limited, test.want, test.limited)
+		}
+	}
+	if got, limited := (Limits{}).PreloadPageSize(100, 50); got != 100 || limited {
+		t.Errorf("PreloadPageSize without cost limit = %d, %v", got, limited)
+	}
+}
+
+func TestPreloadCost(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		association string
+		join        string
+		want        int
+	}{
+		{"", "", 0},
+		{"true", "", 1},
+		{"deep", "", 1},
+		{"Company,Company.Owner", "", 3},
+		{"", "company.owner", 2},
+		{"1", "company", 2},
+	}
+	for _, test := range tests {
+		if got := preloadCost(test.association, test.join, s, DefaultLimits.PreloadDepth); got != test.want {
+			t.Errorf("preloadCost(%q, %q) = %d, want %d", test.association, test.join, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tlog "github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SQLLogConfig represents the SQL logging configuration of the rest package.
+// - Level: default verbosity (silent, error, warn, info).
+// - SlowThreshold: queries slower than this duration are logged as warnings, e.g. "200ms". Empty disables it.
+// - Resources: per-resource verbosity keyed by table name, overriding Level.
+type SQLLogConfig struct {
+	Level         string            `json:"level"`
+	SlowThreshold string            `json:"slow_threshold"`
+	Resources     map[string]string `json:"resources"`
+}
+
+// sqlLogging holds the parsed SQL logging configuration.
+var sqlLogging = struct {
+	sync.RWMutex
+	config    SQLLogConfig
+	level     logger.LogLevel
+	slow      time.Duration
+	resources map[string]logger.LogLevel
+}{
+	config:    SQLLogConfig{Level: "warn", SlowThreshold: "500ms"},
+	level:     logger.Warn,
+	slow:      500 * time.Millisecond,
+	resources: map[string]logger.LogLevel{},
+}
+
+// GetSQLLogConfig returns the current SQL logging configuration.
+func GetSQLLogConfig() SQLLogConfig {
+	sqlLogging.RLock()
+	defer sqlLogging.RUnlock()
+	return sqlLogging.config
+}
+
+// SetSQLLogConfig validates and applies the given SQL logging configuration.
+// It can be called at any time, the new configuration is used by subsequent queries.
+func SetSQLLogConfig(config SQLLogConfig) error {
+	level, err := parseLogLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	var slow time.Duration
+	if config.SlowThreshold != "" {
+		if slow, err = time.ParseDuration(config.SlowThreshold); err != nil {
+			return fmt.Errorf("invalid slow threshold %s", config.SlowThreshold)
+		}
+	}
+	var resources = map[string]logger.LogLevel{}
+	for resource, item := range config.Resources {
+		if resources[resource], err = parseLogLevel(item); err != nil {
+			return err
+		}
+	}
+
+	sqlLogging.Lock()
+	sqlLogging.config = config
+	sqlLogging.level = level
+	sqlLogging.slow = slow
+	sqlLogging.resources = resources
+	sqlLogging.Unlock()
+	return nil
+}
+
+// SetResourceLogLevel sets the SQL logging verbosity of a single resource.
+func SetResourceLogLevel(resource string, level string) error {
+	var config = GetSQLLogConfig()
+	var resources = map[string]string{}
+	for k, v := range config.Resources {
+		resources[k] = v
+	}
+	resources[resource] = level
+	config.Resources = resources
+	return SetSQLLogConfig(config)
+}
+
+// parseLogLevel converts the given level name to a gorm log level.
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "", "warn", "warning":
+		return logger.Warn, nil
+	case "info", "debug":
+		return logger.Info, nil
+	}
+	return 0, fmt.Errorf("invalid log level %s", level)
+}
+
+// SQLLogger is a gorm logger writing SQL statements through the logger carried by the statement context.
+// The verbosity and slow query threshold are read from the package SQL logging configuration.
+// Every statement of a Traced logger is logged, regardless of the verbosity.
+type SQLLogger struct {
+	Resource string
+	Traced   bool
+}
+
+// level returns the effective log level of the logger resource.
+func (l SQLLogger) level() (logger.LogLevel, time.Duration) {
+	sqlLogging.RLock()
+	defer sqlLogging.RUnlock()
+	if l.Traced {
+		return logger.Info, sqlLogging.slow
+	}
+	if v, ok := sqlLogging.resources[l.Resource]; ok {
+		return v, sqlLogging.slow
+	}
+	return sqlLogging.level, sqlLogging.slow
+}
+
+// LogMode is required by gorm logger interface; verbosity is controlled by SetSQLLogConfig.
+func (l SQLLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l SQLLogger) Info(ctx context.Context, message string, data ...interface{}) {
+	if level, _ := l.level(); level >= logger.Info {
+		tlog.FromContext(ctx).Info(fmt.Sprintf(message, data...), "resource", l.Resource)
+	}
+}
+
+func (l SQLLogger) Warn(ctx context.Context, message string, data ...interface{}) {
+	if level, _ := l.level(); level >= logger.Warn {
+		tlog.FromContext(ctx).Warning(fmt.Sprintf(message, data...), "resource", l.Resource)
+	}
+}
+
+func (l SQLLogger) Error(ctx context.Context, message string, data ...interface{}) {
+	if level, _ := l.level(); level >= logger.Error {
+		tlog.FromContext(ctx).Error(fmt.Sprintf(message, data...), "resource", l.Resource)
+	}
+}
+
+// Trace logs the executed statement according to the resource verbosity.
+// Failed statements are logged as errors, statements slower than the threshold as warnings.
+func (l SQLLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	level, slow := l.level()
+	if level <= logger.Silent {
+		return
+	}
+	var elapsed = time.Since(begin)
+	switch {
+	case err != nil && level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		tlog.FromContext(ctx).Error("sql error", "resource", l.Resource, "sql", sql, "rows", rows, "elapsed", elapsed.String(), "error", err.Error())
+	case slow > 0 && elapsed > slow && level >= logger.Warn:
+		sql, rows := fc()
+		tlog.FromContext(ctx).Warning("slow sql", "resource", l.Resource, "sql", sql, "rows", rows, "elapsed", elapsed.String())
+	case level >= logger.Info:
+		sql, rows := fc()
+		tlog.FromContext(ctx).Info("sql", "resource", l.Resource, "sql", sql, "rows", rows, "elapsed", elapsed.String())
+	}
+}
+
+// withLogger returns the session bound to a context carrying the logger, so SQLLogger writes the request fields.
+func withLogger(dbo *gorm.DB, l *tlog.Logger) *gorm.DB {
+	var ctx = dbo.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return dbo.WithContext(tlog.WithContext(ctx, l))
+}
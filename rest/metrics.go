@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/iesitalia/toolbox/observability"
+)
+
+var (
+	requestsTotal = observability.NewCounter("rest_requests_total",
+		"Requests to the rest endpoints by outcome.", "resource", "endpoint", "outcome")
+	requestDuration = observability.NewHistogram("rest_request_duration_seconds",
+		"Latency of the rest endpoints.", observability.DefaultBuckets, "resource", "endpoint")
+	rowsReturned = observability.NewCounter("rest_rows_returned_total",
+		"Rows returned by the rest endpoints.", "resource", "endpoint")
+	filterViewDuration = observability.NewHistogram("rest_filterview_duration_seconds",
+		"Latency of the filter view queries.", observability.DefaultBuckets, "table")
+	replicaFallbacks = observability.NewCounter("rest_replica_fallbacks_total",
+		"Requests read again from the primary after the read replica failed.", "resource")
+)
+
+// observe records the metrics of the request once handled.
+func (context *Context) observe(start time.Time) {
+	var resource, endpoint = context.Action.Resource.Table, context.Action.Name
+	var outcome = "success"
+	if !context.Response.Success {
+		outcome = "error"
+	}
+	requestsTotal.Inc(resource, endpoint, outcome)
+	requestDuration.ObserveSpan(time.Since(start).Seconds(), context.span, resource, endpoint)
+	if context.Response.Success {
+		if rows := countRows(context.Response.Data); rows > 0 {
+			rowsReturned.Add(float64(rows), resource, endpoint)
+		}
+	}
+}
+
+// countRows returns the number of rows in the response data: the length of a slice, or of the slice a pointer
+// points to, 1 for a struct or a map, 0 otherwise.
+func countRows(data interface{}) int {
+	var v = reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return v.Len()
+	case reflect.Struct, reflect.Map:
+		return 1
+	}
+	return 0
+}
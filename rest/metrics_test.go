@@ -0,0 +1,30 @@
+package rest
+
+import "testing"
+
+func TestCountRows(t *testing.T) {
+	type row struct{ ID int }
+	var rows = []row{{1}, {2}}
+	var none *[]row
+	var tests = []struct {
+		name string
+		data interface{}
+		want int
+	}{
+		{"slice", rows, 2},
+		{"pointer to slice", &rows, 2},
+		{"nil pointer", none, 0},
+		{"struct", row{1}, 1},
+		{"pointer to struct", &row{1}, 1},
+		{"map", map[string]interface{}{"count": 1}, 1},
+		{"number", 3, 0},
+		{"nil", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countRows(tt.data); got != tt.want {
+				t.Errorf("countRows() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package rest
+
+import "net/http"
+
+// Middleware wraps the handlers of endpoints, for cross-cutting concerns like logging, tenant scoping or
+// request shaping. It calls next to run the rest of the chain and the handler, or returns without calling it
+// to short-circuit the request; the error returned is the error of the request.
+//
+//	resource.Use(func(context *rest.Context, next func() error) error {
+//		var start = time.Now()
+//		var err = next()
+//		log.Info(context.Action.Name, time.Since(start))
+//		return err
+//	})
+type Middleware func(context *Context, next func() error) error
+
+// Use adds middlewares to every endpoint of the resource, including the endpoints registered later.
+// The middlewares of the resource run before those of the endpoints, in the order they are added.
+func (res *Resource) Use(middlewares ...Middleware) {
+	res.middlewares = append(res.middlewares, middlewares...)
+}
+
+// Use adds middlewares to the endpoint, run after those of its resource in the order they are added.
+func (action *Endpoint) Use(middlewares ...Middleware) *Endpoint {
+	action.middlewares = append(action.middlewares, middlewares...)
+	return action
+}
+
+// RequirePermission returns a middleware answering 401 to the anonymous users and 403 to the users without
+// the permission (APP.KEY), whether or not the resource checks permissions.
+func RequirePermission(permission string) Middleware {
+	return func(context *Context, next func() error) error {
+		var user = context.User()
+		if user.Anonymous() {
+			context.SetStatus(http.StatusUnauthorized)
+			return ErrorUnauthorized
+		}
+		if !user.HasPermission(permission) {
+			context.SetStatus(http.StatusForbidden)
+			return ErrorPermissionDenied
+		}
+		return next()
+	}
+}
+
+// handle runs the handler of the endpoint wrapped by the middlewares of its resource and its own.
+func (action *Endpoint) handle(context *Context) error {
+	var chain []Middleware
+	if action.Resource != nil {
+		chain = append(chain, action.Resource.middlewares...)
+	}
+	chain = append(chain, action.middlewares...)
+	var next func(i int) error
+	next = func(i int) error {
+		if i == len(chain) {
+			return action.Handler(context)
+		}
+		return chain[i](context, func() error {
+			return next(i + 1)
+		})
+	}
+	return next(0)
+}
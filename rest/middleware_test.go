@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEndpointHandle(t *testing.T) {
+	var calls []string
+	var trace = func(name string) Middleware {
+		return func(context *Context, next func() error) error {
+			calls = append(calls, name+" before")
+			var err = next()
+			calls = append(calls, name+" after")
+			return err
+		}
+	}
+	var resource = &Resource{}
+	var action = &Endpoint{Resource: resource, Handler: func(context *Context) error {
+		calls = append(calls, "handler")
+		return nil
+	}}
+	resource.Use(trace("resource"))
+	action.Use(trace("endpoint"))
+	if err := action.handle(&Context{}); err != nil {
+		t.Fatal(err)
+	}
+	var want = []string{"resource before", "endpoint before", "handler", "endpoint after", "resource after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	var denied = errors.New("denied")
+	resource.Use(func(context *Context, next func() error) error {
+		return denied
+	})
+	if err := action.handle(&Context{}); err != denied {
+		t.Errorf("handle error = %v, want %v", err, denied)
+	}
+	want = []string{"resource before", "resource after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
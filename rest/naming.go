@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/schema"
+)
+
+// NamingStrategy is the naming of the keys of the rows served by a resource. Resources choose it by
+// implementing RESTNaming() NamingStrategy on the model, or by the naming of their declaration.
+// Whatever the strategy, filters, fields, views and orders accept columns by database name, JSON name,
+// Go field name or their camelCase or snake_case form, see findField.
+// The rows sent to the create, update and set endpoints use the keys of the strategy as well.
+type NamingStrategy string
+
+const (
+	// NamingTags serves the keys given by the json tags of the model, as encoding/json does.
+	NamingTags NamingStrategy = ""
+	// NamingSnake serves snake_case keys, e.g. first_name.
+	NamingSnake NamingStrategy = "snake"
+	// NamingCamel serves camelCase keys, e.g. firstName.
+	NamingCamel NamingStrategy = "camel"
+)
+
+// DefaultNaming is the naming of the resources which do not choose one.
+var DefaultNaming = NamingTags
+
+// Name returns the key served for a key given by the json tags of the model.
+func (n NamingStrategy) Name(key string) string {
+	switch n {
+	case NamingSnake:
+		return strcase.ToSnake(key)
+	case NamingCamel:
+		return strcase.ToLowerCamel(key)
+	}
+	return key
+}
+
+// Key returns the key served for the field.
+func (n NamingStrategy) Key(field *schema.Field) string {
+	return n.Name(jsonName(field.Tag.Get("json"), field.Name))
+}
+
+// rename returns the generic JSON rows of the schema with their keys named by the strategy, along with the
+// keys of the rows of their loaded relations.
+func (n NamingStrategy) rename(s *schema.Schema, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = n.rename(s, v[i])
+		}
+		return v
+	case map[string]interface{}:
+		var relations = map[string]*schema.Schema{}
+		for _, relation := range s.Relationships.Relations {
+			relations[jsonName(relation.Field.Tag.Get("json"), relation.Field.Name)] = relation.FieldSchema
+		}
+		var result = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if target, ok := relations[key]; ok {
+				item = n.rename(target, item)
+			}
+			result[n.Name(key)] = item
+		}
+		return result
+	}
+	return value
+}
+
+// restore returns the generic JSON rows of the schema sent with the keys named by the strategy, along with the
+// rows of their relations, with the keys of the json tags of the model. Unknown keys are kept.
+func (n NamingStrategy) restore(s *schema.Schema, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = n.restore(s, v[i])
+		}
+		return v
+	case map[string]interface{}:
+		var keys = map[string]string{}
+		for _, field := range s.Fields {
+			var key = jsonName(field.Tag.Get("json"), field.Name)
+			keys[n.Name(key)] = key
+		}
+		var relations = map[string]*schema.Schema{}
+		for _, relation := range s.Relationships.Relations {
+			var key = jsonName(relation.Field.Tag.Get("json"), relation.Field.Name)
+			keys[n.Name(key)] = key
+			relations[key] = relation.FieldSchema
+		}
+		var result = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if original, ok := keys[key]; ok {
+				key = original
+			}
+			if target, ok := relations[key]; ok {
+				item = n.restore(target, item)
+			}
+			result[key] = item
+		}
+		return result
+	}
+	return value
+}
+
+// naming returns the naming strategy of the resource of the context.
+func (context *Context) naming() NamingStrategy {
+	if context.Action != nil && context.Action.Resource != nil && context.Action.Resource.Feature != nil {
+		return context.Action.Resource.Feature.Naming
+	}
+	return DefaultNaming
+}
+
+// withNaming returns the rows of the model in the response data with their keys named by the strategy of
+// the resource. Other data is returned as is.
+func (context *Context) withNaming(data interface{}, rows bool) interface{} {
+	var naming = context.naming()
+	if naming == NamingTags || !rows {
+		return data
+	}
+	if value := genericJSON(data); value != nil {
+		return naming.rename(context.Schema, value)
+	}
+	return data
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestNamingStrategyName(t *testing.T) {
+	var tests = []struct {
+		naming NamingStrategy
+		key    string
+		want   string
+	}{
+		{NamingTags, "firstName", "firstName"},
+		{NamingTags, "company_id", "company_id"},
+		{NamingSnake, "firstName", "first_name"},
+		{NamingSnake, "company_id", "company_id"},
+		{NamingCamel, "company_id", "companyId"},
+		{NamingCamel, "firstName", "firstName"},
+	}
+	for _, test := range tests {
+		if got := test.naming.Name(test.key); got != test.want {
+			t.Errorf("%q.Name(%q) = %q, want %q", test.naming, test.key, got, test.want)
+		}
+	}
+}
+
+func TestNamingStrategyRename(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows = []orderUser{{ID: 1, FirstName: "Ada", CompanyID: 2, Company: &orderCompany{ID: 2, Title: "Acme"}}}
+	var tests = []struct {
+		naming NamingStrategy
+		want   string
+	}{
+		{NamingSnake, `[{"company":{"id":2,"title":"Acme"},"company_id":2,"first_name":"Ada","id":1}]`},
+		{NamingCamel, `[{"company":{"id":2,"title":"Acme"},"companyId":2,"firstName":"Ada","id":1}]`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(test.naming.rename(s, genericJSON(rows)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.want {
+			t.Errorf("%q.rename = %s, want %s", test.naming, b, test.want)
+		}
+	}
+}
+
+func TestNamingStrategyRestore(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body = `{"firstName":"Ada","companyId":2,"company":{"id":2,"title":"Acme"},"unknownKey":1}`
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(NamingCamel.restore(s, value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `{"company":{"id":2,"title":"Acme"},"company_id":2,"firstName":"Ada","unknownKey":1}`
+	if string(b) != want {
+		t.Errorf("restore = %s, want %s", b, want)
+	}
+}
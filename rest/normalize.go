@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/iesitalia/toolbox"
+)
+
+// whitespaceRegex matches runs of whitespace characters.
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+// Normalizers holds the input normalizers usable in the `normalize` struct tag, keyed by name.
+// Custom normalizers can be added to the map.
+var Normalizers = map[string]func(s string) string{
+	"trim":     strings.TrimSpace,
+	"collapse": func(s string) string { return whitespaceRegex.ReplaceAllString(s, " ") },
+	"unicode":  toolbox.NormalizeUnicode,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"email":    func(s string) string { return strings.ToLower(strings.TrimSpace(s)) },
+	"slug":     toolbox.Slugify,
+}
+
+// DefaultNormalizers holds the normalizers applied to string fields without a `normalize` tag.
+// Use `normalize:"-"` to exclude a field.
+var DefaultNormalizers []string
+
+// NormalizeInput applies the normalizers declared in the `normalize` tag of each string field of the object, in order.
+// It is called by the rest handlers after parsing the request body and before Before* and Validate* hooks.
+//
+// Example:
+//
+//	type User struct {
+//	    Name  string `json:"name" normalize:"trim,collapse,unicode"`
+//	    Email string `json:"email" normalize:"email"`
+//	}
+func NormalizeInput(ptr interface{}) {
+	normalizeValue(reflect.Indirect(reflect.ValueOf(ptr)))
+}
+
+func normalizeValue(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	var typ = v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		var field = typ.Field(i)
+		var value = v.Field(i)
+		if field.Anonymous {
+			normalizeValue(reflect.Indirect(value))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		var ops = DefaultNormalizers
+		if tag, ok := field.Tag.Lookup("normalize"); ok {
+			if tag == "-" {
+				continue
+			}
+			ops = strings.Split(tag, ",")
+		}
+		if len(ops) == 0 {
+			continue
+		}
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.String || !value.CanSet() {
+			continue
+		}
+		value.SetString(Normalize(value.String(), ops...))
+	}
+}
+
+// Normalize applies the named normalizers to s, in order. Unknown names are ignored.
+func Normalize(s string, ops ...string) string {
+	for _, op := range ops {
+		if fn, ok := Normalizers[strings.TrimSpace(op)]; ok {
+			s = fn(s)
+		}
+	}
+	return s
+}
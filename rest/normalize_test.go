@@ -0,0 +1,39 @@
+package rest
+
+import "testing"
+
+type normalizeBase struct {
+	Code string `normalize:"trim,upper"`
+}
+
+type normalizeSample struct {
+	normalizeBase
+	Name      string  `normalize:"trim,collapse,unicode"`
+	Email     *string `normalize:"email"`
+	Raw       string  `normalize:"-"`
+	Untouched string
+}
+
+func TestNormalizeInput(t *testing.T) {
+	var email = "  John.Doe@Example.COM "
+	var sample = normalizeSample{
+		normalizeBase: normalizeBase{Code: " ab1 "},
+		Name:          "  John   “Jack”  Doe ",
+		Email:         &email,
+		Raw:           " raw ",
+		Untouched:     " untouched ",
+	}
+	NormalizeInput(&sample)
+	if sample.Code != "AB1" {
+		t.Errorf("Code = %q", sample.Code)
+	}
+	if sample.Name != `John "Jack" Doe` {
+		t.Errorf("Name = %q", sample.Name)
+	}
+	if *sample.Email != "john.doe@example.com" {
+		t.Errorf("Email = %q", *sample.Email)
+	}
+	if sample.Raw != " raw " || sample.Untouched != " untouched " {
+		t.Errorf("untagged fields changed: %q %q", sample.Raw, sample.Untouched)
+	}
+}
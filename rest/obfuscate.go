@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/iesitalia/toolbox/hashid"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/settings"
+)
+
+// IDSalt specifies the secret salt used to obfuscate primary keys of resources embedding ObfuscateID, the
+// REST.ID_SALT setting when empty. Each resource derives its own encoder from the salt and its table, so hashes
+// are not shared between resources. Without a salt anyone can encode and reverse the hashes, which is logged as
+// a warning.
+var IDSalt = ""
+
+// IDMinLength specifies the minimum length of obfuscated primary keys.
+var IDMinLength = 8
+
+// ObfuscateID is a marker type which, embedded in a model, makes the rest endpoints expose
+// keyed hashes instead of numeric primary keys, see hashid.Encoder. Hashes are accepted in primary key URLs,
+// and by the batch endpoints decoding their ids with Context.DecodeID.
+type ObfuscateID struct{}
+
+var encoders sync.Map
+
+// IDEncoder returns the primary key encoder of the resource.
+func (res *Resource) IDEncoder() *hashid.Encoder {
+	if v, ok := encoders.Load(res.Table); ok {
+		return v.(*hashid.Encoder)
+	}
+	var salt = IDSalt
+	if salt == "" {
+		salt = settings.Get("", "REST.ID_SALT").String()
+	}
+	if salt == "" {
+		logger.Warning("obfuscated ids of the resource use an empty salt, set REST.ID_SALT", "resource", res.Table)
+	}
+	v, _ := encoders.LoadOrStore(res.Table, hashid.New(salt+":"+res.Table, IDMinLength))
+	return v.(*hashid.Encoder)
+}
+
+// decodeID decodes an obfuscated primary key taken from the URL.
+func (res *Resource) decodeID(hash string) (uint64, error) {
+	return res.IDEncoder().Decode(hash)
+}
+
+// DecodeID returns the primary key of a row given by the client, e.g. in the body of a batch endpoint, decoded
+// from its hash when the resource obfuscates its ids. Other values than valid hashes return ErrorObjectNotExist
+// on such resources; the ids of the other resources are returned as is.
+func (context *Context) DecodeID(id interface{}) (interface{}, error) {
+	if context.Action == nil || context.Action.Resource == nil || context.Action.Resource.Feature == nil ||
+		!context.Action.Resource.Feature.ObfuscateID {
+		return id, nil
+	}
+	hash, ok := id.(string)
+	if !ok {
+		return nil, ErrorObjectNotExist
+	}
+	n, err := context.Action.Resource.decodeID(hash)
+	if err != nil {
+		return nil, ErrorObjectNotExist
+	}
+	return n, nil
+}
+
+// Output returns the data of the resource as served by its endpoints, with the primary keys obfuscated when
+// the resource enables ObfuscateID.
+func (res *Resource) Output(data interface{}) interface{} {
+	if res.Feature.ObfuscateID {
+		return res.obfuscateIDs(data)
+	}
+	return data
+}
+
+// obfuscateIDs returns the data with the integer primary keys of the resource replaced by their hashes.
+// Data is converted to its generic JSON representation, so it must be serializable.
+func (res *Resource) obfuscateIDs(data interface{}) interface{} {
+	var keys []string
+	for _, field := range res.Schema.PrimaryFields {
+		var name = strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		keys = append(keys, name)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	var decoder = json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return data
+	}
+
+	var encode = func(item interface{}) {
+		if object, ok := item.(map[string]interface{}); ok {
+			for _, key := range keys {
+				if n, ok := object[key].(json.Number); ok {
+					if v, err := n.Int64(); err == nil && v >= 0 {
+						object[key] = res.IDEncoder().Encode(uint64(v))
+					}
+				}
+			}
+		}
+	}
+	if list, ok := generic.([]interface{}); ok {
+		for _, item := range list {
+			encode(item)
+		}
+	} else {
+		encode(generic)
+	}
+	return generic
+}
@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Operator applies a filter condition of the list endpoints, e.g. column[near]=value, to the query.
+type Operator func(context *Context, query *gorm.DB, column string, value string) (*gorm.DB, error)
+
+var operators = map[string]Operator{}
+var operatorsMu sync.RWMutex
+
+// RegisterOperator adds a filter condition to the list endpoints. The column is checked to exist before the operator is called.
+func RegisterOperator(name string, fn Operator) {
+	operatorsMu.Lock()
+	operators[strings.ToLower(name)] = fn
+	operatorsMu.Unlock()
+}
+
+func getOperator(name string) (Operator, bool) {
+	operatorsMu.RLock()
+	defer operatorsMu.RUnlock()
+	fn, ok := operators[strings.ToLower(name)]
+	return fn, ok
+}
@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// orderItemRegex matches a single order item in the format "[relation.]column [asc|desc]".
+var orderItemRegex = regexp.MustCompile(`(?i)^\s*(?:([a-z0-9_\-]+)\.)?([a-z0-9_\-]+)(?:\s+(asc|desc))?\s*$`)
+
+// Order represents a validated order expression.
+// - Clause: the ORDER BY clause with quoted column names.
+// - Joins: the names of the relations which must be joined for the clause to be valid.
+// - Columns: the database columns of the schema itself the clause orders by.
+type Order struct {
+	Clause  string
+	Joins   []string
+	Columns []string
+}
+
+// ParseOrder validates a comma-separated order expression against the given schema.
+// Each item is a column optionally qualified by the table or a has-one/belongs-to relation name,
+// followed by an optional direction, e.g. "name desc,company.title". Columns may be given by
+// database name, JSON name or Go field name and are always mapped to their database column.
+// It returns ErrorColumnNotExist if a column cannot be resolved.
+func ParseOrder(order string, s *schema.Schema) (Order, error) {
+	var result Order
+	var items []string
+	for _, item := range strings.Split(order, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		var match = orderItemRegex.FindStringSubmatch(item)
+		if match == nil {
+			return Order{}, fmt.Errorf("invalid order %s", strings.TrimSpace(item))
+		}
+		var qualifier, column, direction = match[1], match[2], strings.ToUpper(match[3])
+		if direction == "" {
+			direction = "ASC"
+		}
+
+		var target = s
+		var table = s.Table
+		if qualifier != "" && qualifier != s.Table {
+			var relation = findRelation(s, qualifier)
+			if relation == nil {
+				return Order{}, ErrorColumnNotExist
+			}
+			target = relation.FieldSchema
+			table = relation.Name
+			var joined = false
+			for _, item := range result.Joins {
+				if item == relation.Name {
+					joined = true
+				}
+			}
+			if !joined {
+				result.Joins = append(result.Joins, relation.Name)
+			}
+		}
+
+		var field = findField(target, column)
+		if field == nil {
+			return Order{}, ErrorColumnNotExist
+		}
+		if target == s {
+			result.Columns = append(result.Columns, field.DBName)
+		}
+		items = append(items, "`"+table+"`.`"+field.DBName+"` "+direction)
+	}
+	result.Clause = strings.Join(items, ", ")
+	return result, nil
+}
+
+// findField looks up a field of the schema by database name, JSON name or Go field name, or by the camelCase
+// or snake_case form of either, so the keys served by every NamingStrategy resolve to their column.
+func findField(s *schema.Schema, name string) *schema.Field {
+	if field := s.LookUpField(name); field != nil && field.DBName != "" {
+		return field
+	}
+	return indexFields(s).lookup(s, name)
+}
+
+// findRelation looks up a has-one or belongs-to relation of the schema by name, JSON name or table name.
+// Has-many relations are not returned as ordering by them is ambiguous.
+func findRelation(s *schema.Schema, name string) *schema.Relationship {
+	var relations []*schema.Relationship
+	relations = append(relations, s.Relationships.HasOne...)
+	relations = append(relations, s.Relationships.BelongsTo...)
+	for _, relation := range relations {
+		var tag = strings.Split(relation.Field.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(relation.Name, name) || tag == name || relation.FieldSchema.Table == name {
+			return relation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type orderCompany struct {
+	ID    int    `gorm:"column:id;primaryKey" json:"id"`
+	Title string `gorm:"column:title" json:"title"`
+}
+
+func (orderCompany) TableName() string {
+	return "company"
+}
+
+type orderUser struct {
+	ID        int           `gorm:"column:id;primaryKey" json:"id"`
+	FirstName string        `gorm:"column:first_name" json:"firstName"`
+	CompanyID int           `gorm:"column:company_id" json:"company_id"`
+	Company   *orderCompany `gorm:"foreignKey:CompanyID" json:"company"`
+}
+
+func (orderUser) TableName() string {
+	return "user"
+}
+
+func TestParseOrder(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		order  string
+		clause string
+		joins  int
+		err    bool
+	}{
+		{"id desc", "`user`.`id` DESC", 0, false},
+		{"first_name", "`user`.`first_name` ASC", 0, false},
+		{"firstName asc, id desc", "`user`.`first_name` ASC, `user`.`id` DESC", 0, false},
+		{"companyId", "`user`.`company_id` ASC", 0, false},
+		{"FIRST_NAME", "`user`.`first_name` ASC", 0, false},
+		{"user.id asc", "`user`.`id` ASC", 0, false},
+		{"company.title desc", "`Company`.`title` DESC", 1, false},
+		{"missing desc", "", 0, true},
+		{"company.missing", "", 0, true},
+		{"id desc; drop table user", "", 0, true},
+	}
+	for _, test := range tests {
+		order, err := ParseOrder(test.order, s)
+		if (err != nil) != test.err {
+			t.Errorf("ParseOrder(%q) error = %v", test.order, err)
+			continue
+		}
+		if order.Clause != test.clause {
+			t.Errorf("ParseOrder(%q) = %q, want %q", test.order, order.Clause, test.clause)
+		}
+		if len(order.Joins) != test.joins {
+			t.Errorf("ParseOrder(%q) joins = %v", test.order, order.Joins)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"gorm.io/gorm"
+)
+
+// Policy represents a row-level security policy.
+// Scope receives every query issued by list, get, update and delete endpoints of the resource
+// and returns it restricted to the rows the request is allowed to access, e.g. by tenant or owner.
+//
+// A model may implement Policy itself, or policies can be attached to a resource using AddPolicy.
+type Policy interface {
+	Scope(context *Context, query *gorm.DB) *gorm.DB
+}
+
+// PolicyFunc is an adapter allowing the use of ordinary functions as policies.
+type PolicyFunc func(context *Context, query *gorm.DB) *gorm.DB
+
+// Scope calls f(context, query).
+func (f PolicyFunc) Scope(context *Context, query *gorm.DB) *gorm.DB {
+	return f(context, query)
+}
+
+// AddPolicy attaches row-level security policies to the resource.
+func (res *Resource) AddPolicy(policies ...Policy) {
+	res.Policies = append(res.Policies, policies...)
+}
+
+// ApplyPolicies restricts the query using the policy implemented by the model and the policies attached to the resource.
+func (context *Context) ApplyPolicies(query *gorm.DB) *gorm.DB {
+	if obj, ok := context.GetObject().Addr().Interface().(Policy); ok {
+		query = obj.Scope(context, query)
+	}
+	if context.Action != nil && context.Action.Resource != nil {
+		for _, policy := range context.Action.Resource.Policies {
+			query = policy.Scope(context, query)
+		}
+	}
+	return query
+}
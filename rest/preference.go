@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/clause"
+)
+
+// ViewPreference is the grid layout of a filter view saved by a user.
+// - Columns: keys of the visible columns in display order, columns not listed are hidden. Empty shows every column.
+// - Widths: column widths in pixels keyed by column key.
+// - PageSize: rows per page used when the request has no size parameter.
+// - Density: row density of the grid, e.g. compact, normal or comfortable.
+type ViewPreference struct {
+	User      string         `gorm:"column:user;size:36;primaryKey" json:"-"`
+	Resource  string         `gorm:"column:resource;size:64;primaryKey" json:"-"`
+	Columns   []string       `gorm:"column:columns;type:text;serializer:json" json:"columns"`
+	Widths    map[string]int `gorm:"column:widths;type:text;serializer:json" json:"widths"`
+	PageSize  int            `gorm:"column:page_size" json:"page_size"`
+	Density   string         `gorm:"column:density;size:16" json:"density"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName returns the name of the table for the ViewPreference struct.
+func (ViewPreference) TableName() string {
+	return "view_preference"
+}
+
+// GetViewPreference returns the preference of the user for the filter view of the resource, nil if none is saved.
+func GetViewPreference(user string, resource string) *ViewPreference {
+	var preference ViewPreference
+	if db.Where("`user` = ? AND resource = ?", user, resource).Take(&preference).RowsAffected == 0 {
+		return nil
+	}
+	return &preference
+}
+
+// ColumnKey returns the key identifying the column in the preferences, the Key field or derived from the database field or title.
+func (c FilterViewColumn) ColumnKey() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	if c.DBField != "" && c.DBField != "-" {
+		var field = c.DBField
+		if idx := strings.LastIndex(strings.ToLower(field), " as "); idx >= 0 {
+			field = field[idx+4:]
+		}
+		if idx := strings.LastIndex(field, "."); idx >= 0 {
+			field = field[idx+1:]
+		}
+		return strings.Trim(strings.TrimSpace(field), "`")
+	}
+	return strcase.ToSnake(c.Title)
+}
+
+// ApplyPreference orders the columns of the view, hides the ones not selected and sets their width and the density.
+func (v *FilterView) ApplyPreference(preference *ViewPreference) {
+	for idx := range v.Columns {
+		v.Columns[idx].Key = v.Columns[idx].ColumnKey()
+	}
+	if preference == nil {
+		return
+	}
+	v.Density = preference.Density
+	for idx := range v.Columns {
+		if width, ok := preference.Widths[v.Columns[idx].Key]; ok {
+			v.Columns[idx].Width = width
+		}
+	}
+	if len(preference.Columns) == 0 {
+		return
+	}
+	var columns = make([]FilterViewColumn, 0, len(v.Columns))
+	var used = make([]bool, len(v.Columns))
+	for _, key := range preference.Columns {
+		for idx, column := range v.Columns {
+			if !used[idx] && column.Key == key {
+				columns = append(columns, column)
+				used[idx] = true
+				break
+			}
+		}
+	}
+	for idx, column := range v.Columns {
+		if !used[idx] {
+			column.Hidden = true
+			columns = append(columns, column)
+		}
+	}
+	v.Columns = columns
+}
+
+// GetViewPreferenceHandler returns the filter view preference of the user of the request.
+func GetViewPreferenceHandler(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var user = context.User()
+	if user.Anonymous() {
+		return ErrorUnauthorized
+	}
+	var preference = GetViewPreference(user.UUID(), context.Action.Resource.Table)
+	if preference == nil {
+		preference = &ViewPreference{}
+	}
+	context.Response.Data = preference
+	return nil
+}
+
+// SaveViewPreferenceHandler saves the filter view preference of the user of the request.
+func SaveViewPreferenceHandler(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var user = context.User()
+	if user.Anonymous() {
+		return ErrorUnauthorized
+	}
+	var preference ViewPreference
+	if err := context.ParseBody(&preference); err != nil {
+		return err
+	}
+	preference.User = user.UUID()
+	preference.Resource = context.Action.Resource.Table
+	if preference.PageSize < 0 {
+		preference.PageSize = 0
+	}
+	var err = db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&preference).Error
+	if err != nil {
+		return err
+	}
+	context.Response.Data = preference
+	return nil
+}
+
+// ResetViewPreferenceHandler removes the filter view preference of the user of the request.
+func ResetViewPreferenceHandler(context *Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var user = context.User()
+	if user.Anonymous() {
+		return ErrorUnauthorized
+	}
+	return db.Where("`user` = ? AND resource = ?", user.UUID(), context.Action.Resource.Table).Delete(&ViewPreference{}).Error
+}
@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnKey(t *testing.T) {
+	var tests = []struct {
+		column FilterViewColumn
+		want   string
+	}{
+		{FilterViewColumn{Key: "custom", DBField: "name"}, "custom"},
+		{FilterViewColumn{DBField: "user.email"}, "email"},
+		{FilterViewColumn{DBField: "CONCAT(first, ' ', last) AS full_name"}, "full_name"},
+		{FilterViewColumn{DBField: "-", Title: "Row Actions"}, "row_actions"},
+	}
+	for _, tt := range tests {
+		if got := tt.column.ColumnKey(); got != tt.want {
+			t.Errorf("ColumnKey() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestApplyPreference(t *testing.T) {
+	var view = func() *FilterView {
+		return &FilterView{Columns: []FilterViewColumn{{DBField: "id"}, {DBField: "name"}, {DBField: "email"}}}
+	}
+	var tests = []struct {
+		name       string
+		preference *ViewPreference
+		keys       []string
+		hidden     []bool
+	}{
+		{"none", nil, []string{"id", "name", "email"}, []bool{false, false, false}},
+		{"all columns", &ViewPreference{}, []string{"id", "name", "email"}, []bool{false, false, false}},
+		{"reordered", &ViewPreference{Columns: []string{"email", "id"}}, []string{"email", "id", "name"}, []bool{false, false, true}},
+		{"unknown column", &ViewPreference{Columns: []string{"phone", "name"}}, []string{"name", "id", "email"}, []bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v = view()
+			v.ApplyPreference(tt.preference)
+			var keys []string
+			var hidden []bool
+			for _, column := range v.Columns {
+				keys = append(keys, column.Key)
+				hidden = append(hidden, column.Hidden)
+			}
+			if !reflect.DeepEqual(keys, tt.keys) || !reflect.DeepEqual(hidden, tt.hidden) {
+				t.Errorf("columns = %v %v, want %v %v", keys, hidden, tt.keys, tt.hidden)
+			}
+		})
+	}
+	var v = view()
+	v.ApplyPreference(&ViewPreference{Widths: map[string]int{"name": 240}, Density: "compact"})
+	if v.Columns[1].Width != 240 || v.Density != "compact" {
+		t.Errorf("width = %d, density = %q", v.Columns[1].Width, v.Density)
+	}
+}
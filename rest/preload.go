@@ -0,0 +1,331 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrorRelationNotExist is returned when the associations, join or fields queries name a relation the model
+// does not have.
+var ErrorRelationNotExist = errors.New("relation does not exists")
+
+// ErrorPreloadTooDeep is returned when the associations, join or fields queries name a relation deeper than the
+// PreloadDepth of the resource.
+var ErrorPreloadTooDeep = errors.New("relation is too deep to preload")
+
+// preload is a relation preloaded by a request:
+// - path: the Go field names of the relations leading to it, e.g. Customer.Address.
+// - columns: the columns selected on the related rows, every column when empty.
+// - limit: the maximum rows loaded by the preload query, zero for no limit.
+type preload struct {
+	path    string
+	columns []string
+	limit   int
+}
+
+// preloadPlan is the set of relations preloaded by a request, once each whatever the queries naming them.
+// keys are the columns of the model the preloaded rows are joined by.
+type preloadPlan struct {
+	schema   *schema.Schema
+	depth    int
+	preloads []*preload
+	index    map[string]*preload
+	keys     []string
+}
+
+// planPreloads returns the relations preloaded by the associations and join queries and by the relation fields
+// of the fields query, e.g. Customer.email:
+//   - associations=1 preloads the relations of the model, associations=deep the relations of the relations up to
+//     the PreloadDepth of the limits, skipping the relations leading back to a model of the path, and
+//     associations=Customer,Lines.Product the given relations, checked against the depth.
+//   - fields=number,Customer.email selects the email of the preloaded customers only, along with the keys joining
+//     the rows, and preloads the customers when not requested.
+//
+// The query of each relation loads up to the row limit given for its path, else the PreloadRows of the limits.
+// The limit applies to the rows of the relation of every row of the page together, see truncated.
+func planPreloads(s *schema.Schema, association string, join string, fields []string, limits Limits, rows map[string]int) (*preloadPlan, error) {
+	var plan = &preloadPlan{schema: s, depth: limits.PreloadDepth, index: map[string]*preload{}}
+	switch association {
+	case "":
+	case "1", "true":
+		for _, relation := range relations(s) {
+			plan.add(relation.Name)
+		}
+	case "deep":
+		for _, path := range deepPaths(s, limits.PreloadDepth) {
+			plan.add(path)
+		}
+	default:
+		for _, path := range strings.Split(association, ",") {
+			if _, err := plan.resolve(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if join != "" {
+		for _, path := range strings.Split(join, ",") {
+			if _, err := plan.resolve(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range fields {
+		var i = strings.LastIndex(name, ".")
+		if i < 0 {
+			continue
+		}
+		var target, err = plan.resolve(name[:i])
+		if err != nil {
+			return nil, err
+		}
+		var field = findField(target.schema, strings.TrimSpace(name[i+1:]))
+		if field == nil {
+			return nil, ErrorColumnNotExist
+		}
+		target.preload.columns = appendColumn(target.preload.columns, field.DBName)
+	}
+	plan.addKeys()
+	for _, item := range plan.preloads {
+		if limit, ok := rows[item.path]; ok {
+			item.limit = limit
+		} else {
+			item.limit = limits.PreloadRows
+		}
+	}
+	return plan, nil
+}
+
+// planTarget is a relation of a preload path along with the schema of its rows.
+type planTarget struct {
+	preload  *preload
+	relation *schema.Relationship
+	schema   *schema.Schema
+}
+
+// resolve adds the relation path, given by field or JSON names, to the plan and returns its last relation.
+func (plan *preloadPlan) resolve(path string) (*planTarget, error) {
+	var s = plan.schema
+	var names []string
+	var relation *schema.Relationship
+	for _, name := range strings.Split(strings.TrimSpace(path), ".") {
+		relation = lookupRelation(s, strings.TrimSpace(name))
+		if relation == nil {
+			return nil, ErrorRelationNotExist
+		}
+		names = append(names, relation.Name)
+		s = relation.FieldSchema
+	}
+	if plan.depth > 0 && len(names) > plan.depth {
+		return nil, ErrorPreloadTooDeep
+	}
+	return &planTarget{preload: plan.add(strings.Join(names, ".")), relation: relation, schema: s}, nil
+}
+
+// add adds the relation path to the plan, once.
+func (plan *preloadPlan) add(path string) *preload {
+	if item, ok := plan.index[path]; ok {
+		return item
+	}
+	var item = &preload{path: path}
+	plan.index[path] = item
+	plan.preloads = append(plan.preloads, item)
+	return item
+}
+
+// addKeys adds the keys joining the rows to the columns of the preloads selecting some columns only, and lists
+// the keys of the model the preloaded rows are joined by.
+func (plan *preloadPlan) addKeys() {
+	for _, item := range plan.preloads {
+		var s = plan.schema
+		var relation *schema.Relationship
+		for i, name := range strings.Split(item.path, ".") {
+			relation = s.Relationships.Relations[name]
+			if i == 0 {
+				plan.keys = appendKeys(plan.keys, s, relation)
+			}
+			s = relation.FieldSchema
+		}
+		if len(item.columns) == 0 {
+			continue
+		}
+		for _, field := range s.PrimaryFields {
+			item.columns = appendColumn(item.columns, field.DBName)
+		}
+		item.columns = appendKeys(item.columns, s, relation)
+		for _, child := range relations(s) {
+			item.columns = appendKeys(item.columns, s, child)
+		}
+	}
+}
+
+// appendKeys appends the columns of the schema the relation joins the rows by.
+func appendKeys(columns []string, s *schema.Schema, relation *schema.Relationship) []string {
+	for _, ref := range relation.References {
+		if ref.ForeignKey != nil && ref.ForeignKey.Schema == s && ref.ForeignKey.DBName != "" {
+			columns = appendColumn(columns, ref.ForeignKey.DBName)
+		}
+		if ref.PrimaryKey != nil && ref.PrimaryKey.Schema == s && ref.PrimaryKey.DBName != "" {
+			columns = appendColumn(columns, ref.PrimaryKey.DBName)
+		}
+	}
+	return columns
+}
+
+// appendColumn appends the column unless listed.
+func appendColumn(columns []string, column string) []string {
+	for _, item := range columns {
+		if item == column {
+			return columns
+		}
+	}
+	return append(columns, column)
+}
+
+// relations returns the relations of the schema in the order of their fields.
+func relations(s *schema.Schema) []*schema.Relationship {
+	var list []*schema.Relationship
+	for _, field := range s.Fields {
+		if relation, ok := s.Relationships.Relations[field.Name]; ok && relation.Field == field {
+			list = append(list, relation)
+		}
+	}
+	return list
+}
+
+// lookupRelation looks up a relation of the schema by field name, case-insensitively, or by JSON name.
+func lookupRelation(s *schema.Schema, name string) *schema.Relationship {
+	for _, relation := range relations(s) {
+		if relation.Name == name {
+			return relation
+		}
+	}
+	for _, relation := range relations(s) {
+		if strings.EqualFold(relation.Name, name) || jsonName(relation.Field.Tag.Get("json"), "") == name {
+			return relation
+		}
+	}
+	return nil
+}
+
+// deepPaths returns the paths of the has-one, belongs-to and has-many relations of the schema and of their
+// relations, up to depth, skipping the relations leading back to a table of the path. A non-positive depth
+// stops at the first table met twice only.
+func deepPaths(s *schema.Schema, depth int) []string {
+	var paths []string
+	var walk func(prefix string, s *schema.Schema, tables []string)
+	walk = func(prefix string, s *schema.Schema, tables []string) {
+		if depth > 0 && len(tables) > depth {
+			return
+		}
+		var related []*schema.Relationship
+		related = append(related, s.Relationships.HasOne...)
+		related = append(related, s.Relationships.BelongsTo...)
+		related = append(related, s.Relationships.HasMany...)
+	next:
+		for _, relation := range related {
+			for _, table := range tables {
+				if relation.FieldSchema.Table == table {
+					continue next
+				}
+			}
+			var path = strings.TrimPrefix(prefix+"."+relation.Name, ".")
+			paths = append(paths, path)
+			walk(path, relation.FieldSchema, append(tables[:len(tables):len(tables)], relation.FieldSchema.Table))
+		}
+	}
+	walk("", s, []string{s.Table})
+	return paths
+}
+
+// apply preloads the relations of the plan on the query.
+func (plan *preloadPlan) apply(query *gorm.DB) *gorm.DB {
+	for _, item := range plan.preloads {
+		if len(item.columns) == 0 && item.limit <= 0 {
+			query = query.Preload(item.path)
+			continue
+		}
+		var columns, limit = item.columns, item.limit
+		query = query.Preload(item.path, func(tx *gorm.DB) *gorm.DB {
+			if len(columns) > 0 {
+				tx = tx.Select(columns)
+			}
+			if limit > 0 {
+				tx = tx.Limit(limit)
+			}
+			return tx
+		})
+	}
+	return query
+}
+
+// truncated reports whether a preloaded relation of the rows may miss rows, as the rows loaded for it reached
+// its limit.
+func (plan *preloadPlan) truncated(rows reflect.Value) bool {
+	if plan == nil {
+		return false
+	}
+	for _, item := range plan.preloads {
+		if item.limit > 0 && preloaded(rows, strings.Split(item.path, ".")) >= item.limit {
+			return true
+		}
+	}
+	return false
+}
+
+// preloaded counts the rows loaded in the relation path of the rows, a row, a slice of rows or a pointer to
+// either.
+func preloaded(value reflect.Value, path []string) int {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		var n = 0
+		for i := 0; i < value.Len(); i++ {
+			n += preloaded(value.Index(i), path)
+		}
+		return n
+	case reflect.Struct:
+		if len(path) == 0 {
+			return 1
+		}
+		var field = value.FieldByName(path[0])
+		if !field.IsValid() || (field.Kind() == reflect.Ptr && field.IsNil()) {
+			return 0
+		}
+		if len(path) == 1 && field.Kind() == reflect.Struct && field.IsZero() {
+			return 0
+		}
+		return preloaded(field, path[1:])
+	}
+	return 0
+}
+
+// preloadLimits returns the row limits of the preloaded relations defined by the RESTPreloadLimits()
+// map[string]int method of the model, by relation path:
+//
+//	func (Customer) RESTPreloadLimits() map[string]int {
+//		return map[string]int{"Orders": 50, "Orders.Lines": 500}
+//	}
+func (context *Context) preloadLimits() map[string]int {
+	if obj, ok := context.GetObject().Addr().Interface().(interface{ RESTPreloadLimits() map[string]int }); ok {
+		return obj.RESTPreloadLimits()
+	}
+	return nil
+}
+
+// applyPreloads preloads the relations requested by the associations, join and fields queries, see planPreloads.
+func (context *Context) applyPreloads(query *gorm.DB) (*gorm.DB, *preloadPlan, error) {
+	var fields []string
+	if list := context.Request.Query("fields").String(); list != "" {
+		fields = strings.Split(list, ",")
+	}
+	plan, err := planPreloads(context.Schema, context.Request.Query("associations").String(), context.Request.Query("join").String(),
+		fields, context.Limits(), context.preloadLimits())
+	if err != nil {
+		return query, nil, err
+	}
+	return plan.apply(query), plan, nil
+}
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type preloadCustomer struct {
+	ID     int            `gorm:"column:id;primaryKey" json:"id"`
+	Email  string         `gorm:"column:email" json:"email"`
+	Name   string         `gorm:"column:name" json:"name"`
+	Orders []preloadOrder `gorm:"foreignKey:CustomerID" json:"orders"`
+}
+
+type preloadOrder struct {
+	ID         int              `gorm:"column:id;primaryKey" json:"id"`
+	Number     string           `gorm:"column:number" json:"number"`
+	CustomerID int              `gorm:"column:customer_id" json:"customer_id"`
+	Customer   *preloadCustomer `gorm:"foreignKey:CustomerID" json:"customer"`
+	Lines      []preloadLine    `gorm:"foreignKey:OrderID" json:"lines"`
+}
+
+type preloadLine struct {
+	ID        int             `gorm:"column:id;primaryKey" json:"id"`
+	OrderID   int             `gorm:"column:order_id" json:"order_id"`
+	ProductID int             `gorm:"column:product_id" json:"product_id"`
+	Product   *preloadProduct `gorm:"foreignKey:ProductID" json:"product"`
+}
+
+type preloadProduct struct {
+	ID    int    `gorm:"column:id;primaryKey" json:"id"`
+	Title string `gorm:"column:title" json:"title"`
+}
+
+func TestDeepPaths(t *testing.T) {
+	s, err := schema.Parse(&preloadOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		depth int
+		want  []string
+	}{
+		{1, []string{"Customer", "Lines"}},
+		{2, []string{"Customer", "Lines", "Lines.Product"}},
+		{0, []string{"Customer", "Lines", "Lines.Product"}},
+	}
+	for _, test := range tests {
+		if got := deepPaths(s, test.depth); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("deepPaths(%d) = %v, want %v", test.depth, got, test.want)
+		}
+	}
+}
+
+func TestPlanPreloads(t *testing.T) {
+	s, err := schema.Parse(&preloadOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var limits = Limits{PreloadDepth: 2, PreloadRows: 100}
+	var tests = []struct {
+		name        string
+		association string
+		join        string
+		fields      []string
+		rows        map[string]int
+		want        []preload
+		keys        []string
+		err         error
+	}{
+		{name: "none"},
+		{name: "direct", association: "1", want: []preload{{"Customer", nil, 100}, {"Lines", nil, 100}},
+			keys: []string{"customer_id", "id"}},
+		{name: "deep", association: "deep",
+			want: []preload{{"Customer", nil, 100}, {"Lines", nil, 100}, {"Lines.Product", nil, 100}},
+			keys: []string{"customer_id", "id"}},
+		{name: "dedup", association: "Customer,customer", join: "customer,lines.product",
+			want: []preload{{"Customer", nil, 100}, {"Lines.Product", nil, 100}}, keys: []string{"customer_id", "id"}},
+		{name: "fields", fields: []string{"number", "Customer.email"},
+			want: []preload{{"Customer", []string{"email", "id"}, 100}}, keys: []string{"customer_id"}},
+		{name: "nested fields", association: "Lines", fields: []string{"lines.product.title"},
+			want: []preload{{"Lines", nil, 100}, {"Lines.Product", []string{"title", "id"}, 100}}, keys: []string{"id"}},
+		{name: "row limits", association: "Lines", rows: map[string]int{"Lines": 5},
+			want: []preload{{"Lines", nil, 5}}, keys: []string{"id"}},
+		{name: "unknown", association: "Vendor", err: ErrorRelationNotExist},
+		{name: "unknown join", join: "customer.vendor", err: ErrorRelationNotExist},
+		{name: "unknown field", fields: []string{"Customer.phone"}, err: ErrorColumnNotExist},
+		{name: "too deep", association: "Customer.Orders.Lines", err: ErrorPreloadTooDeep},
+	}
+	for _, test := range tests {
+		plan, err := planPreloads(s, test.association, test.join, test.fields, limits, test.rows)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: planPreloads() error = %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var got []preload
+		for _, item := range plan.preloads {
+			got = append(got, *item)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: planPreloads() = %v, want %v", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(plan.keys, test.keys) {
+			t.Errorf("%s: keys = %v, want %v", test.name, plan.keys, test.keys)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var orders = []preloadOrder{
+		{ID: 1, Customer: &preloadCustomer{ID: 1}, Lines: []preloadLine{{ID: 1, Product: &preloadProduct{ID: 1}}, {ID: 2}}},
+		{ID: 2, Lines: []preloadLine{{ID: 3, Product: &preloadProduct{ID: 2}}}},
+	}
+	var tests = []struct {
+		path  string
+		limit int
+		want  bool
+	}{
+		{"Lines", 3, true},
+		{"Lines", 4, false},
+		{"Lines.Product", 2, true},
+		{"Lines.Product", 3, false},
+		{"Customer", 2, false},
+		{"Lines", 0, false},
+	}
+	for _, test := range tests {
+		var plan = &preloadPlan{preloads: []*preload{{path: test.path, limit: test.limit}}}
+		if got := plan.truncated(reflect.ValueOf(&orders)); got != test.want {
+			t.Errorf("truncated(%s, %d) = %v, want %v", test.path, test.limit, got, test.want)
+		}
+	}
+	if (*preloadPlan)(nil).truncated(reflect.ValueOf(orders)) {
+		t.Error("truncated() without preloads = true")
+	}
+}
@@ -0,0 +1,290 @@
+package rest
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrorProjectionNotExist is returned when rebuilding a table which is not a projection.
+var ErrorProjectionNotExist = errors.New("projection does not exists")
+
+// ProjectionBatchSize is the number of rows read and written at once by RebuildProjection.
+var ProjectionBatchSize = 1000
+
+// Projection is a denormalized read table built from source models, for list screens which would otherwise
+// join many tables on every request. Its rows are the rows returned by Query, stored in the table of Model.
+//
+// The projection is maintained incrementally: after every insert, update or delete of a source model, the rows
+// of the projection built from the written rows are selected again by Query and replaced, in the transaction
+// of the write. Writes without the primary keys of the rows, such as updates by condition, are not tracked;
+// RebuildProjection, or the POST /rest/:table/rebuild endpoint, rebuilds the whole table.
+//
+// The table of Model is migrated and exposed as a read-only rest resource.
+//
+//	rest.Project(rest.Projection{
+//	    Model: OrderRow{},
+//	    Key:   "orders.id",
+//	    Query: func(db *gorm.DB) *gorm.DB {
+//	        return db.Table("orders").
+//	            Select("orders.id, orders.total, customers.name AS customer, countries.name AS country").
+//	            Joins("JOIN customers ON customers.id = orders.customer_id").
+//	            Joins("JOIN countries ON countries.id = customers.country_id")
+//	    },
+//	    Sources: []rest.ProjectionSource{
+//	        {Model: Order{}},
+//	        {Model: Customer{}, Keys: func(db *gorm.DB, row interface{}) ([]interface{}, error) {
+//	            var ids []interface{}
+//	            return ids, db.Model(&Order{}).Where("customer_id = ?", row.(*Customer).ID).Pluck("id", &ids).Error
+//	        }},
+//	    },
+//	})
+type Projection struct {
+	// Model is the read model, whose table holds the projection. Its primary key is the key of the projection.
+	Model interface{}
+	// Key is the column of Query holding the key of the projection, e.g. "orders.id".
+	Key string
+	// Query returns the query selecting the rows of the projection, in the columns of Model.
+	Query func(db *gorm.DB) *gorm.DB
+	// Sources are the models whose writes update the projection.
+	Sources []ProjectionSource
+}
+
+// ProjectionSource is a model whose writes update a projection.
+type ProjectionSource struct {
+	Model interface{}
+	// Keys returns the keys of the projection rows built from the written row, a pointer to the model. The
+	// primary key of the row is the key of the projection when nil.
+	Keys func(db *gorm.DB, row interface{}) ([]interface{}, error)
+}
+
+type projectionSource struct {
+	projection *Projection
+	keys       func(db *gorm.DB, row interface{}) ([]interface{}, error)
+}
+
+// projections holds the projections by type of their model; tables and sources hold them by table once the rest
+// app is registered.
+var projections = struct {
+	sync.RWMutex
+	items   map[reflect.Type]*Projection
+	tables  map[string]*Projection
+	sources map[string][]projectionSource
+	// registered is set once the gorm callbacks are registered
+	registered bool
+}{items: map[reflect.Type]*Projection{}}
+
+// Project declares a projection. It must be called before the rest app is registered.
+func Project(projection Projection) {
+	projections.Lock()
+	defer projections.Unlock()
+	projections.items[getObject(projection.Model).Type()] = &projection
+}
+
+// isProjection reports whether the type is the model of a projection.
+func isProjection(t reflect.Type) bool {
+	projections.RLock()
+	defer projections.RUnlock()
+	_, ok := projections.items[t]
+	return ok
+}
+
+// findProjection returns the projection stored in the table, nil when there is none.
+func findProjection(table string) *Projection {
+	projections.RLock()
+	defer projections.RUnlock()
+	return projections.tables[table]
+}
+
+// useProjections registers the models of the projections and indexes them by table, then maintains them from
+// the writes of their sources.
+func useProjections() error {
+	projections.Lock()
+	defer projections.Unlock()
+	if len(projections.items) == 0 {
+		return nil
+	}
+	var dbo = evo.GetDBO()
+	projections.tables = map[string]*Projection{}
+	projections.sources = map[string][]projectionSource{}
+	for _, projection := range projections.items {
+		s, err := parseSchema(dbo, projection.Model)
+		if err != nil {
+			return err
+		}
+		if len(s.PrimaryFields) != 1 || projection.Key == "" || projection.Query == nil {
+			return fmt.Errorf("projection %s: a single primary key, a Key and a Query are required", s.Table)
+		}
+		db.UseModel(getObject(projection.Model).Interface())
+		projections.tables[s.Table] = projection
+		for _, source := range projection.Sources {
+			ss, err := parseSchema(dbo, source.Model)
+			if err != nil {
+				return err
+			}
+			projections.sources[ss.Table] = append(projections.sources[ss.Table], projectionSource{
+				projection: projection,
+				keys:       source.Keys,
+			})
+		}
+	}
+	if projections.registered {
+		return nil
+	}
+	projections.registered = true
+	if err := dbo.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("rest:projection:create", refreshProjections); err != nil {
+		return err
+	}
+	if err := dbo.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("rest:projection:update", refreshProjections); err != nil {
+		return err
+	}
+	return dbo.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("rest:projection:delete", refreshProjections)
+}
+
+// parseSchema returns the schema of the model.
+func parseSchema(dbo *gorm.DB, model interface{}) (*schema.Schema, error) {
+	var object = reflect.New(getObject(model).Type()).Interface()
+	var stmt = dbo.Model(object).Statement
+	if err := stmt.Parse(object); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// refreshProjections replaces the rows of the projections built from the rows written by the statement.
+func refreshProjections(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	projections.RLock()
+	var sources = projections.sources[db.Statement.Schema.Table]
+	projections.RUnlock()
+	if len(sources) == 0 {
+		return
+	}
+	var tx = db.Session(&gorm.Session{NewDB: true})
+	var rows = writtenRows(db.Statement.ReflectValue)
+	for _, source := range sources {
+		var keys []interface{}
+		for _, row := range rows {
+			if source.keys == nil {
+				keys = append(keys, primaryKeys(db.Statement.Schema, db.Statement.Context, row)...)
+				continue
+			}
+			list, err := source.keys(tx, row.Addr().Interface())
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			keys = append(keys, list...)
+		}
+		if err := source.projection.refresh(tx, keys); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
+
+// writtenRows returns the addressable struct rows of the reflect value of a statement.
+func writtenRows(value reflect.Value) []reflect.Value {
+	var rows []reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.CanAddr() {
+			rows = append(rows, value)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, writtenRows(reflect.Indirect(value.Index(i)))...)
+		}
+	}
+	return rows
+}
+
+// primaryKeys returns the value of the primary key of the row, none when it is zero.
+func primaryKeys(s *schema.Schema, ctx stdcontext.Context, row reflect.Value) []interface{} {
+	if s.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	v, zero := s.PrioritizedPrimaryField.ValueOf(ctx, row)
+	if zero {
+		return nil
+	}
+	return []interface{}{v}
+}
+
+// refresh replaces the rows of the projection of the keys.
+func (p *Projection) refresh(tx *gorm.DB, keys []interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	var rows = reflect.New(reflect.SliceOf(getObject(p.Model).Type()))
+	if err := p.Query(tx).Where(p.Key+" IN ?", keys).Find(rows.Interface()).Error; err != nil {
+		return err
+	}
+	var model = reflect.New(getObject(p.Model).Type()).Interface()
+	s, err := parseSchema(tx, p.Model)
+	if err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("`"+s.PrimaryFields[0].DBName+"` IN ?", keys).Delete(model).Error; err != nil {
+		return err
+	}
+	if rows.Elem().Len() == 0 {
+		return nil
+	}
+	return tx.Create(rows.Interface()).Error
+}
+
+// RebuildProjection rebuilds the whole projection stored in the table, returning the number of rows written.
+func RebuildProjection(dbo *gorm.DB, table string) (int64, error) {
+	var p = findProjection(table)
+	if p == nil {
+		return 0, ErrorProjectionNotExist
+	}
+	var typ = getObject(p.Model).Type()
+	var total int64
+	err := dbo.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(reflect.New(typ).Interface()).Error; err != nil {
+			return err
+		}
+		for offset := 0; ; offset += ProjectionBatchSize {
+			var rows = reflect.New(reflect.SliceOf(typ))
+			if err := p.Query(tx).Order(p.Key).Limit(ProjectionBatchSize).Offset(offset).Find(rows.Interface()).Error; err != nil {
+				return err
+			}
+			var n = rows.Elem().Len()
+			if n == 0 {
+				return nil
+			}
+			if err := tx.Create(rows.Interface()).Error; err != nil {
+				return err
+			}
+			total += int64(n)
+			if n < ProjectionBatchSize {
+				return nil
+			}
+		}
+	})
+	return total, err
+}
+
+// Rebuild rebuilds the projection of the resource, returning the number of rows written.
+func Rebuild(context *Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	total, err := RebuildProjection(context.GetDBO(), context.Action.Resource.Table)
+	if err != nil {
+		return err
+	}
+	context.Response.Data = total
+	return nil
+}
@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestProjectionKeys(t *testing.T) {
+	s, err := schema.Parse(&orderUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var one = orderUser{ID: 1}
+	var tests = []struct {
+		name  string
+		value reflect.Value
+		want  []interface{}
+	}{
+		{"struct", reflect.ValueOf(&one).Elem(), []interface{}{1}},
+		{"zero key", reflect.ValueOf(&orderUser{FirstName: "Ann"}).Elem(), nil},
+		{"slice", reflect.ValueOf([]orderUser{{ID: 2}, {}, {ID: 3}}), []interface{}{2, 3}},
+		{"pointers", reflect.ValueOf([]*orderUser{{ID: 4}}), []interface{}{4}},
+		{"not addressable", reflect.ValueOf(one), nil},
+	}
+	for _, test := range tests {
+		var keys []interface{}
+		for _, row := range writtenRows(test.value) {
+			keys = append(keys, primaryKeys(s, context.Background(), row)...)
+		}
+		if !reflect.DeepEqual(keys, test.want) {
+			t.Errorf("%s: keys = %v, want %v", test.name, keys, test.want)
+		}
+	}
+}
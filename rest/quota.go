@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrorRateLimited is returned when a caller exceeds the requests allowed by the quota of a resource.
+var ErrorRateLimited = errors.New("rate limit exceeded")
+
+// Quota grants the callers holding a permission of the resource their own limits and request rate.
+// - Permission: key of the permission of the resource, e.g. EXPORT, empty for every caller.
+// - Limits: limits overriding those of the resource, see Limits.Override.
+// - Requests: requests allowed to each caller in Window, zero means no rate limit.
+// - Window: period of the rate limit, defaults to a minute.
+//
+// Quotas are declared by implementing RESTQuotas() []Quota on the model, or with the quotas of a Declaration,
+// from the lowest to the highest tier: every quota the caller holds applies in order, so the later ones win.
+//
+//	func (Order) RESTQuotas() []rest.Quota {
+//		return []rest.Quota{
+//			{Requests: 60},
+//			{Permission: "EXPORT", Limits: rest.Limits{MaxExportRows: 5000000}, Requests: 600},
+//		}
+//	}
+//
+// Requests are counted per user, or per address for anonymous callers, on each instance.
+// The rate limit is reported with the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+type Quota struct {
+	Permission string        `json:"permission"`
+	Limits     Limits        `json:"limits"`
+	Requests   int           `json:"requests"`
+	Window     time.Duration `json:"window"`
+}
+
+// resolveQuota returns the limits and the request rate of a caller holding the permissions reported by has.
+func resolveQuota(quotas []Quota, limits Limits, has func(permission string) bool) Quota {
+	var result = Quota{Limits: limits}
+	for _, quota := range quotas {
+		if quota.Permission != "" && !has(quota.Permission) {
+			continue
+		}
+		result.Limits = result.Limits.Override(quota.Limits)
+		if quota.Requests > 0 {
+			result.Permission = quota.Permission
+			result.Requests = quota.Requests
+			result.Window = quota.Window
+		}
+	}
+	if result.Requests > 0 && result.Window <= 0 {
+		result.Window = time.Minute
+	}
+	return result
+}
+
+// Quota returns the quota of the caller, resolved through the permissions of the user on the resource.
+func (context *Context) Quota() Quota {
+	if context.quota == nil {
+		var quota = resolveQuota(context.Action.Resource.Feature.Quotas, context.Action.Resource.Feature.Limits, func(permission string) bool {
+			return context.HasPerm(permission) == nil
+		})
+		context.quota = &quota
+	}
+	return *context.quota
+}
+
+// Limits returns the limits applied to the caller, see Quota.
+func (context *Context) Limits() Limits {
+	return context.Quota().Limits
+}
+
+// checkRate counts the request against the rate limit of the caller and sets the RateLimit headers.
+func (context *Context) checkRate() error {
+	var quota = context.Quota()
+	if quota.Requests <= 0 {
+		return nil
+	}
+	var caller = context.User().UUID()
+	if context.User().Anonymous() {
+		caller = context.Request.IP()
+	}
+	var key = context.Action.Resource.Table + ":" + quota.Permission + ":" + caller
+	remaining, reset, ok := takeRate(key, quota.Requests, quota.Window, time.Now())
+	var seconds = strconv.Itoa(int(time.Until(reset).Seconds() + 0.5))
+	context.Request.SetHeader("RateLimit-Limit", strconv.Itoa(quota.Requests))
+	context.Request.SetHeader("RateLimit-Remaining", strconv.Itoa(remaining))
+	context.Request.SetHeader("RateLimit-Reset", seconds)
+	context.Request.SetHeader("RateLimit-Policy", fmt.Sprintf("%d;w=%d", quota.Requests, int(quota.Window.Seconds())))
+	if !ok {
+		context.Request.SetHeader("Retry-After", seconds)
+		context.SetStatus(http.StatusTooManyRequests)
+		return ErrorRateLimited
+	}
+	return nil
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+var rateWindows = map[string]*rateWindow{}
+var rateSweep time.Time
+var rateWindowsMu sync.Mutex
+
+// takeRate counts a request of the key in its fixed window. It returns the requests left in the window, the
+// time the window resets and false when the limit is exceeded.
+func takeRate(key string, limit int, window time.Duration, now time.Time) (int, time.Time, bool) {
+	rateWindowsMu.Lock()
+	defer rateWindowsMu.Unlock()
+	if now.Sub(rateSweep) > time.Minute {
+		for k, w := range rateWindows {
+			if !now.Before(w.reset) {
+				delete(rateWindows, k)
+			}
+		}
+		rateSweep = now
+	}
+	var w = rateWindows[key]
+	if w == nil || !now.Before(w.reset) {
+		w = &rateWindow{reset: now.Add(window)}
+		rateWindows[key] = w
+	}
+	if w.count >= limit {
+		return 0, w.reset, false
+	}
+	w.count++
+	return limit - w.count, w.reset, true
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveQuota(t *testing.T) {
+	var base = Limits{MaxPageSize: 100, MaxExportRows: 1000}
+	var quotas = []Quota{
+		{Requests: 60},
+		{Permission: "EXPORT", Limits: Limits{MaxExportRows: 100000}, Requests: 600, Window: time.Hour},
+		{Permission: "ADMIN", Limits: Limits{MaxPageSize: 1000}},
+	}
+	var tests = []struct {
+		name        string
+		permissions []string
+		pageSize    int
+		exportRows  int
+		requests    int
+		window      time.Duration
+	}{
+		{"no permission", nil, 100, 1000, 60, time.Minute},
+		{"export", []string{"EXPORT"}, 100, 100000, 600, time.Hour},
+		{"admin", []string{"ADMIN"}, 1000, 1000, 60, time.Minute},
+		{"export and admin", []string{"EXPORT", "ADMIN"}, 1000, 100000, 600, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var quota = resolveQuota(quotas, base, func(permission string) bool {
+				for _, p := range tt.permissions {
+					if p == permission {
+						return true
+					}
+				}
+				return false
+			})
+			if quota.Limits.MaxPageSize != tt.pageSize || quota.Limits.MaxExportRows != tt.exportRows ||
+				quota.Requests != tt.requests || quota.Window != tt.window {
+				t.Errorf("resolveQuota() = %+v", quota)
+			}
+		})
+	}
+	if quota := resolveQuota(nil, base, nil); quota.Limits != base || quota.Requests != 0 {
+		t.Errorf("resolveQuota(nil) = %+v, want the limits of the resource", quota)
+	}
+}
+
+func TestTakeRate(t *testing.T) {
+	var now = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	var steps = []struct {
+		at        time.Duration
+		remaining int
+		ok        bool
+	}{
+		{0, 1, true},
+		{time.Second, 0, true},
+		{2 * time.Second, 0, false},
+		{time.Minute, 1, true},
+	}
+	for i, step := range steps {
+		remaining, _, ok := takeRate("test:rate", 2, time.Minute, now.Add(step.at))
+		if remaining != step.remaining || ok != step.ok {
+			t.Errorf("step %d: takeRate() = %d %v, want %d %v", i, remaining, ok, step.remaining, step.ok)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/schema"
+)
+
+// MaxPooledRows is the capacity above which the slices the list endpoints load rows into are not reused, so
+// large pages and exports do not stay in memory.
+var MaxPooledRows = 1000
+
+// schemas caches the schema of the models by type, see modelSchema.
+var schemas sync.Map
+
+// fieldIndexes caches the field lookups of the schemas, see findField.
+var fieldIndexes sync.Map
+
+// slicePools pools the slices the list endpoints load rows into, by slice type.
+var slicePools sync.Map
+
+// modelSchema returns the schema of the model, parsed once per type.
+func modelSchema(model reflect.Value) (*schema.Schema, error) {
+	if s, ok := schemas.Load(model.Type()); ok {
+		return s.(*schema.Schema), nil
+	}
+	var stmt = evo.GetDBO().Model(model.Interface()).Statement
+	if err := stmt.Parse(model.Interface()); err != nil {
+		return nil, err
+	}
+	schemas.Store(model.Type(), stmt.Schema)
+	return stmt.Schema, nil
+}
+
+// fieldIndex maps the names findField accepts to the position of their field in the schema, the first field
+// wins when names collide.
+// - tags: JSON names.
+// - folded: Go field names, lower-cased.
+// - snakes: database names, and JSON names in snake_case.
+type fieldIndex struct {
+	tags   map[string]int
+	folded map[string]int
+	snakes map[string]int
+}
+
+// indexFields returns the field index of the schema, built once per schema.
+func indexFields(s *schema.Schema) *fieldIndex {
+	if index, ok := fieldIndexes.Load(s); ok {
+		return index.(*fieldIndex)
+	}
+	var index = &fieldIndex{tags: map[string]int{}, folded: map[string]int{}, snakes: map[string]int{}}
+	var add = func(m map[string]int, key string, i int) {
+		if _, ok := m[key]; !ok && key != "" {
+			m[key] = i
+		}
+	}
+	for i, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		var tag = strings.Split(field.Tag.Get("json"), ",")[0]
+		add(index.tags, tag, i)
+		add(index.folded, strings.ToLower(field.Name), i)
+		add(index.snakes, field.DBName, i)
+		if tag != "" {
+			add(index.snakes, strcase.ToSnake(tag), i)
+		}
+	}
+	fieldIndexes.Store(s, index)
+	return index
+}
+
+// lookup returns the first field of the schema whose JSON name is name or whose Go name matches it
+// case-insensitively, else the first field whose database or JSON name in snake_case matches it.
+func (index *fieldIndex) lookup(s *schema.Schema, name string) *schema.Field {
+	var tag, tagged = index.tags[name]
+	var folded, ok = index.folded[strings.ToLower(name)]
+	if tagged && (!ok || tag < folded) {
+		folded, ok = tag, true
+	}
+	if ok {
+		return s.Fields[folded]
+	}
+	if i, ok := index.snakes[strcase.ToSnake(name)]; ok {
+		return s.Fields[i]
+	}
+	return nil
+}
+
+// pooledSlice returns an empty slice of the type of the Object field in the Context, reused from the previous
+// requests and given back once the response is encoded, see releaseSlices.
+func (context *Context) pooledSlice() reflect.Value {
+	var typ = reflect.SliceOf(context.Object.Type())
+	pool, _ := slicePools.LoadOrStore(typ, &sync.Pool{New: func() interface{} {
+		return reflect.New(typ)
+	}})
+	var ptr = pool.(*sync.Pool).Get().(reflect.Value)
+	context.slices = append(context.slices, ptr)
+	return ptr.Elem()
+}
+
+// releaseSlices gives the slices of the request back to their pool, cleared so they do not keep the rows alive.
+// Slices above MaxPooledRows are left to the garbage collector. The rows are no longer valid afterwards, which
+// the OnResponse hooks keeping the response data must account for.
+func (context *Context) releaseSlices() {
+	for _, ptr := range context.slices {
+		var slice = ptr.Elem()
+		if slice.Cap() > MaxPooledRows {
+			continue
+		}
+		slice = slice.Slice(0, slice.Cap())
+		for i := 0; i < slice.Len(); i++ {
+			slice.Index(i).SetZero()
+		}
+		ptr.Elem().SetLen(0)
+		if pool, ok := slicePools.Load(slice.Type()); ok {
+			pool.(*sync.Pool).Put(ptr)
+		}
+	}
+	context.slices = nil
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type indexedModel struct {
+	ID        int    `gorm:"column:id;primaryKey" json:"id"`
+	FirstName string `gorm:"column:first_name" json:"firstName"`
+	Label     string `gorm:"column:title" json:"label"`
+	Title     string `gorm:"column:heading" json:"name"`
+	Ignored   string `gorm:"-" json:"ignored"`
+}
+
+func TestFindField(t *testing.T) {
+	s, err := schema.Parse(&indexedModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name string
+		want string
+	}{
+		{"first_name", "FirstName"},
+		{"FirstName", "FirstName"},
+		{"firstName", "FirstName"},
+		{"firstname", "FirstName"},
+		{"FIRST_NAME", "FirstName"},
+		{"title", "Label"},
+		{"label", "Label"},
+		{"Title", "Title"},
+		{"name", "Title"},
+		{"ignored", ""},
+		{"missing", ""},
+	}
+	for _, test := range tests {
+		var got string
+		if field := findField(s, test.name); field != nil {
+			got = field.Name
+		}
+		if got != test.want {
+			t.Errorf("findField(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPooledSlice(t *testing.T) {
+	var context = &Context{Object: reflect.ValueOf(indexedModel{})}
+	var slice = context.pooledSlice()
+	slice.Set(reflect.Append(slice, reflect.ValueOf(indexedModel{ID: 1, FirstName: "ada"})))
+	var backing = slice.Slice(0, slice.Cap())
+	context.releaseSlices()
+
+	if len(context.slices) != 0 {
+		t.Fatal("releaseSlices() kept the slices of the request")
+	}
+	if got := backing.Index(0).Interface().(indexedModel); got != (indexedModel{}) {
+		t.Errorf("released slice keeps the row %+v", got)
+	}
+
+	var max = MaxPooledRows
+	MaxPooledRows = 0
+	defer func() { MaxPooledRows = max }()
+	slice = context.pooledSlice()
+	if slice.Len() != 0 {
+		t.Errorf("pooledSlice() returned %d rows, want an empty slice", slice.Len())
+	}
+	slice.Set(reflect.Append(slice, reflect.ValueOf(indexedModel{ID: 2})))
+	backing = slice.Slice(0, slice.Cap())
+	context.releaseSlices()
+	if got := backing.Index(0).Interface().(indexedModel); got.ID != 2 {
+		t.Error("a slice above MaxPooledRows was cleared and pooled")
+	}
+}
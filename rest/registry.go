@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/outcome"
+)
+
+// registry holds the attached resources and the endpoints serving their routes. The router can not drop
+// routes, so each method and URI is routed once to a handler dispatching to the endpoint currently holding
+// it, which lets resources be deregistered and attached again while requests are served.
+type registry struct {
+	mu        sync.RWMutex
+	resources map[string]*Resource
+	endpoints map[string]*Endpoint
+	routed    map[string]bool
+}
+
+// resources holds the resources attached by name, and by type name for the declared ones.
+var resources = &registry{
+	resources: map[string]*Resource{},
+	endpoints: map[string]*Endpoint{},
+	routed:    map[string]bool{},
+}
+
+// get returns the resource of the name, nil when there is none.
+func (r *registry) get(name string) *Resource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resources[name]
+}
+
+// set stores the resource under the name.
+func (r *registry) set(name string, resource *Resource) {
+	r.mu.Lock()
+	r.resources[name] = resource
+	r.mu.Unlock()
+}
+
+// all returns a copy of the resources by name, safe to range over while resources are attached.
+func (r *registry) all() map[string]*Resource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result = make(map[string]*Resource, len(r.resources))
+	for name, resource := range r.resources {
+		result[name] = resource
+	}
+	return result
+}
+
+// reset removes the resources and their endpoints. The routes stay with the router and answer 404 until an
+// endpoint is mounted on them again.
+func (r *registry) reset() {
+	r.mu.Lock()
+	r.resources = map[string]*Resource{}
+	r.endpoints = map[string]*Endpoint{}
+	r.mu.Unlock()
+}
+
+// remove removes the resource, under all of its names, and the endpoints it still holds.
+func (r *registry) remove(resource *Resource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, item := range r.resources {
+		if item == resource {
+			delete(r.resources, name)
+		}
+	}
+	for key, action := range r.endpoints {
+		if action.Resource == resource {
+			delete(r.endpoints, key)
+		}
+	}
+}
+
+// mount serves the endpoint on its method and URI, in place of the endpoint serving them before.
+func (r *registry) mount(action *Endpoint) {
+	switch action.Method {
+	case GET, POST, PUT, PATCH, DELETE:
+	default:
+		panic("invalid method passed")
+	}
+	var key = string(action.Method) + " " + action.AbsoluteURI
+	r.mu.Lock()
+	r.endpoints[key] = action
+	var routed = r.routed[key]
+	r.routed[key] = true
+	r.mu.Unlock()
+	if routed {
+		return
+	}
+	var handler = func(request *evo.Request) interface{} {
+		return r.dispatch(key, request)
+	}
+	switch action.Method {
+	case GET:
+		evo.Get(action.AbsoluteURI, handler)
+	case POST:
+		evo.Post(action.AbsoluteURI, handler)
+	case PUT:
+		evo.Put(action.AbsoluteURI, handler)
+	case PATCH:
+		evo.Patch(action.AbsoluteURI, handler)
+	case DELETE:
+		evo.Delete(action.AbsoluteURI, handler)
+	}
+}
+
+// dispatch handles the request with the endpoint mounted on the route, 404 when it has none anymore.
+func (r *registry) dispatch(key string, request *evo.Request) interface{} {
+	r.mu.RLock()
+	var action = r.endpoints[key]
+	r.mu.RUnlock()
+	if action == nil {
+		return outcome.Json(&Pagination{Error: ErrorObjectNotExist.Error()}).Status(http.StatusNotFound)
+	}
+	return action.requestHandler(request)
+}
+
+// Deregister removes the resource of the model: its endpoints answer 404, it is no longer listed by Resources
+// and its cached schema and counts are dropped. Attaching the model again, e.g. once its schema changed, serves
+// the endpoints again. Requests already running complete with the removed resource.
+func Deregister(model interface{}) error {
+	resource, err := GetResource(model)
+	if err != nil {
+		return err
+	}
+	resource.deregister()
+	return nil
+}
+
+// deregister removes the resource from the registry and drops the caches of its model.
+func (res *Resource) deregister() {
+	resources.remove(res)
+	forgetSchema(res.Object.Type())
+	forgetCounts(res.Table)
+}
+
+// inherit carries over to the resource the settings made at runtime on the resource of the model attached
+// before: its policies and middlewares, the permissions set by SetPermission and the approval of changes.
+func (res *Resource) inherit(previous *Resource) {
+	res.Policies = append(res.Policies, previous.Policies...)
+	res.middlewares = append(res.middlewares, previous.middlewares...)
+	if previous.Feature.CheckPermission {
+		res.Permissions, res.Feature.CheckPermission = previous.Permissions, true
+	}
+	res.Feature.RequireApproval = res.Feature.RequireApproval || previous.Feature.RequireApproval
+}
+
+// forgetSchema drops the cached schema of the model type and its field index.
+func forgetSchema(t reflect.Type) {
+	if s, ok := schemas.LoadAndDelete(t); ok {
+		fieldIndexes.Delete(s)
+	}
+}
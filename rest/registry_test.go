@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func newTestRegistry() *registry {
+	return &registry{resources: map[string]*Resource{}, endpoints: map[string]*Endpoint{}, routed: map[string]bool{}}
+}
+
+func TestRegistryRemove(t *testing.T) {
+	var r = newTestRegistry()
+	var old = &Resource{Name: "models.Order", Table: "orders"}
+	var other = &Resource{Name: "models.Customer", Table: "customers"}
+	r.set(old.Name, old)
+	r.set("struct { ID int }", old)
+	r.set(other.Name, other)
+	r.endpoints["GET /admin/rest/orders/all"] = &Endpoint{Resource: old}
+	r.endpoints["GET /admin/rest/customers/all"] = &Endpoint{Resource: other}
+
+	r.remove(old)
+	if r.get(old.Name) != nil || r.get("struct { ID int }") != nil {
+		t.Error("removed resource still registered")
+	}
+	if r.get(other.Name) != other {
+		t.Error("other resource removed")
+	}
+	if _, ok := r.endpoints["GET /admin/rest/orders/all"]; ok {
+		t.Error("endpoint of the removed resource still mounted")
+	}
+	if _, ok := r.endpoints["GET /admin/rest/customers/all"]; !ok {
+		t.Error("endpoint of the other resource unmounted")
+	}
+}
+
+func TestRegistryAllIsCopy(t *testing.T) {
+	var r = newTestRegistry()
+	r.set("a", &Resource{Name: "a"})
+	var all = r.all()
+	delete(all, "a")
+	if r.get("a") == nil {
+		t.Error("all returned the registry map")
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	var r = newTestRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				var res = &Resource{Name: strconv.Itoa(i)}
+				r.set(res.Name, res)
+				r.remove(res)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for range r.all() {
+				}
+				r.get("0")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestForgetSchema(t *testing.T) {
+	s, err := schema.Parse(&indexedModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typ = reflect.TypeOf(indexedModel{})
+	schemas.Store(typ, s)
+	indexFields(s)
+	forgetSchema(typ)
+	if _, ok := schemas.Load(typ); ok {
+		t.Error("schema still cached")
+	}
+	if _, ok := fieldIndexes.Load(s); ok {
+		t.Error("field index still cached")
+	}
+}
@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/iesitalia/toolbox/migration"
+	"gorm.io/gorm/schema"
+)
+
+// ColumnRename represents a staged rename of a database column.
+// Until cutover both columns are written and reads fall back to the old column when the new one is empty,
+// so deployed clients keep working while the data is backfilled. After cutover only the new column is used.
+type ColumnRename struct {
+	Old     string
+	New     string
+	cutover atomic.Bool
+	// unset is the value rows hold in the new column when it is added: its default, the zero value of a
+	// NOT NULL column, or nil for NULL.
+	unset interface{}
+}
+
+// Cutover reports whether the rename has been completed.
+func (r *ColumnRename) Cutover() bool {
+	return r.cutover.Load()
+}
+
+// SetCutover flips the cutover flag of the rename.
+func (r *ColumnRename) SetCutover(v bool) {
+	r.cutover.Store(v)
+}
+
+// Backfill returns a data migration copying the old column into the new one for rows not yet migrated: the
+// rows whose new column is NULL or still holds the value the column was added with, e.g. the default of a
+// NOT NULL column.
+func (r *ColumnRename) Backfill(table string, version string) migration.DataMigration {
+	return migration.DataMigration{
+		Version:     version,
+		Key:         fmt.Sprintf("rename:%s.%s:%s", table, r.Old, r.New),
+		Description: fmt.Sprintf("backfill %s.%s from %s", table, r.New, r.Old),
+		Run: func(m *migration.Migrator) error {
+			var where = fmt.Sprintf("`%s` IS NULL", r.New)
+			var args []interface{}
+			if r.unset != nil {
+				where += fmt.Sprintf(" OR `%s` = ?", r.New)
+				args = append(args, r.unset)
+			}
+			var tx = m.DB.Exec(fmt.Sprintf("UPDATE `%s` SET `%s` = `%s` WHERE %s", table, r.New, r.Old, where), args...)
+			m.Progress(tx.RowsAffected)
+			return tx.Error
+		},
+	}
+}
+
+// RenameColumn declares a staged rename of the old column to the new one on the resource.
+// Both columns must exist in the model until cutover.
+func (res *Resource) RenameColumn(old string, new string) *ColumnRename {
+	var rename = &ColumnRename{Old: old, New: new}
+	if res.Schema != nil {
+		if field := res.Schema.LookUpField(new); field != nil {
+			if field.DefaultValueInterface != nil {
+				rename.unset = field.DefaultValueInterface
+			} else if field.NotNull {
+				rename.unset = reflect.Zero(field.IndirectFieldType).Interface()
+			}
+		}
+	}
+	res.Renames = append(res.Renames, rename)
+	return rename
+}
+
+// GetRename returns the rename declared for the given old or new column, or nil.
+func (res *Resource) GetRename(column string) *ColumnRename {
+	for _, item := range res.Renames {
+		if item.Old == column || item.New == column {
+			return item
+		}
+	}
+	return nil
+}
+
+// shadowWrite copies values between the old and new columns of renames not cut over yet, so both columns are
+// written. Previous is the row before an update, nil for new rows: the column changed by the update is copied
+// to the other, the new column winning when both changed, so the stored value of a column does not overwrite
+// the value a client wrote to the other one. Without change, or on new rows, the new column wins when set.
+func (context *Context) shadowWrite(value reflect.Value, previous interface{}) {
+	context.eachRename(value, func(old, new *schema.Field, oldValue, newValue interface{}, oldZero, newZero bool) {
+		if previous != nil {
+			var prev = reflect.ValueOf(previous)
+			prevOld, _ := old.ValueOf(context.ctx(), prev)
+			prevNew, _ := new.ValueOf(context.ctx(), prev)
+			var oldChanged, newChanged = !reflect.DeepEqual(oldValue, prevOld), !reflect.DeepEqual(newValue, prevNew)
+			if newChanged {
+				_ = old.Set(context.ctx(), value, newValue)
+				return
+			}
+			if oldChanged {
+				_ = new.Set(context.ctx(), value, oldValue)
+				return
+			}
+		}
+		if newZero && !oldZero {
+			_ = new.Set(context.ctx(), value, oldValue)
+		} else if !newZero {
+			_ = old.Set(context.ctx(), value, newValue)
+		}
+	})
+}
+
+// shadowRead fills the new column from the old one when it has not been backfilled yet.
+func (context *Context) shadowRead(value reflect.Value) {
+	context.eachRename(value, func(old, new *schema.Field, oldValue, newValue interface{}, oldZero, newZero bool) {
+		if newZero && !oldZero {
+			_ = new.Set(context.ctx(), value, oldValue)
+		}
+	})
+}
+
+// shadowColumns adds to the columns written by an update the other column of the renames not cut over yet,
+// so the shadow written by shadowWrite is stored along with the column.
+func (context *Context) shadowColumns(columns []string) []string {
+	if context.Action == nil || context.Action.Resource == nil {
+		return columns
+	}
+	for _, column := range columns {
+		var rename = context.Action.Resource.GetRename(column)
+		if rename == nil || rename.Cutover() {
+			continue
+		}
+		for _, shadow := range []string{rename.Old, rename.New} {
+			if field := context.Schema.LookUpField(shadow); field != nil {
+				columns = appendColumn(columns, field.DBName)
+			}
+		}
+	}
+	return columns
+}
+
+// eachRename calls fn for every rename of the resource which has not been cut over.
+func (context *Context) eachRename(value reflect.Value, fn func(old, new *schema.Field, oldValue, newValue interface{}, oldZero, newZero bool)) {
+	if context.Action == nil || context.Action.Resource == nil || len(context.Action.Resource.Renames) == 0 {
+		return
+	}
+	value = reflect.Indirect(value)
+	var ctx = context.ctx()
+	for _, rename := range context.Action.Resource.Renames {
+		if rename.Cutover() {
+			continue
+		}
+		var old, new = context.Schema.LookUpField(rename.Old), context.Schema.LookUpField(rename.New)
+		if old == nil || new == nil {
+			continue
+		}
+		oldValue, oldZero := old.ValueOf(ctx, value)
+		newValue, newZero := new.ValueOf(ctx, value)
+		fn(old, new, oldValue, newValue, oldZero, newZero)
+	}
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type renamedModel struct {
+	ID    int    `gorm:"column:id;primaryKey" json:"id"`
+	Name  string `gorm:"column:name" json:"name"`
+	Title string `gorm:"column:title" json:"title"`
+	Note  string `gorm:"column:note" json:"note"`
+}
+
+// renameContext returns a context of a resource renaming the name column to title.
+func renameContext(t *testing.T) (*Context, *ColumnRename) {
+	t.Helper()
+	s, err := schema.Parse(&renamedModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resource = &Resource{Schema: s, Object: reflect.ValueOf(renamedModel{})}
+	var rename = resource.RenameColumn("name", "title")
+	return &Context{Action: &Endpoint{Resource: resource}, Schema: s, Object: resource.Object}, rename
+}
+
+func TestShadowColumns(t *testing.T) {
+	var context, rename = renameContext(t)
+	var tests = []struct {
+		columns []string
+		want    []string
+	}{
+		{[]string{"name"}, []string{"name", "title"}},
+		{[]string{"title", "updated_at"}, []string{"title", "updated_at", "name"}},
+		{[]string{"note"}, []string{"note"}},
+	}
+	for _, tt := range tests {
+		if got := context.shadowColumns(tt.columns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shadowColumns(%v) = %v, want %v", tt.columns, got, tt.want)
+		}
+	}
+	rename.SetCutover(true)
+	if got := context.shadowColumns([]string{"title"}); !reflect.DeepEqual(got, []string{"title"}) {
+		t.Errorf("shadowColumns() after cutover = %v, want [title]", got)
+	}
+}
+
+func TestShadowWrite(t *testing.T) {
+	var context, _ = renameContext(t)
+	var tests = []struct {
+		name     string
+		previous *renamedModel
+		value    renamedModel
+		want     string
+	}{
+		{"create old", nil, renamedModel{Name: "legacy"}, "legacy"},
+		{"create both", nil, renamedModel{Name: "legacy", Title: "current"}, "current"},
+		{"update old", &renamedModel{Name: "a", Title: "a"}, renamedModel{Name: "b", Title: "a"}, "b"},
+		{"update new", &renamedModel{Name: "a", Title: "a"}, renamedModel{Name: "a", Title: "b"}, "b"},
+		{"update both", &renamedModel{Name: "a", Title: "a"}, renamedModel{Name: "b", Title: "c"}, "c"},
+		{"update other", &renamedModel{Name: "a", Title: "b"}, renamedModel{Name: "a", Title: "b", Note: "x"}, "b"},
+	}
+	for _, tt := range tests {
+		var value = reflect.ValueOf(&tt.value).Elem()
+		var previous interface{}
+		if tt.previous != nil {
+			previous = tt.previous
+		}
+		context.shadowWrite(value, previous)
+		if tt.value.Name != tt.want || tt.value.Title != tt.want {
+			t.Errorf("%s: got name %q title %q, want %q", tt.name, tt.value.Name, tt.value.Title, tt.want)
+		}
+	}
+}
+
+func TestRenameUnset(t *testing.T) {
+	type model struct {
+		ID       int `gorm:"primaryKey"`
+		Name     string
+		Title    string `gorm:"not null"`
+		Label    string `gorm:"default:none"`
+		Subtitle *string
+	}
+	s, err := schema.Parse(&model{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resource = &Resource{Schema: s}
+	var tests = []struct {
+		column string
+		want   interface{}
+	}{
+		{"title", ""},
+		{"label", "none"},
+		{"subtitle", nil},
+	}
+	for _, tt := range tests {
+		if got := resource.RenameColumn("name", tt.column).unset; got != tt.want {
+			t.Errorf("RenameColumn(name, %s) unset = %#v, want %#v", tt.column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	stdcontext "context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// PrimaryReads is a marker type which, embedded in a model, keeps the reads of its resource on the primary
+// database when a read replica is set, e.g. for rows read back right after being written.
+type PrimaryReads struct{}
+
+// ReplicaRetryInterval is the time the reads stay on the primary database after the read replica failed.
+var ReplicaRetryInterval = 30 * time.Second
+
+// replicaActions are the endpoints whose queries are routed to the read replica.
+var replicaActions = map[string]bool{"ALL": true, "PAGINATE": true, "GET": true, "FILTERVIEW": true}
+
+var replica struct {
+	mu        sync.RWMutex
+	db        *gorm.DB
+	downUntil time.Time
+}
+
+// replicaReads records whether the replica failed during the queries bound to it, see bind, so the reads of
+// a request or of a filter view run again on the primary only when the replica failed under them.
+type replicaReads struct {
+	failed atomic.Bool
+}
+
+type replicaReadsKey struct{}
+
+// bind returns the database with the queries reporting the failures of the replica to the reads.
+func (r *replicaReads) bind(dbo *gorm.DB) *gorm.DB {
+	return dbo.WithContext(stdcontext.WithValue(dbo.Statement.Context, replicaReadsKey{}, r))
+}
+
+// OpenReplica connects to the MySQL read replica of the DSN, see SetReplica. The connection is made by the
+// first query, so a replica unavailable at startup leaves the reads on the primary until it is reachable.
+func OpenReplica(dsn string) error {
+	var config = &gorm.Config{DisableAutomaticPing: true}
+	if primary := evo.GetDBO(); primary != nil {
+		config.Logger = primary.Logger
+	}
+	dbo, err := gorm.Open(mysql.Open(dsn), config)
+	if err != nil {
+		return err
+	}
+	return SetReplica(dbo)
+}
+
+// SetReplica routes the queries of the ALL, PAGINATE, GET and filter view endpoints to the read replica, unless
+// the model embeds PrimaryReads, while writes stay on the primary. Nil routes every query to the primary.
+// A query failing on the replica for a connection error is run again on the primary, and the reads stay on
+// the primary for ReplicaRetryInterval. The callbacks registered with toolbox.Callbacks, e.g. translating the
+// rows, are installed on the replica.
+func SetReplica(dbo *gorm.DB) error {
+	if dbo != nil {
+		if err := dbo.Callback().Query().After("*").Register("rest:replica", replicaCallback); err != nil {
+			return err
+		}
+		if err := dbo.Callback().Row().After("*").Register("rest:replica", replicaCallback); err != nil {
+			return err
+		}
+		if err := dbo.Callback().Raw().After("*").Register("rest:replica", replicaCallback); err != nil {
+			return err
+		}
+		if err := toolbox.AddReplica(dbo); err != nil {
+			return err
+		}
+	}
+	replica.mu.Lock()
+	replica.db = dbo
+	replica.downUntil = time.Time{}
+	replica.mu.Unlock()
+	return nil
+}
+
+// replicaCallback leaves the replica out when a query fails for a connection error, and reports the failure to
+// the reads of the query.
+func replicaCallback(db *gorm.DB) {
+	if !replicaError(db.Error) {
+		return
+	}
+	failReplica()
+	if reads, ok := db.Statement.Context.Value(replicaReadsKey{}).(*replicaReads); ok {
+		reads.failed.Store(true)
+	}
+}
+
+// Replica returns the read replica, nil when none is set or while it is left out after a failure.
+func Replica() *gorm.DB {
+	replica.mu.RLock()
+	defer replica.mu.RUnlock()
+	if replica.db == nil || time.Now().Before(replica.downUntil) {
+		return nil
+	}
+	return replica.db
+}
+
+// failReplica leaves the replica out for ReplicaRetryInterval.
+func failReplica() {
+	replica.mu.Lock()
+	replica.downUntil = time.Now().Add(ReplicaRetryInterval)
+	replica.mu.Unlock()
+}
+
+// replicaError reports whether the error is a failure of the connection to the replica rather than of the query.
+func replicaError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) ||
+		err.Error() == "invalid connection"
+}
+
+// readReplica returns the read replica the queries of the request are routed to, nil for the primary.
+func (context *Context) readReplica() *gorm.DB {
+	if context.primary || context.Action == nil || !replicaActions[context.Action.Name] {
+		return nil
+	}
+	if resource := context.Action.Resource; resource != nil && resource.Feature != nil && resource.Feature.PrimaryReads {
+		return nil
+	}
+	return Replica()
+}
+
+// run runs the handler of the action. When the replica failed under the queries of the handler, the handler
+// runs again with its queries on the primary.
+func (context *Context) run() error {
+	var err = context.Action.handle(context)
+	if context.replica == nil || context.streamed || !context.replica.failed.Load() {
+		return err
+	}
+	replicaFallbacks.Inc(context.Action.Resource.Table)
+	context.Logger().Warning("read replica failed, reading from the primary")
+	context.replica, context.primary = nil, true
+	context.Response, context.row, context.view = newResponse(), nil, nil
+	return context.Action.handle(context)
+}
+
+// readDB returns the database the filter view of the model reads from, along with the reads reporting the
+// failures of the replica, nil for the primary.
+func (v *FilterView) readDB(name string) (*gorm.DB, *replicaReads) {
+	if resource := resources.get(name); resource != nil && resource.Feature != nil && resource.Feature.PrimaryReads {
+		return evo.GetDBO(), nil
+	}
+	if dbo := Replica(); dbo != nil {
+		var reads = &replicaReads{}
+		return reads.bind(dbo), reads
+	}
+	return evo.GetDBO(), nil
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type replicaModel struct {
+	ID int `gorm:"column:id;primaryKey"`
+	API
+	PrimaryReads
+}
+
+func TestPrimaryReadsFeature(t *testing.T) {
+	if !GetFeatures(replicaModel{}).PrimaryReads {
+		t.Error("PrimaryReads is not detected")
+	}
+	if GetFeatures(orderUser{}).PrimaryReads {
+		t.Error("PrimaryReads is detected on a model without the marker")
+	}
+}
+
+func TestReplicaError(t *testing.T) {
+	var tests = map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":         {nil, false},
+		"not found":   {gorm.ErrRecordNotFound, false},
+		"query":       {errors.New("Error 1054: Unknown column 'x'"), false},
+		"bad conn":    {fmt.Errorf("query: %w", driver.ErrBadConn), true},
+		"network":     {&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		"invalid con": {errors.New("invalid connection"), true},
+	}
+	for name, test := range tests {
+		if got := replicaError(test.err); got != test.want {
+			t.Errorf("%s: replicaError() = %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	var dbo = &gorm.DB{}
+	replica.mu.Lock()
+	replica.db, replica.downUntil = dbo, time.Time{}
+	replica.mu.Unlock()
+	defer func() {
+		replica.mu.Lock()
+		replica.db, replica.downUntil = nil, time.Time{}
+		replica.mu.Unlock()
+	}()
+
+	var resource = &Resource{Table: "orders", Feature: &Feature{}}
+	var pinned = &Resource{Table: "ledger", Feature: &Feature{PrimaryReads: true}}
+	var tests = []struct {
+		action   string
+		resource *Resource
+		primary  bool
+		want     bool
+	}{
+		{"PAGINATE", resource, false, true},
+		{"ALL", resource, false, true},
+		{"GET", resource, false, true},
+		{"FILTERVIEW", resource, false, true},
+		{"UPDATE", resource, false, false},
+		{"CREATE", resource, false, false},
+		{"PAGINATE", pinned, false, false},
+		{"PAGINATE", resource, true, false},
+	}
+	for _, test := range tests {
+		var context = &Context{Action: &Endpoint{Name: test.action, Resource: test.resource}, primary: test.primary}
+		if got := context.readReplica() != nil; got != test.want {
+			t.Errorf("%s %s (primary %v): replica = %v, want %v", test.action, test.resource.Table, test.primary, got, test.want)
+		}
+	}
+
+	failReplica()
+	if Replica() != nil {
+		t.Error("Replica() returned the replica after a failure")
+	}
+	replica.mu.Lock()
+	replica.downUntil = time.Now().Add(-time.Second)
+	replica.mu.Unlock()
+	if Replica() != dbo {
+		t.Error("Replica() did not return the replica after ReplicaRetryInterval")
+	}
+}
+
+func TestReplicaCallback(t *testing.T) {
+	defer func() {
+		replica.mu.Lock()
+		replica.downUntil = time.Time{}
+		replica.mu.Unlock()
+	}()
+	var failed, other = &replicaReads{}, &replicaReads{}
+	var ctx = context.WithValue(context.Background(), replicaReadsKey{}, failed)
+	replicaCallback(&gorm.DB{Error: driver.ErrBadConn, Statement: &gorm.Statement{Context: ctx}})
+	if !failed.failed.Load() {
+		t.Error("the failure of the replica was not reported to the reads of the query")
+	}
+	if other.failed.Load() {
+		t.Error("the failure of the replica was reported to unrelated reads")
+	}
+}
@@ -1,20 +1,27 @@
 package rest
 
 import (
+	stdcontext "context"
 	"fmt"
-	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/generic"
 	"github.com/getevo/evo/v2/lib/log"
 	"github.com/iesitalia/toolbox/acl"
-	"gorm.io/gorm/clause"
+	"github.com/iesitalia/toolbox/contract"
+	"github.com/iesitalia/toolbox/i18n"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/metering"
+	"github.com/iesitalia/toolbox/settings"
+	"github.com/iesitalia/toolbox/tracing"
+	"net/http"
 	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/getevo/evo/v2"
 	"github.com/iancoleman/strcase"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 
 	scm "github.com/getevo/evo/v2/lib/db/schema"
 	"github.com/getevo/evo/v2/lib/outcome"
@@ -28,6 +35,7 @@ const (
 	POST   Method = "POST"
 	PUT    Method = "PUT"
 	PUSH   Method = "PUSH"
+	PATCH  Method = "PATCH"
 	DELETE Method = "DELETE"
 )
 
@@ -62,33 +70,58 @@ var (
 // Method represents an HTTP request method.
 type Method string
 
-// resources is a map that holds a collection of *Resource objects.
-var resources map[string]*Resource
-
 // Context represents the context of an HTTP request.
 // It contains information about the request, the object being processed,
 // the sample data, the action to be performed, the response, and the schema.
 type Context struct {
-	Request  *evo.Request
-	Object   reflect.Value
-	Sample   interface{}
-	Action   *Endpoint
-	Response *Pagination
-	Schema   *schema.Schema
+	Request      *evo.Request
+	Object       reflect.Value
+	Sample       interface{}
+	Action       *Endpoint
+	Response     *Pagination
+	Schema       *schema.Schema
+	streamed     bool
+	tenant       *string
+	status       int
+	impersonated evo.UserInterface
+	user         evo.UserInterface
+	locale       *string
+	logger       *logger.Logger
+	span         *tracing.Span
+	quota        *Quota
+	view         *View
+	err          error
+	row          interface{}
+	// preloads are the relations preloaded by the filters of the request, see ApplyFilters.
+	preloads *preloadPlan
+	// replica is set once the queries of the request ran on the read replica, primary once they must not.
+	replica *replicaReads
+	primary bool
+	// slices are the pooled slices of the request, see pooledSlice.
+	slices     []reflect.Value
+	idempotent *idempotentRequest
 }
 
 // Pagination represents the pagination metadata and data for a response.
+// PreloadLimit is the page size applied when the requested preloads exceed the PreloadCost of the resource.
+// Estimated is set when the total is estimated, and HasNext replaces the total when the rows are not counted,
+// see Count. Partial is set when the time budget ran out or a preloaded relation reached its row limit, see
+// Limits.
 type Pagination struct {
-	Total      int64       `json:"total"`
-	Offset     int         `json:"offset"`
-	TotalPages int         `json:"total_pages"`
-	Page       int         `json:"current_page"`
-	Size       int         `json:"size"`
-	Data       interface{} `json:"data"`
-	Success    bool        `json:"success"`
-	Error      string      `json:"error"`
-	Type       string      `json:"type"`
-	FilterView *FilterView `json:"filter_view"`
+	Total        int64       `json:"total"`
+	Offset       int         `json:"offset"`
+	TotalPages   int         `json:"total_pages"`
+	Page         int         `json:"current_page"`
+	Size         int         `json:"size"`
+	Data         interface{} `json:"data"`
+	Success      bool        `json:"success"`
+	Error        string      `json:"error"`
+	Type         string      `json:"type"`
+	FilterView   *FilterView `json:"filter_view"`
+	Partial      bool        `json:"partial"`
+	PreloadLimit int         `json:"preload_limit,omitempty"`
+	Estimated    bool        `json:"estimated,omitempty"`
+	HasNext      *bool       `json:"has_next,omitempty"`
 }
 
 // Endpoint represents an API endpoint with specific properties and behaviors.
@@ -120,38 +153,82 @@ type Endpoint struct {
 	Handler     func(context *Context) error `json:"-"`
 	Resource    *Resource                    `json:"-"`
 	URLParams   []Filter                     `json:"-"`
+	Flag        string                       `json:"-"`
+	middlewares []Middleware
 }
 
 // Resource represents a resource in an API.
 // It holds information about the object, actions, path, schema, table, name, model, JavaScript model,
 // and parameters of the resource.
 type Resource struct {
-	Object      reflect.Value  `json:"-"`
-	Actions     []*Endpoint    `json:"actions"`
-	Path        string         `json:"-"`
-	Schema      *schema.Schema `json:"-"`
-	Table       string         `json:"table"`
-	Name        string         `json:"model"`
-	Model       *scm.Model     `json:"-"`
-	JSModel     string         `json:"js_model"`
-	Params      []Param        `json:"params"`
-	Feature     *Feature       `json:"feature"`
-	Permissions acl.App        `json:"permissions"`
+	Object      reflect.Value   `json:"-"`
+	Actions     []*Endpoint     `json:"actions"`
+	Path        string          `json:"-"`
+	Schema      *schema.Schema  `json:"-"`
+	Table       string          `json:"table"`
+	Name        string          `json:"model"`
+	Model       *scm.Model      `json:"-"`
+	JSModel     string          `json:"js_model"`
+	Params      []Param         `json:"params"`
+	Feature     *Feature        `json:"feature"`
+	Permissions acl.App         `json:"permissions"`
+	Renames     []*ColumnRename `json:"-"`
+	Policies    []Policy        `json:"-"`
+	middlewares []Middleware
+	accessors   Accessors
 }
 
 // GetResource retrieves a Resource object based on the provided input. It checks if a Resource with the same type already exists in the resources map and returns it if found. Otherwise
 func GetResource(input interface{}) (*Resource, error) {
-	if v, ok := resources[getObject(input).Type().String()]; ok {
+	if v := resources.get(getObject(input).Type().String()); v != nil {
 		return v, nil
 	}
 	return nil, ErrorObjectNotExist
 }
 
-// AttachResource creates a new Resource object using the provided model and adds it to the resources map.
+// Resources returns the resources exposed through the API, once each, sorted by table.
+func Resources() []*Resource {
+	var result []*Resource
+	var seen = map[string]bool{}
+	for _, resource := range resources.all() {
+		if !resource.Feature.EnableAPI || seen[resource.Table] {
+			continue
+		}
+		seen[resource.Table] = true
+		result = append(result, resource)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+// NewContext returns a context of the resource for a request of an endpoint spanning many resources, see Trash.
+// Name is the name of the action of the context.
+func (res *Resource) NewContext(request *evo.Request, name string) *Context {
+	return &Context{
+		Request:  request,
+		Action:   &Endpoint{Name: name, Resource: res, Object: res.Object},
+		Object:   res.Object,
+		Schema:   res.Schema,
+		Response: &Pagination{},
+	}
+}
+
+// Readable reports whether the user of the context can view the rows of the resource: the view endpoints
+// are enabled, the feature flag of the resource is on and the user has the VIEW permission.
+func (context *Context) Readable() bool {
+	return !context.Action.Resource.Feature.DisableView && context.flagged() && context.HasPerm("VIEW") == nil
+}
+
+// AttachResource creates a new Resource object using the provided model and adds it to the resources registry.
+// A model attached again, e.g. once its schema changed at runtime, replaces the resource attached before and
+// keeps its policies, middlewares, permissions and approval of changes, see Deregister.
 // It also defines a series of actions on the resource:
 // - ORM: Creates an endpoint for the ORM SDK
 // - ALL: Returns all objects in one call
 // - PAGINATE: Paginates objects
+// - STREAM: Streams the changes of the objects
 // - GET: Returns a single object using its primary key
 // - CREATE: Creates an object using given values
 // - FIND: Searches for object(s) by given criteria
@@ -161,6 +238,8 @@ func GetResource(input interface{}) (*Resource, error) {
 // - UPDATE.PUT: Batch updates objects
 // - UPDATE.POST: Updates a single object using its primary key
 // - DELETE: Deletes an existing object using its primary key
+// Models, or types embedded in them, can register additional endpoints by implementing RESTActions() []*Endpoint,
+// matched before the built-in endpoints.
 // The function then adds parameters to the resource based on the fields in the model's schema.
 func AttachResource(model *scm.Model) *Resource {
 	var feature = GetFeatures(model.Sample)
@@ -175,10 +254,36 @@ func AttachResource(model *scm.Model) *Resource {
 	}
 	resource.Schema = model.Schema
 	resource.Path = model.Table
-	resources[model.Name] = &resource
+	resource.bindAccessors()
+	if existing := resources.get(model.Name); existing != nil {
+		existing.deregister()
+		resource.inherit(existing)
+	}
+	resources.set(model.Name, &resource)
+	if feature.Permission != "" {
+		resource.Use(RequirePermission(feature.Permission))
+	}
+	if obj, ok := model.Sample.(interface{ ColumnRenames() map[string]string }); ok {
+		for old, new := range obj.ColumnRenames() {
+			resource.RenameColumn(old, new)
+		}
+	}
 	if !feature.EnableAPI {
 		return &resource
 	}
+	// registered first so the routes of the built-in endpoints, e.g. POST /:id, do not match their urls
+	if obj, ok := reflect.New(resource.Object.Type()).Interface().(interface{ RESTActions() []*Endpoint }); ok {
+		for _, action := range obj.RESTActions() {
+			resource.Action(action)
+		}
+	}
+
+	// registered before GET so the primary key url does not match the pending changes
+	if feature.RequireApproval && (!feature.DisableUpdate || !feature.DisableDelete) {
+		for _, action := range approvalEndpoints() {
+			resource.Action(action)
+		}
+	}
 	resource.Action(&Endpoint{
 		Name:        "MODEL INFO",
 		Method:      GET,
@@ -189,6 +294,28 @@ func AttachResource(model *scm.Model) *Resource {
 	if !feature.DisableView {
 		if v, ok := resource.Object.Interface().(interface{ FilterView() FilterView }); ok {
 			if !feature.DisableView {
+				// registered first so the url params of the filter view do not match them
+				resource.Action(&Endpoint{
+					Name:        "FILTER VIEW PREFERENCE",
+					Method:      GET,
+					URL:         "/filter-view/preference",
+					Handler:     GetViewPreferenceHandler,
+					Description: "return the filter view layout saved by the user",
+				})
+				resource.Action(&Endpoint{
+					Name:        "SAVE FILTER VIEW PREFERENCE",
+					Method:      PUT,
+					URL:         "/filter-view/preference",
+					Handler:     SaveViewPreferenceHandler,
+					Description: "save the filter view layout of the user",
+				})
+				resource.Action(&Endpoint{
+					Name:        "RESET FILTER VIEW PREFERENCE",
+					Method:      DELETE,
+					URL:         "/filter-view/preference",
+					Handler:     ResetViewPreferenceHandler,
+					Description: "remove the filter view layout saved by the user",
+				})
 				resource.Action(&Endpoint{
 					Name:        "FILTER VIEW",
 					Method:      GET,
@@ -202,6 +329,16 @@ func AttachResource(model *scm.Model) *Resource {
 			}
 		}
 
+		// registered before GET so the primary key url does not match it
+		resource.Action(&Endpoint{
+			Name:        "STREAM",
+			Method:      GET,
+			URL:         "/stream",
+			Handler:     StreamChanges,
+			Description: "stream the changes of the objects as server-sent events",
+			Permissions: []acl.Permission{ListPermission},
+		})
+
 		resource.Action(&Endpoint{
 			Name:        "ALL",
 			Method:      GET,
@@ -220,6 +357,15 @@ func AttachResource(model *scm.Model) *Resource {
 			Permissions: []acl.Permission{ListPermission},
 		})
 
+		resource.Action(&Endpoint{
+			Name:        "QUERY BY EXAMPLE",
+			Method:      POST,
+			URL:         "/query-by-example",
+			Handler:     QueryByExample,
+			Description: "paginate objects matching the non-zero fields of the given object",
+			Permissions: []acl.Permission{ListPermission},
+		})
+
 		resource.Action(&Endpoint{
 			Name:        "GET",
 			Method:      GET,
@@ -236,6 +382,7 @@ func AttachResource(model *scm.Model) *Resource {
 			Method:      PUT,
 			URL:         "/",
 			Handler:     Create,
+			middlewares: []Middleware{Idempotency(0)},
 			Description: "create an object using given values",
 			Permissions: []acl.Permission{CreatePermission},
 		})
@@ -251,6 +398,54 @@ func AttachResource(model *scm.Model) *Resource {
 			Permissions: []acl.Permission{UpdatePermission, SelfUpdatePermission},
 		})
 	}
+	var pk = primaryKeyURL(model.Schema)
+	if _, ok := resource.Object.Interface().(interface{ FilterView() FilterView }); ok && !feature.DisableUpdate && pk != "" {
+		resource.Action(&Endpoint{
+			Name:        "UPDATE FIELD",
+			Method:      PATCH,
+			URL:         pk + "/field/:column",
+			Handler:     UpdateField,
+			Description: "update a single editable column of the object selected using primary key",
+			Permissions: []acl.Permission{UpdatePermission},
+		})
+	}
+	if !feature.DisableDelete && deletedAtField(model.Schema, reflect.New(resource.Object.Type()).Interface()) != nil {
+		for _, action := range trashEndpoints() {
+			resource.Action(action)
+		}
+	}
+	if pk != "" {
+		if !feature.DisableView {
+			resource.Action(&Endpoint{
+				Name:        "HISTORY",
+				Method:      GET,
+				URL:         pk + "/history",
+				Handler:     History,
+				Description: "return the changes of the object selected using primary key",
+				Permissions: []acl.Permission{ListPermission},
+			})
+		}
+		if !feature.DisableCreate {
+			resource.Action(&Endpoint{
+				Name:        "CLONE",
+				Method:      POST,
+				URL:         pk + "/clone",
+				Handler:     Clone,
+				Description: "copy the object selected using primary key, along with the has-many children listed by ?children=",
+				Permissions: []acl.Permission{CreatePermission},
+			})
+		}
+		if !feature.DisableUpdate {
+			resource.Action(&Endpoint{
+				Name:        "REVERT TO",
+				Method:      POST,
+				URL:         pk + "/revert_to/:audit",
+				Handler:     RevertTo,
+				Description: "restore the object selected using primary key as it was after a change of its history",
+				Permissions: []acl.Permission{UpdatePermission},
+			})
+		}
+	}
 	if !feature.DisableDelete {
 		resource.Action(&Endpoint{
 			Name:        "DELETE",
@@ -263,26 +458,35 @@ func AttachResource(model *scm.Model) *Resource {
 		})
 	}
 
-	if feature.EnableSetAPI {
-		var key = ""
-		for _, field := range model.Schema.Fields {
-			if _, ok := field.TagSettings["SET_KEY"]; ok {
-				key = field.DBName
-				break
-			}
-		}
-		if key == "" {
+	if feature.EnableSetAPI && !feature.RequireApproval {
+		var keys = setKeys(model.Schema)
+		if len(keys) == 0 {
 			log.Fatalf("object " + model.Name + " has rest.EnableSetAPI set to true, but no SET_KEY tag is found in the model definition.")
 		}
+		var url string
+		for _, field := range keys {
+			url += "/:" + field.DBName
+		}
 		resource.Action(&Endpoint{
 			Name:        "SET",
 			Method:      PUT,
-			URL:         "/:" + key + "/set",
+			URL:         url + "/set",
 			Handler:     Set,
+			middlewares: []Middleware{Idempotency(0)},
 			Description: "set multiple values base on set_key at once",
 			Permissions: []acl.Permission{UpdatePermission},
 		})
 	}
+	if findProjection(model.Table) != nil {
+		resource.Action(&Endpoint{
+			Name:        "REBUILD",
+			Method:      POST,
+			URL:         "/rebuild",
+			Handler:     Rebuild,
+			Description: "rebuild the projection from its sources",
+			Permissions: []acl.Permission{UpdatePermission},
+		})
+	}
 
 	for _, field := range model.Schema.Fields {
 		resource.Params = append(resource.Params, Param{
@@ -296,9 +500,41 @@ func AttachResource(model *scm.Model) *Resource {
 }
 
 // GetFeatures represents the features of a resource
+// Limits default to DefaultLimits and can be overridden by implementing RESTLimits() Limits on the model.
+// Resources are served only to the callers a feature flag is on for by implementing RESTFlag() string on the model.
+// Quotas per permission are declared by implementing RESTQuotas() []Quota on the model.
+// Duplicate create detection is enabled by implementing DedupWindow() time.Duration on the model.
+// The naming of the keys of the rows is chosen by implementing RESTNaming() NamingStrategy on the model.
+// The envelope of the responses is chosen by implementing RESTEnvelope() Envelope on the model.
+// The counting of the rows by Paginate is chosen by implementing RESTCount() Count on the model.
 func GetFeatures(v interface{}) *Feature {
-	var features = Feature{}
+	var features = Feature{Limits: DefaultLimits, Naming: DefaultNaming}
+	if obj, ok := v.(interface{ RESTNaming() NamingStrategy }); ok {
+		features.Naming = obj.RESTNaming()
+	}
+	if obj, ok := v.(interface{ RESTEnvelope() Envelope }); ok {
+		features.Envelope = obj.RESTEnvelope()
+	}
+	if obj, ok := v.(interface{ RESTLimits() Limits }); ok {
+		features.Limits = features.Limits.Override(obj.RESTLimits())
+	}
+	if obj, ok := v.(interface{ RESTFlag() string }); ok {
+		features.Flag = obj.RESTFlag()
+	}
+	if obj, ok := v.(interface{ RESTQuotas() []Quota }); ok {
+		features.Quotas = obj.RESTQuotas()
+	}
+	if obj, ok := v.(interface{ DedupWindow() time.Duration }); ok {
+		features.DedupWindow = obj.DedupWindow()
+	}
+	if obj, ok := v.(interface{ RESTCount() Count }); ok {
+		features.Count = obj.RESTCount()
+	}
 	var typ = reflect.ValueOf(v)
+	if permission, ok := apiModels.Load(typ.Type().String()); ok {
+		features.EnableAPI = true
+		features.Permission = permission.(string)
+	}
 	for i := 0; i < typ.NumField(); i++ {
 		switch typ.Field(i).Type().String() {
 		case "rest.API":
@@ -313,9 +549,22 @@ func GetFeatures(v interface{}) *Feature {
 			features.DisableDelete = true
 		case "rest.DisableView":
 			features.DisableView = true
+		case "rest.ObfuscateID":
+			features.ObfuscateID = true
+		case "rest.JSONAPI":
+			features.JSONAPI = true
+		case "rest.RequireApproval":
+			features.RequireApproval = true
+		case "rest.PrimaryReads":
+			features.PrimaryReads = true
 		}
 
 	}
+	if isProjection(typ.Type()) {
+		features.EnableAPI = true
+		features.DisableCreate, features.DisableUpdate, features.DisableDelete = true, true, true
+		features.EnableSetAPI = false
+	}
 	return &features
 }
 
@@ -348,9 +597,7 @@ func (res *Resource) Action(action *Endpoint) {
 	}
 	action.URL = strings.Trim(action.URL, "/")
 	if action.PKUrl {
-		for _, item := range res.Schema.PrimaryFields {
-			action.URL += "/:" + item.DBName
-		}
+		action.URL += primaryKeyURL(res.Schema)
 	}
 
 	for _, item := range action.URLParams {
@@ -362,19 +609,11 @@ func (res *Resource) Action(action *Endpoint) {
 	action.AbsoluteURI = "/" + strings.Trim(PREFIX+"/rest/"+res.Path+"/"+strings.Trim(action.URL, "/"), "/")
 
 	action.Resource = res
-
-	switch action.Method {
-	case GET:
-		evo.Get(action.AbsoluteURI, action.requestHandler)
-	case POST:
-		evo.Post(action.AbsoluteURI, action.requestHandler)
-	case PUT:
-		evo.Put(action.AbsoluteURI, action.requestHandler)
-	case DELETE:
-		evo.Delete(action.AbsoluteURI, action.requestHandler)
-	default:
-		panic("invalid method passed")
+	if s, err := modelSchema(action.Object); err == nil {
+		indexFields(s)
 	}
+
+	resources.mount(action)
 	res.Actions = append(res.Actions, action)
 
 	for idx, perm := range action.Permissions {
@@ -397,37 +636,91 @@ func (res *Resource) Action(action *Endpoint) {
 // If the action has a handler defined
 func (action *Endpoint) requestHandler(request *evo.Request) interface{} {
 	context := &Context{
-		Request: request,
-		Action:  action,
-		Object:  action.Object,
-		Response: &Pagination{
-			TotalPages: 1,
-			Total:      1,
-			Page:       1,
-			Size:       1,
-			Success:    true,
-		},
-	}
-
-	var stmt = evo.GetDBO().Model(action.Object.Interface()).Statement
-	err := stmt.Parse(action.Object.Interface())
+		Request:  request,
+		Action:   action,
+		Object:   action.Object,
+		Response: newResponse(),
+	}
+
+	s, err := modelSchema(action.Object)
 	if err != nil {
 		return err
 	}
-	context.Schema = stmt.Schema
-	if action.Handler != nil {
-		if err := action.Handler(context); err != nil {
+	context.Schema = s
+	var start = time.Now()
+	context.span = tracing.Start(request, action.Resource.Table)
+	if err := context.onRequest(); err != nil {
+		context.SetError(err)
+	} else if err := context.checkFlag(); err != nil {
+		context.SetError(err)
+	} else if err := context.impersonate(); err != nil {
+		context.SetError(err)
+	} else if err := context.checkRate(); err != nil {
+		context.SetError(err)
+	} else if action.Handler != nil {
+		if err := context.run(); err != nil {
 			context.SetError(err)
 		}
 	} else {
 		context.SetError(fmt.Errorf("unimplemented handler"))
 	}
+	metering.Record(context.Tenant(), metering.APICalls, 1)
+	context.span.Finish(context.Logger(), "action", action.Name, "success", context.Response.Success)
+	context.observe(start)
+	context.onResponse()
+	if context.streamed {
+		context.settleIdempotency(nil)
+		return nil
+	}
+	if replay := context.replayed(); replay != nil {
+		return replay
+	}
 
-	return outcome.Json(context.GetResponse())
+	var rows = context.isRows(context.Response.Data)
+	if context.Response.Success && context.Response.Data != nil {
+		context.Response.Data = context.withView(context.withComputed(context.Response.Data))
+	}
+	// other payloads such as pending changes carry ids of their own
+	if action.Resource.Feature.ObfuscateID && rows && context.Response.Success {
+		context.Response.Data = action.Resource.obfuscateIDs(context.Response.Data)
+	}
+	if context.Response.Success && context.Response.Data != nil {
+		context.Response.Data = context.withNaming(context.Response.Data, rows)
+	}
+	var response *outcome.Response
+	if context.jsonAPI() {
+		response = context.jsonAPIResponse(rows)
+	} else {
+		response = outcome.Json(context.envelope().Wrap(context))
+		if context.status > 0 {
+			response.Status(context.status)
+		}
+	}
+	if data, ok := response.Data.([]byte); ok {
+		contract.Record(action.AbsoluteURI, string(action.Method), request.OriginalURL(), data)
+		context.releaseSlices()
+	}
+	context.settleIdempotency(response)
+	return response
+}
+
+// newResponse returns the response of a request before its handler runs.
+func newResponse() *Pagination {
+	return &Pagination{
+		TotalPages: 1,
+		Total:      1,
+		Page:       1,
+		Size:       1,
+		Success:    true,
+	}
 }
 
 // GetObject is a method of the Context type that returns a new indirect reflect.Value of the context Object's type.
+// Models with generated accessors are allocated by them, see Accessors.
 func (context *Context) GetObject() reflect.Value {
+	if accessors := context.accessors(); accessors != nil {
+		return reflect.ValueOf(accessors.RESTNew()).Elem()
+	}
 	return reflect.Indirect(reflect.New(context.Object.Type()))
 }
 
@@ -478,67 +771,92 @@ func (context *Context) SetResponse(response interface{}) {
 
 }
 
+// SetStatus sets the HTTP status code of the response.
+func (context *Context) SetStatus(code int) {
+	context.status = code
+}
+
 // SetError is a method of the Context type that sets the error message in the Response field and marks the Response as unsuccessful.
-// It takes an error parameter.
+// It takes an error parameter. The message is translated in the locale of the caller, see i18n.Translate.
 func (context *Context) SetError(error error) {
-	context.Response.Error = error.Error()
+	context.Response.Error = i18n.Translate(context.Locale(), error)
+	context.err = error
 	context.Response.Success = false
 }
 
 // GetObjectSlice returns a new indirect reflect value of a slice of the type of the Object field in the Context.
 func (context *Context) GetObjectSlice() reflect.Value {
+	if accessors := context.accessors(); accessors != nil {
+		return reflect.ValueOf(accessors.RESTNewSlice()).Elem()
+	}
 	return reflect.Indirect(reflect.New(reflect.SliceOf(context.Object.Type())))
 }
 
 // FindByPrimaryKey is a method that searches for a record in the database based on the primary key values provided.
 // The method takes an input parameter, which can be a struct or a
 func (context *Context) FindByPrimaryKey(input interface{}) (bool, error) {
-	var err error
-	var dbo = context.GetDBO().Debug()
-	var association = context.Request.Query("associations").String()
-	if association != "" {
-		if association == "1" || association == "true" {
-			dbo = dbo.Preload(clause.Associations)
-		} else if association == "deep" {
-			var preload = getAssociations("", context.Schema)
-			for _, item := range preload {
-				dbo = dbo.Preload(item)
-			}
-		} else {
-			var ls = strings.Split(association, ",")
-			for _, item := range ls {
-				dbo = dbo.Preload(item)
-			}
+	var dbo, viewed, err = context.applyView(context.GetDBO())
+	if err != nil {
+		return false, err
+	}
+	if !viewed {
+		if dbo, _, err = context.applyPreloads(dbo); err != nil {
+			return false, err
 		}
+	}
+	where, params, err := context.primaryKeyConditions(input)
+	if err != nil {
+		return false, err
+	}
 
+	dbo, err = filterMapper(context.Request.QueryString(), context, dbo)
+	if err != nil {
+		return false, err
 	}
+	dbo = context.ApplyPolicies(dbo)
+	return dbo.Where(strings.Join(where, " AND "), params...).Take(input).RowsAffected != 0, err
+}
+
+// primaryKeyURL returns the URL segments of the primary keys of the schema, one per key in the order of the
+// schema, empty for schemas without primary key.
+func primaryKeyURL(s *schema.Schema) string {
+	var url string
+	for _, field := range s.PrimaryFields {
+		url += "/:" + field.DBName
+	}
+	return url
+}
+
+// primaryKeyConditions returns the conditions selecting the row given by the primary key in the url of the
+// request, or else by the primary key of input.
+func (context *Context) primaryKeyConditions(input interface{}) ([]string, []interface{}, error) {
 	var where []string
 	var params []interface{}
 	for _, field := range context.Action.Resource.Schema.PrimaryFields {
 		var v interface{} = context.Request.Param(field.DBName).String()
 		if v == "" {
-			v = getValueByFieldName(input, field.Name)
+			v = fieldValue(input, field.Name)
+		} else if context.Action.Resource.Feature.ObfuscateID {
+			id, err := context.Action.Resource.decodeID(v.(string))
+			if err != nil {
+				return nil, nil, ErrorObjectNotExist
+			}
+			v = id
 		}
 		where = append(where, field.DBName+" = ?")
 		params = append(params, v)
 	}
-
-	var join = context.Request.Query("join").String()
-	if len(join) > 0 {
-		if relations := relationsMapper(join); relations != "" {
-			dbo = dbo.Preload(relations)
-		}
-	}
-	dbo, err = filterMapper(context.Request.QueryString(), context, dbo)
-	return dbo.Where(strings.Join(where, " AND "), params...).Take(input).RowsAffected != 0, err
+	return where, params, nil
 }
 
-// orderRegex is a regular expression that matches strings in the format of "[field] [asc|desc]" where:
+// orderRegex is a regular expression that matches strings in the format of "[table.][field] [asc|desc]" where:
+//   - [table.] optionally qualifies the field with a table name
 //   - [field] represents a sequence of letters, numbers, hyphens, and underscores
 //   - [asc|desc] represents either the word "asc" or "desc"
 //
-// The regular expression is case-insensitive and accepts leading and trailing whitespace characters.
-var orderRegex = regexp.MustCompile(`(?mi)\s*[a-zA-Z0-9-_]+\s+(asc|desc)\s*`)
+// The regular expression is case-insensitive, anchored, and accepts leading and trailing whitespace characters.
+// It is used where no schema is available to validate the columns, otherwise use ParseOrder.
+var orderRegex = regexp.MustCompile(`(?i)^\s*([a-zA-Z0-9-_]+\.)?[a-zA-Z0-9-_]+\s+(asc|desc)\s*$`)
 
 // ApplyFilters applies filters to the query based on the request parameters in the context. It modifies the
 func (context *Context) ApplyFilters(query *gorm.DB) (*gorm.DB, error) {
@@ -547,53 +865,67 @@ func (context *Context) ApplyFilters(query *gorm.DB) (*gorm.DB, error) {
 		}
 	*/
 
-	var association = context.Request.Query("associations").String()
-	if association != "" {
-		if association == "1" || association == "true" {
-			query = query.Preload(clause.Associations)
-		} else if association == "deep" {
-			var preload = getAssociations("", context.Schema)
-			fmt.Println(context.Schema.Name, preload)
-			for _, item := range preload {
-				query = query.Preload(item)
-			}
-		} else {
-			var ls = strings.Split(association, ",")
-			for _, item := range ls {
-				query = query.Preload(item)
-			}
+	query, viewed, err := context.applyView(query)
+	if err != nil {
+		return query, err
+	}
+	var plan *preloadPlan
+	if !viewed {
+		if query, plan, err = context.applyPreloads(query); err != nil {
+			return query, err
 		}
-
+		context.preloads = plan
 	}
 
 	var order = context.Request.Query("order").String()
 	if order != "" {
-		valid := true
-		for _, item := range strings.Split(order, ",") {
-			if !orderRegex.MatchString(item) {
-				valid = false
-				break
+		parsed, err := ParseOrder(order, context.Schema)
+		if err != nil {
+			return query, err
+		}
+		for _, column := range parsed.Columns {
+			if !context.queryable(column) {
+				return query, ErrorPermissionDenied
 			}
 		}
-		if valid {
-			query = query.Order(order)
+		for _, relation := range parsed.Joins {
+			query = query.Joins(relation)
 		}
+		query = query.Order(parsed.Clause)
 	}
 
 	var fields = context.Request.Query("fields").String()
-	if len(fields) > 0 {
-		splitFields := strings.Split(fields, ",")
-		query = query.Select(splitFields)
-	}
-
-	var join = context.Request.Query("join").String()
-	if len(join) > 0 {
-		if relations := relationsMapper(join); relations != "" {
-			query = query.Preload(relations)
+	if len(fields) > 0 && !viewed {
+		var columns []string
+		var selected []string
+		for _, name := range strings.Split(fields, ",") {
+			if strings.Contains(name, ".") {
+				continue
+			}
+			var field = findField(context.Schema, strings.TrimSpace(name))
+			if field == nil {
+				return query, ErrorColumnNotExist
+			}
+			selected = appendColumn(selected, field.DBName)
+		}
+		for _, key := range plan.keys {
+			selected = appendColumn(selected, key)
 		}
+		for _, column := range selected {
+			columns = append(columns, "`"+context.Schema.Table+"`.`"+column+"`")
+		}
+		query = query.Select(columns)
 	}
-	var err error
+
 	query, err = filterMapper(context.Request.QueryString(), context, query)
+	if err != nil {
+		return query, err
+	}
+	query, err = context.applySegment(query)
+	if err != nil {
+		return query, err
+	}
+	query = context.ApplyPolicies(query)
 
 	var offset = context.Request.Query("offset").Int()
 	if offset > 0 {
@@ -601,70 +933,169 @@ func (context *Context) ApplyFilters(query *gorm.DB) (*gorm.DB, error) {
 	}
 
 	var limit = context.Request.Query("limit").Int()
+	if context.Action.Name == "ALL" {
+		if context.Request.Query("stream").Bool() {
+			limit = RowLimit(limit, context.Limits().MaxExportRows)
+		} else {
+			limit = RowLimit(limit, context.Limits().AllEndpointCap)
+		}
+	}
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
 	return query, err
 }
 
-func getAssociations(prefix string, s *schema.Schema, loaded ...string) []string {
-	var preload []string
-	if len(loaded) == 0 {
-		loaded = []string{s.Table}
+// preloadCost returns the cost of the preloads requested by the associations and join parameters: the number
+// of preloaded relations, each weighted by its depth since nested relations multiply the loaded rows.
+// associations=deep counts the relations up to depth, see deepPaths.
+func preloadCost(association string, join string, s *schema.Schema, depth int) int {
+	var cost = 0
+	var paths []string
+	switch association {
+	case "":
+	case "1", "true":
+		cost = len(s.Relationships.Relations)
+	case "deep":
+		paths = deepPaths(s, depth)
+	default:
+		paths = strings.Split(association, ",")
+	}
+	if join != "" {
+		paths = append(paths, strings.Split(join, ",")...)
+	}
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			cost += strings.Count(path, ".") + 1
+		}
 	}
+	return cost
+}
 
-	var relations []*schema.Relationship
-	relations = append(relations, s.Relationships.HasOne...)
-	relations = append(relations, s.Relationships.BelongsTo...)
-	relations = append(relations, s.Relationships.HasMany...)
+// Locale returns the language of the caller, from the "language" header or the "l10n-language" cookie.
+func (context *Context) Locale() string {
+	if context.locale != nil {
+		return *context.locale
+	}
+	return requestLocale(context.Request)
+}
 
-	for idx, _ := range relations {
+// ctx returns the context of the request, or the background context of the contexts without request, see Sync.
+func (context *Context) ctx() stdcontext.Context {
+	if context.Request == nil {
+		return stdcontext.Background()
+	}
+	return context.Request.Context.Context()
+}
 
-		var relation = relations[idx]
+func requestLocale(request *evo.Request) string {
+	if locale := request.Header("language"); locale != "" {
+		return locale
+	}
+	return request.Cookie("l10n-language")
+}
 
-		var chunks = strings.Split(loaded[0], ".")
-		if len(chunks) > 2 && chunks[len(chunks)-2] == s.Table {
-			continue
-		}
-		if len(chunks) > 4 {
-			continue
+// GetDBO returns a pointer to the *gorm.DB object.
+// It retrieves the *gorm.DB object from the `evo` package, or the read replica for the reads of the list and
+// get endpoints, see SetReplica.
+// If the caller has a locale, it is set as the "lang" of the session of the requests reading rows, see reading.
+func (context *Context) GetDBO() *gorm.DB {
+	var dbo = evo.GetDBO()
+	var ctx = tracing.WithSpan(stdcontext.Background(), context.span)
+	if replica := context.readReplica(); replica != nil {
+		if context.replica == nil {
+			context.replica = &replicaReads{}
 		}
-		loaded[0] += "." + relation.Field.Schema.Table
-		preload = append(preload, strings.TrimLeft(prefix+"."+relation.Field.Name, "."))
-		/*	query = query.Preload(strings.TrimLeft(prefix+"."+relation.Field.Name, "."))
-			fmt.Println("preload:", strings.TrimLeft(prefix+"."+relation.Field.Name, "."))
-		*/
-		for i, _ := range relation.FieldSchema.Relationships.Relations {
-			var item = relation.FieldSchema.Relationships.Relations[i]
-			if item.Schema.Table == s.Table {
-				continue
-			}
-			preload = append(preload, getAssociations(prefix+"."+relation.Field.Name, item.Schema, loaded...)...)
+		dbo = replica
+		ctx = stdcontext.WithValue(ctx, replicaReadsKey{}, context.replica)
+	}
+	if locale := context.Locale(); locale != "" && context.reading() {
+		dbo = dbo.Set("lang", locale)
+	}
+	return withLogger(dbo.Session(&gorm.Session{
+		Logger:  SQLLogger{Resource: context.Action.Resource.Table, Traced: context.span.IsSampled()},
+		Context: ctx,
+	}), context.Logger())
+}
+
+// reading reports whether the request only reads rows. The other requests read the base values of the
+// translated columns, so the rows they write back keep them rather than the values of the locale.
+func (context *Context) reading() bool {
+	if context.Request == nil {
+		return true
+	}
+	var method = context.Request.Method()
+	return method == http.MethodGet || method == http.MethodHead
+}
 
+// Logger returns the logger of the request carrying the request id, trace id, user, tenant and resource.
+// Entries of impersonated requests are flagged with the impersonated_by field.
+func (context *Context) Logger() *logger.Logger {
+	if context.logger == nil {
+		context.logger = logger.FromRequest(context.Request).With("tenant", context.Tenant(), "resource", context.Action.Resource.Table)
+		if context.span != nil {
+			context.logger = context.logger.With("trace_id", context.span.TraceID)
 		}
+		if by := context.ImpersonatedBy(); by != nil {
+			context.logger = context.logger.With("user", context.User().UUID(), "impersonated_by", by.UUID())
+		}
+	}
+	return context.logger
+}
 
+// Span returns the tracing span of the request, nil outside of a request.
+// Pass Span().Context(ctx) to httpclient calls to propagate the trace.
+func (context *Context) Span() *tracing.Span {
+	return context.span
+}
+
+// Tenant returns the tenant of the request resolved once using TenantResolver.
+// It returns an empty string if the request is not bound to a tenant.
+func (context *Context) Tenant() string {
+	if context.tenant == nil {
+		var tenant = TenantResolver(context.Request)
+		context.tenant = &tenant
 	}
-	return preload
+	return *context.tenant
 }
 
-// GetDBO returns a pointer to the *gorm.DB object.
-// It retrieves the *gorm.DB object from the `evo` package.
-// If the "language" header is present in the request, it sets
-func (context *Context) GetDBO() *gorm.DB {
-	var dbo = evo.GetDBO()
-	if context.Request.Header("language") != "" {
-		dbo = db.Set("lang", context.Request.Header("language"))
-	} else {
-		if context.Request.Cookie("l10n-language") != "" {
-			dbo = db.Set("lang", context.Request.Cookie("l10n-language"))
-		}
+// TenantSetter is implemented by models bound to a tenant.
+// The tenant of the request is stamped on such objects before they are written.
+type TenantSetter interface {
+	SetTenant(tenant string)
+}
+
+// stampTenant sets the request tenant on the object if it implements TenantSetter.
+func (context *Context) stampTenant(ptr interface{}) {
+	if obj, ok := ptr.(TenantSetter); ok {
+		obj.SetTenant(context.Tenant())
 	}
-	return dbo
+}
+
+// IDGenerator is implemented by models generating their own primary key, such as those embedding
+// model.Identifier. GenerateID sets a new key unless the object already has one.
+type IDGenerator interface {
+	GenerateID()
+}
+
+// generateID generates the primary key of the object if it implements IDGenerator, reporting whether it does.
+func (context *Context) generateID(ptr interface{}) bool {
+	obj, ok := ptr.(IDGenerator)
+	if ok {
+		obj.GenerateID()
+	}
+	return ok
+}
+
+// Setting returns the value of the given settings key resolved for the tenant of the request.
+// Tenant overrides take precedence over the global settings.
+func (context *Context) Setting(key string) generic.Value {
+	return settings.Get(context.Tenant(), key)
 }
 
 func (context *Context) HasPerm(s string) error {
 	if context.Action.Resource.Feature.CheckPermission {
-		var user = context.Request.User()
+		var user = context.User()
 		if user.Anonymous() {
 			return ErrorUnauthorized
 		}
@@ -719,56 +1150,51 @@ const (
 	InOperator      = "in"
 )
 
-// relationsMapper maps the given string of relations into a formatted nested relation string.
-// It splits the input string by comma and then splits each relation by dot.
-// It converts the first letter of each relation to uppercase using proper language casing rules.
-// It joins the titled relation strings with dots.
-// If the resulting nested relation string is not empty, it is returned. Otherwise, an empty string is returned.
-func relationsMapper(joins string) string {
-	relations := strings.Split(joins, ",")
-	for _, relation := range relations {
-		nestedRelationsSlice := strings.Split(relation, ".")
-		titledSlice := make([]string, len(nestedRelationsSlice))
-		for i, relation := range nestedRelationsSlice {
-			titledSlice[i] = cases.Title(language.English, cases.NoLower).String(relation)
-		}
-		nestedRelation := strings.Join(titledSlice, ".")
-		if len(nestedRelation) > 0 {
-			return nestedRelation
-		}
-	}
-	return ""
-}
-
 // filterMapper applies filters to the given query based on the provided filter string.
 // It parses the filter
 func filterMapper(filters string, context *Context, query *gorm.DB) (*gorm.DB, error) {
 	fRegEx := filterRegEx(filters)
 	for _, filter := range fRegEx {
-		var obj = context.GetObject().Interface()
-		var ref = reflect.ValueOf(obj)
-		fnd := false
-		var fieldName = ""
 		filter["value"], _ = url.QueryUnescape(filter["value"])
-		for _, field := range context.Schema.Fields {
-			if field.DBName == filter["column"] {
-				fieldName = field.Name
-				fnd = true
-				break
+		column, name, ok := "", "", false
+		var accessors = context.accessors()
+		if accessors != nil {
+			column, name, ok = accessors.RESTFilterField(filter["column"])
+		}
+		if !ok {
+			var field = findField(context.Schema, filter["column"])
+			if field == nil {
+				return nil, ErrorColumnNotExist
 			}
+			column, name = field.DBName, field.Name
+		}
+		if !context.queryable(column) {
+			return nil, ErrorPermissionDenied
 		}
-		if !fnd {
-			return nil, ErrorColumnNotExist
+		filter["column"] = column
+		var v interface{}
+		if accessors != nil {
+			v, ok = accessors.RESTGet(name)
+		}
+		if !ok {
+			v = reflect.Indirect(context.GetObject()).FieldByName(name).Interface()
 		}
-		v := ref.FieldByName(fieldName)
 
-		if obj, ok := v.Interface().(interface {
+		if obj, ok := v.(interface {
 			RestFilter(context *Context, query *gorm.DB, filter map[string]string)
 		}); ok {
 			obj.RestFilter(context, query, filter)
 			return query, nil
 		}
 
+		if fn, ok := getOperator(filter["condition"]); ok {
+			var err error
+			if query, err = fn(context, query, filter["column"], filter["value"]); err != nil {
+				return query, err
+			}
+			continue
+		}
+
 		if filter["condition"] == NotNullOperator || filter["condition"] == IsNullOperator {
 			if filter["column"] == "deleted_at" {
 				query = query.Unscoped()
@@ -790,13 +1216,23 @@ func filterMapper(filters string, context *Context, query *gorm.DB) (*gorm.DB, e
 			}
 		}
 	}
-	query = query.Debug()
 	return query, nil
 }
 
+// queryable reports whether the user of the context may filter and sort the rows on the column. Models
+// restricting the columns implement RESTQueryable(context *Context, column string) bool.
+func (context *Context) queryable(column string) bool {
+	if obj, ok := context.GetObject().Addr().Interface().(interface {
+		RESTQueryable(context *Context, column string) bool
+	}); ok {
+		return obj.RESTQueryable(context, column)
+	}
+	return true
+}
+
 // result will be [{"column":"column1","condition":"condition1","value":"value1"},{"column":"column2","condition":"condition2","value":"value2"},{"column":"column3","condition":"condition
 func filterRegEx(str string) []map[string]string {
-	var re = regexp.MustCompile(`(?m)((?P<column>[a-zA-Z_\-0-9]+)\[(?P<condition>[a-zA-Z]+)\](\=((?P<value>[a-zA-Z_\-0-9\s\%\,]+))){0,1})\&*`)
+	var re = regexp.MustCompile(`(?m)((?P<column>[a-zA-Z_\-0-9]+)\[(?P<condition>[a-zA-Z]+)\](\=((?P<value>[a-zA-Z_\-0-9\s\%\,\.]+))){0,1})\&*`)
 	var keys = re.SubexpNames()
 	var result = []map[string]string{}
 	for _, match := range re.FindAllStringSubmatch(str, -1) {
@@ -846,6 +1282,20 @@ type Feature struct {
 	DisableDelete          bool
 	CheckPermission        bool
 	EnableSetAPI           bool
+	ObfuscateID            bool
+	JSONAPI                bool
+	Naming                 NamingStrategy
+	Envelope               Envelope `json:"-"`
+	DedupWindow            time.Duration
+	Quotas                 []Quota
+	Flag                   string
+	RequireApproval        bool
+	PrimaryReads           bool
+	Count                  Count
+	// Permission is required on every endpoint of the resource on top of the permissions of the endpoint,
+	// see EnableAdminAPI.
+	Permission string
+	Limits
 }
 
 type AppPermission struct {
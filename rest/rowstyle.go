@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iesitalia/toolbox/query"
+)
+
+// RowStyle highlights the rows of a FilterView matching a condition, e.g. the cancelled orders:
+//
+//	RowStyles: []rest.RowStyle{
+//		{When: "order.status = 'cancelled'", Class: "muted", Icon: "ban"},
+//		{When: "order.due_date < NOW() AND order.paid = 0", Class: "danger", Color: "#c0392b"},
+//	}
+//
+// Conditions are SQL expressions over the tables of the view, like the filters, evaluated by the data query.
+// The style of each returned row is set in the Rows of the view: the classes of every matching rule are
+// combined, while color and icon are taken from the first matching rule setting them.
+type RowStyle struct {
+	When  string `json:"-"`
+	Class string `json:"class,omitempty"`
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// RowMeta is the style of a returned row.
+type RowMeta struct {
+	Class string `json:"class,omitempty"`
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// selectRowStyles selects the outcome of the condition of each rule as row_style_<index>.
+func (v *FilterView) selectRowStyles(q *query.Query) {
+	for i, style := range v.RowStyles {
+		q.Select("(CASE WHEN "+style.When+" THEN 1 ELSE 0 END)", fmt.Sprintf("row_style_%d", i))
+	}
+}
+
+// rowMeta returns the style of the row from the outcome of the rules.
+func (v *FilterView) rowMeta(row map[string]interface{}) RowMeta {
+	var meta RowMeta
+	var classes []string
+	for i, style := range v.RowStyles {
+		if toInt64(row[fmt.Sprintf("row_style_%d", i)]) == 0 {
+			continue
+		}
+		if style.Class != "" {
+			classes = append(classes, style.Class)
+		}
+		if meta.Color == "" {
+			meta.Color = style.Color
+		}
+		if meta.Icon == "" {
+			meta.Icon = style.Icon
+		}
+	}
+	meta.Class = strings.Join(classes, " ")
+	return meta
+}
@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"testing"
+)
+
+func TestRowMeta(t *testing.T) {
+	var view = FilterView{RowStyles: []RowStyle{
+		{When: "status = 'cancelled'", Class: "muted", Icon: "ban"},
+		{When: "overdue = 1", Class: "danger", Color: "red"},
+		{When: "vip = 1", Class: "bold", Color: "gold", Icon: "star"},
+	}}
+	var tests = []struct {
+		name string
+		row  map[string]interface{}
+		want RowMeta
+	}{
+		{"no match", map[string]interface{}{"row_style_0": int64(0), "row_style_1": int64(0), "row_style_2": int64(0)}, RowMeta{}},
+		{"single", map[string]interface{}{"row_style_0": int64(1)}, RowMeta{Class: "muted", Icon: "ban"}},
+		{"first wins", map[string]interface{}{"row_style_1": []byte("1"), "row_style_2": "1"}, RowMeta{Class: "danger bold", Color: "red", Icon: "star"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := view.rowMeta(tt.row); got != tt.want {
+				t.Errorf("rowMeta() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package rest
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db/schema/ddl"
+	"github.com/getevo/evo/v2/lib/db/schema/table"
+	"github.com/iesitalia/toolbox/acl"
+	"gorm.io/gorm"
+)
+
+// Drifts of the live database from the schema of a resource.
+const (
+	DriftMissingTable  = "missing_table"
+	DriftMissingColumn = "missing_column"
+	DriftType          = "type"
+	DriftNullable      = "nullable"
+	DriftMissingIndex  = "missing_index"
+	DriftIndex         = "index"
+)
+
+// Drift is a difference between the schema of a resource and the live database.
+// - Kind: the kind of difference, see DriftMissingTable and the following.
+// - Column, Index: the column or index concerned.
+// - Expected, Actual: the definition in the schema and in the database, for type, nullable and index drifts.
+type Drift struct {
+	Kind     string `json:"kind"`
+	Column   string `json:"column,omitempty"`
+	Index    string `json:"index,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// SchemaDiff is the comparison of the schema of a resource with the live database, along with the statements
+// migrating the database, as generated by the evo migrations.
+type SchemaDiff struct {
+	Table  string   `json:"table"`
+	Drifts []Drift  `json:"drifts"`
+	Plan   []string `json:"plan"`
+}
+
+// DiffSchema compares the schema of every resource with the live database, returning the resources which differ.
+func DiffSchema(db *gorm.DB) ([]SchemaDiff, error) {
+	tables, err := liveTables(db)
+	if err != nil {
+		return nil, err
+	}
+	var result = []SchemaDiff{}
+	for _, resource := range Resources() {
+		if resource.Model == nil || resource.Model.Statement == nil || resource.Model.Statement.Schema == nil {
+			continue
+		}
+		var local = ddl.FromStatement(resource.Model.Statement)
+		var remote = tables.GetTable(local.Name)
+		var diff = diffTable(local, remote)
+		if remote != nil {
+			diff.Plan = statements(local.GetDiff(*remote))
+		} else {
+			diff.Plan = statements(local.GetCreateQuery())
+		}
+		if len(diff.Drifts) > 0 || len(diff.Plan) > 0 {
+			result = append(result, diff)
+		}
+	}
+	return result, nil
+}
+
+// detectEngine sets the engine of the DDL statements from the version of the MySQL database, as the evo
+// migrations do. It is called once when the rest app is registered.
+func detectEngine(db *gorm.DB) {
+	if db.Dialector.Name() != "mysql" {
+		return
+	}
+	var engine string
+	db.Raw("SELECT VERSION()").Scan(&engine)
+	switch engine = strings.ToLower(engine); {
+	case strings.Contains(engine, "mariadb"):
+		ddl.Engine = "mariadb"
+	case strings.Contains(engine, "mysql"):
+		ddl.Engine = "mysql"
+	}
+}
+
+// liveTables loads the tables of the current database with their columns and indexes from the information
+// schema, as the evo migrations do.
+func liveTables(db *gorm.DB) (table.Tables, error) {
+	var database string
+	if err := db.Raw("SELECT DATABASE()").Scan(&database).Error; err != nil {
+		return nil, err
+	}
+
+	var tables table.Tables
+	err := db.Raw("SELECT CCSA.character_set_name AS 'TABLE_CHARSET', T.* FROM information_schema.TABLES T, information_schema.COLLATION_CHARACTER_SET_APPLICABILITY CCSA WHERE CCSA.collation_name = T.table_collation AND T.table_schema = ?", database).
+		Scan(&tables).Error
+	if err != nil {
+		return nil, err
+	}
+	var columns table.Columns
+	if err := db.Where(table.Table{Database: database}).Order("TABLE_NAME ASC, ORDINAL_POSITION ASC").Find(&columns).Error; err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		if t := tables.GetTable(column.Table); t != nil {
+			t.Columns = append(t.Columns, column)
+		}
+	}
+	var stats []table.IndexStat
+	if err := db.Where(table.IndexStat{Database: database}).Order("TABLE_NAME ASC, SEQ_IN_INDEX ASC").Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	for _, stat := range stats {
+		var t = tables.GetTable(stat.Table)
+		if t == nil || stat.Name == "PRIMARY" {
+			continue
+		}
+		var index = t.Indexes.Find(stat.Name)
+		if index == nil {
+			t.Indexes = append(t.Indexes, table.Index{Name: stat.Name, Table: stat.Table, Unique: !stat.NonUnique})
+			index = &t.Indexes[len(t.Indexes)-1]
+		}
+		if column := t.Columns.GetColumn(stat.ColumnName); column != nil {
+			index.Columns = append(index.Columns, *column)
+		}
+	}
+	return tables, nil
+}
+
+// diffTable returns the drifts of the live table, nil when missing, from the schema.
+func diffTable(local ddl.Table, remote *table.Table) SchemaDiff {
+	var diff = SchemaDiff{Table: local.Name, Drifts: []Drift{}}
+	if remote == nil {
+		diff.Drifts = append(diff.Drifts, Drift{Kind: DriftMissingTable})
+		return diff
+	}
+	for _, column := range local.Columns {
+		var live = remote.Columns.GetColumn(column.Name)
+		if live == nil {
+			diff.Drifts = append(diff.Drifts, Drift{Kind: DriftMissingColumn, Column: column.Name, Expected: column.Type})
+			continue
+		}
+		if expected, actual := columnType(column.Type), columnType(live.ColumnType); expected != actual {
+			diff.Drifts = append(diff.Drifts, Drift{Kind: DriftType, Column: column.Name, Expected: expected, Actual: actual})
+		}
+		var nullable = column.Nullable && !column.PrimaryKey
+		if nullable != (live.Nullable == "YES") {
+			diff.Drifts = append(diff.Drifts, Drift{Kind: DriftNullable, Column: column.Name, Expected: nullability(nullable), Actual: nullability(!nullable)})
+		}
+	}
+	for _, index := range local.Index {
+		var live = remote.Indexes.Find(index.Name)
+		if live == nil {
+			diff.Drifts = append(diff.Drifts, Drift{Kind: DriftMissingIndex, Index: index.Name, Expected: indexDefinition(index.Unique, index.Columns.Keys())})
+			continue
+		}
+		var expected, actual = indexDefinition(index.Unique, index.Columns.Keys()), indexDefinition(live.Unique, live.Columns.Keys())
+		if expected != actual {
+			diff.Drifts = append(diff.Drifts, Drift{Kind: DriftIndex, Index: index.Name, Expected: expected, Actual: actual})
+		}
+	}
+	return diff
+}
+
+var intWidthRegex = regexp.MustCompile(`^(smallint|mediumint|int|bigint)\(\d+\)$`)
+
+// columnType returns the type of a column normalized for comparison: mapped to the storage type of the engine,
+// without the attributes such as unsigned and without the display width of the integers, which MySQL 8 omits.
+func columnType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if !strings.HasPrefix(typ, "enum(") {
+		if fields := strings.Fields(typ); len(fields) > 0 {
+			typ = fields[0]
+		}
+	}
+	if v, ok := ddl.EngineDataTypes[ddl.Engine][typ]; ok {
+		typ = v
+	}
+	return intWidthRegex.ReplaceAllString(typ, "$1")
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "null"
+	}
+	return "not null"
+}
+
+// indexDefinition returns the definition of an index, e.g. unique(tenant,code).
+func indexDefinition(unique bool, columns []string) string {
+	var kind = "index"
+	if unique {
+		kind = "unique"
+	}
+	return kind + "(" + strings.Join(columns, ",") + ")"
+}
+
+// statements returns the SQL statements of a migration script, without its comments.
+func statements(queries []string) []string {
+	var result = []string{}
+	for _, query := range queries {
+		if query = strings.TrimSpace(query); query != "" && !strings.HasPrefix(query, "--") {
+			result = append(result, query)
+		}
+	}
+	return result
+}
+
+// SchemaDiffs returns the differences between the schemas of the resources and the live database, along with
+// the statements migrating it, to the users holding acl.Admin. The statements are not executed.
+func SchemaDiffs(request *evo.Request) interface{} {
+	if acl.Deny(request, acl.Admin) {
+		return nil
+	}
+	diffs, err := DiffSchema(evo.GetDBO())
+	if err != nil {
+		return err
+	}
+	return diffs
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/getevo/evo/v2/lib/db/schema/ddl"
+	"github.com/getevo/evo/v2/lib/db/schema/table"
+)
+
+func TestColumnType(t *testing.T) {
+	var tests = []struct {
+		typ  string
+		want string
+	}{
+		{"bigint(20)", "bigint"},
+		{"bigint(20) unsigned", "bigint"},
+		{"BIGINT", "bigint"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"varchar(255)", "varchar(255)"},
+		{"enum('draft','in review')", "enum('draft','in review')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			if got := columnType(tt.typ); got != tt.want {
+				t.Errorf("columnType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffTable(t *testing.T) {
+	var code = ddl.Column{Name: "code", Type: "varchar(32)"}
+	var local = ddl.Table{
+		Name: "order",
+		Columns: ddl.Columns{
+			{Name: "id", Type: "bigint(20)", PrimaryKey: true},
+			code,
+			{Name: "note", Type: "text", Nullable: true},
+			{Name: "total", Type: "decimal(10,2)"},
+		},
+		Index: ddl.Indexes{
+			{Name: "idx_code", Unique: true, Columns: ddl.Columns{code}},
+			{Name: "idx_total", Columns: ddl.Columns{{Name: "total"}}},
+		},
+	}
+	var remote = &table.Table{
+		Table: "order",
+		Columns: table.Columns{
+			{Name: "id", ColumnType: "bigint unsigned", Nullable: "NO"},
+			{Name: "code", ColumnType: "varchar(16)", Nullable: "NO"},
+			{Name: "note", ColumnType: "text", Nullable: "NO"},
+		},
+		Indexes: table.Indexes{
+			{Name: "idx_code", Columns: table.Columns{{Name: "code"}}},
+		},
+	}
+	var want = []Drift{
+		{Kind: DriftType, Column: "code", Expected: "varchar(32)", Actual: "varchar(16)"},
+		{Kind: DriftNullable, Column: "note", Expected: "null", Actual: "not null"},
+		{Kind: DriftMissingColumn, Column: "total", Expected: "decimal(10,2)"},
+		{Kind: DriftIndex, Index: "idx_code", Expected: "unique(code)", Actual: "index(code)"},
+		{Kind: DriftMissingIndex, Index: "idx_total", Expected: "index(total)"},
+	}
+	if got := diffTable(local, remote).Drifts; !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTable() = %+v, want %+v", got, want)
+	}
+	if got := diffTable(local, nil).Drifts; !reflect.DeepEqual(got, []Drift{{Kind: DriftMissingTable}}) {
+		t.Errorf("diffTable() of a missing table = %+v", got)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	var got = statements([]string{"--  column total does not exists", "ALTER TABLE `order` ADD `total` decimal(10,2);", "\r\n\r\n-- Migrate Table:order", " "})
+	if want := []string{"ALTER TABLE `order` ADD `total` decimal(10,2);"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statements() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+// SegmentRefreshInterval specifies how often the member counts of the segments are recalculated.
+var SegmentRefreshInterval = 15 * time.Minute
+
+// ErrorSegmentNotExist is returned when the segment of a list request does not exist or belongs to another resource.
+var ErrorSegmentNotExist = errors.New("segment does not exists")
+
+// ErrorSegmentOwner is returned when the members of a segment are counted and its owner can not be loaded.
+var ErrorSegmentOwner = errors.New("segment owner does not exists")
+
+// Segment is a named filter of a resource, written using the filters of the list endpoints,
+// e.g. `status[eq]=active&tag[contains]=vip,lead`.
+// List endpoints apply it with ?segment=<id> along with the filters of the request.
+// Members holds the number of matching rows as of CountedAt, updated every SegmentRefreshInterval, counted as
+// the Owner of the segment, the user who saved it last, so the policies of the resource apply.
+// Segments are bound to the tenant of the request they are saved by.
+type Segment struct {
+	ID        uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TenantID  string     `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	Owner     string     `gorm:"column:owner;size:36" json:"owner"`
+	Name      string     `gorm:"column:name;size:255" json:"name"`
+	Resource  string     `gorm:"column:resource;size:64;index" json:"resource"`
+	Filter    string     `gorm:"column:filter;size:2048" json:"filter"`
+	Members   int64      `gorm:"column:members" json:"members"`
+	CountedAt *time.Time `gorm:"column:counted_at" json:"counted_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	API
+}
+
+// TableName returns the name of the table for the Segment struct.
+func (Segment) TableName() string {
+	return "segment"
+}
+
+// SetTenant sets the tenant of the segment.
+func (s *Segment) SetTenant(tenant string) {
+	s.TenantID = tenant
+}
+
+// Scope restricts the segments to the tenant of the request.
+func (s *Segment) Scope(context *Context, query *gorm.DB) *gorm.DB {
+	return query.Where("`segment`.`tenant_id` = ?", context.Tenant())
+}
+
+// ValidateCreate sets the owner of the segment to the user of the request.
+func (s *Segment) ValidateCreate(context *Context) error {
+	s.Owner = context.User().UUID()
+	return nil
+}
+
+// ValidateUpdate sets the owner of the segment to the user of the request.
+func (s *Segment) ValidateUpdate(context *Context) error {
+	s.Owner = context.User().UUID()
+	return nil
+}
+
+// BeforeSave makes sure the resource exists and the filter only uses its columns.
+func (s *Segment) BeforeSave(tx *gorm.DB) error {
+	var context, err = s.context()
+	if err != nil {
+		return err
+	}
+	_, err = filterMapper(s.Filter, context, tx.Session(&gorm.Session{NewDB: true, DryRun: true}))
+	return err
+}
+
+// resource returns the resource the segment filters.
+func (s *Segment) resource() (*Resource, error) {
+	for _, resource := range resources.all() {
+		if resource.Table == s.Resource {
+			return resource, nil
+		}
+	}
+	return nil, fmt.Errorf("segment resource %s: %w", s.Resource, ErrorObjectNotExist)
+}
+
+// context returns a context of the segment resource used to apply the filter outside of a request.
+func (s *Segment) context() (*Context, error) {
+	resource, err := s.resource()
+	if err != nil {
+		return nil, err
+	}
+	return &Context{Object: resource.Object, Schema: resource.Schema}, nil
+}
+
+// Count returns the number of rows of the resource matching the segment, as listed to its owner in its tenant:
+// the owner must be able to view the resource and the policies of the resource apply.
+func (s *Segment) Count() (int64, error) {
+	resource, err := s.resource()
+	if err != nil {
+		return 0, err
+	}
+	if Impersonator == nil {
+		return 0, ErrorSegmentOwner
+	}
+	var owner = Impersonator(nil, s.Owner)
+	if owner == nil || owner.Anonymous() {
+		return 0, ErrorSegmentOwner
+	}
+	var tenant = s.TenantID
+	var context = &Context{
+		Action:   &Endpoint{Name: "SEGMENT", Resource: resource, Object: resource.Object},
+		Object:   resource.Object,
+		Schema:   resource.Schema,
+		Response: &Pagination{},
+		user:     owner,
+		tenant:   &tenant,
+	}
+	if !context.Readable() {
+		return 0, ErrorPermissionDenied
+	}
+	var ptr = context.GetObject().Addr().Interface()
+	query, err := filterMapper(s.Filter, context, context.ApplyPolicies(db.Model(ptr)))
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	return count, query.Count(&count).Error
+}
+
+// applySegment applies the filter of the segment given by the segment query parameter.
+func (context *Context) applySegment(query *gorm.DB) (*gorm.DB, error) {
+	var id = context.Request.Query("segment").Uint64()
+	if id == 0 {
+		return query, nil
+	}
+	var segment Segment
+	if db.Where("id = ? AND resource = ? AND tenant_id = ?", id, context.Schema.Table, context.Tenant()).Take(&segment).RowsAffected == 0 {
+		return query, ErrorSegmentNotExist
+	}
+	return filterMapper(segment.Filter, context, query)
+}
+
+// RefreshSegments recalculates the member count of every segment.
+func RefreshSegments() error {
+	var segments []Segment
+	if err := db.Find(&segments).Error; err != nil {
+		return err
+	}
+	for idx := range segments {
+		var segment = &segments[idx]
+		count, err := segment.Count()
+		if err != nil {
+			logger.Error("unable to count segment members", "segment", segment.ID, "resource", segment.Resource, "error", err.Error())
+			continue
+		}
+		var now = time.Now()
+		db.Model(segment).UpdateColumns(map[string]interface{}{"members": count, "counted_at": now})
+	}
+	return nil
+}
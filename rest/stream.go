@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"bufio"
+	"encoding/json"
+	"reflect"
+
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/metering"
+	"gorm.io/gorm"
+)
+
+// NDJSONContentType is the content type of newline-delimited JSON responses.
+const NDJSONContentType = "application/x-ndjson"
+
+// streamFlushSize is the number of rows written between two flushes of the response stream.
+var streamFlushSize = 100
+
+// StreamNDJSON iterates the rows of the given query and writes each object as a single JSON line to the response.
+// Rows are read one by one using gorm Rows(), so memory use is bounded regardless of the size of the table.
+// AfterGet is called on every object before it is written, and the primary keys are obfuscated as in the other
+// responses of the resource. Associations are not preloaded in stream mode.
+// If an error occurs after streaming has started, a final line {"error": "..."} is written and the stream is closed.
+func (context *Context) StreamNDJSON(query *gorm.DB) error {
+	var sample = context.GetObject().Addr().Interface()
+	rows, err := query.Model(sample).Rows()
+	if err != nil {
+		return err
+	}
+	context.streamed = true
+	context.Request.SetHeader("Content-Type", NDJSONContentType)
+	context.Request.Context.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+		var encoder = json.NewEncoder(w)
+		var count = 0
+		for rows.Next() {
+			var ptr = context.GetObject().Addr().Interface()
+			if err := query.ScanRows(rows, ptr); err != nil {
+				writeStreamError(context.Logger(), encoder, w, err)
+				return
+			}
+			context.ReadRow(reflect.ValueOf(ptr))
+			if obj, ok := ptr.(interface{ AfterGet(context *Context) error }); ok {
+				if err := obj.AfterGet(context); err != nil {
+					writeStreamError(context.Logger(), encoder, w, err)
+					return
+				}
+			}
+			var row = context.withView(ptr)
+			if context.Action.Resource.Feature.ObfuscateID {
+				row = context.Action.Resource.obfuscateIDs(row)
+			}
+			if err := encoder.Encode(context.withNaming(row, true)); err != nil {
+				return
+			}
+			count++
+			if count%streamFlushSize == 0 {
+				if err := w.Flush(); err != nil {
+					// client went away
+					return
+				}
+			}
+		}
+		metering.Record(context.Tenant(), metering.RowsExported, int64(count))
+		if err := rows.Err(); err != nil {
+			writeStreamError(context.Logger(), encoder, w, err)
+			return
+		}
+		_ = w.Flush()
+	})
+	return nil
+}
+
+// writeStreamError writes the error as the last line of a NDJSON stream.
+func writeStreamError(l *logger.Logger, encoder *json.Encoder, w *bufio.Writer, err error) {
+	l.Error("ndjson stream interrupted", "error", err.Error())
+	_ = encoder.Encode(map[string]string{"error": err.Error()})
+	_ = w.Flush()
+}
@@ -0,0 +1,455 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/i18n"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+// SyncSnapshotSize bounds the number of rows of the snapshot of a sync subscription.
+var SyncSnapshotSize = 1000
+
+// SyncPingInterval specifies how often the sync connections are pinged to keep them open.
+var SyncPingInterval = 30 * time.Second
+
+// ErrorNotWebSocket is returned when the sync endpoint is called without a websocket upgrade.
+var ErrorNotWebSocket = errors.New("websocket upgrade required")
+
+// ErrorInvalidSubscription is returned for subscribe messages without id or resource.
+var ErrorInvalidSubscription = errors.New("invalid subscription")
+
+// SyncRequest is a message sent by the clients of the sync endpoint.
+// - subscribe: subscribes the id to the rows of the resource, the table of a rest resource, matching the filters
+// given as query string like on the paginate endpoint. Cursor resumes a previous subscription.
+// - unsubscribe: ends the subscription with the id.
+type SyncRequest struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Resource string `json:"resource,omitempty"`
+	Filters  string `json:"filters,omitempty"`
+	Cursor   uint64 `json:"cursor,omitempty"`
+}
+
+// SyncMessage is a message sent to the clients of the sync endpoint.
+// - snapshot: Data holds the rows of the subscription keyed by primary key, composite keys joined by comma.
+// Truncated is set when the rows exceed SyncSnapshotSize.
+// - patch: Patch is a JSON Patch (RFC 6902) to apply to the snapshot.
+// - error: Error describes why a message could not be handled.
+// Cursor is the position in the changes the client is up to date with, subscribe with it to resume.
+type SyncMessage struct {
+	Type      string                 `json:"type"`
+	ID        string                 `json:"id,omitempty"`
+	Cursor    uint64                 `json:"cursor,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Truncated bool                   `json:"truncated,omitempty"`
+	Patch     []PatchOperation       `json:"patch,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// PatchOperation is an operation of a JSON Patch.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type syncSession struct {
+	conn          *wsConn
+	user          evo.UserInterface
+	tenant        string
+	locale        string
+	logger        *logger.Logger
+	changes       *changeSubscription
+	subscriptions map[string]*syncSubscription
+}
+
+type syncSubscription struct {
+	id      string
+	context *Context
+	base    *gorm.DB
+	known   map[string]bool
+	cursor  uint64
+}
+
+// Sync upgrades the request to a websocket where clients subscribe to the rows of resources and receive
+// a snapshot followed by a JSON Patch for each change of the rows, see SyncRequest and SyncMessage:
+//
+//	→ {"type": "subscribe", "id": "open-orders", "resource": "order", "filters": "status=open"}
+//	← {"type": "snapshot", "id": "open-orders", "cursor": 41, "data": {"7": {...}, "9": {...}}}
+//	← {"type": "patch", "id": "open-orders", "cursor": 42, "patch": [{"op": "remove", "path": "/7"}]}
+//
+// Permissions and policies of the resources apply to the user of the upgrade request. A client reconnecting
+// within the changes kept by ChangeLogSize subscribes with its last cursor to receive the missed changes as
+// a patch, otherwise a new snapshot is sent. Removals of rows unknown to the client may be sent after a resume
+// and are to be ignored. Changes are those made on the same instance.
+func Sync(request *evo.Request) interface{} {
+	var key = request.Header("Sec-WebSocket-Key")
+	if !strings.EqualFold(request.Header("Upgrade"), "websocket") || key == "" {
+		return ErrorNotWebSocket
+	}
+	var session = &syncSession{
+		user:          request.User(),
+		tenant:        TenantResolver(request),
+		locale:        requestLocale(request),
+		logger:        logger.FromRequest(request),
+		subscriptions: map[string]*syncSubscription{},
+	}
+	var ctx = request.Context.Context()
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(conn net.Conn) {
+		session.run(newWSConn(conn), key)
+	})
+	return nil
+}
+
+func (s *syncSession) run(conn *wsConn, key string) {
+	if conn.handshake(key) != nil {
+		return
+	}
+	s.conn = conn
+	s.changes = newChangeSubscription()
+	defer func() {
+		for _, subscription := range s.subscriptions {
+			s.changes.unwatch(subscription.context.Action.Resource.Table)
+		}
+	}()
+
+	var requests = make(chan SyncRequest)
+	var stop = make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(requests)
+		for {
+			message, err := conn.read()
+			if err != nil {
+				return
+			}
+			var request SyncRequest
+			if err := json.Unmarshal(message, &request); err != nil {
+				request = SyncRequest{Type: "invalid"}
+			}
+			select {
+			case requests <- request:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var ping = time.NewTicker(SyncPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case request, ok := <-requests:
+			if !ok {
+				return
+			}
+			err = s.handle(request)
+		case change := <-s.changes.changes:
+			if s.changes.lost.Swap(false) {
+				err = s.resnapshot()
+			} else {
+				err = s.apply(change)
+			}
+		case <-ping.C:
+			if s.changes.lost.Swap(false) {
+				err = s.resnapshot()
+			} else {
+				err = conn.write(wsPing, nil)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle answers a message of the client. Errors of the message are sent to the client, only write errors
+// are returned.
+func (s *syncSession) handle(request SyncRequest) error {
+	var err error
+	switch request.Type {
+	case "subscribe":
+		err = s.subscribe(request)
+	case "unsubscribe":
+		s.unsubscribe(request.ID)
+	default:
+		err = fmt.Errorf("invalid message type %q", request.Type)
+	}
+	if err != nil {
+		var message = SyncMessage{Type: "error", ID: request.ID, Error: i18n.Translate(s.locale, err)}
+		if err := s.conn.writeJSON(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// context returns a context of the resource acting as the user of the session.
+func (s *syncSession) context(resource *Resource) *Context {
+	var tenant = s.tenant
+	var locale = s.locale
+	return &Context{
+		Action:   &Endpoint{Name: "SYNC", Resource: resource, Object: resource.Object},
+		Object:   resource.Object,
+		Schema:   resource.Schema,
+		Response: &Pagination{},
+		user:     s.user,
+		tenant:   &tenant,
+		locale:   &locale,
+		logger:   s.logger.With("tenant", tenant, "resource", resource.Table),
+	}
+}
+
+func findResource(table string) (*Resource, error) {
+	for _, resource := range resources.all() {
+		if resource.Table == table && resource.Feature.EnableAPI && !resource.Feature.DisableView {
+			return resource, nil
+		}
+	}
+	return nil, fmt.Errorf("resource %s: %w", table, ErrorObjectNotExist)
+}
+
+func (s *syncSession) subscribe(request SyncRequest) error {
+	if request.ID == "" || request.Resource == "" {
+		return ErrorInvalidSubscription
+	}
+	resource, err := findResource(request.Resource)
+	if err != nil {
+		return err
+	}
+	var context = s.context(resource)
+	if !context.flagged() {
+		return fmt.Errorf("resource %s: %w", request.Resource, ErrorObjectNotExist)
+	}
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var sample = context.GetObject().Addr().Interface()
+	base, err := filterMapper(request.Filters, context, context.GetDBO().Model(sample))
+	if err != nil {
+		return err
+	}
+	s.unsubscribe(request.ID)
+	var subscription = &syncSubscription{
+		id:      request.ID,
+		context: context,
+		base:    context.ApplyPolicies(base).Session(&gorm.Session{}),
+	}
+	s.subscriptions[request.ID] = subscription
+	// changes are received from now on, the ones already reflected by the snapshot are skipped by cursor
+	s.changes.watch(resource.Table)
+	logChanges(resource.Table)
+
+	if request.Cursor > 0 {
+		if changes, ok := changesSince(resource.Table, request.Cursor); ok {
+			return s.resume(subscription, request.Cursor, changes)
+		}
+	}
+	return s.snapshot(subscription)
+}
+
+func (s *syncSession) unsubscribe(id string) {
+	var subscription, ok = s.subscriptions[id]
+	if !ok {
+		return
+	}
+	delete(s.subscriptions, id)
+	var table = subscription.context.Action.Resource.Table
+	for _, other := range s.subscriptions {
+		if other.context.Action.Resource.Table == table {
+			// the table is still watched by another subscription
+			return
+		}
+	}
+	s.changes.unwatch(table)
+}
+
+// snapshot sends the rows of the subscription.
+func (s *syncSession) snapshot(subscription *syncSubscription) error {
+	subscription.cursor = currentCursor()
+	rows, truncated, err := subscription.rows()
+	if err != nil {
+		return s.conn.writeJSON(SyncMessage{Type: "error", ID: subscription.id, Error: i18n.Translate(s.locale, err)})
+	}
+	subscription.known = map[string]bool{}
+	for key := range rows {
+		subscription.known[key] = true
+	}
+	return s.conn.writeJSON(SyncMessage{Type: "snapshot", ID: subscription.id, Cursor: subscription.cursor, Data: rows, Truncated: truncated})
+}
+
+// resnapshot sends a new snapshot of every subscription, once changes were missed.
+func (s *syncSession) resnapshot() error {
+	for _, subscription := range s.subscriptions {
+		if err := s.snapshot(subscription); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resume sends the changes made after the cursor as a single patch.
+func (s *syncSession) resume(subscription *syncSubscription, cursor uint64, changes []Change) error {
+	subscription.cursor = currentCursor()
+	rows, _, err := subscription.rows()
+	if err != nil {
+		return err
+	}
+	subscription.known = map[string]bool{}
+	for key := range rows {
+		subscription.known[key] = true
+	}
+	var patch = []PatchOperation{}
+	for _, change := range latestChanges(changes) {
+		if !subscription.context.visibleChange(&change) {
+			continue
+		}
+		if change.Action == ChangeReset {
+			return s.snapshot(subscription)
+		}
+		var key = subscription.key(change.Keys)
+		if row, ok := rows[key]; ok {
+			patch = append(patch, PatchOperation{Op: "add", Path: jsonPointer(key), Value: row})
+		} else {
+			patch = append(patch, PatchOperation{Op: "remove", Path: jsonPointer(key)})
+		}
+	}
+	return s.conn.writeJSON(SyncMessage{Type: "patch", ID: subscription.id, Cursor: subscription.cursor, Patch: patch})
+}
+
+// apply sends the patch of the change to the subscriptions of its table.
+func (s *syncSession) apply(change Change) error {
+	for _, subscription := range s.subscriptions {
+		if subscription.context.Action.Resource.Table != change.Table || change.Cursor <= subscription.cursor {
+			continue
+		}
+		if !subscription.context.visibleChange(&change) {
+			subscription.cursor = change.Cursor
+			continue
+		}
+		if change.Action == ChangeReset {
+			if err := s.snapshot(subscription); err != nil {
+				return err
+			}
+			continue
+		}
+		subscription.cursor = change.Cursor
+		if patch := subscription.patch(change); len(patch) > 0 {
+			if err := s.conn.writeJSON(SyncMessage{Type: "patch", ID: subscription.id, Cursor: change.Cursor, Patch: patch}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// patch returns the operations updating the rows known to the client with the change.
+func (subscription *syncSubscription) patch(change Change) []PatchOperation {
+	var key = subscription.key(change.Keys)
+	if change.Action != ChangeDelete {
+		subscription.context.loadChange(subscription.base, &change)
+	}
+	if change.Action == ChangeDelete || change.Action == ChangeRemove {
+		if !subscription.known[key] {
+			return nil
+		}
+		delete(subscription.known, key)
+		return []PatchOperation{{Op: "remove", Path: jsonPointer(key)}}
+	}
+	subscription.known[key] = true
+	return []PatchOperation{{Op: "add", Path: jsonPointer(key), Value: subscription.value(change.Data)}}
+}
+
+// rows returns the rows of the subscription keyed by primary key.
+func (subscription *syncSubscription) rows() (map[string]interface{}, bool, error) {
+	var context = subscription.context
+	var slice = context.GetObjectSlice()
+	var query = subscription.base
+	for _, field := range context.Schema.PrimaryFields {
+		query = query.Order(field.DBName)
+	}
+	if err := query.Limit(SyncSnapshotSize + 1).Find(slice.Addr().Interface()).Error; err != nil {
+		return nil, false, err
+	}
+	var truncated = slice.Len() > SyncSnapshotSize
+	var rows = map[string]interface{}{}
+	for i := 0; i < slice.Len() && i < SyncSnapshotSize; i++ {
+		var ptr = slice.Index(i).Addr().Interface()
+		context.afterRead(ptr)
+		var keys = map[string]interface{}{}
+		for _, field := range context.Schema.PrimaryFields {
+			keys[jsonName(field.Tag.Get("json"), field.Name)], _ = field.ValueOf(context.ctx(), slice.Index(i))
+		}
+		rows[subscription.key(keys)] = subscription.value(ptr)
+	}
+	return rows, truncated, nil
+}
+
+// key returns the key of a row in the snapshot, the primary key values joined by comma.
+func (subscription *syncSubscription) key(keys map[string]interface{}) string {
+	var resource = subscription.context.Action.Resource
+	var values []string
+	for _, field := range subscription.context.Schema.PrimaryFields {
+		var value = keys[jsonName(field.Tag.Get("json"), field.Name)]
+		if resource.Feature.ObfuscateID {
+			if n, err := toUint64(value); err == nil {
+				values = append(values, resource.IDEncoder().Encode(n))
+				continue
+			}
+		}
+		values = append(values, fmt.Sprint(value))
+	}
+	return strings.Join(values, ",")
+}
+
+// value returns the row as sent to the client.
+func (subscription *syncSubscription) value(row interface{}) interface{} {
+	if subscription.context.Action.Resource.Feature.ObfuscateID {
+		return subscription.context.Action.Resource.obfuscateIDs(row)
+	}
+	return row
+}
+
+// latestChanges returns the last change of each row, in the order of the changes.
+func latestChanges(changes []Change) []Change {
+	var last = map[string]int{}
+	for i, change := range changes {
+		last[fmt.Sprint(change.Keys)] = i
+	}
+	var result []Change
+	for i, change := range changes {
+		if last[fmt.Sprint(change.Keys)] == i {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// jsonPointer returns the JSON Pointer of the member with the given key.
+func jsonPointer(key string) string {
+	return "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func toUint64(value interface{}) (uint64, error) {
+	var v = reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() >= 0 {
+			return uint64(v.Int()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	}
+	return 0, fmt.Errorf("%v is not an unsigned integer", value)
+}
@@ -0,0 +1,222 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWSAccept(t *testing.T) {
+	// example of RFC 6455
+	if got := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("wsAccept() = %s", got)
+	}
+}
+
+// clientFrame returns a masked frame as sent by the clients.
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	var header = wsFrameHeader(opcode, len(payload))
+	if !fin {
+		header[0] &^= 0x80
+	}
+	header[1] |= 0x80
+	var mask = []byte{1, 2, 3, 4}
+	var masked = make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	return append(append(header, mask...), masked...)
+}
+
+func TestWSConnRead(t *testing.T) {
+	var long = bytes.Repeat([]byte("x"), 70000)
+	var tests = []struct {
+		name   string
+		frames [][]byte
+		want   string
+		pong   bool
+	}{
+		{"text", [][]byte{clientFrame(true, wsText, []byte(`{"type":"subscribe"}`))}, `{"type":"subscribe"}`, false},
+		{"fragmented", [][]byte{clientFrame(false, wsText, []byte("hel")), clientFrame(true, wsContinuation, []byte("lo"))}, "hello", false},
+		{"ping", [][]byte{clientFrame(true, wsPing, []byte("p")), clientFrame(true, wsText, []byte("after"))}, "after", true},
+		{"medium", [][]byte{clientFrame(true, wsText, long[:300])}, string(long[:300]), false},
+		{"long", [][]byte{clientFrame(true, wsText, long)}, string(long), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+			go func() {
+				for _, frame := range tt.frames {
+					client.Write(frame)
+				}
+			}()
+			var pong = make(chan []byte, 1)
+			if tt.pong {
+				go func() {
+					var b = make([]byte, 3)
+					n, _ := client.Read(b)
+					pong <- b[:n]
+				}()
+			}
+			message, err := newWSConn(server).read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(message) != tt.want {
+				t.Errorf("read() = %.40q, want %.40q", message, tt.want)
+			}
+			if tt.pong {
+				if got := <-pong; !bytes.Equal(got, []byte{0x80 | wsPong, 1, 'p'}) {
+					t.Errorf("pong = %v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestWSConnReadClose(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	go func() {
+		client.Write(clientFrame(true, wsClose, nil))
+		client.Read(make([]byte, 2))
+		client.Close()
+	}()
+	if _, err := newWSConn(server).read(); err != errClosed {
+		t.Errorf("read() error = %v, want errClosed", err)
+	}
+}
+
+func TestWSConnReadUnmasked(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	var closed = make(chan []byte, 1)
+	go func() {
+		client.Write(append(wsFrameHeader(wsText, 2), "hi"...))
+		var b = make([]byte, 4)
+		n, _ := io.ReadFull(client, b)
+		closed <- b[:n]
+		client.Close()
+	}()
+	if _, err := newWSConn(server).read(); err != ErrorUnmaskedFrame {
+		t.Errorf("read() error = %v, want ErrorUnmaskedFrame", err)
+	}
+	if got := <-closed; !bytes.Equal(got, []byte{0x80 | wsClose, 2, 0x03, 0xEA}) {
+		t.Errorf("close = %v", got)
+	}
+}
+
+func TestWSConnReadTimeout(t *testing.T) {
+	var timeout = WebSocketReadTimeout
+	WebSocketReadTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { WebSocketReadTimeout = timeout })
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	var err error
+	var done = make(chan struct{})
+	go func() {
+		_, err = newWSConn(server).read()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if err == nil {
+			t.Error("read() error = nil, want a timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read() did not time out")
+	}
+}
+
+func TestWSFrameHeader(t *testing.T) {
+	var tests = []struct {
+		length int
+		want   []byte
+	}{
+		{5, []byte{0x81, 5}},
+		{126, []byte{0x81, 126, 0, 126}},
+		{65536, []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := wsFrameHeader(wsText, tt.length); !bytes.Equal(got, tt.want) {
+			t.Errorf("wsFrameHeader(%d) = %v, want %v", tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	var saved = ChangeLogSize
+	ChangeLogSize = 2
+	defer func() {
+		ChangeLogSize = saved
+		delete(changeLogs, "sync_test")
+	}()
+	var start = currentCursor()
+	logChanges("sync_test")
+	for i := 1; i <= 3; i++ {
+		publishChange(Change{Action: ChangeUpdate, Table: "sync_test", Keys: map[string]interface{}{"id": i}})
+	}
+	var tests = []struct {
+		cursor uint64
+		want   string
+		ok     bool
+	}{
+		{start, "[1 2 3]", true},
+		{start + 2, "[3]", true},
+		{start + 3, "[]", true},
+		{start + 4, "[]", false},
+	}
+	for _, tt := range tests {
+		changes, ok := changesSince("sync_test", tt.cursor)
+		var ids = []interface{}{}
+		for _, change := range changes {
+			ids = append(ids, change.Keys["id"])
+		}
+		if fmt.Sprint(ids) != tt.want || ok != tt.ok {
+			t.Errorf("changesSince(%d) = %v, %v, want %s, %v", tt.cursor-start, ids, ok, tt.want, tt.ok)
+		}
+	}
+	// the log is trimmed once it holds twice ChangeLogSize changes
+	publishChange(Change{Action: ChangeDelete, Table: "sync_test", Keys: map[string]interface{}{"id": 4}})
+	if _, ok := changesSince("sync_test", start+1); ok {
+		t.Errorf("changesSince() before the trimmed changes should fail")
+	}
+	if changes, ok := changesSince("sync_test", start+2); !ok || len(changes) != 2 {
+		t.Errorf("changesSince() after the trimmed changes = %v, %v", changes, ok)
+	}
+}
+
+func TestLatestChanges(t *testing.T) {
+	var changes = []Change{
+		{Action: ChangeCreate, Keys: map[string]interface{}{"id": 1}},
+		{Action: ChangeUpdate, Keys: map[string]interface{}{"id": 2}},
+		{Action: ChangeDelete, Keys: map[string]interface{}{"id": 1}},
+	}
+	var got []string
+	for _, change := range latestChanges(changes) {
+		got = append(got, fmt.Sprint(change.Action, change.Keys["id"]))
+	}
+	if fmt.Sprint(got) != "[update2 delete1]" {
+		t.Errorf("latestChanges() = %v", got)
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	var tests = map[string]string{
+		"7":      "/7",
+		"1,2":    "/1,2",
+		"a/b~c":  "/a~1b~0c",
+		"x9Kd2A": "/x9Kd2A",
+	}
+	for key, want := range tests {
+		if got := jsonPointer(key); got != want {
+			t.Errorf("jsonPointer(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package rest
+
+import (
+	stdcontext "context"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TrashRetention specifies how long soft deleted rows are kept before PurgeTrash deletes them permanently,
+// zero keeps them forever. It is read from the REST.TRASH_RETENTION setting, e.g. 720h.
+var TrashRetention time.Duration
+
+// TrashDefaultSize and TrashMaxSize bound the number of rows returned by the trash endpoint.
+var TrashDefaultSize = 100
+var TrashMaxSize = 1000
+
+// TrashItem is a soft deleted row listed by the trash endpoint. Key is the primary key of the row, comma
+// separated for composite keys.
+type TrashItem struct {
+	Resource  string      `json:"resource"`
+	Key       string      `json:"key"`
+	DeletedAt time.Time   `json:"deleted_at"`
+	Data      interface{} `json:"data"`
+}
+
+// deletedAtField returns the deleted_at column of the soft deleted models, those implementing Delete(bool)
+// or using gorm.DeletedAt.
+func deletedAtField(s *schema.Schema, object interface{}) *schema.Field {
+	var field = s.LookUpField("deleted_at")
+	if field == nil || field.DBName == "" {
+		return nil
+	}
+	if _, ok := object.(interface{ Delete(v bool) }); ok {
+		return field
+	}
+	if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+		return field
+	}
+	return nil
+}
+
+// deletedTime returns the time of a deleted_at value.
+func deletedTime(v interface{}) time.Time {
+	switch value := v.(type) {
+	case time.Time:
+		return value
+	case *time.Time:
+		if value != nil {
+			return *value
+		}
+	case gorm.DeletedAt:
+		return value.Time
+	}
+	return time.Time{}
+}
+
+// trashEndpoints returns the endpoints restoring and purging the soft deleted rows of a resource.
+func trashEndpoints() []*Endpoint {
+	return []*Endpoint{
+		{
+			Name:        "RESTORE",
+			Method:      POST,
+			URL:         "/restore",
+			PKUrl:       true,
+			Handler:     Restore,
+			Description: "restore a soft deleted object",
+			Permissions: []acl.Permission{DeletePermission},
+		},
+		{
+			Name:        "PURGE",
+			Method:      DELETE,
+			URL:         "/purge",
+			PKUrl:       true,
+			Handler:     Purge,
+			Description: "permanently delete a soft deleted object",
+			Permissions: []acl.Permission{DeletePermission},
+		},
+	}
+}
+
+// deleted returns the query of the soft deleted rows of the resource visible to the user.
+func (context *Context) deleted(field *schema.Field) *gorm.DB {
+	var query = context.GetDBO().Unscoped().Model(context.GetObject().Addr().Interface())
+	return context.ApplyPolicies(query).Where(context.Schema.Table + "." + field.DBName + " IS NOT NULL")
+}
+
+// Trash lists the soft deleted rows of every resource the user can view, latest first.
+// ?resource= restricts the list to a resource and ?size= sets the number of rows, TrashDefaultSize by default.
+func Trash(request *evo.Request) interface{} {
+	if request.User().Anonymous() {
+		return ErrorUnauthorized
+	}
+	var size = request.Query("size").Int()
+	if size <= 0 {
+		size = TrashDefaultSize
+	}
+	if size > TrashMaxSize {
+		size = TrashMaxSize
+	}
+	var only = request.Query("resource").String()
+	var items = []TrashItem{}
+	for _, resource := range Resources() {
+		if only != "" && resource.Table != only {
+			continue
+		}
+		var context = resource.NewContext(request, "TRASH")
+		var field = deletedAtField(resource.Schema, context.GetObject().Addr().Interface())
+		if field == nil || !context.Readable() {
+			continue
+		}
+		var slice = context.GetObjectSlice()
+		if err := context.deleted(field).Order(field.DBName + " DESC").Limit(size).Find(slice.Addr().Interface()).Error; err != nil {
+			context.Logger().Error("unable to list deleted rows", "error", err.Error())
+			continue
+		}
+		for i := 0; i < slice.Len(); i++ {
+			var row = slice.Index(i)
+			context.ReadRow(row)
+			v, _ := field.ValueOf(stdcontext.Background(), row)
+			items = append(items, TrashItem{
+				Resource:  resource.Table,
+				Key:       context.rowID(row),
+				DeletedAt: deletedTime(v),
+				Data:      resource.Output(row.Addr().Interface()),
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	if len(items) > size {
+		items = items[:size]
+	}
+	return items
+}
+
+// findDeleted reads the soft deleted row given by the primary key of the request.
+func (context *Context) findDeleted(ptr interface{}) (*schema.Field, error) {
+	var field = deletedAtField(context.Schema, ptr)
+	if field == nil {
+		return nil, ErrorObjectNotExist
+	}
+	where, params, err := context.primaryKeyConditions(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if context.deleted(field).Where(strings.Join(where, " AND "), params...).Take(ptr).RowsAffected == 0 {
+		return nil, ErrorObjectNotExist
+	}
+	return field, nil
+}
+
+// Restore clears the deletion of the soft deleted row given by the primary key.
+func Restore(context *Context) error {
+	if err := context.HasPerm("DELETE"); err != nil {
+		return err
+	}
+	var object = context.GetObject()
+	var ptr = object.Addr().Interface()
+	field, err := context.findDeleted(ptr)
+	if err != nil {
+		return err
+	}
+	var values = map[string]interface{}{field.DBName: nil}
+	if deleted := context.Schema.LookUpField("deleted"); deleted != nil && deleted.DBName != "" {
+		values[deleted.DBName] = false
+	}
+	if err := context.GetDBO().Unscoped().Model(ptr).Updates(values).Error; err != nil {
+		return err
+	}
+	if obj, ok := ptr.(interface{ Delete(v bool) }); ok {
+		obj.Delete(false)
+	} else {
+		_ = field.Set(context.ctx(), object, gorm.DeletedAt{})
+	}
+	context.Response.Data = ptr
+	return nil
+}
+
+// Purge permanently deletes the soft deleted row given by the primary key.
+func Purge(context *Context) error {
+	if err := context.HasPerm("DELETE"); err != nil {
+		return err
+	}
+	var ptr = context.GetObject().Addr().Interface()
+	if _, err := context.findDeleted(ptr); err != nil {
+		return err
+	}
+	return context.GetDBO().Unscoped().Delete(ptr).Error
+}
+
+// PurgeTrash permanently deletes the rows soft deleted more than TrashRetention ago.
+func PurgeTrash(ctx stdcontext.Context) error {
+	if TrashRetention <= 0 {
+		return nil
+	}
+	var before = time.Now().Add(-TrashRetention)
+	var seen = map[string]bool{}
+	for _, resource := range resources.all() {
+		if seen[resource.Table] {
+			continue
+		}
+		seen[resource.Table] = true
+		var object = reflect.New(resource.Object.Type()).Interface()
+		var field = deletedAtField(resource.Schema, object)
+		if field == nil {
+			continue
+		}
+		var result = db.WithContext(ctx).Unscoped().Where(field.DBName+" < ?", before).Delete(object)
+		if result.Error != nil {
+			logger.Error("unable to purge deleted rows", "resource", resource.Table, "error", result.Error.Error())
+			continue
+		}
+		if result.RowsAffected > 0 {
+			logger.Info("purged deleted rows", "resource", resource.Table, "rows", result.RowsAffected)
+		}
+	}
+	return nil
+}
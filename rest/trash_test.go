@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type trashSoftRow struct {
+	ID        int        `gorm:"column:id;primaryKey"`
+	Deleted   bool       `gorm:"column:deleted"`
+	DeletedAt *time.Time `gorm:"column:deleted_at"`
+}
+
+func (r *trashSoftRow) Delete(v bool) {
+	r.Deleted = v
+}
+
+type trashGormRow struct {
+	ID        int            `gorm:"column:id;primaryKey"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at"`
+}
+
+type trashPlainRow struct {
+	ID        int        `gorm:"column:id;primaryKey"`
+	DeletedAt *time.Time `gorm:"column:deleted_at"`
+}
+
+func TestDeletedAtField(t *testing.T) {
+	var tests = []struct {
+		object interface{}
+		want   bool
+	}{
+		{&trashSoftRow{}, true},
+		{&trashGormRow{}, true},
+		{&trashPlainRow{}, false},
+	}
+	for _, test := range tests {
+		s, err := schema.Parse(test.object, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := deletedAtField(s, test.object) != nil; got != test.want {
+			t.Errorf("deletedAtField(%T) = %v, want %v", test.object, got, test.want)
+		}
+	}
+}
+
+func TestDeletedTime(t *testing.T) {
+	var at = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		value interface{}
+		want  time.Time
+	}{
+		{at, at},
+		{&at, at},
+		{(*time.Time)(nil), time.Time{}},
+		{gorm.DeletedAt{Time: at, Valid: true}, at},
+		{nil, time.Time{}},
+	}
+	for _, test := range tests {
+		if got := deletedTime(test.value); !got.Equal(test.want) {
+			t.Errorf("deletedTime(%v) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
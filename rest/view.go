@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrorUnknownView is returned when the ?view= query names a view the resource does not define.
+var ErrorUnknownView = errors.New("unknown view")
+
+// SummaryView and DetailView name the views applied when no ?view= is given: the list endpoints (ALL and
+// PAGINATE) apply the summary view and GET applies the detail view, when the model defines them.
+var SummaryView = "summary"
+var DetailView = "detail"
+
+// View is a named serialization of the rows of a resource, selected by the ?view= query of the list and GET
+// endpoints, so lists return lightweight rows while GET returns the full graph. Models define their views by
+// a RESTViews() map[string]View method:
+//
+//	func (Order) RESTViews() map[string]rest.View {
+//		return map[string]rest.View{
+//			"summary": {Fields: []string{"number", "status", "total"}},
+//			"detail":  {Associations: []string{"Customer", "Lines.Product"}},
+//		}
+//	}
+//
+// Fields lists the columns, by column or JSON name, and the computed fields of the rows; the primary keys are
+// always returned and every field is returned when Fields is empty. Associations lists the relations to
+// preload, as in the ?associations= query. A view replaces the fields, associations and join queries.
+type View struct {
+	Fields       []string
+	Associations []string
+}
+
+// views returns the views defined by the RESTViews() map[string]View method of the model.
+func (context *Context) views() map[string]View {
+	if obj, ok := context.GetObject().Addr().Interface().(interface{ RESTViews() map[string]View }); ok {
+		return obj.RESTViews()
+	}
+	return nil
+}
+
+// resolveView returns the view given by the ?view= query, or else the default view of the action, if any.
+// Views apply to the ALL, PAGINATE and GET endpoints only.
+func (context *Context) resolveView() (*View, error) {
+	var name = context.Request.Query("view").String()
+	var fallback string
+	switch context.Action.Name {
+	case "ALL", "PAGINATE":
+		fallback = SummaryView
+	case "GET":
+		fallback = DetailView
+	default:
+		return nil, nil
+	}
+	var views = context.views()
+	if name == "" {
+		if view, ok := views[fallback]; ok {
+			return &view, nil
+		}
+		return nil, nil
+	}
+	if view, ok := views[name]; ok {
+		return &view, nil
+	}
+	return nil, ErrorUnknownView
+}
+
+// applyView selects the columns and preloads the associations of the view, returning whether a view applies.
+func (context *Context) applyView(query *gorm.DB) (*gorm.DB, bool, error) {
+	view, err := context.resolveView()
+	if err != nil || view == nil {
+		return query, false, err
+	}
+	context.view = view
+	if columns := viewColumns(context.Schema, view); len(columns) > 0 {
+		query = query.Select(columns)
+	}
+	for _, association := range view.Associations {
+		query = query.Preload(association)
+	}
+	return query, true, nil
+}
+
+// viewColumns returns the columns selected by the view: its fields, the primary keys and the foreign keys of
+// the belongs-to associations it preloads. It returns nil when the view returns every field.
+func viewColumns(s *schema.Schema, view *View) []string {
+	if len(view.Fields) == 0 {
+		return nil
+	}
+	var columns []string
+	var seen = map[string]bool{}
+	var add = func(field *schema.Field) {
+		if field != nil && field.DBName != "" && !seen[field.DBName] {
+			seen[field.DBName] = true
+			columns = append(columns, "`"+s.Table+"`.`"+field.DBName+"`")
+		}
+	}
+	for _, field := range s.PrimaryFields {
+		add(field)
+	}
+	for _, name := range view.Fields {
+		add(findField(s, strings.TrimSpace(name)))
+	}
+	for _, association := range view.Associations {
+		var relation, ok = s.Relationships.Relations[strings.Split(association, ".")[0]]
+		if !ok || relation.Type != schema.BelongsTo {
+			continue
+		}
+		for _, ref := range relation.References {
+			add(ref.ForeignKey)
+		}
+	}
+	return columns
+}
+
+// viewKeys returns the keys of the rows returned by the view, nil when the view returns every field.
+func viewKeys(s *schema.Schema, view *View) map[string]bool {
+	if len(view.Fields) == 0 {
+		return nil
+	}
+	var keys = map[string]bool{}
+	for _, field := range s.PrimaryFields {
+		keys[jsonName(field.Tag.Get("json"), field.Name)] = true
+	}
+	for _, name := range view.Fields {
+		name = strings.TrimSpace(name)
+		if field := findField(s, name); field != nil {
+			keys[jsonName(field.Tag.Get("json"), field.Name)] = true
+		} else {
+			// computed fields
+			keys[name] = true
+		}
+	}
+	for _, association := range view.Associations {
+		if relation, ok := s.Relationships.Relations[strings.Split(association, ".")[0]]; ok {
+			keys[jsonName(relation.Field.Tag.Get("json"), relation.Field.Name)] = true
+		}
+	}
+	return keys
+}
+
+// project returns the rows of the response data with the keys of the view only: a row or a slice of rows.
+func project(data interface{}, keys map[string]bool) interface{} {
+	var value = genericJSON(data)
+	if value == nil {
+		return data
+	}
+	var pick = func(row interface{}) interface{} {
+		if m, ok := row.(map[string]interface{}); ok {
+			for key := range m {
+				if !keys[key] {
+					delete(m, key)
+				}
+			}
+		}
+		return row
+	}
+	if list, ok := value.([]interface{}); ok {
+		for i := range list {
+			list[i] = pick(list[i])
+		}
+		return list
+	}
+	return pick(value)
+}
+
+// withView returns the response data with the fields of the view applied by the handler only.
+func (context *Context) withView(data interface{}) interface{} {
+	if context.view == nil {
+		return data
+	}
+	var keys = viewKeys(context.Schema, context.view)
+	if keys == nil {
+		return data
+	}
+	return project(data, keys)
+}
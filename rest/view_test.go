@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type viewCustomer struct {
+	ID   int    `gorm:"column:id;primaryKey"`
+	Name string `gorm:"column:name"`
+}
+
+type viewOrder struct {
+	ID         int           `gorm:"column:id;primaryKey" json:"id"`
+	Number     string        `gorm:"column:number" json:"number"`
+	Note       string        `gorm:"column:note" json:"note"`
+	CustomerID int           `gorm:"column:customer_id" json:"customer_id"`
+	Customer   *viewCustomer `json:"customer"`
+}
+
+func TestViewColumns(t *testing.T) {
+	s, err := schema.Parse(&viewOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		view View
+		want []string
+	}{
+		{View{}, nil},
+		{View{Associations: []string{"Customer"}}, nil},
+		{View{Fields: []string{"number"}}, []string{"`view_orders`.`id`", "`view_orders`.`number`"}},
+		{View{Fields: []string{"Note", "total"}}, []string{"`view_orders`.`id`", "`view_orders`.`note`"}},
+		{View{Fields: []string{"number"}, Associations: []string{"Customer"}}, []string{"`view_orders`.`id`", "`view_orders`.`number`", "`view_orders`.`customer_id`"}},
+	}
+	for _, test := range tests {
+		if got := viewColumns(s, &test.view); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("viewColumns(%v) = %v, want %v", test.view, got, test.want)
+		}
+	}
+}
+
+func TestViewKeys(t *testing.T) {
+	s, err := schema.Parse(&viewOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := viewKeys(s, &View{Associations: []string{"Customer"}}); keys != nil {
+		t.Errorf("viewKeys without fields = %v, want nil", keys)
+	}
+	var got = viewKeys(s, &View{Fields: []string{"Number", "total"}, Associations: []string{"Customer.Orders"}})
+	var want = map[string]bool{"id": true, "number": true, "total": true, "customer": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("viewKeys = %v, want %v", got, want)
+	}
+}
+
+func TestProject(t *testing.T) {
+	var keys = map[string]bool{"id": true, "number": true}
+	var tests = []struct {
+		data interface{}
+		want string
+	}{
+		{viewOrder{ID: 1, Number: "A1", Note: "x"}, `{"id":1,"number":"A1"}`},
+		{&viewOrder{ID: 9007199254740993, Number: "A2"}, `{"id":9007199254740993,"number":"A2"}`},
+		{[]viewOrder{{ID: 1, Number: "A1"}, {ID: 2, Number: "A2"}}, `[{"id":1,"number":"A1"},{"id":2,"number":"A2"}]`},
+		{map[string]interface{}{"id": 3, "total": 5}, `{"id":3}`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(project(test.data, keys))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.want {
+			t.Errorf("project(%v) = %s, want %s", test.data, b, test.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes, see RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsGUID is appended to the key of the handshake to compute the accept header.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketMaxMessageSize bounds the size of the messages received from the clients.
+var WebSocketMaxMessageSize int64 = 1 << 20
+
+// WebSocketWriteTimeout limits the time of writing a message to a client.
+var WebSocketWriteTimeout = 10 * time.Second
+
+// WebSocketReadTimeout closes connections not sending any frame for this long. Clients answer the pings sent
+// every SyncPingInterval, so it must be longer than that interval.
+var WebSocketReadTimeout = 75 * time.Second
+
+// ErrorMessageTooLarge is returned when a client sends a message larger than WebSocketMaxMessageSize.
+var ErrorMessageTooLarge = errors.New("websocket message too large")
+
+// ErrorUnmaskedFrame is returned when a client sends a frame without masking its payload.
+var ErrorUnmaskedFrame = errors.New("websocket frame not masked")
+
+// errClosed is returned by read once the client closes the connection.
+var errClosed = errors.New("websocket closed")
+
+// wsAccept returns the Sec-WebSocket-Accept header of the handshake with the given key.
+func wsAccept(key string) string {
+	var h = sha1.Sum([]byte(strings.TrimSpace(key) + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsConn is a server side websocket connection. Reads happen on a single goroutine, writes are serialized.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+}
+
+func newWSConn(conn net.Conn) *wsConn {
+	return &wsConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// handshake writes the response switching the connection to the websocket protocol.
+func (c *wsConn) handshake(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+wsAccept(key)+"\r\n\r\n")
+	return err
+}
+
+// read returns the next data message, answering pings and joining fragmented messages. Unmasked frames close
+// the connection with a protocol error as required of servers by RFC 6455.
+func (c *wsConn) read() ([]byte, error) {
+	var message []byte
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(WebSocketReadTimeout))
+		fin, opcode, payload, err := c.readFrame()
+		if err == ErrorUnmaskedFrame {
+			_ = c.write(wsClose, []byte{0x03, 0xEA})
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.write(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsClose:
+			_ = c.write(wsClose, payload)
+			return nil, errClosed
+		case wsText, wsBinary, wsContinuation:
+			if int64(len(message)+len(payload)) > WebSocketMaxMessageSize {
+				return nil, ErrorMessageTooLarge
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		}
+	}
+}
+
+// readFrame reads a frame, unmasking the payload of the client. Frames of clients must be masked.
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	var fin = header[0]&0x80 != 0
+	var opcode = header[0] & 0x0F
+	var masked = header[1]&0x80 != 0
+	var length = int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if length < 0 || length > WebSocketMaxMessageSize {
+		return false, 0, nil, ErrorMessageTooLarge
+	}
+	if !masked {
+		return false, 0, nil, ErrorUnmaskedFrame
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	var payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// write sends a single unmasked frame.
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(WebSocketWriteTimeout))
+	_, err := c.conn.Write(append(wsFrameHeader(opcode, len(payload)), payload...))
+	return err
+}
+
+// writeJSON sends the value as a text message.
+func (c *wsConn) writeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(wsText, b)
+}
+
+func wsFrameHeader(opcode byte, length int) []byte {
+	var header = []byte{0x80 | opcode}
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	return header
+}
@@ -0,0 +1,283 @@
+// Package resttest serves the rest endpoints of chosen models from an in-memory SQLite database, so the
+// downstream projects can test their resources without standing up MySQL:
+//
+//	func TestOrders(t *testing.T) {
+//		var db = resttest.Setup(t, &Order{})
+//		db.Create(&Order{Number: "A-1"})
+//		var page = resttest.Get[[]Order](t, "/admin/rest/orders/paginate?size=10")
+//		if page.Total != 1 || page.Data[0].Number != "A-1" {
+//			t.Fatalf("unexpected page %+v", page)
+//		}
+//	}
+//
+// The evo router and the rest app are set up once per test binary; the rows of the models are deleted when the
+// test ends. The typed helpers decode the default Pagination envelope of the rest endpoints, see rest.Envelope.
+package resttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// DSN is the SQLite database the endpoints are served from. It must be set before the first Setup.
+var DSN = "file:resttest?mode=memory&cache=shared"
+
+// Pagination is the envelope of the rest endpoints with the data decoded as T, e.g. []Order for the list
+// endpoints and Order for GET.
+type Pagination[T any] struct {
+	Total        int64  `json:"total"`
+	Offset       int    `json:"offset"`
+	TotalPages   int    `json:"total_pages"`
+	Page         int    `json:"current_page"`
+	Size         int    `json:"size"`
+	Data         T      `json:"data"`
+	Success      bool   `json:"success"`
+	Error        string `json:"error"`
+	Type         string `json:"type"`
+	Partial      bool   `json:"partial"`
+	PreloadLimit int    `json:"preload_limit,omitempty"`
+	Estimated    bool   `json:"estimated,omitempty"`
+	HasNext      *bool  `json:"has_next,omitempty"`
+	// Status is the HTTP status of the response.
+	Status int `json:"-"`
+}
+
+var (
+	once    sync.Once
+	bootErr error
+	mu      sync.Mutex
+	user    evo.UserInterface
+	header  = http.Header{}
+)
+
+// config is the evo configuration of the harness: no listener, the database in DSN and silent queries.
+const config = `HTTP:
+  Host: 127.0.0.1
+Database:
+  Enabled: true
+  Type: sqlite
+  Database: %q
+  Debug: 1
+`
+
+// boot sets up evo and the rest app, once.
+func boot() error {
+	once.Do(func() {
+		var dir string
+		if dir, bootErr = os.MkdirTemp("", "resttest"); bootErr != nil {
+			return
+		}
+		var path = filepath.Join(dir, "config.yml")
+		if bootErr = os.WriteFile(path, []byte(fmt.Sprintf(config, DSN)), 0600); bootErr != nil {
+			return
+		}
+		// evo reads its configuration from the -c argument
+		var args = os.Args
+		os.Args = []string{args[0], "-c", path}
+		evo.Setup()
+		os.Args = args
+		evo.SetUserInterface(testUser{})
+		var app = rest.App{}
+		if bootErr = app.Register(); bootErr != nil {
+			return
+		}
+		bootErr = app.Router()
+	})
+	return bootErr
+}
+
+// Setup serves the rest endpoints of the models, creating their tables, and returns the database so the test
+// can insert its rows. The models are exposed whether or not they embed rest.API, and their rows are deleted
+// when the test ends.
+func Setup(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	if err := boot(); err != nil {
+		t.Fatalf("resttest: unable to set up: %s", err)
+	}
+	var dbo = evo.GetDBO()
+	mu.Lock()
+	defer mu.Unlock()
+	for _, model := range models {
+		var value = reflect.Indirect(reflect.ValueOf(model)).Interface()
+		if err := dbo.AutoMigrate(value); err != nil {
+			t.Fatalf("resttest: unable to migrate %T: %s", value, err)
+		}
+		if _, err := rest.GetResource(value); err == nil {
+			continue
+		}
+		rest.EnableAPI(value)
+		db.UseModel(value)
+		var m = schema.Models[len(schema.Models)-1]
+		rest.AttachResource(&m)
+	}
+	t.Cleanup(func() {
+		for _, model := range models {
+			dbo.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model)
+		}
+	})
+	return dbo
+}
+
+// AsUser makes the requests of the test made by the user, anonymous by default.
+func AsUser(t testing.TB, u evo.UserInterface) {
+	mu.Lock()
+	user = u
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		user = nil
+		mu.Unlock()
+	})
+}
+
+// WithHeader adds a header to the requests of the test, e.g. rest.TenantHeader.
+func WithHeader(t testing.TB, key string, value string) {
+	mu.Lock()
+	header.Set(key, value)
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		header.Del(key)
+		mu.Unlock()
+	})
+}
+
+// Request sends a request to the endpoints with the body encoded as JSON, unless nil, and returns the status
+// and the body of the response.
+func Request(t testing.TB, method string, url string, body interface{}) (int, []byte) {
+	t.Helper()
+	if evo.GetFiber() == nil {
+		t.Fatalf("resttest: Setup was not called")
+	}
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("resttest: unable to encode the body of %s %s: %s", method, url, err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	var req = httptest.NewRequest(method, url, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	mu.Lock()
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+	mu.Unlock()
+	resp, err := evo.GetFiber().Test(req, -1)
+	if err != nil {
+		t.Fatalf("resttest: %s %s: %s", method, url, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("resttest: unable to read the response of %s %s: %s", method, url, err)
+	}
+	return resp.StatusCode, b
+}
+
+// Do sends a request to the endpoints and returns the envelope of the response, failing the test when the
+// response is not a Pagination envelope. Unlike Get and the other helpers, failed requests are returned, so
+// the test can assert on their status and error.
+func Do[T any](t testing.TB, method string, url string, body interface{}) *Pagination[T] {
+	t.Helper()
+	status, b := Request(t, method, url, body)
+	var envelope = Pagination[json.RawMessage]{}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		t.Fatalf("resttest: %s %s answered %d with an unexpected body %q: %s", method, url, status, b, err)
+	}
+	var page = Pagination[T]{
+		Total:        envelope.Total,
+		Offset:       envelope.Offset,
+		TotalPages:   envelope.TotalPages,
+		Page:         envelope.Page,
+		Size:         envelope.Size,
+		Success:      envelope.Success,
+		Error:        envelope.Error,
+		Type:         envelope.Type,
+		Partial:      envelope.Partial,
+		PreloadLimit: envelope.PreloadLimit,
+		Estimated:    envelope.Estimated,
+		HasNext:      envelope.HasNext,
+		Status:       status,
+	}
+	// failed requests carry no data of T
+	if envelope.Success && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, &page.Data); err != nil {
+			t.Fatalf("resttest: %s %s answered %d with unexpected data %s: %s", method, url, status, envelope.Data, err)
+		}
+	}
+	return &page
+}
+
+// succeed sends a request to the endpoints and returns the envelope of the response, failing the test when
+// the request failed.
+func succeed[T any](t testing.TB, method string, url string, body interface{}) *Pagination[T] {
+	t.Helper()
+	var page = Do[T](t, method, url, body)
+	if page.Status >= http.StatusBadRequest || !page.Success {
+		t.Fatalf("resttest: %s %s answered %d: %s", method, url, page.Status, page.Error)
+	}
+	return page
+}
+
+// Get sends a GET request and returns the envelope of the successful response.
+func Get[T any](t testing.TB, url string) *Pagination[T] {
+	t.Helper()
+	return succeed[T](t, http.MethodGet, url, nil)
+}
+
+// Post sends a POST request with the body and returns the envelope of the successful response.
+func Post[T any](t testing.TB, url string, body interface{}) *Pagination[T] {
+	t.Helper()
+	return succeed[T](t, http.MethodPost, url, body)
+}
+
+// Put sends a PUT request with the body and returns the envelope of the successful response.
+func Put[T any](t testing.TB, url string, body interface{}) *Pagination[T] {
+	t.Helper()
+	return succeed[T](t, http.MethodPut, url, body)
+}
+
+// Patch sends a PATCH request with the body and returns the envelope of the successful response.
+func Patch[T any](t testing.TB, url string, body interface{}) *Pagination[T] {
+	t.Helper()
+	return succeed[T](t, http.MethodPatch, url, body)
+}
+
+// Delete sends a DELETE request and returns the envelope of the successful response.
+func Delete[T any](t testing.TB, url string) *Pagination[T] {
+	t.Helper()
+	return succeed[T](t, http.MethodDelete, url, nil)
+}
+
+// testUser resolves the user of the requests to the one given by AsUser.
+type testUser struct {
+	evo.DefaultUserInterface
+}
+
+func (testUser) FromRequest(request *evo.Request) evo.UserInterface {
+	mu.Lock()
+	defer mu.Unlock()
+	if user != nil {
+		return user
+	}
+	return evo.DefaultUserInterface{}
+}
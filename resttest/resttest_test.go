@@ -0,0 +1,843 @@
+package resttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db/schema"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/circuit"
+	"github.com/iesitalia/toolbox/migration"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+type Gadget struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Name  string `gorm:"size:64" json:"name"`
+	Price int    `json:"price"`
+}
+
+type admin struct {
+	evo.DefaultUserInterface
+}
+
+func (admin) Anonymous() bool {
+	return false
+}
+
+// member is an authenticated user without permissions.
+type member struct {
+	admin
+}
+
+func (member) HasPermission(permission string) bool {
+	return false
+}
+
+// granted is an authenticated user holding the permissions.
+type granted struct {
+	admin
+	uuid        string
+	permissions []string
+}
+
+func (u granted) UUID() string {
+	return u.uuid
+}
+
+func (u granted) HasPermission(permission string) bool {
+	return acl.Match(u.permissions, permission)
+}
+
+func (u granted) Permissions() []string {
+	return u.permissions
+}
+
+func TestEndpoints(t *testing.T) {
+	var db = Setup(t, &Gadget{})
+	if page := Get[[]Gadget](t, "/admin/rest/gadgets/paginate?size=2"); page.Total != 0 || page.TotalPages != 0 {
+		t.Fatalf("expected no pages for an empty result, got %+v", page)
+	}
+	db.Create(&[]Gadget{{Name: "lamp", Price: 10}, {Name: "desk", Price: 90}, {Name: "chair", Price: 40}})
+
+	var page = Get[[]Gadget](t, "/admin/rest/gadgets/paginate?size=2&order=price%20asc")
+	if page.Total != 3 || page.TotalPages != 2 || len(page.Data) != 2 || page.Data[0].Name != "lamp" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	var created = Put[Gadget](t, "/admin/rest/gadgets", Gadget{Name: "shelf", Price: 25})
+	if created.Data.ID == 0 || created.Data.Name != "shelf" {
+		t.Fatalf("unexpected created row %+v", created.Data)
+	}
+	var all = Get[[]Gadget](t, "/admin/rest/gadgets/all")
+	if len(all.Data) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(all.Data))
+	}
+
+	var missing = Do[Gadget](t, http.MethodGet, "/admin/rest/gadgets/9999", nil)
+	if missing.Success || missing.Error == "" {
+		t.Fatalf("expected an error for a missing row, got %+v", missing)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var db = Setup(t, &Gadget{}, rest.FieldAudit{})
+	db.Create(&Gadget{ID: 1, Name: "lamp", Price: 10})
+	Post[Gadget](t, "/admin/rest/gadgets/1", map[string]interface{}{"price": 20})
+
+	var history = Get[[]rest.FieldAudit](t, "/admin/rest/gadgets/1/history")
+	if len(history.Data) != 1 || history.Data[0].Column != "price" || history.Data[0].OldValue != "10" || history.Data[0].NewValue != "20" {
+		t.Errorf("expected the changes in the data of the response, got %+v", history.Data)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	t.Run("insert", func(t *testing.T) {
+		var db = Setup(t, Gadget{})
+		db.Create(&Gadget{Name: "lamp"})
+	})
+	Setup(t, Gadget{})
+	if page := Get[[]Gadget](t, "/admin/rest/gadgets/all"); len(page.Data) != 0 {
+		t.Fatalf("expected the rows of the previous test to be deleted, got %d", len(page.Data))
+	}
+}
+
+func TestAsUser(t *testing.T) {
+	Setup(t, Gadget{})
+	AsUser(t, admin{})
+	var u = testUser{}.FromRequest(nil)
+	if u.Anonymous() {
+		t.Fatalf("expected the user given by AsUser")
+	}
+}
+
+func TestACLRequiresAdmin(t *testing.T) {
+	Setup(t, acl.UserRole{})
+	var grant = acl.UserRole{User: "member", Role: acl.SuperRole}
+
+	if status, _ := Request(t, http.MethodPut, "/admin/rest/user_role", grant); status != http.StatusUnauthorized {
+		t.Errorf("anonymous: expected 401, got %d", status)
+	}
+	t.Run("member", func(t *testing.T) {
+		AsUser(t, member{})
+		if status, _ := Request(t, http.MethodPut, "/admin/rest/user_role", grant); status != http.StatusForbidden {
+			t.Errorf("create: expected 403, got %d", status)
+		}
+		if status, _ := Request(t, http.MethodGet, "/admin/rest/user_role/all", nil); status != http.StatusForbidden {
+			t.Errorf("list: expected 403, got %d", status)
+		}
+	})
+	t.Run("admin", func(t *testing.T) {
+		AsUser(t, admin{})
+		if page := Put[acl.UserRole](t, "/admin/rest/user_role", grant); page.Data.Role != acl.SuperRole {
+			t.Errorf("unexpected grant %+v", page.Data)
+		}
+	})
+}
+
+func TestImpersonation(t *testing.T) {
+	Setup(t, Gadget{})
+	var impersonator = rest.Impersonator
+	rest.Impersonator = func(request *evo.Request, uuid string) evo.UserInterface {
+		if uuid == "root" {
+			return granted{uuid: uuid, permissions: []string{acl.Wildcard}}
+		}
+		return granted{uuid: uuid, permissions: []string{"GADGETS.VIEW"}}
+	}
+	t.Cleanup(func() {
+		rest.Impersonator = impersonator
+	})
+	AsUser(t, granted{uuid: "support", permissions: []string{acl.Impersonate, "GADGETS.VIEW"}})
+
+	WithHeader(t, rest.ImpersonateHeader, "clerk")
+	if status, body := Request(t, http.MethodGet, "/admin/rest/gadgets/all", nil); status != http.StatusOK {
+		t.Errorf("impersonating a user with fewer permissions: %d %s", status, body)
+	}
+	WithHeader(t, rest.ImpersonateHeader, "root")
+	if status, _ := Request(t, http.MethodGet, "/admin/rest/gadgets/all", nil); status != http.StatusForbidden {
+		t.Errorf("impersonating a user with more permissions: expected 403, got %d", status)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	var db = Setup(t, Gadget{}, rest.Segment{})
+	db.Create(&[]Gadget{{Name: "lamp", Price: 10}, {Name: "desk", Price: 90}, {Name: "chair", Price: 40}, {Name: "shelf", Price: 25}})
+	var impersonator = rest.Impersonator
+	rest.Impersonator = func(request *evo.Request, uuid string) evo.UserInterface {
+		return granted{uuid: uuid, permissions: []string{"GADGETS.VIEW"}}
+	}
+	resource, err := rest.GetResource(Gadget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policies = resource.Policies
+	resource.AddPolicy(rest.PolicyFunc(func(context *rest.Context, query *gorm.DB) *gorm.DB {
+		return query.Where("price < ?", 80)
+	}))
+	t.Cleanup(func() {
+		rest.Impersonator = impersonator
+		resource.Policies = policies
+	})
+
+	var segment = rest.Segment{Name: "mid range", Resource: "gadgets", Filter: "price[gt]=20"}
+	t.Run("member", func(t *testing.T) {
+		AsUser(t, member{})
+		if page := Do[rest.Segment](t, http.MethodPut, "/admin/rest/segment", segment); page.Success {
+			t.Errorf("expected the segment permission to be required, got %+v", page)
+		}
+	})
+
+	AsUser(t, granted{uuid: "analyst", permissions: []string{acl.Wildcard}})
+	WithHeader(t, rest.TenantHeader, "north")
+	var created = Put[rest.Segment](t, "/admin/rest/segment", segment)
+	if created.Data.TenantID != "north" || created.Data.Owner != "analyst" {
+		t.Fatalf("expected the segment bound to the tenant and the user, got %+v", created.Data)
+	}
+	count, err := created.Data.Count()
+	if err != nil || count != 2 {
+		t.Errorf("expected the policies to apply to the count, got %d %v", count, err)
+	}
+	if err := rest.RefreshSegments(); err != nil {
+		t.Fatal(err)
+	}
+	var refreshed rest.Segment
+	db.Take(&refreshed, created.Data.ID)
+	if refreshed.Members != 2 || refreshed.CountedAt == nil {
+		t.Errorf("expected the refreshed member count, got %+v", refreshed)
+	}
+
+	var path = fmt.Sprintf("/admin/rest/gadgets/all?segment=%d", created.Data.ID)
+	if page := Get[[]Gadget](t, path); len(page.Data) != 2 {
+		t.Errorf("expected the segment to filter the list, got %d rows", len(page.Data))
+	}
+	WithHeader(t, rest.TenantHeader, "south")
+	if page := Do[[]Gadget](t, http.MethodGet, path, nil); page.Success {
+		t.Errorf("expected the segment of another tenant to be rejected, got %+v", page)
+	}
+	if page := Get[[]rest.Segment](t, "/admin/rest/segment/all"); len(page.Data) != 0 {
+		t.Errorf("expected the segments of another tenant to be hidden, got %d", len(page.Data))
+	}
+}
+
+func TestReadyzHidesChecks(t *testing.T) {
+	Setup(t)
+	if err := (circuit.App{}).Router(); err != nil {
+		t.Fatal(err)
+	}
+	circuit.AddCheck("search", false, func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.7:9200: connection refused")
+	})
+
+	if status, body := Request(t, http.MethodGet, "/readyz", nil); status != http.StatusOK || strings.Contains(string(body), "10.0.0.7") {
+		t.Errorf("anonymous: expected the status only, got %d %s", status, body)
+	}
+	AsUser(t, admin{})
+	if _, body := Request(t, http.MethodGet, "/readyz", nil); !strings.Contains(string(body), "10.0.0.7") {
+		t.Errorf("admin: expected the checks, got %s", body)
+	}
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	Setup(t)
+	for _, path := range []string{"/admin/rest/schema/diff", "/admin/rest/logging"} {
+		if status, _ := Request(t, http.MethodGet, path, nil); status != http.StatusUnauthorized {
+			t.Errorf("%s anonymous: expected 401, got %d", path, status)
+		}
+	}
+	AsUser(t, member{})
+	for _, path := range []string{"/admin/rest/schema/diff", "/admin/rest/logging"} {
+		if status, _ := Request(t, http.MethodGet, path, nil); status != http.StatusForbidden {
+			t.Errorf("%s member: expected 403, got %d", path, status)
+		}
+	}
+}
+
+type Invoice struct {
+	rest.RequireApproval
+	ID     uint `gorm:"primaryKey" json:"id"`
+	Amount int  `json:"amount"`
+}
+
+func TestApproval(t *testing.T) {
+	var db = Setup(t, Invoice{}, rest.PendingChange{}, rest.FieldAudit{})
+	db.Create(&Invoice{Amount: 10})
+
+	AsUser(t, granted{uuid: "clerk", permissions: []string{acl.Wildcard}})
+	var page = Do[rest.PendingChange](t, http.MethodPost, "/admin/rest/invoices/1", map[string]interface{}{"amount": 20})
+	if page.Status != http.StatusAccepted || page.Data.Status != rest.ApprovalPending {
+		t.Fatalf("expected the update to wait for approval, got %+v", page)
+	}
+	var invoice Invoice
+	if db.Take(&invoice, 1); invoice.Amount != 10 {
+		t.Fatalf("expected the row to be unchanged, got %d", invoice.Amount)
+	}
+	var approve = fmt.Sprintf("/admin/rest/invoices/pending/approve/%d", page.Data.ID)
+	if page := Do[rest.PendingChange](t, http.MethodPost, approve, nil); page.Success {
+		t.Errorf("expected the requester not to approve the change, got %+v", page)
+	}
+
+	AsUser(t, granted{uuid: "manager", permissions: []string{acl.Wildcard}})
+	if page := Post[rest.PendingChange](t, approve, nil); page.Data.Status != rest.ApprovalApproved {
+		t.Errorf("expected the change to be approved, got %+v", page.Data)
+	}
+	if db.Take(&invoice, 1); invoice.Amount != 20 {
+		t.Errorf("expected the change to be applied, got %d", invoice.Amount)
+	}
+	if page := Do[rest.PendingChange](t, http.MethodPost, approve, nil); page.Success {
+		t.Errorf("expected the change to be applied once, got %+v", page)
+	}
+}
+
+func TestApprovalScope(t *testing.T) {
+	var db = Setup(t, Invoice{}, rest.PendingChange{}, rest.FieldAudit{})
+	db.Create(&[]Invoice{{Amount: 10}, {Amount: 500}})
+	resource, err := rest.GetResource(Invoice{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policies = resource.Policies
+	resource.AddPolicy(rest.PolicyFunc(func(context *rest.Context, query *gorm.DB) *gorm.DB {
+		if context.User().UUID() == "clerk" {
+			return query
+		}
+		return query.Where("amount < ?", 100)
+	}))
+	t.Cleanup(func() {
+		resource.Policies = policies
+	})
+
+	AsUser(t, granted{uuid: "clerk", permissions: []string{acl.Wildcard}})
+	WithHeader(t, rest.TenantHeader, "north")
+	var small = Do[rest.PendingChange](t, http.MethodPost, "/admin/rest/invoices/1", map[string]interface{}{"amount": 20})
+	var large = Do[rest.PendingChange](t, http.MethodPost, "/admin/rest/invoices/2", map[string]interface{}{"amount": 600})
+	if small.Data.Tenant != "north" || large.Data.ID == 0 {
+		t.Fatalf("expected the changes to be recorded in the tenant, got %+v %+v", small.Data, large.Data)
+	}
+
+	var reject = func(change rest.PendingChange) bool {
+		t.Helper()
+		return Do[rest.PendingChange](t, http.MethodPost, fmt.Sprintf("/admin/rest/invoices/pending/reject/%d", change.ID), nil).Success
+	}
+	AsUser(t, granted{uuid: "manager", permissions: []string{acl.Wildcard}})
+	WithHeader(t, rest.TenantHeader, "south")
+	if reject(small.Data) {
+		t.Error("expected the change of another tenant to be rejected by its tenant only")
+	}
+	WithHeader(t, rest.TenantHeader, "north")
+	if reject(large.Data) {
+		t.Error("expected the change of a row hidden from the reviewer not to be rejected")
+	}
+	var list = func() []rest.PendingChange {
+		t.Helper()
+		return Get[[]rest.PendingChange](t, "/admin/rest/invoices/pending").Data
+	}
+	if got := list(); len(got) != 1 || got[0].ID != small.Data.ID {
+		t.Errorf("expected the visible change of the tenant only, got %+v", got)
+	}
+	WithHeader(t, rest.TenantHeader, "south")
+	if got := list(); len(got) != 0 {
+		t.Errorf("expected no change of another tenant, got %+v", got)
+	}
+	WithHeader(t, rest.TenantHeader, "north")
+	if !reject(small.Data) {
+		t.Error("expected the reviewer of the tenant to reject the change")
+	}
+}
+
+func TestReattach(t *testing.T) {
+	var db = Setup(t, Gadget{})
+	db.Create(&[]Gadget{{Name: "lamp", Price: 10}, {Name: "desk", Price: 90}})
+	resource, err := rest.GetResource(Gadget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource.AddPolicy(rest.PolicyFunc(func(context *rest.Context, query *gorm.DB) *gorm.DB {
+		return query.Where("price < ?", 50)
+	}))
+	var calls int
+	resource.Use(func(context *rest.Context, next func() error) error {
+		calls++
+		return next()
+	})
+	var reattach = func() *rest.Resource {
+		for _, model := range schema.Models {
+			if model.Name == resource.Name {
+				return rest.AttachResource(&model)
+			}
+		}
+		t.Fatal("model of the resource not found")
+		return nil
+	}
+	rest.SetPermission(&rest.AppPermission{App: "GADGETS", Name: "gadgets", Objects: []interface{}{Gadget{}}})
+	var attached = reattach()
+	t.Cleanup(func() {
+		if err := rest.Deregister(Gadget{}); err != nil {
+			t.Error(err)
+		}
+		reattach()
+	})
+	if !attached.Feature.CheckPermission || attached.Permissions.App != "GADGETS" {
+		t.Errorf("expected the permissions to be kept, got %+v", attached.Permissions)
+	}
+
+	AsUser(t, granted{uuid: "clerk", permissions: []string{acl.Wildcard}})
+	if page := Get[[]Gadget](t, "/admin/rest/gadgets/all"); len(page.Data) != 1 {
+		t.Errorf("expected the policy to be kept, got %d rows", len(page.Data))
+	}
+	if calls != 1 {
+		t.Errorf("expected the middleware to be kept, called %d times", calls)
+	}
+}
+
+type Article struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Name  string `json:"name"`
+	Title string `gorm:"not null" json:"title"`
+}
+
+func TestRenameColumn(t *testing.T) {
+	var db = Setup(t, Article{})
+	db.Create(&[]Article{{Name: "legacy"}, {Name: "stale", Title: "kept"}})
+	resource, err := rest.GetResource(Article{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rename = resource.RenameColumn("name", "title")
+	t.Cleanup(func() { resource.Renames = nil })
+
+	var backfill = rename.Backfill("articles", "1")
+	if err := backfill.Run(&migration.Migrator{Migration: backfill, DB: db}); err != nil {
+		t.Fatal(err)
+	}
+	var articles []Article
+	db.Order("id").Find(&articles)
+	if articles[0].Title != "legacy" || articles[1].Title != "kept" {
+		t.Fatalf("expected only the unset column to be backfilled, got %+v", articles)
+	}
+
+	AsUser(t, admin{})
+	Post[Article](t, "/admin/rest/articles/2", map[string]interface{}{"name": "renamed"})
+	var article Article
+	if db.Take(&article, 2); article.Name != "renamed" || article.Title != "renamed" {
+		t.Errorf("expected the edit of the old column to be written to both, got %+v", article)
+	}
+}
+
+type OrderLine struct {
+	rest.EnableSetAPI
+	OrderID int    `gorm:"primaryKey;autoIncrement:false;SET_KEY" json:"order_id"`
+	Line    int    `gorm:"primaryKey;autoIncrement:false" json:"line"`
+	Name    string `json:"name"`
+}
+
+func (OrderLine) TableName() string {
+	return "order_lines"
+}
+
+func (l *OrderLine) Computed(context *rest.Context) map[string]interface{} {
+	return map[string]interface{}{"label": fmt.Sprintf("%d.%d %s", l.OrderID, l.Line, l.Name)}
+}
+
+func (OrderLine) FilterView() rest.FilterView {
+	return rest.FilterView{
+		Model: OrderLine{},
+		Columns: []rest.FilterViewColumn{
+			{Title: "Label", Computed: "label", Href: "/lines/$pk"},
+		},
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	Setup(t, OrderLine{})
+	AsUser(t, admin{})
+	var lines = []OrderLine{{Line: 1, Name: "bolt"}, {Line: 2, Name: "nut"}}
+	Put[[]OrderLine](t, "/admin/rest/order_lines/7/set", lines)
+	Put[[]OrderLine](t, "/admin/rest/order_lines/8/set", lines[:1])
+
+	if page := Get[OrderLine](t, "/admin/rest/order_lines/7/2"); page.Data.Name != "nut" || page.Data.OrderID != 7 {
+		t.Errorf("expected the line of the composite key, got %+v", page.Data)
+	}
+	var rows = Get[[][]string](t, "/admin/rest/order_lines/filter-view?sort=order_id,line")
+	var want = [][]string{
+		{`<a href="/lines/7/1">7.1 bolt</a>`},
+		{`<a href="/lines/7/2">7.2 nut</a>`},
+		{`<a href="/lines/8/1">8.1 bolt</a>`},
+	}
+	if !reflect.DeepEqual(rows.Data, want) {
+		t.Errorf("expected the rows keyed by the composite key, got %v", rows.Data)
+	}
+}
+
+func TestResetViewPreference(t *testing.T) {
+	Setup(t, OrderLine{}, rest.ViewPreference{})
+	rest.SetPermission(&rest.AppPermission{App: "ORDER_LINES", Name: "order lines", Objects: []interface{}{OrderLine{}}})
+	t.Cleanup(func() {
+		if resource, err := rest.GetResource(OrderLine{}); err == nil {
+			resource.Feature.CheckPermission = false
+		}
+	})
+	AsUser(t, member{})
+	if page := Do[interface{}](t, http.MethodDelete, "/admin/rest/order_lines/filter-view/preference", nil); page.Success {
+		t.Errorf("expected users without the view permission to be refused, got %+v", page)
+	}
+	AsUser(t, admin{})
+	if page := Do[interface{}](t, http.MethodDelete, "/admin/rest/order_lines/filter-view/preference", nil); !page.Success {
+		t.Errorf("expected users with the view permission to be served, got %+v", page)
+	}
+}
+
+type Contact struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	FirstName string `json:"first_name"`
+}
+
+func (Contact) RESTNaming() rest.NamingStrategy {
+	return rest.NamingCamel
+}
+
+func TestViewPreference(t *testing.T) {
+	Setup(t, OrderLine{}, rest.ViewPreference{})
+	AsUser(t, granted{uuid: "clerk", permissions: []string{acl.Wildcard}})
+	if page := Get[rest.ViewPreference](t, "/admin/rest/order_lines/filter-view/preference"); page.Data.PageSize != 0 || page.Data.Columns != nil {
+		t.Errorf("expected an empty preference in the data of the response, got %+v", page.Data)
+	}
+	var saved = Put[rest.ViewPreference](t, "/admin/rest/order_lines/filter-view/preference", rest.ViewPreference{Columns: []string{"name"}, PageSize: 50})
+	if saved.Data.PageSize != 50 || len(saved.Data.Columns) != 1 {
+		t.Errorf("expected the saved preference in the data of the response, got %+v", saved.Data)
+	}
+	if page := Get[rest.ViewPreference](t, "/admin/rest/order_lines/filter-view/preference"); page.Data.PageSize != 50 {
+		t.Errorf("expected the stored preference in the data of the response, got %+v", page.Data)
+	}
+}
+
+func TestNamingCamelBody(t *testing.T) {
+	var db = Setup(t, Contact{})
+	AsUser(t, admin{})
+	Put[map[string]interface{}](t, "/admin/rest/contacts", map[string]interface{}{"firstName": "Ada"})
+	Post[map[string]interface{}](t, "/admin/rest/contacts/1", map[string]interface{}{"firstName": "Grace"})
+	var contact Contact
+	if db.Take(&contact, 1); contact.FirstName != "Grace" {
+		t.Errorf("expected the camelCase keys to be decoded, got %+v", contact)
+	}
+}
+
+func TestCustomRequiresUser(t *testing.T) {
+	Setup(t, Gadget{})
+	resource, err := rest.GetResource(Gadget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource.Custom(rest.GET, "/report/ping", func(context *rest.Context) error {
+		context.Response.Data = "pong"
+		return nil
+	})
+	if page := Do[string](t, http.MethodGet, "/admin/rest/gadgets/report/ping", nil); page.Success {
+		t.Errorf("expected anonymous users to be refused, got %+v", page)
+	}
+	AsUser(t, member{})
+	if page := Get[string](t, "/admin/rest/gadgets/report/ping"); page.Data != "pong" {
+		t.Errorf("expected authenticated users to be served, got %+v", page)
+	}
+}
+
+// Ticket counts its rows with a cache outliving the test.
+type Ticket struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Title string `gorm:"size:64" json:"title"`
+}
+
+func (Ticket) RESTCount() rest.Count {
+	return rest.Count{Strategy: rest.CountCached, TTL: time.Hour}
+}
+
+func TestCachedCountExec(t *testing.T) {
+	var db = Setup(t, &Ticket{})
+	db.Create(&Ticket{Title: "first"})
+	if page := Get[[]Ticket](t, "/admin/rest/tickets/paginate"); page.Total != 1 {
+		t.Fatalf("expected 1 ticket, got %d", page.Total)
+	}
+	db.Exec("INSERT INTO tickets (title) VALUES (?)", "second")
+	if page := Get[[]Ticket](t, "/admin/rest/tickets/paginate"); page.Total != 2 {
+		t.Errorf("expected the insert by Exec to invalidate the cached count, got %d", page.Total)
+	}
+}
+
+// Memo rejects the creation of identical memos within an hour.
+type Memo struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Text string `gorm:"size:64" json:"text"`
+}
+
+func (Memo) DedupWindow() time.Duration {
+	return time.Hour
+}
+
+var memoRejected bool
+
+func (Memo) ValidateCreate(context *rest.Context) error {
+	if memoRejected {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func TestDedupConcurrent(t *testing.T) {
+	var db = Setup(t, &Memo{})
+	AsUser(t, admin{})
+	var statuses = make(chan int, 8)
+	for i := 0; i < cap(statuses); i++ {
+		go func() {
+			status, _ := Request(t, "PUT", "/admin/rest/memos", Memo{Text: "concurrent"})
+			statuses <- status
+		}()
+	}
+	var created int
+	for i := 0; i < cap(statuses); i++ {
+		if status := <-statuses; status == http.StatusOK {
+			created++
+		} else if status != http.StatusConflict {
+			t.Errorf("expected duplicates to be answered %d, got %d", http.StatusConflict, status)
+		}
+	}
+	var count int64
+	db.Model(&Memo{}).Where("text = ?", "concurrent").Count(&count)
+	if created != 1 || count != 1 {
+		t.Errorf("expected one memo to be created, got %d successes and %d rows", created, count)
+	}
+}
+
+func TestDedupFailedCreate(t *testing.T) {
+	Setup(t, &Memo{})
+	AsUser(t, admin{})
+	memoRejected = true
+	if page := Do[Memo](t, "PUT", "/admin/rest/memos", Memo{Text: "retried"}); page.Success {
+		t.Fatalf("expected the rejected memo to fail, got %+v", page)
+	}
+	memoRejected = false
+	if page := Do[Memo](t, "PUT", "/admin/rest/memos", Memo{Text: "retried"}); !page.Success {
+		t.Errorf("expected the retry of a failed create to succeed, got %+v", page)
+	}
+	if page := Do[Memo](t, "PUT", "/admin/rest/memos", Memo{Text: "retried"}); page.Status != http.StatusConflict {
+		t.Errorf("expected the duplicate to be answered %d, got %+v", http.StatusConflict, page)
+	}
+}
+
+// Document is visible to its owner only, see Scope.
+type Document struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Owner    string `gorm:"size:64" json:"owner"`
+	Title    string `gorm:"size:64" json:"title"`
+	Archived bool   `json:"archived"`
+}
+
+func (Document) Scope(context *rest.Context, query *gorm.DB) *gorm.DB {
+	return query.Where("owner = ?", context.User().UUID())
+}
+
+func TestPolicies(t *testing.T) {
+	var db = Setup(t, Document{})
+	db.Create(&[]Document{
+		{ID: 1, Owner: "alice", Title: "mine"},
+		{ID: 2, Owner: "bob", Title: "theirs"},
+		{ID: 3, Owner: "alice", Title: "old", Archived: true},
+	})
+	resource, err := rest.GetResource(Document{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policies = resource.Policies
+	resource.AddPolicy(rest.PolicyFunc(func(context *rest.Context, query *gorm.DB) *gorm.DB {
+		return query.Where("archived = ?", false)
+	}))
+	t.Cleanup(func() {
+		resource.Policies = policies
+	})
+	AsUser(t, granted{uuid: "alice", permissions: []string{acl.Wildcard}})
+
+	t.Run("list", func(t *testing.T) {
+		var page = Get[[]Document](t, "/admin/rest/documents/all")
+		if len(page.Data) != 1 || page.Data[0].ID != 1 {
+			t.Errorf("expected the model and resource policies to apply, got %+v", page.Data)
+		}
+		if page := Get[[]Document](t, "/admin/rest/documents/paginate"); page.Total != 1 {
+			t.Errorf("expected the policies to apply to the total, got %d", page.Total)
+		}
+	})
+	t.Run("get", func(t *testing.T) {
+		if page := Do[Document](t, http.MethodGet, "/admin/rest/documents/1", nil); !page.Success {
+			t.Errorf("expected the own row, got %+v", page)
+		}
+		for _, id := range []string{"2", "3"} {
+			if page := Do[Document](t, http.MethodGet, "/admin/rest/documents/"+id, nil); page.Success {
+				t.Errorf("expected row %s to be hidden, got %+v", id, page.Data)
+			}
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		Do[Document](t, http.MethodPost, "/admin/rest/documents/1", map[string]interface{}{"title": "changed"})
+		Do[Document](t, http.MethodPost, "/admin/rest/documents/2", map[string]interface{}{"title": "changed"})
+		var own, other Document
+		db.Take(&own, 1)
+		db.Take(&other, 2)
+		if own.Title != "changed" || other.Title != "theirs" {
+			t.Errorf("expected the own row only to be updated, got %+v %+v", own, other)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		for _, id := range []string{"1", "2", "3"} {
+			Do[Document](t, http.MethodDelete, "/admin/rest/documents/"+id, nil)
+		}
+		var left []Document
+		db.Order("id").Find(&left)
+		if len(left) != 2 || left[0].ID != 2 || left[1].ID != 3 {
+			t.Errorf("expected the own row only to be deleted, got %+v", left)
+		}
+	})
+}
+
+func TestRawEnvelope(t *testing.T) {
+	var db = Setup(t, Gadget{})
+	db.Create(&[]Gadget{{Name: "lamp", Price: 10}, {Name: "desk", Price: 90}, {Name: "chair", Price: 40}})
+	var get = func(url string) (*http.Response, string) {
+		t.Helper()
+		resp, err := evo.GetFiber().Test(httptest.NewRequest(http.MethodGet, url, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(b)
+	}
+
+	resp, body := get("/admin/rest/gadgets/paginate?size=2&order=price%20asc&envelope=false")
+	if want := `[{"id":1,"name":"lamp","price":10},{"id":3,"name":"chair","price":40}]`; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+	if resp.Header.Get("X-Total-Count") != "3" || resp.Header.Get("X-Total-Pages") != "2" {
+		t.Errorf("X-Total-Count = %q, X-Total-Pages = %q", resp.Header.Get("X-Total-Count"), resp.Header.Get("X-Total-Pages"))
+	}
+
+	resp, body = get("/admin/rest/gadgets/9999?envelope=false")
+	if resp.StatusCode != http.StatusNotFound || !strings.HasPrefix(body, `{"error":`) {
+		t.Errorf("missing row = %d %s, want 404 with the error", resp.StatusCode, body)
+	}
+
+	if _, body = get("/admin/rest/gadgets/all?envelope=true"); !strings.Contains(body, `"success":true`) {
+		t.Errorf("envelope=true = %s, want the pagination envelope", body)
+	}
+}
+
+type Voucher struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Code string `gorm:"size:32" json:"code"`
+	rest.ObfuscateID
+}
+
+func TestObfuscatedIDs(t *testing.T) {
+	var db = Setup(t, Voucher{})
+	db.Create(&Voucher{ID: 1, Code: "WELCOME"})
+	resource, err := rest.GetResource(Voucher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource.Custom(rest.GET, "/report/last", func(context *rest.Context) error {
+		context.Response.Data = map[string]interface{}{"id": 1}
+		return nil
+	})
+	AsUser(t, admin{})
+	var hash = resource.IDEncoder().Encode(1)
+
+	if page := Get[[]map[string]interface{}](t, "/admin/rest/vouchers/all"); len(page.Data) != 1 || page.Data[0]["id"] != hash {
+		t.Errorf("expected the hashed id in the list, got %+v", page.Data)
+	}
+	status, body := Request(t, http.MethodGet, "/admin/rest/vouchers/all?stream=true", nil)
+	if want := `{"code":"WELCOME","id":"` + hash + `"}` + "\n"; status != http.StatusOK || string(body) != want {
+		t.Errorf("stream = %d %s, want %s", status, body, want)
+	}
+	if page := Get[map[string]interface{}](t, "/admin/rest/vouchers/report/last"); page.Data["id"] != float64(1) {
+		t.Errorf("expected payloads other than rows to be left as is, got %+v", page.Data)
+	}
+}
+
+type Payout struct {
+	rest.RequireApproval
+	rest.ObfuscateID
+	ID     uint `gorm:"primaryKey" json:"id"`
+	Amount int  `json:"amount"`
+}
+
+func TestApprovalObfuscated(t *testing.T) {
+	var db = Setup(t, Payout{}, rest.PendingChange{}, rest.FieldAudit{})
+	db.Create(&Payout{Amount: 10})
+	resource, err := rest.GetResource(Payout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hash = resource.IDEncoder().Encode(1)
+
+	AsUser(t, granted{uuid: "clerk", permissions: []string{acl.Wildcard}})
+	var page = Do[rest.PendingChange](t, http.MethodPost, "/admin/rest/payouts/"+hash, map[string]interface{}{"amount": 20})
+	if page.Status != http.StatusAccepted || page.Data.ID == 0 || page.Data.RowID != hash {
+		t.Fatalf("expected the change with its own id and the hashed row id, got %+v", page)
+	}
+	if list := Get[[]rest.PendingChange](t, "/admin/rest/payouts/pending"); len(list.Data) != 1 || list.Data[0].RowID != hash {
+		t.Errorf("expected the hashed row id in the list, got %+v", list.Data)
+	}
+
+	AsUser(t, granted{uuid: "manager", permissions: []string{acl.Wildcard}})
+	var approved = Post[rest.PendingChange](t, fmt.Sprintf("/admin/rest/payouts/pending/approve/%d", page.Data.ID), nil)
+	if approved.Data.Status != rest.ApprovalApproved || approved.Data.RowID != hash {
+		t.Errorf("expected the change to be approved, got %+v", approved.Data)
+	}
+}
+
+type Crate struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Name  string `gorm:"size:64" json:"name"`
+	Label string `gorm:"-" json:"label"`
+}
+
+func (c *Crate) AfterGet(context *rest.Context) error {
+	if c.Name == "broken" {
+		return errors.New("crate is broken")
+	}
+	c.Label = strings.ToUpper(c.Name)
+	return nil
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	var db = Setup(t, Crate{})
+	db.Create(&[]Crate{{ID: 1, Name: "apples"}, {ID: 2, Name: "pears"}, {ID: 3, Name: "broken"}, {ID: 4, Name: "plums"}})
+
+	resp, err := evo.GetFiber().Test(httptest.NewRequest(http.MethodGet, "/admin/rest/crates/all?stream=true&order=id%20asc", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != rest.NDJSONContentType {
+		t.Fatalf("stream = %d %q, want %d %q", resp.StatusCode, resp.Header.Get("Content-Type"), http.StatusOK, rest.NDJSONContentType)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `{"id":1,"name":"apples","label":"APPLES"}` + "\n" +
+		`{"id":2,"name":"pears","label":"PEARS"}` + "\n" +
+		`{"error":"crate is broken"}` + "\n"
+	if string(body) != want {
+		t.Errorf("stream = %s, want one line per row and the error as the last line:\n%s", body, want)
+	}
+}
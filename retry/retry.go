@@ -0,0 +1,106 @@
+// Package retry runs operations with exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Policy describes how an operation is retried.
+// - Attempts: maximum number of attempts, including the first one.
+// - Delay: delay before the second attempt.
+// - MaxDelay: upper bound of the delay, zero means unbounded.
+// - Multiplier: factor applied to the delay after each attempt.
+// - Jitter: fraction of the delay randomly added or removed, between 0 and 1.
+type Policy struct {
+	Attempts   int
+	Delay      time.Duration
+	MaxDelay   time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// DefaultPolicy makes 3 attempts waiting 200ms then 400ms.
+var DefaultPolicy = Policy{
+	Attempts:   3,
+	Delay:      200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.1,
+}
+
+// NoRetry makes a single attempt.
+var NoRetry = Policy{Attempts: 1}
+
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+func (p permanent) Unwrap() error {
+	return p.err
+}
+
+// Permanent wraps an error which must not be retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err: err}
+}
+
+// Backoff returns the delay to wait after the given attempt, starting from 1, without jitter.
+func (p Policy) Backoff(attempt int) time.Duration {
+	var multiplier = p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	var delay = float64(p.Delay)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+			return p.MaxDelay
+		}
+	}
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, returns a Permanent error, the attempts are exhausted or ctx is done.
+// The attempt number starting from 1 is passed to fn. The last error is returned unwrapped.
+func Do(ctx context.Context, policy Policy, fn func(attempt int) error) error {
+	var attempts = policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		var p permanent
+		if errors.As(err, &p) {
+			return p.err
+		}
+		if attempt == attempts {
+			break
+		}
+		var delay = policy.Backoff(attempt)
+		if policy.Jitter > 0 {
+			delay += time.Duration((rand.Float64()*2 - 1) * policy.Jitter * float64(delay))
+		}
+		var timer = time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+	return err
+}
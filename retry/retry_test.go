@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	var policy = Policy{Delay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, time.Second},
+	}
+	for _, test := range tests {
+		if got := policy.Backoff(test.attempt); got != test.want {
+			t.Errorf("Backoff(%d) = %s, want %s", test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	var policy = Policy{Attempts: 3, Delay: time.Millisecond}
+	var calls int
+	err := Do(context.Background(), policy, func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	var fatal = errors.New("fatal")
+	err = Do(context.Background(), policy, func(attempt int) error {
+		calls++
+		return Permanent(fatal)
+	})
+	if err != fatal || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want fatal after 1", err, calls)
+	}
+}
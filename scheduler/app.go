@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// PREFIX specifies the prefix for scheduler routes in the admin panel.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the task state model.
+func (a App) Register() error {
+	db.UseModel(State{})
+	return nil
+}
+
+// Router sets up the task endpoint.
+// GET /scheduler/tasks returns the last activation of every task, to the users holding acl.Admin.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/scheduler/tasks", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		states, err := States()
+		if err != nil {
+			return err
+		}
+		return states
+	})
+	return nil
+}
+
+// WhenReady starts the tasks declared by the applications.
+func (a App) WhenReady() error {
+	Start()
+	return nil
+}
+
+// Shutdown stops the tasks, waiting for the runs in progress.
+func (a App) Shutdown(ctx context.Context) error {
+	return Stop(ctx)
+}
+
+func (a App) Name() string {
+	return "scheduler"
+}
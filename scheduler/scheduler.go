@@ -0,0 +1,300 @@
+// Package scheduler runs recurring tasks declared by the applications:
+//
+//	scheduler.Every("5m", flush).Named("metering.flush")
+//	scheduler.Cron("0 3 * * *", cleanup)
+//
+// Each activation is claimed in the scheduler_task table before running, so a task runs on a single
+// instance per activation in multi-replica deployments. Instances must have synchronized clocks.
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm/clause"
+)
+
+// Instance identifies this process as the runner of the tasks.
+var Instance = instanceID()
+
+func instanceID() string {
+	var host, _ = os.Hostname()
+	var b = make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Task is a function run on a schedule.
+// - Local: the task runs on every instance instead of a single one.
+// - Timeout: cancels the context of a run, zero waits until the next activation.
+type Task struct {
+	Name     string
+	Schedule Schedule
+	Fn       func(ctx context.Context) error
+	Local    bool
+	Timeout  time.Duration
+	running  sync.Mutex
+	stop     chan struct{}
+}
+
+// State records the last activation of a task claimed by an instance.
+type State struct {
+	Name       string     `gorm:"column:name;size:128;primaryKey" json:"name"`
+	Slot       *time.Time `gorm:"column:slot" json:"slot"`
+	Instance   string     `gorm:"column:instance;size:255" json:"instance"`
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`
+	Error      string     `gorm:"column:error;size:1024" json:"error"`
+}
+
+// TableName returns the name of the table for the State struct.
+func (State) TableName() string {
+	return "scheduler_task"
+}
+
+var tasks = map[string]*Task{}
+var mu sync.Mutex
+var started bool
+
+// runs tracks the runs in progress, waited for by Stop.
+var runs sync.WaitGroup
+
+// Every schedules fn at a fixed interval given as a duration such as "30s", "5m" or "1h".
+// Activations are aligned on multiples of the interval. It panics if the interval is invalid.
+func Every(interval string, fn func(ctx context.Context) error) *Task {
+	d, err := time.ParseDuration(interval)
+	if err != nil || d < time.Second {
+		panic(fmt.Sprintf("scheduler: invalid interval %q", interval))
+	}
+	return Add(Interval(d), fn)
+}
+
+// Cron schedules fn using a cron expression, see ParseCron. It panics if the expression is invalid.
+func Cron(expr string, fn func(ctx context.Context) error) *Task {
+	c, err := ParseCron(expr)
+	if err != nil {
+		panic("scheduler: " + err.Error())
+	}
+	return Add(c, fn)
+}
+
+// Add adds a task running fn on the given schedule, named after the function.
+// Tasks added after the scheduler app is ready start immediately.
+func Add(schedule Schedule, fn func(ctx context.Context) error) *Task {
+	var task = &Task{
+		Name:     runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name(),
+		Schedule: schedule,
+		Fn:       fn,
+	}
+	mu.Lock()
+	replace(task)
+	if started {
+		task.start()
+	}
+	mu.Unlock()
+	return task
+}
+
+// Named renames the task. Names identify the task across instances, so closures should always be named.
+// A task already named so is stopped and replaced.
+func (t *Task) Named(name string) *Task {
+	mu.Lock()
+	if tasks[t.Name] == t {
+		delete(tasks, t.Name)
+	}
+	t.Name = name
+	replace(t)
+	mu.Unlock()
+	return t
+}
+
+// replace registers the task in place of the task of the same name, which is stopped. mu must be held.
+func replace(task *Task) {
+	if previous, ok := tasks[task.Name]; ok && previous != task && previous.stop != nil {
+		close(previous.stop)
+		previous.stop = nil
+	}
+	tasks[task.Name] = task
+}
+
+// RunLocal makes the task run on every instance.
+func (t *Task) RunLocal() *Task {
+	t.Local = true
+	return t
+}
+
+// WithTimeout sets the timeout of each run.
+func (t *Task) WithTimeout(timeout time.Duration) *Task {
+	t.Timeout = timeout
+	return t
+}
+
+// Remove stops and removes the task with the given name.
+func Remove(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if task, ok := tasks[name]; ok {
+		if task.stop != nil {
+			close(task.stop)
+			task.stop = nil
+		}
+		delete(tasks, name)
+	}
+}
+
+// Tasks returns the scheduled tasks.
+func Tasks() []*Task {
+	mu.Lock()
+	defer mu.Unlock()
+	var result []*Task
+	for _, task := range tasks {
+		result = append(result, task)
+	}
+	return result
+}
+
+// Start starts every scheduled task. It is called by the scheduler app when ready.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	if started {
+		return
+	}
+	started = true
+	for _, task := range tasks {
+		task.start()
+	}
+}
+
+// Stop stops every task and waits for the runs in progress to complete, or for ctx to be done.
+// Tasks added afterwards do not start until Start is called again.
+func Stop(ctx context.Context) error {
+	mu.Lock()
+	if started {
+		started = false
+		for _, task := range tasks {
+			if task.stop != nil {
+				close(task.stop)
+				task.stop = nil
+			}
+		}
+	}
+	mu.Unlock()
+	var done = make(chan struct{})
+	go func() {
+		runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Task) start() {
+	t.stop = make(chan struct{})
+	go t.loop(t.stop)
+}
+
+func (t *Task) loop(stop chan struct{}) {
+	for {
+		var slot = t.Schedule.Next(time.Now())
+		if slot.IsZero() {
+			logger.Warning("task has no next activation", "task", t.Name)
+			return
+		}
+		var timer = time.NewTimer(time.Until(slot))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			runs.Add(1)
+			go func() {
+				defer runs.Done()
+				t.run(slot)
+			}()
+		}
+	}
+}
+
+// run runs the task for the activation if this instance claims it. A run still in progress skips the activation.
+func (t *Task) run(slot time.Time) {
+	if !t.running.TryLock() {
+		logger.Warning("task skipped, previous run still in progress", "task", t.Name)
+		return
+	}
+	defer t.running.Unlock()
+	if !t.Local && !claim(t.Name, slot) {
+		return
+	}
+	var ctx = context.Background()
+	var cancel context.CancelFunc = func() {}
+	if t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+	} else if next := t.Schedule.Next(slot); !next.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, next)
+	}
+	defer cancel()
+	var err = t.call(ctx)
+	if err != nil {
+		logger.Error("task failed", "task", t.Name, "error", err.Error())
+	}
+	if !t.Local {
+		finish(t.Name, err)
+	}
+}
+
+// call runs the task function, recovering from panics.
+func (t *Task) call(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return t.Fn(ctx)
+}
+
+// claim records the activation of the task by this instance.
+// It reports false if another instance has already claimed the activation.
+func claim(name string, slot time.Time) bool {
+	db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&State{Name: name})
+	var now = time.Now()
+	var result = db.Model(&State{}).Where("name = ? AND (slot IS NULL OR slot < ?)", name, slot).
+		Updates(map[string]interface{}{"slot": slot, "instance": Instance, "started_at": now, "finished_at": nil, "error": ""})
+	if result.Error != nil {
+		logger.Error("unable to claim task", "task", name, "error", result.Error.Error())
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// finish records the outcome of the run claimed by this instance.
+func finish(name string, err error) {
+	var message string
+	if err != nil {
+		message = err.Error()
+		if len(message) > 1024 {
+			message = message[:1024]
+		}
+	}
+	db.Model(&State{}).Where("name = ? AND instance = ?", name, Instance).
+		Updates(map[string]interface{}{"finished_at": time.Now(), "error": message})
+}
+
+// States returns the last activation of every task.
+func States() ([]State, error) {
+	var result []State
+	return result, db.Order("name ASC").Find(&result).Error
+}
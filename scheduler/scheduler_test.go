@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamedReplacesTask(t *testing.T) {
+	Start()
+	t.Cleanup(func() {
+		Remove("report.send")
+		_ = Stop(context.Background())
+	})
+	var noop = func(ctx context.Context) error { return nil }
+	var first = Every("1h", noop).Named("report.send")
+	if first.stop == nil {
+		t.Fatalf("expected the task to be started")
+	}
+	var second = Every("1h", func(ctx context.Context) error { return nil }).Named("report.send")
+	if first.stop != nil {
+		t.Errorf("expected the replaced task to be stopped")
+	}
+	if second.stop == nil {
+		t.Errorf("expected the new task to be started")
+	}
+	var count = 0
+	for _, task := range Tasks() {
+		if task.Name == "report.send" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected a single task named report.send, got %d", count)
+	}
+}
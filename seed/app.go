@@ -0,0 +1,71 @@
+package seed
+
+import (
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// PREFIX specifies the prefix for seed routes in the admin panel.
+var PREFIX = "/admin"
+
+// RunAtStartup specifies whether the pending sets are applied when the application is ready.
+var RunAtStartup = true
+
+type App struct {
+}
+
+// Register registers the applied sets model.
+func (a App) Register() error {
+	db.UseModel(Applied{})
+	return nil
+}
+
+// Router sets up the seed endpoints, restricted to the users holding acl.Admin.
+// GET /seed lists the registered sets with their state in the current environment.
+// POST /seed/run applies the pending sets.
+// POST /seed/:key/apply applies a set of the current environment again.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/seed", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		statuses, err := Statuses()
+		if err != nil {
+			return err
+		}
+		return statuses
+	})
+	evo.Post(PREFIX+"/seed/run", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		if err := Run(); err != nil {
+			return err
+		}
+		statuses, err := Statuses()
+		if err != nil {
+			return err
+		}
+		return statuses
+	})
+	evo.Post(PREFIX+"/seed/:key/apply", func(request *evo.Request) interface{} {
+		if acl.Deny(request, acl.Admin) {
+			return nil
+		}
+		return Apply(request.Param("key").String())
+	})
+	return nil
+}
+
+// WhenReady applies the pending sets.
+func (a App) WhenReady() error {
+	if RunAtStartup {
+		return Run()
+	}
+	return nil
+}
+
+func (a App) Name() string {
+	return "seed"
+}
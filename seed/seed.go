@@ -0,0 +1,275 @@
+// Package seed applies fixture sets to the database: reference data such as countries or default roles, and
+// demo data targeted at some environments. Sets are registered by the applications and applied once, in order,
+// when the app is ready or by the admin endpoints, see App:
+//
+//	seed.Register(seed.Set{
+//		Key:   "countries",
+//		Model: Country{},
+//		File:  "seeds/countries.yml",
+//	}, seed.Set{
+//		Key:          "demo-customers",
+//		Environments: []string{"dev", "stage"},
+//		Run: func(tx *gorm.DB) error {
+//			return tx.Create(&Customer{Name: "ACME"}).Error
+//		},
+//	})
+package seed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/settings"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownSet is returned when applying a set which is not registered.
+var ErrUnknownSet = errors.New("unknown seed set")
+
+// ErrSetDisabled is returned when applying a set which does not apply to the current environment.
+var ErrSetDisabled = errors.New("seed set disabled in the environment")
+
+// Set is a fixture set.
+// - Key: idempotency key, a set already applied is not applied again unless its rows change.
+// - Description: a brief description of the set.
+// - Environments: the environments the set applies to, see settings.Environment; every environment if empty.
+// - Model, File: the model of the rows and the YAML (.yml, .yaml) or JSON file holding the list of rows.
+// - Rows: the rows, when not read from a file.
+// - Run: a function seeding the database, run after the rows are inserted the first time the set is applied.
+//
+// Rows are inserted keeping the existing rows with the same primary or unique keys, so sets can be extended
+// and applied again without overwriting the changes made since. Run is not run again when the set is applied
+// again, as the rows it inserts have no key to be kept by.
+type Set struct {
+	Key          string
+	Description  string
+	Environments []string
+	Model        interface{}
+	File         string
+	Rows         interface{}
+	Run          func(tx *gorm.DB) error
+}
+
+// Applied represents a set which has been applied successfully.
+type Applied struct {
+	Key       string    `gorm:"column:key;size:128;primaryKey" json:"key"`
+	Checksum  string    `gorm:"column:checksum;size:64" json:"checksum"`
+	Rows      int64     `gorm:"column:rows" json:"rows"`
+	AppliedAt time.Time `gorm:"column:applied_at" json:"applied_at"`
+}
+
+// TableName returns the name of the table for the Applied struct.
+func (Applied) TableName() string {
+	return "seed_set"
+}
+
+// Status is a registered set with its state in the current environment.
+type Status struct {
+	Key         string     `json:"key"`
+	Description string     `json:"description"`
+	Enabled     bool       `json:"enabled"`
+	Pending     bool       `json:"pending"`
+	AppliedAt   *time.Time `json:"applied_at"`
+}
+
+var sets []Set
+var mu sync.Mutex
+
+// Register adds fixture sets to be applied by Run, in the order of registration.
+func Register(items ...Set) {
+	mu.Lock()
+	sets = append(sets, items...)
+	mu.Unlock()
+}
+
+// registered returns the registered sets.
+func registered() []Set {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Set(nil), sets...)
+}
+
+// Enabled reports whether the set applies to the environment.
+func (s Set) Enabled(environment string) bool {
+	if len(s.Environments) == 0 {
+		return true
+	}
+	for _, item := range s.Environments {
+		if strings.EqualFold(strings.TrimSpace(item), environment) {
+			return true
+		}
+	}
+	return false
+}
+
+// rows returns a pointer to the slice of models holding the rows of the set, nil if it has none.
+func (s Set) rows() (interface{}, error) {
+	if s.File == "" && s.Rows == nil {
+		return nil, nil
+	}
+	if s.Model == nil {
+		return nil, fmt.Errorf("seed set %s has rows but no model", s.Key)
+	}
+	var b []byte
+	var err error
+	if s.File != "" {
+		if b, err = os.ReadFile(s.File); err != nil {
+			return nil, err
+		}
+		if ext := filepath.Ext(s.File); ext == ".yml" || ext == ".yaml" {
+			var doc interface{}
+			if err := yaml.Unmarshal(b, &doc); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.File, err)
+			}
+			if b, err = json.Marshal(doc); err != nil {
+				return nil, err
+			}
+		}
+	} else if b, err = json.Marshal(s.Rows); err != nil {
+		return nil, err
+	}
+	return parseRows(reflect.Indirect(reflect.ValueOf(s.Model)).Type(), b)
+}
+
+// parseRows decodes the JSON list of rows into a pointer to a slice of the type.
+func parseRows(typ reflect.Type, b []byte) (interface{}, error) {
+	var slice = reflect.New(reflect.SliceOf(typ))
+	if err := json.Unmarshal(b, slice.Interface()); err != nil {
+		return nil, err
+	}
+	return slice.Interface(), nil
+}
+
+// checksum returns the checksum of the rows of the set, empty for the sets without rows. Sets are applied again
+// when their checksum changes.
+func checksum(rows interface{}) string {
+	if rows == nil {
+		return ""
+	}
+	b, _ := json.Marshal(rows)
+	var sum = sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Statuses returns the registered sets with their state in the current environment.
+func Statuses() ([]Status, error) {
+	var applied []Applied
+	if err := db.Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	var done = map[string]Applied{}
+	for _, item := range applied {
+		done[item.Key] = item
+	}
+	var result = []Status{}
+	for _, set := range registered() {
+		var status = Status{Key: set.Key, Description: set.Description, Enabled: set.Enabled(settings.Environment)}
+		if item, ok := done[set.Key]; ok {
+			var at = item.AppliedAt
+			status.AppliedAt = &at
+			if rows, err := set.rows(); err == nil {
+				status.Pending = checksum(rows) != item.Checksum
+			}
+		} else {
+			status.Pending = true
+		}
+		status.Pending = status.Pending && status.Enabled
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Run applies the pending sets of the current environment in order.
+// It stops at the first failing set, the following ones will be tried again on the next run.
+func Run() error {
+	var applied []Applied
+	if err := db.Find(&applied).Error; err != nil {
+		return err
+	}
+	var done = map[string]string{}
+	for _, item := range applied {
+		done[item.Key] = item.Checksum
+	}
+	for _, set := range registered() {
+		if !set.Enabled(settings.Environment) {
+			continue
+		}
+		rows, err := set.rows()
+		if err != nil {
+			return fmt.Errorf("seed set %s: %w", set.Key, err)
+		}
+		if sum, ok := done[set.Key]; ok && sum == checksum(rows) {
+			continue
+		}
+		if err := apply(set, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply applies the registered set with the given key, whether or not it was applied before.
+// It returns ErrSetDisabled for the sets which do not apply to the current environment.
+func Apply(key string) error {
+	for _, set := range registered() {
+		if set.Key == key {
+			if !set.Enabled(settings.Environment) {
+				return ErrSetDisabled
+			}
+			rows, err := set.rows()
+			if err != nil {
+				return fmt.Errorf("seed set %s: %w", set.Key, err)
+			}
+			return apply(set, rows)
+		}
+	}
+	return ErrUnknownSet
+}
+
+// apply inserts the rows of the set, and runs its function the first time, in a transaction, then records the set.
+func apply(set Set, rows interface{}) error {
+	if set.Key == "" {
+		return fmt.Errorf("invalid seed set %s", set.Description)
+	}
+	logger.Info("applying seed set", "key", set.Key, "environment", settings.Environment)
+	var record = Applied{Key: set.Key, Checksum: checksum(rows), AppliedAt: time.Now()}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if rows != nil && reflect.ValueOf(rows).Elem().Len() > 0 {
+			var result = tx.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(rows)
+			if result.Error != nil {
+				return result.Error
+			}
+			record.Rows = result.RowsAffected
+		}
+		if set.Run != nil {
+			var count int64
+			if err := tx.Model(&Applied{}).Where(&Applied{Key: set.Key}).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				if err := set.Run(tx); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error
+	})
+	if err != nil {
+		return fmt.Errorf("seed set %s failed: %w", set.Key, err)
+	}
+	logger.Info("seed set applied", "key", set.Key, "rows", record.Rows)
+	return nil
+}
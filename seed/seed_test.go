@@ -0,0 +1,131 @@
+package seed
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iesitalia/toolbox/resttest"
+	"gorm.io/gorm"
+)
+
+type country struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func TestEnabled(t *testing.T) {
+	var tests = []struct {
+		name         string
+		environments []string
+		environment  string
+		want         bool
+	}{
+		{"every environment", nil, "prod", true},
+		{"listed", []string{"dev", "stage"}, "stage", true},
+		{"case insensitive", []string{"Dev"}, "dev", true},
+		{"not listed", []string{"dev", "stage"}, "prod", false},
+		{"no environment", []string{"dev"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Set{Environments: tt.environments}).Enabled(tt.environment); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	var dir = t.TempDir()
+	var write = func(name string, content string) string {
+		var path = filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var want = &[]country{{Code: "IT", Name: "Italy"}, {Code: "FR", Name: "France"}}
+	var tests = []struct {
+		name string
+		set  Set
+		want interface{}
+		fail bool
+	}{
+		{"yaml", Set{Model: country{}, File: write("countries.yml", "- code: IT\n  name: Italy\n- code: FR\n  name: France\n")}, want, false},
+		{"json", Set{Model: &country{}, File: write("countries.json", `[{"code":"IT","name":"Italy"},{"code":"FR","name":"France"}]`)}, want, false},
+		{"rows", Set{Model: country{}, Rows: []map[string]string{{"code": "IT", "name": "Italy"}, {"code": "FR", "name": "France"}}}, want, false},
+		{"function only", Set{Key: "demo"}, nil, false},
+		{"no model", Set{Key: "countries", File: write("none.json", "[]")}, nil, true},
+		{"missing file", Set{Model: country{}, File: filepath.Join(dir, "missing.yml")}, nil, true},
+		{"invalid", Set{Model: country{}, File: write("invalid.json", `{"code":"IT"}`)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.set.rows()
+			if (err != nil) != tt.fail {
+				t.Fatalf("rows() error = %v, want fail %v", err, tt.fail)
+			}
+			if !tt.fail && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	var a = &[]country{{Code: "IT", Name: "Italy"}}
+	var b = &[]country{{Code: "IT", Name: "Italia"}}
+	if checksum(nil) != "" {
+		t.Errorf("checksum() of no rows is not empty")
+	}
+	if checksum(a) != checksum(&[]country{{Code: "IT", Name: "Italy"}}) {
+		t.Errorf("checksum() differs for the same rows")
+	}
+	if checksum(a) == checksum(b) {
+		t.Errorf("checksum() is the same for different rows")
+	}
+}
+
+type customer struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:64" json:"name"`
+}
+
+func TestApply(t *testing.T) {
+	var db = resttest.Setup(t, Applied{}, customer{})
+	var previous = sets
+	t.Cleanup(func() {
+		sets = previous
+	})
+	sets = nil
+	Register(Set{
+		Key: "demo-customers",
+		Run: func(tx *gorm.DB) error {
+			return tx.Create(&customer{Name: "ACME"}).Error
+		},
+	}, Set{
+		Key:          "stage-customers",
+		Environments: []string{"stage"},
+		Run: func(tx *gorm.DB) error {
+			return tx.Create(&customer{Name: "Stage"}).Error
+		},
+	})
+
+	if err := Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply("demo-customers"); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&customer{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected the function of the set to run once, got %d rows", count)
+	}
+	if err := Apply("stage-customers"); !errors.Is(err, ErrSetDisabled) {
+		t.Errorf("Apply of a set of another environment = %v, want ErrSetDisabled", err)
+	}
+}
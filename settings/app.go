@@ -0,0 +1,48 @@
+package settings
+
+import (
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm"
+)
+
+type App struct {
+}
+
+// Register registers the setting models, and reloads the stored values once their changes are committed
+// so listeners are notified of the change.
+func (a App) Register() error {
+	db.UseModel(Setting{}, TenantSetting{})
+	return toolbox.OnCommit(evo.GetDBO(), "settings:reload", Setting{}, func(tx *gorm.DB) {
+		if err := Reload(); err != nil {
+			logger.Error("unable to reload settings", "error", err.Error())
+		}
+	})
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+// WhenReady loads the stored values and reloads them every RefreshInterval.
+func (a App) WhenReady() error {
+	if err := Reload(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(RefreshInterval) {
+			if err := Reload(); err != nil {
+				logger.Error("unable to reload settings", "error", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (a App) Name() string {
+	return "settings"
+}
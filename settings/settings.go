@@ -0,0 +1,119 @@
+package settings
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/logger"
+	"gorm.io/gorm/clause"
+)
+
+// TenantSetting represents a configuration value overridden for a single tenant.
+// Keys follow the evo settings convention (DOMAIN.NAME) and are stored uppercase.
+type TenantSetting struct {
+	Tenant string `gorm:"column:tenant;size:64;primaryKey" json:"tenant"`
+	Key    string `gorm:"column:key;size:128;primaryKey" json:"key"`
+	Value  string `gorm:"column:value;type:text" json:"value"`
+}
+
+// TableName returns the name of the table for the TenantSetting struct.
+func (TenantSetting) TableName() string {
+	return "tenant_setting"
+}
+
+// cache holds the loaded overrides of each tenant, keyed by tenant then by setting key.
+var cache = map[string]map[string]generic.Value{}
+
+// generation is incremented by every invalidation so overrides read before it are not cached.
+var generation uint64
+var mu sync.RWMutex
+
+// Has returns the override of the given key for the tenant and reports whether the tenant overrides it.
+func Has(tenant string, key string) (generic.Value, bool) {
+	if tenant == "" {
+		return generic.Parse(""), false
+	}
+	v, ok := load(tenant)[strings.ToUpper(key)]
+	return v, ok
+}
+
+// All returns all overrides of the given tenant.
+func All(tenant string) map[string]generic.Value {
+	var result = map[string]generic.Value{}
+	for k, v := range load(tenant) {
+		result[k] = v
+	}
+	return result
+}
+
+// Set stores an override of the key for the tenant and invalidates the tenant cache.
+func Set(tenant string, key string, value any) error {
+	var item = TenantSetting{
+		Tenant: tenant,
+		Key:    strings.ToUpper(key),
+		Value:  generic.Parse(value).String(),
+	}
+	err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&item).Error
+	Invalidate(tenant)
+	return err
+}
+
+// Unset removes the override of the key for the tenant so the global value applies again.
+func Unset(tenant string, key string) error {
+	err := db.Where("`tenant` = ? AND `key` = ?", tenant, strings.ToUpper(key)).Delete(&TenantSetting{}).Error
+	Invalidate(tenant)
+	return err
+}
+
+// Invalidate drops the cached overrides of the given tenants, they will be reloaded on next access.
+func Invalidate(tenants ...string) {
+	mu.Lock()
+	for _, tenant := range tenants {
+		delete(cache, tenant)
+	}
+	generation++
+	mu.Unlock()
+}
+
+// InvalidateAll drops the cached overrides of every tenant.
+func InvalidateAll() {
+	mu.Lock()
+	cache = map[string]map[string]generic.Value{}
+	generation++
+	mu.Unlock()
+}
+
+// load returns the overrides of the tenant, reading them from the database on cache miss.
+// Overrides are not cached when the read fails or the cache is invalidated meanwhile.
+func load(tenant string) map[string]generic.Value {
+	mu.RLock()
+	values, ok := cache[tenant]
+	var current = generation
+	mu.RUnlock()
+	if ok {
+		return values
+	}
+
+	var items []TenantSetting
+	values = map[string]generic.Value{}
+	if err := db.Where("`tenant` = ?", tenant).Find(&items).Error; err != nil {
+		logger.Error("unable to load tenant settings", "tenant", tenant, "error", err.Error())
+		return values
+	}
+	for _, item := range items {
+		values[item.Key] = generic.Parse(item.Value)
+	}
+	store(tenant, values, current)
+	return values
+}
+
+// store caches the overrides of the tenant read at the given generation, unless the cache was invalidated since.
+func store(tenant string, values map[string]generic.Value, readAt uint64) {
+	mu.Lock()
+	if generation == readAt {
+		cache[tenant] = values
+	}
+	mu.Unlock()
+}
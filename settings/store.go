@@ -0,0 +1,195 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/getevo/evo/v2/lib/settings"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Environment selects the stored values of the running deployment, e.g. production or staging.
+// Values stored for the empty environment apply to every environment without its own value.
+var Environment = os.Getenv("APP_ENV")
+
+// RefreshInterval specifies how often the stored values are reloaded to pick up changes made by other instances.
+var RefreshInterval = 30 * time.Second
+
+// Setting is a configuration value stored in the database, managed through the rest endpoints of the setting resource.
+// Keys follow the evo settings convention (DOMAIN.NAME) and are stored uppercase.
+type Setting struct {
+	Key         string    `gorm:"column:key;size:128;primaryKey" json:"key"`
+	Environment string    `gorm:"column:environment;size:32;primaryKey" json:"environment"`
+	Value       string    `gorm:"column:value;type:text" json:"value"`
+	Description string    `gorm:"column:description;size:255" json:"description"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName returns the name of the table for the Setting struct.
+func (Setting) TableName() string {
+	return "setting"
+}
+
+// BeforeSave normalizes the key.
+func (s *Setting) BeforeSave(tx *gorm.DB) error {
+	s.Key = strings.ToUpper(strings.TrimSpace(s.Key))
+	return nil
+}
+
+// stored holds the values of the current environment, falling back to the empty environment.
+var stored = map[string]string{}
+var storedMu sync.RWMutex
+var reloadMu sync.Mutex
+
+type listener struct {
+	pattern string
+	fn      func(key string, value generic.Value)
+}
+
+var listeners []listener
+var listenersMu sync.RWMutex
+
+// OnChange calls fn when a stored value matching the pattern is added, changed or removed.
+// The pattern is a key, a prefix ending with `*` such as REST.*, or `*` for every key.
+// Removed keys are notified with their value from the evo settings.
+func OnChange(pattern string, fn func(key string, value generic.Value)) {
+	listenersMu.Lock()
+	listeners = append(listeners, listener{pattern: strings.ToUpper(pattern), fn: fn})
+	listenersMu.Unlock()
+}
+
+func match(pattern, key string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(key, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == key
+}
+
+// Reload reads the stored values of the current environment and notifies the listeners of the changed keys.
+// The cached tenant overrides are dropped so the changes made by other instances are picked up.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	InvalidateAll()
+	var items []Setting
+	if err := db.Where("environment IN (?)", []string{"", Environment}).Order("environment ASC").Find(&items).Error; err != nil {
+		return err
+	}
+	var values = map[string]string{}
+	for _, item := range items {
+		// the current environment is ordered last and overrides the empty one
+		values[item.Key] = item.Value
+	}
+
+	storedMu.Lock()
+	var previous = stored
+	stored = values
+	storedMu.Unlock()
+
+	var changed []string
+	for key, value := range values {
+		if old, ok := previous[key]; !ok || old != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := values[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	listenersMu.RLock()
+	var list = listeners
+	listenersMu.RUnlock()
+	for _, key := range changed {
+		for _, l := range list {
+			if match(l.pattern, key) {
+				l.fn(key, Get("", key))
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the given key for the tenant, resolved in order from
+// the tenant override, the value stored for the environment and the evo settings.
+func Get(tenant string, key string) generic.Value {
+	if v, ok := Has(tenant, key); ok {
+		return v
+	}
+	storedMu.RLock()
+	v, ok := stored[strings.ToUpper(key)]
+	storedMu.RUnlock()
+	if ok {
+		return generic.Parse(v)
+	}
+	return settings.Get(key)
+}
+
+// GetAs returns the value of the key converted to T, or the fallback if the key is not set:
+//
+//	var size = settings.GetAs[int]("rest.max_page_size", 100)
+func GetAs[T any](key string, fallback ...T) T {
+	return GetTenantAs[T]("", key, fallback...)
+}
+
+// GetTenantAs returns the value of the key for the tenant converted to T, or the fallback if the key is not set.
+func GetTenantAs[T any](tenant string, key string, fallback ...T) T {
+	var result T
+	var v = Get(tenant, key)
+	if v.String() == "" {
+		if len(fallback) > 0 {
+			return fallback[0]
+		}
+		return result
+	}
+	switch ptr := any(&result).(type) {
+	case *string:
+		*ptr = v.String()
+	case *int:
+		*ptr = v.Int()
+	case *int64:
+		*ptr = v.Int64()
+	case *uint:
+		*ptr = v.Uint()
+	case *uint64:
+		*ptr = v.Uint64()
+	case *float64:
+		*ptr = v.Float64()
+	case *bool:
+		*ptr = v.Bool()
+	case *time.Duration:
+		d, err := time.ParseDuration(v.String())
+		if err != nil {
+			d = time.Duration(v.Int64())
+		}
+		*ptr = d
+	case *[]string:
+		for _, item := range strings.Split(v.String(), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*ptr = append(*ptr, item)
+			}
+		}
+	default:
+		if err := json.Unmarshal([]byte(v.String()), ptr); err != nil && len(fallback) > 0 {
+			return fallback[0]
+		}
+	}
+	return result
+}
+
+// SetEnv stores the value of the key for the environment, empty for every environment.
+func SetEnv(environment string, key string, value any) error {
+	var item = Setting{Key: key, Environment: environment, Value: generic.Parse(value).String()}
+	return db.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"})}).Create(&item).Error
+}
+
+// UnsetEnv removes the value of the key stored for the environment.
+func UnsetEnv(environment string, key string) error {
+	return db.Where("`key` = ? AND environment = ?", strings.ToUpper(key), environment).Delete(&Setting{}).Error
+}
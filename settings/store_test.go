@@ -0,0 +1,101 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/generic"
+)
+
+func TestGet(t *testing.T) {
+	stored = map[string]string{
+		"REST.MAX_PAGE_SIZE": "500",
+		"ACL.CACHE_TTL":      "90s",
+		"MAIL.ENABLED":       "true",
+		"MAIL.OPERATORS":     "a@example.com, b@example.com",
+		"REST.RATIO":         "0.25",
+		"REST.LIMITS":        `{"max":3}`,
+	}
+	defer func() { stored = map[string]string{} }()
+
+	if got := GetAs[int]("rest.max_page_size"); got != 500 {
+		t.Errorf("GetAs[int] = %d", got)
+	}
+	if got := GetAs[time.Duration]("ACL.CACHE_TTL"); got != 90*time.Second {
+		t.Errorf("GetAs[time.Duration] = %s", got)
+	}
+	if got := GetAs[bool]("MAIL.ENABLED"); !got {
+		t.Errorf("GetAs[bool] = %v", got)
+	}
+	if got := GetAs[[]string]("MAIL.OPERATORS"); !reflect.DeepEqual(got, []string{"a@example.com", "b@example.com"}) {
+		t.Errorf("GetAs[[]string] = %v", got)
+	}
+	if got := GetAs[float64]("REST.RATIO"); got != 0.25 {
+		t.Errorf("GetAs[float64] = %v", got)
+	}
+	if got := GetAs[map[string]int]("REST.LIMITS"); got["max"] != 3 {
+		t.Errorf("GetAs[map] = %v", got)
+	}
+	if got := GetAs[struct{ X int }]("REST.RATIO", struct{ X int }{X: 7}); got.X != 7 {
+		t.Errorf("Get with invalid JSON should return the fallback, got %v", got)
+	}
+}
+
+func TestHas(t *testing.T) {
+	mu.Lock()
+	cache["acme"] = map[string]generic.Value{"REST.MAX_PAGE_SIZE": generic.Parse("50")}
+	mu.Unlock()
+	defer Invalidate("acme")
+
+	if v, ok := Has("acme", "rest.max_page_size"); !ok || v.Int() != 50 {
+		t.Errorf("Has() = %v, %v, want 50, true", v, ok)
+	}
+	if _, ok := Has("acme", "MAIL.ENABLED"); ok {
+		t.Error("Has() of a key not overridden = true")
+	}
+	if _, ok := Has("", "REST.MAX_PAGE_SIZE"); ok {
+		t.Error("Has() without tenant = true")
+	}
+}
+
+func TestStoreAfterInvalidate(t *testing.T) {
+	defer Invalidate("acme")
+	var values = map[string]generic.Value{"REST.MAX_PAGE_SIZE": generic.Parse("50")}
+	mu.RLock()
+	var readAt = generation
+	mu.RUnlock()
+
+	Invalidate("acme")
+	store("acme", values, readAt)
+	if _, ok := cache["acme"]; ok {
+		t.Error("expected the overrides read before an invalidation not to be cached")
+	}
+
+	mu.RLock()
+	readAt = generation
+	mu.RUnlock()
+	store("acme", values, readAt)
+	if v, ok := Has("acme", "REST.MAX_PAGE_SIZE"); !ok || v.Int() != 50 {
+		t.Errorf("Has() = %v, %v, want the cached override", v, ok)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "REST.MAX_PAGE_SIZE", true},
+		{"REST.*", "REST.MAX_PAGE_SIZE", true},
+		{"REST.*", "ACL.CACHE_TTL", false},
+		{"REST.MAX_PAGE_SIZE", "REST.MAX_PAGE_SIZE", true},
+		{"REST.MAX", "REST.MAX_PAGE_SIZE", false},
+	}
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/getevo/evo/v2/lib/settings"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// PREFIX specifies the prefix for storage routes.
+var PREFIX = "/admin"
+
+type App struct {
+}
+
+// Register registers the attachment model and configures the backend from the STORAGE.BACKEND setting,
+// unless Default is already set:
+// - local: STORAGE.PATH, the directory of the files, defaults to ./storage.
+// - s3: STORAGE.S3_ENDPOINT, STORAGE.S3_REGION, STORAGE.S3_BUCKET, STORAGE.S3_ACCESS_KEY, STORAGE.S3_SECRET_KEY and STORAGE.S3_PATH_STYLE.
+//
+// STORAGE.SECRET is required by the backends not implementing Presigner, such as local.
+func (a App) Register() error {
+	db.UseModel(Attachment{})
+	if secret := settings.Get("STORAGE.SECRET").String(); secret != "" {
+		Secret = []byte(secret)
+	}
+	if Default == nil {
+		configure()
+	}
+	if _, ok := Default.(Presigner); !ok && len(Secret) == 0 {
+		return ErrorNoSecret
+	}
+	return nil
+}
+
+// configure sets Default to the backend of the STORAGE.BACKEND setting.
+func configure() {
+	switch settings.Get("STORAGE.BACKEND").String() {
+	case "s3":
+		Default = S3{
+			Endpoint:  settings.Get("STORAGE.S3_ENDPOINT").String(),
+			Region:    settings.Get("STORAGE.S3_REGION").String(),
+			Bucket:    settings.Get("STORAGE.S3_BUCKET").String(),
+			AccessKey: settings.Get("STORAGE.S3_ACCESS_KEY").String(),
+			SecretKey: settings.Get("STORAGE.S3_SECRET_KEY").String(),
+			PathStyle: settings.Get("STORAGE.S3_PATH_STYLE").Bool(),
+		}
+	default:
+		var path = settings.Get("STORAGE.PATH").String()
+		if path == "" {
+			path = "./storage"
+		}
+		Default = Local{Root: path}
+	}
+}
+
+// Router sets up the download endpoint of the signed URLs.
+// GET /storage/attachments/:id?expires=...&signature=... downloads the file of the attachment.
+func (a App) Router() error {
+	evo.Get(PREFIX+"/storage/attachments/:id", func(request *evo.Request) interface{} {
+		var id = request.Param("id").Uint64()
+		if err := verify(id, request.Query("expires").String(), request.Query("signature").String()); err != nil {
+			return err
+		}
+		var attachment Attachment
+		if db.Where("id = ?", id).Take(&attachment).RowsAffected == 0 {
+			return rest.ErrorObjectNotExist
+		}
+		if Default == nil {
+			return ErrorNoBackend
+		}
+		r, err := Default.Open(context.Background(), attachment.Key)
+		if err != nil {
+			return err
+		}
+		request.SetHeader("Content-Type", attachment.Mime)
+		request.SetHeader("Content-Disposition", contentDisposition(attachment.Filename))
+		// the stream is closed once the response is written
+		request.Context.Context().SetBodyStream(r, int(attachment.Size))
+		return nil
+	})
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "storage"
+}
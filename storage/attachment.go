@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox"
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/metering"
+	"github.com/iesitalia/toolbox/rest"
+	"gorm.io/gorm"
+)
+
+// Secret signs the download URLs of backends not implementing Presigner. It is set from the STORAGE.SECRET
+// setting and must be shared by every instance, so URLs signed by one instance are accepted by the others
+// and across restarts. These URLs can not be signed while it is empty.
+var Secret []byte
+
+// Attachment is a file linked to a row of a rest resource, identified by its table and primary key.
+// URL is a download link valid for URLExpiry, filled when the attachment is read. Attachments are not a rest
+// resource of their own, they are managed through the endpoints of their rows, see Attachments.
+type Attachment struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OwnerTable string    `gorm:"column:owner_table;size:64;index:attachment_owner_idx" json:"owner_table"`
+	OwnerID    string    `gorm:"column:owner_id;size:64;index:attachment_owner_idx" json:"owner_id"`
+	Filename   string    `gorm:"column:filename;size:255" json:"filename"`
+	Mime       string    `gorm:"column:mime;size:128" json:"mime"`
+	Size       int64     `gorm:"column:size" json:"size"`
+	Checksum   string    `gorm:"column:checksum;size:64" json:"checksum"`
+	Key        string    `gorm:"column:key;size:512" json:"-"`
+	Tenant     string    `gorm:"column:tenant;size:64" json:"-"`
+	CreatedBy  string    `gorm:"column:created_by;size:36" json:"created_by"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	URL        string    `gorm:"-" json:"url"`
+}
+
+// TableName returns the name of the table for the Attachment struct.
+func (Attachment) TableName() string {
+	return "attachment"
+}
+
+// AfterFind sets the download URL of the attachment.
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	if url, err := a.SignedURL(URLExpiry); err == nil {
+		a.URL = url
+	}
+	return nil
+}
+
+// AfterDelete removes the file of the attachment from the backend and releases its size from the storage
+// usage of its tenant.
+func (a *Attachment) AfterDelete(tx *gorm.DB) error {
+	if Default == nil || a.Key == "" {
+		return nil
+	}
+	metering.Record(a.Tenant, metering.StorageBytes, -a.Size)
+	if err := Default.Delete(context.Background(), a.Key); err != nil {
+		logger.Error("unable to delete attachment file", "attachment", a.ID, "key", a.Key, "error", err.Error())
+	}
+	return nil
+}
+
+// SignedURL returns a URL downloading the attachment until it expires.
+func (a *Attachment) SignedURL(expires time.Duration) (string, error) {
+	if Default == nil {
+		return "", ErrorNoBackend
+	}
+	if presigner, ok := Default.(Presigner); ok {
+		return presigner.PresignGet(a.Key, a.Filename, expires)
+	}
+	if len(Secret) == 0 {
+		return "", ErrorNoSecret
+	}
+	var deadline = strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	return fmt.Sprintf("%s/storage/attachments/%d?expires=%s&signature=%s", PREFIX, a.ID, deadline, sign(a.ID, deadline)), nil
+}
+
+// sign returns the signature of the download URL of the attachment.
+func sign(id uint64, expires string) string {
+	var h = hmac.New(sha256.New, Secret)
+	h.Write([]byte(strconv.FormatUint(id, 10) + ":" + expires))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify checks the signature and expiry of a download URL.
+func verify(id uint64, expires string, signature string) error {
+	deadline, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > deadline || len(Secret) == 0 {
+		return ErrorInvalidSignature
+	}
+	if !hmac.Equal([]byte(sign(id, expires)), []byte(signature)) {
+		return ErrorInvalidSignature
+	}
+	return nil
+}
+
+// Upload stores the content of r as a file attached to the row of the table and records the attachment.
+// The filename is normalized and the mime type detected from the content when empty. The size of the file is
+// added to the metering.StorageBytes usage of the tenant.
+func Upload(ctx context.Context, tenant string, table string, ownerID string, filename string, mimeType string, r io.Reader, user string) (*Attachment, error) {
+	if Default == nil {
+		return nil, ErrorNoBackend
+	}
+	var attachment = Attachment{
+		Tenant:     tenant,
+		OwnerTable: table,
+		OwnerID:    ownerID,
+		Filename:   normalizeFilename(filename),
+		Mime:       mimeType,
+		CreatedBy:  user,
+	}
+	// spool the file to compute its size and checksum before writing it to the backend
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	var hash = sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxSize {
+		return nil, ErrorFileTooLarge
+	}
+	attachment.Size = size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+	if attachment.Mime == "" || attachment.Mime == "application/octet-stream" {
+		attachment.Mime = detectMime(tmp, attachment.Filename)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	attachment.Key = fmt.Sprintf("%s/%s/%s-%s", table, time.Now().Format("2006/01"), hex.EncodeToString(randomBytes(8)), attachment.Filename)
+	if err := Default.Put(ctx, attachment.Key, tmp, size, attachment.Mime); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&attachment).Error; err != nil {
+		_ = Default.Delete(ctx, attachment.Key)
+		return nil, err
+	}
+	metering.Record(tenant, metering.StorageBytes, size)
+	attachment.URL, _ = attachment.SignedURL(URLExpiry)
+	return &attachment, nil
+}
+
+// List returns the attachments of the row of the table, latest first.
+func List(table string, ownerID string) ([]Attachment, error) {
+	var result []Attachment
+	return result, db.Where("owner_table = ? AND owner_id = ?", table, ownerID).Order("id DESC").Find(&result).Error
+}
+
+// normalizeFilename normalizes the name keeping its extension.
+func normalizeFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	var ext = strings.ToLower(filepath.Ext(filename))
+	var name = toolbox.NormalizeFilename(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	if name == "" {
+		name = "file"
+	}
+	if ext = toolbox.NormalizeFilename(strings.TrimPrefix(ext, ".")); ext != "" {
+		return name + "." + ext
+	}
+	return name
+}
+
+// detectMime returns the mime type of the extension, or sniffed from the content of the file.
+func detectMime(f *os.File, filename string) string {
+	if t := mime.TypeByExtension(filepath.Ext(filename)); t != "" {
+		return t
+	}
+	var b = make([]byte, 512)
+	n, _ := f.ReadAt(b, 0)
+	return http.DetectContentType(b[:n])
+}
+
+func contentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+func randomBytes(n int) []byte {
+	var b = make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// Attachments adds endpoints to upload, list and delete the files of a row to the resources of the models
+// embedding it. Permissions and policies of the resource apply to the row.
+// GET /rest/:table/attachments/:pk lists the attachments of the row.
+// POST /rest/:table/attachments/:pk uploads the files of the multipart `file` field.
+// DELETE /rest/:table/attachments/:pk/:attachment deletes an attachment of the row.
+type Attachments struct{}
+
+// RESTActions registers the attachment endpoints on the resources of models embedding Attachments.
+func (Attachments) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "ATTACHMENTS",
+			Method:      rest.GET,
+			URL:         "/attachments",
+			PKUrl:       true,
+			Handler:     ListAttachments,
+			Description: "list the files attached to the row",
+		},
+		{
+			Name:        "UPLOAD_ATTACHMENT",
+			Method:      rest.POST,
+			URL:         "/attachments",
+			PKUrl:       true,
+			Handler:     UploadAttachments,
+			Description: "upload files attached to the row",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+		{
+			Name:        "DELETE_ATTACHMENT",
+			Method:      rest.DELETE,
+			URL:         "/attachments",
+			PKUrl:       true,
+			URLParams:   []rest.Filter{{Name: "attachment"}},
+			Handler:     DeleteAttachment,
+			Description: "delete a file attached to the row",
+			Permissions: []acl.Permission{rest.UpdatePermission},
+		},
+	}
+}
+
+// owner returns the primary key of the row given by the request, checking it exists and is visible to the user.
+func owner(context *rest.Context) (string, error) {
+	var object = context.GetObject()
+	found, err := context.FindByPrimaryKey(object.Addr().Interface())
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", rest.ErrorObjectNotExist
+	}
+	var keys []string
+	for _, field := range context.Schema.PrimaryFields {
+		v, _ := field.ValueOf(context.Request.Context.Context(), reflect.Indirect(object))
+		keys = append(keys, fmt.Sprint(v))
+	}
+	return strings.Join(keys, ","), nil
+}
+
+// ListAttachments lists the attachments of the row given by the primary key of the request.
+func ListAttachments(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	id, err := owner(context)
+	if err != nil {
+		return err
+	}
+	list, err := List(context.Schema.Table, id)
+	if err != nil {
+		return err
+	}
+	context.Response.Data = list
+	return nil
+}
+
+// UploadAttachments stores the files of the multipart `file` field as attachments of the row given by the primary key of the request.
+func UploadAttachments(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	id, err := owner(context)
+	if err != nil {
+		return err
+	}
+	form, err := context.Request.MultipartForm()
+	if err != nil {
+		return err
+	}
+	if len(form.File["file"]) == 0 {
+		return fmt.Errorf("no file uploaded")
+	}
+	var result []*Attachment
+	for _, header := range form.File["file"] {
+		if header.Size > MaxSize {
+			return ErrorFileTooLarge
+		}
+		f, err := header.Open()
+		if err != nil {
+			return err
+		}
+		attachment, err := Upload(context.Request.Context.Context(), context.Tenant(), context.Schema.Table, id, header.Filename, header.Header.Get("Content-Type"), f, context.User().UUID())
+		f.Close()
+		if err != nil {
+			return err
+		}
+		result = append(result, attachment)
+	}
+	context.Response.Data = result
+	return nil
+}
+
+// DeleteAttachment deletes the attachment given by the request from the row given by the primary key.
+func DeleteAttachment(context *rest.Context) error {
+	if err := context.HasPerm("UPDATE"); err != nil {
+		return err
+	}
+	id, err := owner(context)
+	if err != nil {
+		return err
+	}
+	var attachment Attachment
+	if db.Where("id = ? AND owner_table = ? AND owner_id = ?", context.Request.Param("attachment").Uint64(), context.Schema.Table, id).
+		Take(&attachment).RowsAffected == 0 {
+		return rest.ErrorObjectNotExist
+	}
+	return db.Delete(&attachment).Error
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+type folder struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:64" json:"name"`
+	Attachments
+}
+
+type admin struct {
+	evo.DefaultUserInterface
+}
+
+func (admin) Anonymous() bool {
+	return false
+}
+
+func (admin) HasPermission(permission string) bool {
+	return true
+}
+
+func TestAttachmentEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, folder{})
+	if err := db.AutoMigrate(&Attachment{}); err != nil {
+		t.Fatal(err)
+	}
+	var backend, secret = Default, Secret
+	Default, Secret = Local{Root: t.TempDir()}, []byte("secret")
+	t.Cleanup(func() {
+		Default, Secret = backend, secret
+		db.Where("owner_table = ?", "folders").Delete(&Attachment{})
+	})
+	db.Create(&[]folder{{ID: 1, Name: "hr"}, {ID: 2, Name: "sales"}})
+	var upload = func(owner string, name string) *Attachment {
+		t.Helper()
+		attachment, err := Upload(context.Background(), "", "folders", owner, name, "text/plain", strings.NewReader(name), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attachment
+	}
+	var payroll = upload("1", "payroll.txt")
+	var brochure = upload("2", "brochure.txt")
+	resttest.AsUser(t, admin{})
+
+	if status, _ := resttest.Request(t, http.MethodGet, "/admin/rest/attachment/all", nil); status != http.StatusNotFound {
+		t.Errorf("expected no attachment resource, got %d", status)
+	}
+	if page := resttest.Do[Attachment](t, http.MethodDelete, fmt.Sprintf("/admin/rest/folders/attachments/2/%d", payroll.ID), nil); page.Success {
+		t.Errorf("expected the attachment of another row not to be deleted, got %+v", page)
+	}
+	var listed = resttest.Get[[]Attachment](t, "/admin/rest/folders/attachments/2")
+	if len(listed.Data) != 1 || listed.Data[0].ID != brochure.ID || listed.Data[0].URL == "" {
+		t.Errorf("expected the attachments of the row in the data of the response, got %+v", listed)
+	}
+
+	var body bytes.Buffer
+	var form = multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "price-list.txt")
+	part.Write([]byte("prices"))
+	form.Close()
+	var req = httptest.NewRequest(http.MethodPost, "/admin/rest/folders/attachments/2", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := evo.GetFiber().Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var uploaded resttest.Pagination[[]Attachment]
+	json.NewDecoder(resp.Body).Decode(&uploaded)
+	if !uploaded.Success || len(uploaded.Data) != 1 || uploaded.Data[0].Filename != "price-list.txt" {
+		t.Errorf("expected the uploaded attachments in the data of the response, got %+v", uploaded)
+	}
+
+	resttest.Delete[Attachment](t, fmt.Sprintf("/admin/rest/folders/attachments/1/%d", payroll.ID))
+	var left []Attachment
+	db.Order("id").Find(&left)
+	if len(left) != 2 || left[0].ID != brochure.ID {
+		t.Errorf("expected the attachment of the row only to be deleted, got %+v", left)
+	}
+}
@@ -0,0 +1,53 @@
+// Package storage stores files attached to the rows of rest resources.
+//
+// Files are written to the Default backend, a local directory or an S3 compatible bucket, and recorded
+// in the attachment table with their owner row. Models embedding Attachments get endpoints to upload
+// and list the files of a row:
+//
+//	type Order struct {
+//		ID uint64 `gorm:"primaryKey"`
+//		rest.API
+//		storage.Attachments
+//	}
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrorNoBackend is returned when no storage backend is configured.
+var ErrorNoBackend = errors.New("storage backend is not configured")
+
+// ErrorFileTooLarge is returned when an uploaded file exceeds MaxSize.
+var ErrorFileTooLarge = errors.New("file is too large")
+
+// ErrorInvalidSignature is returned when a download URL is expired or its signature does not match.
+var ErrorInvalidSignature = errors.New("invalid or expired signature")
+
+// ErrorNoSecret is returned when download URLs must be signed with the Secret and it is not configured.
+var ErrorNoSecret = errors.New("storage secret is not configured, set STORAGE.SECRET")
+
+// MaxSize specifies the maximum size in bytes of an uploaded file.
+var MaxSize int64 = 32 << 20
+
+// URLExpiry specifies how long the download URLs returned with the attachments are valid.
+var URLExpiry = 15 * time.Minute
+
+// Backend stores the content of the files by key.
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, mime string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends serving the files directly, such as S3.
+// Attachments of other backends are downloaded through the storage app using a signed URL.
+type Presigner interface {
+	PresignGet(key string, filename string, expires time.Duration) (string, error)
+}
+
+// Default is the backend used by the attachments, configured by the storage app.
+var Default Backend
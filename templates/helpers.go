@@ -0,0 +1,98 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// builtins are the helpers every engine starts with.
+var builtins = map[string]Helper{
+	"upper":    stringHelper(strings.ToUpper),
+	"lower":    stringHelper(strings.ToLower),
+	"trim":     stringHelper(strings.TrimSpace),
+	"title":    stringHelper(cases.Title(language.Und).String),
+	"slug":     stringHelper(toolbox.Slugify),
+	"html":     stringHelper(html.EscapeString),
+	"urlquery": stringHelper(url.QueryEscape),
+	"default":  defaultHelper,
+	"truncate": truncateHelper,
+	"date":     dateHelper,
+	"json":     jsonHelper,
+}
+
+// stringHelper adapts a string function to a Helper.
+func stringHelper(fn func(string) string) Helper {
+	return func(value interface{}, args ...string) (interface{}, error) {
+		return fn(format(value)), nil
+	}
+}
+
+// defaultHelper returns its argument if the value is missing or empty.
+func defaultHelper(value interface{}, args ...string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("default expects 1 argument")
+	}
+	if value == nil || format(value) == "" {
+		return args[0], nil
+	}
+	return value, nil
+}
+
+// truncateHelper cuts the value to the given number of characters.
+func truncateHelper(value interface{}, args ...string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("truncate expects 1 argument")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var runes = []rune(format(value))
+	if len(runes) > n {
+		runes = runes[:n]
+	}
+	return string(runes), nil
+}
+
+// dateHelper formats a time, or a value parsed as a time, using a Go layout.
+func dateHelper(value interface{}, args ...string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("date expects 1 argument")
+	}
+	if value == nil {
+		return "", nil
+	}
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		t = *v
+	default:
+		parsed, err := generic.Parse(value).Time()
+		if err != nil {
+			return nil, err
+		}
+		t = parsed
+	}
+	return t.Format(args[0]), nil
+}
+
+// jsonHelper encodes the value as JSON.
+func jsonHelper(value interface{}, args ...string) (interface{}, error) {
+	b, err := json.Marshal(value)
+	return string(b), err
+}
@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isPathChar reports whether c can be part of a $path variable, as in tpl.
+func isPathChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '[' || c == ']'
+}
+
+// parse splits the source into literals, $path variables and ${path | helper args} expressions.
+func parse(src string) (*Template, error) {
+	var t = &Template{}
+	var literal strings.Builder
+	var flush = func() {
+		if literal.Len() > 0 {
+			t.segments = append(t.segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(src); i++ {
+		if src[i] != '$' || i+1 == len(src) {
+			literal.WriteByte(src[i])
+			continue
+		}
+		if src[i+1] == '{' {
+			var end = closing(src[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed template expression at %d", i)
+			}
+			s, err := parseExpression(src[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			s.raw = src[i : i+end+1]
+			flush()
+			t.segments = append(t.segments, s)
+			i += end
+			continue
+		}
+		var j = i + 1
+		for j < len(src) && isPathChar(src[j]) {
+			j++
+		}
+		if j == i+1 {
+			literal.WriteByte(src[i])
+			continue
+		}
+		flush()
+		t.segments = append(t.segments, segment{raw: src[i:j], path: src[i+1 : j]})
+		i = j - 1
+	}
+	flush()
+	return t, nil
+}
+
+// closing returns the index of the } closing the ${ expression at the start of s, skipping the quoted
+// strings of the expression, or -1 if the expression is not closed.
+func closing(s string) int {
+	var quoted = false
+	for i := 2; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '}':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseExpression parses "path | helper arg "quoted arg" | helper".
+func parseExpression(expr string) (segment, error) {
+	var parts = splitPipes(expr)
+	var s = segment{path: strings.TrimSpace(parts[0])}
+	if s.path == "" {
+		return s, fmt.Errorf("empty template expression %q", expr)
+	}
+	for _, part := range parts[1:] {
+		fields, err := splitArgs(part)
+		if err != nil {
+			return s, err
+		}
+		if len(fields) == 0 {
+			return s, fmt.Errorf("empty helper in template expression %q", expr)
+		}
+		s.calls = append(s.calls, call{name: fields[0], args: fields[1:]})
+	}
+	return s, nil
+}
+
+// splitPipes splits the expression on | outside of quoted strings.
+func splitPipes(expr string) []string {
+	var parts []string
+	var quoted = false
+	var start = 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+// splitArgs splits a helper call on spaces, unquoting double quoted arguments.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		if s[0] == '"' {
+			var end = 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string in %q", s)
+			}
+			arg, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			s = strings.TrimSpace(s[end+1:])
+			continue
+		}
+		var end = strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		args = append(args, s[:end])
+		s = strings.TrimSpace(s[end:])
+	}
+	return args, nil
+}
@@ -0,0 +1,244 @@
+// Package templates renders user-defined text templates.
+//
+// Templates use the tpl syntax where $user.Name is replaced by the Name of the user parameter,
+// extended with ${path | helper args...} expressions piping the value through registered helpers:
+//
+//	Dear ${user.Name | title}, your order $order.ID ships on ${order.ShipAt | date "02/01/2006"}
+//
+// Only registered helpers can be called, templates can not invoke methods of the parameters.
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/getevo/evo/v2/lib/dot"
+	"github.com/getevo/evo/v2/lib/generic"
+)
+
+// MissingKey defines what happens when a variable is not found in the parameters.
+type MissingKey int
+
+const (
+	// MissingKeep leaves the variable untouched, as tpl.Render does.
+	MissingKeep MissingKey = iota
+	// MissingEmpty replaces the variable by an empty string.
+	MissingEmpty
+	// MissingError makes Execute fail.
+	MissingError
+)
+
+// ErrMissingKey is returned by Execute when a variable is not found and the policy is MissingError.
+var ErrMissingKey = errors.New("missing template key")
+
+// MaxCompiled bounds the number of templates cached by each engine, the cache is emptied when it is reached.
+var MaxCompiled = 1000
+
+// Helper transforms a value. Args are the literal arguments written after the helper name.
+type Helper func(value interface{}, args ...string) (interface{}, error)
+
+// Engine compiles and renders templates using its helpers and missing key policy.
+// Compiled templates are cached by source, up to MaxCompiled.
+type Engine struct {
+	Missing MissingKey
+	mu      sync.RWMutex
+	helpers map[string]Helper
+	cacheMu sync.RWMutex
+	cache   map[string]*Template
+}
+
+// New returns an engine with the built-in helpers.
+func New(missing MissingKey) *Engine {
+	var e = &Engine{Missing: missing, helpers: map[string]Helper{}, cache: map[string]*Template{}}
+	for name, helper := range builtins {
+		e.helpers[name] = helper
+	}
+	return e
+}
+
+// Default is the engine used by the package level functions. It keeps missing keys like tpl.Render.
+var Default = New(MissingKeep)
+
+// Register adds a helper to the engine, replacing any helper with the same name.
+func (e *Engine) Register(name string, helper Helper) {
+	e.mu.Lock()
+	e.helpers[name] = helper
+	e.mu.Unlock()
+}
+
+// helper returns the helper with the given name.
+func (e *Engine) helper(name string) (Helper, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	h, ok := e.helpers[name]
+	return h, ok
+}
+
+// Compile parses the source, reusing the cached template if it has already been compiled.
+func (e *Engine) Compile(src string) (*Template, error) {
+	e.cacheMu.RLock()
+	cached, ok := e.cache[src]
+	e.cacheMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+	t, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range t.segments {
+		for _, call := range segment.calls {
+			if _, ok := e.helper(call.name); !ok {
+				return nil, fmt.Errorf("unknown template helper %q", call.name)
+			}
+		}
+	}
+	t.engine = e
+	e.cacheMu.Lock()
+	if e.cache == nil || len(e.cache) >= MaxCompiled {
+		e.cache = map[string]*Template{}
+	}
+	e.cache[src] = t
+	e.cacheMu.Unlock()
+	return t, nil
+}
+
+// Execute compiles and renders the source with the given parameters.
+func (e *Engine) Execute(src string, params ...interface{}) (string, error) {
+	t, err := e.Compile(src)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(params...)
+}
+
+// Render renders the source, returning it unchanged if it can not be compiled or rendered.
+func (e *Engine) Render(src string, params ...interface{}) string {
+	result, err := e.Execute(src, params...)
+	if err != nil {
+		return src
+	}
+	return result
+}
+
+// Register adds a helper to the Default engine.
+func Register(name string, helper Helper) {
+	Default.Register(name, helper)
+}
+
+// Compile compiles the source using the Default engine.
+func Compile(src string) (*Template, error) {
+	return Default.Compile(src)
+}
+
+// Execute renders the source using the Default engine.
+func Execute(src string, params ...interface{}) (string, error) {
+	return Default.Execute(src, params...)
+}
+
+// Render renders the source using the Default engine. It is a drop-in replacement of tpl.Render.
+func Render(src string, params ...interface{}) string {
+	return Default.Render(src, params...)
+}
+
+// Template is a compiled template.
+type Template struct {
+	engine   *Engine
+	segments []segment
+}
+
+type segment struct {
+	literal string
+	raw     string
+	path    string
+	calls   []call
+}
+
+type call struct {
+	name string
+	args []string
+}
+
+// Execute renders the template. Variables are looked up in each parameter in order.
+func (t *Template) Execute(params ...interface{}) (string, error) {
+	var sb strings.Builder
+	for _, segment := range t.segments {
+		if segment.path == "" {
+			sb.WriteString(segment.literal)
+			continue
+		}
+		value, found := lookup(segment.path, params)
+		if !found && len(segment.calls) == 0 {
+			switch t.engine.Missing {
+			case MissingKeep:
+				sb.WriteString(segment.raw)
+				continue
+			case MissingError:
+				return "", fmt.Errorf("%w: %s", ErrMissingKey, segment.path)
+			}
+		}
+		for _, call := range segment.calls {
+			helper, _ := t.engine.helper(call.name)
+			var err error
+			if value, err = helper(value, call.args...); err != nil {
+				return "", fmt.Errorf("template helper %s: %w", call.name, err)
+			}
+		}
+		if value == nil && !found && t.engine.Missing == MissingError {
+			return "", fmt.Errorf("%w: %s", ErrMissingKey, segment.path)
+		}
+		sb.WriteString(format(value))
+	}
+	return sb.String(), nil
+}
+
+// lookup returns the value of the path in the first parameter defining it.
+func lookup(path string, params []interface{}) (interface{}, bool) {
+	for _, item := range params {
+		if v := get(item, path); v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// get returns the value of the path in the item, or nil if it can not be resolved, including when dot panics.
+func get(item interface{}, path string) (value interface{}) {
+	if item == nil {
+		return nil
+	}
+	defer func() {
+		if recover() != nil {
+			value = nil
+		}
+	}()
+	v, err := dot.Get(item, path)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// format returns the text representation of a value. Scalars are printed, other values are encoded as JSON.
+func format(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	var obj = generic.Parse(value)
+	if obj.IsAny(reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64) {
+		return fmt.Sprint(value)
+	}
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
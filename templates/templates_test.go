@@ -0,0 +1,89 @@
+package templates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name   string
+	Family string
+}
+
+func TestRender(t *testing.T) {
+	var params = map[string]interface{}{
+		"id":      42,
+		"user":    user{Name: "maria", Family: "Rossi"},
+		"created": time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		"empty":   "",
+	}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"/admin/order/$id/edit", "/admin/order/42/edit"},
+		{"Hello $user.Name $user.Family", "Hello maria Rossi"},
+		{"Hello ${user.Name | title}", "Hello Maria"},
+		{"${user.Family | upper | truncate 3}", "ROS"},
+		{`${created | date "02/01/2006 15:04"}`, "01/05/2024 10:00"},
+		{`${empty | default "n/a"}`, "n/a"},
+		{`${missing | default "n/a"}`, "n/a"},
+		{`${empty | default "{none}"} left`, "{none} left"},
+		{`${empty | default "a \"}\" b"}`, `a "}" b`},
+		{"cost $ 10, $missing", "cost $ 10, $missing"},
+	}
+	for _, test := range tests {
+		if got := Render(test.src, params); got != test.want {
+			t.Errorf("Render(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	var engine = New(MissingEmpty)
+	if got, _ := engine.Execute("a$missing b", nil); got != "a b" {
+		t.Errorf("MissingEmpty rendered %q", got)
+	}
+	engine = New(MissingError)
+	if _, err := engine.Execute("a $missing", map[string]interface{}{}); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("MissingError returned %v", err)
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	var engine = New(MissingKeep)
+	if _, err := engine.Compile("${name | exec}"); err == nil {
+		t.Error("unknown helper compiled")
+	}
+	engine.Register("exclaim", func(value interface{}, args ...string) (interface{}, error) {
+		return format(value) + "!", nil
+	})
+	if got := engine.Render("${name | exclaim}", map[string]string{"name": "hi"}); got != "hi!" {
+		t.Errorf("custom helper rendered %q", got)
+	}
+	a, _ := engine.Compile("${name | exclaim}")
+	b, _ := engine.Compile("${name | exclaim}")
+	if a != b {
+		t.Error("template not cached")
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	var limit = MaxCompiled
+	MaxCompiled = 3
+	defer func() { MaxCompiled = limit }()
+	var engine = New(MissingKeep)
+	first, _ := engine.Compile("$a")
+	if again, _ := engine.Compile("$a"); again != first {
+		t.Error("compiled template not reused")
+	}
+	for _, src := range []string{"$b", "$c", "$d", "$e"} {
+		if _, err := engine.Compile(src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(engine.cache) > MaxCompiled {
+		t.Errorf("cache holds %d templates, want at most %d", len(engine.cache), MaxCompiled)
+	}
+}
@@ -0,0 +1,30 @@
+package tenancy
+
+import (
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+type App struct {
+}
+
+// Register registers the membership model and makes the rest package resolve request tenants using the
+// configured resolvers, checking the membership of the users in the tenant_member table.
+func (a App) Register() error {
+	db.UseModel(Member{})
+	rest.TenantMember = IsMember
+	rest.TenantResolver = Resolve
+	return nil
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "tenancy"
+}
@@ -0,0 +1,35 @@
+package tenancy
+
+import (
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/db"
+	"github.com/iesitalia/toolbox/acl"
+)
+
+// Member grants a user access to a tenant.
+type Member struct {
+	Tenant string `gorm:"column:tenant_id;size:64;primaryKey" json:"tenant_id"`
+	User   string `gorm:"column:user;size:36;primaryKey;fk:users.uuid" json:"user"`
+}
+
+// TableName returns the name of the table for the Member struct.
+func (Member) TableName() string {
+	return "tenant_member"
+}
+
+// IsMember reports whether the user of the request is a member of the tenant. Users holding acl.Admin are
+// members of every tenant, users without uuid are members of none.
+func IsMember(request *evo.Request, tenant string) bool {
+	var user = request.User()
+	if user.Anonymous() {
+		return false
+	}
+	if user.HasPermission(acl.Admin) {
+		return true
+	}
+	var uuid = user.UUID()
+	if uuid == "" {
+		return false
+	}
+	return db.Where("tenant_id = ? AND `user` = ?", tenant, uuid).Take(&Member{}).RowsAffected > 0
+}
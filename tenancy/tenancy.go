@@ -0,0 +1,78 @@
+package tenancy
+
+import (
+	"strings"
+
+	"github.com/getevo/evo/v2"
+	"github.com/getevo/evo/v2/lib/generic"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// ClaimsLocal is the request local under which authentication middlewares store the token claims.
+const ClaimsLocal = "claims"
+
+// Resolver extracts the tenant of a request. It returns an empty string if the tenant cannot be determined.
+// Resolvers reading a tenant chosen by the client must only return it once rest.TenantMember verified it.
+type Resolver func(request *evo.Request) string
+
+// Resolvers holds the resolvers tried in order until one returns a tenant.
+var Resolvers = []Resolver{FromHeader(rest.TenantHeader)}
+
+// Resolve returns the tenant of the request using the configured resolvers.
+func Resolve(request *evo.Request) string {
+	for _, resolver := range Resolvers {
+		if tenant := resolver(request); tenant != "" {
+			return tenant
+		}
+	}
+	return ""
+}
+
+// FromHeader returns a resolver reading the tenant from the given request header, when the user of the request
+// is a member of the tenant, see rest.TenantMember.
+func FromHeader(header string) Resolver {
+	return func(request *evo.Request) string {
+		return verify(request, strings.TrimSpace(request.Header(header)))
+	}
+}
+
+// FromSubdomain returns a resolver reading the tenant from the first subdomain of the request host,
+// e.g. acme for acme.example.com, when the user of the request is a member of the tenant. The given subdomains
+// such as www or api are ignored.
+func FromSubdomain(ignore ...string) Resolver {
+	return func(request *evo.Request) string {
+		var subdomains = request.Subdomains()
+		if len(subdomains) == 0 {
+			return ""
+		}
+		var tenant = subdomains[0]
+		for _, item := range ignore {
+			if strings.EqualFold(item, tenant) {
+				return ""
+			}
+		}
+		return verify(request, tenant)
+	}
+}
+
+// FromClaim returns a resolver reading the tenant from the given claim of the authenticated token, trusted as
+// the token is signed. Claims are read from the ClaimsLocal request local populated by the authentication
+// middleware.
+func FromClaim(claim string) Resolver {
+	return func(request *evo.Request) string {
+		if claims, ok := request.Locals(ClaimsLocal).(map[string]interface{}); ok {
+			if v, exists := claims[claim]; exists && v != nil {
+				return generic.Parse(v).String()
+			}
+		}
+		return ""
+	}
+}
+
+// verify returns the tenant when the user of the request is a member of it, an empty string otherwise.
+func verify(request *evo.Request, tenant string) string {
+	if tenant == "" || !rest.TenantMember(request, tenant) {
+		return ""
+	}
+	return tenant
+}
@@ -0,0 +1,86 @@
+package tenancy
+
+import (
+	"testing"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/model"
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+type Invoice struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Number string `gorm:"size:32" json:"number"`
+	model.TenantID
+}
+
+type user struct {
+	evo.DefaultUserInterface
+	uuid  string
+	admin bool
+}
+
+func (u user) Anonymous() bool {
+	return false
+}
+
+func (u user) UUID() string {
+	return u.uuid
+}
+
+func (u user) HasPermission(permission string) bool {
+	return u.admin
+}
+
+func TestMembership(t *testing.T) {
+	var db = resttest.Setup(t, Invoice{}, Member{})
+	var member, resolver = rest.TenantMember, rest.TenantResolver
+	if err := (App{}).Register(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		rest.TenantMember, rest.TenantResolver = member, resolver
+	})
+	db.Create(&[]Invoice{{Number: "A-1", TenantID: model.TenantID{TenantID: "acme"}}, {Number: "G-1", TenantID: model.TenantID{TenantID: "globex"}}})
+	db.Create(&Member{Tenant: "acme", User: "ann"})
+
+	var numbers = func(tenant string) []string {
+		t.Helper()
+		resttest.WithHeader(t, rest.TenantHeader, tenant)
+		var result []string
+		for _, item := range resttest.Get[[]Invoice](t, "/admin/rest/invoices/all").Data {
+			result = append(result, item.Number)
+		}
+		return result
+	}
+	t.Run("member", func(t *testing.T) {
+		resttest.AsUser(t, user{uuid: "ann"})
+		if got := numbers("acme"); len(got) != 1 || got[0] != "A-1" {
+			t.Errorf("member of acme got %v", got)
+		}
+	})
+	t.Run("not a member", func(t *testing.T) {
+		resttest.AsUser(t, user{uuid: "ann"})
+		if got := numbers("globex"); len(got) != 0 {
+			t.Errorf("member of acme got the rows of globex %v", got)
+		}
+	})
+	t.Run("no uuid", func(t *testing.T) {
+		resttest.AsUser(t, user{})
+		if got := numbers("acme"); len(got) != 0 {
+			t.Errorf("user without uuid got the rows of acme %v", got)
+		}
+	})
+	t.Run("anonymous", func(t *testing.T) {
+		if got := numbers("acme"); len(got) != 0 {
+			t.Errorf("anonymous user got the rows of acme %v", got)
+		}
+	})
+	t.Run("admin", func(t *testing.T) {
+		resttest.AsUser(t, user{uuid: "root", admin: true})
+		if got := numbers("globex"); len(got) != 1 || got[0] != "G-1" {
+			t.Errorf("admin got %v", got)
+		}
+	})
+}
@@ -175,6 +175,31 @@ var slugSub = map[rune]string{
 	'я':  "ya",
 }
 
+// typographySub is a map that holds the mapping of typographic characters to their plain ASCII counterparts.
+var typographySub = map[rune]string{
+	'‘':      "'",
+	'’':      "'",
+	'‚':      "'",
+	'“':      "\"",
+	'”':      "\"",
+	'„':      "\"",
+	'‒':      "-", // figure dash
+	'–':      "-", // en dash
+	'—':      "-", // em dash
+	'―':      "-", // horizontal bar
+	'…':      "...",
+	'\u00a0': " ", // no-break space
+	'\u2009': " ", // thin space
+	'\u200b': "",  // zero width space
+	'\ufeff': "",  // byte order mark
+}
+
+// NormalizeUnicode replaces typographic quotes, dashes, ellipsis and special spaces with their plain ASCII counterparts.
+// Other characters, including letters with diacritics, are kept as they are.
+func NormalizeUnicode(in string) string {
+	return SubstituteRune(in, typographySub)
+}
+
 // NormalizeFilename takes an input string and returns a normalized filename.
 // The normalization process involves converting non-ASCII characters to their closest ASCII equivalents,
 // converting all characters to lowercase, removing or substituting certain characters, and trimming leading/trailing dashes and underscores.
@@ -0,0 +1,239 @@
+// Package tracing decides which requests are traced and carries their W3C trace context.
+//
+// A request is traced when the ForceHeader header is set or the incoming traceparent is sampled by a Trusted
+// caller, or at random with the rate of its resource, read from the TRACING.RATE.<RESOURCE> setting and
+// falling back to TRACING.RATE (0 to 1, default 0). Traced rest requests log every SQL statement
+// and a summary span, so heavy diagnostics can be enabled on a single resource:
+//
+//	settings.SetEnv("production", "TRACING.RATE.ORDER", 0.05)
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getevo/evo/v2"
+	"github.com/iesitalia/toolbox/httpclient"
+	"github.com/iesitalia/toolbox/logger"
+	"github.com/iesitalia/toolbox/settings"
+)
+
+// ForceHeader specifies the header forcing the request to be traced, e.g. `X-Trace: 1`.
+var ForceHeader = "X-Trace"
+
+// Trusted reports whether the caller of the request may force its tracing with the ForceHeader header or the
+// sampled flag of its traceparent, by default when the user is authenticated. The traces of the other callers
+// are continued but sampled with the rate of the resource.
+var Trusted = func(request *evo.Request) bool {
+	var user = request.User()
+	return !user.Anonymous()
+}
+
+// SpanLocal is the request local holding the span of the request.
+const SpanLocal = "trace_span"
+
+// Span is the part of a trace handled by this service for a request, or an operation within it, see Child.
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	State    string
+	Sampled  bool
+	Resource string
+	Name     string
+	Start    time.Time
+}
+
+// Exporter receives the sampled spans once finished, along with the fields given to Finish, e.g. to forward
+// them to an OpenTelemetry collector. The ids of the spans follow the W3C trace context, as OpenTelemetry does.
+type Exporter func(span *Span, end time.Time, fields ...interface{})
+
+var exporters []Exporter
+var exportersMu sync.RWMutex
+
+// AddExporter adds an exporter of the finished spans.
+func AddExporter(exporter Exporter) {
+	exportersMu.Lock()
+	exporters = append(exporters, exporter)
+	exportersMu.Unlock()
+}
+
+type spanKey struct{}
+
+// WithSpan returns a copy of ctx carrying the span, read back by FromContext, e.g. by the database callbacks.
+func WithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext returns the span carried by ctx, nil if there is none.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Child returns a span for an operation within the span, e.g. a query, sampled like its parent.
+// It returns nil for a nil span.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return &Span{
+		TraceID:  s.TraceID,
+		SpanID:   newID(8),
+		ParentID: s.SpanID,
+		State:    s.State,
+		Sampled:  s.Sampled,
+		Resource: s.Resource,
+		Name:     name,
+		Start:    time.Now(),
+	}
+}
+
+// Rate returns the sampling rate of the resource.
+func Rate(resource string) float64 {
+	var rate = settings.GetAs[float64]("TRACING.RATE", 0)
+	if resource != "" {
+		rate = settings.GetAs[float64]("TRACING.RATE."+resource, rate)
+	}
+	return rate
+}
+
+// Start returns the span of the request, creating it on the first call.
+// The traceparent of the span is echoed in the response header.
+func Start(request *evo.Request, resource string) *Span {
+	if span := FromRequest(request); span != nil {
+		return span
+	}
+	var span = newSpan(request.Header("traceparent"), request.Header("tracestate"), forced(request.Header(ForceHeader)), Trusted(request), Rate(resource), random())
+	span.Resource = resource
+	request.Locals(SpanLocal, span)
+	request.SetHeader("traceparent", span.Traceparent())
+	return span
+}
+
+// FromRequest returns the span of the request, nil if the request is not traced yet.
+func FromRequest(request *evo.Request) *Span {
+	if span, ok := request.Locals(SpanLocal).(*Span); ok {
+		return span
+	}
+	return nil
+}
+
+func forced(header string) bool {
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// newSpan returns a span continuing the incoming trace, if valid, sampled according to the force flag or the
+// sampled flag of the parent when the caller is trusted, or else the rate compared to the random value in [0, 1).
+func newSpan(traceparent string, tracestate string, force bool, trusted bool, rate float64, random float64) *Span {
+	var span = &Span{SpanID: newID(8), Start: time.Now()}
+	traceID, parentID, flags, ok := parseTraceparent(traceparent)
+	if ok {
+		span.TraceID = traceID
+		span.ParentID = parentID
+		span.State = tracestate
+	} else {
+		span.TraceID = newID(16)
+	}
+	switch {
+	case force && trusted:
+		span.Sampled = true
+	case ok && trusted:
+		span.Sampled = flags&1 == 1
+	default:
+		span.Sampled = random < rate
+	}
+	return span
+}
+
+// parseTraceparent returns the fields of a version 00 traceparent header.
+func parseTraceparent(value string) (traceID string, parentID string, flags byte, ok bool) {
+	var parts = strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", 0, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", 0, false
+	}
+	for _, part := range parts[:4] {
+		if _, err := hex.DecodeString(part); err != nil || part != strings.ToLower(part) {
+			return "", "", 0, false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", 0, false
+	}
+	b, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], b[0], true
+}
+
+// Traceparent returns the traceparent header identifying the span.
+func (s *Span) Traceparent() string {
+	var flags = "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// IsSampled reports whether the span is traced. It is false for a nil span.
+func (s *Span) IsSampled() bool {
+	return s != nil && s.Sampled
+}
+
+// Context returns a copy of ctx propagating the span to the requests of httpclient.
+func (s *Span) Context(ctx context.Context) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return httpclient.WithTraceContext(ctx, s.Traceparent(), s.State)
+}
+
+// Finish logs the span with its duration and the given fields if it is sampled, and passes it to the exporters.
+// The logger is expected to carry the trace id, as the loggers of the rest requests do. A nil logger logs nothing.
+func (s *Span) Finish(l *logger.Logger, fields ...interface{}) {
+	if !s.IsSampled() {
+		return
+	}
+	var end = time.Now()
+	if l != nil {
+		var params = append([]interface{}{"span_id", s.SpanID, "parent_id", s.ParentID, "elapsed", end.Sub(s.Start).String()}, fields...)
+		if s.Name != "" {
+			params = append(params, "name", s.Name)
+		}
+		l.Info("span", params...)
+	}
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	for _, exporter := range exporters {
+		exporter(s, end, fields...)
+	}
+}
+
+func newID(size int) string {
+	var b = make([]byte, size)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// random returns a random value in [0, 1).
+func random() float64 {
+	var b = make([]byte, 8)
+	_, _ = rand.Read(b)
+	return float64(binary.BigEndian.Uint64(b)>>11) / (1 << 53)
+}
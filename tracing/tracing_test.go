@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewSpan(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var tests = []struct {
+		name        string
+		traceparent string
+		force       bool
+		trusted     bool
+		rate        float64
+		random      float64
+		sampled     bool
+		continued   bool
+	}{
+		{"forced", "", true, true, 0, 0.5, true, false},
+		{"sampled parent", parent, false, true, 0, 0.5, true, true},
+		{"unsampled parent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, true, 1, 0, false, true},
+		{"forced unsampled parent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, true, 0, 0, true, true},
+		{"untrusted forced", "", true, false, 0, 0.5, false, false},
+		{"untrusted sampled parent", parent, false, false, 0, 0.5, false, true},
+		{"untrusted below rate", parent, true, false, 0.1, 0.05, true, true},
+		{"below rate", "", false, true, 0.1, 0.05, true, false},
+		{"above rate", "", false, true, 0.1, 0.5, false, false},
+		{"zero rate", "", false, true, 0, 0, false, false},
+		{"invalid parent", "00-xyz-00f067aa0ba902b7-01", false, true, 0, 0.5, false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, true, 0, 0.5, false, false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", false, true, 0, 0.5, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var span = newSpan(tt.traceparent, "", tt.force, tt.trusted, tt.rate, tt.random)
+			if span.Sampled != tt.sampled {
+				t.Errorf("Sampled = %v, want %v", span.Sampled, tt.sampled)
+			}
+			if continued := span.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736"; continued != tt.continued {
+				t.Errorf("continued = %v, want %v", continued, tt.continued)
+			}
+			if _, _, _, ok := parseTraceparent(span.Traceparent()); !ok {
+				t.Errorf("Traceparent() = %s is not valid", span.Traceparent())
+			}
+		})
+	}
+}
+
+func TestChild(t *testing.T) {
+	var parent = &Span{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, Resource: "order"}
+	var child = parent.Child("sql query")
+	if child.TraceID != parent.TraceID || child.ParentID != parent.SpanID || child.SpanID == parent.SpanID {
+		t.Errorf("Child() = %+v, want a span of the trace with parent %s", child, parent.SpanID)
+	}
+	if !child.Sampled || child.Name != "sql query" || child.Resource != "order" {
+		t.Errorf("Child() = %+v, want a sampled span named sql query", child)
+	}
+	var none *Span
+	if none.Child("sql query") != nil {
+		t.Errorf("Child() of a nil span is not nil")
+	}
+}
+
+func TestWithSpan(t *testing.T) {
+	var span = &Span{SpanID: "00f067aa0ba902b7"}
+	if got := FromContext(WithSpan(context.Background(), span)); got != span {
+		t.Errorf("FromContext() = %v, want %v", got, span)
+	}
+	if got := FromContext(context.Background()); got != nil {
+		t.Errorf("FromContext() = %v, want nil", got)
+	}
+	if got := FromContext(WithSpan(context.Background(), nil)); got != nil {
+		t.Errorf("FromContext() = %v, want nil", got)
+	}
+}
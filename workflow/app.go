@@ -0,0 +1,27 @@
+package workflow
+
+import (
+	"github.com/iesitalia/toolbox/rest"
+)
+
+type App struct {
+}
+
+// Register makes the create and update endpoints of the rest resources enforce the workflows of their models.
+func (a App) Register() error {
+	rest.AddCreateHook(beforeCreate)
+	rest.AddUpdateHook(rest.UpdateHook{Before: beforeUpdate, After: afterUpdate})
+	return nil
+}
+
+func (a App) Router() error {
+	return nil
+}
+
+func (a App) WhenReady() error {
+	return nil
+}
+
+func (a App) Name() string {
+	return "workflow"
+}
@@ -0,0 +1,271 @@
+// Package workflow restricts the changes of a status field of a rest resource to the transitions of a state machine.
+//
+// A model declares its workflow by implementing Workflow() *workflow.Workflow and embeds Stateful to expose
+// the legal transitions of its rows:
+//
+//	var orderWorkflow = &workflow.Workflow{
+//		Field:  "status",
+//		States: []string{"draft", "submitted", "approved", "rejected"},
+//		Transitions: []workflow.Transition{
+//			{Name: "submit", From: []string{"draft", "rejected"}, To: "submitted"},
+//			{Name: "approve", From: []string{"submitted"}, To: "approved", Permission: "APPROVE", Effect: notifyCustomer},
+//			{Name: "reject", From: []string{"submitted"}, To: "rejected", Permission: "APPROVE"},
+//		},
+//	}
+//
+//	type Order struct {
+//		ID     uint64 `gorm:"column:id;primaryKey" json:"id"`
+//		Status string `gorm:"column:status;size:16" json:"status"`
+//		workflow.Stateful
+//		rest.API
+//	}
+//
+//	func (Order) Workflow() *workflow.Workflow { return orderWorkflow }
+//
+// Once the App is registered, the create, set and clone endpoints of the resource reject the rows which are not
+// in an initial state, and the update endpoints reject the changes of the field which are not a transition the
+// user is allowed to make, and run the effect of the transition after the change is saved.
+package workflow
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/iesitalia/toolbox/acl"
+	"github.com/iesitalia/toolbox/rest"
+)
+
+// ErrIllegalTransition is returned when a row is moved to a state no transition leads to from its current state.
+var ErrIllegalTransition = errors.New("illegal state transition")
+
+// ErrUnknownState is returned when a row is moved to a state not listed in the workflow.
+var ErrUnknownState = errors.New("unknown state")
+
+// ErrInitialState is returned when a row is created in a state which is not an initial state of the workflow.
+var ErrInitialState = errors.New("illegal initial state")
+
+// Workflow is the state machine of the status field of a model.
+// - Field: column or struct field holding the state, defaults to status.
+// - States: the states of the rows, any value is accepted when empty.
+// - Initial: the states the rows are created in, the first of States when empty. Rows created without state
+// get the default of the column.
+// - Transitions: the allowed changes of state.
+type Workflow struct {
+	Field       string
+	States      []string
+	Initial     []string
+	Transitions []Transition
+}
+
+// Transition moves a row from one of the From states, or from any state when From is empty, to the To state.
+// - Permission: key of the permission of the resource required to make the transition, e.g. APPROVE.
+// - Guard: rejects the transition for the given row by returning an error.
+// - Effect: runs after the transition is saved, e.g. to send a notification.
+type Transition struct {
+	Name       string                                                `json:"name"`
+	From       []string                                              `json:"from,omitempty"`
+	To         string                                                `json:"to"`
+	Permission string                                                `json:"permission,omitempty"`
+	Guard      func(context *rest.Context, object interface{}) error `json:"-"`
+	Effect     func(context *rest.Context, object interface{}) error `json:"-"`
+}
+
+// from reports whether the transition starts from the state.
+func (t Transition) from(state string) bool {
+	if len(t.From) == 0 {
+		return true
+	}
+	for _, item := range t.From {
+		if item == state {
+			return true
+		}
+	}
+	return false
+}
+
+// field returns the name of the field holding the state.
+func (w *Workflow) field() string {
+	if w.Field == "" {
+		return "status"
+	}
+	return w.Field
+}
+
+// known reports whether the state is one of the states of the workflow.
+func (w *Workflow) known(state string) bool {
+	if len(w.States) == 0 {
+		return true
+	}
+	for _, item := range w.States {
+		if item == state {
+			return true
+		}
+	}
+	return false
+}
+
+// initial reports whether the rows can be created in the state.
+func (w *Workflow) initial(state string) bool {
+	if len(w.Initial) == 0 {
+		return len(w.States) == 0 || w.States[0] == state
+	}
+	for _, item := range w.Initial {
+		if item == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the transitions leading out of the state, in order of declaration.
+func (w *Workflow) Next(state string) []Transition {
+	var result []Transition
+	for _, t := range w.Transitions {
+		if t.from(state) && t.To != state {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Find returns the first transition moving a row from one state to the other.
+func (w *Workflow) Find(from, to string) (Transition, error) {
+	if !w.known(to) {
+		return Transition{}, fmt.Errorf("%w: %s", ErrUnknownState, to)
+	}
+	for _, t := range w.Transitions {
+		if t.To == to && t.from(from) {
+			return t, nil
+		}
+	}
+	return Transition{}, fmt.Errorf("%w: %s to %s", ErrIllegalTransition, from, to)
+}
+
+// Allowed checks the permission and the guard of the transition for the user of the context and the row.
+func (t Transition) Allowed(context *rest.Context, object interface{}) error {
+	if t.Permission != "" {
+		if err := context.HasPerm(t.Permission); err != nil {
+			return err
+		}
+	}
+	if t.Guard != nil {
+		return t.Guard(context, object)
+	}
+	return nil
+}
+
+// Of returns the workflow of the object, nil when its model has none.
+func Of(object interface{}) *Workflow {
+	if obj, ok := object.(interface{ Workflow() *Workflow }); ok {
+		return obj.Workflow()
+	}
+	return nil
+}
+
+// State returns the state of the object.
+func State(context *rest.Context, w *Workflow, object interface{}) string {
+	var field = context.Schema.LookUpField(w.field())
+	if field == nil {
+		return ""
+	}
+	v, _ := field.ValueOf(stdcontext.Background(), reflect.Indirect(reflect.ValueOf(object)))
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// transition returns the transition made by an update, false when the state does not change.
+func transition(context *rest.Context, previous, current interface{}) (Transition, bool, error) {
+	var w = Of(current)
+	if w == nil {
+		return Transition{}, false, nil
+	}
+	var from, to = State(context, w, previous), State(context, w, current)
+	if from == to {
+		return Transition{}, false, nil
+	}
+	t, err := w.Find(from, to)
+	return t, err == nil, err
+}
+
+// beforeCreate rejects the rows created in a state which is not an initial state.
+func beforeCreate(context *rest.Context, current interface{}) error {
+	var w = Of(current)
+	if w == nil {
+		return nil
+	}
+	if state := State(context, w, current); state != "" && !w.initial(state) {
+		return fmt.Errorf("%w: %s", ErrInitialState, state)
+	}
+	return nil
+}
+
+// beforeUpdate rejects the changes of state which are not a transition allowed to the user.
+func beforeUpdate(context *rest.Context, previous, current interface{}) error {
+	t, changed, err := transition(context, previous, current)
+	if err != nil || !changed {
+		return err
+	}
+	return t.Allowed(context, current)
+}
+
+// afterUpdate runs the effect of the transition made by the update. The change is already saved, so the
+// errors of the effect are logged rather than failing the request.
+func afterUpdate(context *rest.Context, previous, current interface{}) error {
+	t, changed, err := transition(context, previous, current)
+	if err != nil || !changed || t.Effect == nil {
+		return err
+	}
+	if err := t.Effect(context, current); err != nil {
+		context.Logger().Error("workflow effect failed", "transition", t.Name, "error", err.Error())
+	}
+	return nil
+}
+
+// Stateful exposes the transitions of the rows of the models embedding it.
+// GET /rest/:table/transitions/:pk lists the transitions the user can make from the state of the row.
+type Stateful struct{}
+
+// RESTActions registers the transitions endpoint on the resources of models embedding Stateful.
+func (Stateful) RESTActions() []*rest.Endpoint {
+	return []*rest.Endpoint{
+		{
+			Name:        "TRANSITIONS",
+			Method:      rest.GET,
+			URL:         "/transitions",
+			PKUrl:       true,
+			Handler:     Transitions,
+			Description: "list the legal next states of the row",
+			Permissions: []acl.Permission{rest.ListPermission},
+		},
+	}
+}
+
+// Transitions lists the transitions the user can make from the current state of the row given by the primary key.
+func Transitions(context *rest.Context) error {
+	if err := context.HasPerm("VIEW"); err != nil {
+		return err
+	}
+	var object = context.GetObject()
+	var ptr = object.Addr().Interface()
+	found, err := context.FindByPrimaryKey(ptr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return rest.ErrorObjectNotExist
+	}
+	var result = []Transition{}
+	if w := Of(ptr); w != nil {
+		for _, t := range w.Next(State(context, w, ptr)) {
+			if t.Allowed(context, ptr) == nil {
+				result = append(result, t)
+			}
+		}
+	}
+	context.Response.Data = result
+	return nil
+}
@@ -0,0 +1,119 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/iesitalia/toolbox/rest"
+	"github.com/iesitalia/toolbox/resttest"
+)
+
+var testWorkflow = &Workflow{
+	States: []string{"draft", "submitted", "approved", "rejected", "archived"},
+	Transitions: []Transition{
+		{Name: "submit", From: []string{"draft", "rejected"}, To: "submitted"},
+		{Name: "approve", From: []string{"submitted"}, To: "approved", Permission: "APPROVE"},
+		{Name: "reject", From: []string{"submitted"}, To: "rejected", Permission: "APPROVE"},
+		{Name: "archive", To: "archived"},
+	},
+}
+
+func TestFind(t *testing.T) {
+	var tests = []struct {
+		from, to string
+		name     string
+		err      error
+	}{
+		{"draft", "submitted", "submit", nil},
+		{"rejected", "submitted", "submit", nil},
+		{"submitted", "approved", "approve", nil},
+		{"approved", "archived", "archive", nil},
+		{"draft", "approved", "", ErrIllegalTransition},
+		{"approved", "submitted", "", ErrIllegalTransition},
+		{"draft", "deleted", "", ErrUnknownState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			transition, err := testWorkflow.Find(tt.from, tt.to)
+			if !errors.Is(err, tt.err) || transition.Name != tt.name {
+				t.Errorf("Find() = %q %v, want %q %v", transition.Name, err, tt.name, tt.err)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	var tests = []struct {
+		state string
+		want  []string
+	}{
+		{"draft", []string{"submit", "archive"}},
+		{"submitted", []string{"approve", "reject", "archive"}},
+		{"archived", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			var got []string
+			for _, transition := range testWorkflow.Next(tt.state) {
+				got = append(got, transition.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Next(%s) = %v, want %v", tt.state, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Next(%s) = %v, want %v", tt.state, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+type ticket struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Status string `gorm:"size:16" json:"status"`
+	Stateful
+}
+
+var ticketWorkflow = &Workflow{
+	States: []string{"open", "closed"},
+	Transitions: []Transition{
+		{Name: "close", From: []string{"open"}, To: "closed", Effect: func(context *rest.Context, object interface{}) error {
+			return errors.New("mailer down")
+		}},
+	},
+}
+
+func (ticket) Workflow() *Workflow {
+	return ticketWorkflow
+}
+
+func TestEndpoints(t *testing.T) {
+	var db = resttest.Setup(t, ticket{})
+	if err := (App{}).Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	if page := resttest.Do[ticket](t, http.MethodPut, "/admin/rest/tickets", ticket{Status: "closed"}); page.Success {
+		t.Errorf("expected a ticket created closed to be rejected")
+	}
+	var created = resttest.Put[ticket](t, "/admin/rest/tickets", ticket{Status: "open"})
+	var path = fmt.Sprintf("/admin/rest/tickets/%d", created.Data.ID)
+	var transitions = resttest.Get[[]Transition](t, fmt.Sprintf("/admin/rest/tickets/transitions/%d", created.Data.ID))
+	if len(transitions.Data) != 1 || transitions.Data[0].Name != "close" {
+		t.Errorf("expected the transitions in the data of the response, got %+v", transitions.Data)
+	}
+	if page := resttest.Do[ticket](t, http.MethodPost, path, map[string]string{"status": "closed"}); !page.Success {
+		t.Errorf("expected the failing effect not to fail the saved transition, got %+v", page)
+	}
+	var stored ticket
+	db.Take(&stored, created.Data.ID)
+	if stored.Status != "closed" {
+		t.Errorf("expected the ticket to be closed, got %q", stored.Status)
+	}
+	if page := resttest.Do[ticket](t, http.MethodPost, path+"/clone", nil); page.Success {
+		t.Errorf("expected the clone of a closed ticket to be rejected")
+	}
+}